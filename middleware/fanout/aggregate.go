@@ -0,0 +1,92 @@
+package fanout
+
+import "github.com/Comcast/webpa-common/tracing"
+
+// Result describes the outcome of invoking a single component endpoint of a fanout.
+type Result struct {
+	// Name is the component name, i.e. the key from the Components map
+	Name string
+
+	// Span is the span recorded for the component.  This will be nil if the component did not
+	// finish before the fanout's context was canceled.
+	Span tracing.Span
+
+	// Response is the object returned by the component endpoint.  This is nil if Err is set.
+	Response interface{}
+
+	// Err is the error returned by the component endpoint.  If the component did not finish
+	// before the fanout's context was canceled, this field will be the context's error.
+	Err error
+
+	// notRun is set when the component was never invoked, e.g. because of hedging or a saturated WorkerPool
+	notRun bool
+}
+
+// AggregateResponse is the fanout response returned when the PartialSuccess option is used.  It
+// contains the result of each component, in the order in which the components finished.
+type AggregateResponse struct {
+	// Results holds one Result for each component of the fanout
+	Results []Result
+
+	spans []tracing.Span
+}
+
+func (ar *AggregateResponse) Spans() []tracing.Span {
+	return ar.spans
+}
+
+func (ar *AggregateResponse) WithSpans(s ...tracing.Span) interface{} {
+	copyOf := *ar
+	copyOf.spans = s
+	return &copyOf
+}
+
+// Succeeded returns the results for components which returned without an error
+func (ar *AggregateResponse) Succeeded() []Result {
+	var succeeded []Result
+	for _, r := range ar.Results {
+		if r.Err == nil {
+			succeeded = append(succeeded, r)
+		}
+	}
+
+	return succeeded
+}
+
+// Failed returns the results for components which returned an error or which did not finish
+// before the fanout's context was canceled
+func (ar *AggregateResponse) Failed() []Result {
+	var failed []Result
+	for _, r := range ar.Results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// hasSuccess tests if at least one component returned without an error
+func (ar *AggregateResponse) hasSuccess() bool {
+	for _, r := range ar.Results {
+		if r.Err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// complete records a result with the given error for each component which does not yet have a result
+func (ar *AggregateResponse) complete(endpoints Components, err error) {
+	finished := make(map[string]bool, len(ar.Results))
+	for _, r := range ar.Results {
+		finished[r.Name] = true
+	}
+
+	for name := range endpoints {
+		if !finished[name] {
+			ar.Results = append(ar.Results, Result{Name: name, Err: err})
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateResponse(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		spanner       = tracing.NewSpanner()
+		spans         = []tracing.Span{spanner.Start("success")(nil), spanner.Start("failure")(expectedError)}
+
+		aggregate = &AggregateResponse{
+			Results: []Result{
+				{Name: "success", Span: spans[0], Response: "response"},
+				{Name: "failure", Span: spans[1], Err: expectedError},
+			},
+		}
+	)
+
+	assert.Empty(aggregate.Spans())
+	assert.True(aggregate.hasSuccess())
+	assert.Equal([]Result{aggregate.Results[0]}, aggregate.Succeeded())
+	assert.Equal([]Result{aggregate.Results[1]}, aggregate.Failed())
+
+	merged, ok := tracing.MergeSpans(aggregate, spans)
+	require.True(ok)
+	assert.Equal(spans, merged.(*AggregateResponse).Spans())
+	assert.Equal(aggregate.Results, merged.(*AggregateResponse).Results)
+	assert.Empty(aggregate.Spans())
+
+	aggregate.complete(
+		Components{
+			"success":    nil,
+			"failure":    nil,
+			"unfinished": nil,
+		},
+		context.DeadlineExceeded,
+	)
+
+	require.Len(aggregate.Results, 3)
+	assert.Equal(Result{Name: "unfinished", Err: context.DeadlineExceeded}, aggregate.Results[2])
+	assert.Len(aggregate.Failed(), 2)
+
+	failures := &AggregateResponse{Results: []Result{{Name: "failure", Err: expectedError}}}
+	assert.False(failures.hasSuccess())
+	assert.Empty(failures.Succeeded())
+}
+
+func testNewPartialSuccessAllFinish(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"success1": func(context.Context, interface{}) (interface{}, error) { return "response1", nil },
+				"success2": func(context.Context, interface{}) (interface{}, error) { return "response2", nil },
+				"failure":  func(context.Context, interface{}) (interface{}, error) { return nil, expectedError },
+			},
+			PartialSuccess(),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+
+	aggregate, ok := response.(*AggregateResponse)
+	require.True(ok)
+	assert.Len(aggregate.Results, 3)
+	assert.Len(aggregate.Spans(), 3)
+	assert.Len(aggregate.Succeeded(), 2)
+
+	results := make(map[string]Result)
+	for _, r := range aggregate.Results {
+		require.NotNil(r.Span)
+		assert.Equal(r.Name, r.Span.Name())
+		results[r.Name] = r
+	}
+
+	assert.Equal("response1", results["success1"].Response)
+	assert.Equal("response2", results["success2"].Response)
+	assert.Equal(expectedError, results["failure"].Err)
+}
+
+func testNewPartialSuccessTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		slowGate    = make(chan struct{})
+		successDone = make(chan struct{})
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"success": func(context.Context, interface{}) (interface{}, error) {
+					defer close(successDone)
+					return "response", nil
+				},
+				"slow": func(context.Context, interface{}) (interface{}, error) {
+					<-slowGate
+					return "too late", nil
+				},
+			},
+			PartialSuccess(),
+		)
+	)
+
+	defer close(slowGate)
+	require.NotNil(fanout)
+
+	go func() {
+		<-successDone
+		cancel()
+	}()
+
+	response, err := fanout(ctx, "request")
+
+	// because of select timings, the context cancellation can be seen before the successful component
+	if err != nil {
+		spanError := err.(tracing.SpanError)
+		assert.Equal(context.Canceled, spanError.Err())
+		return
+	}
+
+	aggregate, ok := response.(*AggregateResponse)
+	require.True(ok)
+	require.Len(aggregate.Results, 2)
+	assert.Equal(Result{Name: "slow", Err: context.Canceled}, aggregate.Failed()[0])
+	require.Len(aggregate.Succeeded(), 1)
+	assert.Equal("response", aggregate.Succeeded()[0].Response)
+}
+
+func testNewPartialSuccessAllFail(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		failure       = func(context.Context, interface{}) (interface{}, error) { return nil, expectedError }
+
+		fanout = New(
+			tracing.NewSpanner(),
+			map[string]endpoint.Endpoint{"failure1": failure, "failure2": failure},
+			PartialSuccess(),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.Nil(response)
+	require.Error(err)
+
+	spanError := err.(tracing.SpanError)
+	assert.Equal(expectedError, spanError.Err())
+	assert.Len(spanError.Spans(), 2)
+}
+
+func TestNewPartialSuccess(t *testing.T) {
+	t.Run("AllFinish", testNewPartialSuccessAllFinish)
+	t.Run("Timeout", testNewPartialSuccessTimeout)
+	t.Run("AllFail", testNewPartialSuccessAllFail)
+}
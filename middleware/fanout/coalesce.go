@@ -0,0 +1,82 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// call is an in-flight, coalesced invocation of an endpoint
+type call struct {
+	done     chan struct{}
+	response interface{}
+	err      error
+}
+
+// coalescer tracks the in-flight calls for a single decorated endpoint
+type coalescer struct {
+	lock     sync.Mutex
+	key      KeyFunc
+	next     endpoint.Endpoint
+	inFlight map[string]*call
+}
+
+func (c *coalescer) invoke(ctx context.Context, v interface{}) (interface{}, error) {
+	k := string(c.key(NewContext(ctx, v), v))
+	if len(k) == 0 {
+		return c.next(ctx, v)
+	}
+
+	c.lock.Lock()
+	if existing, ok := c.inFlight[k]; ok {
+		c.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-existing.done:
+			return existing.response, existing.err
+		}
+	}
+
+	leader := &call{done: make(chan struct{})}
+	c.inFlight[k] = leader
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.inFlight, k)
+		c.lock.Unlock()
+		close(leader.done)
+	}()
+
+	leader.response, leader.err = c.next(ctx, v)
+	return leader.response, leader.err
+}
+
+// Coalesce produces a middleware that merges identical, concurrent requests into a single invocation of the
+// decorated endpoint.  Requests are identical if the given KeyFunc returns the same key for them, e.g. a digest of
+// the request entity.  The first request for a key invokes the endpoint, and every other request with that key which
+// arrives before the endpoint returns receives the same response and error.
+//
+// Because the shared invocation uses the first request's context, cancellation of that context affects every coalesced
+// request.  Each of the other requests still honors its own context, returning that context's error if it is canceled
+// before the shared invocation completes.  Requests for which the KeyFunc returns an empty key are never coalesced.
+//
+// Typically, this middleware decorates the endpoint returned by New.  If key is nil, this function panics.
+func Coalesce(key KeyFunc) endpoint.Middleware {
+	if key == nil {
+		panic("A KeyFunc is required")
+	}
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		c := &coalescer{
+			key:      key,
+			next:     next,
+			inFlight: make(map[string]*call),
+		}
+
+		return c.invoke
+	}
+}
@@ -0,0 +1,137 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCoalesceNilKey(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { Coalesce(nil) })
+}
+
+// waitingContext signals each call to Done.  A coalesced follower only calls Done once it is waiting on the leader's call.
+type waitingContext struct {
+	context.Context
+	waiting chan<- struct{}
+}
+
+func (wc waitingContext) Done() <-chan struct{} {
+	wc.waiting <- struct{}{}
+	return wc.Context.Done()
+}
+
+func testCoalesceConcurrent(t *testing.T) {
+	const followerCount = 5
+
+	var (
+		assert = assert.New(t)
+
+		calls   int32
+		started = make(chan struct{})
+		gate    = make(chan struct{})
+
+		c = &coalescer{
+			key: stringKey,
+			next: func(ctx context.Context, v interface{}) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				close(started)
+				<-gate
+				return "shared response", nil
+			},
+			inFlight: make(map[string]*call),
+		}
+
+		responses = make(chan interface{}, followerCount+1)
+		waiting   = make(chan struct{}, followerCount)
+		everyone  = new(sync.WaitGroup)
+	)
+
+	everyone.Add(followerCount + 1)
+	go func() {
+		defer everyone.Done()
+		response, err := c.invoke(context.Background(), "key")
+		assert.NoError(err)
+		responses <- response
+	}()
+
+	<-started
+	for i := 0; i < followerCount; i++ {
+		go func() {
+			defer everyone.Done()
+			response, err := c.invoke(waitingContext{context.Background(), waiting}, "key")
+			assert.NoError(err)
+			responses <- response
+		}()
+	}
+
+	// wait until every follower is waiting on the leader's call
+	for i := 0; i < followerCount; i++ {
+		<-waiting
+	}
+
+	close(gate)
+	everyone.Wait()
+
+	for i := 0; i < followerCount+1; i++ {
+		assert.Equal("shared response", <-responses)
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	assert.Empty(c.inFlight)
+}
+
+func testCoalesceFollowerCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		started     = make(chan struct{})
+		gate        = make(chan struct{})
+		ctx, cancel = context.WithCancel(context.Background())
+
+		coalesced = Coalesce(stringKey)(func(ctx context.Context, v interface{}) (interface{}, error) {
+			close(started)
+			<-gate
+			return "response", nil
+		})
+	)
+
+	defer close(gate)
+	go coalesced(context.Background(), "key")
+	<-started
+
+	cancel()
+	response, err := coalesced(ctx, "key")
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+}
+
+func testCoalesceEmptyKey(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		calls  = 0
+
+		coalesced = Coalesce(stringKey)(func(ctx context.Context, v interface{}) (interface{}, error) {
+			calls++
+			return "response", nil
+		})
+	)
+
+	for repeat := 0; repeat < 3; repeat++ {
+		response, err := coalesced(context.Background(), "")
+		assert.Equal("response", response)
+		assert.NoError(err)
+	}
+
+	assert.Equal(3, calls)
+}
+
+func TestCoalesce(t *testing.T) {
+	t.Run("NilKey", testCoalesceNilKey)
+	t.Run("Concurrent", testCoalesceConcurrent)
+	t.Run("FollowerCanceled", testCoalesceFollowerCanceled)
+	t.Run("EmptyKey", testCoalesceEmptyKey)
+}
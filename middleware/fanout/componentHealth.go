@@ -0,0 +1,220 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHealthWindow                  = 20
+	DefaultHealthThreshold               = 0.5
+	DefaultEvictionPeriod  time.Duration = 30 * time.Second
+)
+
+// HealthPolicy configures the health tracking of components, enabled via the EvictUnhealthy option.
+type HealthPolicy struct {
+	// Window is the number of each component's most recent results used to compute its failure rate.
+	// If not set, DefaultHealthWindow is used.
+	Window int `json:"window"`
+
+	// Threshold is the failure rate above which a component is evicted.  A component is never evicted until it has
+	// a full Window of results.  Valid thresholds are greater than 0.0 and at most 1.0, and a threshold of 1.0 never
+	// evicts a component.  Zero is not a valid threshold:  it means the threshold was not set, and DefaultHealthThreshold
+	// is used.  To evict a component after any failure, use a threshold smaller than 1/Window.
+	Threshold float64 `json:"threshold"`
+
+	// EvictionPeriod is how long an evicted component is excluded from fanouts.  After this period, the component
+	// is sent one request as a probe.  A successful probe restores the component, while a failed probe evicts it again.
+	// If not set, DefaultEvictionPeriod is used.
+	EvictionPeriod time.Duration `json:"evictionPeriod"`
+}
+
+// Validate checks this policy for settings that would otherwise be silently replaced with defaults, such as a negative
+// Threshold.  A nil HealthPolicy is valid.
+func (hp *HealthPolicy) Validate() error {
+	switch {
+	case hp == nil:
+		return nil
+	case hp.Window < 0:
+		return fmt.Errorf("Health window cannot be negative: %d", hp.Window)
+	case hp.Threshold < 0.0 || hp.Threshold > 1.0:
+		return fmt.Errorf("Health threshold must be from 0.0 to 1.0: %v", hp.Threshold)
+	case hp.EvictionPeriod < 0:
+		return fmt.Errorf("Health evictionPeriod cannot be negative: %s", hp.EvictionPeriod)
+	default:
+		return nil
+	}
+}
+
+func (hp *HealthPolicy) window() int {
+	if hp != nil && hp.Window > 0 {
+		return hp.Window
+	}
+
+	return DefaultHealthWindow
+}
+
+func (hp *HealthPolicy) threshold() float64 {
+	if hp != nil && hp.Threshold > 0.0 {
+		return hp.Threshold
+	}
+
+	return DefaultHealthThreshold
+}
+
+func (hp *HealthPolicy) evictionPeriod() time.Duration {
+	if hp != nil && hp.EvictionPeriod > 0 {
+		return hp.EvictionPeriod
+	}
+
+	return DefaultEvictionPeriod
+}
+
+// componentState is the rolling health of a single component
+type componentState struct {
+	// outcomes is a ring buffer of the most recent results, true indicating a failure
+	outcomes []bool
+	next     int
+	count    int
+	failures int
+
+	// evictedUntil is the time at which an evicted component may be probed.  This is the zero
+	// value if the component is not evicted.
+	evictedUntil time.Time
+
+	// probeExpires is set when a probe is outstanding.  If the probe does not report back before this time,
+	// another probe is allowed.
+	probeExpires time.Time
+}
+
+func (cs *componentState) record(failed bool) {
+	if cs.count == len(cs.outcomes) {
+		if cs.outcomes[cs.next] {
+			cs.failures--
+		}
+	} else {
+		cs.count++
+	}
+
+	cs.outcomes[cs.next] = failed
+	if failed {
+		cs.failures++
+	}
+
+	cs.next = (cs.next + 1) % len(cs.outcomes)
+}
+
+func (cs *componentState) reset() {
+	for i := range cs.outcomes {
+		cs.outcomes[i] = false
+	}
+
+	cs.next = 0
+	cs.count = 0
+	cs.failures = 0
+}
+
+// healthTracker tracks the health of components and evicts those which fail too often
+type healthTracker struct {
+	lock           sync.Mutex
+	now            func() time.Time
+	window         int
+	threshold      float64
+	evictionPeriod time.Duration
+	states         map[string]*componentState
+}
+
+func newHealthTracker(hp *HealthPolicy, now func() time.Time) *healthTracker {
+	return &healthTracker{
+		now:            now,
+		window:         hp.window(),
+		threshold:      hp.threshold(),
+		evictionPeriod: hp.evictionPeriod(),
+		states:         make(map[string]*componentState),
+	}
+}
+
+func (ht *healthTracker) state(name string) *componentState {
+	cs, ok := ht.states[name]
+	if !ok {
+		cs = &componentState{outcomes: make([]bool, ht.window)}
+		ht.states[name] = cs
+	}
+
+	return cs
+}
+
+// selectComponents removes evicted components.  An evicted component whose eviction period has elapsed is
+// allowed through as a probe, but only one probe is outstanding for each component at any time.  If every
+// component is evicted, all components are used so that the fanout can still make an attempt.
+func (ht *healthTracker) selectComponents(_ context.Context, _ interface{}, components Components) Components {
+	ht.lock.Lock()
+	defer ht.lock.Unlock()
+
+	var (
+		now     = ht.now()
+		healthy = make(Components, len(components))
+	)
+
+	for name, e := range components {
+		cs, ok := ht.states[name]
+		if !ok || cs.evictedUntil.IsZero() {
+			healthy[name] = e
+		} else if !now.Before(cs.evictedUntil) && !now.Before(cs.probeExpires) {
+			cs.probeExpires = now.Add(ht.evictionPeriod)
+			healthy[name] = e
+		}
+	}
+
+	if len(healthy) == 0 {
+		return components
+	}
+
+	return healthy
+}
+
+// onResult updates the rolling health of a component.  Components that never ran, and errors that do not reflect
+// on the component's health, such as cancellations and locally imposed limits, are ignored.
+func (ht *healthTracker) onResult(_ context.Context, _ interface{}, r Result) {
+	if r.notRun {
+		return
+	}
+
+	switch r.Err {
+	case context.Canceled, ErrRateLimited, ErrPoolFull, ErrPoolStopped, ErrResponseRejected, ErrNotDispatched:
+		return
+	}
+
+	ht.lock.Lock()
+	defer ht.lock.Unlock()
+
+	var (
+		cs     = ht.state(r.Name)
+		failed = r.Err != nil
+	)
+
+	if !cs.evictedUntil.IsZero() {
+		if cs.probeExpires.IsZero() {
+			// a straggler from before the eviction, or a component used only because all were evicted
+			return
+		}
+
+		cs.probeExpires = time.Time{}
+		cs.reset()
+		if failed {
+			cs.evictedUntil = ht.now().Add(ht.evictionPeriod)
+		} else {
+			cs.evictedUntil = time.Time{}
+		}
+
+		return
+	}
+
+	cs.record(failed)
+	if cs.count == ht.window && float64(cs.failures)/float64(cs.count) > ht.threshold {
+		cs.reset()
+		cs.evictedUntil = ht.now().Add(ht.evictionPeriod)
+	}
+}
@@ -0,0 +1,174 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthPolicy(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, hp := range []*HealthPolicy{nil, new(HealthPolicy)} {
+			assert.Equal(DefaultHealthWindow, hp.window())
+			assert.Equal(DefaultHealthThreshold, hp.threshold())
+			assert.Equal(DefaultEvictionPeriod, hp.evictionPeriod())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			hp     = &HealthPolicy{Window: 5, Threshold: 0.25, EvictionPeriod: time.Minute}
+		)
+
+		assert.Equal(5, hp.window())
+		assert.Equal(0.25, hp.threshold())
+		assert.Equal(time.Minute, hp.evictionPeriod())
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, hp := range []*HealthPolicy{nil, new(HealthPolicy), {Window: 5, Threshold: 1.0, EvictionPeriod: time.Minute}} {
+			assert.NoError(hp.Validate())
+		}
+
+		for _, hp := range []*HealthPolicy{{Window: -1}, {Threshold: -0.5}, {Threshold: 1.5}, {EvictionPeriod: -time.Second}} {
+			assert.Error(hp.Validate())
+		}
+	})
+}
+
+func TestComponentState(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cs     = &componentState{outcomes: make([]bool, 3)}
+	)
+
+	cs.record(true)
+	cs.record(false)
+	assert.Equal(2, cs.count)
+	assert.Equal(1, cs.failures)
+
+	cs.record(true)
+	assert.Equal(3, cs.count)
+	assert.Equal(2, cs.failures)
+
+	// the oldest failure rolls off
+	cs.record(false)
+	assert.Equal(3, cs.count)
+	assert.Equal(1, cs.failures)
+
+	cs.reset()
+	assert.Zero(cs.count)
+	assert.Zero(cs.failures)
+	assert.Zero(cs.next)
+}
+
+func testHealthTrackerEviction(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		current       = time.Now()
+		ht            = newHealthTracker(
+			&HealthPolicy{Window: 4, Threshold: 0.5, EvictionPeriod: time.Minute},
+			func() time.Time { return current },
+		)
+
+		components = Components{"healthy": nil, "unhealthy": nil}
+		ctx        = context.Background()
+	)
+
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+
+	// ignored errors never count against a component
+	for _, err := range []error{context.Canceled, ErrRateLimited, ErrPoolFull, ErrPoolStopped, ErrResponseRejected, ErrNotDispatched} {
+		ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: err})
+	}
+
+	// nor do components that never ran, whatever their error
+	for repeat := 0; repeat < 4; repeat++ {
+		ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: context.DeadlineExceeded, notRun: true})
+	}
+
+	// 2 out of 4 is not above the threshold
+	ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: expectedError})
+	ht.onResult(ctx, "request", Result{Name: "unhealthy"})
+	ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: expectedError})
+	ht.onResult(ctx, "request", Result{Name: "unhealthy"})
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+
+	// the oldest failure rolls off, so the rate is still 2 out of 4
+	ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: expectedError})
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+
+	ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: expectedError})
+	assert.Equal(Components{"healthy": nil}, ht.selectComponents(ctx, "request", components))
+
+	// stragglers are ignored while evicted
+	ht.onResult(ctx, "request", Result{Name: "unhealthy"})
+	assert.Equal(Components{"healthy": nil}, ht.selectComponents(ctx, "request", components))
+
+	// if everything is evicted, all components are used
+	assert.Equal(Components{"unhealthy": nil}, ht.selectComponents(ctx, "request", Components{"unhealthy": nil}))
+
+	// after the eviction period, exactly one probe is allowed
+	current = current.Add(time.Minute)
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+	assert.Equal(Components{"healthy": nil}, ht.selectComponents(ctx, "request", components))
+
+	// a failed probe evicts the component again
+	ht.onResult(ctx, "request", Result{Name: "unhealthy", Err: expectedError})
+	assert.Equal(Components{"healthy": nil}, ht.selectComponents(ctx, "request", components))
+
+	// a probe that never reports back expires, allowing another probe
+	current = current.Add(time.Minute)
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+	current = current.Add(time.Minute)
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+
+	// a successful probe restores the component
+	ht.onResult(ctx, "request", Result{Name: "unhealthy"})
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+	assert.Equal(components, ht.selectComponents(ctx, "request", components))
+}
+
+func testHealthTrackerFanout(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		deadCalls     = 0
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"dead": func(context.Context, interface{}) (interface{}, error) {
+					deadCalls++
+					return nil, expectedError
+				},
+			},
+			EvictUnhealthy(&HealthPolicy{Window: 2, Threshold: 0.5, EvictionPeriod: time.Hour}),
+		)
+	)
+
+	require.NotNil(fanout)
+
+	// with only one component, the dead component is still used after it is evicted
+	for repeat := 0; repeat < 5; repeat++ {
+		_, err := fanout(context.Background(), "request")
+		assert.Error(err)
+	}
+
+	assert.Equal(5, deadCalls)
+}
+
+func TestHealthTracker(t *testing.T) {
+	t.Run("Eviction", testHealthTrackerEviction)
+	t.Run("Fanout", testHealthTrackerFanout)
+}
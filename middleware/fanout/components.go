@@ -15,3 +15,15 @@ func (c Components) Apply(m endpoint.Middleware) Components {
 
 	return decorated
 }
+
+// Componenter supplies the current components of a fanout whose components change over time.  Implementations
+// must be safe for concurrent use, and must not modify a Components once it has been returned.
+type Componenter interface {
+	// Components returns the current set of components
+	Components() Components
+}
+
+// Components implements Componenter, allowing a static set of components to be used where a Componenter is expected
+func (c Components) Components() Components {
+	return c
+}
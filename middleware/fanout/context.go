@@ -4,6 +4,10 @@ import "context"
 
 type fanoutRequestKey struct{}
 
+type componentNamesKey struct{}
+
+type componentNameKey struct{}
+
 // NewContext returns a new Context with the given fanoutRequest.  This function is primarily used by the endpoint
 // returned by New to inject the decoded fanout request into the context so that downstream code, such as request functions,
 // can access it.
@@ -35,3 +39,51 @@ func FromContextEntity(ctx context.Context) (interface{}, bool) {
 
 	return r.Entity(), true
 }
+
+// WithComponents returns a new Context which restricts any fanout that uses it to the named components.  This allows
+// upstream code, such as middleware that examines a routing header, to select a subset of a fanout's configured
+// components for a particular request.  Names which do not correspond to a configured component are ignored.
+//
+// If no names are supplied, the returned context restricts fanouts to no components at all.
+func WithComponents(ctx context.Context, names ...string) context.Context {
+	copyOf := make([]string, len(names))
+	copy(copyOf, names)
+	return context.WithValue(ctx, componentNamesKey{}, copyOf)
+}
+
+// ComponentsFromContext returns the component names set via WithComponents.  If no names were set in
+// the context, this function returns false.
+func ComponentsFromContext(ctx context.Context) ([]string, bool) {
+	names, ok := ctx.Value(componentNamesKey{}).([]string)
+	return names, ok
+}
+
+// restrictComponents applies any restriction set via WithComponents to a set of components
+func restrictComponents(ctx context.Context, components Components) Components {
+	names, ok := ComponentsFromContext(ctx)
+	if !ok {
+		return components
+	}
+
+	restricted := make(Components, len(names))
+	for _, name := range names {
+		if e, ok := components[name]; ok {
+			restricted[name] = e
+		}
+	}
+
+	return restricted
+}
+
+// withName returns a new Context carrying the name of the component about to be invoked
+func withName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, componentNameKey{}, name)
+}
+
+// NameFromContext returns the name of the fanout component whose endpoint is being invoked with the given context.
+// This allows endpoint implementations and middleware shared across components, such as logging, to tell which component
+// they are executing as.  If the context was not created by a fanout for a component, this function returns false.
+func NameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(componentNameKey{}).(string)
+	return name, ok
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/Comcast/webpa-common/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -58,3 +59,77 @@ func TestFromContextEntity(t *testing.T) {
 
 	request.AssertExpectations(t)
 }
+
+func TestWithComponents(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		names   = []string{"first", "second"}
+	)
+
+	_, ok := ComponentsFromContext(context.Background())
+	assert.False(ok)
+
+	ctx := WithComponents(context.Background(), names...)
+	require.NotNil(ctx)
+
+	actual, ok := ComponentsFromContext(ctx)
+	require.True(ok)
+	assert.Equal(names, actual)
+
+	// the names must be copied
+	names[0] = "modified"
+	actual, _ = ComponentsFromContext(ctx)
+	assert.Equal([]string{"first", "second"}, actual)
+
+	actual, ok = ComponentsFromContext(WithComponents(context.Background()))
+	assert.True(ok)
+	assert.Empty(actual)
+}
+
+func TestRestrictComponents(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		components = Components{"first": nil, "second": nil, "third": nil}
+	)
+
+	assert.Equal(components, restrictComponents(context.Background(), components))
+	assert.Equal(
+		Components{"first": nil, "third": nil},
+		restrictComponents(WithComponents(context.Background(), "first", "third", "nosuch"), components),
+	)
+
+	assert.Empty(restrictComponents(WithComponents(context.Background(), "nosuch"), components))
+}
+
+func TestNameFromContext(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		names   = make(chan string, 2)
+
+		component = func(ctx context.Context, v interface{}) (interface{}, error) {
+			name, ok := NameFromContext(ctx)
+			assert.True(ok)
+			names <- name
+			return tracing.NopMergeable{}, nil
+		}
+	)
+
+	name, ok := NameFromContext(context.Background())
+	assert.Empty(name)
+	assert.False(ok)
+
+	fanout := New(tracing.NewSpanner(), Components{"first": component, "second": component}, PartialSuccess())
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+	require.NotNil(response)
+
+	close(names)
+	actual := make(map[string]bool)
+	for name := range names {
+		actual[name] = true
+	}
+
+	assert.Equal(map[string]bool{"first": true, "second": true}, actual)
+}
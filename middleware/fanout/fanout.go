@@ -2,6 +2,7 @@ package fanout
 
 import (
 	"context"
+	"errors"
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/tracing"
@@ -9,22 +10,25 @@ import (
 	"github.com/go-kit/kit/log/level"
 )
 
-// response is the internal tuple used to communicate the results of an asynchronously
-// invoked endpoint
-type response struct {
-	name              string
-	span              tracing.Span
-	componentResponse interface{}
-	err               error
+// ErrResponseRejected is the error recorded for a component whose successful response was rejected
+// by the function supplied via the AcceptResponse option.
+var ErrResponseRejected = errors.New("Component response was rejected")
+
+// ErrNoComponents is returned by a fanout when no components were selected for a request, e.g. because
+// WithComponents named no configured components.
+var ErrNoComponents = errors.New("No components were selected for this fanout")
+
+// dispatcher holds the configuration common to all the fanout variants, and handles
+// the concurrent invocation of components.
+type dispatcher struct {
+	spanner    tracing.Spanner
+	components func() Components
+	options    *fanoutOptions
 }
 
-// New produces a go-kit Endpoint which tries all of a set of component endpoints concurrently.  The first component
-// to respond successfully causes this endpoint to return with that response immediately, without waiting
-// on subsequent endpoints.  If the context is canceled for any reason, ctx.Err() is returned.  Finally,
-// if all endpoints fail, an error is returned with a span for each endpoint.
-//
-// If spanner is nil or endpoints is empty, this function panics.
-func New(spanner tracing.Spanner, endpoints Components) endpoint.Endpoint {
+// newDispatcher validates the fanout configuration and produces a dispatcher.  If spanner is nil
+// or endpoints is empty, this function panics.
+func newDispatcher(spanner tracing.Spanner, endpoints Components, o ...Option) *dispatcher {
 	if spanner == nil {
 		panic("No spanner supplied")
 	}
@@ -34,59 +38,240 @@ func New(spanner tracing.Spanner, endpoints Components) endpoint.Endpoint {
 	}
 
 	// use a copy of the endpoints map, for concurrent safety
-	copyOf := make(map[string]endpoint.Endpoint, len(endpoints))
+	copyOf := make(Components, len(endpoints))
 	for k, v := range endpoints {
 		copyOf[k] = v
 	}
 
-	endpoints = copyOf
-	return func(ctx context.Context, v interface{}) (interface{}, error) {
+	return &dispatcher{
+		spanner:    spanner,
+		components: func() Components { return copyOf },
+		options:    newFanoutOptions(o...),
+	}
+}
 
+// newDynamicDispatcher produces a dispatcher whose components are obtained from a Componenter for each
+// fanout request.  If spanner or c is nil, this function panics.
+func newDynamicDispatcher(spanner tracing.Spanner, c Componenter, o ...Option) *dispatcher {
+	if spanner == nil {
+		panic("No spanner supplied")
+	}
+
+	if c == nil {
+		panic("No Componenter supplied")
+	}
+
+	return &dispatcher{
+		spanner:    spanner,
+		components: c.Components,
+		options:    newFanoutOptions(o...),
+	}
+}
+
+// dispatch concurrently invokes each component selected for the given fanout request.  The selected
+// components are returned along with a channel on which each component's Result is sent.  The channel
+// is buffered so that components never block, even if the caller stops receiving.
+//
+// The ctx is used to select components, honoring any restriction from WithComponents, while componentCtx
+// is the context passed to each component.  A component that panics, including in its TransformRequest or AcceptResponse
+// hook, is recorded with a *PanicError.  If a
+// WorkerPool is configured, components are executed by that pool.  If hedging is configured, components are
+// started one at a time by a separate goroutine.  Any shadow components are also dispatched.
+func (d *dispatcher) dispatch(ctx, componentCtx context.Context, v interface{}) (Components, <-chan Result) {
+	var (
+		components = d.options.selectComponents(ctx, v, restrictComponents(ctx, d.components()))
+		results    = make(chan Result, len(components))
+	)
+
+	if d.options.hedge != nil && len(components) > 0 {
+		go d.hedge(ctx, componentCtx, v, components, results)
+	} else {
+		for name, e := range components {
+			d.run(ctx, componentCtx, name, e, v, results)
+		}
+	}
+
+	d.shadow(ctx, v)
+	return components, results
+}
+
+// run starts a single component, either in its own goroutine or via the configured WorkerPool
+func (d *dispatcher) run(ctx, componentCtx context.Context, name string, e endpoint.Endpoint, v interface{}, results chan<- Result) {
+	task := d.component(ctx, componentCtx, name, e, v, results)
+	if d.options.pool == nil {
+		go task()
+	} else {
+		abandon := func(err error) {
+			d.notRun(ctx, v, name, err, results)
+		}
+
+		if err := d.options.pool.submit(ctx, task, abandon); err != nil {
+			if err != ErrPoolFull && err != ErrPoolStopped {
+				// the context ended while waiting for a worker
+				err = ErrNotDispatched
+			}
+
+			abandon(err)
+		}
+	}
+}
+
+// notRun reports the Result for a component that was never invoked
+func (d *dispatcher) notRun(ctx context.Context, v interface{}, name string, err error, results chan<- Result) {
+	r := Result{Name: name, Span: d.spanner.Start(name)(err), Err: err, notRun: true}
+	r.Span = d.options.decorateSpan(r)
+	d.report(ctx, v, r, results)
+}
+
+// component produces the task which invokes a single component and sends its Result to the results channel
+func (d *dispatcher) component(ctx, componentCtx context.Context, name string, e endpoint.Endpoint, v interface{}, results chan<- Result) func() {
+	return func() {
 		var (
-			logger  = logging.Logger(ctx)
-			results = make(chan response, len(endpoints))
+			finisher               = d.spanner.Start(name)
+			componentResponse, err = invokeComponent(componentCtx, name, d.hooked(name, e), v)
 		)
 
-		ctx = NewContext(ctx, v)
-		for name, e := range endpoints {
-			go func(name string, e endpoint.Endpoint) {
-				var (
-					finisher               = spanner.Start(name)
-					componentResponse, err = e(ctx, v)
-				)
-
-				results <- response{
-					name:              name,
-					span:              finisher(err),
-					componentResponse: componentResponse,
-					err:               err,
-				}
-			}(name, e)
+		r := Result{
+			Name:     name,
+			Span:     finisher(err),
+			Response: componentResponse,
+			Err:      err,
+		}
+
+		r.Span = d.options.decorateSpan(r)
+		d.report(ctx, v, r, results)
+	}
+}
+
+// hooked decorates a component endpoint with the TransformRequest and AcceptResponse hooks.  The hooks are user code
+// just like the endpoint, so the decorated endpoint is what invokeComponent recovers from.
+func (d *dispatcher) hooked(name string, e endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, v interface{}) (interface{}, error) {
+		response, err := e(ctx, d.options.transform(name, v))
+		if err == nil && !d.options.accept(name, response) {
+			return nil, ErrResponseRejected
 		}
 
+		return response, err
+	}
+}
+
+// report notifies any listeners of a component's Result, then sends that Result to the results channel
+func (d *dispatcher) report(ctx context.Context, v interface{}, r Result, results chan<- Result) {
+	d.options.notify(ctx, v, r)
+	results <- r
+}
+
+// New produces a go-kit Endpoint which tries all of a set of component endpoints concurrently.  The first component
+// to respond successfully causes this endpoint to return with that response immediately, without waiting
+// on subsequent endpoints.  If the context is canceled for any reason, ctx.Err() is returned.  Finally,
+// if all endpoints fail, an error is returned with a span for each endpoint.  A component endpoint which panics
+// is treated as a failure, with a *PanicError as its error.
+//
+// When the PartialSuccess option is used, the fanout instead waits on every component and returns an *AggregateResponse
+// so long as at least one component succeeded.  When the Quorum option is used, the fanout waits until enough components
+// agree on a response.
+//
+// Zero or more options may be supplied to alter the behavior of the fanout.  See the Option type.
+//
+// If spanner is nil or endpoints is empty, this function panics.
+func New(spanner tracing.Spanner, endpoints Components, o ...Option) endpoint.Endpoint {
+	return newEndpoint(newDispatcher(spanner, endpoints, o...))
+}
+
+// NewDynamic is like New, except that the fanout's components are obtained from the given Componenter for each
+// request.  This allows the components to change over time, e.g. in response to service discovery.  A request made
+// while the Componenter has no components fails with ErrNoComponents.
+//
+// If spanner or c is nil, this function panics.
+func NewDynamic(spanner tracing.Spanner, c Componenter, o ...Option) endpoint.Endpoint {
+	return newEndpoint(newDynamicDispatcher(spanner, c, o...))
+}
+
+// newEndpoint produces the fanout endpoint for a dispatcher
+func newEndpoint(d *dispatcher) endpoint.Endpoint {
+	return func(ctx context.Context, v interface{}) (interface{}, error) {
+		ctx, cancel := d.options.fanoutContext(NewContext(ctx, v))
+		defer cancel()
+
+		componentCtx, componentCancel := d.options.componentContext(ctx)
+		defer componentCancel()
+
 		var (
+			logger              = logging.Logger(ctx)
+			components, results = d.dispatch(ctx, componentCtx, v)
+
 			lastError error
 			spans     []tracing.Span
+			aggregate *AggregateResponse
+			votes     *ballot
 		)
 
-		for r := 0; r < len(endpoints); r++ {
+		if len(components) == 0 {
+			logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), ErrNoComponents, logging.MessageKey(), "no components selected")
+			return nil, tracing.NewSpanError(ErrNoComponents)
+		}
+
+		if d.options.quorum != nil {
+			votes = d.options.quorum.newBallot(len(components))
+		} else if d.options.partialSuccess {
+			aggregate = &AggregateResponse{
+				Results: make([]Result, 0, len(components)),
+			}
+		}
+
+		for r := 0; r < len(components); r++ {
 			select {
 			case <-ctx.Done():
 				logger.Log(level.Key(), level.WarnValue(), logging.ErrorKey(), ctx.Err(), logging.MessageKey(), "timed out")
+				if aggregate != nil && aggregate.hasSuccess() {
+					aggregate.complete(components, ctx.Err())
+					return aggregate.WithSpans(spans...), nil
+				}
+
 				return nil, tracing.NewSpanError(ctx.Err(), spans...)
 			case fr := <-results:
-				spans = append(spans, fr.span)
-				if fr.err != nil {
-					lastError = fr.err
-					logger.Log(level.Key(), level.DebugValue(), "service", fr.name, logging.ErrorKey(), fr.err, logging.MessageKey(), "failed")
+				spans = append(spans, fr.Span)
+				if aggregate != nil {
+					aggregate.Results = append(aggregate.Results, fr)
+				}
+
+				if fr.Err != nil {
+					lastError = fr.Err
+					logger.Log(level.Key(), level.DebugValue(), "service", fr.Name, logging.ErrorKey(), fr.Err, logging.MessageKey(), "failed")
+					if d.options.shouldTerminate(fr.Err) {
+						logger.Log(level.Key(), level.ErrorValue(), "service", fr.Name, logging.ErrorKey(), fr.Err, logging.MessageKey(), "terminating fanout")
+						return nil, tracing.NewSpanError(fr.Err, spans...)
+					}
+
+					if votes != nil {
+						votes.fail()
+					}
+				} else if votes != nil {
+					logger.Log(level.Key(), level.DebugValue(), "service", fr.Name, logging.MessageKey(), "success")
+					if response, ok := votes.vote(d.options.quorum.fingerprint(fr.Response), fr.Response); ok {
+						fanoutResponse, _ := tracing.MergeSpans(response, spans)
+						return fanoutResponse, nil
+					}
 				} else {
-					logger.Log(level.Key(), level.DebugValue(), "service", fr.name, logging.MessageKey(), "success")
-					fanoutResponse, _ := tracing.MergeSpans(fr.componentResponse, spans)
-					return fanoutResponse, nil
+					logger.Log(level.Key(), level.DebugValue(), "service", fr.Name, logging.MessageKey(), "success")
+					if aggregate == nil {
+						fanoutResponse, _ := tracing.MergeSpans(fr.Response, spans)
+						return fanoutResponse, nil
+					}
+				}
+
+				if votes != nil && votes.voted() && votes.impossible() {
+					logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), ErrNoQuorum, logging.MessageKey(), "no quorum")
+					return nil, tracing.NewSpanError(ErrNoQuorum, spans...)
 				}
 			}
 		}
 
+		if aggregate != nil && aggregate.hasSuccess() {
+			return aggregate.WithSpans(spans...), nil
+		}
+
 		logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), lastError, logging.MessageKey(), "all endpoints failed")
 		return nil, tracing.NewSpanError(lastError, spans...)
 	}
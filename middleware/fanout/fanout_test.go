@@ -279,6 +279,203 @@ func testNewAllEndpointsFail(t *testing.T, serviceCount int) {
 	}
 }
 
+func testNewShouldTerminate(t *testing.T, serviceCount int) {
+	var (
+		require             = require.New(t)
+		assert              = assert.New(t)
+		logger              = logging.NewTestLogger(nil, t)
+		expectedCtx, cancel = context.WithCancel(
+			logging.WithLogger(context.Background(), logger),
+		)
+
+		expectedRequest  = "expectedRequest"
+		terminatingError = fmt.Errorf("terminating error")
+
+		endpoints = make(map[string]endpoint.Endpoint, serviceCount)
+		otherGate = make(chan struct{})
+	)
+
+	for i := 0; i < serviceCount; i++ {
+		if i == 0 {
+			endpoints["terminate"] = func(ctx context.Context, request interface{}) (interface{}, error) {
+				assert.Equal(expectedRequest, request)
+				return nil, terminatingError
+			}
+		} else {
+			endpoints[fmt.Sprintf("slow#%d", i)] = func(ctx context.Context, request interface{}) (interface{}, error) {
+				assert.Equal(expectedRequest, request)
+				<-otherGate
+				return new(tracing.NopMergeable), nil
+			}
+		}
+	}
+
+	// release the slow endpoints when this test exits
+	defer close(otherGate)
+	defer cancel()
+
+	fanout := New(
+		tracing.NewSpanner(),
+		endpoints,
+		ShouldTerminate(func(err error) bool { return err == terminatingError }),
+	)
+
+	require.NotNil(fanout)
+
+	response, err := fanout(expectedCtx, expectedRequest)
+	assert.Nil(response)
+	require.Error(err)
+
+	spanError := err.(tracing.SpanError)
+	assert.Equal(terminatingError, spanError.Err())
+	require.Len(spanError.Spans(), 1)
+	assert.Equal("terminate", spanError.Spans()[0].Name())
+	assert.Equal(terminatingError, spanError.Spans()[0].Error())
+}
+
+func testNewTransformRequest(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lock     sync.Mutex
+		requests = make(map[string]interface{})
+
+		fanout = New(
+			tracing.NewSpanner(),
+			map[string]endpoint.Endpoint{
+				"first": func(ctx context.Context, request interface{}) (interface{}, error) {
+					assert.Equal("original", FromContext(ctx))
+					lock.Lock()
+					requests["first"] = request
+					lock.Unlock()
+					return nil, fmt.Errorf("expected failure")
+				},
+				"second": func(ctx context.Context, request interface{}) (interface{}, error) {
+					assert.Equal("original", FromContext(ctx))
+					lock.Lock()
+					requests["second"] = request
+					lock.Unlock()
+					return nil, fmt.Errorf("expected failure")
+				},
+			},
+			TransformRequest(func(name string, request interface{}) interface{} {
+				return fmt.Sprintf("%s-%s", request, name)
+			}),
+		)
+	)
+
+	require.NotNil(fanout)
+	_, err := fanout(context.Background(), "original")
+	assert.Error(err)
+	assert.Equal(
+		map[string]interface{}{"first": "original-first", "second": "original-second"},
+		requests,
+	)
+}
+
+func testNewAcceptResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		rejectedDone = make(chan struct{})
+		fanout       = New(
+			tracing.NewSpanner(),
+			map[string]endpoint.Endpoint{
+				"rejected": func(context.Context, interface{}) (interface{}, error) {
+					defer close(rejectedDone)
+					return "empty", nil
+				},
+				"accepted": func(context.Context, interface{}) (interface{}, error) {
+					<-rejectedDone
+					return tracing.NopMergeable{}, nil
+				},
+			},
+			AcceptResponse(func(name string, response interface{}) bool {
+				return response != "empty"
+			}),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.NoError(err)
+	require.NotNil(response)
+
+	// the rejected span may or may not be merged, depending on goroutine timing
+	spans := response.(tracing.Spanned).Spans()
+	require.True(0 < len(spans) && len(spans) <= 2)
+	for _, s := range spans {
+		if s.Name() == "rejected" {
+			assert.Equal(ErrResponseRejected, s.Error())
+		} else {
+			assert.Equal("accepted", s.Name())
+			assert.NoError(s.Error())
+		}
+	}
+}
+
+func testNewDecorateSpans(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			map[string]endpoint.Endpoint{
+				"component": func(context.Context, interface{}) (interface{}, error) {
+					return tracing.NopMergeable{}, nil
+				},
+			},
+			DecorateSpans(func(r Result) tracing.Span {
+				return tracing.Decorate(r.Span, "decorated-"+r.Name, nil)
+			}),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 1)
+	assert.Equal("decorated-component", spans[0].Name())
+}
+
+func testNewWithComponents(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			map[string]endpoint.Endpoint{
+				"selected": func(context.Context, interface{}) (interface{}, error) {
+					return tracing.NopMergeable{}, nil
+				},
+				"ignored": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("This component should not have been called")
+					return nil, nil
+				},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(WithComponents(context.Background(), "selected"), "request")
+	require.NoError(err)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 1)
+	assert.Equal("selected", spans[0].Name())
+
+	response, err = fanout(WithComponents(context.Background(), "nosuch"), "request")
+	assert.Nil(response)
+	require.Error(err)
+	assert.Equal(ErrNoComponents, err.(tracing.SpanError).Err())
+}
+
 func TestNew(t *testing.T) {
 	t.Run("NoConfiguredEndpoints", testNewNoConfiguredEndpoints)
 	t.Run("NilSpanner", testNewNilSpanner)
@@ -314,4 +511,78 @@ func TestNew(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("ShouldTerminate", func(t *testing.T) {
+		for c := 1; c <= 5; c++ {
+			t.Run(fmt.Sprintf("EndpointCount=%d", c), func(t *testing.T) {
+				testNewShouldTerminate(t, c)
+			})
+		}
+	})
+
+	t.Run("TransformRequest", testNewTransformRequest)
+	t.Run("AcceptResponse", testNewAcceptResponse)
+	t.Run("DecorateSpans", testNewDecorateSpans)
+	t.Run("WithComponents", testNewWithComponents)
+}
+
+func TestNewDynamic(t *testing.T) {
+	t.Run("Panics", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Panics(func() {
+			NewDynamic(nil, new(mockComponenter))
+		})
+
+		assert.Panics(func() {
+			NewDynamic(tracing.NewSpanner(), nil)
+		})
+	})
+
+	t.Run("Changing", func(t *testing.T) {
+		var (
+			assert      = assert.New(t)
+			require     = require.New(t)
+			componenter = new(mockComponenter)
+
+			respond = func(name string) endpoint.Endpoint {
+				return func(ctx context.Context, v interface{}) (interface{}, error) {
+					return name, nil
+				}
+			}
+
+			fanout = NewDynamic(tracing.NewSpanner(), componenter)
+		)
+
+		require.NotNil(fanout)
+		componenter.On("Components").Return(Components{"first": respond("first")}).Once()
+		componenter.On("Components").Return(Components{"second": respond("second")}).Once()
+		componenter.On("Components").Return(nil).Once()
+
+		response, err := fanout(context.Background(), "request")
+		assert.Equal("first", response)
+		assert.NoError(err)
+
+		response, err = fanout(context.Background(), "request")
+		assert.Equal("second", response)
+		assert.NoError(err)
+
+		response, err = fanout(context.Background(), "request")
+		assert.Nil(response)
+		require.Error(err)
+		assert.Equal(ErrNoComponents, err.(tracing.SpanError).Err())
+
+		componenter.AssertExpectations(t)
+	})
+
+	t.Run("Static", func(t *testing.T) {
+		var (
+			assert     = assert.New(t)
+			components = Components{"static": func(context.Context, interface{}) (interface{}, error) { return "static", nil }}
+			fanout     = NewDynamic(tracing.NewSpanner(), components)
+		)
+
+		response, err := fanout(context.Background(), "request")
+		assert.Equal("static", response)
+		assert.NoError(err)
+	})
 }
@@ -0,0 +1,135 @@
+package fanouthttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// accessRecordKey is the context key for the *accessRecord of a fanout request
+type accessRecordKey struct{}
+
+// accessRecord holds the spans produced by a fanout endpoint for a single original request
+type accessRecord struct {
+	spans []tracing.Span
+}
+
+// recordAccess is an endpoint middleware that captures the fanout's spans for the AccessLog, if the request is being logged
+func recordAccess(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		if record, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+			if err != nil {
+				record.spans, _ = tracing.Spans(err)
+			} else {
+				record.spans, _ = tracing.Spans(response)
+			}
+		}
+
+		return response, err
+	}
+}
+
+// statusWriter is an http.ResponseWriter decorator that captures the response status code
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.statusCode == 0 {
+		sw.statusCode = statusCode
+	}
+
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.statusCode == 0 {
+		sw.statusCode = http.StatusOK
+	}
+
+	return sw.ResponseWriter.Write(p)
+}
+
+// Flush delegates to the decorated ResponseWriter, so that streamed responses still work.  If the delegate
+// does not implement http.Flusher, this method does nothing.
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// summarizeSpans produces the names of the components that succeeded along with a summary of each component's outcome
+func summarizeSpans(spans []tracing.Span) (string, string) {
+	var (
+		chosen   []string
+		outcomes = make([]string, 0, len(spans))
+	)
+
+	for _, s := range spans {
+		switch err := s.Error().(type) {
+		case nil:
+			chosen = append(chosen, s.Name())
+			outcomes = append(outcomes, s.Name()+"=ok")
+
+		case gokithttp.StatusCoder:
+			outcomes = append(outcomes, fmt.Sprintf("%s=%d", s.Name(), err.StatusCode()))
+
+		default:
+			outcomes = append(outcomes, s.Name()+"="+err.Error())
+		}
+	}
+
+	return strings.Join(chosen, ","), strings.Join(outcomes, ",")
+}
+
+// AccessLog returns an Alice-style constructor that logs one line, at the info level, for each request handled by
+// a fanout.  Each line includes the request's method and URL, the response status code, the overall latency, the
+// component or components whose responses were used, and a summary of each component's outcome.
+//
+// The component information is derived from the fanout's spans, so the decorated handler must have been created with
+// NewHandler or NewLimitedHandler.  For any other handler, only the request information is logged.  If logger is nil,
+// logging.DefaultLogger is used.
+func AccessLog(logger log.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	infoLog := logging.Info(logger)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var (
+				start  = time.Now()
+				record = new(accessRecord)
+				writer = &statusWriter{ResponseWriter: response}
+			)
+
+			next.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), accessRecordKey{}, record)))
+
+			statusCode := writer.statusCode
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+
+			chosen, components := summarizeSpans(record.spans)
+			infoLog.Log(
+				logging.MessageKey(), "fanout request",
+				"method", request.Method,
+				"url", request.URL.String(),
+				"code", statusCode,
+				"latency", time.Since(start),
+				"chosen", chosen,
+				"components", components,
+			)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package fanouthttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+	gokithttp "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCapturingLogger returns a go-kit logger which records the key/value pairs of each log line
+func newCapturingLogger(lines *[]map[interface{}]interface{}) log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		line := make(map[interface{}]interface{}, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			line[keyvals[i]] = keyvals[i+1]
+		}
+
+		*lines = append(*lines, line)
+		return nil
+	})
+}
+
+func testAccessLogSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lines   []map[interface{}]interface{}
+		handler = AccessLog(newCapturingLogger(&lines))(
+			NewHandler(
+				fanout.New(
+					tracing.NewSpanner(),
+					fanout.Components{
+						"success": func(context.Context, interface{}) (interface{}, error) {
+							return &PassThrough{StatusCode: 200, Entity: []byte("success")}, nil
+						},
+					},
+				),
+				DecodePassThroughRequest,
+				EncodePassThroughResponse,
+			),
+		)
+
+		request  = httptest.NewRequest("POST", "/api/v2/device", strings.NewReader("entity"))
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("success", response.Body.String())
+
+	require.Len(lines, 1)
+	assert.Equal("fanout request", lines[0][logging.MessageKey()])
+	assert.Equal("POST", lines[0]["method"])
+	assert.Equal("/api/v2/device", lines[0]["url"])
+	assert.Equal(http.StatusOK, lines[0]["code"])
+	assert.NotNil(lines[0]["latency"])
+	assert.Equal("success", lines[0]["chosen"])
+	assert.Equal("success=ok", lines[0]["components"])
+}
+
+func testAccessLogFailure(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lines   []map[interface{}]interface{}
+		handler = AccessLog(newCapturingLogger(&lines))(
+			NewHandler(
+				fanout.New(
+					tracing.NewSpanner(),
+					fanout.Components{
+						"notFound": func(context.Context, interface{}) (interface{}, error) {
+							return nil, &xhttp.Error{Code: http.StatusNotFound}
+						},
+					},
+				),
+				DecodePassThroughRequest,
+				EncodePassThroughResponse,
+				gokithttp.ServerErrorEncoder(ServerErrorEncoder("")),
+			),
+		)
+
+		request  = httptest.NewRequest("GET", "/api/v2/device", nil)
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusNotFound, response.Code)
+
+	require.Len(lines, 1)
+	assert.Equal(http.StatusNotFound, lines[0]["code"])
+	assert.Equal("", lines[0]["chosen"])
+	assert.Equal("notFound=404", lines[0]["components"])
+}
+
+func testAccessLogOtherHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lines   []map[interface{}]interface{}
+		handler = AccessLog(newCapturingLogger(&lines))(
+			http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+				response.Write([]byte("not a fanout"))
+			}),
+		)
+
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+
+	require.Len(lines, 1)
+	assert.Equal(http.StatusOK, lines[0]["code"])
+	assert.Equal("", lines[0]["chosen"])
+	assert.Equal("", lines[0]["components"])
+}
+
+func testAccessLogDefaultLogger(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(AccessLog(nil))
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Run("Success", testAccessLogSuccess)
+	t.Run("Failure", testAccessLogFailure)
+	t.Run("OtherHandler", testAccessLogOtherHandler)
+	t.Run("DefaultLogger", testAccessLogDefaultLogger)
+}
+
+func TestSummarizeSpans(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		spanner = tracing.NewSpanner()
+	)
+
+	chosen, components := summarizeSpans(nil)
+	assert.Empty(chosen)
+	assert.Empty(components)
+
+	chosen, components = summarizeSpans([]tracing.Span{
+		spanner.Start("one")(nil),
+		spanner.Start("two")(&xhttp.Error{Code: http.StatusServiceUnavailable}),
+		spanner.Start("three")(errors.New("connection refused")),
+		spanner.Start("four")(nil),
+	})
+
+	assert.Equal("one,four", chosen)
+	assert.Equal("one=ok,two=503,three=connection refused,four=ok", components)
+}
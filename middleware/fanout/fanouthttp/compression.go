@@ -0,0 +1,167 @@
+package fanouthttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+const (
+	// EncodingGzip is the gzip content coding
+	EncodingGzip = "gzip"
+
+	// EncodingDeflate is the deflate content coding, which is the zlib format of RFC 1950
+	EncodingDeflate = "deflate"
+)
+
+// AcceptCompression is a component client RequestFunc which advertises that compressed component responses are
+// accepted.  Setting Accept-Encoding disables the transparent decompression normally done by net/http, so this
+// should always be paired with DecodeCompressedResponse.
+func AcceptCompression(ctx context.Context, r *http.Request) context.Context {
+	r.Header.Set("Accept-Encoding", EncodingGzip+", "+EncodingDeflate)
+	return ctx
+}
+
+// decompressor adapts a decompressing reader so that closing it also closes the underlying body
+type decompressor struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressor) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// DecodeCompressedResponse decorates a component DecodeResponseFunc so that gzip or deflate component responses are
+// decompressed before being decoded.  The Content-Encoding and Content-Length headers are removed from decompressed
+// responses, since they no longer describe the body.  A response with any other content coding is treated as an error.
+//
+// If dec is nil, this function panics.
+func DecodeCompressedResponse(dec gokithttp.DecodeResponseFunc) gokithttp.DecodeResponseFunc {
+	if dec == nil {
+		panic("The response decoder cannot be nil")
+	}
+
+	return func(ctx context.Context, component *http.Response) (interface{}, error) {
+		var (
+			body io.ReadCloser
+			err  error
+		)
+
+		switch encoding := strings.ToLower(strings.TrimSpace(component.Header.Get("Content-Encoding"))); encoding {
+		case "", "identity":
+			return dec(ctx, component)
+
+		case EncodingGzip:
+			var gr *gzip.Reader
+			if gr, err = gzip.NewReader(component.Body); err == nil {
+				body = &decompressor{Reader: gr, closers: []io.Closer{gr, component.Body}}
+			}
+
+		case EncodingDeflate:
+			var zr io.ReadCloser
+			if zr, err = zlib.NewReader(component.Body); err == nil {
+				body = &decompressor{Reader: zr, closers: []io.Closer{zr, component.Body}}
+			}
+
+		default:
+			err = fmt.Errorf("Unsupported content encoding: %s", encoding)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		component.Body = body
+		component.Header.Del("Content-Encoding")
+		component.Header.Del("Content-Length")
+		component.ContentLength = -1
+		component.Uncompressed = true
+		return dec(ctx, component)
+	}
+}
+
+// compress writes the given data using the given content coding
+func compress(encoding string, data []byte) ([]byte, error) {
+	var (
+		output = new(bytes.Buffer)
+		writer io.WriteCloser
+	)
+
+	switch encoding {
+	case EncodingGzip:
+		writer = gzip.NewWriter(output)
+	case EncodingDeflate:
+		writer = zlib.NewWriter(output)
+	default:
+		return nil, fmt.Errorf("Unsupported content encoding: %s", encoding)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// EncodeCompressedRequest decorates a component EncodeRequestFunc so that the body it produces is compressed with
+// the given content coding, which must be either EncodingGzip or EncodingDeflate.  The Content-Encoding, Content-Length,
+// and GetBody of the component request are updated accordingly.  Requests without a body are not altered.
+//
+// If enc is nil or the encoding is not supported, this function panics.
+func EncodeCompressedRequest(enc gokithttp.EncodeRequestFunc, encoding string) gokithttp.EncodeRequestFunc {
+	if enc == nil {
+		panic("The request encoder cannot be nil")
+	}
+
+	if encoding != EncodingGzip && encoding != EncodingDeflate {
+		panic(fmt.Errorf("Unsupported content encoding: %s", encoding))
+	}
+
+	return func(ctx context.Context, component *http.Request, v interface{}) error {
+		if err := enc(ctx, component, v); err != nil {
+			return err
+		}
+
+		if component.Body == nil || component.Body == http.NoBody {
+			return nil
+		}
+
+		data, err := ioutil.ReadAll(component.Body)
+		component.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		compressed, err := compress(encoding, data)
+		if err != nil {
+			return err
+		}
+
+		pt := &PassThrough{Entity: compressed}
+		component.Body = pt.ReadCloser()
+		component.GetBody = pt.GetBody
+		component.ContentLength = int64(len(compressed))
+		component.Header.Set("Content-Encoding", encoding)
+		return nil
+	}
+}
@@ -0,0 +1,188 @@
+package fanouthttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptCompression(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		component = httptest.NewRequest("GET", "/", nil)
+		ctx       = context.Background()
+	)
+
+	assert.Equal(ctx, AcceptCompression(ctx, component))
+	assert.Equal("gzip, deflate", component.Header.Get("Accept-Encoding"))
+}
+
+func testDecodeCompressedResponse(t *testing.T, encoding string, body []byte) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		component = &http.Response{
+			StatusCode:    200,
+			Header:        http.Header{"Content-Encoding": []string{encoding}, "Content-Length": []string{"123"}},
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: 123,
+		}
+
+		decoder = DecodeCompressedResponse(func(_ context.Context, actual *http.Response) (interface{}, error) {
+			assert.Empty(actual.Header.Get("Content-Encoding"))
+			assert.Empty(actual.Header.Get("Content-Length"))
+			assert.Equal(int64(-1), actual.ContentLength)
+			data, err := ioutil.ReadAll(actual.Body)
+			require.NoError(err)
+			assert.NoError(actual.Body.Close())
+			return string(data), nil
+		})
+	)
+
+	response, err := decoder(context.Background(), component)
+	assert.Equal("decompressed body", response)
+	assert.NoError(err)
+}
+
+func TestDecodeCompressedResponse(t *testing.T) {
+	t.Run("NilDecoder", func(t *testing.T) {
+		assert.Panics(t, func() {
+			DecodeCompressedResponse(nil)
+		})
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			component = &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(new(bytes.Buffer))}
+			decoder   = DecodeCompressedResponse(func(_ context.Context, actual *http.Response) (interface{}, error) {
+				assert.Equal(component, actual)
+				return "identity", nil
+			})
+		)
+
+		response, err := decoder(context.Background(), component)
+		assert.Equal("identity", response)
+		assert.NoError(err)
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		var (
+			output = new(bytes.Buffer)
+			writer = gzip.NewWriter(output)
+		)
+
+		writer.Write([]byte("decompressed body"))
+		writer.Close()
+		testDecodeCompressedResponse(t, "gzip", output.Bytes())
+	})
+
+	t.Run("Deflate", func(t *testing.T) {
+		var (
+			output = new(bytes.Buffer)
+			writer = zlib.NewWriter(output)
+		)
+
+		writer.Write([]byte("decompressed body"))
+		writer.Close()
+		testDecodeCompressedResponse(t, "Deflate", output.Bytes())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		assert := assert.New(t)
+		decoder := DecodeCompressedResponse(func(context.Context, *http.Response) (interface{}, error) {
+			assert.Fail("The decoder should not have been called")
+			return nil, nil
+		})
+
+		for _, encoding := range []string{"gzip", "deflate", "br"} {
+			response, err := decoder(context.Background(), &http.Response{
+				Header: http.Header{"Content-Encoding": []string{encoding}},
+				Body:   ioutil.NopCloser(bytes.NewBufferString("this is not compressed")),
+			})
+
+			assert.Nil(response)
+			assert.Error(err)
+		}
+	})
+}
+
+func TestEncodeCompressedRequest(t *testing.T) {
+	nopEncoder := func(context.Context, *http.Request, interface{}) error { return nil }
+
+	t.Run("Panics", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Panics(func() {
+			EncodeCompressedRequest(nil, EncodingGzip)
+		})
+
+		assert.Panics(func() {
+			EncodeCompressedRequest(nopEncoder, "br")
+		})
+	})
+
+	t.Run("NoBody", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			component = httptest.NewRequest("GET", "/", nil)
+		)
+
+		component.Body = nil
+		assert.NoError(EncodeCompressedRequest(nopEncoder, EncodingGzip)(context.Background(), component, "entity"))
+		assert.Empty(component.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("EncoderError", func(t *testing.T) {
+		expectedError := errors.New("expected")
+		encoder := EncodeCompressedRequest(
+			func(context.Context, *http.Request, interface{}) error { return expectedError },
+			EncodingGzip,
+		)
+
+		assert.Equal(t, expectedError, encoder(context.Background(), httptest.NewRequest("GET", "/", nil), "entity"))
+	})
+
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate} {
+		t.Run(encoding, func(t *testing.T) {
+			var (
+				assert    = assert.New(t)
+				require   = require.New(t)
+				component = httptest.NewRequest("POST", "/", nil)
+				encoder   = EncodeCompressedRequest(
+					func(_ context.Context, r *http.Request, v interface{}) error {
+						r.Body = ioutil.NopCloser(bytes.NewBufferString(v.(string)))
+						return nil
+					},
+					encoding,
+				)
+			)
+
+			require.NoError(encoder(context.Background(), component, "uncompressed body"))
+			assert.Equal(encoding, component.Header.Get("Content-Encoding"))
+			require.NotNil(component.GetBody)
+
+			decoded, err := DecodeCompressedResponse(func(_ context.Context, r *http.Response) (interface{}, error) {
+				data, err := ioutil.ReadAll(r.Body)
+				return string(data), err
+			})(context.Background(), &http.Response{Header: component.Header, Body: component.Body})
+
+			assert.Equal("uncompressed body", decoded)
+			assert.NoError(err)
+
+			body, err := component.GetBody()
+			require.NoError(err)
+			data, err := ioutil.ReadAll(body)
+			require.NoError(err)
+			assert.Equal(component.ContentLength, int64(len(data)))
+		})
+	}
+}
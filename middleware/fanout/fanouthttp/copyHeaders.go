@@ -12,6 +12,8 @@ import (
 // CopyHeaders is a component client RequestFunc for transferring certain headers from the original
 // request into each component request of a fanout.
 //
+// Hop-by-hop headers, as defined by RFC 7230, are never copied even if named.
+//
 // THe returned RequestFunc requires that the fanoutRequest is available in the context.
 func CopyHeaders(headers ...string) gokithttp.RequestFunc {
 	normalizedHeaders := make([]string, len(headers))
@@ -22,8 +24,9 @@ func CopyHeaders(headers ...string) gokithttp.RequestFunc {
 	headers = normalizedHeaders
 	return func(ctx context.Context, r *http.Request) context.Context {
 		if fr, ok := fanout.FromContext(ctx).(*fanoutRequest); ok {
+			isHopByHop := hopByHop(fr.original.Header)
 			for _, name := range headers {
-				if values, ok := fr.original.Header[name]; ok {
+				if values, ok := fr.original.Header[name]; ok && !isHopByHop(name) {
 					r.Header[name] = values
 				}
 			}
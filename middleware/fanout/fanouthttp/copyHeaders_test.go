@@ -20,7 +20,7 @@ func TestCopyHeaders(t *testing.T) {
 		}
 
 		component   = httptest.NewRequest("GET", "/", nil)
-		copyHeaders = CopyHeaders("X-Scalar", "x-multi")
+		copyHeaders = CopyHeaders("X-Scalar", "x-multi", "Upgrade", "X-Hop")
 	)
 
 	require.NotNil(copyHeaders)
@@ -29,6 +29,9 @@ func TestCopyHeaders(t *testing.T) {
 	original.Header.Set("X-Scalar", "1234")
 	original.Header.Add("X-Multi", "value1")
 	original.Header.Add("X-Multi", "value2")
+	original.Header.Set("Upgrade", "websocket")
+	original.Header.Set("Connection", "X-Hop")
+	original.Header.Set("X-Hop", "hop")
 
 	ctx := fanout.NewContext(context.Background(), fanoutRequest)
 	assert.Equal(ctx, copyHeaders(ctx, component))
@@ -36,4 +39,6 @@ func TestCopyHeaders(t *testing.T) {
 	assert.Empty(component.Header.Get("X-NotCopied"))
 	assert.Equal("1234", component.Header.Get("X-Scalar"))
 	assert.Equal([]string{"value1", "value2"}, component.Header["X-Multi"])
+	assert.Empty(component.Header.Get("Upgrade"))
+	assert.Empty(component.Header.Get("X-Hop"))
 }
@@ -0,0 +1,138 @@
+package fanouthttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are the methods allowed for cross-origin requests when a CORSPolicy doesn't specify any
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORSPolicy describes how a fanout handler responds to cross-origin requests from browsers
+type CORSPolicy struct {
+	// AllowedOrigins are the origins permitted to make cross-origin requests.  An entry of "*", or an empty slice,
+	// allows any origin.  Origins are compared case-insensitively.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+
+	// AllowedMethods are the methods permitted for cross-origin requests.  If not set, DefaultCORSMethods is used.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// AllowedHeaders are the request headers permitted for cross-origin requests.  If not set, any headers
+	// the browser asks for in a preflight request are allowed.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+
+	// ExposedHeaders are the response headers which browsers are permitted to read
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+
+	// AllowCredentials indicates whether browsers may send cookies and Authorization with cross-origin requests
+	AllowCredentials bool `json:"allowCredentials"`
+
+	// MaxAge is how long browsers may cache the results of a preflight request.  If not set, no caching
+	// directive is sent.
+	MaxAge time.Duration `json:"maxAge"`
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin for the given request origin.  If the origin
+// is not allowed, this method returns the empty string.
+func (cp *CORSPolicy) allowOrigin(origin string) string {
+	anyOrigin := len(cp.AllowedOrigins) == 0
+	for _, allowed := range cp.AllowedOrigins {
+		if allowed == "*" {
+			anyOrigin = true
+		} else if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+
+	switch {
+	case !anyOrigin:
+		return ""
+	case cp.AllowCredentials:
+		// browsers reject a wildcard when credentials are allowed
+		return origin
+	default:
+		return "*"
+	}
+}
+
+func (cp *CORSPolicy) allowedMethods() []string {
+	if len(cp.AllowedMethods) > 0 {
+		return cp.AllowedMethods
+	}
+
+	return DefaultCORSMethods
+}
+
+// preflight writes the response to a preflight request
+func (cp *CORSPolicy) preflight(response http.ResponseWriter, request *http.Request) {
+	header := response.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	allowOrigin := cp.allowOrigin(request.Header.Get("Origin"))
+	if len(allowOrigin) == 0 {
+		response.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(cp.allowedMethods(), ", "))
+
+	if len(cp.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cp.AllowedHeaders, ", "))
+	} else if requested := request.Header.Get("Access-Control-Request-Headers"); len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if cp.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if cp.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cp.MaxAge/time.Second)))
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// CORS returns an Alice-style constructor that applies the given policy to cross-origin requests.  Preflight
+// requests, i.e. OPTIONS requests with an Origin and an Access-Control-Request-Method, are answered directly and
+// never reach the decorated handler.  A preflight request from an origin that is not allowed receives a 403.
+//
+// Other requests with an allowed Origin are passed to the decorated handler with the appropriate CORS headers
+// already set on the response.  Requests without an Origin, or from an origin that is not allowed, are passed
+// to the decorated handler as is.
+func CORS(policy CORSPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			origin := request.Header.Get("Origin")
+			if len(origin) == 0 {
+				next.ServeHTTP(response, request)
+				return
+			}
+
+			if request.Method == "OPTIONS" && len(request.Header.Get("Access-Control-Request-Method")) > 0 {
+				policy.preflight(response, request)
+				return
+			}
+
+			header := response.Header()
+			header.Add("Vary", "Origin")
+			if allowOrigin := policy.allowOrigin(origin); len(allowOrigin) > 0 {
+				header.Set("Access-Control-Allow-Origin", allowOrigin)
+				if len(policy.ExposedHeaders) > 0 {
+					header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+				}
+
+				if policy.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			next.ServeHTTP(response, request)
+		})
+	}
+}
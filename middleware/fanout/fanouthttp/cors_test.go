@@ -0,0 +1,170 @@
+package fanouthttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCORSHandler(t *testing.T, policy CORSPolicy, called *bool) http.Handler {
+	handler := CORS(policy)(
+		http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			*called = true
+			response.WriteHeader(http.StatusAccepted)
+		}),
+	)
+
+	require.NotNil(t, handler)
+	return handler
+}
+
+func testCORSNoOrigin(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		called   = false
+		handler  = newCORSHandler(t, CORSPolicy{}, &called)
+		request  = httptest.NewRequest("OPTIONS", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	handler.ServeHTTP(response, request)
+	assert.True(called)
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Empty(response.HeaderMap.Get("Access-Control-Allow-Origin"))
+}
+
+func testCORSPreflightDefaults(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		called   = false
+		handler  = newCORSHandler(t, CORSPolicy{}, &called)
+		request  = httptest.NewRequest("OPTIONS", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://app.comcast.net")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	request.Header.Set("Access-Control-Request-Headers", "X-Webpa-Device-Name")
+	handler.ServeHTTP(response, request)
+
+	assert.False(called)
+	assert.Equal(http.StatusNoContent, response.Code)
+	assert.Equal("*", response.HeaderMap.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, POST, PUT, PATCH, DELETE", response.HeaderMap.Get("Access-Control-Allow-Methods"))
+	assert.Equal("X-Webpa-Device-Name", response.HeaderMap.Get("Access-Control-Allow-Headers"))
+	assert.Empty(response.HeaderMap.Get("Access-Control-Allow-Credentials"))
+	assert.Empty(response.HeaderMap.Get("Access-Control-Max-Age"))
+	assert.Contains(response.HeaderMap["Vary"], "Origin")
+}
+
+func testCORSPreflightConfigured(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		called = false
+		policy = CORSPolicy{
+			AllowedOrigins:   []string{"https://app.comcast.net"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		}
+
+		handler  = newCORSHandler(t, policy, &called)
+		request  = httptest.NewRequest("OPTIONS", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://APP.comcast.net")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	request.Header.Set("Access-Control-Request-Headers", "X-Something-Else")
+	handler.ServeHTTP(response, request)
+
+	assert.False(called)
+	assert.Equal(http.StatusNoContent, response.Code)
+	assert.Equal("https://APP.comcast.net", response.HeaderMap.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, POST", response.HeaderMap.Get("Access-Control-Allow-Methods"))
+	assert.Equal("Content-Type, Authorization", response.HeaderMap.Get("Access-Control-Allow-Headers"))
+	assert.Equal("true", response.HeaderMap.Get("Access-Control-Allow-Credentials"))
+	assert.Equal("600", response.HeaderMap.Get("Access-Control-Max-Age"))
+}
+
+func testCORSPreflightForbidden(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		called   = false
+		handler  = newCORSHandler(t, CORSPolicy{AllowedOrigins: []string{"https://app.comcast.net"}}, &called)
+		request  = httptest.NewRequest("OPTIONS", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://evil.example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	handler.ServeHTTP(response, request)
+
+	assert.False(called)
+	assert.Equal(http.StatusForbidden, response.Code)
+	assert.Empty(response.HeaderMap.Get("Access-Control-Allow-Origin"))
+}
+
+func testCORSRequest(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		called = false
+		policy = CORSPolicy{
+			AllowedOrigins:   []string{"*"},
+			ExposedHeaders:   []string{"X-Webpa-Transaction-Id"},
+			AllowCredentials: true,
+		}
+
+		handler  = newCORSHandler(t, policy, &called)
+		request  = httptest.NewRequest("POST", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://app.comcast.net")
+	handler.ServeHTTP(response, request)
+
+	assert.True(called)
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Equal("https://app.comcast.net", response.HeaderMap.Get("Access-Control-Allow-Origin"))
+	assert.Equal("X-Webpa-Transaction-Id", response.HeaderMap.Get("Access-Control-Expose-Headers"))
+	assert.Equal("true", response.HeaderMap.Get("Access-Control-Allow-Credentials"))
+	assert.Equal([]string{"Origin"}, response.HeaderMap["Vary"])
+}
+
+func testCORSRequestDisallowedOrigin(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		called   = false
+		handler  = newCORSHandler(t, CORSPolicy{AllowedOrigins: []string{"https://app.comcast.net"}}, &called)
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://evil.example.com")
+	handler.ServeHTTP(response, request)
+
+	assert.True(called)
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Empty(response.HeaderMap.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS(t *testing.T) {
+	t.Run("NoOrigin", testCORSNoOrigin)
+
+	t.Run("Preflight", func(t *testing.T) {
+		t.Run("Defaults", testCORSPreflightDefaults)
+		t.Run("Configured", testCORSPreflightConfigured)
+		t.Run("Forbidden", testCORSPreflightForbidden)
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		t.Run("AllowedOrigin", testCORSRequest)
+		t.Run("DisallowedOrigin", testCORSRequestDisallowedOrigin)
+	})
+}
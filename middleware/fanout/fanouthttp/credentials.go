@@ -0,0 +1,42 @@
+package fanouthttp
+
+import (
+	"context"
+	"net/http"
+
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// Credentials describes static Authorization material presented to a component, independent of any credentials
+// presented by the fanout's caller.  At most one of the fields should be set.  If both are set, Bearer takes precedence.
+type Credentials struct {
+	// Basic is the base64-encoded Basic Auth token, i.e. the encoding of "user:password"
+	Basic string `json:"basic,omitempty"`
+
+	// Bearer is a bearer token, such as a SAT
+	Bearer string `json:"bearer,omitempty"`
+}
+
+// Authorization returns the Authorization header value for these credentials.  If this Credentials is nil or empty,
+// this method returns the empty string.
+func (c *Credentials) Authorization() string {
+	switch {
+	case c == nil:
+		return ""
+	case len(c.Bearer) > 0:
+		return "Bearer " + c.Bearer
+	case len(c.Basic) > 0:
+		return "Basic " + c.Basic
+	default:
+		return ""
+	}
+}
+
+// SetAuthorization is a component client RequestFunc which sets the Authorization header of each component
+// request to the given value, replacing any Authorization forwarded from the original request.
+func SetAuthorization(value string) gokithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set("Authorization", value)
+		return ctx
+	}
+}
@@ -2,11 +2,14 @@ package fanouthttp
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
 	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	gokithttp "github.com/go-kit/kit/transport/http"
 )
@@ -31,15 +34,62 @@ func (fr *fanoutRequest) Entity() interface{} {
 	return fr.entity
 }
 
+// limitedBody is an io.ReadCloser that fails with a 413 error once more than a maximum number of bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	max       int64
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.remaining < 0 {
+		return 0, newRequestEntityTooLarge(lb.max)
+	}
+
+	// allow reading 1 byte past the maximum, so that an oversized body can be detected
+	if int64(len(p)) > lb.remaining+1 {
+		p = p[:lb.remaining+1]
+	}
+
+	n, err := lb.ReadCloser.Read(p)
+	lb.remaining -= int64(n)
+	if lb.remaining < 0 {
+		return n, newRequestEntityTooLarge(lb.max)
+	}
+
+	return n, err
+}
+
+// newRequestEntityTooLarge creates the error returned when an original request's entity exceeds the configured maximum
+func newRequestEntityTooLarge(max int64) error {
+	return &xhttp.Error{
+		Code: http.StatusRequestEntityTooLarge,
+		Text: fmt.Sprintf("Request entity exceeds the maximum of %d bytes", max),
+	}
+}
+
 // decodeFanoutRequest is executed once per original request to turn an HTTP request into a fanoutRequest.
 // The dec is used to perform one-time parsing on the original request to produce a custom entity object.
 // If the dec function is nil, this function panics.
-func decodeFanoutRequest(dec gokithttp.DecodeRequestFunc) gokithttp.DecodeRequestFunc {
+//
+// If maxRequestBody is positive, original requests whose entities are larger than that many bytes are rejected with
+// an error carrying http.StatusRequestEntityTooLarge.  This happens during decoding, before any component is invoked.
+func decodeFanoutRequest(dec gokithttp.DecodeRequestFunc, maxRequestBody int64) gokithttp.DecodeRequestFunc {
 	if dec == nil {
 		panic("The entity decoder cannot be nil")
 	}
 
 	return func(ctx context.Context, original *http.Request) (interface{}, error) {
+		if maxRequestBody > 0 {
+			if original.ContentLength > maxRequestBody {
+				return nil, newRequestEntityTooLarge(maxRequestBody)
+			}
+
+			if original.Body != nil {
+				original.Body = &limitedBody{ReadCloser: original.Body, remaining: maxRequestBody, max: maxRequestBody}
+			}
+		}
+
 		entity, err := dec(ctx, original)
 		if err != nil {
 			return nil, err
@@ -59,8 +109,31 @@ func decodeFanoutRequest(dec gokithttp.DecodeRequestFunc) gokithttp.DecodeReques
 
 }
 
+// resolveComponentURL produces the URL for a component request by resolving the original, relative URL against
+// the component's base URL.  If the base URL has a query, its parameters are merged with those of the original
+// request.  A parameter present in both replaces the original request's values with the component's values.
+func resolveComponentURL(base, relativeURL *url.URL) *url.URL {
+	resolved := base.ResolveReference(relativeURL)
+	if len(base.RawQuery) > 0 {
+		// NewComponents has already verified that the base query is valid
+		merged, _ := url.ParseQuery(relativeURL.RawQuery)
+		if merged == nil {
+			merged = make(url.Values)
+		}
+
+		for name, values := range base.Query() {
+			merged[name] = values
+		}
+
+		resolved.RawQuery = merged.Encode()
+	}
+
+	return resolved
+}
+
 // encodeComponentRequest creates the EncodeRequestFunc invoked for each component endpoint of a fanout.  Input to the
-// return function is always a *fanoutRequest.  If the enc function is nil, this function panics.
+// return function is always a *fanoutRequest.  The standard proxy headers, X-Forwarded-For, X-Forwarded-Proto, and Via,
+// are added to each component request.  If the enc function is nil, this function panics.
 func encodeComponentRequest(enc gokithttp.EncodeRequestFunc) gokithttp.EncodeRequestFunc {
 	if enc == nil {
 		panic("The entity encoder cannot be nil")
@@ -70,7 +143,8 @@ func encodeComponentRequest(enc gokithttp.EncodeRequestFunc) gokithttp.EncodeReq
 		fanoutRequest := v.(*fanoutRequest)
 
 		component.Method = fanoutRequest.original.Method
-		component.URL = component.URL.ResolveReference(fanoutRequest.relativeURL)
+		component.URL = resolveComponentURL(component.URL, fanoutRequest.relativeURL)
+		addProxyHeaders(component, fanoutRequest.original)
 
 		return enc(ctx, component, fanoutRequest.entity)
 	}
@@ -80,32 +154,85 @@ func encodeComponentRequest(enc gokithttp.EncodeRequestFunc) gokithttp.EncodeReq
 // a fanoutRequest.  However, the encoder function is only expected to decode the HTTP entity.  The fanoutRequest is never passed
 // to the supplied encoder function.
 //
+// A URL may include a query string, e.g. to supply an API key or version required by that component.  The parameters
+// in that query string are added to each component request, replacing any parameters of the same name in the original request.
+//
 // This factory function is the approximate equivalent of go-kit's transport/http.NewClient.  In effect, it creates a multi-client.
 // The resulting components can in turn be passed to fanout.New to create the aggregate fanout endpoint.
 func NewComponents(urls []string, enc gokithttp.EncodeRequestFunc, dec gokithttp.DecodeResponseFunc, options ...gokithttp.ClientOption) (fanout.Components, error) {
-	components := make(fanout.Components, len(urls))
-	for _, raw := range urls {
-		target, err := url.Parse(raw)
+	targets := make([]Target, len(urls))
+	for i, raw := range urls {
+		targets[i].URL = raw
+	}
+
+	return NewTargetComponents(targets, nil, enc, dec, options...)
+}
+
+// NewTargetComponents is like NewComponents, except that each component may have its own TLS configuration, transport,
+// credentials, and client options.  This allows a single fanout to mix mutually authenticated and plain backends, or backends with
+// different timeouts and proxies.  The components are keyed by each target's URL.
+//
+// The newClient function creates the HTTP client for a target with the given TLS configuration, which is nil for targets
+// without one.  For example, Options.NewTLSClient may be passed.  If newClient is nil, targets without a TLS configuration
+// or transport use whatever client the options specify, while other targets use a client with default settings.
+// A client created for a target replaces any client set via the shared options, though not one set via the target's own options.
+func NewTargetComponents(targets []Target, newClient func(*tls.Config) *http.Client, enc gokithttp.EncodeRequestFunc, dec gokithttp.DecodeResponseFunc, options ...gokithttp.ClientOption) (fanout.Components, error) {
+	components := make(fanout.Components, len(targets))
+	for _, t := range targets {
+		target, err := url.Parse(t.URL)
 		if err != nil {
 			return nil, err
 		}
 
 		if len(target.Scheme) == 0 {
-			return nil, fmt.Errorf("Endpoint '%s' does not specify a scheme", raw)
+			return nil, fmt.Errorf("Endpoint '%s' does not specify a scheme", t.URL)
 		}
 
-		if len(target.RawQuery) > 0 {
-			return nil, fmt.Errorf("Endpoint '%s' specifies a query string", raw)
+		if _, err := url.ParseQuery(target.RawQuery); err != nil {
+			return nil, fmt.Errorf("Endpoint '%s' specifies an invalid query string: %s", t.URL, err)
+		}
+
+		var client *http.Client
+		if newClient != nil {
+			client = newClient(t.TLS)
+		} else if t.TLS != nil || t.Transport != nil {
+			client = newTLSClient(t.TLS)
+		}
+
+		if t.Transport != nil {
+			// don't modify the client, as newClient may return a shared instance or nil
+			var copyOf http.Client
+			if client != nil {
+				copyOf = *client
+			}
+
+			copyOf.Transport = t.Transport
+			client = &copyOf
+		}
+
+		authorization := t.Credentials.Authorization()
+		componentOptions := options
+		if client != nil || len(authorization) > 0 || len(t.Options) > 0 {
+			componentOptions = append([]gokithttp.ClientOption{}, options...)
+			if client != nil {
+				componentOptions = append(componentOptions, gokithttp.SetClient(client))
+			}
+
+			if len(authorization) > 0 {
+				componentOptions = append(componentOptions, gokithttp.ClientBefore(SetAuthorization(authorization)))
+			}
+
+			componentOptions = append(componentOptions, t.Options...)
 		}
 
 		// the method and target don't really matter, since they'll be replaced on each
 		// request with the appropriate information from the original HTTP request.
-		components[raw] = gokithttp.NewClient(
+		components[t.URL] = gokithttp.NewClient(
 			"GET",
 			target,
 			encodeComponentRequest(enc),
 			dec,
-			options...,
+			componentOptions...,
 		).Endpoint()
 	}
 
@@ -121,9 +248,16 @@ func NewComponents(urls []string, enc gokithttp.EncodeRequestFunc, dec gokithttp
 // The encode response function is used the encode the component-specific response object.  It is passed the same response
 // object that comes from a successful fanout.Components endpoint.
 func NewHandler(endpoint endpoint.Endpoint, dec gokithttp.DecodeRequestFunc, enc gokithttp.EncodeResponseFunc, options ...gokithttp.ServerOption) http.Handler {
+	return NewLimitedHandler(endpoint, 0, dec, enc, options...)
+}
+
+// NewLimitedHandler is like NewHandler, except that original requests with entities larger than maxRequestBody bytes
+// are rejected with http.StatusRequestEntityTooLarge before any component is invoked.  This protects every component
+// of the fanout at once.  If maxRequestBody is nonpositive, entities of any size are allowed.
+func NewLimitedHandler(endpoint endpoint.Endpoint, maxRequestBody int64, dec gokithttp.DecodeRequestFunc, enc gokithttp.EncodeResponseFunc, options ...gokithttp.ServerOption) http.Handler {
 	return gokithttp.NewServer(
-		endpoint,
-		decodeFanoutRequest(dec),
+		recordAccess(endpoint),
+		decodeFanoutRequest(dec, maxRequestBody),
 		enc,
 		options...,
 	)
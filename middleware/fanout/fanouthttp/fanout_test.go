@@ -22,7 +22,7 @@ import (
 func testDecodeFanoutRequestNilDecoder(t *testing.T, originalURL, relativeURL string) {
 	assert := assert.New(t)
 	assert.Panics(func() {
-		decodeFanoutRequest(nil)
+		decodeFanoutRequest(nil, 0)
 	})
 }
 
@@ -43,6 +43,7 @@ func testDecodeFanoutRequestCustomDecoder(t *testing.T, originalURL, relativeURL
 
 				return "decoded body", nil
 			},
+			0,
 		)
 	)
 
@@ -75,6 +76,7 @@ func testDecodeFanoutRequestCustomDecoderError(t *testing.T) {
 
 				return "decoded body", expectedError
 			},
+			0,
 		)
 	)
 
@@ -84,6 +86,39 @@ func testDecodeFanoutRequestCustomDecoderError(t *testing.T) {
 	assert.Equal(expectedError, err)
 }
 
+func testDecodeFanoutRequestMaxRequestBody(t *testing.T, body string, contentLength int64, expectedCode int) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		decoderCalled = false
+		original      = httptest.NewRequest("POST", "/does/not/matter", strings.NewReader(body))
+		decoder       = decodeFanoutRequest(
+			func(_ context.Context, original *http.Request) (interface{}, error) {
+				decoderCalled = true
+				entity, err := ioutil.ReadAll(original.Body)
+				return string(entity), err
+			},
+			10,
+		)
+	)
+
+	original.ContentLength = contentLength
+	require.NotNil(decoder)
+	v, err := decoder(context.Background(), original)
+	if expectedCode == 0 {
+		require.NoError(err)
+		require.NotNil(v)
+		assert.True(decoderCalled)
+		assert.Equal(body, v.(*fanoutRequest).entity)
+		return
+	}
+
+	assert.Nil(v)
+	require.Error(err)
+	assert.Equal(expectedCode, StatusCodeForError(err))
+}
+
 func TestDecodeFanoutRequest(t *testing.T) {
 	var testData = []struct {
 		originalURL, relativeURL string
@@ -107,6 +142,24 @@ func TestDecodeFanoutRequest(t *testing.T) {
 	})
 
 	t.Run("CustomDecoderError", testDecodeFanoutRequestCustomDecoderError)
+
+	t.Run("MaxRequestBody", func(t *testing.T) {
+		t.Run("Empty", func(t *testing.T) {
+			testDecodeFanoutRequestMaxRequestBody(t, "", 0, 0)
+		})
+
+		t.Run("AtLimit", func(t *testing.T) {
+			testDecodeFanoutRequestMaxRequestBody(t, "0123456789", 10, 0)
+		})
+
+		t.Run("ContentLength", func(t *testing.T) {
+			testDecodeFanoutRequestMaxRequestBody(t, "0123456789A", 11, http.StatusRequestEntityTooLarge)
+		})
+
+		t.Run("Chunked", func(t *testing.T) {
+			testDecodeFanoutRequestMaxRequestBody(t, "0123456789A", -1, http.StatusRequestEntityTooLarge)
+		})
+	})
 }
 
 func testEncodeComponentRequestNilEncoder(t *testing.T) {
@@ -147,6 +200,8 @@ func testEncodeComponentRequestCustomEncoder(t *testing.T) {
 	assert.NoError(encoder(expectedCtx, expectedComponent, fanoutRequest))
 	assert.Equal(original.Method, expectedComponent.Method)
 	assert.Equal("http://localhost:1234/foo/bar", expectedComponent.URL.String())
+	assert.Equal("192.0.2.1", expectedComponent.Header.Get("X-Forwarded-For"))
+	assert.Equal("1.1 fanout", expectedComponent.Header.Get("Via"))
 	assert.True(customEncoderCalled)
 }
 
@@ -185,6 +240,35 @@ func testEncodeComponentRequestCustomEncoderError(t *testing.T) {
 	assert.True(customEncoderCalled)
 }
 
+func TestResolveComponentURL(t *testing.T) {
+	testData := []struct {
+		base, relative, expected string
+	}{
+		{"http://localhost:1234", "/foo/bar", "http://localhost:1234/foo/bar"},
+		{"http://localhost:1234", "/foo/bar?a=1&b=2", "http://localhost:1234/foo/bar?a=1&b=2"},
+		{"http://localhost:1234?key=abc", "/foo/bar", "http://localhost:1234/foo/bar?key=abc"},
+		{"http://localhost:1234?key=abc&v=2", "/foo/bar?a=1", "http://localhost:1234/foo/bar?a=1&key=abc&v=2"},
+		{"http://localhost:1234?key=abc", "/foo/bar?key=override&key=again&a=1", "http://localhost:1234/foo/bar?a=1&key=abc"},
+	}
+
+	for _, record := range testData {
+		t.Run(record.base+record.relative, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			base, err := url.Parse(record.base)
+			require.NoError(err)
+
+			relative, err := url.Parse(record.relative)
+			require.NoError(err)
+
+			assert.Equal(record.expected, resolveComponentURL(base, relative).String())
+		})
+	}
+}
+
 func TestEncodeComponentRequest(t *testing.T) {
 	t.Run("NilEncoder", testEncodeComponentRequestNilEncoder)
 	t.Run("CustomEncoder", testEncodeComponentRequestCustomEncoder)
@@ -193,7 +277,7 @@ func TestEncodeComponentRequest(t *testing.T) {
 
 func testNewComponentsInvalidURL(t *testing.T) {
 	assert := assert.New(t)
-	for _, bad := range []string{"h\\ttp://localhost", "/foo/bar", "http://comcast.net:8080/test?v=1"} {
+	for _, bad := range []string{"h\\ttp://localhost", "/foo/bar", "http://comcast.net:8080/test?v=%zz"} {
 		components, err := NewComponents([]string{bad}, nil, nil)
 		assert.Empty(components)
 		assert.Error(err)
@@ -230,6 +314,7 @@ func TestNewComponents(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		testNewComponentsSuccess(t, "http://something.comcast.net:8080")
 		testNewComponentsSuccess(t, "http://somehost.com", "https://anotherhost.net:1212/foo/bar")
+		testNewComponentsSuccess(t, "http://somehost.com?apiKey=1234", "https://anotherhost.net:1212/foo/bar?v=2&key=abc")
 	})
 }
 
@@ -357,8 +442,34 @@ func testNewHandlerIntegration(t *testing.T, componentCount int) {
 	assert.Equal(http.StatusOK, response.Code)
 }
 
+func testNewLimitedHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request  = httptest.NewRequest("POST", "http://localhost/foo/bar", strings.NewReader("this entity is too large"))
+		response = httptest.NewRecorder()
+
+		handler = NewLimitedHandler(
+			func(ctx context.Context, v interface{}) (interface{}, error) {
+				assert.Fail("The endpoint should not have been called")
+				return nil, nil
+			},
+			10,
+			DecodePassThroughRequest,
+			EncodePassThroughResponse,
+			gokithttp.ServerErrorEncoder(ServerErrorEncoder("")),
+		)
+	)
+
+	require.NotNil(handler)
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusRequestEntityTooLarge, response.Code)
+}
+
 func TestNewHandler(t *testing.T) {
 	t.Run("ServeHTTP", testNewHandlerServeHTTP)
+	t.Run("Limited", testNewLimitedHandler)
 	t.Run("Integration", func(t *testing.T) {
 		t.Run("Components-1", func(t *testing.T) {
 			testNewHandlerIntegration(t, 1)
@@ -0,0 +1,90 @@
+package fanouthttp
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// HeaderPolicy describes which headers from the original request are forwarded onto each component request.
+// Each entry is either a header name, e.g. "Authorization", or a prefix ending with "*", e.g. "X-Webpa-*".
+// Entries are matched case-insensitively.
+type HeaderPolicy struct {
+	// Allow lists the headers that are forwarded.  If empty, all headers are forwarded unless denied.
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny lists the headers that are never forwarded.  Deny takes precedence over Allow.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// headerMatcher is the compiled form of a list of HeaderPolicy entries
+type headerMatcher struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+func newHeaderMatcher(entries []string) *headerMatcher {
+	hm := &headerMatcher{names: make(map[string]bool, len(entries))}
+	for _, e := range entries {
+		if strings.HasSuffix(e, "*") {
+			hm.prefixes = append(hm.prefixes, textproto.CanonicalMIMEHeaderKey(strings.TrimSuffix(e, "*")))
+		} else {
+			hm.names[textproto.CanonicalMIMEHeaderKey(e)] = true
+		}
+	}
+
+	return hm
+}
+
+func (hm *headerMatcher) empty() bool {
+	return len(hm.names) == 0 && len(hm.prefixes) == 0
+}
+
+// matches tests if the given canonical header name matches any entry
+func (hm *headerMatcher) matches(name string) bool {
+	if hm.names[name] {
+		return true
+	}
+
+	for _, p := range hm.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ForwardHeaders is a component client RequestFunc which copies headers from the original request onto each
+// component request of a fanout, as permitted by the given policy.  Headers already present on the component request,
+// such as those set by the component's encoder, are never replaced.  Content-Length is never forwarded, as the component
+// entity may differ from the original.  Hop-by-hop headers, as defined by RFC 7230, are never forwarded either.
+//
+// The returned RequestFunc requires that the fanoutRequest is available in the context.
+func ForwardHeaders(policy HeaderPolicy) gokithttp.RequestFunc {
+	var (
+		allow = newHeaderMatcher(policy.Allow)
+		deny  = newHeaderMatcher(append([]string{"Content-Length"}, policy.Deny...))
+	)
+
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if fr, ok := fanout.FromContext(ctx).(*fanoutRequest); ok {
+			isHopByHop := hopByHop(fr.original.Header)
+			for name, values := range fr.original.Header {
+				if _, exists := r.Header[name]; exists || deny.matches(name) || isHopByHop(name) {
+					continue
+				}
+
+				if allow.empty() || allow.matches(name) {
+					r.Header[name] = values
+				}
+			}
+		}
+
+		return ctx
+	}
+}
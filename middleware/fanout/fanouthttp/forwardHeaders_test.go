@@ -0,0 +1,102 @@
+package fanouthttp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testForwardHeaders(t *testing.T, policy HeaderPolicy, expected, unexpected []string) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		original      = httptest.NewRequest("POST", "/foo/bar", nil)
+		fanoutRequest = &fanoutRequest{
+			original: original,
+		}
+
+		component      = httptest.NewRequest("POST", "/", nil)
+		forwardHeaders = ForwardHeaders(policy)
+	)
+
+	require.NotNil(forwardHeaders)
+
+	original.Header.Set("Authorization", "Basic dGVzdDp0ZXN0")
+	original.Header.Set("X-Webpa-Device-Name", "mac:112233445566")
+	original.Header.Add("X-Webpa-Transaction-Id", "1")
+	original.Header.Add("X-Webpa-Transaction-Id", "2")
+	original.Header.Set("X-Other", "other")
+	original.Header.Set("Content-Type", "text/plain")
+	original.Header.Set("Content-Length", "123")
+	original.Header.Set("Connection", "X-Webpa-Hop")
+	original.Header.Set("X-Webpa-Hop", "hop")
+	original.Header.Set("Keep-Alive", "timeout=5")
+	original.Header.Set("Upgrade", "websocket")
+	component.Header.Set("Content-Type", "application/msgpack")
+
+	ctx := fanout.NewContext(context.Background(), fanoutRequest)
+	assert.Equal(ctx, forwardHeaders(ctx, component))
+
+	for _, name := range expected {
+		assert.Equal(original.Header[name], component.Header[name], "header %s should have been forwarded", name)
+	}
+
+	for _, name := range unexpected {
+		assert.Empty(component.Header[name], "header %s should not have been forwarded", name)
+	}
+
+	assert.Equal("application/msgpack", component.Header.Get("Content-Type"))
+	assert.Empty(component.Header.Get("Content-Length"))
+	for _, name := range []string{"Connection", "X-Webpa-Hop", "Keep-Alive", "Upgrade"} {
+		assert.Empty(component.Header[name], "hop-by-hop header %s should not have been forwarded", name)
+	}
+}
+
+func TestForwardHeaders(t *testing.T) {
+	t.Run("NoFanoutRequest", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			component = httptest.NewRequest("GET", "/", nil)
+			ctx       = context.Background()
+		)
+
+		assert.Equal(ctx, ForwardHeaders(HeaderPolicy{})(ctx, component))
+		assert.Empty(component.Header)
+	})
+
+	t.Run("All", func(t *testing.T) {
+		testForwardHeaders(t,
+			HeaderPolicy{},
+			[]string{"Authorization", "X-Webpa-Device-Name", "X-Webpa-Transaction-Id", "X-Other"},
+			nil,
+		)
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		testForwardHeaders(t,
+			HeaderPolicy{Allow: []string{"authorization", "x-webpa-*"}},
+			[]string{"Authorization", "X-Webpa-Device-Name", "X-Webpa-Transaction-Id"},
+			[]string{"X-Other"},
+		)
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		testForwardHeaders(t,
+			HeaderPolicy{Deny: []string{"Authorization", "X-Webpa-Transaction-*"}},
+			[]string{"X-Webpa-Device-Name", "X-Other"},
+			[]string{"Authorization", "X-Webpa-Transaction-Id"},
+		)
+	})
+
+	t.Run("AllowAndDeny", func(t *testing.T) {
+		testForwardHeaders(t,
+			HeaderPolicy{Allow: []string{"X-Webpa-*"}, Deny: []string{"X-Webpa-Device-Name"}},
+			[]string{"X-Webpa-Transaction-Id"},
+			[]string{"Authorization", "X-Webpa-Device-Name", "X-Other"},
+		)
+	})
+}
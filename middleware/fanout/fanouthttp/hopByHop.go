@@ -0,0 +1,42 @@
+package fanouthttp
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// hopByHopHeaders are the canonicalized headers which apply only to a single connection, and so must not be
+// forwarded by a proxy.  See RFC 7230, section 6.1.  Proxy-Connection is nonstandard, but is still sent by some clients.
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// hopByHop returns a predicate that tests if a canonicalized header name from the given header is hop-by-hop.
+// In addition to the standard hop-by-hop headers, any header named by a Connection header is hop-by-hop.
+func hopByHop(h http.Header) func(string) bool {
+	var connection map[string]bool
+	for _, value := range h["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				if connection == nil {
+					connection = make(map[string]bool)
+				}
+
+				connection[textproto.CanonicalMIMEHeaderKey(name)] = true
+			}
+		}
+	}
+
+	return func(name string) bool {
+		return hopByHopHeaders[name] || connection[name]
+	}
+}
@@ -0,0 +1,27 @@
+package fanouthttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHopByHop(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		isHopByHop = hopByHop(http.Header{
+			"Connection": []string{"keep-alive, x-custom-hop", " x-another-hop ,"},
+		})
+	)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "X-Custom-Hop", "X-Another-Hop"} {
+		assert.True(isHopByHop(name), "%s should be hop-by-hop", name)
+	}
+
+	for _, name := range []string{"Authorization", "Content-Type", "X-Webpa-Device-Name", ""} {
+		assert.False(isHopByHop(name), "%s should not be hop-by-hop", name)
+	}
+
+	assert.False(hopByHop(http.Header{})("X-Custom-Hop"))
+}
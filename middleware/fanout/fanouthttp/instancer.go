@@ -0,0 +1,131 @@
+package fanouthttp
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/sd"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// InstancerComponents maintains a set of fanout components, one for each instance known to a service discovery
+// sd.Instancer.  Each instance is expected to be a URL suitable for NewComponents.  This type implements fanout.Componenter,
+// and so may be passed to fanout.NewDynamic.
+type InstancerComponents struct {
+	errorLog log.Logger
+	infoLog  log.Logger
+
+	enc     gokithttp.EncodeRequestFunc
+	dec     gokithttp.DecodeResponseFunc
+	options []gokithttp.ClientOption
+
+	instancer sd.Instancer
+	events    chan sd.Event
+	stopOnce  sync.Once
+	stopped   chan struct{}
+	current   atomic.Value
+}
+
+// NewComponentsFromInstancer subscribes to the given sd.Instancer, and returns an InstancerComponents which keeps its
+// components up to date as instances register and deregister.  The encoder, decoder, and options are used just as with
+// NewComponents.  Instances that are not valid component URLs are logged and ignored.  After a service discovery error,
+// the last known components continue to be used.
+//
+// The initial set of instances is processed before this function returns.  Stop must be called to release the subscription.
+// If logger is nil, logging.DefaultLogger is used.  If enc is nil, this function panics.
+func NewComponentsFromInstancer(logger log.Logger, i sd.Instancer, enc gokithttp.EncodeRequestFunc, dec gokithttp.DecodeResponseFunc, options ...gokithttp.ClientOption) *InstancerComponents {
+	if enc == nil {
+		panic("The entity encoder cannot be nil")
+	}
+
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	ic := &InstancerComponents{
+		errorLog:  logging.Error(logger),
+		infoLog:   logging.Info(logger),
+		enc:       enc,
+		dec:       dec,
+		options:   options,
+		instancer: i,
+		events:    make(chan sd.Event, 10),
+		stopped:   make(chan struct{}),
+	}
+
+	ic.current.Store(fanout.Components{})
+	i.Register(ic.events)
+
+	// the standard go-kit instancers send the current instances immediately upon registration
+	select {
+	case e := <-ic.events:
+		ic.update(e)
+	default:
+	}
+
+	go ic.monitor()
+	return ic
+}
+
+// Components returns the current components.  This method implements fanout.Componenter.
+func (ic *InstancerComponents) Components() fanout.Components {
+	return ic.current.Load().(fanout.Components)
+}
+
+// Stop halts updates and deregisters from the Instancer.  The current components remain available.
+// This method is idempotent.
+func (ic *InstancerComponents) Stop() {
+	ic.stopOnce.Do(func() {
+		close(ic.stopped)
+	})
+}
+
+// update replaces the current components in response to a service discovery event.  Components for instances
+// that were already known are reused.
+func (ic *InstancerComponents) update(e sd.Event) {
+	if e.Err != nil {
+		ic.errorLog.Log(logging.MessageKey(), "service discovery error", logging.ErrorKey(), e.Err)
+		return
+	}
+
+	var (
+		previous = ic.Components()
+		next     = make(fanout.Components, len(e.Instances))
+	)
+
+	for _, instance := range e.Instances {
+		if existing, ok := previous[instance]; ok {
+			next[instance] = existing
+			continue
+		}
+
+		created, err := NewComponents([]string{instance}, ic.enc, ic.dec, ic.options...)
+		if err != nil {
+			ic.errorLog.Log(logging.MessageKey(), "ignoring invalid instance", "instance", instance, logging.ErrorKey(), err)
+			continue
+		}
+
+		next[instance] = created[instance]
+	}
+
+	ic.infoLog.Log(logging.MessageKey(), "updated fanout components", "instances", e.Instances)
+	ic.current.Store(next)
+}
+
+// monitor is the goroutine which processes service discovery events until Stop is called
+func (ic *InstancerComponents) monitor() {
+	defer ic.instancer.Deregister(ic.events)
+
+	for {
+		select {
+		case e := <-ic.events:
+			ic.update(e)
+
+		case <-ic.stopped:
+			return
+		}
+	}
+}
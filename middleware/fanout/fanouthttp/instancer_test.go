@@ -0,0 +1,140 @@
+package fanouthttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInstancer is a simple sd.Instancer that allows tests to push events
+type testInstancer struct {
+	lock      sync.Mutex
+	instances []string
+	channels  map[chan<- sd.Event]bool
+}
+
+func (ti *testInstancer) Register(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	if ti.channels == nil {
+		ti.channels = make(map[chan<- sd.Event]bool)
+	}
+
+	ti.channels[ch] = true
+	ch <- sd.Event{Instances: ti.instances}
+}
+
+func (ti *testInstancer) Deregister(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	delete(ti.channels, ch)
+}
+
+func (ti *testInstancer) registered() int {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	return len(ti.channels)
+}
+
+func (ti *testInstancer) send(e sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	for ch := range ti.channels {
+		ch <- e
+	}
+}
+
+func waitForComponents(t *testing.T, ic *InstancerComponents, expected ...string) fanout.Components {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		components := ic.Components()
+		if len(components) == len(expected) {
+			matched := true
+			for _, name := range expected {
+				if _, ok := components[name]; !ok {
+					matched = false
+				}
+			}
+
+			if matched {
+				return components
+			}
+		}
+
+		if time.Now().After(deadline) {
+			require.FailNow(t, "components were not updated", "expected: %v, actual: %v", expected, components)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewComponentsFromInstancer(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		logger    = logging.NewTestLogger(nil, t)
+		instancer = &testInstancer{instances: []string{"http://host1.comcast.net:8080", "not a url"}}
+
+		enc = func(context.Context, *http.Request, interface{}) error { return nil }
+		dec = func(context.Context, *http.Response) (interface{}, error) { return nil, nil }
+
+		ic = NewComponentsFromInstancer(logger, instancer, enc, dec)
+	)
+
+	require.NotNil(ic)
+	defer ic.Stop()
+
+	initial := ic.Components()
+	require.Len(initial, 1)
+	assert.Contains(initial, "http://host1.comcast.net:8080")
+	assert.Equal(1, instancer.registered())
+
+	instancer.send(sd.Event{Instances: []string{"http://host1.comcast.net:8080", "http://host2.comcast.net:8080"}})
+	updated := waitForComponents(t, ic, "http://host1.comcast.net:8080", "http://host2.comcast.net:8080")
+	assert.Len(updated, 2)
+
+	// an error event should leave the components unchanged
+	instancer.send(sd.Event{Err: errors.New("expected")})
+	instancer.send(sd.Event{Instances: []string{"http://host2.comcast.net:8080"}})
+	waitForComponents(t, ic, "http://host2.comcast.net:8080")
+
+	// a dynamic fanout can use the components directly
+	assert.NotNil(fanout.NewDynamic(tracing.NewSpanner(), ic))
+
+	ic.Stop()
+	ic.Stop()
+
+	for repeat := 0; repeat < 500 && instancer.registered() > 0; repeat++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Zero(instancer.registered())
+	assert.Len(ic.Components(), 1)
+}
+
+func TestNewComponentsFromInstancerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		enc    = func(context.Context, *http.Request, interface{}) error { return nil }
+		dec    = func(context.Context, *http.Response) (interface{}, error) { return nil, nil }
+		ic     = NewComponentsFromInstancer(nil, sd.FixedInstancer{"http://host1.comcast.net"}, enc, dec)
+	)
+
+	defer ic.Stop()
+	assert.Len(ic.Components(), 1)
+
+	assert.Panics(func() {
+		NewComponentsFromInstancer(nil, sd.FixedInstancer{}, nil, dec)
+	})
+}
@@ -0,0 +1,91 @@
+package fanouthttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+)
+
+// URLLabel is the metric label which holds the component name, i.e. the target URL, of each fanout leg
+const URLLabel = "url"
+
+// Measures holds the metric objects used to instrument each component of an HTTP fanout.  Any field may be nil,
+// in which case that metric is not recorded.
+type Measures struct {
+	// Requests counts each component request, successful or not
+	Requests metrics.Counter
+
+	// Errors counts each component request that returned an error
+	Errors metrics.Counter
+
+	// Duration observes the time, in seconds, that each component request took
+	Duration metrics.Histogram
+
+	// InFlight tracks the number of component requests currently executing
+	InFlight metrics.Gauge
+}
+
+// Instrument decorates each component endpoint so that its requests are recorded with these Measures.  Each metric
+// is labeled with URLLabel set to the component's name, which for components created by this package is the target URL.
+// The returned Components is a distinct map, and the original is not modified.
+func (m Measures) Instrument(components fanout.Components) fanout.Components {
+	instrumented := make(fanout.Components, len(components))
+	for name, e := range components {
+		instrumented[name] = m.instrument(name, e)
+	}
+
+	return instrumented
+}
+
+// instrument decorates a single component endpoint
+func (m Measures) instrument(name string, next endpoint.Endpoint) endpoint.Endpoint {
+	var (
+		requests metrics.Counter
+		errors   metrics.Counter
+		duration metrics.Histogram
+		inFlight metrics.Gauge
+	)
+
+	if m.Requests != nil {
+		requests = m.Requests.With(URLLabel, name)
+	}
+
+	if m.Errors != nil {
+		errors = m.Errors.With(URLLabel, name)
+	}
+
+	if m.Duration != nil {
+		duration = m.Duration.With(URLLabel, name)
+	}
+
+	if m.InFlight != nil {
+		inFlight = m.InFlight.With(URLLabel, name)
+	}
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if inFlight != nil {
+			inFlight.Add(1.0)
+			defer inFlight.Add(-1.0)
+		}
+
+		start := time.Now()
+		response, err := next(ctx, request)
+
+		if requests != nil {
+			requests.Add(1.0)
+		}
+
+		if err != nil && errors != nil {
+			errors.Add(1.0)
+		}
+
+		if duration != nil {
+			duration.Observe(time.Since(start).Seconds())
+		}
+
+		return response, err
+	}
+}
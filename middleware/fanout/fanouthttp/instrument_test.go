@@ -0,0 +1,110 @@
+package fanouthttp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testMeasuresInstrumentEmpty(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		measures Measures
+		called   = false
+
+		components = fanout.Components{
+			"http://localhost:8080": func(context.Context, interface{}) (interface{}, error) {
+				called = true
+				return "response", nil
+			},
+		}
+	)
+
+	instrumented := measures.Instrument(components)
+	assert.Len(instrumented, 1)
+
+	response, err := instrumented["http://localhost:8080"](context.Background(), "request")
+	assert.Equal("response", response)
+	assert.NoError(err)
+	assert.True(called)
+}
+
+func testMeasuresInstrument(t *testing.T, expectedErr error) {
+	var (
+		assert = assert.New(t)
+		name   = "http://localhost:8080"
+
+		requests        = new(mockCounter)
+		labeledRequests = new(mockCounter)
+		errorCount      = new(mockCounter)
+		labeledErrors   = new(mockCounter)
+		duration        = new(mockHistogram)
+		labeledDuration = new(mockHistogram)
+		inFlight        = new(mockGauge)
+		labeledInFlight = new(mockGauge)
+
+		measures = Measures{
+			Requests: requests,
+			Errors:   errorCount,
+			Duration: duration,
+			InFlight: inFlight,
+		}
+
+		components = fanout.Components{
+			name: func(ctx context.Context, request interface{}) (interface{}, error) {
+				labeledInFlight.AssertCalled(t, "Add", 1.0)
+				labeledInFlight.AssertNotCalled(t, "Add", -1.0)
+				return "response", expectedErr
+			},
+		}
+	)
+
+	requests.On("With", []string{URLLabel, name}).Return(metrics.Counter(labeledRequests)).Once()
+	labeledRequests.On("Add", 1.0).Once()
+	errorCount.On("With", []string{URLLabel, name}).Return(metrics.Counter(labeledErrors)).Once()
+	duration.On("With", []string{URLLabel, name}).Return(metrics.Histogram(labeledDuration)).Once()
+	labeledDuration.On("Observe", mock.MatchedBy(func(v float64) bool { return v >= 0.0 })).Once()
+	inFlight.On("With", []string{URLLabel, name}).Return(metrics.Gauge(labeledInFlight)).Once()
+	labeledInFlight.On("Add", 1.0).Once()
+	labeledInFlight.On("Add", -1.0).Once()
+
+	if expectedErr != nil {
+		labeledErrors.On("Add", 1.0).Once()
+	}
+
+	instrumented := measures.Instrument(components)
+	assert.Len(instrumented, 1)
+	assert.Len(components, 1)
+
+	response, actualErr := instrumented[name](context.Background(), "request")
+	assert.Equal("response", response)
+	assert.Equal(expectedErr, actualErr)
+
+	requests.AssertExpectations(t)
+	labeledRequests.AssertExpectations(t)
+	errorCount.AssertExpectations(t)
+	labeledErrors.AssertExpectations(t)
+	duration.AssertExpectations(t)
+	labeledDuration.AssertExpectations(t)
+	inFlight.AssertExpectations(t)
+	labeledInFlight.AssertExpectations(t)
+}
+
+func TestMeasures(t *testing.T) {
+	t.Run("Instrument", func(t *testing.T) {
+		t.Run("Empty", testMeasuresInstrumentEmpty)
+
+		t.Run("Success", func(t *testing.T) {
+			testMeasuresInstrument(t, nil)
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			testMeasuresInstrument(t, errors.New("expected"))
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package fanouthttp
+
+import (
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	ComponentRequestCounter    = "fanout_component_request_count"
+	ComponentErrorCounter      = "fanout_component_error_count"
+	ComponentDurationHistogram = "fanout_component_duration_seconds"
+	ComponentInFlightGauge     = "fanout_component_in_flight"
+)
+
+// Metrics is the fanouthttp module function that adds the default per-component metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		xmetrics.Metric{
+			Name:       ComponentRequestCounter,
+			Type:       "counter",
+			LabelNames: []string{URLLabel},
+		},
+		xmetrics.Metric{
+			Name:       ComponentErrorCounter,
+			Type:       "counter",
+			LabelNames: []string{URLLabel},
+		},
+		xmetrics.Metric{
+			Name:       ComponentDurationHistogram,
+			Type:       "histogram",
+			LabelNames: []string{URLLabel},
+		},
+		xmetrics.Metric{
+			Name:       ComponentInFlightGauge,
+			Type:       "gauge",
+			LabelNames: []string{URLLabel},
+		},
+	}
+}
+
+// NewMeasures constructs a Measures given a go-kit metrics Provider
+func NewMeasures(p provider.Provider) Measures {
+	return Measures{
+		Requests: p.NewCounter(ComponentRequestCounter),
+		Errors:   p.NewCounter(ComponentErrorCounter),
+		Duration: p.NewHistogram(ComponentDurationHistogram, 10),
+		InFlight: p.NewGauge(ComponentInFlightGauge),
+	}
+}
@@ -0,0 +1,44 @@
+package fanouthttp
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	var (
+		require = require.New(t)
+	)
+
+	r, err := xmetrics.NewRegistry(nil, Metrics)
+	require.NoError(err)
+	require.NotNil(r)
+
+	for _, counterName := range []string{ComponentRequestCounter, ComponentErrorCounter} {
+		counter := r.NewCounter(counterName)
+		counter.With(URLLabel, "http://localhost:8080").Add(1.0)
+	}
+
+	histogram := r.NewHistogram(ComponentDurationHistogram, 10)
+	histogram.With(URLLabel, "http://localhost:8080").Observe(0.5)
+
+	gauge := r.NewGauge(ComponentInFlightGauge)
+	gauge.With(URLLabel, "http://localhost:8080").Add(1.0)
+	gauge.With(URLLabel, "http://localhost:8080").Add(-1.0)
+}
+
+func TestNewMeasures(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		m      = NewMeasures(provider.NewDiscardProvider())
+	)
+
+	assert.NotNil(m.Requests)
+	assert.NotNil(m.Errors)
+	assert.NotNil(m.Duration)
+	assert.NotNil(m.InFlight)
+}
@@ -1,6 +1,12 @@
 package fanouthttp
 
-import "github.com/stretchr/testify/mock"
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/stretchr/testify/mock"
+)
 
 type mockReader struct {
 	mock.Mock
@@ -10,3 +16,81 @@ func (m *mockReader) Read(p []byte) (int, error) {
 	arguments := m.Called(p)
 	return arguments.Int(0), arguments.Error(1)
 }
+
+type mockRoundTripper struct {
+	mock.Mock
+}
+
+func (m *mockRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	arguments := m.Called(request)
+	response, _ := arguments.Get(0).(*http.Response)
+	return response, arguments.Error(1)
+}
+
+type mockCounter struct {
+	mock.Mock
+}
+
+func (m *mockCounter) With(labelValues ...string) metrics.Counter {
+	arguments := m.Called(labelValues)
+	return arguments.Get(0).(metrics.Counter)
+}
+
+func (m *mockCounter) Add(delta float64) {
+	m.Called(delta)
+}
+
+type mockGauge struct {
+	mock.Mock
+}
+
+func (m *mockGauge) With(labelValues ...string) metrics.Gauge {
+	arguments := m.Called(labelValues)
+	return arguments.Get(0).(metrics.Gauge)
+}
+
+func (m *mockGauge) Set(value float64) {
+	m.Called(value)
+}
+
+func (m *mockGauge) Add(delta float64) {
+	m.Called(delta)
+}
+
+type mockHistogram struct {
+	mock.Mock
+}
+
+func (m *mockHistogram) With(labelValues ...string) metrics.Histogram {
+	arguments := m.Called(labelValues)
+	return arguments.Get(0).(metrics.Histogram)
+}
+
+func (m *mockHistogram) Observe(value float64) {
+	m.Called(value)
+}
+
+type mockProvider struct {
+	mock.Mock
+}
+
+var _ provider.Provider = (*mockProvider)(nil)
+
+func (m *mockProvider) NewCounter(name string) metrics.Counter {
+	counter, _ := m.Called(name).Get(0).(metrics.Counter)
+	return counter
+}
+
+func (m *mockProvider) NewGauge(name string) metrics.Gauge {
+	gauge, _ := m.Called(name).Get(0).(metrics.Gauge)
+	return gauge
+}
+
+func (m *mockProvider) NewHistogram(name string, buckets int) metrics.Histogram {
+	histogram, _ := m.Called(name, buckets).Get(0).(metrics.Histogram)
+	return histogram
+}
+
+func (m *mockProvider) Stop() {
+	m.Called()
+}
@@ -2,14 +2,18 @@ package fanouthttp
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"time"
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/middleware"
+	"github.com/Comcast/webpa-common/middleware/fanout"
 	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/provider"
+	gokithttp "github.com/go-kit/kit/transport/http"
 )
 
 const (
@@ -25,6 +29,10 @@ type Options struct {
 	// Logger is the go-kit logger to use when creating the service fanout.  If not set, logging.DefaultLogger is used.
 	Logger log.Logger `json:"-"`
 
+	// MetricsProvider, if set, is used to create the per-component Measures with which NewHandler instruments
+	// each component.  If not set, components are not instrumented.
+	MetricsProvider provider.Provider `json:"-"`
+
 	// Endpoints are the URLs for each endpoint to fan out to
 	Endpoints []string `json:"endpoints,omitempty"`
 
@@ -34,6 +42,10 @@ type Options struct {
 	// Transport is the http.Client transport
 	Transport http.Transport `json:"transport"`
 
+	// Connections holds connection pooling and HTTP/2 settings which are applied on top of Transport.  Unlike Transport,
+	// this field is straightforward to populate from configuration files.
+	Connections *TransportOptions `json:"connections,omitempty"`
+
 	// FanoutTimeout is the timeout for the entire fanout operation.  If not supplied, DefaultFanoutTimeout is used.
 	FanoutTimeout time.Duration `json:"timeout"`
 
@@ -53,6 +65,37 @@ type Options struct {
 
 	// RedirectExcludeHeaders are the headers that will *not* be copied on a redirect
 	RedirectExcludeHeaders []string `json:"redirectExcludeHeaders,omitempty"`
+
+	// Retry is the policy for retrying component requests after network errors or 5xx responses.
+	// If not set, component requests are not retried.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// ForwardHeaders is the policy for copying headers from the original request onto each component request.
+	// If not set, no headers are forwarded.
+	ForwardHeaders *HeaderPolicy `json:"forwardHeaders,omitempty"`
+
+	// Status is the policy for mapping fanout errors onto HTTP status codes.  If not set, defaults are used.
+	Status *StatusPolicy `json:"status,omitempty"`
+
+	// Targets are the fanout components which require their own headers, credentials, timeouts, or TLS configuration.
+	// These are in addition to any Endpoints.
+	Targets []TargetOptions `json:"targets,omitempty"`
+
+	// Behavior is the configurable fanout behavior, such as partial success and deadline budgets.  This field
+	// is only used by NewHandler.
+	Behavior *fanout.Options `json:"behavior,omitempty"`
+
+	// CORS is the policy for cross-origin requests.  If set, the handler returned by NewHandler answers
+	// preflight requests and emits CORS headers.
+	CORS *CORSPolicy `json:"cors,omitempty"`
+
+	// MaxRequestBody is the maximum size, in bytes, of an original request's entity.  Larger requests are rejected
+	// with a 413 before any component is invoked.  If not set, entities of any size are allowed.
+	MaxRequestBody int64 `json:"maxRequestBody"`
+
+	// AccessLog indicates whether the handler returned by NewHandler logs each request via AccessLog, using the
+	// configured Logger
+	AccessLog bool `json:"accessLog"`
 }
 
 func (o *Options) logger() log.Logger {
@@ -63,6 +106,14 @@ func (o *Options) logger() log.Logger {
 	return logging.DefaultLogger()
 }
 
+func (o *Options) metricsProvider() provider.Provider {
+	if o != nil {
+		return o.MetricsProvider
+	}
+
+	return nil
+}
+
 func (o *Options) endpoints() []string {
 	if o != nil {
 		return o.Endpoints
@@ -141,6 +192,74 @@ func (o *Options) redirectExcludeHeaders() []string {
 	return nil
 }
 
+func (o *Options) retry() *RetryPolicy {
+	if o != nil {
+		return o.Retry
+	}
+
+	return nil
+}
+
+func (o *Options) forwardHeaders() *HeaderPolicy {
+	if o != nil {
+		return o.ForwardHeaders
+	}
+
+	return nil
+}
+
+func (o *Options) status() *StatusPolicy {
+	if o != nil {
+		return o.Status
+	}
+
+	return nil
+}
+
+func (o *Options) targets() []TargetOptions {
+	if o != nil {
+		return o.Targets
+	}
+
+	return nil
+}
+
+func (o *Options) behavior() *fanout.Options {
+	if o != nil {
+		return o.Behavior
+	}
+
+	return nil
+}
+
+func (o *Options) cors() *CORSPolicy {
+	if o != nil {
+		return o.CORS
+	}
+
+	return nil
+}
+
+func (o *Options) accessLog() bool {
+	return o != nil && o.AccessLog
+}
+
+func (o *Options) connections() *TransportOptions {
+	if o != nil {
+		return o.Connections
+	}
+
+	return nil
+}
+
+func (o *Options) maxRequestBody() int64 {
+	if o != nil && o.MaxRequestBody > 0 {
+		return o.MaxRequestBody
+	}
+
+	return 0
+}
+
 func (o *Options) checkRedirect() func(*http.Request, []*http.Request) error {
 	return xhttp.CheckRedirect(xhttp.RedirectPolicy{
 		Logger:         o.logger(),
@@ -149,15 +268,51 @@ func (o *Options) checkRedirect() func(*http.Request, []*http.Request) error {
 	})
 }
 
-// NewClient returns a distinct HTTP client synthesized from these options
+// NewClient returns a distinct HTTP client synthesized from these options.  If a RetryPolicy is configured,
+// the client's transport retries failed requests.
 func (o *Options) NewClient() *http.Client {
+	return o.NewTLSClient(nil)
+}
+
+// NewTLSClient returns a distinct HTTP client synthesized from these options, using the given TLS configuration
+// in place of the configured transport's.  If config is nil, this method is equivalent to NewClient.  Any Connections
+// settings are applied to the client's transport, and the given config is never modified.
+func (o *Options) NewTLSClient(config *tls.Config) *http.Client {
+	transport := o.transport()
+	if config != nil {
+		transport.TLSClientConfig = config
+	}
+
+	o.connections().apply(transport)
+
 	return &http.Client{
 		CheckRedirect: o.checkRedirect(),
-		Transport:     o.transport(),
+		Transport:     RetryTransport(transport, o.retry()),
 		Timeout:       o.clientTimeout(),
 	}
 }
 
+// ClientOptions returns the go-kit client options for each component, as described by these options.  The returned
+// slice is suitable for passing to NewComponents.
+func (o *Options) ClientOptions() []gokithttp.ClientOption {
+	var options []gokithttp.ClientOption
+	if policy := o.forwardHeaders(); policy != nil {
+		options = append(options, gokithttp.ClientBefore(ForwardHeaders(*policy)))
+	}
+
+	if authorization := o.authorization(); len(authorization) > 0 {
+		options = append(options, gokithttp.ClientBefore(SetAuthorization((&Credentials{Basic: authorization}).Authorization())))
+	}
+
+	return options
+}
+
+// ErrorEncoder returns the go-kit server ErrorEncoder described by these options.  Errors are encoded with
+// AggregateErrorEncoder using the configured StatusPolicy.
+func (o *Options) ErrorEncoder(timeLayout string) gokithttp.ErrorEncoder {
+	return AggregateErrorEncoder(o.status(), timeLayout)
+}
+
 func (o *Options) loggerMiddleware(next endpoint.Endpoint) endpoint.Endpoint {
 	logger := o.logger()
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
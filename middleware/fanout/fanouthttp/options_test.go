@@ -2,6 +2,7 @@ package fanouthttp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net/http"
 	"testing"
@@ -21,6 +22,17 @@ func testOptionsDefaults(t *testing.T, o *Options) {
 	assert.NotNil(o.logger())
 	assert.Empty(o.endpoints())
 	assert.Empty(o.authorization())
+	assert.Nil(o.forwardHeaders())
+	assert.Nil(o.retry())
+	assert.Nil(o.status())
+	assert.Nil(o.targets())
+	assert.Nil(o.behavior())
+	assert.Nil(o.cors())
+	assert.Zero(o.maxRequestBody())
+	assert.Nil(o.connections())
+	assert.False(o.accessLog())
+	assert.NotNil(o.ErrorEncoder(""))
+	assert.Empty(o.ClientOptions())
 
 	transport := o.transport()
 	require.NotNil(transport)
@@ -30,6 +42,12 @@ func testOptionsDefaults(t *testing.T, o *Options) {
 	require.NotNil(client)
 	assert.Equal(*transport, *client.Transport.(*http.Transport))
 
+	tlsConfig := &tls.Config{ServerName: "test.comcast.net"}
+	tlsClient := o.NewTLSClient(tlsConfig)
+	require.NotNil(tlsClient)
+	assert.Equal(tlsConfig, tlsClient.Transport.(*http.Transport).TLSClientConfig)
+	assert.Equal(DefaultClientTimeout, tlsClient.Timeout)
+
 	assert.Equal(DefaultFanoutTimeout, o.fanoutTimeout())
 	assert.Equal(DefaultClientTimeout, o.clientTimeout())
 	assert.Equal(DefaultMaxClients, o.maxClients())
@@ -70,16 +88,27 @@ func testOptionsConfigured(t *testing.T) {
 				IdleConnTimeout:     30 * time.Minute,
 				MaxIdleConnsPerHost: 256,
 			},
-			FanoutTimeout: 500 * time.Second,
-			ClientTimeout: 37 * time.Second,
-			MaxClients:    38734,
-			Concurrency:   3249,
+			FanoutTimeout:  500 * time.Second,
+			ClientTimeout:  37 * time.Second,
+			MaxClients:     38734,
+			Concurrency:    3249,
+			ForwardHeaders: &HeaderPolicy{Allow: []string{"X-Webpa-*"}},
+			Retry:          &RetryPolicy{Retries: 2},
+			Status:         &StatusPolicy{Mixed: http.StatusBadGateway},
+			MaxRequestBody: 1024,
+			AccessLog:      true,
 		}
 	)
 
 	assert.Equal(expectedLogger, o.logger())
 	assert.Equal([]string{"http://host1.com:8080/api", "http://host2.com:9090/api"}, o.endpoints())
 	assert.Equal("QWxhZGRpbjpPcGVuU2VzYW1l", o.authorization())
+	assert.Equal(&HeaderPolicy{Allow: []string{"X-Webpa-*"}}, o.forwardHeaders())
+	assert.Len(o.ClientOptions(), 2)
+	assert.Equal(&StatusPolicy{Mixed: http.StatusBadGateway}, o.status())
+	assert.Equal(int64(1024), o.maxRequestBody())
+	assert.True(o.accessLog())
+	assert.NotNil(o.ErrorEncoder(""))
 
 	transport := o.transport()
 	require.NotNil(transport)
@@ -88,7 +117,9 @@ func testOptionsConfigured(t *testing.T) {
 
 	client := o.NewClient()
 	require.NotNil(client)
-	assert.Equal(*transport, *client.Transport.(*http.Transport))
+	require.IsType(&retryTransport{}, client.Transport)
+	assert.Equal(*transport, *client.Transport.(*retryTransport).next.(*http.Transport))
+	assert.Equal(&RetryPolicy{Retries: 2}, o.retry())
 
 	assert.Equal(500*time.Second, o.fanoutTimeout())
 	assert.Equal(37*time.Second, o.clientTimeout())
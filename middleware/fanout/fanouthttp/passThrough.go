@@ -14,6 +14,10 @@ import (
 
 // PassThrough holds the raw contents of an original fanout request.  This is useful
 // when the fanout doesn't need to do any thing to the original request except pass it on.
+//
+// DecodePassThroughRequest, EncodePassThroughRequest, DecodePassThroughResponse, and EncodePassThroughResponse
+// together treat each entity as opaque bytes plus a content type.  Fanouts that don't need to parse WRP messages
+// can use these to avoid decoding and reencoding each entity.
 type PassThrough struct {
 	// StatusCode is the original status code from an http.Response.  This field doesn't apply to requests,
 	// and is generally set to a negative value for requests.
@@ -25,6 +29,9 @@ type PassThrough struct {
 	// Entity is the optional original entity of the request or response.
 	Entity []byte
 
+	// Header is the original header of an http.Response.  This field doesn't apply to requests, and is nil for requests.
+	Header http.Header
+
 	spans []tracing.Span
 }
 
@@ -38,6 +45,12 @@ func (pt *PassThrough) WithSpans(s ...tracing.Span) interface{} {
 	return &copyOf
 }
 
+// ResponseHeader returns the original header of the component response.  This method allows a *PassThrough
+// to be used with CopyResponseHeaders.
+func (pt *PassThrough) ResponseHeader() http.Header {
+	return pt.Header
+}
+
 // ReadCloser returns a distinct io.ReadCloser which can read the Entity bytes
 func (pt *PassThrough) ReadCloser() io.ReadCloser {
 	return ioutil.NopCloser(bytes.NewReader(pt.Entity))
@@ -49,7 +62,7 @@ func (pt *PassThrough) GetBody() (io.ReadCloser, error) {
 }
 
 // DecodePassThroughRequest is a fanout entity decoder which returns a *PassThrough with the original request's contents.
-// If supplied, the headers contains the set of original headers that are copied as is from the original HTTP request.
+// Use CopyHeaders or ForwardHeaders to pass along any of the original request's headers.
 func DecodePassThroughRequest(_ context.Context, original *http.Request) (interface{}, error) {
 	entity, err := ioutil.ReadAll(original.Body)
 	if err != nil {
@@ -83,6 +96,7 @@ func DecodePassThroughResponse(_ context.Context, component *http.Response) (int
 		StatusCode:  component.StatusCode,
 		ContentType: component.Header.Get("Content-Type"),
 		Entity:      entity,
+		Header:      component.Header,
 	}, nil
 }
 
@@ -182,6 +182,8 @@ func testDecodePassThroughResponseValid(t *testing.T) {
 				assert.Equal(statusCode, pt.StatusCode)
 				assert.Equal(record.contentType, pt.ContentType)
 				assert.Equal(record.body, pt.Entity)
+				assert.Equal(component.Header, pt.Header)
+				assert.Equal(component.Header, pt.ResponseHeader())
 			}
 		}
 	})
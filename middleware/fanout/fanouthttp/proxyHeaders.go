@@ -0,0 +1,39 @@
+package fanouthttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// viaPseudonym is the name this fanout uses to identify itself in Via headers
+const viaPseudonym = "fanout"
+
+// addProxyHeaders adds the standard proxy headers to a component request, so that components can see the
+// true origin of the original request.  X-Forwarded-For and Via are appended to any values supplied by upstream
+// proxies, while an upstream X-Forwarded-Proto is preserved as is.
+func addProxyHeaders(component, original *http.Request) {
+	if host, _, err := net.SplitHostPort(original.RemoteAddr); err == nil && len(host) > 0 {
+		forwardedFor := host
+		if prior := original.Header.Get("X-Forwarded-For"); len(prior) > 0 {
+			forwardedFor = prior + ", " + host
+		}
+
+		component.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	if proto := original.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		component.Header.Set("X-Forwarded-Proto", proto)
+	} else if original.TLS != nil {
+		component.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		component.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	via := fmt.Sprintf("%d.%d %s", original.ProtoMajor, original.ProtoMinor, viaPseudonym)
+	if prior := original.Header.Get("Via"); len(prior) > 0 {
+		via = prior + ", " + via
+	}
+
+	component.Header.Set("Via", via)
+}
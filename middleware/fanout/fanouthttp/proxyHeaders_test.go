@@ -0,0 +1,58 @@
+package fanouthttp
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddProxyHeaders(t *testing.T) {
+	t.Run("Direct", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			original  = httptest.NewRequest("GET", "/foo", nil)
+			component = httptest.NewRequest("GET", "http://component.comcast.net/foo", nil)
+		)
+
+		original.RemoteAddr = "10.1.1.1:5555"
+		addProxyHeaders(component, original)
+		assert.Equal("10.1.1.1", component.Header.Get("X-Forwarded-For"))
+		assert.Equal("http", component.Header.Get("X-Forwarded-Proto"))
+		assert.Equal("1.1 fanout", component.Header.Get("Via"))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			original  = httptest.NewRequest("GET", "/foo", nil)
+			component = httptest.NewRequest("GET", "http://component.comcast.net/foo", nil)
+		)
+
+		original.TLS = new(tls.ConnectionState)
+		original.RemoteAddr = "not a valid address"
+		addProxyHeaders(component, original)
+		assert.Empty(component.Header.Get("X-Forwarded-For"))
+		assert.Equal("https", component.Header.Get("X-Forwarded-Proto"))
+	})
+
+	t.Run("Upstream", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			original  = httptest.NewRequest("GET", "/foo", nil)
+			component = httptest.NewRequest("GET", "http://component.comcast.net/foo", nil)
+		)
+
+		original.RemoteAddr = "10.1.1.1:5555"
+		original.ProtoMajor, original.ProtoMinor = 2, 0
+		original.Header.Set("X-Forwarded-For", "203.0.113.7")
+		original.Header.Set("X-Forwarded-Proto", "https")
+		original.Header.Set("Via", "1.1 edge")
+
+		addProxyHeaders(component, original)
+		assert.Equal("203.0.113.7, 10.1.1.1", component.Header.Get("X-Forwarded-For"))
+		assert.Equal("https", component.Header.Get("X-Forwarded-Proto"))
+		assert.Equal("1.1 edge, 2.0 fanout", component.Header.Get("Via"))
+	})
+}
@@ -0,0 +1,42 @@
+package fanouthttp
+
+import (
+	"context"
+	"net/http"
+
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// ResponseHeaderer is implemented by component responses which retain the header of the component's HTTP response.
+// *PassThrough implements this interface.
+type ResponseHeaderer interface {
+	// ResponseHeader returns the header of the component's HTTP response
+	ResponseHeader() http.Header
+}
+
+// CopyResponseHeaders decorates a fanout EncodeResponseFunc so that selected headers from the successful component's
+// response are copied onto the fanout's response.  Each header is either a header name, e.g. "Content-Type", or a
+// prefix ending with "*", e.g. "X-Webpa-*".  Headers are copied before the decorated encoder is invoked, so the encoder
+// may still override them.  Hop-by-hop headers, as defined by RFC 7230, are never copied.
+//
+// Headers are only copied when the fanout response implements ResponseHeaderer.  If enc is nil, this function panics.
+func CopyResponseHeaders(enc gokithttp.EncodeResponseFunc, headers ...string) gokithttp.EncodeResponseFunc {
+	if enc == nil {
+		panic("The response encoder cannot be nil")
+	}
+
+	matcher := newHeaderMatcher(headers)
+	return func(ctx context.Context, original http.ResponseWriter, v interface{}) error {
+		if rh, ok := v.(ResponseHeaderer); ok {
+			header := rh.ResponseHeader()
+			isHopByHop := hopByHop(header)
+			for name, values := range header {
+				if matcher.matches(name) && !isHopByHop(name) {
+					original.Header()[name] = values
+				}
+			}
+		}
+
+		return enc(ctx, original, v)
+	}
+}
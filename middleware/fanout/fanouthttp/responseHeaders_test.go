@@ -0,0 +1,81 @@
+package fanouthttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyResponseHeaders(t *testing.T) {
+	t.Run("NilEncoder", func(t *testing.T) {
+		assert.Panics(t, func() {
+			CopyResponseHeaders(nil)
+		})
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+			response      = &PassThrough{
+				StatusCode: 200,
+				Header: http.Header{
+					"Content-Type":          []string{"application/json"},
+					"Cache-Control":         []string{"no-cache"},
+					"X-Webpa-Transaction":   []string{"1234"},
+					"X-Webpa-Device-Name":   []string{"mac:112233445566"},
+					"X-Not-Copied":          []string{"true"},
+					"X-Webpa-Other-Headers": []string{"a", "b"},
+					"Connection":            []string{"X-Webpa-Hop"},
+					"X-Webpa-Hop":           []string{"hop"},
+					"Transfer-Encoding":     []string{"chunked"},
+				},
+			}
+
+			original = httptest.NewRecorder()
+			encoder  = CopyResponseHeaders(
+				func(_ context.Context, actual http.ResponseWriter, v interface{}) error {
+					assert.Equal(original, actual)
+					assert.Equal(response, v)
+					assert.Equal("application/json", actual.Header().Get("Content-Type"))
+					return expectedError
+				},
+				"content-type", "Cache-Control", "X-Webpa-*", "Transfer-Encoding", "Connection",
+			)
+		)
+
+		assert.Equal(expectedError, encoder(context.Background(), original, response))
+		assert.Equal("application/json", original.Header().Get("Content-Type"))
+		assert.Equal("no-cache", original.Header().Get("Cache-Control"))
+		assert.Equal("1234", original.Header().Get("X-Webpa-Transaction"))
+		assert.Equal("mac:112233445566", original.Header().Get("X-Webpa-Device-Name"))
+		assert.Equal([]string{"a", "b"}, original.Header()["X-Webpa-Other-Headers"])
+		assert.Empty(original.Header().Get("X-Not-Copied"))
+		assert.Empty(original.Header().Get("X-Webpa-Hop"))
+		assert.Empty(original.Header().Get("Transfer-Encoding"))
+		assert.Empty(original.Header().Get("Connection"))
+	})
+
+	t.Run("NotHeaderer", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			original = httptest.NewRecorder()
+			called   = false
+			encoder  = CopyResponseHeaders(
+				func(context.Context, http.ResponseWriter, interface{}) error {
+					called = true
+					return nil
+				},
+				"Content-Type",
+			)
+		)
+
+		assert.NoError(encoder(context.Background(), original, "not a headerer"))
+		assert.True(called)
+		assert.Empty(original.Header())
+	})
+}
@@ -0,0 +1,176 @@
+package fanouthttp
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRetryInterval    time.Duration = 100 * time.Millisecond
+	DefaultRetryMaxInterval time.Duration = 5 * time.Second
+)
+
+// IdempotencyKeyHeader is the request header which marks a request as safe to repeat, whatever its method
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy describes how component requests are retried after network errors or 5xx responses.  The interval
+// between attempts doubles after each retry, up to MaxInterval.
+//
+// Only idempotent requests are retried:  GET, HEAD, OPTIONS, PUT, and DELETE requests, and requests of any method
+// which carry an Idempotency-Key header.  A POST which succeeded downstream but returned a 5xx would otherwise be
+// duplicated.  Other methods must be opted in through Methods.
+type RetryPolicy struct {
+	// Retries is the maximum number of retries after the initial attempt.  If nonpositive, no retries are made.
+	Retries int `json:"retries"`
+
+	// Interval is the time to wait before the first retry.  If not set, DefaultRetryInterval is used.
+	Interval time.Duration `json:"interval"`
+
+	// MaxInterval is the maximum time to wait between retries.  If not set, DefaultRetryMaxInterval is used.
+	MaxInterval time.Duration `json:"maxInterval"`
+
+	// Methods lists additional HTTP methods, e.g. POST, which are retried even without an Idempotency-Key header.
+	// Only list methods whose components tolerate duplicate requests.
+	Methods []string `json:"methods,omitempty"`
+}
+
+func (rp *RetryPolicy) retries() int {
+	if rp != nil && rp.Retries > 0 {
+		return rp.Retries
+	}
+
+	return 0
+}
+
+func (rp *RetryPolicy) interval() time.Duration {
+	if rp != nil && rp.Interval > 0 {
+		return rp.Interval
+	}
+
+	return DefaultRetryInterval
+}
+
+func (rp *RetryPolicy) maxInterval() time.Duration {
+	if rp != nil && rp.MaxInterval > 0 {
+		return rp.MaxInterval
+	}
+
+	return DefaultRetryMaxInterval
+}
+
+// idempotentMethods are the methods retried regardless of policy
+var idempotentMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+
+func (rp *RetryPolicy) methods() map[string]bool {
+	methods := make(map[string]bool, len(idempotentMethods))
+	for _, m := range idempotentMethods {
+		methods[m] = true
+	}
+
+	if rp != nil {
+		for _, m := range rp.Methods {
+			methods[strings.ToUpper(m)] = true
+		}
+	}
+
+	return methods
+}
+
+// retryTransport is an http.RoundTripper decorator that retries requests
+type retryTransport struct {
+	next        http.RoundTripper
+	retries     int
+	interval    time.Duration
+	maxInterval time.Duration
+	methods     map[string]bool
+}
+
+// RetryTransport decorates an http.RoundTripper so that idempotent requests which fail with a network error or a
+// 5xx status are retried as described by the given policy.  A request with a body is only retried if its GetBody field is set,
+// as it is when EncodePassThroughRequest is used.  Retries stop as soon as the request's context is canceled.
+//
+// If the policy allows no retries, next is returned undecorated.  If next is nil, http.DefaultTransport is used.
+func RetryTransport(next http.RoundTripper, policy *RetryPolicy) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if policy.retries() < 1 {
+		return next
+	}
+
+	return &retryTransport{
+		next:        next,
+		retries:     policy.retries(),
+		interval:    policy.interval(),
+		maxInterval: policy.maxInterval(),
+		methods:     policy.methods(),
+	}
+}
+
+// shouldRetry tests if the outcome of an attempt warrants a retry
+func shouldRetry(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= 500
+}
+
+// idempotent tests if a request may safely be sent more than once
+func (rt *retryTransport) idempotent(request *http.Request) bool {
+	method := request.Method
+	if len(method) == 0 {
+		method = "GET"
+	}
+
+	return rt.methods[method] || len(request.Header.Get(IdempotencyKeyHeader)) > 0
+}
+
+// replayable tests if a request's body, if any, can be sent again
+func replayable(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+func (rt *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var (
+		response *http.Response
+		err      error
+		interval = rt.interval
+	)
+
+	for attempt := 0; ; attempt++ {
+		response, err = rt.next.RoundTrip(request)
+		if attempt >= rt.retries || !shouldRetry(response, err) || !rt.idempotent(request) || !replayable(request) {
+			return response, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return response, err
+		case <-timer.C:
+		}
+
+		if response != nil {
+			// discard the failed response so that its connection may be reused
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		if request.GetBody != nil {
+			body, bodyErr := request.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+
+			copyOf := *request
+			copyOf.Body = body
+			request = &copyOf
+		}
+
+		if interval *= 2; interval > rt.maxInterval {
+			interval = rt.maxInterval
+		}
+	}
+}
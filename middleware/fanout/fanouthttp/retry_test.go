@@ -0,0 +1,222 @@
+package fanouthttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponse(statusCode int) *http.Response {
+	return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(new(bytes.Buffer))}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, rp := range []*RetryPolicy{nil, new(RetryPolicy)} {
+		assert.Zero(rp.retries())
+		assert.Equal(DefaultRetryInterval, rp.interval())
+		assert.Equal(DefaultRetryMaxInterval, rp.maxInterval())
+	}
+
+	rp := &RetryPolicy{Retries: 3, Interval: time.Second, MaxInterval: time.Minute}
+	assert.Equal(3, rp.retries())
+	assert.Equal(time.Second, rp.interval())
+	assert.Equal(time.Minute, rp.maxInterval())
+
+	assert.Equal(
+		map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true, "PUT": true, "DELETE": true},
+		(*RetryPolicy)(nil).methods(),
+	)
+
+	rp.Methods = []string{"post", "PATCH"}
+	assert.Equal(
+		map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true, "PUT": true, "DELETE": true, "POST": true, "PATCH": true},
+		rp.methods(),
+	)
+}
+
+func testRetryTransportNoRetries(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = new(mockRoundTripper)
+	)
+
+	assert.Equal(next, RetryTransport(next, nil))
+	assert.Equal(next, RetryTransport(next, &RetryPolicy{Retries: -1}))
+	assert.Equal(http.DefaultTransport, RetryTransport(nil, nil))
+	assert.IsType(&retryTransport{}, RetryTransport(nil, &RetryPolicy{Retries: 1}))
+}
+
+func testRetryTransportNetworkError(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		next      = new(mockRoundTripper)
+		transport = RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond})
+		request   = httptest.NewRequest("GET", "http://localhost/test", nil)
+		expected  = newTestResponse(200)
+	)
+
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(nil, errors.New("expected")).Twice()
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+
+	response, err := transport.RoundTrip(request)
+	assert.Equal(expected, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+}
+
+func testRetryTransportExhausted(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		next      = new(mockRoundTripper)
+		transport = RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond, MaxInterval: time.Millisecond})
+		request   = httptest.NewRequest("GET", "http://localhost/test", nil)
+		last      = newTestResponse(503)
+	)
+
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(newTestResponse(500), nil).Twice()
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(last, nil).Once()
+
+	response, err := transport.RoundTrip(request)
+	assert.Equal(last, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+}
+
+func testRetryTransportNoRetry(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		next      = new(mockRoundTripper)
+		transport = RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond})
+		expected  = newTestResponse(404)
+	)
+
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+	response, err := transport.RoundTrip(httptest.NewRequest("GET", "http://localhost/test", nil))
+	assert.Equal(expected, response)
+	assert.NoError(err)
+
+	// a body that cannot be replayed prevents retries
+	expected = newTestResponse(500)
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+	request := httptest.NewRequest("PUT", "http://localhost/test", bytes.NewBufferString("body"))
+	request.GetBody = nil
+	response, err = transport.RoundTrip(request)
+	assert.Equal(expected, response)
+	assert.NoError(err)
+
+	next.AssertExpectations(t)
+}
+
+func testRetryTransportGetBody(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		next      = new(mockRoundTripper)
+		transport = RetryTransport(next, &RetryPolicy{Retries: 1, Interval: time.Millisecond})
+		pt        = &PassThrough{Entity: []byte("body")}
+		request   = httptest.NewRequest("PUT", "http://localhost/test", nil)
+		bodies    []string
+	)
+
+	request.Body = pt.ReadCloser()
+	request.GetBody = pt.GetBody
+
+	readBody := func(arguments mock.Arguments) {
+		body, err := ioutil.ReadAll(arguments.Get(0).(*http.Request).Body)
+		require.NoError(err)
+		bodies = append(bodies, string(body))
+	}
+
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(newTestResponse(502), nil).Once().Run(readBody)
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(newTestResponse(200), nil).Once().Run(readBody)
+
+	response, err := transport.RoundTrip(request)
+	require.NotNil(response)
+	assert.Equal(200, response.StatusCode)
+	assert.NoError(err)
+	assert.Equal([]string{"body", "body"}, bodies)
+	next.AssertExpectations(t)
+}
+
+func testRetryTransportNonIdempotent(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = new(mockRoundTripper)
+		pt     = &PassThrough{Entity: []byte("body")}
+	)
+
+	newRequest := func(method string) *http.Request {
+		request := httptest.NewRequest(method, "http://localhost/test", nil)
+		request.Body = pt.ReadCloser()
+		request.GetBody = pt.GetBody
+		return request
+	}
+
+	// by default, a replayable POST is not retried
+	expected := newTestResponse(500)
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+	response, err := RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond}).RoundTrip(newRequest("POST"))
+	assert.Equal(expected, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+
+	// an Idempotency-Key header allows any method to be retried
+	next = new(mockRoundTripper)
+	expected = newTestResponse(200)
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(newTestResponse(500), nil).Once()
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+	request := newRequest("PATCH")
+	request.Header.Set(IdempotencyKeyHeader, "1234")
+	response, err = RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond}).RoundTrip(request)
+	assert.Equal(expected, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+
+	// methods can be opted in by the policy
+	next = new(mockRoundTripper)
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(nil, errors.New("expected")).Once()
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once()
+	response, err = RetryTransport(next, &RetryPolicy{Retries: 2, Interval: time.Millisecond, Methods: []string{"post"}}).RoundTrip(newRequest("POST"))
+	assert.Equal(expected, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+}
+
+func testRetryTransportCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		next        = new(mockRoundTripper)
+		transport   = RetryTransport(next, &RetryPolicy{Retries: 5, Interval: time.Hour})
+		ctx, cancel = context.WithCancel(context.Background())
+		request     = httptest.NewRequest("GET", "http://localhost/test", nil).WithContext(ctx)
+		expected    = newTestResponse(500)
+	)
+
+	next.On("RoundTrip", mock.AnythingOfType("*http.Request")).Return(expected, nil).Once().Run(func(mock.Arguments) { cancel() })
+
+	response, err := transport.RoundTrip(request)
+	assert.Equal(expected, response)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("NoRetries", testRetryTransportNoRetries)
+	t.Run("NetworkError", testRetryTransportNetworkError)
+	t.Run("Exhausted", testRetryTransportExhausted)
+	t.Run("NoRetry", testRetryTransportNoRetry)
+	t.Run("GetBody", testRetryTransportGetBody)
+	t.Run("NonIdempotent", testRetryTransportNonIdempotent)
+	t.Run("Canceled", testRetryTransportCanceled)
+}
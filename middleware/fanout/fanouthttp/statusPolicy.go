@@ -0,0 +1,177 @@
+package fanouthttp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/Comcast/webpa-common/tracing"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+const (
+	DefaultTimeoutStatus    = http.StatusGatewayTimeout
+	DefaultConnectionStatus = http.StatusBadGateway
+)
+
+// failureKind categorizes the error returned by a fanout component
+type failureKind int
+
+const (
+	failureOther failureKind = iota
+	failureTimeout
+	failureConnection
+)
+
+// classifyError determines the failureKind of a component error.  Context errors and network timeouts are timeouts,
+// while any other network error is a connection error.
+func classifyError(err error) failureKind {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return failureTimeout
+	}
+
+	switch v := err.(type) {
+	case tracing.SpanError:
+		return classifyError(v.Err())
+
+	case *url.Error:
+		return classifyError(v.Err)
+
+	case net.Error:
+		if v.Timeout() {
+			return failureTimeout
+		}
+
+		return failureConnection
+	}
+
+	return failureOther
+}
+
+// StatusPolicy describes how a fanout error is mapped onto an HTTP status code, based on how each component failed.
+type StatusPolicy struct {
+	// Timeout is the status used when every failed component timed out.  If not set, DefaultTimeoutStatus is used.
+	Timeout int `json:"timeout"`
+
+	// Connection is the status used when every failed component could not be reached due to a network error.
+	// If not set, DefaultConnectionStatus is used.
+	Connection int `json:"connection"`
+
+	// Mixed is the status used when components failed in different ways.  If not set, StatusCodeForError is used.
+	Mixed int `json:"mixed"`
+}
+
+func (sp *StatusPolicy) timeout() int {
+	if sp != nil && sp.Timeout > 0 {
+		return sp.Timeout
+	}
+
+	return DefaultTimeoutStatus
+}
+
+func (sp *StatusPolicy) connection() int {
+	if sp != nil && sp.Connection > 0 {
+		return sp.Connection
+	}
+
+	return DefaultConnectionStatus
+}
+
+// StatusCode determines the HTTP status code for a fanout error.  If err is a tracing.SpanError, the errors of the
+// component spans are examined.  If the fanout itself timed out, or if every failed component either timed out or
+// could not be reached, the corresponding status from this policy is returned.  If components failed in different ways,
+// the Mixed status is returned.  Otherwise, StatusCodeForError is used.
+//
+// This method may be invoked on a nil StatusPolicy, in which case defaults are used.
+func (sp *StatusPolicy) StatusCode(err error) int {
+	kind := classifyError(err)
+	if spanError, ok := err.(tracing.SpanError); ok && kind != failureTimeout {
+		var (
+			failures = 0
+			kinds    = make(map[failureKind]bool, 3)
+		)
+
+		for _, s := range spanError.Spans() {
+			if e := s.Error(); e != nil {
+				failures++
+				kinds[classifyError(e)] = true
+			}
+		}
+
+		switch {
+		case len(kinds) > 1:
+			if sp != nil && sp.Mixed > 0 {
+				return sp.Mixed
+			}
+
+			return StatusCodeForError(err)
+
+		case len(kinds) == 1:
+			for k := range kinds {
+				kind = k
+			}
+		}
+	}
+
+	switch kind {
+	case failureTimeout:
+		return sp.timeout()
+
+	case failureConnection:
+		return sp.connection()
+
+	default:
+		return StatusCodeForError(err)
+	}
+}
+
+// componentOutcome is the JSON representation of a single component's result within an error response
+type componentOutcome struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// errorResponse is the JSON body written by AggregateErrorEncoder
+type errorResponse struct {
+	Code       int                `json:"code"`
+	Error      string             `json:"error,omitempty"`
+	Components []componentOutcome `json:"components,omitempty"`
+}
+
+// AggregateErrorEncoder produces a go-kit ErrorEncoder which uses the given StatusPolicy to determine the response
+// status code.  Headers are emitted using HeadersForError, and the response body is a JSON object holding the status code,
+// the error text, and the outcome of each component span.  The policy may be nil, in which case defaults are used.
+func AggregateErrorEncoder(policy *StatusPolicy, timeLayout string) gokithttp.ErrorEncoder {
+	return func(ctx context.Context, err error, response http.ResponseWriter) {
+		body := errorResponse{
+			Code: policy.StatusCode(err),
+		}
+
+		if err != nil {
+			body.Error = err.Error()
+		}
+
+		if spanError, ok := err.(tracing.SpanError); ok {
+			for _, s := range spanError.Spans() {
+				outcome := componentOutcome{
+					Name:     s.Name(),
+					Duration: s.Duration().String(),
+				}
+
+				if e := s.Error(); e != nil {
+					outcome.Error = e.Error()
+				}
+
+				body.Components = append(body.Components, outcome)
+			}
+		}
+
+		HeadersForError(err, timeLayout, response.Header())
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(body.Code)
+		json.NewEncoder(response).Encode(body)
+	}
+}
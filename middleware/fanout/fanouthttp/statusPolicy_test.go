@@ -0,0 +1,173 @@
+package fanouthttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNetError is a net.Error with a configurable timeout flag
+type testNetError struct {
+	timeout bool
+}
+
+func (e testNetError) Error() string   { return "network error" }
+func (e testNetError) Timeout() bool   { return e.timeout }
+func (e testNetError) Temporary() bool { return false }
+
+func newSpan(spanner tracing.Spanner, name string, err error) tracing.Span {
+	return spanner.Start(name)(err)
+}
+
+func testStatusPolicyStatusCode(t *testing.T, policy *StatusPolicy, expectedTimeout, expectedConnection int, expectedMixed func(error) int) {
+	var (
+		assert  = assert.New(t)
+		spanner = tracing.NewSpanner()
+
+		timeoutError    = &url.Error{Op: "Post", URL: "http://localhost", Err: testNetError{timeout: true}}
+		connectionError = &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+		statusError     = &xhttp.Error{Code: http.StatusNotFound}
+	)
+
+	assert.Equal(expectedTimeout, policy.StatusCode(context.DeadlineExceeded))
+	assert.Equal(expectedTimeout, policy.StatusCode(timeoutError))
+	assert.Equal(expectedConnection, policy.StatusCode(connectionError))
+	assert.Equal(http.StatusInternalServerError, policy.StatusCode(errors.New("random error")))
+	assert.Equal(http.StatusNotFound, policy.StatusCode(statusError))
+
+	assert.Equal(
+		expectedTimeout,
+		policy.StatusCode(tracing.NewSpanError(context.DeadlineExceeded, newSpan(spanner, "one", connectionError))),
+	)
+
+	assert.Equal(
+		expectedTimeout,
+		policy.StatusCode(
+			tracing.NewSpanError(
+				timeoutError,
+				newSpan(spanner, "one", timeoutError),
+				newSpan(spanner, "two", context.DeadlineExceeded),
+			),
+		),
+	)
+
+	assert.Equal(
+		expectedConnection,
+		policy.StatusCode(
+			tracing.NewSpanError(
+				connectionError,
+				newSpan(spanner, "one", connectionError),
+				newSpan(spanner, "two", connectionError),
+			),
+		),
+	)
+
+	assert.Equal(
+		http.StatusNotFound,
+		policy.StatusCode(
+			tracing.NewSpanError(
+				statusError,
+				newSpan(spanner, "one", statusError),
+				newSpan(spanner, "two", nil),
+			),
+		),
+	)
+
+	mixed := tracing.NewSpanError(
+		statusError,
+		newSpan(spanner, "one", timeoutError),
+		newSpan(spanner, "two", connectionError),
+		newSpan(spanner, "three", statusError),
+	)
+
+	assert.Equal(expectedMixed(mixed), policy.StatusCode(mixed))
+}
+
+func testAggregateErrorEncoder(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		spanner = tracing.NewSpanner()
+
+		connectionError = &url.Error{Op: "Post", URL: "http://host1.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+		err             = tracing.NewSpanError(
+			connectionError,
+			newSpan(spanner, "http://host1.com", connectionError),
+			newSpan(spanner, "http://host2.com", context.DeadlineExceeded),
+		)
+
+		response = httptest.NewRecorder()
+	)
+
+	AggregateErrorEncoder(&StatusPolicy{Mixed: 599}, "")(context.Background(), err, response)
+	assert.Equal(599, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+
+	var body errorResponse
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	assert.Equal(599, body.Code)
+	assert.Equal(connectionError.Error(), body.Error)
+	require.Len(body.Components, 2)
+
+	outcomes := make(map[string]componentOutcome, len(body.Components))
+	for _, o := range body.Components {
+		_, parseErr := time.ParseDuration(o.Duration)
+		assert.NoError(parseErr)
+		outcomes[o.Name] = o
+	}
+
+	assert.Equal(connectionError.Error(), outcomes["http://host1.com"].Error)
+	assert.Equal(context.DeadlineExceeded.Error(), outcomes["http://host2.com"].Error)
+}
+
+func testAggregateErrorEncoderSimpleError(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		response = httptest.NewRecorder()
+	)
+
+	AggregateErrorEncoder(nil, "")(context.Background(), &xhttp.Error{Code: 403, Header: http.Header{"Foo": []string{"Bar"}}}, response)
+	assert.Equal(403, response.Code)
+	assert.Equal("Bar", response.HeaderMap.Get("Foo"))
+
+	var body errorResponse
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	assert.Equal(403, body.Code)
+	assert.Empty(body.Components)
+}
+
+func TestStatusPolicy(t *testing.T) {
+	t.Run("StatusCode", func(t *testing.T) {
+		t.Run("Defaults", func(t *testing.T) {
+			testStatusPolicyStatusCode(t, nil, DefaultTimeoutStatus, DefaultConnectionStatus, StatusCodeForError)
+			testStatusPolicyStatusCode(t, new(StatusPolicy), DefaultTimeoutStatus, DefaultConnectionStatus, StatusCodeForError)
+		})
+
+		t.Run("Configured", func(t *testing.T) {
+			testStatusPolicyStatusCode(
+				t,
+				&StatusPolicy{Timeout: 598, Connection: 597, Mixed: 596},
+				598,
+				597,
+				func(error) int { return 596 },
+			)
+		})
+	})
+}
+
+func TestAggregateErrorEncoder(t *testing.T) {
+	t.Run("SpanError", testAggregateErrorEncoder)
+	t.Run("SimpleError", testAggregateErrorEncoderSimpleError)
+}
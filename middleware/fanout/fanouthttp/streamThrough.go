@@ -0,0 +1,275 @@
+package fanouthttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/xhttp"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+var (
+	// ErrStreamConsumed is returned when more than one component attempts to send the same streamed request body
+	ErrStreamConsumed = errors.New("The request body stream has already been consumed")
+
+	// ErrTooManyStreamComponents is returned by NewStreamComponents when given more than one URL
+	ErrTooManyStreamComponents = errors.New("A streaming fanout can only have one component")
+)
+
+// StreamThrough is the streaming analog of PassThrough.  Rather than buffering entities, a StreamThrough holds
+// the unread body of the original request or the component's response.  This allows large payloads to pass through
+// a fanout without double-buffering.
+//
+// Because a body can only be read once, a StreamThrough request is only appropriate for a fanout with exactly one
+// component.  NewStreamComponents enforces this, and supplies the client options a StreamThrough response requires.
+type StreamThrough struct {
+	// StatusCode is the original status code from an http.Response.  This field doesn't apply to requests,
+	// and is generally set to a negative value for requests.
+	StatusCode int
+
+	// ContentType is the original content type of the request or response entity
+	ContentType string
+
+	// ContentLength is the original length of the entity, or -1 if unknown
+	ContentLength int64
+
+	// Header is the original header of an http.Response.  This field doesn't apply to requests, and is nil for requests.
+	Header http.Header
+
+	// Body is the unread entity
+	Body io.ReadCloser
+
+	consumed uint32
+	spans    []tracing.Span
+}
+
+func (st *StreamThrough) Spans() []tracing.Span {
+	return st.spans
+}
+
+func (st *StreamThrough) WithSpans(s ...tracing.Span) interface{} {
+	return &StreamThrough{
+		StatusCode:    st.StatusCode,
+		ContentType:   st.ContentType,
+		ContentLength: st.ContentLength,
+		Header:        st.Header,
+		Body:          st.Body,
+		consumed:      atomic.LoadUint32(&st.consumed),
+		spans:         s,
+	}
+}
+
+// ResponseHeader returns the original header of the component response.  This method allows a *StreamThrough
+// to be used with CopyResponseHeaders.
+func (st *StreamThrough) ResponseHeader() http.Header {
+	return st.Header
+}
+
+// claim returns the Body for the first caller, and ErrStreamConsumed for every subsequent caller
+func (st *StreamThrough) claim() (io.ReadCloser, error) {
+	if atomic.CompareAndSwapUint32(&st.consumed, 0, 1) {
+		return st.Body, nil
+	}
+
+	return nil, ErrStreamConsumed
+}
+
+// streamContextKey is the context key under which a streamContext refers to itself
+type streamContextKey struct{}
+
+// streamContext carries the values of another context, and follows that context's cancellation only until detach
+// is called.  This allows a component request to be abandoned while the component has yet to respond, but lets its
+// response body be read after the fanout has returned.
+type streamContext struct {
+	context.Context
+
+	done     chan struct{}
+	detached chan struct{}
+	once     sync.Once
+
+	// err is only written before done is closed
+	err error
+}
+
+func newStreamContext(parent context.Context) *streamContext {
+	sc := &streamContext{
+		Context:  parent,
+		done:     make(chan struct{}),
+		detached: make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-parent.Done():
+			// a detach which happened before the parent was canceled takes precedence
+			select {
+			case <-sc.detached:
+			default:
+				sc.err = parent.Err()
+				close(sc.done)
+			}
+
+		case <-sc.detached:
+		}
+	}()
+
+	return sc
+}
+
+func (sc *streamContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (sc *streamContext) Done() <-chan struct{}       { return sc.done }
+
+func (sc *streamContext) Err() error {
+	select {
+	case <-sc.done:
+		return sc.err
+	default:
+		return nil
+	}
+}
+
+func (sc *streamContext) Value(key interface{}) interface{} {
+	if key == (streamContextKey{}) {
+		return sc
+	}
+
+	return sc.Context.Value(key)
+}
+
+// detach stops this context from following the cancellation of its parent.  This method is idempotent.
+func (sc *streamContext) detach() {
+	sc.once.Do(func() { close(sc.detached) })
+}
+
+// attachStream is a component client RequestFunc which makes a component request cancelable by the fanout only until
+// the component responds.  See detachStream.
+func attachStream(ctx context.Context, _ *http.Request) context.Context {
+	return newStreamContext(ctx)
+}
+
+// detachStream is a component client ResponseFunc which detaches a component request from the cancellation of the fanout
+// once the component has responded, so that a streamed response body can still be read after the fanout endpoint returns
+func detachStream(ctx context.Context, _ *http.Response) context.Context {
+	if sc, ok := ctx.Value(streamContextKey{}).(*streamContext); ok {
+		sc.detach()
+	}
+
+	return ctx
+}
+
+// StreamClientOptions returns the go-kit client options required for components that use DecodeStreamResponse.  These options
+// leave the component response body open, and detach a component request from the fanout's cancellation once the component
+// has responded, so that the body can be read when the fanout response is encoded.  Until then, the component request is
+// canceled with the fanout, so a component that has not answered by the time the fanout ends is abandoned rather than left
+// holding a connection.  The HTTP client's own timeout, if any, still applies to the entire exchange.
+func StreamClientOptions() []gokithttp.ClientOption {
+	return []gokithttp.ClientOption{
+		gokithttp.BufferedStream(true),
+		gokithttp.ClientBefore(attachStream),
+		gokithttp.ClientAfter(detachStream),
+	}
+}
+
+// NewStreamComponents is like NewComponents, except that the component streams both the original request body and its
+// own response body using EncodeStreamRequest, DecodeStreamResponse, and StreamClientOptions.  The options are applied after
+// StreamClientOptions.  Since a request body can only be sent once, more than one URL results in ErrTooManyStreamComponents
+// rather than a fanout in which all but one component fails with ErrStreamConsumed.
+func NewStreamComponents(urls []string, options ...gokithttp.ClientOption) (fanout.Components, error) {
+	if len(urls) > 1 {
+		return nil, ErrTooManyStreamComponents
+	}
+
+	return NewComponents(urls, EncodeStreamRequest, DecodeStreamResponse, append(StreamClientOptions(), options...)...)
+}
+
+// DecodeStreamRequest is a fanout entity decoder which returns a *StreamThrough holding the original request's unread body
+func DecodeStreamRequest(_ context.Context, original *http.Request) (interface{}, error) {
+	return &StreamThrough{
+		StatusCode:    -1,
+		ContentType:   original.Header.Get("Content-Type"),
+		ContentLength: original.ContentLength,
+		Body:          original.Body,
+	}, nil
+}
+
+// EncodeStreamRequest is a component entity encoder that assumes a *StreamThrough is passed as the value, and sends its
+// body as is to the component.  Only the first component to be encoded receives the body.  Any other component fails with
+// ErrStreamConsumed, so components using this encoder should be created with NewStreamComponents.
+func EncodeStreamRequest(_ context.Context, component *http.Request, v interface{}) error {
+	st := v.(*StreamThrough)
+	body, err := st.claim()
+	if err != nil {
+		return err
+	}
+
+	component.Body = body
+	component.ContentLength = st.ContentLength
+	if len(st.ContentType) > 0 {
+		component.Header.Set("Content-Type", st.ContentType)
+	}
+
+	return nil
+}
+
+// DecodeStreamResponse is a component response entity decoder that returns a *StreamThrough holding the component's unread
+// response body.  The component must be created with StreamClientOptions.  Error responses are read fully and closed, and
+// returned as an *xhttp.Error just as with DecodePassThroughResponse.
+func DecodeStreamResponse(_ context.Context, component *http.Response) (interface{}, error) {
+	if component.StatusCode > 399 {
+		entity, err := ioutil.ReadAll(component.Body)
+		component.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, &xhttp.Error{
+			Code:   component.StatusCode,
+			Text:   fmt.Sprintf("HTTP transaction failed with code: %d", component.StatusCode),
+			Entity: entity,
+		}
+	}
+
+	return &StreamThrough{
+		StatusCode:    component.StatusCode,
+		ContentType:   component.Header.Get("Content-Type"),
+		ContentLength: component.ContentLength,
+		Header:        component.Header,
+		Body:          component.Body,
+	}, nil
+}
+
+// EncodeStreamResponse is a fanout entity encoder that copies the body of a *StreamThrough from a component response
+// to the fanout's original response, then closes that body.
+func EncodeStreamResponse(_ context.Context, original http.ResponseWriter, v interface{}) error {
+	st := v.(*StreamThrough)
+	body, err := st.claim()
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+	if len(st.ContentType) > 0 {
+		original.Header().Set("Content-Type", st.ContentType)
+	}
+
+	if st.ContentLength >= 0 {
+		original.Header().Set("Content-Length", strconv.FormatInt(st.ContentLength, 10))
+	}
+
+	if st.StatusCode > 0 {
+		original.WriteHeader(st.StatusCode)
+	}
+
+	_, err = io.Copy(original, body)
+	return err
+}
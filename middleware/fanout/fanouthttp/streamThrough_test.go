@@ -0,0 +1,301 @@
+package fanouthttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/xhttp"
+	gokithttp "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamThrough(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		spans   = []tracing.Span{tracing.NewSpanner().Start("test")(nil)}
+		st      = &StreamThrough{StatusCode: 200, Header: http.Header{"X-Test": []string{"true"}}, Body: ioutil.NopCloser(new(bytes.Buffer))}
+	)
+
+	assert.Empty(st.Spans())
+	assert.Equal(st.Header, st.ResponseHeader())
+
+	copyOf, ok := st.WithSpans(spans...).(*StreamThrough)
+	require.True(ok)
+	assert.Equal(spans, copyOf.Spans())
+	assert.Equal(st.Body, copyOf.Body)
+	assert.Empty(st.Spans())
+
+	body, err := st.claim()
+	assert.Equal(st.Body, body)
+	assert.NoError(err)
+
+	body, err = st.claim()
+	assert.Nil(body)
+	assert.Equal(ErrStreamConsumed, err)
+}
+
+func testStreamClientOptionsDetached(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		ctx, cancel = context.WithTimeout(context.WithValue(context.Background(), "foo", "bar"), time.Hour)
+	)
+
+	assert.Len(StreamClientOptions(), 3)
+
+	attached := attachStream(ctx, nil)
+	assert.Equal("bar", attached.Value("foo"))
+	_, ok := attached.Deadline()
+	assert.False(ok)
+
+	// once detached, the fanout's cancellation no longer applies
+	detached := detachStream(context.WithValue(attached, "other", "value"), nil)
+	assert.Equal("value", detached.Value("other"))
+	detachStream(detached, nil) // idempotency
+
+	cancel()
+	require.NotNil(attached.Done())
+	select {
+	case <-attached.Done():
+		assert.Fail("A detached context should not be canceled")
+	case <-time.After(50 * time.Millisecond):
+		// passing
+	}
+
+	assert.NoError(attached.Err())
+
+	// contexts without a stream are ignored
+	plain := context.Background()
+	assert.Equal(plain, detachStream(plain, nil))
+}
+
+func testStreamClientOptionsCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+		attached    = attachStream(ctx, nil)
+	)
+
+	// a component that has not yet responded is canceled with the fanout
+	assert.NoError(attached.Err())
+	cancel()
+
+	select {
+	case <-attached.Done():
+		assert.Equal(context.Canceled, attached.Err())
+	case <-time.After(time.Second):
+		assert.Fail("An attached context should be canceled with its parent")
+	}
+}
+
+func TestStreamClientOptions(t *testing.T) {
+	t.Run("Detached", testStreamClientOptionsDetached)
+	t.Run("Canceled", testStreamClientOptionsCanceled)
+}
+
+func TestStreamRequest(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		original  = httptest.NewRequest("POST", "/", strings.NewReader("streamed"))
+		component = httptest.NewRequest("POST", "http://component.comcast.net/", nil)
+	)
+
+	original.Header.Set("Content-Type", "text/plain")
+	v, err := DecodeStreamRequest(context.Background(), original)
+	require.NoError(err)
+	require.IsType(&StreamThrough{}, v)
+	assert.Equal(original.Body, v.(*StreamThrough).Body)
+
+	require.NoError(EncodeStreamRequest(context.Background(), component, v))
+	assert.Equal(original.Body, component.Body)
+	assert.Equal(int64(8), component.ContentLength)
+	assert.Equal("text/plain", component.Header.Get("Content-Type"))
+
+	assert.Equal(ErrStreamConsumed, EncodeStreamRequest(context.Background(), httptest.NewRequest("POST", "/", nil), v))
+}
+
+func TestDecodeStreamResponse(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			require   = require.New(t)
+			component = &http.Response{
+				StatusCode:    201,
+				Header:        http.Header{"Content-Type": []string{"text/plain"}},
+				ContentLength: 8,
+				Body:          ioutil.NopCloser(strings.NewReader("streamed")),
+			}
+		)
+
+		v, err := DecodeStreamResponse(context.Background(), component)
+		require.NoError(err)
+		require.IsType(&StreamThrough{}, v)
+
+		st := v.(*StreamThrough)
+		assert.Equal(201, st.StatusCode)
+		assert.Equal("text/plain", st.ContentType)
+		assert.Equal(int64(8), st.ContentLength)
+		assert.Equal(component.Body, st.Body)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			require   = require.New(t)
+			component = &http.Response{
+				StatusCode: 503,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("unavailable")),
+			}
+		)
+
+		v, err := DecodeStreamResponse(context.Background(), component)
+		assert.Nil(v)
+		require.IsType(&xhttp.Error{}, err)
+		assert.Equal(503, err.(*xhttp.Error).Code)
+		assert.Equal([]byte("unavailable"), err.(*xhttp.Error).Entity)
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			body          = new(mockReader)
+			expectedError = errors.New("expected")
+		)
+
+		body.On("Read", mock.MatchedBy(func([]byte) bool { return true })).Return(0, expectedError).Once()
+		v, err := DecodeStreamResponse(context.Background(), &http.Response{StatusCode: 500, Body: ioutil.NopCloser(body)})
+		assert.Nil(v)
+		assert.Equal(expectedError, err)
+	})
+}
+
+func TestEncodeStreamResponse(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		original = httptest.NewRecorder()
+		st       = &StreamThrough{
+			StatusCode:    202,
+			ContentType:   "text/plain",
+			ContentLength: 8,
+			Body:          ioutil.NopCloser(strings.NewReader("streamed")),
+		}
+	)
+
+	assert.NoError(EncodeStreamResponse(context.Background(), original, st))
+	assert.Equal(202, original.Code)
+	assert.Equal("text/plain", original.HeaderMap.Get("Content-Type"))
+	assert.Equal("8", original.HeaderMap.Get("Content-Length"))
+	assert.Equal("streamed", original.Body.String())
+
+	assert.Equal(ErrStreamConsumed, EncodeStreamResponse(context.Background(), httptest.NewRecorder(), st))
+}
+
+func TestNewStreamComponents(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	components, err := NewStreamComponents([]string{"http://first.com", "http://second.com"})
+	assert.Nil(components)
+	assert.Equal(ErrTooManyStreamComponents, err)
+
+	components, err = NewStreamComponents([]string{"/api/v2"})
+	assert.Nil(components)
+	assert.Error(err)
+
+	components, err = NewStreamComponents([]string{"http://first.com"}, gokithttp.SetClient(http.DefaultClient))
+	require.NoError(err)
+	assert.Len(components, 1)
+	assert.Contains(components, "http://first.com")
+}
+
+func TestStreamIntegration(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		server  = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			entity, err := ioutil.ReadAll(request.Body)
+			assert.NoError(err)
+			response.Header().Set("Content-Type", "text/plain")
+			response.Write([]byte("echo: "))
+
+			// force the remainder of the body to arrive after the fanout endpoint has returned
+			response.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			response.Write(entity)
+		}))
+	)
+
+	defer server.Close()
+
+	components, err := NewStreamComponents([]string{server.URL})
+	require.NoError(err)
+
+	// the fanout middleware, including its timeout, cancels the fanout context before the response is encoded
+	handler := NewHandler(
+		new(Options).FanoutMiddleware()(fanout.New(tracing.NewSpanner(), components)),
+		DecodeStreamRequest,
+		EncodeStreamResponse,
+	)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("a large payload")))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("text/plain", response.HeaderMap.Get("Content-Type"))
+	assert.Equal("echo: a large payload", response.Body.String())
+}
+
+func TestStreamIntegrationTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		aborted = make(chan struct{})
+		server  = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			// the server only notices a client going away once the request body has been read
+			ioutil.ReadAll(request.Body)
+			select {
+			case <-request.Context().Done():
+				close(aborted)
+			case <-time.After(5 * time.Second):
+			}
+		}))
+	)
+
+	defer server.Close()
+
+	components, err := NewStreamComponents([]string{server.URL})
+	require.NoError(err)
+
+	handler := NewHandler(
+		(&Options{FanoutTimeout: 50 * time.Millisecond}).FanoutMiddleware()(fanout.New(tracing.NewSpanner(), components)),
+		DecodeStreamRequest,
+		EncodeStreamResponse,
+	)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("a large payload")))
+	assert.NotEqual(http.StatusOK, response.Code)
+
+	// a component that has not responded when the fanout times out is abandoned, rather than left holding a connection
+	select {
+	case <-aborted:
+		// passing
+	case <-time.After(time.Second):
+		assert.Fail("The component request was not canceled with the fanout")
+	}
+}
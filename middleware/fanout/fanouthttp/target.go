@@ -0,0 +1,35 @@
+package fanouthttp
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// Target describes a single component of an HTTP fanout
+type Target struct {
+	// URL is the absolute URL of the component.  See NewComponents.
+	URL string
+
+	// TLS is the optional TLS configuration used when connecting to this component
+	TLS *tls.Config
+
+	// Transport is the optional round tripper used for this component's requests, e.g. to use a particular proxy
+	// or custom connection settings.  If set, this replaces the transport of the component's HTTP client, including
+	// any TLS configuration.
+	Transport http.RoundTripper
+
+	// Credentials is the optional Authorization material presented to this component.  If set, this replaces any
+	// Authorization header forwarded from the original request or set by the shared options.
+	Credentials *Credentials
+
+	// Options are additional go-kit client options for this component only.  These options are applied after
+	// the options shared by all components, and so may override them.
+	Options []gokithttp.ClientOption
+}
+
+// newTLSClient is the default strategy for creating an HTTP client for a component with a custom TLS configuration
+func newTLSClient(config *tls.Config) *http.Client {
+	return (*Options)(nil).NewTLSClient(config)
+}
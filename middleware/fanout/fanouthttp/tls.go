@@ -0,0 +1,62 @@
+package fanouthttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+)
+
+// TLSOptions describes the TLS configuration for a component, in a form suitable for configuration files
+type TLSOptions struct {
+	// CAFile is the PEM-encoded bundle of certificate authorities used to verify the component's certificate.
+	// If not set, the system roots are used.
+	CAFile string `json:"caFile,omitempty"`
+
+	// CertificateFile is the PEM-encoded client certificate presented to the component.  If set, KeyFile is required.
+	CertificateFile string `json:"certificateFile,omitempty"`
+
+	// KeyFile is the PEM-encoded private key for CertificateFile
+	KeyFile string `json:"keyFile,omitempty"`
+
+	// ServerName overrides the server name used to verify the component's certificate
+	ServerName string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify disables verification of the component's certificate.  This should only be used for testing.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// NewConfig produces a tls.Config from these options.  If this TLSOptions is nil, this method returns a nil config.
+func (o *TLSOptions) NewConfig() (*tls.Config, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if len(o.CAFile) > 0 {
+		bundle, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("No certificates found in " + o.CAFile)
+		}
+	}
+
+	if len(o.CertificateFile) > 0 || len(o.KeyFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(o.CertificateFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,67 @@
+package fanouthttp
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempFile(t *testing.T, contents []byte) string {
+	f, err := ioutil.TempFile("", "tls_test")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write(contents)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func testTLSOptionsNil(t *testing.T) {
+	assert := assert.New(t)
+	config, err := (*TLSOptions)(nil).NewConfig()
+	assert.Nil(config)
+	assert.NoError(err)
+}
+
+func testTLSOptionsBasic(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		config, err = (&TLSOptions{ServerName: "test.comcast.net", InsecureSkipVerify: true}).NewConfig()
+	)
+
+	require.NoError(err)
+	require.NotNil(config)
+	assert.Equal("test.comcast.net", config.ServerName)
+	assert.True(config.InsecureSkipVerify)
+	assert.Nil(config.RootCAs)
+	assert.Empty(config.Certificates)
+}
+
+func testTLSOptionsErrors(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		empty  = writeTempFile(t, []byte("this is not PEM"))
+	)
+
+	defer os.Remove(empty)
+	for _, o := range []TLSOptions{
+		{CAFile: "/nosuch/ca.pem"},
+		{CAFile: empty},
+		{CertificateFile: "/nosuch/cert.pem", KeyFile: "/nosuch/key.pem"},
+		{CertificateFile: empty},
+	} {
+		config, err := o.NewConfig()
+		assert.Nil(config)
+		assert.Error(err)
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	t.Run("Nil", testTLSOptionsNil)
+	t.Run("Basic", testTLSOptionsBasic)
+	t.Run("Errors", testTLSOptionsErrors)
+}
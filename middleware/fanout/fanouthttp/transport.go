@@ -0,0 +1,88 @@
+package fanouthttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// TransportOptions describes the connection pooling and protocol settings for component clients, in a form suitable
+// for configuration files.  Each zero field leaves the corresponding setting of the base transport unchanged.
+type TransportOptions struct {
+	// MaxIdleConns is the maximum number of idle connections across all components
+	MaxIdleConns int `json:"maxIdleConns"`
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept for each component
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+
+	// MaxConnsPerHost limits the total number of connections, idle or active, to each component
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+
+	// IdleConnTimeout is how long an idle connection remains in the pool before being closed
+	IdleConnTimeout time.Duration `json:"idleConnTimeout"`
+
+	// TLSHandshakeTimeout is the maximum time to wait for a TLS handshake
+	TLSHandshakeTimeout time.Duration `json:"tlsHandshakeTimeout"`
+
+	// ResponseHeaderTimeout is the maximum time to wait for a component's response headers after writing the request
+	ResponseHeaderTimeout time.Duration `json:"responseHeaderTimeout"`
+
+	// ForceAttemptHTTP2 enables HTTP/2 even when a custom TLS configuration is used, which otherwise disables it
+	ForceAttemptHTTP2 bool `json:"forceAttemptHTTP2"`
+
+	// TLSSessionCacheSize is the capacity of the TLS client session cache, which allows TLS sessions to be resumed
+	// rather than renegotiated for each new connection.  If nonpositive, no session cache is configured.
+	TLSSessionCacheSize int `json:"tlsSessionCacheSize"`
+}
+
+// apply modifies the given transport with these options.  Any TLS configuration must already be set on the transport,
+// so that it can be given a session cache.  If this TransportOptions is nil, this method does nothing.
+func (to *TransportOptions) apply(t *http.Transport) {
+	if to == nil {
+		return
+	}
+
+	if to.MaxIdleConns > 0 {
+		t.MaxIdleConns = to.MaxIdleConns
+	}
+
+	if to.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = to.MaxIdleConnsPerHost
+	}
+
+	if to.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = to.MaxConnsPerHost
+	}
+
+	if to.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = to.IdleConnTimeout
+	}
+
+	if to.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = to.TLSHandshakeTimeout
+	}
+
+	if to.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = to.ResponseHeaderTimeout
+	}
+
+	if to.ForceAttemptHTTP2 {
+		t.ForceAttemptHTTP2 = true
+	}
+
+	if to.TLSSessionCacheSize > 0 {
+		// don't modify the original configuration, as it may be shared with other transports
+		var config *tls.Config
+		if t.TLSClientConfig != nil {
+			config = t.TLSClientConfig.Clone()
+		} else {
+			config = new(tls.Config)
+		}
+
+		if config.ClientSessionCache == nil {
+			config.ClientSessionCache = tls.NewLRUClientSessionCache(to.TLSSessionCacheSize)
+		}
+
+		t.TLSClientConfig = config
+	}
+}
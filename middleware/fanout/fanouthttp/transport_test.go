@@ -0,0 +1,113 @@
+package fanouthttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTransportOptionsApplyNil(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		transport = &http.Transport{MaxIdleConnsPerHost: 17}
+	)
+
+	(*TransportOptions)(nil).apply(transport)
+	new(TransportOptions).apply(transport)
+	assert.Equal(17, transport.MaxIdleConnsPerHost)
+	assert.Nil(transport.TLSClientConfig)
+	assert.False(transport.ForceAttemptHTTP2)
+}
+
+func testTransportOptionsApply(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		original  = &tls.Config{ServerName: "component.comcast.net"}
+		transport = &http.Transport{
+			MaxIdleConnsPerHost: 17,
+			TLSClientConfig:     original,
+		}
+
+		to = TransportOptions{
+			MaxIdleConns:          500,
+			MaxIdleConnsPerHost:   100,
+			MaxConnsPerHost:       200,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			ForceAttemptHTTP2:     true,
+			TLSSessionCacheSize:   64,
+		}
+	)
+
+	to.apply(transport)
+	assert.Equal(500, transport.MaxIdleConns)
+	assert.Equal(100, transport.MaxIdleConnsPerHost)
+	assert.Equal(200, transport.MaxConnsPerHost)
+	assert.Equal(90*time.Second, transport.IdleConnTimeout)
+	assert.Equal(5*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(10*time.Second, transport.ResponseHeaderTimeout)
+	assert.True(transport.ForceAttemptHTTP2)
+
+	require.NotNil(transport.TLSClientConfig)
+	assert.False(original == transport.TLSClientConfig)
+	assert.Nil(original.ClientSessionCache)
+	assert.Equal("component.comcast.net", transport.TLSClientConfig.ServerName)
+	assert.NotNil(transport.TLSClientConfig.ClientSessionCache)
+}
+
+func testTransportOptionsApplySessionCacheNoTLS(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		transport = new(http.Transport)
+	)
+
+	(&TransportOptions{TLSSessionCacheSize: 16}).apply(transport)
+	require.NotNil(transport.TLSClientConfig)
+	assert.NotNil(transport.TLSClientConfig.ClientSessionCache)
+}
+
+func testTransportOptionsNewTLSClient(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		config = &tls.Config{ServerName: "component.comcast.net"}
+		o      = Options{
+			Connections: &TransportOptions{
+				MaxIdleConnsPerHost: 250,
+				ForceAttemptHTTP2:   true,
+				TLSSessionCacheSize: 32,
+			},
+		}
+	)
+
+	client := o.NewTLSClient(config)
+	require.NotNil(client)
+
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(ok)
+	assert.Equal(250, transport.MaxIdleConnsPerHost)
+	assert.True(transport.ForceAttemptHTTP2)
+	require.NotNil(transport.TLSClientConfig)
+	assert.Equal("component.comcast.net", transport.TLSClientConfig.ServerName)
+	assert.NotNil(transport.TLSClientConfig.ClientSessionCache)
+	assert.Nil(config.ClientSessionCache)
+}
+
+func TestTransportOptions(t *testing.T) {
+	t.Run("Apply", func(t *testing.T) {
+		t.Run("Nil", testTransportOptionsApplyNil)
+		t.Run("Full", testTransportOptionsApply)
+		t.Run("SessionCacheNoTLS", testTransportOptionsApplySessionCacheNoTLS)
+	})
+
+	t.Run("NewTLSClient", testTransportOptionsNewTLSClient)
+}
@@ -0,0 +1,168 @@
+package fanouthttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	gokithttp "github.com/go-kit/kit/transport/http"
+	"github.com/spf13/viper"
+)
+
+// TargetOptions describes a single fanout target, in a form suitable for configuration files
+type TargetOptions struct {
+	// URL is the absolute URL of the component.  See NewComponents.
+	URL string `json:"url"`
+
+	// Headers are set on each request to this component, replacing any headers of the same name
+	// forwarded from the original request.
+	Headers http.Header `json:"headers,omitempty"`
+
+	// Credentials is the optional Authorization material presented to this component.  See Target.
+	Credentials *Credentials `json:"credentials,omitempty"`
+
+	// Timeout is the HTTP client timeout for this component.  If not set, the shared client timeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// TLS is the optional TLS configuration used when connecting to this component
+	TLS *TLSOptions `json:"tls,omitempty"`
+}
+
+// setHeaders is a component client RequestFunc which sets each of the given headers on component requests.
+// Header names are canonicalized, since configuration sources such as Viper do not preserve case.
+func setHeaders(h http.Header) gokithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		for name, values := range h {
+			name = http.CanonicalHeaderKey(name)
+			r.Header.Del(name)
+			for _, value := range values {
+				r.Header.Add(name, value)
+			}
+		}
+
+		return ctx
+	}
+}
+
+// NewTarget produces the Target described by these options.  The options are used to create the target's HTTP client
+// when the target has its own TLS configuration or timeout.  The options may be nil, in which case defaults are used.
+func (to TargetOptions) NewTarget(o *Options) (Target, error) {
+	config, err := to.TLS.NewConfig()
+	if err != nil {
+		return Target{}, err
+	}
+
+	target := Target{
+		URL:         to.URL,
+		TLS:         config,
+		Credentials: to.Credentials,
+	}
+
+	if to.Timeout > 0 {
+		client := o.NewTLSClient(config)
+		client.Timeout = to.Timeout
+		target.Options = append(target.Options, gokithttp.SetClient(client))
+	}
+
+	if len(to.Headers) > 0 {
+		target.Options = append(target.Options, gokithttp.ClientBefore(setHeaders(to.Headers)))
+	}
+
+	return target, nil
+}
+
+// NewTargets produces the complete set of targets described by these options.  Each of the Endpoints is a target
+// with no customizations, followed by a target for each of the configured Targets.
+func (o *Options) NewTargets() ([]Target, error) {
+	var targets []Target
+	for _, endpoint := range o.endpoints() {
+		targets = append(targets, Target{URL: endpoint})
+	}
+
+	for _, to := range o.targets() {
+		target, err := to.NewTarget(o)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// NewHandler constructs a complete fanout http.Handler from these options.  The component clients, the fanout endpoint,
+// its middleware, and the server's error encoding are all described by these options.  The codec functions are the same
+// as those passed to NewComponents and the package-level NewHandler.  The extra options are applied after those from the
+// Behavior configuration, and may be used to supply features that cannot be configured, such as ShouldTerminate.
+// If a MetricsProvider is set, each component is instrumented with the Measures from NewMeasures.  If a CORSPolicy is configured, the returned handler is decorated with CORS.  Original request entities are limited
+// to MaxRequestBody bytes, as with NewLimitedHandler.  If AccessLog is set, each request is logged via AccessLog.
+//
+// If these options describe no primary components, fanout.ErrNoComponents is returned.
+func (o *Options) NewHandler(dec gokithttp.DecodeRequestFunc, componentEnc gokithttp.EncodeRequestFunc, componentDec gokithttp.DecodeResponseFunc, enc gokithttp.EncodeResponseFunc, extra ...fanout.Option) (http.Handler, error) {
+	targets, err := o.NewTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	components, err := NewTargetComponents(targets, o.NewTLSClient, componentEnc, componentDec, o.ClientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if p := o.metricsProvider(); p != nil {
+		components = NewMeasures(p).Instrument(components)
+	}
+
+	primaries, options, err := o.behavior().FanoutOptions(components)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(primaries) == 0 {
+		return nil, fanout.ErrNoComponents
+	}
+
+	handler := NewLimitedHandler(
+		o.FanoutMiddleware()(fanout.New(tracing.NewSpanner(), primaries, append(options, extra...)...)),
+		o.maxRequestBody(),
+		dec,
+		enc,
+		gokithttp.ServerErrorEncoder(o.ErrorEncoder("")),
+	)
+
+	if o.accessLog() {
+		handler = AccessLog(o.logger())(handler)
+	}
+
+	if policy := o.cors(); policy != nil {
+		handler = CORS(*policy)(handler)
+	}
+
+	return handler, nil
+}
+
+// Sub returns the standard child Viper, using fanout.FanoutKey, for this package.
+// If passed nil, this function returns nil.
+func Sub(v *viper.Viper) *viper.Viper {
+	if v != nil {
+		return v.Sub(fanout.FanoutKey)
+	}
+
+	return nil
+}
+
+// FromViper produces an Options from a (possibly nil) Viper instance.
+// Callers should use FromViper(Sub(v)) if the standard subkey is desired.
+func FromViper(v *viper.Viper) (*Options, error) {
+	o := new(Options)
+	if v != nil {
+		if err := v.Unmarshal(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
@@ -0,0 +1,351 @@
+package fanouthttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/go-kit/kit/metrics"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestViper(t *testing.T, configuration string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(configuration)))
+	return v
+}
+
+func TestSub(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	assert.Nil(Sub(nil))
+	assert.Nil(Sub(viper.New()))
+
+	child := Sub(newTestViper(t, `{"fanout": {"endpoints": ["http://localhost:8080"]}}`))
+	require.NotNil(child)
+	assert.Equal([]string{"http://localhost:8080"}, child.GetStringSlice("endpoints"))
+}
+
+func TestFromViper(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o, err = FromViper(nil)
+		)
+
+		assert.Equal(new(Options), o)
+		assert.NoError(err)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			o, err  = FromViper(newTestViper(t, `{
+				"endpoints": ["http://host1.com:8080"],
+				"clientTimeout": "10s",
+				"connections": {
+					"maxIdleConnsPerHost": 100,
+					"idleConnTimeout": "90s",
+					"forceAttemptHTTP2": true,
+					"tlsSessionCacheSize": 64
+				},
+				"targets": [
+					{
+						"url": "https://host2.com:8443",
+						"headers": {"X-Custom": ["value"]},
+						"credentials": {"bearer": "token"},
+						"timeout": "2s",
+						"tls": {"serverName": "host2.comcast.net"}
+					}
+				],
+				"behavior": {
+					"partialSuccess": true,
+					"budget": "15s"
+				}
+			}`))
+		)
+
+		require.NoError(err)
+		require.NotNil(o)
+		assert.Equal([]string{"http://host1.com:8080"}, o.Endpoints)
+		assert.Equal(10*time.Second, o.ClientTimeout)
+		assert.Equal(&TransportOptions{MaxIdleConnsPerHost: 100, IdleConnTimeout: 90 * time.Second, ForceAttemptHTTP2: true, TLSSessionCacheSize: 64}, o.Connections)
+		require.Len(o.Targets, 1)
+		assert.Equal("https://host2.com:8443", o.Targets[0].URL)
+		assert.Equal([]string{"value"}, o.Targets[0].Headers[http.CanonicalHeaderKey("x-custom")])
+		assert.Equal(&Credentials{Bearer: "token"}, o.Targets[0].Credentials)
+		assert.Equal(2*time.Second, o.Targets[0].Timeout)
+		assert.Equal(&TLSOptions{ServerName: "host2.comcast.net"}, o.Targets[0].TLS)
+		assert.Equal(&fanout.Options{PartialSuccess: true, Budget: 15 * time.Second}, o.Behavior)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o, err = FromViper(newTestViper(t, `{"clientTimeout": "this is not a duration"}`))
+		)
+
+		assert.Nil(o)
+		assert.Error(err)
+	})
+}
+
+func testTargetOptionsNewTargetDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	target, err := TargetOptions{URL: "http://localhost:8080"}.NewTarget(nil)
+	require.NoError(err)
+	assert.Equal(Target{URL: "http://localhost:8080"}, target)
+}
+
+func testTargetOptionsNewTargetCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		to = TargetOptions{
+			URL:         "https://localhost:8443",
+			Headers:     http.Header{"x-custom": []string{"value"}},
+			Credentials: &Credentials{Basic: "dXNlcjpwYXNzd29yZA=="},
+			Timeout:     3 * time.Second,
+			TLS:         &TLSOptions{ServerName: "localhost.comcast.net"},
+		}
+	)
+
+	target, err := to.NewTarget(nil)
+	require.NoError(err)
+	assert.Equal("https://localhost:8443", target.URL)
+	require.NotNil(target.TLS)
+	assert.Equal("localhost.comcast.net", target.TLS.ServerName)
+	assert.Equal(&Credentials{Basic: "dXNlcjpwYXNzd29yZA=="}, target.Credentials)
+	assert.Len(target.Options, 2)
+}
+
+func testTargetOptionsNewTargetTLSError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		to     = TargetOptions{
+			URL: "https://localhost:8443",
+			TLS: &TLSOptions{CAFile: "/this/file/does/not/exist.pem"},
+		}
+	)
+
+	_, err := to.NewTarget(nil)
+	assert.Error(err)
+}
+
+func TestTargetOptions(t *testing.T) {
+	t.Run("NewTarget", func(t *testing.T) {
+		t.Run("Default", testTargetOptionsNewTargetDefault)
+		t.Run("Custom", testTargetOptionsNewTargetCustom)
+		t.Run("TLSError", testTargetOptionsNewTargetTLSError)
+	})
+}
+
+func TestSetHeaders(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request.Header.Set("X-Custom", "forwarded")
+	request.Header.Set("X-Other", "untouched")
+
+	ctx := setHeaders(http.Header{"x-custom": []string{"value1", "value2"}})(context.Background(), request)
+	assert.Equal(context.Background(), ctx)
+	assert.Equal([]string{"value1", "value2"}, request.Header["X-Custom"])
+	assert.Equal("untouched", request.Header.Get("X-Other"))
+}
+
+func TestOptionsNewTargets(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	targets, err := (*Options)(nil).NewTargets()
+	assert.Empty(targets)
+	assert.NoError(err)
+
+	o := &Options{
+		Endpoints: []string{"http://host1.com:8080"},
+		Targets: []TargetOptions{
+			{URL: "http://host2.com:8080", Timeout: time.Second},
+		},
+	}
+
+	targets, err = o.NewTargets()
+	require.NoError(err)
+	require.Len(targets, 2)
+	assert.Equal(Target{URL: "http://host1.com:8080"}, targets[0])
+	assert.Equal("http://host2.com:8080", targets[1].URL)
+	assert.Len(targets[1].Options, 1)
+
+	o.Targets = append(o.Targets, TargetOptions{URL: "https://host3.com", TLS: &TLSOptions{CAFile: "/this/file/does/not/exist.pem"}})
+	targets, err = o.NewTargets()
+	assert.Empty(targets)
+	assert.Error(err)
+}
+
+func testOptionsNewHandlerIntegration(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		plain = httptest.NewServer(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				assert.Empty(request.Header.Get("X-Custom"))
+				response.WriteHeader(http.StatusServiceUnavailable)
+			}),
+		)
+
+		custom = httptest.NewServer(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				assert.Equal("value", request.Header.Get("X-Custom"))
+				assert.Equal("Bearer token", request.Header.Get("Authorization"))
+
+				entity, err := ioutil.ReadAll(request.Body)
+				assert.NoError(err)
+				assert.Equal("original", string(entity))
+
+				response.Header().Set("Content-Type", "text/plain")
+				response.Write([]byte("success"))
+			}),
+		)
+	)
+
+	defer plain.Close()
+	defer custom.Close()
+
+	o, err := FromViper(newTestViper(t, `{
+		"endpoints": ["`+plain.URL+`"],
+		"targets": [
+			{
+				"url": "`+custom.URL+`",
+				"headers": {"X-Custom": ["value"]},
+				"credentials": {"bearer": "token"},
+				"timeout": "5s"
+			}
+		],
+		"behavior": {
+			"budget": "10s"
+		},
+		"cors": {
+			"allowedOrigins": ["https://app.comcast.net"]
+		}
+	}`))
+
+	require.NoError(err)
+	handler, err := o.NewHandler(DecodePassThroughRequest, EncodePassThroughRequest, DecodePassThroughResponse, EncodePassThroughResponse)
+	require.NoError(err)
+	require.NotNil(handler)
+
+	var (
+		request  = httptest.NewRequest("POST", "/api/v2/device", strings.NewReader("original"))
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Origin", "https://app.comcast.net")
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("success", response.Body.String())
+	assert.Equal("https://app.comcast.net", response.HeaderMap.Get("Access-Control-Allow-Origin"))
+
+	preflight := httptest.NewRequest("OPTIONS", "/api/v2/device", nil)
+	preflight.Header.Set("Origin", "https://app.comcast.net")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, preflight)
+	assert.Equal(http.StatusNoContent, response.Code)
+}
+
+func testOptionsNewHandlerMetrics(t *testing.T) {
+	var (
+		assert          = assert.New(t)
+		require         = require.New(t)
+		requests        = new(mockCounter)
+		labeledRequests = new(mockCounter)
+		p               = new(mockProvider)
+
+		server = httptest.NewServer(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.Write([]byte("success"))
+			}),
+		)
+	)
+
+	defer server.Close()
+
+	p.On("NewCounter", ComponentRequestCounter).Return(requests).Once()
+	p.On("NewCounter", ComponentErrorCounter).Return(nil).Once()
+	p.On("NewHistogram", ComponentDurationHistogram, 10).Return(nil).Once()
+	p.On("NewGauge", ComponentInFlightGauge).Return(nil).Once()
+	requests.On("With", []string{URLLabel, server.URL}).Return(metrics.Counter(labeledRequests)).Once()
+	labeledRequests.On("Add", 1.0).Once()
+
+	o := &Options{Endpoints: []string{server.URL}, MetricsProvider: p}
+	handler, err := o.NewHandler(DecodePassThroughRequest, EncodePassThroughRequest, DecodePassThroughResponse, EncodePassThroughResponse)
+	require.NoError(err)
+	require.NotNil(handler)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/api/v2/device", nil))
+	assert.Equal(http.StatusOK, response.Code)
+
+	p.AssertExpectations(t)
+	requests.AssertExpectations(t)
+	labeledRequests.AssertExpectations(t)
+}
+
+func testOptionsNewHandlerNoComponents(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		handler, err = new(Options).NewHandler(DecodePassThroughRequest, EncodePassThroughRequest, DecodePassThroughResponse, EncodePassThroughResponse)
+	)
+
+	assert.Nil(handler)
+	assert.Equal(fanout.ErrNoComponents, err)
+}
+
+func testOptionsNewHandlerError(t *testing.T, o *Options) {
+	var (
+		assert       = assert.New(t)
+		handler, err = o.NewHandler(DecodePassThroughRequest, EncodePassThroughRequest, DecodePassThroughResponse, EncodePassThroughResponse)
+	)
+
+	assert.Nil(handler)
+	assert.Error(err)
+}
+
+func TestOptionsNewHandler(t *testing.T) {
+	t.Run("Integration", testOptionsNewHandlerIntegration)
+	t.Run("Metrics", testOptionsNewHandlerMetrics)
+	t.Run("NoComponents", testOptionsNewHandlerNoComponents)
+
+	t.Run("InvalidEndpoint", func(t *testing.T) {
+		testOptionsNewHandlerError(t, &Options{Endpoints: []string{"/api/v2"}})
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		testOptionsNewHandlerError(t, &Options{Targets: []TargetOptions{{URL: "https://localhost", TLS: &TLSOptions{CAFile: "/this/file/does/not/exist.pem"}}}})
+	})
+
+	t.Run("InvalidShadow", func(t *testing.T) {
+		testOptionsNewHandlerError(t, &Options{Endpoints: []string{"http://localhost:8080"}, Behavior: &fanout.Options{Shadows: []string{"nosuch"}}})
+	})
+}
@@ -0,0 +1,79 @@
+package fanout
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log/level"
+)
+
+// NewGroups produces a go-kit Endpoint which fans out to ordered groups of components.  The first group is
+// dispatched just as with New.  Only if every component in that group fails is the next group dispatched, and so on.
+// This allows primary/failover topologies, such as a local datacenter followed by remote datacenters, within a single
+// fanout endpoint.
+//
+// The spans from each failed group are merged into the eventual response or error, ahead of the spans from later groups.
+// A group is not tried if the context is canceled or if the ShouldTerminate predicate returns true for the previous
+// group's error.  The options are applied to each group as well as to the overall endpoint, so a DeadlineBudget bounds
+// the cumulative time taken by all groups.  Shadow components are the exception: they are dispatched once per request
+// by the overall endpoint, no matter how many groups are tried.
+//
+// If spanner is nil, groups is empty, or any group has no components, this function panics.
+func NewGroups(spanner tracing.Spanner, groups []Components, o ...Option) endpoint.Endpoint {
+	if len(groups) == 0 {
+		panic("No groups supplied")
+	}
+
+	var (
+		options      = newFanoutOptions(o...)
+		shadower     = &dispatcher{spanner: spanner, options: options}
+		groupOptions = append(append(make([]Option, 0, len(o)+1), o...), withoutShadows())
+		fanouts      = make([]endpoint.Endpoint, len(groups))
+	)
+
+	for i, g := range groups {
+		fanouts[i] = New(spanner, g, groupOptions...)
+	}
+
+	return func(ctx context.Context, v interface{}) (interface{}, error) {
+		ctx, cancel := options.fanoutContext(ctx)
+		defer cancel()
+
+		shadower.shadow(NewContext(ctx, v), v)
+
+		var (
+			logger    = logging.Logger(ctx)
+			lastError error
+			spans     []tracing.Span
+		)
+
+		for i, f := range fanouts {
+			response, err := f(ctx, v)
+			if err == nil {
+				if mergeable, ok := response.(tracing.Mergeable); ok && len(spans) > 0 {
+					response = mergeable.WithSpans(append(spans, mergeable.Spans()...)...)
+				}
+
+				return response, nil
+			}
+
+			lastError = err
+			if spanError, ok := err.(tracing.SpanError); ok {
+				lastError = spanError.Err()
+				spans = append(spans, spanError.Spans()...)
+			}
+
+			if ctx.Err() != nil || options.shouldTerminate(lastError) {
+				break
+			}
+
+			if i < len(fanouts)-1 {
+				logger.Log(level.Key(), level.WarnValue(), "group", i, logging.ErrorKey(), lastError, logging.MessageKey(), "group failed, trying next group")
+			}
+		}
+
+		return nil, tracing.NewSpanError(lastError, spans...)
+	}
+}
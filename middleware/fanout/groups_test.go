@@ -0,0 +1,204 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewGroupsInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		success = func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	)
+
+	assert.Panics(func() { NewGroups(tracing.NewSpanner(), nil) })
+	assert.Panics(func() { NewGroups(tracing.NewSpanner(), []Components{}) })
+	assert.Panics(func() { NewGroups(tracing.NewSpanner(), []Components{{"success": success}, {}}) })
+	assert.Panics(func() { NewGroups(nil, []Components{{"success": success}}) })
+}
+
+func testNewGroupsPrimarySucceeds(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{
+				{"primary": func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }},
+				{"secondary": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("The secondary group should not have been called")
+					return nil, nil
+				}},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 1)
+	assert.Equal("primary", spans[0].Name())
+}
+
+func testNewGroupsFailover(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{
+				{"primary": func(context.Context, interface{}) (interface{}, error) { return nil, expectedError }},
+				{"secondary": func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 2)
+	assert.Equal("primary", spans[0].Name())
+	assert.Equal(expectedError, spans[0].Error())
+	assert.Equal("secondary", spans[1].Name())
+	assert.NoError(spans[1].Error())
+}
+
+func testNewGroupsAllFail(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		first   = errors.New("first")
+		second  = errors.New("second")
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{
+				{"primary": func(context.Context, interface{}) (interface{}, error) { return nil, first }},
+				{"secondary": func(context.Context, interface{}) (interface{}, error) { return nil, second }},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.Nil(response)
+	require.Error(err)
+
+	spanError := err.(tracing.SpanError)
+	assert.Equal(second, spanError.Err())
+	require.Len(spanError.Spans(), 2)
+	assert.Equal(first, spanError.Spans()[0].Error())
+	assert.Equal(second, spanError.Spans()[1].Error())
+}
+
+func testNewGroupsShouldTerminate(t *testing.T) {
+	var (
+		assert           = assert.New(t)
+		require          = require.New(t)
+		terminatingError = errors.New("terminate")
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{
+				{"primary": func(context.Context, interface{}) (interface{}, error) { return nil, terminatingError }},
+				{"secondary": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("The secondary group should not have been called")
+					return nil, nil
+				}},
+			},
+			ShouldTerminate(func(err error) bool { return err == terminatingError }),
+		)
+	)
+
+	require.NotNil(fanout)
+	_, err := fanout(context.Background(), "request")
+	require.Error(err)
+	assert.Equal(terminatingError, err.(tracing.SpanError).Err())
+}
+
+func testNewGroupsCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{
+				{"primary": func(context.Context, interface{}) (interface{}, error) {
+					cancel()
+					return nil, errors.New("expected")
+				}},
+				{"secondary": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("The secondary group should not have been called")
+					return nil, nil
+				}},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	_, err := fanout(ctx, "request")
+	assert.Error(err)
+}
+
+func testNewGroupsShadow(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		failure       = func(context.Context, interface{}) (interface{}, error) { return nil, expectedError }
+		observed      = make(chan Result, 3)
+
+		fanout = NewGroups(
+			tracing.NewSpanner(),
+			[]Components{{"first": failure}, {"second": failure}, {"third": failure}},
+			Shadow(
+				Components{"shadow": func(context.Context, interface{}) (interface{}, error) { return "shadow", nil }},
+				time.Minute,
+				func(r Result) { observed <- r },
+			),
+		)
+	)
+
+	require.NotNil(fanout)
+	_, err := fanout(context.Background(), "request")
+	assert.Error(err)
+
+	select {
+	case r := <-observed:
+		assert.Equal("shadow", r.Name)
+	case <-time.After(time.Second):
+		require.Fail("The shadow was not dispatched")
+	}
+
+	// the shadow is dispatched once per request, not once per group
+	select {
+	case r := <-observed:
+		assert.Fail("The shadow was dispatched more than once", "%#v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNewGroups(t *testing.T) {
+	t.Run("Invalid", testNewGroupsInvalid)
+	t.Run("PrimarySucceeds", testNewGroupsPrimarySucceeds)
+	t.Run("Failover", testNewGroupsFailover)
+	t.Run("AllFail", testNewGroupsAllFail)
+	t.Run("ShouldTerminate", testNewGroupsShouldTerminate)
+	t.Run("Canceled", testNewGroupsCanceled)
+	t.Run("Shadow", testNewGroupsShadow)
+}
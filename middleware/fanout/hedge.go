@@ -0,0 +1,207 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotDispatched is the error recorded for a component that was never started, either because an earlier
+// component of a hedged fanout had already succeeded, or because the fanout's context ended first.
+var ErrNotDispatched = errors.New("Component was not dispatched")
+
+// DefaultHedgeAlpha is the smoothing factor used for latency averages when a nonpositive alpha is supplied to AdaptiveHedge
+const DefaultHedgeAlpha = 0.2
+
+// latencyTracker maintains an exponentially weighted moving average of each component's latency
+type latencyTracker struct {
+	lock      sync.RWMutex
+	alpha     float64
+	latencies map[string]time.Duration
+}
+
+func newLatencyTracker(alpha float64) *latencyTracker {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultHedgeAlpha
+	}
+
+	return &latencyTracker{
+		alpha:     alpha,
+		latencies: make(map[string]time.Duration),
+	}
+}
+
+// latency returns the average latency of the given component.  If no successful result has been
+// observed for the component, this method returns false.
+func (lt *latencyTracker) latency(name string) (time.Duration, bool) {
+	lt.lock.RLock()
+	l, ok := lt.latencies[name]
+	lt.lock.RUnlock()
+	return l, ok
+}
+
+// onResult is the resultListener which updates a component's average latency.  Only successful results
+// contribute to the average, as failures are frequently much faster or slower than normal responses.
+func (lt *latencyTracker) onResult(_ context.Context, _ interface{}, r Result) {
+	if r.Err != nil || r.Span == nil {
+		return
+	}
+
+	sample := r.Span.Duration()
+	lt.lock.Lock()
+	if l, ok := lt.latencies[r.Name]; ok {
+		lt.latencies[r.Name] = l + time.Duration(lt.alpha*float64(sample-l))
+	} else {
+		lt.latencies[r.Name] = sample
+	}
+
+	lt.lock.Unlock()
+}
+
+// hedging holds the configuration for a hedged fanout
+type hedging struct {
+	delay     time.Duration
+	latencies *latencyTracker
+}
+
+// order returns the names of the given components in the order in which they should be started.  Without
+// latency tracking, components are ordered by name.  With latency tracking, components are ordered from fastest
+// to slowest, where components with no recorded latency are assumed to take the configured delay.
+func (h *hedging) order(components Components) []string {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+
+	if h.latencies == nil {
+		sort.Strings(names)
+		return names
+	}
+
+	latencies := make(map[string]time.Duration, len(names))
+	for _, name := range names {
+		latencies[name] = h.delayAfter(name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if latencies[names[i]] == latencies[names[j]] {
+			return names[i] < names[j]
+		}
+
+		return latencies[names[i]] < latencies[names[j]]
+	})
+
+	return names
+}
+
+// delayAfter returns how long to wait on the given component before starting the next one
+func (h *hedging) delayAfter(name string) time.Duration {
+	if h.latencies != nil {
+		if l, ok := h.latencies.latency(name); ok {
+			return l
+		}
+	}
+
+	return h.delay
+}
+
+// Hedge configures a fanout to start its components one at a time rather than all at once.  The first
+// component is started immediately, and each subsequent component is started if no earlier component has
+// succeeded within the given delay.  A component that fails causes the next component to be started immediately.
+// Once a component succeeds, no further components are started, and any that were not started are recorded
+// with ErrNotDispatched.  This reduces downstream load while bounding tail latency.
+//
+// Components are started in order of their names.  See AdaptiveHedge for latency-based ordering.  If delay
+// is nonpositive, this option does nothing.
+func Hedge(delay time.Duration) Option {
+	return func(fo *fanoutOptions) {
+		if delay > 0 {
+			fo.hedge = &hedging{delay: delay}
+		}
+	}
+}
+
+// AdaptiveHedge is like Hedge, except that the fanout maintains an exponentially weighted moving average of each
+// component's latency using the smoothing factor alpha.  Components are started fastest first, and the delay before
+// starting the next component is the average latency of the last component started.  The hedging delay therefore
+// adapts automatically as backend performance changes.  Components that have not yet succeeded are assumed
+// to have a latency equal to the given delay.
+//
+// If alpha is not in (0, 1], DefaultHedgeAlpha is used.  If delay is nonpositive, this option does nothing.
+func AdaptiveHedge(delay time.Duration, alpha float64) Option {
+	return func(fo *fanoutOptions) {
+		if delay > 0 {
+			lt := newLatencyTracker(alpha)
+			fo.hedge = &hedging{delay: delay, latencies: lt}
+			fo.listeners = append(fo.listeners, lt.onResult)
+		}
+	}
+}
+
+// hedge starts the given components one at a time, as configured by the Hedge or AdaptiveHedge options.  Each component's
+// Result is forwarded to the results channel.  Components that are never started are also reported, so that every
+// component produces exactly one Result.
+func (d *dispatcher) hedge(ctx, componentCtx context.Context, v interface{}, components Components, results chan<- Result) {
+	var (
+		order   = d.options.hedge.order(components)
+		started = make(chan Result, len(order))
+		next    = 0
+		running = 0
+		done    = ctx.Done()
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+
+	stopTimer := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+	}
+
+	start := func() {
+		stopTimer()
+		name := order[next]
+		next++
+		running++
+		d.run(ctx, componentCtx, name, components[name], v, started)
+		if next < len(order) {
+			timer = time.NewTimer(d.options.hedge.delayAfter(name))
+			timeout = timer.C
+		}
+	}
+
+	skip := func() {
+		stopTimer()
+		for ; next < len(order); next++ {
+			d.notRun(ctx, v, order[next], ErrNotDispatched, results)
+		}
+	}
+
+	start()
+	for running > 0 {
+		select {
+		case r := <-started:
+			running--
+			results <- r
+			if r.Err == nil {
+				skip()
+			} else if next < len(order) {
+				start()
+			}
+
+		case <-timeout:
+			timer, timeout = nil, nil
+			start()
+
+		case <-done:
+			done = nil
+			skip()
+		}
+	}
+
+	// in case the final component finished before the context was canceled
+	skip()
+}
@@ -0,0 +1,262 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResult(name string, latency time.Duration, err error) Result {
+	spanner := tracing.NewSpanner(tracing.Since(func(time.Time) time.Duration { return latency }))
+	return Result{Name: name, Span: spanner.Start(name)(err), Err: err}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lt     = newLatencyTracker(0.5)
+	)
+
+	assert.Equal(DefaultHedgeAlpha, newLatencyTracker(0.0).alpha)
+	assert.Equal(DefaultHedgeAlpha, newLatencyTracker(1.5).alpha)
+
+	l, ok := lt.latency("test")
+	assert.Zero(l)
+	assert.False(ok)
+
+	lt.onResult(context.Background(), "request", newTestResult("test", time.Second, errors.New("expected")))
+	l, ok = lt.latency("test")
+	assert.Zero(l)
+	assert.False(ok)
+
+	lt.onResult(context.Background(), "request", Result{Name: "test"})
+	l, ok = lt.latency("test")
+	assert.Zero(l)
+	assert.False(ok)
+
+	lt.onResult(context.Background(), "request", newTestResult("test", 100*time.Millisecond, nil))
+	l, ok = lt.latency("test")
+	assert.Equal(100*time.Millisecond, l)
+	assert.True(ok)
+
+	lt.onResult(context.Background(), "request", newTestResult("test", 200*time.Millisecond, nil))
+	l, ok = lt.latency("test")
+	assert.Equal(150*time.Millisecond, l)
+	assert.True(ok)
+}
+
+func TestHedgingOrder(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		components = Components{"charlie": nil, "alpha": nil, "bravo": nil, "delta": nil}
+	)
+
+	assert.Equal([]string{"alpha", "bravo", "charlie", "delta"}, (&hedging{delay: time.Second}).order(components))
+
+	h := &hedging{delay: time.Second, latencies: newLatencyTracker(0.5)}
+	h.latencies.onResult(context.Background(), "request", newTestResult("alpha", 300*time.Millisecond, nil))
+	h.latencies.onResult(context.Background(), "request", newTestResult("charlie", 100*time.Millisecond, nil))
+	h.latencies.onResult(context.Background(), "request", newTestResult("delta", 200*time.Millisecond, nil))
+
+	assert.Equal([]string{"charlie", "delta", "alpha", "bravo"}, h.order(components))
+	assert.Equal(100*time.Millisecond, h.delayAfter("charlie"))
+	assert.Equal(time.Second, h.delayAfter("bravo"))
+}
+
+func testHedgeFirstSucceeds(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		success = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+		unused  = func(context.Context, interface{}) (interface{}, error) {
+			assert.Fail("This component should not have been started")
+			return nil, nil
+		}
+
+		fanout = New(tracing.NewSpanner(), Components{"first": success, "second": unused}, Hedge(time.Hour), PartialSuccess())
+	)
+
+	response, err := fanout(context.Background(), "request")
+	require.NoError(err)
+	require.IsType(&AggregateResponse{}, response)
+
+	aggregate := response.(*AggregateResponse)
+	require.Len(aggregate.Results, 2)
+	assert.Equal("first", aggregate.Results[0].Name)
+	assert.NoError(aggregate.Results[0].Err)
+	assert.Equal("second", aggregate.Results[1].Name)
+	assert.Equal(ErrNotDispatched, aggregate.Results[1].Err)
+}
+
+func testHedgeDelayElapsed(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		release = make(chan struct{})
+		slow    = func(context.Context, interface{}) (interface{}, error) {
+			<-release
+			return nil, errors.New("expected")
+		}
+
+		success = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+		fanout  = New(tracing.NewSpanner(), Components{"first": slow, "second": success}, Hedge(time.Millisecond))
+	)
+
+	defer close(release)
+	response, err := fanout(context.Background(), "request")
+	assert.NoError(err)
+	require.NotNil(response)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 1)
+	assert.Equal("second", spans[0].Name())
+}
+
+func testHedgeFailure(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		failure       = func(context.Context, interface{}) (interface{}, error) { return nil, expectedError }
+		success       = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+		fanout        = New(tracing.NewSpanner(), Components{"first": failure, "second": success}, Hedge(time.Hour))
+	)
+
+	response, err := fanout(context.Background(), "request")
+	assert.NoError(err)
+	require.NotNil(response)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 2)
+	assert.Equal("first", spans[0].Name())
+	assert.Equal(expectedError, spans[0].Error())
+	assert.Equal("second", spans[1].Name())
+
+	response, err = New(tracing.NewSpanner(), Components{"first": failure, "second": failure}, Hedge(time.Hour))(context.Background(), "request")
+	assert.Nil(response)
+	assert.Error(err)
+	require.Implements((*tracing.Spanned)(nil), err)
+	assert.Len(err.(tracing.Spanned).Spans(), 2)
+}
+
+func testHedgeCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		release     = make(chan struct{})
+		ctx, cancel = context.WithCancel(context.Background())
+		blocking    = func(context.Context, interface{}) (interface{}, error) {
+			cancel()
+			<-release
+			return nil, errors.New("expected")
+		}
+
+		fanout = New(tracing.NewSpanner(), Components{"first": blocking, "second": blocking}, Hedge(time.Hour))
+	)
+
+	defer close(release)
+	response, err := fanout(ctx, "request")
+	assert.Nil(response)
+	assert.Error(err)
+}
+
+func testHedgeCanceledNotDispatched(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		release     = make(chan struct{})
+		skipped     = make(chan Result, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+		blocking    = func(context.Context, interface{}) (interface{}, error) {
+			cancel()
+			<-release
+			return nil, errors.New("expected")
+		}
+
+		unused = func(context.Context, interface{}) (interface{}, error) {
+			assert.Fail("This component should not have been started")
+			return nil, nil
+		}
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{"first": blocking, "second": unused},
+			Hedge(time.Hour),
+			DecorateSpans(func(r Result) tracing.Span {
+				if r.Name == "second" {
+					skipped <- r
+				}
+
+				return nil
+			}),
+		)
+	)
+
+	defer close(release)
+	response, err := fanout(ctx, "request")
+	assert.Nil(response)
+	assert.Error(err)
+
+	// the component which was never started is not blamed for the cancellation
+	select {
+	case r := <-skipped:
+		assert.Equal(ErrNotDispatched, r.Err)
+		assert.True(r.notRun)
+	case <-time.After(time.Second):
+		require.Fail("The skipped component was not reported")
+	}
+}
+
+func testHedgeAdaptive(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		release    = make(chan struct{})
+		alphaCalls int32
+		slow       = func(context.Context, interface{}) (interface{}, error) {
+			atomic.AddInt32(&alphaCalls, 1)
+			<-release
+			return tracing.NopMergeable{}, nil
+		}
+
+		fast = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+
+		// "alpha" is started first initially, since it sorts first, but it is slower than "bravo"
+		fanout = New(tracing.NewSpanner(), Components{"alpha": slow, "bravo": fast}, AdaptiveHedge(10*time.Millisecond, 0.5))
+	)
+
+	defer close(release)
+	for repeat := 0; repeat < 2; repeat++ {
+		response, err := fanout(context.Background(), "request")
+		assert.NoError(err)
+		require.NotNil(response)
+
+		spans := response.(tracing.Spanned).Spans()
+		require.Len(spans, 1)
+		assert.Equal("bravo", spans[0].Name())
+	}
+
+	// the second fanout should have started the faster component first, and never started the slow one
+	assert.Equal(int32(1), atomic.LoadInt32(&alphaCalls))
+}
+
+func TestHedge(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Nil(newFanoutOptions(Hedge(0)).hedge)
+		assert.Nil(newFanoutOptions(AdaptiveHedge(-1, 0.5)).hedge)
+		assert.Empty(newFanoutOptions(AdaptiveHedge(-1, 0.5)).listeners)
+	})
+
+	t.Run("FirstSucceeds", testHedgeFirstSucceeds)
+	t.Run("DelayElapsed", testHedgeDelayElapsed)
+	t.Run("Failure", testHedgeFailure)
+	t.Run("Canceled", testHedgeCanceled)
+	t.Run("CanceledNotDispatched", testHedgeCanceledNotDispatched)
+	t.Run("Adaptive", testHedgeAdaptive)
+}
@@ -9,3 +9,12 @@ type mockRequest struct {
 func (m *mockRequest) Entity() interface{} {
 	return m.Called().Get(0)
 }
+
+type mockComponenter struct {
+	mock.Mock
+}
+
+func (m *mockComponenter) Components() Components {
+	c, _ := m.Called().Get(0).(Components)
+	return c
+}
@@ -0,0 +1,289 @@
+package fanout
+
+import (
+	"context"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+)
+
+// Option represents a configuration option for a fanout endpoint created via New.
+type Option func(*fanoutOptions)
+
+// fanoutOptions is the internal configuration for a fanout endpoint
+type fanoutOptions struct {
+	shouldTerminate func(error) bool
+	partialSuccess  bool
+	selectors       []componentSelector
+	transform       func(string, interface{}) interface{}
+	accept          func(string, interface{}) bool
+	budget          time.Duration
+	reserve         time.Duration
+	listeners       []resultListener
+	pool            *WorkerPool
+	contextKeys     map[interface{}]bool
+	decorators      []SpanDecorator
+	shadows         shadows
+	hedge           *hedging
+	quorum          *quorum
+}
+
+// SpanDecorator produces the span for a component's Result.  The Result's Span field is the span recorded by the
+// fanout, named for the component.  A decorator may return that span as is, or may return a different span, e.g. one
+// created with tracing.Decorate, which renames the span or attaches extra data.  Returning nil leaves the span unchanged.
+type SpanDecorator func(Result) tracing.Span
+
+// resultListener is notified of each component's Result.  The context and fanout request
+// are the same as those used to select components.
+type resultListener func(context.Context, interface{}, Result)
+
+// ShouldTerminate supplies a predicate which is consulted each time a component returns an error.  If
+// the predicate returns true, the fanout returns immediately with that error instead of waiting on the
+// remaining components.  This is useful when certain errors, such as validation failures, would be returned
+// by every component anyway.
+//
+// If p is nil, this option does nothing.  By default, a fanout waits for all components to fail.
+func ShouldTerminate(p func(error) bool) Option {
+	return func(fo *fanoutOptions) {
+		if p != nil {
+			fo.shouldTerminate = p
+		}
+	}
+}
+
+// PartialSuccess configures a fanout to wait on all of its components rather than returning the first successful response.
+// The fanout response will be an *AggregateResponse that holds each component's response or error.  If the fanout's context
+// is canceled before all components finish, the unfinished components are recorded with the context's error.
+//
+// If every component fails, the fanout still returns an error just as it would without this option.  The ShouldTerminate
+// predicate, if supplied, is still honored.
+func PartialSuccess() Option {
+	return func(fo *fanoutOptions) {
+		fo.partialSuccess = true
+	}
+}
+
+// FanoutN configures a fanout to dispatch each request to only n of its components.  The components are chosen
+// by a consistent hash of the key returned by the given KeyFunc, so the same key is always sent to the same
+// subset of components.  This reduces load on downstream services while still preserving some redundancy.
+//
+// If n is nonpositive or key is nil, this option does nothing.  If n is at least the number of components,
+// all components are used.
+func FanoutN(n int, key KeyFunc) Option {
+	return func(fo *fanoutOptions) {
+		if n > 0 && key != nil {
+			fo.selectors = append(fo.selectors, func(ctx context.Context, v interface{}, components Components) Components {
+				return selectN(n, key(ctx, v), components)
+			})
+		}
+	}
+}
+
+// TransformRequest supplies a function which is invoked before each component endpoint to produce the request
+// passed to that component.  The function is passed the component's name along with the fanout request, and may
+// return a customized request for that component.  The fanout request available via FromContext is not affected.
+//
+// If t is nil, this option does nothing.  By default, every component receives the same fanout request.
+func TransformRequest(t func(componentName string, request interface{}) interface{}) Option {
+	return func(fo *fanoutOptions) {
+		if t != nil {
+			fo.transform = t
+		}
+	}
+}
+
+// AcceptResponse supplies a function which is invoked with each successful component response.  If the function
+// returns false, the response is treated as a failure and ErrResponseRejected is recorded as that component's error.
+// This allows the fanout to keep waiting for an authoritative answer when a component returns a response that
+// is technically successful but not useful, e.g. an empty result.
+//
+// If a is nil, this option does nothing.  By default, all successful component responses are accepted.
+func AcceptResponse(a func(componentName string, response interface{}) bool) Option {
+	return func(fo *fanoutOptions) {
+		if a != nil {
+			fo.accept = a
+		}
+	}
+}
+
+// DeadlineBudget configures a latency budget for a fanout.  If budget is positive, the entire fanout is given
+// a context that times out after the budget elapses, in addition to any deadline the caller's context already has.
+// If reserve is positive, each component is given a context whose deadline is the fanout's deadline minus the reserve.
+// This leaves the fanout time to produce its own response before the caller's deadline, and ensures that component
+// clients never run past that deadline.
+//
+// The reserve is applied whenever the fanout context has a deadline, whether that deadline came from the budget
+// or from the caller.  Nonpositive values for either budget or reserve disable that feature.  When either feature
+// is in effect, components that are still running when the fanout returns will see their contexts canceled.
+func DeadlineBudget(budget, reserve time.Duration) Option {
+	return func(fo *fanoutOptions) {
+		fo.budget = budget
+		fo.reserve = reserve
+	}
+}
+
+// Sticky configures a fanout to remember which component last succeeded for a request key.  For the given TTL
+// after a component succeeds, subsequent requests with the same key are sent exclusively to that component.  If that
+// component fails, it is forgotten for that key and the next request is sent to all components again.  This reduces
+// duplicate downstream traffic for chatty clients.
+//
+// Requests for which the KeyFunc returns an empty key are never sticky.  If key is nil or ttl is nonpositive,
+// this option does nothing.
+func Sticky(key KeyFunc, ttl time.Duration) Option {
+	return func(fo *fanoutOptions) {
+		if key != nil && ttl > 0 {
+			sr := newStickyRoutes(key, ttl, time.Now)
+			fo.selectors = append(fo.selectors, sr.selectComponents)
+			fo.listeners = append(fo.listeners, sr.onResult)
+		}
+	}
+}
+
+// Pool configures a fanout to execute its components using the given WorkerPool rather than spawning a goroutine
+// for each component.  The pool's OverflowPolicy determines what happens when no worker is available.  A component
+// that cannot be dispatched is recorded as a failure with ErrPoolFull, with ErrPoolStopped if the pool is not running
+// or is shut down before the component runs, or with ErrNotDispatched if the fanout context ends while waiting for a worker.
+//
+// The pool must be started separately, via its Run method.  If p is nil, this option does nothing.
+func Pool(p *WorkerPool) Option {
+	return func(fo *fanoutOptions) {
+		if p != nil {
+			fo.pool = p
+		}
+	}
+}
+
+// EvictUnhealthy enables tracking of each component's rolling failure rate.  Components whose failure rate exceeds
+// the policy's threshold are temporarily excluded from fanouts, then periodically probed so that they are restored once
+// they recover.  This allows a fanout to route around a dead backend.  The policy may be nil, in which case defaults are used.
+// Invalid settings in the policy are replaced with defaults, so callers should check the policy with Validate.
+func EvictUnhealthy(policy *HealthPolicy) Option {
+	return func(fo *fanoutOptions) {
+		ht := newHealthTracker(policy, time.Now)
+		fo.selectors = append(fo.selectors, ht.selectComponents)
+		fo.listeners = append(fo.listeners, ht.onResult)
+	}
+}
+
+// PropagateContext restricts the values that each component's context carries.  Without this option, components
+// see every value in the caller's context.  With this option, components only see the logger, the fanout request, and
+// the values for the given keys, e.g. authorization tokens or request identifiers.  Deadlines and cancellation are
+// unaffected.
+//
+// This option may be used multiple times, and the keys are cumulative.  Calling this option with no keys still
+// restricts component contexts to just the logger and fanout request.
+func PropagateContext(keys ...interface{}) Option {
+	return func(fo *fanoutOptions) {
+		if fo.contextKeys == nil {
+			fo.contextKeys = map[interface{}]bool{fanoutRequestKey{}: true}
+		}
+
+		for _, k := range keys {
+			fo.contextKeys[k] = true
+		}
+	}
+}
+
+// DecorateSpans adds a SpanDecorator which is applied to each component's span before the span is recorded
+// with the component's Result and merged into the fanout response or error.  Multiple decorators are applied
+// in the order the options are supplied.  If d is nil, this option does nothing.
+func DecorateSpans(d SpanDecorator) Option {
+	return func(fo *fanoutOptions) {
+		if d != nil {
+			fo.decorators = append(fo.decorators, d)
+		}
+	}
+}
+
+// Shadow configures components which receive every fanout request, but whose responses and errors never affect
+// the fanout's result.  This supports dark-launch testing of new backends.  Shadow components do not count toward
+// any of the fanout's selection options, and they are not subject to the fanout's deadline or cancellation.  If timeout
+// is positive, each shadow request is bounded by that timeout.
+//
+// The observer, which may be nil, is invoked with the Result of each shadow component, e.g. to compare shadow responses
+// against the primary components.  The observer is invoked from the shadow component's goroutine.  Shadow names should not
+// collide with the fanout's components.
+//
+// Multiple uses of this option replace earlier uses.  If components is empty, this option does nothing.
+func Shadow(components Components, timeout time.Duration, observer func(Result)) Option {
+	return func(fo *fanoutOptions) {
+		if len(components) > 0 {
+			copyOf := make(Components, len(components))
+			for k, v := range components {
+				copyOf[k] = v
+			}
+
+			fo.shadows = shadows{
+				components: copyOf,
+				timeout:    timeout,
+				observer:   observer,
+			}
+		}
+	}
+}
+
+// newFanoutOptions applies each option to a new fanoutOptions, which is initialized with the defaults
+func newFanoutOptions(o ...Option) *fanoutOptions {
+	fo := &fanoutOptions{
+		shouldTerminate: func(error) bool { return false },
+		transform:       func(_ string, v interface{}) interface{} { return v },
+		accept:          func(string, interface{}) bool { return true },
+	}
+
+	for _, option := range o {
+		option(fo)
+	}
+
+	return fo
+}
+
+// selectComponents applies each configured selector, in order, to produce the components that
+// will be used for a particular fanout request
+func (fo *fanoutOptions) selectComponents(ctx context.Context, v interface{}, components Components) Components {
+	for _, s := range fo.selectors {
+		components = s(ctx, v, components)
+	}
+
+	return components
+}
+
+// decorateSpan applies each configured SpanDecorator to a Result, returning the decorated span
+func (fo *fanoutOptions) decorateSpan(r Result) tracing.Span {
+	for _, d := range fo.decorators {
+		if s := d(r); s != nil {
+			r.Span = s
+		}
+	}
+
+	return r.Span
+}
+
+// notify dispatches a component's Result to each configured listener
+func (fo *fanoutOptions) notify(ctx context.Context, v interface{}, r Result) {
+	for _, l := range fo.listeners {
+		l(ctx, v, r)
+	}
+}
+
+// fanoutContext derives the context used for an entire fanout, applying any configured budget
+func (fo *fanoutOptions) fanoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if fo.budget > 0 {
+		return context.WithTimeout(ctx, fo.budget)
+	}
+
+	return ctx, func() {}
+}
+
+// componentContext derives the context passed to each component from the fanout context, applying any configured reserve
+// and restricting the context's values if PropagateContext was used
+func (fo *fanoutOptions) componentContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if fo.contextKeys != nil {
+		ctx = newFilteredContext(ctx, fo.contextKeys)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && fo.reserve > 0 {
+		return context.WithDeadline(ctx, deadline.Add(-fo.reserve))
+	}
+
+	return ctx, func() {}
+}
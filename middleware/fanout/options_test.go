@@ -0,0 +1,287 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testShouldTerminateNil(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		fo      = newFanoutOptions(ShouldTerminate(nil))
+	)
+
+	require.NotNil(fo.shouldTerminate)
+	assert.False(fo.shouldTerminate(errors.New("expected")))
+}
+
+func testShouldTerminateCustom(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		fo            = newFanoutOptions(ShouldTerminate(func(err error) bool { return err == expectedError }))
+	)
+
+	require.NotNil(fo.shouldTerminate)
+	assert.True(fo.shouldTerminate(expectedError))
+	assert.False(fo.shouldTerminate(errors.New("another error")))
+}
+
+func TestShouldTerminate(t *testing.T) {
+	t.Run("Nil", testShouldTerminateNil)
+	t.Run("Custom", testShouldTerminateCustom)
+}
+
+func TestPartialSuccess(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fo     = newFanoutOptions(PartialSuccess())
+	)
+
+	assert.True(fo.partialSuccess)
+}
+
+func TestFanoutN(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		key    = func(context.Context, interface{}) []byte { return []byte("key") }
+	)
+
+	assert.Empty(newFanoutOptions(FanoutN(0, key)).selectors)
+	assert.Empty(newFanoutOptions(FanoutN(-1, key)).selectors)
+	assert.Empty(newFanoutOptions(FanoutN(1, nil)).selectors)
+	assert.Len(newFanoutOptions(FanoutN(1, key)).selectors, 1)
+
+	var (
+		fo         = newFanoutOptions(FanoutN(1, key))
+		components = Components{"first": nil, "second": nil, "third": nil}
+	)
+
+	assert.Len(fo.selectComponents(context.Background(), "request", components), 1)
+	assert.Len(newFanoutOptions().selectComponents(context.Background(), "request", components), 3)
+}
+
+func TestTransformRequest(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	fo := newFanoutOptions(TransformRequest(nil))
+	require.NotNil(fo.transform)
+	assert.Equal("request", fo.transform("component", "request"))
+
+	fo = newFanoutOptions(TransformRequest(func(name string, v interface{}) interface{} {
+		return name + ":" + v.(string)
+	}))
+
+	require.NotNil(fo.transform)
+	assert.Equal("component:request", fo.transform("component", "request"))
+}
+
+func TestAcceptResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	fo := newFanoutOptions(AcceptResponse(nil))
+	require.NotNil(fo.accept)
+	assert.True(fo.accept("component", "response"))
+
+	fo = newFanoutOptions(AcceptResponse(func(name string, v interface{}) bool {
+		return v != nil
+	}))
+
+	require.NotNil(fo.accept)
+	assert.True(fo.accept("component", "response"))
+	assert.False(fo.accept("component", nil))
+}
+
+func testDeadlineBudgetDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fo     = newFanoutOptions()
+	)
+
+	fanoutCtx, cancel := fo.fanoutContext(context.Background())
+	defer cancel()
+	assert.Equal(context.Background(), fanoutCtx)
+
+	componentCtx, componentCancel := fo.componentContext(fanoutCtx)
+	defer componentCancel()
+	assert.Equal(context.Background(), componentCtx)
+}
+
+func testDeadlineBudgetBudgetAndReserve(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		fo      = newFanoutOptions(DeadlineBudget(time.Hour, 10*time.Minute))
+		start   = time.Now()
+	)
+
+	fanoutCtx, cancel := fo.fanoutContext(context.Background())
+	defer cancel()
+
+	fanoutDeadline, ok := fanoutCtx.Deadline()
+	require.True(ok)
+	assert.True(!fanoutDeadline.Before(start.Add(time.Hour)))
+
+	componentCtx, componentCancel := fo.componentContext(fanoutCtx)
+	defer componentCancel()
+
+	componentDeadline, ok := componentCtx.Deadline()
+	require.True(ok)
+	assert.Equal(fanoutDeadline.Add(-10*time.Minute), componentDeadline)
+}
+
+func testDeadlineBudgetReserveOnly(t *testing.T) {
+	var (
+		assert            = assert.New(t)
+		require           = require.New(t)
+		fo                = newFanoutOptions(DeadlineBudget(0, time.Minute))
+		callerDeadline    = time.Now().Add(time.Hour)
+		callerCtx, cancel = context.WithDeadline(context.Background(), callerDeadline)
+	)
+
+	defer cancel()
+
+	// with no budget, the caller's context is used as is for the fanout
+	fanoutCtx, fanoutCancel := fo.fanoutContext(callerCtx)
+	defer fanoutCancel()
+	assert.Equal(callerCtx, fanoutCtx)
+
+	componentCtx, componentCancel := fo.componentContext(fanoutCtx)
+	defer componentCancel()
+
+	componentDeadline, ok := componentCtx.Deadline()
+	require.True(ok)
+	assert.Equal(callerDeadline.Add(-time.Minute), componentDeadline)
+
+	// the reserve has no effect if there is no deadline
+	componentCtx, componentCancel = fo.componentContext(context.Background())
+	defer componentCancel()
+	_, ok = componentCtx.Deadline()
+	assert.False(ok)
+}
+
+func testDeadlineBudgetFanout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"component": func(ctx context.Context, _ interface{}) (interface{}, error) {
+					deadline, ok := ctx.Deadline()
+					assert.True(ok)
+					assert.True(deadline.Before(time.Now().Add(time.Hour)))
+					return tracing.NopMergeable{}, nil
+				},
+			},
+			DeadlineBudget(time.Hour, 30*time.Minute),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.NotNil(response)
+	assert.NoError(err)
+}
+
+func TestDeadlineBudget(t *testing.T) {
+	t.Run("Default", testDeadlineBudgetDefault)
+	t.Run("BudgetAndReserve", testDeadlineBudgetBudgetAndReserve)
+	t.Run("ReserveOnly", testDeadlineBudgetReserveOnly)
+	t.Run("Fanout", testDeadlineBudgetFanout)
+}
+
+func TestStickyOption(t *testing.T) {
+	assert := assert.New(t)
+
+	fo := newFanoutOptions(Sticky(nil, time.Minute))
+	assert.Empty(fo.selectors)
+	assert.Empty(fo.listeners)
+
+	fo = newFanoutOptions(Sticky(func(context.Context, interface{}) []byte { return nil }, 0))
+	assert.Empty(fo.selectors)
+	assert.Empty(fo.listeners)
+
+	fo = newFanoutOptions(Sticky(func(context.Context, interface{}) []byte { return nil }, time.Minute))
+	assert.Len(fo.selectors, 1)
+	assert.Len(fo.listeners, 1)
+}
+
+func TestPool(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		pool   = NewWorkerPool(1, 0, Block)
+	)
+
+	assert.Nil(newFanoutOptions(Pool(nil)).pool)
+	assert.Equal(pool, newFanoutOptions(Pool(pool)).pool)
+}
+
+func TestEvictUnhealthy(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fo     = newFanoutOptions(EvictUnhealthy(nil))
+	)
+
+	assert.Len(fo.selectors, 1)
+	assert.Len(fo.listeners, 1)
+}
+
+func TestDecorateSpans(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		original = tracing.NewSpanner().Start("component")(nil)
+		result   = Result{Name: "component", Span: original, Response: "response"}
+	)
+
+	assert.Empty(newFanoutOptions(DecorateSpans(nil)).decorators)
+	assert.Equal(original, newFanoutOptions().decorateSpan(result))
+
+	fo := newFanoutOptions(
+		DecorateSpans(func(r Result) tracing.Span {
+			return tracing.Decorate(r.Span, "renamed", map[string]interface{}{"response": r.Response})
+		}),
+		DecorateSpans(func(r Result) tracing.Span {
+			assert.Equal("renamed", r.Span.Name())
+			return nil
+		}),
+	)
+
+	assert.Len(fo.decorators, 2)
+	decorated := fo.decorateSpan(result)
+	assert.Equal("renamed", decorated.Name())
+	assert.Equal(map[string]interface{}{"response": "response"}, decorated.(tracing.Annotated).Data())
+}
+
+func TestNewFanoutOptions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		fo      = newFanoutOptions()
+	)
+
+	require.NotNil(fo)
+	require.NotNil(fo.shouldTerminate)
+	assert.False(fo.shouldTerminate(errors.New("expected")))
+	assert.False(fo.partialSuccess)
+	require.NotNil(fo.transform)
+	assert.Equal("request", fo.transform("component", "request"))
+	require.NotNil(fo.accept)
+	assert.True(fo.accept("component", nil))
+	assert.Nil(fo.pool)
+}
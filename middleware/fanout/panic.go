@@ -0,0 +1,43 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// PanicError is the error recorded for a component whose endpoint, TransformRequest, or AcceptResponse panicked.
+// The fanout recovers from such panics so that the remaining components can proceed.
+type PanicError struct {
+	// Component is the name of the component that panicked
+	Component string
+
+	// Value is the value passed to panic
+	Value interface{}
+
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("Component %s panicked: %v", pe.Component, pe.Value)
+}
+
+// invokeComponent calls a component endpoint, converting any panic into a *PanicError.  The component's
+// name is injected into the context passed to the endpoint.  See NameFromContext.
+func invokeComponent(ctx context.Context, name string, e endpoint.Endpoint, request interface{}) (response interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			response = nil
+			err = &PanicError{
+				Component: name,
+				Value:     r,
+				Stack:     debug.Stack(),
+			}
+		}
+	}()
+
+	return e(withName(ctx, name), request)
+}
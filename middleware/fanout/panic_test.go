@@ -0,0 +1,154 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		pe     = &PanicError{Component: "test", Value: "expected"}
+	)
+
+	assert.Equal("Component test panicked: expected", pe.Error())
+}
+
+func testInvokeComponentNoPanic(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	response, err := invokeComponent(context.Background(), "test", func(ctx context.Context, v interface{}) (interface{}, error) {
+		assert.Equal("request", v)
+		name, ok := NameFromContext(ctx)
+		assert.Equal("test", name)
+		assert.True(ok)
+		return "response", expectedError
+	}, "request")
+
+	assert.Equal("response", response)
+	assert.Equal(expectedError, err)
+}
+
+func testInvokeComponentPanic(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	response, err := invokeComponent(context.Background(), "test", func(context.Context, interface{}) (interface{}, error) {
+		panic("expected")
+	}, "request")
+
+	assert.Nil(response)
+	require.Error(err)
+
+	pe, ok := err.(*PanicError)
+	require.True(ok)
+	assert.Equal("test", pe.Component)
+	assert.Equal("expected", pe.Value)
+	assert.NotEmpty(pe.Stack)
+}
+
+func testInvokeComponentFanout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		panicked = make(chan struct{})
+		fanout   = New(
+			tracing.NewSpanner(),
+			Components{
+				"panic": func(context.Context, interface{}) (interface{}, error) {
+					defer close(panicked)
+					panic("expected")
+				},
+				"success": func(context.Context, interface{}) (interface{}, error) {
+					<-panicked
+					return tracing.NopMergeable{}, nil
+				},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.NoError(err)
+	require.NotNil(response)
+
+	for _, s := range response.(tracing.Spanned).Spans() {
+		if s.Name() == "panic" {
+			assert.IsType(&PanicError{}, s.Error())
+		} else {
+			assert.NoError(s.Error())
+		}
+	}
+}
+
+func testInvokeComponentHook(t *testing.T, hook Option) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"panic":   func(context.Context, interface{}) (interface{}, error) { return "panic", nil },
+				"success": func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil },
+			},
+			PartialSuccess(),
+			hook,
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(context.Background(), "request")
+	assert.NoError(err)
+	require.NotNil(response)
+
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 2)
+	for _, s := range spans {
+		if s.Name() == "panic" {
+			pe, ok := s.Error().(*PanicError)
+			require.True(ok)
+			assert.Equal("panic", pe.Component)
+			assert.Equal("expected", pe.Value)
+		} else {
+			assert.NoError(s.Error())
+		}
+	}
+}
+
+func TestInvokeComponent(t *testing.T) {
+	t.Run("NoPanic", testInvokeComponentNoPanic)
+	t.Run("Panic", testInvokeComponentPanic)
+	t.Run("Fanout", testInvokeComponentFanout)
+
+	t.Run("TransformRequest", func(t *testing.T) {
+		testInvokeComponentHook(t, TransformRequest(func(name string, v interface{}) interface{} {
+			if name == "panic" {
+				panic("expected")
+			}
+
+			return v
+		}))
+	})
+
+	t.Run("AcceptResponse", func(t *testing.T) {
+		testInvokeComponentHook(t, AcceptResponse(func(name string, response interface{}) bool {
+			if name == "panic" {
+				panic("expected")
+			}
+
+			return true
+		}))
+	})
+}
@@ -0,0 +1,166 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrPoolFull is the error recorded for a component that could not be dispatched because a WorkerPool
+	// with the FailFast policy had no capacity.
+	ErrPoolFull = errors.New("Fanout worker pool is full")
+
+	// ErrPoolStopped is the error recorded for a component that could not be executed because its WorkerPool
+	// was never started, or was shut down before a worker reached the component.
+	ErrPoolStopped = errors.New("Fanout worker pool is not running")
+)
+
+// OverflowPolicy describes what a WorkerPool does when all workers are busy and its queue is full
+type OverflowPolicy int
+
+const (
+	// Block waits until a worker is available or the fanout's context is canceled
+	Block OverflowPolicy = iota
+
+	// FailFast immediately fails components that cannot be queued, using ErrPoolFull
+	FailFast
+)
+
+// poolTask is a queued component.  Exactly one of run or abandon is invoked for each task.
+type poolTask struct {
+	run     func()
+	abandon func(error)
+}
+
+// WorkerPool is a fixed set of goroutines which execute fanout components.  Using a WorkerPool, via the Pool option,
+// allows high-throughput services to avoid spawning a goroutine for each component of each request.
+//
+// A WorkerPool implements concurrent.Runnable.  Its workers are not started until Run is called, and the workers exit
+// when the shutdown channel is closed.  Components still queued at shutdown are failed with ErrPoolStopped, as are
+// components submitted before Run or after shutdown.  A single WorkerPool may be shared among any number of fanouts.
+type WorkerPool struct {
+	workers int
+	policy  OverflowPolicy
+	tasks   chan poolTask
+	once    sync.Once
+
+	lock       sync.Mutex
+	running    bool
+	stopped    chan struct{}
+	submitting sync.WaitGroup
+}
+
+// NewWorkerPool creates a WorkerPool with the given number of workers.  The queueSize is the number of components
+// that may be waiting on a worker, and may be zero.  If workers is nonpositive or queueSize is negative, this function
+// panics.
+func NewWorkerPool(workers, queueSize int, policy OverflowPolicy) *WorkerPool {
+	if workers < 1 {
+		panic("workers must be positive")
+	}
+
+	if queueSize < 0 {
+		panic("queueSize cannot be negative")
+	}
+
+	return &WorkerPool{
+		workers: workers,
+		policy:  policy,
+		tasks:   make(chan poolTask, queueSize),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Run starts this pool's workers.  This method is idempotent, and always returns nil.  Once the shutdown channel
+// is closed, this pool cannot be started again.
+func (wp *WorkerPool) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	wp.once.Do(func() {
+		wp.lock.Lock()
+		wp.running = true
+		wp.lock.Unlock()
+
+		waitGroup.Add(wp.workers + 1)
+		for w := 0; w < wp.workers; w++ {
+			go func() {
+				defer waitGroup.Done()
+				for {
+					// prefer shutdown, so that no new task is started once it has been signaled
+					select {
+					case <-shutdown:
+						return
+					default:
+					}
+
+					select {
+					case <-shutdown:
+						return
+					case task := <-wp.tasks:
+						task.run()
+					}
+				}
+			}()
+		}
+
+		go func() {
+			defer waitGroup.Done()
+			<-shutdown
+			wp.stop()
+		}()
+	})
+
+	return nil
+}
+
+// stop rejects further submissions, then abandons any tasks left in the queue
+func (wp *WorkerPool) stop() {
+	wp.lock.Lock()
+	wp.running = false
+	close(wp.stopped)
+	wp.lock.Unlock()
+
+	// no task can be queued once the submissions in progress have finished
+	wp.submitting.Wait()
+	for {
+		select {
+		case task := <-wp.tasks:
+			task.abandon(ErrPoolStopped)
+		default:
+			return
+		}
+	}
+}
+
+// submit hands a task to this pool's workers, applying the overflow policy if no worker or queue slot is available.
+// If the task is accepted but this pool is shut down before a worker reaches it, abandon is invoked instead of task.
+// If this pool is not running, ErrPoolStopped is returned immediately.
+func (wp *WorkerPool) submit(ctx context.Context, task func(), abandon func(error)) error {
+	wp.lock.Lock()
+	if !wp.running {
+		wp.lock.Unlock()
+		return ErrPoolStopped
+	}
+
+	wp.submitting.Add(1)
+	wp.lock.Unlock()
+	defer wp.submitting.Done()
+
+	pt := poolTask{run: task, abandon: abandon}
+	select {
+	case wp.tasks <- pt:
+		return nil
+	default:
+	}
+
+	if wp.policy == FailFast {
+		return ErrPoolFull
+	}
+
+	select {
+	case wp.tasks <- pt:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wp.stopped:
+		return ErrPoolStopped
+	}
+}
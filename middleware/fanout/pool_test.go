@@ -0,0 +1,299 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewWorkerPoolInvalid(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewWorkerPool(0, 1, Block) })
+	assert.Panics(func() { NewWorkerPool(-1, 1, Block) })
+	assert.Panics(func() { NewWorkerPool(1, -1, Block) })
+}
+
+func testWorkerPoolRun(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pool    = NewWorkerPool(2, 0, Block)
+
+		waitGroup, shutdown, err = concurrent.Execute(pool)
+	)
+
+	require.NoError(err)
+
+	// Run is idempotent
+	assert.NoError(pool.Run(waitGroup, shutdown))
+
+	executed := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		i := i
+		require.NoError(pool.submit(context.Background(), func() { executed <- i }, func(error) { assert.Fail("No task should be abandoned") }))
+	}
+
+	total := 0
+	for i := 0; i < 10; i++ {
+		total += <-executed
+	}
+
+	assert.Equal(45, total)
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+// startBusyPool starts a single-worker pool whose worker is occupied until the returned release channel is closed
+func startBusyPool(t *testing.T, queueSize int, policy OverflowPolicy) (*WorkerPool, *sync.WaitGroup, chan struct{}, chan struct{}) {
+	var (
+		require = require.New(t)
+		pool    = NewWorkerPool(1, queueSize, policy)
+		started = make(chan struct{})
+		release = make(chan struct{})
+
+		waitGroup, shutdown, err = concurrent.Execute(pool)
+	)
+
+	require.NoError(err)
+
+	// with FailFast and no queue, the task is only accepted once the worker is waiting for one
+	busy := func() { close(started); <-release }
+	for err = pool.submit(context.Background(), busy, func(error) {}); err == ErrPoolFull; err = pool.submit(context.Background(), busy, func(error) {}) {
+		runtime.Gosched()
+	}
+
+	require.NoError(err)
+	<-started
+	return pool, waitGroup, shutdown, release
+}
+
+func testWorkerPoolFailFast(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pool, waitGroup, shutdown, release = startBusyPool(t, 1, FailFast)
+	)
+
+	defer func() {
+		close(release)
+		close(shutdown)
+		waitGroup.Wait()
+	}()
+
+	// with the only worker busy, only the single queue slot is available
+	require.NoError(pool.submit(context.Background(), func() {}, func(error) {}))
+	assert.Equal(ErrPoolFull, pool.submit(context.Background(), func() {}, func(error) {}))
+}
+
+func testWorkerPoolBlockCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+
+		pool, waitGroup, shutdown, release = startBusyPool(t, 0, Block)
+	)
+
+	defer func() {
+		close(release)
+		close(shutdown)
+		waitGroup.Wait()
+	}()
+
+	cancel()
+	assert.Equal(context.Canceled, pool.submit(ctx, func() {}, func(error) {}))
+}
+
+func testWorkerPoolNotRunning(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pool    = NewWorkerPool(1, 1, Block)
+	)
+
+	// a pool that was never started fails fast rather than blocking or queueing
+	assert.Equal(ErrPoolStopped, pool.submit(context.Background(), func() {}, func(error) {}))
+
+	waitGroup, shutdown, err := concurrent.Execute(pool)
+	require.NoError(err)
+	close(shutdown)
+	waitGroup.Wait()
+
+	assert.Equal(ErrPoolStopped, pool.submit(context.Background(), func() {}, func(error) {}))
+}
+
+func testWorkerPoolShutdown(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		abandoned = make(chan error, 2)
+
+		pool, waitGroup, shutdown, release = startBusyPool(t, 2, Block)
+	)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(pool.submit(
+			context.Background(),
+			func() { assert.Fail("A queued task should not run after shutdown") },
+			func(err error) { abandoned <- err },
+		))
+	}
+
+	// a submitter blocked on the full queue is released by the shutdown
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- pool.submit(context.Background(), func() {}, func(error) {})
+	}()
+
+	close(shutdown)
+	close(release)
+	waitGroup.Wait()
+
+	assert.Equal(ErrPoolStopped, <-abandoned)
+	assert.Equal(ErrPoolStopped, <-abandoned)
+	assert.Equal(ErrPoolStopped, <-blocked)
+}
+
+func testWorkerPoolFanout(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		pool     = NewWorkerPool(2, 2, Block)
+		failure  = errors.New("expected")
+		lock     sync.Mutex
+		executed = 0
+
+		waitGroup, shutdown, err = concurrent.Execute(pool)
+	)
+
+	require.NoError(err)
+	defer func() {
+		close(shutdown)
+		waitGroup.Wait()
+	}()
+
+	components := make(Components)
+	for _, name := range []string{"first", "second", "third"} {
+		components[name] = func(context.Context, interface{}) (interface{}, error) {
+			lock.Lock()
+			executed++
+			lock.Unlock()
+			return nil, failure
+		}
+	}
+
+	fanout := New(tracing.NewSpanner(), components, Pool(pool))
+	require.NotNil(fanout)
+
+	_, err = fanout(context.Background(), "request")
+	require.Error(err)
+	assert.Len(err.(tracing.SpanError).Spans(), 3)
+
+	lock.Lock()
+	assert.Equal(3, executed)
+	lock.Unlock()
+}
+
+func testWorkerPoolFanoutFull(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// the pool's only worker stays busy, and there is no queue
+		pool, waitGroup, shutdown, release = startBusyPool(t, 0, FailFast)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"component": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("The component should not have been called")
+					return nil, nil
+				},
+			},
+			Pool(pool),
+		)
+	)
+
+	defer func() {
+		close(release)
+		close(shutdown)
+		waitGroup.Wait()
+	}()
+
+	require.NotNil(fanout)
+	_, err := fanout(context.Background(), "request")
+	require.Error(err)
+
+	spanError := err.(tracing.SpanError)
+	assert.Equal(ErrPoolFull, spanError.Err())
+	require.Len(spanError.Spans(), 1)
+	assert.Equal(ErrPoolFull, spanError.Spans()[0].Error())
+}
+
+func testWorkerPoolFanoutStopped(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pool, waitGroup, shutdown, release = startBusyPool(t, 1, Block)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"component": func(context.Context, interface{}) (interface{}, error) {
+					assert.Fail("The component should not have been called")
+					return nil, nil
+				},
+			},
+			Pool(pool),
+		)
+
+		result = make(chan error, 1)
+	)
+
+	require.NotNil(fanout)
+
+	// the component is queued behind the busy worker, and is failed by the shutdown rather than stranded
+	go func() {
+		_, err := fanout(context.Background(), "request")
+		result <- err
+	}()
+
+	for len(pool.tasks) == 0 {
+		runtime.Gosched()
+	}
+
+	close(shutdown)
+	close(release)
+	waitGroup.Wait()
+
+	select {
+	case err := <-result:
+		require.Error(err)
+		spanError := err.(tracing.SpanError)
+		require.Len(spanError.Spans(), 1)
+		assert.Equal(ErrPoolStopped, spanError.Spans()[0].Error())
+	case <-time.After(5 * time.Second):
+		assert.Fail("The fanout did not return after its pool was shut down")
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	t.Run("Invalid", testNewWorkerPoolInvalid)
+	t.Run("Run", testWorkerPoolRun)
+	t.Run("FailFast", testWorkerPoolFailFast)
+	t.Run("BlockCanceled", testWorkerPoolBlockCanceled)
+	t.Run("NotRunning", testWorkerPoolNotRunning)
+	t.Run("Shutdown", testWorkerPoolShutdown)
+	t.Run("Fanout", testWorkerPoolFanout)
+	t.Run("FanoutFull", testWorkerPoolFanoutFull)
+	t.Run("FanoutStopped", testWorkerPoolFanoutStopped)
+}
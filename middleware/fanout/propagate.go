@@ -0,0 +1,31 @@
+package fanout
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/logging"
+)
+
+// filteredContext is a context.Context which exposes only certain values from its parent.  Deadlines,
+// cancellation, and errors are still those of the parent.
+type filteredContext struct {
+	context.Context
+	keys map[interface{}]bool
+}
+
+func (fc *filteredContext) Value(key interface{}) interface{} {
+	if fc.keys[key] {
+		return fc.Context.Value(key)
+	}
+
+	return nil
+}
+
+// newFilteredContext produces a context which only carries the values for the given keys from its parent,
+// in addition to the logger and fanout request which are always propagated.
+func newFilteredContext(parent context.Context, keys map[interface{}]bool) context.Context {
+	return logging.WithLogger(
+		&filteredContext{Context: parent, keys: keys},
+		logging.Logger(parent),
+	)
+}
@@ -0,0 +1,100 @@
+package fanout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type propagateTestKey string
+
+func TestNewFilteredContext(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		logger      = logging.NewTestLogger(nil, t)
+		ctx, cancel = context.WithCancel(
+			context.WithValue(
+				context.WithValue(
+					logging.WithLogger(context.Background(), logger),
+					propagateTestKey("allowed"), "allowed value",
+				),
+				propagateTestKey("denied"), "denied value",
+			),
+		)
+
+		filtered = newFilteredContext(ctx, map[interface{}]bool{propagateTestKey("allowed"): true})
+	)
+
+	assert.Equal(logger, logging.Logger(filtered))
+	assert.Equal("allowed value", filtered.Value(propagateTestKey("allowed")))
+	assert.Nil(filtered.Value(propagateTestKey("denied")))
+
+	assert.NoError(filtered.Err())
+	cancel()
+	<-filtered.Done()
+	assert.Equal(context.Canceled, filtered.Err())
+}
+
+func testPropagateContextDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"component": func(ctx context.Context, v interface{}) (interface{}, error) {
+					assert.Equal("value", ctx.Value(propagateTestKey("key")))
+					return tracing.NopMergeable{}, nil
+				},
+			},
+		)
+	)
+
+	require.NotNil(fanout)
+	_, err := fanout(context.WithValue(context.Background(), propagateTestKey("key"), "value"), "request")
+	assert.NoError(err)
+}
+
+func testPropagateContextWhitelist(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"component": func(ctx context.Context, v interface{}) (interface{}, error) {
+					assert.Equal(logger, logging.Logger(ctx))
+					assert.Equal("request", FromContext(ctx))
+					assert.Equal("first value", ctx.Value(propagateTestKey("first")))
+					assert.Equal("second value", ctx.Value(propagateTestKey("second")))
+					assert.Nil(ctx.Value(propagateTestKey("denied")))
+					return tracing.NopMergeable{}, nil
+				},
+			},
+			PropagateContext(propagateTestKey("first")),
+			PropagateContext(propagateTestKey("second")),
+		)
+
+		ctx = logging.WithLogger(context.Background(), logger)
+	)
+
+	ctx = context.WithValue(ctx, propagateTestKey("first"), "first value")
+	ctx = context.WithValue(ctx, propagateTestKey("second"), "second value")
+	ctx = context.WithValue(ctx, propagateTestKey("denied"), "denied value")
+
+	require.NotNil(fanout)
+	_, err := fanout(ctx, "request")
+	assert.NoError(err)
+}
+
+func TestPropagateContext(t *testing.T) {
+	t.Run("Default", testPropagateContextDefault)
+	t.Run("Whitelist", testPropagateContextWhitelist)
+}
@@ -0,0 +1,88 @@
+package fanout
+
+import "errors"
+
+// ErrNoQuorum is returned by a fanout configured with Quorum when the successful component responses
+// did not agree in sufficient numbers.
+var ErrNoQuorum = errors.New("Component responses did not reach a quorum")
+
+// quorum is the configuration for consensus among component responses
+type quorum struct {
+	size        int
+	fingerprint func(interface{}) string
+}
+
+// Quorum configures a fanout to compare the successful responses of its components, and to return only once at
+// least size components have returned equivalent responses.  Two responses are considered equivalent if the
+// fingerprint function returns the same value for each.  The fanout returns the first of the agreeing responses.
+//
+// If the components finish, or enough components fail or disagree that a quorum is no longer possible, the fanout returns
+// ErrNoQuorum.  If every component fails, the fanout returns an error just as it would without this option.  If size is
+// nonpositive, a simple majority of the components selected for each request is required.
+//
+// This option takes precedence over PartialSuccess.  If fingerprint is nil, this option does nothing.
+func Quorum(size int, fingerprint func(response interface{}) string) Option {
+	return func(fo *fanoutOptions) {
+		if fingerprint != nil {
+			fo.quorum = &quorum{size: size, fingerprint: fingerprint}
+		}
+	}
+}
+
+// ballot tallies component responses for a single fanout request
+type ballot struct {
+	size      int
+	remaining int
+	counts    map[string]int
+	first     map[string]interface{}
+	highest   int
+}
+
+// newBallot creates a ballot for the given number of components
+func (q *quorum) newBallot(components int) *ballot {
+	size := q.size
+	if size <= 0 {
+		size = components/2 + 1
+	}
+
+	return &ballot{
+		size:      size,
+		remaining: components,
+		counts:    make(map[string]int, components),
+		first:     make(map[string]interface{}, components),
+	}
+}
+
+// fail records a component that did not produce a response
+func (b *ballot) fail() {
+	b.remaining--
+}
+
+// vote records a component's response.  If a quorum has been reached, the first agreeing response is returned along with true.
+func (b *ballot) vote(fingerprint string, response interface{}) (interface{}, bool) {
+	b.remaining--
+	b.counts[fingerprint]++
+	if _, ok := b.first[fingerprint]; !ok {
+		b.first[fingerprint] = response
+	}
+
+	if b.counts[fingerprint] > b.highest {
+		b.highest = b.counts[fingerprint]
+	}
+
+	if b.counts[fingerprint] >= b.size {
+		return b.first[fingerprint], true
+	}
+
+	return nil, false
+}
+
+// impossible tests if a quorum can no longer be reached with the outstanding components
+func (b *ballot) impossible() bool {
+	return b.highest+b.remaining < b.size
+}
+
+// voted tests if any component produced a response
+func (b *ballot) voted() bool {
+	return len(b.counts) > 0
+}
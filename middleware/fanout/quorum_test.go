@@ -0,0 +1,120 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBallot(t *testing.T) {
+	t.Run("Majority", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			b      = (&quorum{}).newBallot(4)
+		)
+
+		assert.Equal(3, b.size)
+		assert.False(b.voted())
+		assert.False(b.impossible())
+
+		response, ok := b.vote("a", "first a")
+		assert.Nil(response)
+		assert.False(ok)
+
+		response, ok = b.vote("b", "first b")
+		assert.Nil(response)
+		assert.False(ok)
+		assert.False(b.impossible())
+
+		response, ok = b.vote("a", "second a")
+		assert.Nil(response)
+		assert.False(ok)
+		assert.False(b.impossible())
+
+		b.fail()
+		assert.True(b.voted())
+		assert.True(b.impossible())
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			b      = (&quorum{size: 2}).newBallot(5)
+		)
+
+		assert.Equal(2, b.size)
+
+		response, ok := b.vote("a", "first a")
+		assert.Nil(response)
+		assert.False(ok)
+
+		response, ok = b.vote("a", "second a")
+		assert.Equal("first a", response)
+		assert.True(ok)
+	})
+}
+
+func TestQuorum(t *testing.T) {
+	var (
+		fingerprint = func(v interface{}) string { return fmt.Sprint(v) }
+		respond     = func(response interface{}) func(context.Context, interface{}) (interface{}, error) {
+			return func(context.Context, interface{}) (interface{}, error) { return response, nil }
+		}
+	)
+
+	t.Run("Nil", func(t *testing.T) {
+		assert.Nil(t, newFanoutOptions(Quorum(2, nil)).quorum)
+	})
+
+	t.Run("Agree", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			fanout = New(
+				tracing.NewSpanner(),
+				Components{"first": respond("agreed"), "second": respond("agreed"), "third": respond("agreed")},
+				Quorum(0, fingerprint),
+			)
+		)
+
+		response, err := fanout(context.Background(), "request")
+		assert.Equal("agreed", response)
+		assert.NoError(err)
+	})
+
+	t.Run("Disagree", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			fanout  = New(
+				tracing.NewSpanner(),
+				Components{"first": respond("one"), "second": respond("two"), "third": respond("three")},
+				Quorum(2, fingerprint),
+			)
+		)
+
+		response, err := fanout(context.Background(), "request")
+		assert.Nil(response)
+		require.Error(err)
+		assert.Equal(ErrNoQuorum, err.(tracing.SpanError).Err())
+	})
+
+	t.Run("AllFailed", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			require       = require.New(t)
+			expectedError = errors.New("expected")
+			failure       = func(context.Context, interface{}) (interface{}, error) { return nil, expectedError }
+			fanout        = New(tracing.NewSpanner(), Components{"first": failure, "second": failure}, Quorum(0, fingerprint))
+		)
+
+		response, err := fanout(context.Background(), "request")
+		assert.Nil(response)
+		require.Error(err)
+		assert.Equal(expectedError, err.(tracing.SpanError).Err())
+	})
+}
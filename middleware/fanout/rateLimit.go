@@ -0,0 +1,87 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ErrRateLimited is the error returned by a component endpoint decorated with RateLimit when the component's
+// rate limit has been exceeded.  This error will appear in the component's span.
+var ErrRateLimited = errors.New("Component rate limit exceeded")
+
+// tokenBucket is a simple, nonblocking token bucket
+type tokenBucket struct {
+	lock     sync.Mutex
+	now      func() time.Time
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	return &tokenBucket{
+		now:      now,
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     now(),
+	}
+}
+
+// take attempts to remove a token from this bucket, returning false if no token is available
+func (tb *tokenBucket) take() bool {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	now := tb.now()
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens = math.Min(tb.capacity, tb.tokens+elapsed.Seconds()*tb.rate)
+	}
+
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+
+	tb.tokens--
+	return true
+}
+
+// RateLimit produces a middleware that applies a token bucket rate limit to an endpoint.  The rate is the number
+// of requests per second allowed on average, while burst is the maximum number of requests allowed at once.  When
+// no token is available, the decorated endpoint returns ErrRateLimited immediately without invoking the component.
+//
+// Each endpoint decorated by the returned middleware gets its own token bucket.  This means that Components.Apply
+// can be used to give each component a distinct limit.
+//
+// If rate is nonpositive or burst is nonpositive, this factory function panics.
+func RateLimit(rate float64, burst int) endpoint.Middleware {
+	return rateLimit(rate, burst, time.Now)
+}
+
+func rateLimit(rate float64, burst int, now func() time.Time) endpoint.Middleware {
+	if rate <= 0 {
+		panic("rate must be positive")
+	}
+
+	if burst < 1 {
+		panic("burst must be positive")
+	}
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		bucket := newTokenBucket(rate, burst, now)
+		return func(ctx context.Context, value interface{}) (interface{}, error) {
+			if !bucket.take() {
+				return nil, ErrRateLimited
+			}
+
+			return next(ctx, value)
+		}
+	}
+}
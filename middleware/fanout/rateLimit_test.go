@@ -0,0 +1,113 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRateLimitInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { RateLimit(0, 1) })
+	assert.Panics(func() { RateLimit(-1.0, 1) })
+	assert.Panics(func() { RateLimit(1.0, 0) })
+	assert.Panics(func() { RateLimit(1.0, -1) })
+}
+
+func testRateLimitTokens(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		current = time.Now()
+		now     = func() time.Time { return current }
+
+		nextCount = 0
+		next      = func(context.Context, interface{}) (interface{}, error) {
+			nextCount++
+			return "response", nil
+		}
+
+		limited = rateLimit(2.0, 2, now)(next)
+	)
+
+	require.NotNil(limited)
+
+	// the initial burst is available immediately
+	for repeat := 0; repeat < 2; repeat++ {
+		response, err := limited(context.Background(), "request")
+		assert.Equal("response", response)
+		assert.NoError(err)
+	}
+
+	response, err := limited(context.Background(), "request")
+	assert.Nil(response)
+	assert.Equal(ErrRateLimited, err)
+	assert.Equal(2, nextCount)
+
+	// at 2 tokens per second, half a second yields one more token
+	current = current.Add(500 * time.Millisecond)
+	response, err = limited(context.Background(), "request")
+	assert.Equal("response", response)
+	assert.NoError(err)
+
+	response, err = limited(context.Background(), "request")
+	assert.Nil(response)
+	assert.Equal(ErrRateLimited, err)
+
+	// the bucket never holds more than the burst
+	current = current.Add(time.Hour)
+	for repeat := 0; repeat < 2; repeat++ {
+		_, err := limited(context.Background(), "request")
+		assert.NoError(err)
+	}
+
+	_, err = limited(context.Background(), "request")
+	assert.Equal(ErrRateLimited, err)
+	assert.Equal(5, nextCount)
+}
+
+func testRateLimitFanout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		failure = errors.New("expected")
+
+		components = Components{
+			"first":  func(context.Context, interface{}) (interface{}, error) { return nil, failure },
+			"second": func(context.Context, interface{}) (interface{}, error) { return nil, failure },
+		}
+
+		fanout = New(tracing.NewSpanner(), components.Apply(RateLimit(0.0001, 1)))
+	)
+
+	require.NotNil(fanout)
+
+	_, err := fanout(context.Background(), "request")
+	require.Error(err)
+	for _, s := range err.(tracing.SpanError).Spans() {
+		assert.Equal(failure, s.Error())
+	}
+
+	// each component has its own bucket, and each bucket is now empty
+	_, err = fanout(context.Background(), "request")
+	require.Error(err)
+	assert.Equal(ErrRateLimited, err.(tracing.SpanError).Err())
+	spans := err.(tracing.SpanError).Spans()
+	assert.Len(spans, 2)
+	for _, s := range spans {
+		assert.Equal(ErrRateLimited, s.Error())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("Invalid", testRateLimitInvalid)
+	t.Run("Tokens", testRateLimitTokens)
+	t.Run("Fanout", testRateLimitFanout)
+}
@@ -0,0 +1,103 @@
+package fanout
+
+import (
+	"context"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log/level"
+)
+
+// detachedContext carries the values of its parent, but none of its parent's deadline or cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (dc detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (dc detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (dc detachedContext) Err() error {
+	return nil
+}
+
+func (dc detachedContext) Value(key interface{}) interface{} {
+	return dc.parent.Value(key)
+}
+
+// shadows holds the configuration for shadow components
+type shadows struct {
+	components Components
+	timeout    time.Duration
+	observer   func(Result)
+}
+
+// withoutShadows is an Option which removes any shadow components configured by earlier options
+func withoutShadows() Option {
+	return func(fo *fanoutOptions) {
+		fo.shadows = shadows{}
+	}
+}
+
+// shadowContext produces the context for shadow components.  The result is detached from the fanout's
+// cancellation, so that shadows can finish even after the fanout returns.
+func (fo *fanoutOptions) shadowContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = detachedContext{parent: ctx}
+	if fo.contextKeys != nil {
+		ctx = newFilteredContext(ctx, fo.contextKeys)
+	}
+
+	if fo.shadows.timeout > 0 {
+		return context.WithTimeout(ctx, fo.shadows.timeout)
+	}
+
+	return ctx, func() {}
+}
+
+// shadow asynchronously invokes each shadow component.  Results from shadows are logged and passed to the
+// observer, if one was configured, but otherwise have no effect on the fanout.
+func (d *dispatcher) shadow(ctx context.Context, v interface{}) {
+	if len(d.options.shadows.components) == 0 {
+		return
+	}
+
+	shadowCtx, cancel := d.options.shadowContext(ctx)
+	remaining := len(d.options.shadows.components)
+	done := make(chan struct{}, remaining)
+
+	for name, e := range d.options.shadows.components {
+		go func(name string, e endpoint.Endpoint) {
+			defer func() { done <- struct{}{} }()
+
+			var (
+				logger                 = logging.Logger(shadowCtx)
+				finisher               = d.spanner.Start(name)
+				componentResponse, err = invokeComponent(shadowCtx, name, e, d.options.transform(name, v))
+				r                      = Result{Name: name, Span: finisher(err), Response: componentResponse, Err: err}
+			)
+
+			if err != nil {
+				logger.Log(level.Key(), level.DebugValue(), "shadow", name, logging.ErrorKey(), err, logging.MessageKey(), "shadow failed")
+			} else {
+				logger.Log(level.Key(), level.DebugValue(), "shadow", name, logging.MessageKey(), "shadow success")
+			}
+
+			if d.options.shadows.observer != nil {
+				d.options.shadows.observer(r)
+			}
+		}(name, e)
+	}
+
+	// release the shadow context's resources once all shadows are finished
+	go func() {
+		defer cancel()
+		for ; remaining > 0; remaining-- {
+			<-done
+		}
+	}()
+}
@@ -0,0 +1,127 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type shadowTestKey struct{}
+
+func TestDetachedContext(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithTimeout(context.WithValue(context.Background(), shadowTestKey{}, "value"), time.Hour)
+		detached    = detachedContext{parent: ctx}
+	)
+
+	cancel()
+	<-ctx.Done()
+
+	_, ok := detached.Deadline()
+	assert.False(ok)
+	assert.Nil(detached.Done())
+	assert.NoError(detached.Err())
+	assert.Equal("value", detached.Value(shadowTestKey{}))
+}
+
+func testShadowOption(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		shadow = func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	)
+
+	assert.Empty(newFanoutOptions(Shadow(nil, time.Minute, nil)).shadows.components)
+
+	var (
+		original = Components{"shadow": shadow}
+		fo       = newFanoutOptions(Shadow(original, time.Minute, nil))
+	)
+
+	assert.Len(fo.shadows.components, 1)
+	assert.Equal(time.Minute, fo.shadows.timeout)
+
+	// the components must be copied
+	delete(original, "shadow")
+	assert.Len(fo.shadows.components, 1)
+
+	shadowCtx, cancel := fo.shadowContext(context.Background())
+	defer cancel()
+	_, ok := shadowCtx.Deadline()
+	assert.True(ok)
+
+	shadowCtx, cancel = newFanoutOptions(Shadow(Components{"shadow": shadow}, 0, nil)).shadowContext(context.Background())
+	defer cancel()
+	_, ok = shadowCtx.Deadline()
+	assert.False(ok)
+}
+
+func testShadowFanout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx, cancel    = context.WithCancel(context.WithValue(context.Background(), shadowTestKey{}, "value"))
+		primaryDone    = make(chan struct{})
+		shadowResults  = make(chan Result, 2)
+		shadowObserved = func(r Result) { shadowResults <- r }
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"primary": func(context.Context, interface{}) (interface{}, error) {
+					defer close(primaryDone)
+					return tracing.NopMergeable{}, nil
+				},
+			},
+			Shadow(
+				Components{
+					"shadow-success": func(ctx context.Context, v interface{}) (interface{}, error) {
+						<-primaryDone
+						assert.Equal("value", ctx.Value(shadowTestKey{}))
+						assert.Equal("request", FromContext(ctx))
+						return "shadow response", nil
+					},
+					"shadow-failure": func(ctx context.Context, v interface{}) (interface{}, error) {
+						<-primaryDone
+						return nil, errors.New("expected")
+					},
+				},
+				0,
+				shadowObserved,
+			),
+		)
+	)
+
+	require.NotNil(fanout)
+	response, err := fanout(ctx, "request")
+	require.NoError(err)
+
+	// shadows never show up in the fanout's spans
+	spans := response.(tracing.Spanned).Spans()
+	require.Len(spans, 1)
+	assert.Equal("primary", spans[0].Name())
+
+	// canceling the caller's context does not affect shadows
+	cancel()
+
+	results := make(map[string]Result)
+	for i := 0; i < 2; i++ {
+		r := <-shadowResults
+		results[r.Name] = r
+	}
+
+	assert.Equal("shadow response", results["shadow-success"].Response)
+	assert.NoError(results["shadow-success"].Err)
+	assert.Error(results["shadow-failure"].Err)
+}
+
+func TestShadow(t *testing.T) {
+	t.Run("Option", testShadowOption)
+	t.Run("Fanout", testShadowFanout)
+}
@@ -0,0 +1,111 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// stickyEntry records the component that last succeeded for a key
+type stickyEntry struct {
+	name    string
+	expires time.Time
+}
+
+// stickyRoutes is a TTL cache of request keys to the components that last succeeded for those keys
+type stickyRoutes struct {
+	lock      sync.Mutex
+	key       KeyFunc
+	ttl       time.Duration
+	now       func() time.Time
+	entries   map[string]stickyEntry
+	lastSweep time.Time
+}
+
+func newStickyRoutes(key KeyFunc, ttl time.Duration, now func() time.Time) *stickyRoutes {
+	return &stickyRoutes{
+		key:       key,
+		ttl:       ttl,
+		now:       now,
+		entries:   make(map[string]stickyEntry),
+		lastSweep: now(),
+	}
+}
+
+// get returns the unexpired component name for a key, if one exists
+func (sr *stickyRoutes) get(key string) (string, bool) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	entry, ok := sr.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	if !sr.now().Before(entry.expires) {
+		delete(sr.entries, key)
+		return "", false
+	}
+
+	return entry.name, true
+}
+
+// put records the component that succeeded for a key, sweeping expired entries at most once per TTL
+func (sr *stickyRoutes) put(key, name string) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	now := sr.now()
+	sr.entries[key] = stickyEntry{name: name, expires: now.Add(sr.ttl)}
+
+	if now.Sub(sr.lastSweep) >= sr.ttl {
+		for k, e := range sr.entries {
+			if !now.Before(e.expires) {
+				delete(sr.entries, k)
+			}
+		}
+
+		sr.lastSweep = now
+	}
+}
+
+// remove deletes the entry for a key, but only if that entry refers to the given component
+func (sr *stickyRoutes) remove(key, name string) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	if entry, ok := sr.entries[key]; ok && entry.name == name {
+		delete(sr.entries, key)
+	}
+}
+
+// selectComponents restricts the components to the sticky component for the request's key, if there is one
+func (sr *stickyRoutes) selectComponents(ctx context.Context, v interface{}, components Components) Components {
+	key := sr.key(ctx, v)
+	if len(key) == 0 {
+		return components
+	}
+
+	if name, ok := sr.get(string(key)); ok {
+		if e, ok := components[name]; ok {
+			return Components{name: e}
+		}
+	}
+
+	return components
+}
+
+// onResult records successful components for the request's key.  A failure of the sticky component
+// removes that component's entry, so that the next request for the key is sent to all components.
+func (sr *stickyRoutes) onResult(ctx context.Context, v interface{}, r Result) {
+	key := sr.key(ctx, v)
+	if len(key) == 0 {
+		return
+	}
+
+	if r.Err == nil {
+		sr.put(string(key), r.Name)
+	} else {
+		sr.remove(string(key), r.Name)
+	}
+}
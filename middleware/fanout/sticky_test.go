@@ -0,0 +1,148 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringKey(_ context.Context, v interface{}) []byte {
+	return []byte(v.(string))
+}
+
+func testStickyRoutesExpiry(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		current = time.Now()
+		sr      = newStickyRoutes(stringKey, time.Minute, func() time.Time { return current })
+	)
+
+	name, ok := sr.get("key")
+	assert.Empty(name)
+	assert.False(ok)
+
+	sr.put("key", "component")
+	name, ok = sr.get("key")
+	assert.Equal("component", name)
+	assert.True(ok)
+
+	current = current.Add(time.Minute)
+	name, ok = sr.get("key")
+	assert.Empty(name)
+	assert.False(ok)
+	assert.Empty(sr.entries)
+}
+
+func testStickyRoutesSweep(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		current = time.Now()
+		sr      = newStickyRoutes(stringKey, time.Minute, func() time.Time { return current })
+	)
+
+	sr.put("first", "component")
+	current = current.Add(30 * time.Second)
+	sr.put("second", "component")
+	assert.Len(sr.entries, 2)
+
+	current = current.Add(45 * time.Second)
+	sr.put("third", "component")
+	assert.Len(sr.entries, 2)
+	_, ok := sr.entries["first"]
+	assert.False(ok)
+}
+
+func testStickyRoutesRemove(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		sr     = newStickyRoutes(stringKey, time.Minute, time.Now)
+	)
+
+	sr.put("key", "component")
+	sr.remove("key", "another component")
+	_, ok := sr.get("key")
+	assert.True(ok)
+
+	sr.remove("key", "component")
+	_, ok = sr.get("key")
+	assert.False(ok)
+}
+
+func testStickyRoutesSelectComponents(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		sr         = newStickyRoutes(stringKey, time.Minute, time.Now)
+		components = Components{"first": nil, "second": nil}
+	)
+
+	assert.Equal(components, sr.selectComponents(context.Background(), "key", components))
+
+	sr.onResult(context.Background(), "key", Result{Name: "second"})
+	assert.Equal(Components{"second": nil}, sr.selectComponents(context.Background(), "key", components))
+
+	// an empty key is never sticky
+	sr.onResult(context.Background(), "", Result{Name: "first"})
+	assert.Equal(components, sr.selectComponents(context.Background(), "", components))
+
+	// a sticky component that is no longer configured is ignored
+	assert.Equal(Components{"first": nil}, sr.selectComponents(context.Background(), "key", Components{"first": nil}))
+
+	sr.onResult(context.Background(), "key", Result{Name: "second", Err: errors.New("expected")})
+	assert.Equal(components, sr.selectComponents(context.Background(), "key", components))
+}
+
+func testStickyFanout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lock   sync.Mutex
+		called = make(map[string]int)
+
+		fanout = New(
+			tracing.NewSpanner(),
+			Components{
+				"first": func(context.Context, interface{}) (interface{}, error) {
+					lock.Lock()
+					called["first"]++
+					lock.Unlock()
+					return tracing.NopMergeable{}, nil
+				},
+				"second": func(context.Context, interface{}) (interface{}, error) {
+					lock.Lock()
+					called["second"]++
+					lock.Unlock()
+					return nil, errors.New("expected")
+				},
+			},
+			Sticky(stringKey, time.Hour),
+		)
+	)
+
+	require.NotNil(fanout)
+
+	for repeat := 0; repeat < 10; repeat++ {
+		response, err := fanout(context.Background(), "key")
+		assert.NotNil(response)
+		assert.NoError(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(10, called["first"])
+	assert.True(called["second"] < 10)
+}
+
+func TestSticky(t *testing.T) {
+	t.Run("Expiry", testStickyRoutesExpiry)
+	t.Run("Sweep", testStickyRoutesSweep)
+	t.Run("Remove", testStickyRoutesRemove)
+	t.Run("SelectComponents", testStickyRoutesSelectComponents)
+	t.Run("Fanout", testStickyFanout)
+}
@@ -0,0 +1,74 @@
+package fanout
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Stream is a fanout variant which produces the result of each component as it arrives, rather than
+// a single merged response.  The returned channel receives at most one Result per component and is closed
+// once every component has been accounted for or the stream has terminated.
+type Stream func(context.Context, interface{}) <-chan Result
+
+// NewStream produces a Stream which invokes all of a set of component endpoints concurrently, just as with New.
+// Each component's Result is sent on the returned channel as soon as that component finishes.  This is useful for
+// handlers that send partial results to clients, such as chunked or server-sent event responses.
+//
+// If the context is canceled before all components finish, a Result with the context's error and no span is sent
+// for each unfinished component.  If the ShouldTerminate predicate returns true for a component's error, that
+// component's Result is sent and the channel is closed without waiting on the remaining components.  The
+// PartialSuccess option has no effect on a Stream.
+//
+// If spanner is nil or endpoints is empty, this function panics.
+func NewStream(spanner tracing.Spanner, endpoints Components, o ...Option) Stream {
+	d := newDispatcher(spanner, endpoints, o...)
+	return func(ctx context.Context, v interface{}) <-chan Result {
+		ctx, cancel := d.options.fanoutContext(NewContext(ctx, v))
+		componentCtx, componentCancel := d.options.componentContext(ctx)
+
+		var (
+			logger              = logging.Logger(ctx)
+			components, results = d.dispatch(ctx, componentCtx, v)
+			stream              = make(chan Result, len(components))
+		)
+
+		go func() {
+			defer cancel()
+			defer componentCancel()
+			defer close(stream)
+
+			finished := make(map[string]bool, len(components))
+			for r := 0; r < len(components); r++ {
+				select {
+				case <-ctx.Done():
+					logger.Log(level.Key(), level.WarnValue(), logging.ErrorKey(), ctx.Err(), logging.MessageKey(), "timed out")
+					for name := range components {
+						if !finished[name] {
+							stream <- Result{Name: name, Err: ctx.Err()}
+						}
+					}
+
+					return
+				case fr := <-results:
+					finished[fr.Name] = true
+					stream <- fr
+
+					if fr.Err != nil {
+						logger.Log(level.Key(), level.DebugValue(), "service", fr.Name, logging.ErrorKey(), fr.Err, logging.MessageKey(), "failed")
+						if d.options.shouldTerminate(fr.Err) {
+							logger.Log(level.Key(), level.ErrorValue(), "service", fr.Name, logging.ErrorKey(), fr.Err, logging.MessageKey(), "terminating fanout")
+							return
+						}
+					} else {
+						logger.Log(level.Key(), level.DebugValue(), "service", fr.Name, logging.MessageKey(), "success")
+					}
+				}
+			}
+		}()
+
+		return stream
+	}
+}
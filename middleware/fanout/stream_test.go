@@ -0,0 +1,162 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewStreamNilSpanner(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() {
+		NewStream(nil, Components{"test": func(context.Context, interface{}) (interface{}, error) { return nil, nil }})
+	})
+}
+
+func testNewStreamNoConfiguredEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	for _, empty := range []Components{nil, {}} {
+		assert.Panics(func() {
+			NewStream(tracing.NewSpanner(), empty)
+		})
+	}
+}
+
+func testNewStreamAllFinish(t *testing.T, serviceCount int) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		components    = make(Components, serviceCount)
+	)
+
+	for i := 0; i < serviceCount; i++ {
+		name := fmt.Sprintf("component-%d", i)
+		if i%2 == 0 {
+			components[name] = func(ctx context.Context, v interface{}) (interface{}, error) {
+				assert.Equal("request", FromContext(ctx))
+				return name, nil
+			}
+		} else {
+			components[name] = func(ctx context.Context, v interface{}) (interface{}, error) {
+				assert.Equal("request", FromContext(ctx))
+				return nil, expectedError
+			}
+		}
+	}
+
+	stream := NewStream(tracing.NewSpanner(), components)
+	require.NotNil(stream)
+
+	results := make(map[string]Result)
+	for r := range stream(context.Background(), "request") {
+		_, duplicate := results[r.Name]
+		assert.False(duplicate)
+		results[r.Name] = r
+	}
+
+	require.Len(results, serviceCount)
+	for name, r := range results {
+		require.NotNil(r.Span)
+		assert.Equal(name, r.Span.Name())
+		if r.Err == nil {
+			assert.Equal(name, r.Response)
+		} else {
+			assert.Equal(expectedError, r.Err)
+			assert.Nil(r.Response)
+		}
+	}
+}
+
+func testNewStreamTimeout(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+		slowGate    = make(chan struct{})
+
+		stream = NewStream(
+			tracing.NewSpanner(),
+			Components{
+				"fast": func(context.Context, interface{}) (interface{}, error) { return "fast", nil },
+				"slow": func(context.Context, interface{}) (interface{}, error) {
+					<-slowGate
+					return "slow", nil
+				},
+			},
+		)
+	)
+
+	defer close(slowGate)
+	require.NotNil(stream)
+
+	var (
+		results = stream(ctx, "request")
+		first   = <-results
+	)
+
+	// the slow component is blocked, so the fast component is always first
+	assert.Equal("fast", first.Name)
+	cancel()
+
+	second, ok := <-results
+	require.True(ok)
+	assert.Equal(Result{Name: "slow", Err: context.Canceled}, second)
+
+	_, ok = <-results
+	assert.False(ok)
+}
+
+func testNewStreamShouldTerminate(t *testing.T) {
+	var (
+		assert           = assert.New(t)
+		require          = require.New(t)
+		terminatingError = errors.New("terminate")
+		slowGate         = make(chan struct{})
+
+		stream = NewStream(
+			tracing.NewSpanner(),
+			Components{
+				"terminate": func(context.Context, interface{}) (interface{}, error) { return nil, terminatingError },
+				"slow": func(context.Context, interface{}) (interface{}, error) {
+					<-slowGate
+					return "slow", nil
+				},
+			},
+			ShouldTerminate(func(err error) bool { return err == terminatingError }),
+		)
+	)
+
+	defer close(slowGate)
+	require.NotNil(stream)
+
+	results := stream(context.Background(), "request")
+	first, ok := <-results
+	require.True(ok)
+	assert.Equal("terminate", first.Name)
+	assert.Equal(terminatingError, first.Err)
+
+	_, ok = <-results
+	assert.False(ok)
+}
+
+func TestNewStream(t *testing.T) {
+	t.Run("NilSpanner", testNewStreamNilSpanner)
+	t.Run("NoConfiguredEndpoints", testNewStreamNoConfiguredEndpoints)
+
+	t.Run("AllFinish", func(t *testing.T) {
+		for c := 1; c <= 5; c++ {
+			t.Run(fmt.Sprintf("EndpointCount=%d", c), func(t *testing.T) {
+				testNewStreamAllFinish(t, c)
+			})
+		}
+	})
+
+	t.Run("Timeout", testNewStreamTimeout)
+	t.Run("ShouldTerminate", testNewStreamShouldTerminate)
+}
@@ -0,0 +1,57 @@
+package fanout
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+)
+
+// KeyFunc extracts a key from a fanout request.  The context will have the fanout request set
+// via NewContext, and the value is the fanout request itself.
+type KeyFunc func(context.Context, interface{}) []byte
+
+// componentSelector narrows the set of components that a fanout will dispatch to for a given request
+type componentSelector func(context.Context, interface{}, Components) Components
+
+// weightedName is a component name together with its rendezvous hash weight for a particular key
+type weightedName struct {
+	name   string
+	weight uint64
+}
+
+// rendezvousWeight computes the highest-random-weight hash of a component name for a given key
+func rendezvousWeight(key []byte, name string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write(key)
+	hasher.Write([]byte(name))
+	return hasher.Sum64()
+}
+
+// selectN uses rendezvous hashing to choose n of the given components for a key.  The same key will always
+// select the same components, and adding or removing a component only affects keys that hashed to that component.
+// If n is greater than or equal to the number of components, components is returned as is.
+func selectN(n int, key []byte, components Components) Components {
+	if n >= len(components) {
+		return components
+	}
+
+	weighted := make([]weightedName, 0, len(components))
+	for name := range components {
+		weighted = append(weighted, weightedName{name: name, weight: rendezvousWeight(key, name)})
+	}
+
+	sort.Slice(weighted, func(i, j int) bool {
+		if weighted[i].weight == weighted[j].weight {
+			return weighted[i].name < weighted[j].name
+		}
+
+		return weighted[i].weight > weighted[j].weight
+	})
+
+	selected := make(Components, n)
+	for _, wn := range weighted[:n] {
+		selected[wn.name] = components[wn.name]
+	}
+
+	return selected
+}
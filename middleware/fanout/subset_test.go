@@ -0,0 +1,119 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSelectN(t *testing.T, n, componentCount int) {
+	var (
+		assert     = assert.New(t)
+		components = make(Components, componentCount)
+	)
+
+	for i := 0; i < componentCount; i++ {
+		components[fmt.Sprintf("component-%d", i)] = nil
+	}
+
+	for _, key := range []string{"", "key1", "mac:112233445566", "another key"} {
+		selected := selectN(n, []byte(key), components)
+		if n >= componentCount {
+			assert.Len(selected, componentCount)
+		} else {
+			assert.Len(selected, n)
+		}
+
+		for name := range selected {
+			_, ok := components[name]
+			assert.True(ok)
+		}
+
+		// the selection must be consistent for the same key
+		assert.Equal(selected, selectN(n, []byte(key), components))
+	}
+}
+
+func testSelectNConsistency(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		components = make(Components, 10)
+		key        = []byte("consistent key")
+	)
+
+	for i := 0; i < 10; i++ {
+		components[fmt.Sprintf("component-%d", i)] = nil
+	}
+
+	selected := selectN(3, key, components)
+
+	// removing a component that was not selected should not change the selection
+	for name := range components {
+		if _, ok := selected[name]; !ok {
+			delete(components, name)
+			break
+		}
+	}
+
+	assert.Equal(selected, selectN(3, key, components))
+}
+
+func TestSelectN(t *testing.T) {
+	for _, componentCount := range []int{1, 2, 5} {
+		for n := 1; n <= componentCount+1; n++ {
+			t.Run(fmt.Sprintf("N=%d,M=%d", n, componentCount), func(t *testing.T) {
+				testSelectN(t, n, componentCount)
+			})
+		}
+	}
+
+	t.Run("Consistency", testSelectNConsistency)
+}
+
+func TestNewFanoutN(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lock   sync.Mutex
+		called = make(map[string]int)
+
+		components = make(Components, 5)
+	)
+
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("component-%d", i)
+		components[name] = func(ctx context.Context, v interface{}) (interface{}, error) {
+			lock.Lock()
+			called[name]++
+			lock.Unlock()
+			return nil, fmt.Errorf("expected failure from %s", name)
+		}
+	}
+
+	fanout := New(
+		tracing.NewSpanner(),
+		components,
+		FanoutN(2, func(_ context.Context, v interface{}) []byte { return []byte(v.(string)) }),
+	)
+
+	require.NotNil(fanout)
+
+	for repeat := 0; repeat < 3; repeat++ {
+		response, err := fanout(context.Background(), "request key")
+		assert.Nil(response)
+		require.Error(err)
+		assert.Len(err.(tracing.SpanError).Spans(), 2)
+	}
+
+	// the same two components must have been called each time
+	assert.Len(called, 2)
+	for _, count := range called {
+		assert.Equal(3, count)
+	}
+}
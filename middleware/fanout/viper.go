@@ -0,0 +1,266 @@
+package fanout
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/spf13/viper"
+)
+
+const (
+	// FanoutKey is the Viper subkey under which fanout configuration is expected.
+	// FromViper *does not* assume this key.
+	FanoutKey = "fanout"
+)
+
+// HedgeOptions configures hedged dispatch of components.  See Hedge and AdaptiveHedge.
+type HedgeOptions struct {
+	// Delay is the time to wait on a component before starting the next one.  If nonpositive, components are not hedged.
+	Delay time.Duration `json:"delay"`
+
+	// Adaptive indicates that components are ordered and delayed by their average latencies, as with AdaptiveHedge
+	Adaptive bool `json:"adaptive"`
+
+	// Alpha is the smoothing factor for adaptive hedging.  If not set, DefaultHedgeAlpha is used.
+	Alpha float64 `json:"alpha"`
+}
+
+// QuorumOptions configures consensus among component responses.  See Quorum.
+type QuorumOptions struct {
+	// Size is the number of equivalent responses required.  If nonpositive, a simple majority is required.
+	Size int `json:"size"`
+}
+
+// PoolOptions configures a WorkerPool used to execute components.  See Pool.
+type PoolOptions struct {
+	// Workers is the number of goroutines in the pool, which must be positive
+	Workers int `json:"workers"`
+
+	// QueueSize is the number of components that may wait on a worker
+	QueueSize int `json:"queueSize"`
+
+	// FailFast selects the FailFast overflow policy.  If not set, the Block policy is used.
+	FailFast bool `json:"failFast"`
+}
+
+// Options is the configurable, serializable subset of fanout behavior.  Features which require code,
+// such as predicates and key functions, are either supplied through the unserialized fields of this struct
+// or as Option values.
+type Options struct {
+	// PartialSuccess enables the PartialSuccess option
+	PartialSuccess bool `json:"partialSuccess"`
+
+	// Budget is the overall latency budget for a fanout.  See DeadlineBudget.
+	Budget time.Duration `json:"budget"`
+
+	// Reserve is the time reserved from the fanout deadline when computing each component's deadline.  See DeadlineBudget.
+	Reserve time.Duration `json:"reserve"`
+
+	// Health, if set, enables health tracking and eviction of components using this policy.  See EvictUnhealthy.
+	Health *HealthPolicy `json:"health,omitempty"`
+
+	// Shadows are the names of components which should be treated as shadows rather than primary components.  See Shadow.
+	Shadows []string `json:"shadows,omitempty"`
+
+	// ShadowTimeout is the timeout for each shadow component request
+	ShadowTimeout time.Duration `json:"shadowTimeout"`
+
+	// Hedge, if set, starts components one at a time.  See Hedge and AdaptiveHedge.
+	Hedge *HedgeOptions `json:"hedge,omitempty"`
+
+	// Quorum, if set, requires agreement among component responses.  Fingerprint must also be set.  See Quorum.
+	Quorum *QuorumOptions `json:"quorum,omitempty"`
+
+	// Fingerprint is the function used to compare responses when a Quorum is configured
+	Fingerprint func(response interface{}) string `json:"-"`
+
+	// FanoutN, if positive, dispatches each request to only this many components.  Key must also be set.  See FanoutN.
+	FanoutN int `json:"fanoutN"`
+
+	// Key is the function used to select components when FanoutN is configured
+	Key KeyFunc `json:"-"`
+
+	// Pool, if set, executes components on a WorkerPool rather than a goroutine apiece.  Shutdown must also be set.
+	// See Pool.
+	Pool *PoolOptions `json:"pool,omitempty"`
+
+	// Shutdown stops the workers of the configured Pool when closed.  Once it is closed, components of fanouts using
+	// the pool fail with ErrPoolStopped.
+	Shutdown <-chan struct{} `json:"-"`
+
+	// WaitGroup, if set, tracks the workers of the configured Pool, so that callers can wait for them to exit
+	// after closing Shutdown
+	WaitGroup *sync.WaitGroup `json:"-"`
+}
+
+// newWorkerPool creates the WorkerPool described by these options, and runs it until shutdown is closed
+func (po *PoolOptions) newWorkerPool(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) (*WorkerPool, error) {
+	if shutdown == nil {
+		return nil, errors.New("A pool requires a Shutdown channel")
+	}
+
+	if po.Workers < 1 {
+		return nil, fmt.Errorf("Pool workers must be positive: %d", po.Workers)
+	}
+
+	if po.QueueSize < 0 {
+		return nil, fmt.Errorf("Pool queueSize cannot be negative: %d", po.QueueSize)
+	}
+
+	policy := Block
+	if po.FailFast {
+		policy = FailFast
+	}
+
+	if waitGroup == nil {
+		waitGroup = new(sync.WaitGroup)
+	}
+
+	wp := NewWorkerPool(po.Workers, po.QueueSize, policy)
+	wp.Run(waitGroup, shutdown)
+	return wp, nil
+}
+
+// FanoutOptions produces the slice of Option values described by this configuration.  The components are used to
+// separate the configured shadow components from the primary components.  The returned Components are the primary components.
+//
+// If the Health policy is invalid, if any configured shadow does not exist in components, or if a Quorum or FanoutN
+// is configured without its corresponding function, an error is returned.  If a Pool is configured, a new WorkerPool is created and run until
+// Shutdown is closed each time this method is called.  A Pool without a Shutdown channel is an error, as its workers
+// could never be stopped.
+func (o *Options) FanoutOptions(components Components) (Components, []Option, error) {
+	if o == nil {
+		return components, nil, nil
+	}
+
+	var options []Option
+	if o.PartialSuccess {
+		options = append(options, PartialSuccess())
+	}
+
+	if o.Budget > 0 || o.Reserve > 0 {
+		options = append(options, DeadlineBudget(o.Budget, o.Reserve))
+	}
+
+	if o.Health != nil {
+		if err := o.Health.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		options = append(options, EvictUnhealthy(o.Health))
+	}
+
+	if h := o.Hedge; h != nil {
+		if h.Adaptive {
+			options = append(options, AdaptiveHedge(h.Delay, h.Alpha))
+		} else {
+			options = append(options, Hedge(h.Delay))
+		}
+	}
+
+	if o.Quorum != nil {
+		if o.Fingerprint == nil {
+			return nil, nil, errors.New("A quorum requires a Fingerprint")
+		}
+
+		options = append(options, Quorum(o.Quorum.Size, o.Fingerprint))
+	}
+
+	if o.FanoutN > 0 {
+		if o.Key == nil {
+			return nil, nil, errors.New("FanoutN requires a Key")
+		}
+
+		options = append(options, FanoutN(o.FanoutN, o.Key))
+	}
+
+	if o.Pool != nil {
+		wp, err := o.Pool.newWorkerPool(o.WaitGroup, o.Shutdown)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		options = append(options, Pool(wp))
+	}
+
+	if len(o.Shadows) > 0 {
+		var (
+			primaries = make(Components, len(components))
+			shadows   = make(Components, len(o.Shadows))
+		)
+
+		for k, v := range components {
+			primaries[k] = v
+		}
+
+		for _, name := range o.Shadows {
+			e, ok := primaries[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("No such shadow component: %s", name)
+			}
+
+			shadows[name] = e
+			delete(primaries, name)
+		}
+
+		components = primaries
+		options = append(options, Shadow(shadows, o.ShadowTimeout, nil))
+	}
+
+	return components, options, nil
+}
+
+// Sub returns the standard child Viper, using FanoutKey, for this package.
+// If passed nil, this function returns nil.
+func Sub(v *viper.Viper) *viper.Viper {
+	if v != nil {
+		return v.Sub(FanoutKey)
+	}
+
+	return nil
+}
+
+// FromViper produces an Options from a (possibly nil) Viper instance.
+// Callers should use FromViper(Sub(v)) if the standard subkey is desired.
+func FromViper(v *viper.Viper) (*Options, error) {
+	o := new(Options)
+	if v != nil {
+		if err := v.Unmarshal(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// NewFromViper constructs a fanout endpoint, via New, using the configuration in the given Viper instance.  A default
+// tracing.Spanner is used.  The extra options are applied after those from the configuration, and may be used to supply
+// features that cannot be configured, such as ShouldTerminate.  Features which need functions or channels, such as
+// Quorum and Pool, can only be configured through FanoutOptions.
+//
+// The components are supplied by the caller.  To build HTTP components, their clients, and metrics from configured
+// URLs as well, use fanouthttp.FromViper and the NewHandler method of the resulting options.
+//
+// Callers should use NewFromViper(Sub(v), ...) if the standard subkey is desired.  The Viper instance may be nil,
+// in which case only the extra options are used.
+func NewFromViper(v *viper.Viper, components Components, extra ...Option) (endpoint.Endpoint, error) {
+	o, err := FromViper(v)
+	if err != nil {
+		return nil, err
+	}
+
+	primaries, options, err := o.FanoutOptions(components)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(primaries) == 0 {
+		return nil, ErrNoComponents
+	}
+
+	return New(tracing.NewSpanner(), primaries, append(options, extra...)...), nil
+}
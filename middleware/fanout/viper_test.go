@@ -0,0 +1,256 @@
+package fanout
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestViper(t *testing.T, configuration string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(configuration)))
+	return v
+}
+
+func TestSub(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	assert.Nil(Sub(nil))
+	assert.Nil(Sub(viper.New()))
+
+	child := Sub(newTestViper(t, `{"fanout": {"partialSuccess": true}}`))
+	require.NotNil(child)
+	assert.True(child.GetBool("partialSuccess"))
+}
+
+func TestFromViper(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o, err = FromViper(nil)
+		)
+
+		assert.Equal(new(Options), o)
+		assert.NoError(err)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			o, err  = FromViper(newTestViper(t, `{
+				"partialSuccess": true,
+				"budget": "10s",
+				"reserve": "500ms",
+				"health": {
+					"window": 10,
+					"threshold": 0.75,
+					"evictionPeriod": "1m"
+				},
+				"shadows": ["shadow"],
+				"shadowTimeout": "2s",
+				"hedge": {
+					"delay": "50ms",
+					"adaptive": true,
+					"alpha": 0.5
+				},
+				"quorum": {
+					"size": 2
+				},
+				"fanoutN": 3,
+				"pool": {
+					"workers": 10,
+					"queueSize": 100,
+					"failFast": true
+				}
+			}`))
+		)
+
+		require.NoError(err)
+		require.NotNil(o)
+		assert.True(o.PartialSuccess)
+		assert.Equal(10*time.Second, o.Budget)
+		assert.Equal(500*time.Millisecond, o.Reserve)
+		assert.Equal(&HealthPolicy{Window: 10, Threshold: 0.75, EvictionPeriod: time.Minute}, o.Health)
+		assert.Equal([]string{"shadow"}, o.Shadows)
+		assert.Equal(2*time.Second, o.ShadowTimeout)
+		assert.Equal(&HedgeOptions{Delay: 50 * time.Millisecond, Adaptive: true, Alpha: 0.5}, o.Hedge)
+		assert.Equal(&QuorumOptions{Size: 2}, o.Quorum)
+		assert.Equal(3, o.FanoutN)
+		assert.Equal(&PoolOptions{Workers: 10, QueueSize: 100, FailFast: true}, o.Pool)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o, err = FromViper(newTestViper(t, `{"budget": "this is not a duration"}`))
+		)
+
+		assert.Nil(o)
+		assert.Error(err)
+	})
+}
+
+func TestOptionsFanoutOptions(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		success    = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+		components = Components{"primary": success, "shadow": success}
+	)
+
+	primaries, options, err := (*Options)(nil).FanoutOptions(components)
+	assert.Equal(components, primaries)
+	assert.Empty(options)
+	assert.NoError(err)
+
+	primaries, options, err = new(Options).FanoutOptions(components)
+	assert.Equal(components, primaries)
+	assert.Empty(options)
+	assert.NoError(err)
+
+	o := &Options{
+		PartialSuccess: true,
+		Budget:         time.Minute,
+		Health:         new(HealthPolicy),
+		Shadows:        []string{"shadow"},
+	}
+
+	primaries, options, err = o.FanoutOptions(components)
+	require.NoError(err)
+	assert.Len(primaries, 1)
+	assert.Contains(primaries, "primary")
+	assert.Len(components, 2)
+
+	fo := newFanoutOptions(options...)
+	assert.True(fo.partialSuccess)
+	assert.Equal(time.Minute, fo.budget)
+	assert.Len(fo.selectors, 1)
+	assert.Len(fo.shadows.components, 1)
+	assert.Contains(fo.shadows.components, "shadow")
+
+	_, _, err = (&Options{Shadows: []string{"nosuch"}}).FanoutOptions(components)
+	assert.Error(err)
+
+	_, _, err = (&Options{Health: &HealthPolicy{Threshold: -1.0}}).FanoutOptions(components)
+	assert.Error(err)
+}
+
+func TestOptionsFanoutOptionsStrategy(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		success     = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+		components  = Components{"first": success, "second": success}
+		fingerprint = func(interface{}) string { return "" }
+		key         = func(context.Context, interface{}) []byte { return nil }
+	)
+
+	_, options, err := (&Options{Hedge: &HedgeOptions{Delay: time.Second}}).FanoutOptions(components)
+	require.NoError(err)
+	fo := newFanoutOptions(options...)
+	require.NotNil(fo.hedge)
+	assert.Equal(time.Second, fo.hedge.delay)
+	assert.Nil(fo.hedge.latencies)
+
+	_, options, err = (&Options{Hedge: &HedgeOptions{Delay: time.Second, Adaptive: true, Alpha: 0.5}}).FanoutOptions(components)
+	require.NoError(err)
+	fo = newFanoutOptions(options...)
+	require.NotNil(fo.hedge)
+	require.NotNil(fo.hedge.latencies)
+	assert.Equal(0.5, fo.hedge.latencies.alpha)
+
+	_, options, err = (&Options{Quorum: &QuorumOptions{Size: 2}, Fingerprint: fingerprint}).FanoutOptions(components)
+	require.NoError(err)
+	fo = newFanoutOptions(options...)
+	require.NotNil(fo.quorum)
+	assert.Equal(2, fo.quorum.size)
+
+	_, _, err = (&Options{Quorum: new(QuorumOptions)}).FanoutOptions(components)
+	assert.Error(err)
+
+	_, options, err = (&Options{FanoutN: 1, Key: key}).FanoutOptions(components)
+	require.NoError(err)
+	assert.Len(newFanoutOptions(options...).selectors, 1)
+
+	_, _, err = (&Options{FanoutN: 1}).FanoutOptions(components)
+	assert.Error(err)
+
+	var (
+		shutdown  = make(chan struct{})
+		waitGroup = new(sync.WaitGroup)
+	)
+
+	_, options, err = (&Options{Pool: &PoolOptions{Workers: 2, QueueSize: 2, FailFast: true}, Shutdown: shutdown, WaitGroup: waitGroup}).FanoutOptions(components)
+	require.NoError(err)
+	fo = newFanoutOptions(options...)
+	require.NotNil(fo.pool)
+	assert.Equal(FailFast, fo.pool.policy)
+
+	// the configured pool is already running
+	response, err := New(tracing.NewSpanner(), components, options...)(context.Background(), "request")
+	assert.NotNil(response)
+	assert.NoError(err)
+
+	// closing Shutdown stops the configured pool's workers
+	close(shutdown)
+	waitGroup.Wait()
+	_, err = New(tracing.NewSpanner(), components, options...)(context.Background(), "request")
+	assert.Error(err)
+
+	_, _, err = (&Options{Pool: &PoolOptions{Workers: 1}}).FanoutOptions(components)
+	assert.Error(err)
+
+	_, _, err = (&Options{Pool: new(PoolOptions), Shutdown: shutdown}).FanoutOptions(components)
+	assert.Error(err)
+
+	_, _, err = (&Options{Pool: &PoolOptions{Workers: 1, QueueSize: -1}, Shutdown: shutdown}).FanoutOptions(components)
+	assert.Error(err)
+}
+
+func TestNewFromViper(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		success = func(context.Context, interface{}) (interface{}, error) { return tracing.NopMergeable{}, nil }
+	)
+
+	fanout, err := NewFromViper(nil, Components{"primary": success})
+	require.NoError(err)
+	require.NotNil(fanout)
+
+	response, err := fanout(context.Background(), "request")
+	assert.NotNil(response)
+	assert.NoError(err)
+
+	fanout, err = NewFromViper(newTestViper(t, `{"partialSuccess": true}`), Components{"primary": success})
+	require.NoError(err)
+	require.NotNil(fanout)
+
+	response, err = fanout(context.Background(), "request")
+	assert.IsType(&AggregateResponse{}, response)
+	assert.NoError(err)
+
+	fanout, err = NewFromViper(newTestViper(t, `{"budget": "bad"}`), Components{"primary": success})
+	assert.Nil(fanout)
+	assert.Error(err)
+
+	fanout, err = NewFromViper(newTestViper(t, `{"shadows": ["nosuch"]}`), Components{"primary": success})
+	assert.Nil(fanout)
+	assert.Error(err)
+
+	fanout, err = NewFromViper(newTestViper(t, `{"shadows": ["primary"]}`), Components{"primary": success})
+	assert.Nil(fanout)
+	assert.Equal(ErrNoComponents, err)
+}
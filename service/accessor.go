@@ -1,7 +1,12 @@
 package service
 
 import (
+	"errors"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/billhathaway/consistentHash"
@@ -9,8 +14,29 @@ import (
 
 const (
 	DefaultVNodeCount = 211
+
+	// CRC32Hash is the name of the IEEE CRC-32 hash function, for use with NewConsistentAccessorFactory
+	CRC32Hash = "crc32"
+
+	// FNV32Hash is the name of the 32-bit FNV-1 hash function, for use with NewConsistentAccessorFactory
+	FNV32Hash = "fnv32"
+
+	// FNV32aHash is the name of the 32-bit FNV-1a hash function, for use with NewConsistentAccessorFactory
+	FNV32aHash = "fnv32a"
 )
 
+var (
+	ErrUnsupportedHash = errors.New("Unsupported hash function")
+	ErrNoInstances     = errors.New("No instances available")
+)
+
+// hashes maps the supported hash function names onto their constructors
+var hashes = map[string]func() hash.Hash32{
+	CRC32Hash:  func() hash.Hash32 { return crc32.NewIEEE() },
+	FNV32Hash:  fnv.New32,
+	FNV32aHash: fnv.New32a,
+}
+
 // InstancesFilter represents a function which can preprocess slices of instances from the
 // service discovery subsystem.
 type InstancesFilter func([]string) []string
@@ -38,7 +64,8 @@ func DefaultInstancesFilter(original []string) []string {
 type AccessorFactory func([]string) Accessor
 
 // ConsistentAccessorFactory produces a factory which uses consistent hashing
-// of server nodes.
+// of server nodes.  A Subscription or Watch invokes its factory for each change in
+// instances, so the consistent hash is rebuilt automatically.
 func ConsistentAccessorFactory(vnodeCount int) AccessorFactory {
 	if vnodeCount < 1 {
 		vnodeCount = DefaultVNodeCount
@@ -55,6 +82,129 @@ func ConsistentAccessorFactory(vnodeCount int) AccessorFactory {
 	}
 }
 
+// NewConsistentAccessorFactory produces a factory which uses consistent hashing of server nodes with the
+// named hash function, one of CRC32Hash, FNV32Hash, or FNV32aHash.  If hashName is empty, this function
+// returns ConsistentAccessorFactory(vnodeCount), which preserves the mapping of keys to server nodes
+// established by earlier releases.  Since a hash function changes that mapping, every process that
+// must agree on where a key lives has to use the same settings.
+func NewConsistentAccessorFactory(vnodeCount int, hashName string) (AccessorFactory, error) {
+	if len(hashName) == 0 {
+		return ConsistentAccessorFactory(vnodeCount), nil
+	}
+
+	newHash, ok := hashes[hashName]
+	if !ok {
+		return nil, ErrUnsupportedHash
+	}
+
+	if vnodeCount < 1 {
+		vnodeCount = DefaultVNodeCount
+	}
+
+	return func(instances []string) Accessor {
+		return newHashRing(instances, vnodeCount, newHash)
+	}, nil
+}
+
+// hashRing is an Accessor which places vnodeCount points on a ring for each server node
+type hashRing struct {
+	newHash   func() hash.Hash32
+	points    []uint32
+	instances []string
+}
+
+func newHashRing(instances []string, vnodeCount int, newHash func() hash.Hash32) *hashRing {
+	var (
+		h  = newHash()
+		hr = &hashRing{
+			newHash:   newHash,
+			points:    make([]uint32, 0, len(instances)*vnodeCount),
+			instances: make([]string, 0, len(instances)*vnodeCount),
+		}
+	)
+
+	for _, instance := range instances {
+		for v := 0; v < vnodeCount; v++ {
+			h.Reset()
+			h.Write([]byte(instance))
+			h.Write([]byte("-"))
+			h.Write([]byte(strconv.Itoa(v)))
+
+			hr.points = append(hr.points, h.Sum32())
+			hr.instances = append(hr.instances, instance)
+		}
+	}
+
+	sort.Sort(hr)
+	return hr
+}
+
+func (hr *hashRing) Len() int {
+	return len(hr.points)
+}
+
+// Less orders by point, then by instance, so that the ring does not depend on the order of instances
+func (hr *hashRing) Less(i, j int) bool {
+	if hr.points[i] == hr.points[j] {
+		return hr.instances[i] < hr.instances[j]
+	}
+
+	return hr.points[i] < hr.points[j]
+}
+
+func (hr *hashRing) Swap(i, j int) {
+	hr.points[i], hr.points[j] = hr.points[j], hr.points[i]
+	hr.instances[i], hr.instances[j] = hr.instances[j], hr.instances[i]
+}
+
+// Get returns the server node at the first point on or after the hash of the key, wrapping around the ring
+func (hr *hashRing) Get(key []byte) (string, error) {
+	if len(hr.points) == 0 {
+		return "", ErrNoInstances
+	}
+
+	h := hr.newHash()
+	h.Write(key)
+	target := h.Sum32()
+
+	i := sort.Search(len(hr.points), func(i int) bool { return hr.points[i] >= target })
+	if i == len(hr.points) {
+		i = 0
+	}
+
+	return hr.instances[i], nil
+}
+
+// DatacenterAccessorFactory produces a factory which prefers the instances located in the given datacenter,
+// according to the DatacenterKey in each instance's Metadata.  The next factory creates the Accessor for the
+// local instances, and only when there are none does it create the Accessor for every instance, so that keys
+// fail over to remote datacenters.  Instances registered without a datacenter are considered remote.
+func DatacenterAccessorFactory(datacenter string, source MetadataSource, next AccessorFactory) AccessorFactory {
+	return func(instances []string) Accessor {
+		local := make([]string, 0, len(instances))
+		for _, i := range instances {
+			if source.Metadata(i)[DatacenterKey] == datacenter {
+				local = append(local, i)
+			}
+		}
+
+		if len(local) > 0 {
+			return next(local)
+		}
+
+		return next(instances)
+	}
+}
+
+// failedAccessor is an Accessor which always returns an error, used when an Accessor cannot be created
+type failedAccessor struct {
+	err error
+}
+
+func (fa failedAccessor) Get([]byte) (string, error) {
+	return "", fa.err
+}
+
 // Accessor holds a hash of server nodes.
 type Accessor interface {
 	// Get fetches the server node associated with a particular key.
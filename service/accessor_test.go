@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultInstancesFilter(t *testing.T) {
@@ -56,3 +57,92 @@ func TestConsistentAccessorFactory(t *testing.T) {
 		}
 	}
 }
+
+func testNewConsistentAccessorFactory(t *testing.T, hashName string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		keys    = []string{"mac:112233445566", "mac:665544332211", "uuid:1234", "random key"}
+	)
+
+	for _, vnodeCount := range []int{-1, 0, 1, 50, 211} {
+		t.Logf("vnodeCount: %d", vnodeCount)
+
+		factory, err := NewConsistentAccessorFactory(vnodeCount, hashName)
+		require.NotNil(factory)
+		require.NoError(err)
+
+		instance, err := factory(nil).Get([]byte("random key"))
+		assert.Empty(instance)
+		assert.Error(err)
+
+		// the order of instances must not matter
+		var (
+			forward  = factory([]string{"abc.com", "def.com", "ghi.com"})
+			backward = factory([]string{"ghi.com", "def.com", "abc.com"})
+		)
+
+		for _, key := range keys {
+			expected, err := forward.Get([]byte(key))
+			assert.Contains([]string{"abc.com", "def.com", "ghi.com"}, expected)
+			assert.NoError(err)
+
+			actual, err := backward.Get([]byte(key))
+			assert.Equal(expected, actual)
+			assert.NoError(err)
+		}
+	}
+}
+
+func TestNewConsistentAccessorFactory(t *testing.T) {
+	t.Run("Default", func(t *testing.T) { testNewConsistentAccessorFactory(t, "") })
+	t.Run("CRC32", func(t *testing.T) { testNewConsistentAccessorFactory(t, CRC32Hash) })
+	t.Run("FNV32", func(t *testing.T) { testNewConsistentAccessorFactory(t, FNV32Hash) })
+	t.Run("FNV32a", func(t *testing.T) { testNewConsistentAccessorFactory(t, FNV32aHash) })
+
+	t.Run("Empty", func(t *testing.T) {
+		factory, err := NewConsistentAccessorFactory(0, FNV32aHash)
+		require.NoError(t, err)
+
+		instance, err := factory([]string{}).Get([]byte("random key"))
+		assert.Empty(t, instance)
+		assert.Equal(t, ErrNoInstances, err)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		factory, err := NewConsistentAccessorFactory(0, "md5")
+		assert.Nil(t, factory)
+		assert.Equal(t, ErrUnsupportedHash, err)
+	})
+}
+
+func TestDatacenterAccessorFactory(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		source   = new(mockMetadataSource)
+		expected = new(mockAccessor)
+		actual   []string
+
+		factory = DatacenterAccessorFactory("east", source, func(instances []string) Accessor {
+			actual = instances
+			return expected
+		})
+	)
+
+	source.On("Metadata", "host1").Return(Metadata{DatacenterKey: "east"})
+	source.On("Metadata", "host2").Return(Metadata{DatacenterKey: "west"})
+	source.On("Metadata", "host3").Return(Metadata{DatacenterKey: "east", "version": "1.2.3"})
+	source.On("Metadata", "host4").Return(nil)
+
+	assert.Equal(expected, factory([]string{"host1", "host2", "host3", "host4"}))
+	assert.Equal([]string{"host1", "host3"}, actual)
+
+	// with no local instances, every instance is used
+	assert.Equal(expected, factory([]string{"host2", "host4"}))
+	assert.Equal([]string{"host2", "host4"}, actual)
+
+	assert.Equal(expected, factory([]string{}))
+	assert.Empty(actual)
+
+	source.AssertExpectations(t)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// BackoffConnect is the BackoffEvent operation for connecting to a backend
+	BackoffConnect = "connect"
+
+	// BackoffRegister is the BackoffEvent operation for restoring a lost registration
+	BackoffRegister = "register"
+)
+
+var (
+	// backoffRandom is the source of jitter, returning values in [0.0, 1.0).
+	// Tests can replace this internal member to take over control of jitter.
+	backoffRandom = rand.Float64
+)
+
+// BackoffEvent describes a failed attempt that will be retried after a delay
+type BackoffEvent struct {
+	// Operation is what was attempted, e.g. BackoffConnect or BackoffRegister
+	Operation string
+
+	// Attempt is the number of attempts made so far, starting at 1
+	Attempt int
+
+	// Delay is the time until the next attempt
+	Delay time.Duration
+
+	// Err is the error from the failed attempt
+	Err error
+}
+
+// backoff computes the delays between attempts, growing exponentially up to a maximum
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+}
+
+func newBackoff(bo *BackoffOptions) backoff {
+	return backoff{
+		initial:    bo.initial(),
+		max:        bo.max(),
+		multiplier: bo.multiplier(),
+		jitter:     bo.jitter(),
+	}
+}
+
+// delay returns the time to wait after the given number of failed attempts, which starts at 1
+func (b backoff) delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(b.initial) * math.Pow(b.multiplier, float64(attempt-1))
+	if d > float64(b.max) {
+		d = float64(b.max)
+	}
+
+	if b.jitter > 0.0 {
+		d *= 1.0 - b.jitter + 2.0*b.jitter*backoffRandom()
+	}
+
+	return time.Duration(d)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testBackoffDelay(t *testing.T) {
+	defer resetBackoffRandom()
+
+	var (
+		assert = assert.New(t)
+		b      = newBackoff(&BackoffOptions{Initial: time.Second, Max: 10 * time.Second, Multiplier: 3.0})
+	)
+
+	backoffRandom = func() float64 { return 0.5 }
+
+	assert.Equal(time.Second, b.delay(0))
+	assert.Equal(time.Second, b.delay(1))
+	assert.Equal(3*time.Second, b.delay(2))
+	assert.Equal(9*time.Second, b.delay(3))
+	assert.Equal(10*time.Second, b.delay(4))
+	assert.Equal(10*time.Second, b.delay(100))
+}
+
+func testBackoffJitter(t *testing.T) {
+	defer resetBackoffRandom()
+
+	var (
+		assert = assert.New(t)
+		b      = newBackoff(&BackoffOptions{Initial: 10 * time.Second, Jitter: 0.1})
+	)
+
+	backoffRandom = func() float64 { return 0.0 }
+	assert.Equal(9*time.Second, b.delay(1))
+
+	backoffRandom = func() float64 { return 1.0 }
+	assert.Equal(11*time.Second, b.delay(1))
+
+	// jitter can be disabled
+	b = newBackoff(&BackoffOptions{Initial: 10 * time.Second, Jitter: -1.0})
+	assert.Equal(10*time.Second, b.delay(1))
+}
+
+func testBackoffDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, bo := range []*BackoffOptions{nil, new(BackoffOptions), {Multiplier: 0.5}} {
+		t.Logf("%#v", bo)
+
+		assert.Equal(
+			backoff{initial: DefaultBackoffInitial, max: DefaultBackoffMax, multiplier: DefaultBackoffMultiplier, jitter: DefaultBackoffJitter},
+			newBackoff(bo),
+		)
+
+		assert.Equal(DefaultBackoffConnectAttempts, bo.connectAttempts())
+	}
+
+	assert.Equal(1.0, (&BackoffOptions{Jitter: 2.0}).jitter())
+	assert.Equal(5, (&BackoffOptions{ConnectAttempts: 5}).connectAttempts())
+
+	for i := 0; i < 100; i++ {
+		d := newBackoff(nil).delay(1)
+		assert.True(d >= 800*time.Millisecond && d <= 1200*time.Millisecond, d.String())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Run("Delay", testBackoffDelay)
+	t.Run("Jitter", testBackoffJitter)
+	t.Run("Defaults", testBackoffDefaults)
+}
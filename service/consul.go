@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/consul"
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// errInvalidRegistration indicates a Registration that cannot be expressed as a Consul service address
+var errInvalidRegistration = errors.New("The registration must be of the form host:port or scheme://host[:port]")
+
+// instrumentedConsulClient is a consul.Client which counts registration attempts and failures.  go-kit's
+// consul.Registrar only logs errors, so this is the only place they can be observed.
+type instrumentedConsulClient struct {
+	consul.Client
+	attempts metrics.Counter
+	failures metrics.Counter
+}
+
+func (c instrumentedConsulClient) Register(r *consulapi.AgentServiceRegistration) error {
+	c.attempts.Add(1)
+	err := c.Client.Register(r)
+	if err != nil {
+		c.failures.Add(1)
+	}
+
+	return err
+}
+
+// consulProvider is the Provider for go-kit/kit/sd/consul
+type consulProvider struct {
+	logger      log.Logger
+	client      consul.Client
+	serviceName string
+	tags        []string
+	passingOnly bool
+	scheme      string
+	registrar   sd.Registrar
+}
+
+func (c *consulProvider) Registrar() Registrar {
+	return c.registrar
+}
+
+func (c *consulProvider) NewInstancer() (Instancer, error) {
+	return c.NewTargetInstancer(WatchTarget{})
+}
+
+// NewTargetInstancer watches the target's ServiceName, using the same tags as the configured service.
+// Consul has no notion of a path, so the target's Path is not used.
+func (c *consulProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	serviceName := c.serviceName
+	if len(t.ServiceName) > 0 {
+		serviceName = t.ServiceName
+	}
+
+	i := consul.NewInstancer(c.client, c.logger, serviceName, c.tags, c.passingOnly)
+	if len(c.scheme) == 0 {
+		return i, nil
+	}
+
+	return newTransformInstancer(i, withScheme(c.scheme)), nil
+}
+
+func (c *consulProvider) Close() error {
+	return nil
+}
+
+// parseRegistration splits a Registration into the parts needed for a Consul service registration.  A registration
+// with a scheme but no port uses the default port for http or https.
+func parseRegistration(registration string) (scheme, host string, port int, err error) {
+	var portValue string
+	if strings.Contains(registration, "://") {
+		var u *url.URL
+		if u, err = url.Parse(registration); err != nil {
+			return
+		}
+
+		scheme, host, portValue = u.Scheme, u.Hostname(), u.Port()
+		if len(portValue) == 0 {
+			switch scheme {
+			case "http":
+				portValue = "80"
+			case "https":
+				portValue = "443"
+			}
+		}
+	} else if host, portValue, err = net.SplitHostPort(registration); err != nil {
+		return
+	}
+
+	if port, err = strconv.Atoi(portValue); err != nil || len(host) == 0 || port < 1 {
+		return "", "", 0, errInvalidRegistration
+	}
+
+	return
+}
+
+// newConsulClient is the default factory for go-kit consul.Client objects
+func newConsulClient(config *consulapi.Config) (consul.Client, error) {
+	client, err := consulapi.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return consul.NewClient(client), nil
+}
+
+var (
+	// consulClientFactory is the factory function used to produce a go-kit consul.Client.
+	// Tests can replace this internal member to take over control of client creation.
+	consulClientFactory func(*consulapi.Config) (consul.Client, error) = newConsulClient
+)
+
+// newConsulProvider constructs the Consul provider.  Consul has no notion of a path, so a service is identified
+// by its name and, optionally, a set of tags.
+func newConsulProvider(o *Options) (Provider, error) {
+	var (
+		co           = o.consul()
+		registration = o.registration()
+		serviceName  = o.serviceName()
+		tags         = co.tags()
+		scheme       = co.scheme()
+		registrar    sd.Registrar
+		logger       = logging.DefaultCaller(o.logger(), "serviceName", serviceName, "backend", ConsulBackend, "registration", registration)
+
+		serviceRegistration *consulapi.AgentServiceRegistration
+	)
+
+	if len(registration) > 0 {
+		registrationScheme, host, port, err := parseRegistration(registration)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(scheme) == 0 {
+			scheme = registrationScheme
+		}
+
+		serviceRegistration = &consulapi.AgentServiceRegistration{
+			ID:      fmt.Sprintf("%s-%s-%d", serviceName, host, port),
+			Name:    serviceName,
+			Tags:    tags,
+			Address: host,
+			Port:    port,
+		}
+	}
+
+	config := consulapi.DefaultConfig()
+	config.Address = co.address()
+	config.Datacenter = co.datacenter()
+	config.Token = co.token()
+
+	// use the internal singleton factory function, which is set to newConsulClient normally
+	client, err := consulClientFactory(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if serviceRegistration != nil {
+		measures := NewMeasures(o.metricsProvider())
+		registrar = consul.NewRegistrar(
+			instrumentedConsulClient{
+				Client:   client,
+				attempts: measures.RegistrationAttempts.With(ServiceLabel, serviceName),
+				failures: measures.RegistrationFailures.With(ServiceLabel, serviceName),
+			},
+			serviceRegistration,
+			logger,
+		)
+	}
+
+	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
+
+	return &consulProvider{
+		logger:      logger,
+		client:      client,
+		serviceName: serviceName,
+		tags:        tags,
+		passingOnly: co.passingOnly(),
+		scheme:      scheme,
+		registrar:   registrar,
+	}, nil
+}
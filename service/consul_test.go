@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/consul"
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testConsulFacade(t *testing.T, o *Options, expectedRegistration *consulapi.AgentServiceRegistration, expectedInstances []string) {
+	defer resetConsulClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		client  = new(mockConsulClient)
+
+		blocking       = make(chan struct{}, 1)
+		block          = make(chan struct{})
+		instanceEvents = make(chan sd.Event, 1)
+
+		entries = []*consulapi.ServiceEntry{
+			{
+				Node:    &consulapi.Node{Address: "node1.comcast.net"},
+				Service: &consulapi.AgentService{Service: o.serviceName(), Tags: o.consul().tags(), Port: 8080},
+			},
+			{
+				Node:    &consulapi.Node{Address: "node2.comcast.net"},
+				Service: &consulapi.AgentService{Service: o.serviceName(), Tags: o.consul().tags(), Address: "service.comcast.net", Port: 1234},
+			},
+		}
+	)
+
+	defer close(block)
+
+	consulClientFactory = func(config *consulapi.Config) (consul.Client, error) {
+		assert.Equal(o.consul().address(), config.Address)
+		assert.Equal(o.consul().datacenter(), config.Datacenter)
+		assert.Equal(o.consul().token(), config.Token)
+		return client, nil
+	}
+
+	if expectedRegistration != nil {
+		client.On("Register", expectedRegistration).Return(error(nil)).Once()
+		client.On("Deregister", expectedRegistration).Return(error(nil)).Twice() // once during Register/Degister, and once during Close
+	}
+
+	var tag string
+	if tags := o.consul().tags(); len(tags) > 0 {
+		tag = tags[0]
+	}
+
+	client.On("Service", o.serviceName(), tag, o.consul().passingOnly(), &consulapi.QueryOptions{WaitIndex: 0}).
+		Return(entries, &consulapi.QueryMeta{LastIndex: 1}, error(nil)).Once()
+
+	// the blocking query for changes never returns during this test
+	client.On("Service", o.serviceName(), tag, o.consul().passingOnly(), &consulapi.QueryOptions{WaitIndex: 1}).
+		Run(func(mock.Arguments) {
+			blocking <- struct{}{}
+			<-block
+		}).
+		Return(nil, nil, errors.New("the blocking query should not have returned"))
+
+	service, err := New(o)
+	require.NotNil(service)
+	require.NoError(err)
+
+	service.Register()
+	service.Deregister()
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	assert.NoError(err)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: expectedInstances}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+	i.Deregister(instanceEvents) // idempotency
+
+	// need to do this to terminate the goroutine, once it is waiting on changes
+	<-blocking
+	i.Stop()
+
+	assert.NoError(service.Close())
+	assert.NoError(service.Close()) // idempotency
+
+	client.AssertExpectations(t)
+}
+
+func testConsulFacadeInvalidRegistration(t *testing.T) {
+	defer resetConsulClientFactory()
+
+	assert := assert.New(t)
+	consulClientFactory = func(*consulapi.Config) (consul.Client, error) {
+		assert.Fail("The client factory should not have been called")
+		return nil, nil
+	}
+
+	service, err := New(&Options{Backend: ConsulBackend, Registration: "localhost"})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func testConsulFacadeClientFactoryError(t *testing.T) {
+	defer resetConsulClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	consulClientFactory = func(*consulapi.Config) (consul.Client, error) {
+		return nil, expectedError
+	}
+
+	service, err := New(&Options{Backend: ConsulBackend})
+	assert.Nil(service)
+	assert.Equal(expectedError, err)
+}
+
+func TestConsulFacade(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testConsulFacade(
+			t,
+			&Options{Backend: ConsulBackend},
+			nil,
+			[]string{"node1.comcast.net:8080", "service.comcast.net:1234"},
+		)
+	})
+
+	t.Run("Nontrivial", func(t *testing.T) {
+		testConsulFacade(
+			t,
+			&Options{
+				Backend:      ConsulBackend,
+				ServiceName:  "testing",
+				Registration: "https://localhost:1400",
+				Consul: &ConsulOptions{
+					Address:     "consul.comcast.net:8500",
+					Datacenter:  "dc1",
+					Token:       "token",
+					Tags:        []string{"tag1", "tag2"},
+					PassingOnly: true,
+				},
+			},
+			&consulapi.AgentServiceRegistration{
+				ID:      "testing-localhost-1400",
+				Name:    "testing",
+				Tags:    []string{"tag1", "tag2"},
+				Address: "localhost",
+				Port:    1400,
+			},
+			[]string{"https://node1.comcast.net:8080", "https://service.comcast.net:1234"},
+		)
+	})
+
+	t.Run("Scheme", func(t *testing.T) {
+		testConsulFacade(
+			t,
+			&Options{
+				Backend:      ConsulBackend,
+				ServiceName:  "testing",
+				Registration: "localhost:1400",
+				Consul:       &ConsulOptions{Scheme: "http"},
+			},
+			&consulapi.AgentServiceRegistration{
+				ID:      "testing-localhost-1400",
+				Name:    "testing",
+				Address: "localhost",
+				Port:    1400,
+			},
+			[]string{"http://node1.comcast.net:8080", "http://service.comcast.net:1234"},
+		)
+	})
+
+	t.Run("InvalidRegistration", testConsulFacadeInvalidRegistration)
+	t.Run("ClientFactoryError", testConsulFacadeClientFactoryError)
+}
+
+func TestParseRegistration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		testData = []struct {
+			registration string
+			scheme       string
+			host         string
+			port         int
+		}{
+			{"localhost:8080", "", "localhost", 8080},
+			{"https://comcast.net:8080", "https", "comcast.net", 8080},
+			{"http://comcast.net", "http", "comcast.net", 80},
+			{"https://comcast.net", "https", "comcast.net", 443},
+			{"http://[::1]:1234", "http", "::1", 1234},
+		}
+	)
+
+	for _, record := range testData {
+		scheme, host, port, err := parseRegistration(record.registration)
+		assert.Equal(record.scheme, scheme, record.registration)
+		assert.Equal(record.host, host, record.registration)
+		assert.Equal(record.port, port, record.registration)
+		assert.NoError(err, record.registration)
+	}
+
+	for _, invalid := range []string{"", "localhost", ":8080", "localhost:notaport", "localhost:0", "ftp://comcast.net", "http://:8080", "http://comcast.net:port"} {
+		_, _, _, err := parseRegistration(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestNewUnsupportedBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := New(&Options{Backend: "etcd"})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func TestInstrumentedConsulClient(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		client        = new(mockConsulClient)
+		registration  = &consulapi.AgentServiceRegistration{ID: "test", Name: "test"}
+		expectedError = errors.New("expected")
+
+		instrumented = instrumentedConsulClient{
+			Client:   client,
+			attempts: generic.NewCounter("attempts"),
+			failures: generic.NewCounter("failures"),
+		}
+	)
+
+	client.On("Register", registration).Return(error(nil)).Once()
+	client.On("Register", registration).Return(expectedError).Once()
+
+	assert.NoError(instrumented.Register(registration))
+	assert.Equal(expectedError, instrumented.Register(registration))
+	assert.Equal(2.0, instrumented.attempts.(*generic.Counter).Value())
+	assert.Equal(1.0, instrumented.failures.(*generic.Counter).Value())
+
+	client.AssertExpectations(t)
+}
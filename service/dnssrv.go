@@ -0,0 +1,99 @@
+package service
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd/dnssrv"
+)
+
+var (
+	// dnsSRVLookup is the function used to resolve SRV records.
+	// Tests can replace this internal member to take over control of DNS lookups.
+	dnsSRVLookup dnssrv.Lookup = net.LookupSRV
+)
+
+// dnsSRVProvider is the Provider for go-kit/kit/sd/dnssrv.  SRV records are maintained outside of this process,
+// e.g. by Kubernetes for headless services, so registration is not supported.
+type dnsSRVProvider struct {
+	logger          log.Logger
+	name            string
+	refreshInterval time.Duration
+	scheme          string
+}
+
+func (d *dnsSRVProvider) Registrar() Registrar {
+	return nil
+}
+
+// NewInstancer looks up the SRV record immediately, then again on each refresh interval.  Lookup failures are
+// dispatched as errors, and the most recent successful set of instances remains in effect.
+func (d *dnsSRVProvider) NewInstancer() (Instancer, error) {
+	return d.NewTargetInstancer(WatchTarget{})
+}
+
+// NewTargetInstancer looks up the SRV record named by the target's ServiceName.  The target's Path is not used.
+func (d *dnsSRVProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	name := d.name
+	if len(t.ServiceName) > 0 {
+		name = t.ServiceName
+	}
+
+	i := dnssrv.NewInstancerDetailed(name, time.NewTicker(d.refreshInterval), dnsSRVLookup, d.logger)
+	return newTransformInstancer(i, d.transform), nil
+}
+
+// transform removes the trailing dot from the fully qualified target of each SRV record, and prepends
+// the scheme, if any
+func (d *dnsSRVProvider) transform(instance string) string {
+	if host, port, err := net.SplitHostPort(instance); err == nil {
+		instance = net.JoinHostPort(strings.TrimSuffix(host, "."), port)
+	}
+
+	if len(d.scheme) > 0 {
+		instance = d.scheme + "://" + instance
+	}
+
+	return instance
+}
+
+func (d *dnsSRVProvider) Close() error {
+	return nil
+}
+
+// newDNSSRVProvider constructs the DNS SRV provider.  No network activity takes place until an instancer is created.
+func newDNSSRVProvider(o *Options) (Provider, error) {
+	var (
+		do           = o.dnsSRV()
+		registration = o.registration()
+		name         = do.name()
+		scheme       = do.scheme()
+	)
+
+	if len(name) == 0 {
+		name = o.serviceName()
+	}
+
+	if len(scheme) == 0 && len(registration) > 0 {
+		registrationScheme, _, _, err := parseRegistration(registration)
+		if err != nil {
+			return nil, err
+		}
+
+		scheme = registrationScheme
+	}
+
+	logger := logging.DefaultCaller(o.logger(), "serviceName", o.serviceName(), "backend", DNSSRVBackend, "name", name)
+	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
+
+	return &dnsSRVProvider{
+		logger:          logger,
+		name:            name,
+		refreshInterval: do.refreshInterval(),
+		scheme:          scheme,
+	}, nil
+}
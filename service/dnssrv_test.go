@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDNSSRVFacade(t *testing.T, o *Options, expectedName string, expectedInstances []string) {
+	defer resetDNSSRVLookup()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lookups        = make(chan string, 10)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	dnsSRVLookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups <- name
+		return "", []*net.SRV{
+			{Target: "talaria-0.talaria.xmidt.svc.cluster.local.", Port: 8080},
+			{Target: "talaria-1.talaria.xmidt.svc.cluster.local.", Port: 8080},
+		}, nil
+	}
+
+	service, err := New(o)
+	require.NotNil(service)
+	require.NoError(err)
+
+	// registration is a nop
+	service.Register()
+	service.Deregister()
+	assert.Empty(lookups)
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	assert.NoError(err)
+	assert.Equal(expectedName, <-lookups)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: expectedInstances}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+
+	// need to do this to terminate the goroutine
+	i.Stop()
+
+	assert.NoError(service.Close())
+	assert.NoError(service.Close()) // idempotency
+}
+
+func testDNSSRVFacadeRefresh(t *testing.T) {
+	defer resetDNSSRVLookup()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedError  = errors.New("expected")
+		results        = make(chan error, 3)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	// after the first two lookups, the closed channel yields successful lookups
+	results <- nil
+	results <- expectedError
+	close(results)
+	dnsSRVLookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		if err := <-results; err != nil {
+			return "", nil, err
+		}
+
+		return "", []*net.SRV{{Target: "localhost.", Port: 1234}}, nil
+	}
+
+	service, err := New(&Options{Backend: DNSSRVBackend, DNSSRV: &DNSSRVOptions{RefreshInterval: time.Millisecond}})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	require.NoError(err)
+	defer i.Stop()
+
+	i.Register(instanceEvents)
+	defer i.Deregister(instanceEvents)
+
+	assert.Equal(sd.Event{Instances: []string{"localhost:1234"}}, <-instanceEvents)
+	assert.Equal(sd.Event{Err: expectedError}, <-instanceEvents)
+}
+
+func testDNSSRVFacadeTarget(t *testing.T) {
+	defer resetDNSSRVLookup()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lookups        = make(chan string, 10)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	dnsSRVLookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups <- name
+		return "", []*net.SRV{{Target: "petasos-0.petasos.xmidt.svc.cluster.local.", Port: 6400}}, nil
+	}
+
+	service, err := New(&Options{Backend: DNSSRVBackend, ServiceName: "talaria"})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(WatchTarget{ServiceName: "petasos"})
+	require.NotNil(i)
+	require.NoError(err)
+	defer i.Stop()
+	assert.Equal("petasos", <-lookups)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"petasos-0.petasos.xmidt.svc.cluster.local:6400"}}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+}
+
+func testDNSSRVFacadeInvalidRegistration(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := New(&Options{Backend: DNSSRVBackend, Registration: "localhost"})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func TestDNSSRVFacade(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testDNSSRVFacade(
+			t,
+			&Options{Backend: DNSSRVBackend},
+			DefaultServiceName,
+			[]string{"talaria-0.talaria.xmidt.svc.cluster.local:8080", "talaria-1.talaria.xmidt.svc.cluster.local:8080"},
+		)
+	})
+
+	t.Run("RegistrationScheme", func(t *testing.T) {
+		testDNSSRVFacade(
+			t,
+			&Options{Backend: DNSSRVBackend, ServiceName: "talaria", Registration: "https://localhost:8080"},
+			"talaria",
+			[]string{"https://talaria-0.talaria.xmidt.svc.cluster.local:8080", "https://talaria-1.talaria.xmidt.svc.cluster.local:8080"},
+		)
+	})
+
+	t.Run("Nontrivial", func(t *testing.T) {
+		testDNSSRVFacade(
+			t,
+			&Options{
+				Backend:      DNSSRVBackend,
+				ServiceName:  "talaria",
+				Registration: "https://localhost:8080",
+				DNSSRV: &DNSSRVOptions{
+					Name:   "_http._tcp.talaria.xmidt.svc.cluster.local",
+					Scheme: "http",
+				},
+			},
+			"_http._tcp.talaria.xmidt.svc.cluster.local",
+			[]string{"http://talaria-0.talaria.xmidt.svc.cluster.local:8080", "http://talaria-1.talaria.xmidt.svc.cluster.local:8080"},
+		)
+	})
+
+	t.Run("Refresh", testDNSSRVFacadeRefresh)
+	t.Run("Target", testDNSSRVFacadeTarget)
+	t.Run("InvalidRegistration", testDNSSRVFacadeInvalidRegistration)
+}
@@ -1,4 +1,25 @@
 /*
-Package service provides basic integration with go.serversets
+Package service provides basic integration with go.serversets, with Consul via go-kit's sd/consul package,
+with etcd via its v3 API, with DNS SRV records via go-kit's sd/dnssrv package, and with the Endpoints or
+EndpointSlices of Kubernetes services via the API server.  The backend is selected with Options.Backend,
+and all backends are exposed through the same Interface.
+
+Each backend is a Provider, which supplies a Registrar for this process and creates Instancers.  Additional
+backends can be plugged in with RegisterProvider, without any changes to code that consumes this package.
+
+Registrations can carry Metadata, such as a build version or datacenter, which is surfaced to watchers of the
+service through Event.Metadata.  With Options.Datacenter and Options.PreferLocalDatacenter, keys are routed
+to instances in the same datacenter, failing over to other datacenters only when there are no local instances.
+FilterInstancer narrows the instances delivered by an Instancer with an InstancePredicate, such as HasScheme,
+PortInRange, or HasMetadata, so that consumers with different needs can share a single registry path.
+
+Each Event delivered by a Watch carries the instances Added and Removed since the subscriber's previous event,
+so that churn can be logged and metered precisely.  Other services, or the same service in other environments,
+can be watched over the same connection with NewWatches.
+
+A registration can be gated on a HealthCheck via Options.Health.  A process whose check fails repeatedly is
+deregistered, so that it drops out of its clients' consistent hash, and is registered again once it recovers.
+Registration can also be paused and resumed through the RegistrarFacade methods of an Interface, e.g. to drain
+traffic before maintenance, while the connection to the backend is kept.
 */
 package service
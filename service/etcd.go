@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+)
+
+// etcdRetryInterval is the time an etcd instancer waits before retrying after it failed to list instances
+const etcdRetryInterval = time.Second
+
+// etcdClient is the subset of an etcd v3 client used by this package
+type etcdClient struct {
+	kv      clientv3.KV
+	lease   clientv3.Lease
+	watcher clientv3.Watcher
+	closer  io.Closer
+}
+
+// newEtcdClient is the default factory for etcd clients
+func newEtcdClient(config clientv3.Config) (etcdClient, error) {
+	client, err := clientv3.New(config)
+	if err != nil {
+		return etcdClient{}, err
+	}
+
+	return etcdClient{
+		kv:      client.KV,
+		lease:   client.Lease,
+		watcher: client.Watcher,
+		closer:  client,
+	}, nil
+}
+
+var (
+	// etcdClientFactory is the factory function used to produce an etcd client.
+	// Tests can replace this internal member to take over control of client creation.
+	etcdClientFactory func(clientv3.Config) (etcdClient, error) = newEtcdClient
+)
+
+// errEtcdLeaseLost is passed to the RegistrationLost callback when the lease for a registration could not be kept alive
+var errEtcdLeaseLost = errors.New("The etcd lease for this registration was lost")
+
+// etcdRegistrar is an sd.Registrar that stores a service's registration under a key attached to an etcd lease.
+// The lease is kept alive while registered, so that the key is removed by etcd if this process goes away.
+// If the lease is lost while registered, e.g. because etcd was unreachable for longer than the TTL, the
+// registration is restored with a new lease.
+type etcdRegistrar struct {
+	logger   log.Logger
+	kv       clientv3.KV
+	lease    clientv3.Lease
+	timeout  time.Duration
+	key      string
+	value    string
+	ttl      int64
+	after    func(time.Duration) <-chan time.Time
+	lost     func(error)
+	restored func()
+	attempts metrics.Counter
+	failures metrics.Counter
+
+	lock    sync.Mutex
+	leaseID clientv3.LeaseID
+	cancel  func()
+}
+
+func (r *etcdRegistrar) Register() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaseID, keepAlive, err := r.register(ctx)
+	if err != nil {
+		cancel()
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to register with etcd", logging.ErrorKey(), err)
+		return
+	}
+
+	r.leaseID, r.cancel = leaseID, cancel
+	go r.keepAlive(ctx, leaseID, keepAlive)
+	r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "registered with etcd", "key", r.key, "lease", leaseID)
+}
+
+// register grants a lease, stores the registration under it, and starts keeping the lease alive.  If anything
+// fails after the grant, the lease is revoked, which also removes the key.
+func (r *etcdRegistrar) register(ctx context.Context) (clientv3.LeaseID, <-chan *clientv3.LeaseKeepAliveResponse, error) {
+	r.attempts.Add(1)
+	requestCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	grant, err := r.lease.Grant(requestCtx, r.ttl)
+	if err != nil {
+		r.failures.Add(1)
+		return clientv3.NoLease, nil, err
+	}
+
+	_, err = r.kv.Put(requestCtx, r.key, r.value, clientv3.WithLease(grant.ID))
+	if err == nil {
+		var keepAlive <-chan *clientv3.LeaseKeepAliveResponse
+		if keepAlive, err = r.lease.KeepAlive(ctx, grant.ID); err == nil {
+			return grant.ID, keepAlive, nil
+		}
+	}
+
+	r.failures.Add(1)
+	r.revoke(grant.ID)
+	return clientv3.NoLease, nil, err
+}
+
+// keepAlive consumes lease keepalive responses until the registration is deregistered.  The etcd client
+// closes the channel when it can no longer keep the lease alive, in which case the registration is restored.
+func (r *etcdRegistrar) keepAlive(ctx context.Context, leaseID clientv3.LeaseID, responses <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		for range responses {
+		}
+
+		r.lock.Lock()
+		if ctx.Err() != nil || r.leaseID != leaseID {
+			// this registration was deregistered
+			r.lock.Unlock()
+			return
+		}
+
+		r.leaseID = clientv3.NoLease
+		r.lock.Unlock()
+
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "etcd lease lost, restoring the registration", "key", r.key, "lease", leaseID)
+		r.lost(errEtcdLeaseLost)
+
+		var ok bool
+		if leaseID, responses, ok = r.restore(ctx); !ok {
+			return
+		}
+
+		r.restored()
+	}
+}
+
+// restore retries the registration until it succeeds or this registration is deregistered
+func (r *etcdRegistrar) restore(ctx context.Context) (clientv3.LeaseID, <-chan *clientv3.LeaseKeepAliveResponse, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return clientv3.NoLease, nil, false
+		case <-r.after(etcdRetryInterval):
+		}
+
+		r.lock.Lock()
+		if ctx.Err() != nil {
+			r.lock.Unlock()
+			return clientv3.NoLease, nil, false
+		}
+
+		leaseID, keepAlive, err := r.register(ctx)
+		if err == nil {
+			r.leaseID = leaseID
+		}
+
+		r.lock.Unlock()
+
+		if err == nil {
+			r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "restored registration with etcd", "key", r.key, "lease", leaseID)
+			return leaseID, keepAlive, true
+		}
+
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to restore registration with etcd", "key", r.key, logging.ErrorKey(), err)
+	}
+}
+
+func (r *etcdRegistrar) revoke(leaseID clientv3.LeaseID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.lease.Revoke(ctx, leaseID)
+	return err
+}
+
+func (r *etcdRegistrar) Deregister() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.cancel == nil {
+		return
+	}
+
+	leaseID := r.leaseID
+	r.cancel()
+	r.leaseID, r.cancel = clientv3.NoLease, nil
+
+	if leaseID == clientv3.NoLease {
+		// the lease was lost, and so the registration key is already gone
+		r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "deregistered from etcd", "key", r.key)
+		return
+	}
+
+	// revoking the lease deletes the registration key
+	if err := r.revoke(leaseID); err != nil {
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to deregister from etcd", "key", r.key, "lease", leaseID, logging.ErrorKey(), err)
+		return
+	}
+
+	r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "deregistered from etcd", "key", r.key, "lease", leaseID)
+}
+
+// etcdInstancer is an sd.Instancer which watches a key prefix in etcd.  The value of each key under the prefix
+// is an instance.
+type etcdInstancer struct {
+	logger  log.Logger
+	kv      clientv3.KV
+	watcher clientv3.Watcher
+	timeout time.Duration
+	prefix  string
+	after   func(time.Duration) <-chan time.Time
+
+	cancel func()
+	done   chan struct{}
+
+	*instanceCache
+}
+
+// newEtcdInstancer lists the current instances under the given prefix, then starts watching for changes.
+// If the initial list cannot be obtained, this function returns an error.
+func newEtcdInstancer(logger log.Logger, client etcdClient, timeout time.Duration, prefix string, after func(time.Duration) <-chan time.Time) (*etcdInstancer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &etcdInstancer{
+		logger:  logger,
+		kv:      client.kv,
+		watcher: client.watcher,
+		timeout: timeout,
+		prefix:  prefix,
+		after:   after,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	instances, revision, err := i.getInstances(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	i.instanceCache = newInstanceCache(sd.Event{Instances: instances})
+	go i.loop(ctx, revision)
+	return i, nil
+}
+
+// getInstances lists the instances under this instancer's prefix, along with the etcd revision of the list
+func (i *etcdInstancer) getInstances(ctx context.Context) ([]string, int64, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, i.timeout)
+	defer cancel()
+
+	response, err := i.kv.Get(requestCtx, i.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	instances := make([]string, 0, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		instances = append(instances, string(kv.Value))
+	}
+
+	return instances, response.Header.Revision, nil
+}
+
+// loop watches for changes until this instancer is stopped.  Each change causes the full set of instances to be
+// listed again.  If a watch fails, e.g. because its revision was compacted, the instances are listed again and a
+// new watch is started from the revision of that list.
+func (i *etcdInstancer) loop(ctx context.Context, revision int64) {
+	defer close(i.done)
+
+	for {
+		watchCtx, cancelWatch := context.WithCancel(ctx)
+		for response := range i.watcher.Watch(watchCtx, i.prefix, clientv3.WithPrefix(), clientv3.WithRev(revision+1)) {
+			if err := response.Err(); err != nil {
+				i.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "etcd watch failed", logging.ErrorKey(), err)
+				break
+			}
+
+			revision = response.Header.Revision
+			instances, _, err := i.getInstances(ctx)
+			if err != nil {
+				i.update(sd.Event{Err: err})
+				break
+			}
+
+			i.update(sd.Event{Instances: instances})
+		}
+
+		cancelWatch()
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			instances, listRevision, err := i.getInstances(ctx)
+			if err == nil {
+				revision = listRevision
+				i.update(sd.Event{Instances: instances})
+				break
+			}
+
+			i.update(sd.Event{Err: err})
+			select {
+			case <-ctx.Done():
+				return
+			case <-i.after(etcdRetryInterval):
+			}
+		}
+	}
+}
+
+// update dispatches an event, logging any error
+func (i *etcdInstancer) update(e sd.Event) {
+	if i.dispatch(e) && e.Err != nil {
+		i.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to list instances from etcd", logging.ErrorKey(), e.Err)
+	}
+}
+
+// Stop terminates the watch on etcd and waits for it to finish.  This method is idempotent.
+func (i *etcdInstancer) Stop() {
+	i.cancel()
+	<-i.done
+}
+
+// etcdProvider is the Provider for etcd v3
+type etcdProvider struct {
+	logger      log.Logger
+	client      etcdClient
+	timeout     time.Duration
+	path        string
+	serviceName string
+	after       func(time.Duration) <-chan time.Time
+	registrar   sd.Registrar
+}
+
+func (e *etcdProvider) Registrar() Registrar {
+	return e.registrar
+}
+
+func (e *etcdProvider) NewInstancer() (Instancer, error) {
+	return e.NewTargetInstancer(WatchTarget{})
+}
+
+// NewTargetInstancer watches the prefix for the target's ServiceName under its Path
+func (e *etcdProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	path, serviceName := e.path, e.serviceName
+	if len(t.Path) > 0 {
+		path = t.Path
+	}
+
+	if len(t.ServiceName) > 0 {
+		serviceName = t.ServiceName
+	}
+
+	i, err := newEtcdInstancer(e.logger, e.client, e.timeout, etcdPrefix(path, serviceName), e.after)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (e *etcdProvider) Close() error {
+	return e.client.closer.Close()
+}
+
+// etcdPrefix is the key prefix under which the registrations for a service are stored
+func etcdPrefix(path, serviceName string) string {
+	return strings.TrimSuffix(path, "/") + "/" + serviceName + "/"
+}
+
+// newEtcdProvider constructs the etcd provider.  Each registration is stored under the key path/serviceName/registration,
+// with the registration and any metadata as its value, and instancers watch the path/serviceName/ prefix.
+func newEtcdProvider(o *Options) (Provider, error) {
+	var (
+		eo           = o.etcd()
+		registration = o.registration()
+		path         = o.path()
+		serviceName  = o.serviceName()
+		prefix       = etcdPrefix(path, serviceName)
+		timeout      = eo.dialTimeout()
+		registrar    sd.Registrar
+		measures     = NewMeasures(o.metricsProvider())
+		logger       = logging.DefaultCaller(o.logger(), "serviceName", serviceName, "backend", EtcdBackend, "path", path, "registration", registration)
+
+		// use the internal singleton factory function, which is set to newEtcdClient normally
+		client, err = etcdClientFactory(clientv3.Config{
+			Endpoints:   eo.endpoints(),
+			DialTimeout: timeout,
+			Username:    eo.username(),
+			Password:    eo.password(),
+		})
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(registration) > 0 {
+		registrar = &etcdRegistrar{
+			logger:   logger,
+			kv:       client.kv,
+			lease:    client.lease,
+			timeout:  timeout,
+			key:      prefix + registration,
+			value:    o.encodedRegistration(),
+			ttl:      eo.ttlSeconds(),
+			after:    o.after(),
+			lost:     o.registrationLost(),
+			restored: o.registrationRestored(),
+			attempts: measures.RegistrationAttempts.With(ServiceLabel, serviceName),
+			failures: measures.RegistrationFailures.With(ServiceLabel, serviceName),
+		}
+	}
+
+	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
+
+	return &etcdProvider{
+		logger:      logger,
+		client:      client,
+		timeout:     timeout,
+		path:        path,
+		serviceName: serviceName,
+		after:       o.after(),
+		registrar:   registrar,
+	}, nil
+}
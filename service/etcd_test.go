@@ -0,0 +1,468 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// etcdGetResponse produces a clientv3.GetResponse with one key for each instance
+func etcdGetResponse(prefix string, revision int64, instances ...string) *clientv3.GetResponse {
+	response := &clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: revision},
+	}
+
+	for _, instance := range instances {
+		response.Kvs = append(response.Kvs, &mvccpb.KeyValue{
+			Key:   []byte(prefix + instance),
+			Value: []byte(instance),
+		})
+	}
+
+	return response
+}
+
+// expectWatch sets an expectation for a watch on the given prefix.  The returned channel is closed when the
+// watch's context is canceled, as with a real etcd client.
+func expectWatch(watcher *mockEtcdWatcher, prefix string) chan clientv3.WatchResponse {
+	watch := make(chan clientv3.WatchResponse)
+	watcher.On("Watch", mock.Anything, prefix).
+		Return(clientv3.WatchChan(watch)).
+		Run(func(arguments mock.Arguments) {
+			ctx := arguments.Get(0).(context.Context)
+			go func() {
+				<-ctx.Done()
+				close(watch)
+			}()
+		}).
+		Once()
+
+	return watch
+}
+
+// expectKeepAlive sets an expectation for keeping a lease alive.  The returned channel is closed when the
+// keepalive's context is canceled.
+func expectKeepAlive(lease *mockEtcdLease, leaseID clientv3.LeaseID) chan *clientv3.LeaseKeepAliveResponse {
+	keepAlive := make(chan *clientv3.LeaseKeepAliveResponse)
+	lease.On("KeepAlive", mock.Anything, leaseID).
+		Return((<-chan *clientv3.LeaseKeepAliveResponse)(keepAlive), error(nil)).
+		Run(func(arguments mock.Arguments) {
+			ctx := arguments.Get(0).(context.Context)
+			go func() {
+				<-ctx.Done()
+				close(keepAlive)
+			}()
+		}).
+		Once()
+
+	return keepAlive
+}
+
+func testEtcdFacade(t *testing.T, o *Options, expectedPrefix string) {
+	defer resetEtcdClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		kv      = new(mockEtcdKV)
+		lease   = new(mockEtcdLease)
+		watcher = new(mockEtcdWatcher)
+
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	etcdClientFactory = func(config clientv3.Config) (etcdClient, error) {
+		assert.Equal(o.etcd().endpoints(), config.Endpoints)
+		assert.Equal(o.etcd().dialTimeout(), config.DialTimeout)
+		assert.Equal(o.etcd().username(), config.Username)
+		assert.Equal(o.etcd().password(), config.Password)
+		return etcdClient{kv: kv, lease: lease, watcher: watcher, closer: watcher}, nil
+	}
+
+	if registration := o.registration(); len(registration) > 0 {
+		lease.On("Grant", o.etcd().ttlSeconds()).Return(&clientv3.LeaseGrantResponse{ID: 123, TTL: o.etcd().ttlSeconds()}, error(nil)).Once()
+		kv.On("Put", expectedPrefix+registration, o.encodedRegistration()).Return(new(clientv3.PutResponse), error(nil)).Once()
+		expectKeepAlive(lease, 123)
+		lease.On("Revoke", clientv3.LeaseID(123)).Return(new(clientv3.LeaseRevokeResponse), error(nil)).Once()
+	}
+
+	kv.On("Get", expectedPrefix).Return(etcdGetResponse(expectedPrefix, 5, "instance1", "instance2"), error(nil)).Once()
+	kv.On("Get", expectedPrefix).Return(etcdGetResponse(expectedPrefix, 6, "instance1", "instance2", "instance3"), error(nil)).Once()
+	watch := expectWatch(watcher, expectedPrefix)
+	watcher.On("Close").Return(error(nil)).Once()
+
+	service, err := New(o)
+	require.NotNil(service)
+	require.NoError(err)
+
+	service.Register()
+	service.Register() // idempotency
+	service.Deregister()
+	service.Deregister() // idempotency
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	assert.NoError(err)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"instance1", "instance2"}}, <-instanceEvents)
+
+	watch <- clientv3.WatchResponse{Header: etcdserverpb.ResponseHeader{Revision: 6}}
+	assert.Equal(sd.Event{Instances: []string{"instance1", "instance2", "instance3"}}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+
+	// need to do this to terminate the goroutine
+	i.Stop()
+	i.Stop() // idempotency
+
+	assert.NoError(service.Close())
+	assert.NoError(service.Close()) // idempotency
+
+	kv.AssertExpectations(t)
+	lease.AssertExpectations(t)
+	watcher.AssertExpectations(t)
+}
+
+func testEtcdFacadeClientFactoryError(t *testing.T) {
+	defer resetEtcdClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	etcdClientFactory = func(clientv3.Config) (etcdClient, error) {
+		return etcdClient{}, expectedError
+	}
+
+	service, err := New(&Options{Backend: EtcdBackend})
+	assert.Nil(service)
+	assert.Equal(expectedError, err)
+}
+
+func testEtcdFacadeInstancerError(t *testing.T) {
+	defer resetEtcdClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		kv            = new(mockEtcdKV)
+	)
+
+	etcdClientFactory = func(clientv3.Config) (etcdClient, error) {
+		return etcdClient{kv: kv}, nil
+	}
+
+	kv.On("Get", "/xmidt/test/").Return(nil, expectedError).Once()
+
+	service, err := New(&Options{Backend: EtcdBackend})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewInstancer()
+	assert.Nil(i)
+	assert.Equal(expectedError, err)
+	kv.AssertExpectations(t)
+}
+
+func testEtcdFacadeTarget(t *testing.T) {
+	defer resetEtcdClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		kv            = new(mockEtcdKV)
+	)
+
+	etcdClientFactory = func(clientv3.Config) (etcdClient, error) {
+		return etcdClient{kv: kv}, nil
+	}
+
+	// the target's prefix is used in the initial read
+	kv.On("Get", "/xmidt/prod/petasos/").Return(nil, expectedError).Once()
+
+	service, err := New(&Options{Backend: EtcdBackend})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(WatchTarget{Path: "/xmidt/prod", ServiceName: "petasos"})
+	assert.Nil(i)
+	assert.Equal(expectedError, err)
+	kv.AssertExpectations(t)
+}
+
+func TestEtcdFacade(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testEtcdFacade(t, &Options{Backend: EtcdBackend}, "/xmidt/test/")
+	})
+
+	t.Run("Nontrivial", func(t *testing.T) {
+		testEtcdFacade(
+			t,
+			&Options{
+				Backend:      EtcdBackend,
+				Path:         "/foo/bar/",
+				ServiceName:  "testing",
+				Registration: "https://localhost:1400",
+				Etcd: &EtcdOptions{
+					Endpoints:   []string{"host1:2379", "host2:2379"},
+					DialTimeout: 17 * time.Second,
+					TTL:         1500 * time.Millisecond,
+					Username:    "user",
+					Password:    "password",
+				},
+			},
+			"/foo/bar/testing/",
+		)
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		testEtcdFacade(
+			t,
+			&Options{
+				Backend:      EtcdBackend,
+				Registration: "https://localhost:1400",
+				Metadata:     Metadata{"version": "1.2.3", "weight": "10"},
+			},
+			"/xmidt/test/",
+		)
+	})
+
+	t.Run("ClientFactoryError", testEtcdFacadeClientFactoryError)
+	t.Run("InstancerError", testEtcdFacadeInstancerError)
+	t.Run("Target", testEtcdFacadeTarget)
+}
+
+func newTestEtcdRegistrar(t *testing.T, kv *mockEtcdKV, lease *mockEtcdLease) *etcdRegistrar {
+	return &etcdRegistrar{
+		logger:   logging.NewTestLogger(nil, t),
+		kv:       kv,
+		lease:    lease,
+		timeout:  time.Second,
+		key:      "/xmidt/test/localhost:8080",
+		value:    "localhost:8080",
+		ttl:      30,
+		after:    time.After,
+		lost:     func(error) {},
+		restored: func() {},
+		attempts: generic.NewCounter("attempts"),
+		failures: generic.NewCounter("failures"),
+	}
+}
+
+func (r *etcdRegistrar) registered() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cancel != nil
+}
+
+func testEtcdRegistrarLeaseLost(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		kv     = new(mockEtcdKV)
+		lease  = new(mockEtcdLease)
+		r      = newTestEtcdRegistrar(t, kv, lease)
+
+		lostKeepAlive = make(chan *clientv3.LeaseKeepAliveResponse)
+		lost          = make(chan error, 1)
+		restored      = make(chan struct{}, 1)
+		afterCalled   = make(chan time.Duration, 1)
+		afterFire     = make(chan time.Time)
+		expectedError = errors.New("expected")
+	)
+
+	r.lost = func(err error) { lost <- err }
+	r.restored = func() { restored <- struct{}{} }
+	r.after = func(d time.Duration) <-chan time.Time {
+		afterCalled <- d
+		return afterFire
+	}
+
+	lease.On("Grant", int64(30)).Return(&clientv3.LeaseGrantResponse{ID: 1, TTL: 30}, error(nil)).Once()
+	kv.On("Put", r.key, r.value).Return(new(clientv3.PutResponse), error(nil)).Twice()
+	lease.On("KeepAlive", mock.Anything, clientv3.LeaseID(1)).Return((<-chan *clientv3.LeaseKeepAliveResponse)(lostKeepAlive), error(nil)).Once()
+
+	r.Register()
+	assert.True(r.registered())
+
+	// the etcd client closes the keepalive channel when the lease cannot be kept alive
+	lostKeepAlive <- &clientv3.LeaseKeepAliveResponse{ID: 1, TTL: 30}
+	close(lostKeepAlive)
+	assert.Equal(errEtcdLeaseLost, <-lost)
+	assert.Equal(etcdRetryInterval, <-afterCalled)
+
+	// the registration is still wanted, so it is retried until it is restored with a new lease
+	assert.True(r.registered())
+	r.Register() // a nop while restoring
+
+	lease.On("Grant", int64(30)).Return(nil, expectedError).Once()
+	afterFire <- time.Now()
+	assert.Equal(etcdRetryInterval, <-afterCalled)
+
+	lease.On("Grant", int64(30)).Return(&clientv3.LeaseGrantResponse{ID: 2, TTL: 30}, error(nil)).Once()
+	expectKeepAlive(lease, 2)
+	lease.On("Revoke", clientv3.LeaseID(2)).Return(new(clientv3.LeaseRevokeResponse), error(nil)).Once()
+
+	afterFire <- time.Now()
+	<-restored
+	assert.True(r.registered())
+	assert.Equal(3.0, r.attempts.(*generic.Counter).Value())
+	assert.Equal(1.0, r.failures.(*generic.Counter).Value())
+
+	r.Deregister()
+	assert.False(r.registered())
+
+	kv.AssertExpectations(t)
+	lease.AssertExpectations(t)
+}
+
+func testEtcdRegistrarDeregisterWhileLost(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		kv     = new(mockEtcdKV)
+		lease  = new(mockEtcdLease)
+		r      = newTestEtcdRegistrar(t, kv, lease)
+
+		lostKeepAlive = make(chan *clientv3.LeaseKeepAliveResponse)
+		lost          = make(chan error, 1)
+		afterCalled   = make(chan time.Duration, 1)
+	)
+
+	r.lost = func(err error) { lost <- err }
+	r.restored = func() { assert.Fail("The registration should not have been restored") }
+	r.after = func(d time.Duration) <-chan time.Time {
+		afterCalled <- d
+		return nil
+	}
+
+	lease.On("Grant", int64(30)).Return(&clientv3.LeaseGrantResponse{ID: 1, TTL: 30}, error(nil)).Once()
+	kv.On("Put", r.key, r.value).Return(new(clientv3.PutResponse), error(nil)).Once()
+	lease.On("KeepAlive", mock.Anything, clientv3.LeaseID(1)).Return((<-chan *clientv3.LeaseKeepAliveResponse)(lostKeepAlive), error(nil)).Once()
+
+	r.Register()
+	close(lostKeepAlive)
+	assert.Equal(errEtcdLeaseLost, <-lost)
+	<-afterCalled
+
+	// there is no lease to revoke
+	r.Deregister()
+	assert.False(r.registered())
+
+	kv.AssertExpectations(t)
+	lease.AssertExpectations(t)
+}
+
+func testEtcdRegistrarFailure(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		kv            = new(mockEtcdKV)
+		lease         = new(mockEtcdLease)
+		r             = newTestEtcdRegistrar(t, kv, lease)
+		expectedError = errors.New("expected")
+	)
+
+	lease.On("Grant", int64(30)).Return(nil, expectedError).Once()
+	r.Register()
+	assert.False(r.registered())
+
+	// a failure after the lease is granted revokes the lease
+	lease.On("Grant", int64(30)).Return(&clientv3.LeaseGrantResponse{ID: 1, TTL: 30}, error(nil)).Once()
+	kv.On("Put", r.key, r.value).Return(nil, expectedError).Once()
+	lease.On("Revoke", clientv3.LeaseID(1)).Return(new(clientv3.LeaseRevokeResponse), error(nil)).Once()
+	r.Register()
+	assert.False(r.registered())
+
+	lease.On("Grant", int64(30)).Return(&clientv3.LeaseGrantResponse{ID: 2, TTL: 30}, error(nil)).Once()
+	kv.On("Put", r.key, r.value).Return(new(clientv3.PutResponse), error(nil)).Once()
+	lease.On("KeepAlive", mock.Anything, clientv3.LeaseID(2)).Return(nil, expectedError).Once()
+	lease.On("Revoke", clientv3.LeaseID(2)).Return(nil, expectedError).Once()
+	r.Register()
+	assert.False(r.registered())
+	assert.Equal(3.0, r.attempts.(*generic.Counter).Value())
+	assert.Equal(3.0, r.failures.(*generic.Counter).Value())
+
+	// Deregister does nothing when not registered
+	r.Deregister()
+
+	kv.AssertExpectations(t)
+	lease.AssertExpectations(t)
+}
+
+func TestEtcdRegistrar(t *testing.T) {
+	t.Run("LeaseLost", testEtcdRegistrarLeaseLost)
+	t.Run("DeregisterWhileLost", testEtcdRegistrarDeregisterWhileLost)
+	t.Run("Failure", testEtcdRegistrarFailure)
+}
+
+func testEtcdInstancerResynchronize(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		prefix  = "/xmidt/test/"
+
+		kv      = new(mockEtcdKV)
+		watcher = new(mockEtcdWatcher)
+
+		expectedError = errors.New("expected")
+		afterCalled   = make(chan time.Duration, 1)
+		after         = func(d time.Duration) <-chan time.Time {
+			afterCalled <- d
+			c := make(chan time.Time, 1)
+			c <- time.Now()
+			return c
+		}
+
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	kv.On("Get", prefix).Return(etcdGetResponse(prefix, 5, "instance1"), error(nil)).Once()
+	firstWatch := expectWatch(watcher, prefix)
+
+	i, err := newEtcdInstancer(logging.NewTestLogger(nil, t), etcdClient{kv: kv, watcher: watcher}, time.Second, prefix, after)
+	require.NotNil(i)
+	require.NoError(err)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"instance1"}}, <-instanceEvents)
+
+	// a compacted watch causes the instances to be listed again, retrying until the list succeeds
+	kv.On("Get", prefix).Return(nil, expectedError).Once()
+	kv.On("Get", prefix).Return(etcdGetResponse(prefix, 10, "instance2"), error(nil)).Once()
+	secondWatch := expectWatch(watcher, prefix)
+
+	firstWatch <- clientv3.WatchResponse{CompactRevision: 8}
+	assert.Equal(sd.Event{Err: expectedError}, <-instanceEvents)
+	assert.Equal(etcdRetryInterval, <-afterCalled)
+	assert.Equal(sd.Event{Instances: []string{"instance2"}}, <-instanceEvents)
+
+	// changes which leave the instances the same are not dispatched
+	kv.On("Get", prefix).Return(etcdGetResponse(prefix, 11, "instance2"), error(nil)).Once()
+	kv.On("Get", prefix).Return(etcdGetResponse(prefix, 12, "instance2", "instance3"), error(nil)).Once()
+	secondWatch <- clientv3.WatchResponse{Header: etcdserverpb.ResponseHeader{Revision: 11}}
+	secondWatch <- clientv3.WatchResponse{Header: etcdserverpb.ResponseHeader{Revision: 12}}
+	assert.Equal(sd.Event{Instances: []string{"instance2", "instance3"}}, <-instanceEvents)
+
+	i.Deregister(instanceEvents)
+	i.Stop()
+
+	kv.AssertExpectations(t)
+	watcher.AssertExpectations(t)
+}
+
+func TestEtcdInstancer(t *testing.T) {
+	t.Run("Resynchronize", testEtcdInstancerResynchronize)
+}
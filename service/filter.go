@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// InstancePredicate decides whether a discovered instance is of interest to a consumer.  The metadata is that
+// published with the instance's registration, and is nil for instances registered without metadata.
+type InstancePredicate func(instance string, m Metadata) bool
+
+// HasScheme matches instances with any of the given schemes, e.g. "https".  Instances of the form host:port
+// have no scheme, and only match the empty string.
+func HasScheme(schemes ...string) InstancePredicate {
+	return func(instance string, _ Metadata) bool {
+		scheme, _, _, err := parseRegistration(instance)
+		if err != nil {
+			return false
+		}
+
+		for _, s := range schemes {
+			if scheme == s {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// PortInRange matches instances whose port lies within [min, max].  Instances with a scheme but no port
+// use the default port for http or https, and instances without a usable port never match.
+func PortInRange(min, max int) InstancePredicate {
+	return func(instance string, _ Metadata) bool {
+		_, _, port, err := parseRegistration(instance)
+		return err == nil && port >= min && port <= max
+	}
+}
+
+// HasMetadata matches instances registered with the given metadata key and value
+func HasMetadata(key, value string) InstancePredicate {
+	return func(_ string, m Metadata) bool {
+		v, ok := m[key]
+		return ok && v == value
+	}
+}
+
+// AllOf matches instances which match every one of the given predicates.  With no predicates, every instance matches.
+func AllOf(predicates ...InstancePredicate) InstancePredicate {
+	return func(instance string, m Metadata) bool {
+		for _, p := range predicates {
+			if !p(instance, m) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// filterInstancer is the Instancer returned by FilterInstancer
+type filterInstancer struct {
+	*transformInstancer
+	predicate InstancePredicate
+	source    MetadataSource
+}
+
+// FilterInstancer decorates an Instancer so that only the instances matching the given predicate are
+// delivered to registered channels, and hence to the Accessors of subscriptions and watches.  This allows
+// consumers with different needs, e.g. only https instances or only those of a given version, to share
+// a single service discovery path.
+//
+// If the decorated Instancer is a MetadataSource, as the Instancers created by Interface.NewInstancer are,
+// the predicate is given each instance's metadata.  The returned Instancer is always a MetadataSource, so
+// that watches of filtered instances still carry their metadata.
+func FilterInstancer(i Instancer, p InstancePredicate) Instancer {
+	fi := &filterInstancer{predicate: p}
+	fi.source, _ = i.(MetadataSource)
+	fi.transformInstancer = newEventTransformInstancer(i, fi.filter)
+	return fi
+}
+
+func (fi *filterInstancer) filter(e sd.Event) sd.Event {
+	if e.Err != nil || len(e.Instances) == 0 {
+		return e
+	}
+
+	instances := make([]string, 0, len(e.Instances))
+	for _, instance := range e.Instances {
+		trimmed := strings.TrimSpace(instance)
+		if fi.predicate(trimmed, fi.Metadata(trimmed)) {
+			instances = append(instances, instance)
+		}
+	}
+
+	return sd.Event{Instances: instances}
+}
+
+func (fi *filterInstancer) Metadata(instance string) Metadata {
+	if fi.source != nil {
+		return fi.source.Metadata(instance)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testInstancePredicateHasScheme(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = HasScheme("https", "")
+	)
+
+	assert.True(p("https://host1:8443", nil))
+	assert.True(p("https://host1", nil))
+	assert.True(p("host1:8080", nil))
+	assert.False(p("http://host1:8080", nil))
+	assert.False(p("not a valid instance", nil))
+	assert.False(HasScheme()("https://host1:8443", nil))
+}
+
+func testInstancePredicatePortInRange(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = PortInRange(443, 8443)
+	)
+
+	assert.True(p("https://host1", nil))
+	assert.True(p("https://host1:8443", nil))
+	assert.True(p("host1:8080", nil))
+	assert.False(p("http://host1", nil))
+	assert.False(p("http://host1:9000", nil))
+	assert.False(p("ftp://host1", nil))
+	assert.False(p("host1", nil))
+}
+
+func testInstancePredicateHasMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = HasMetadata("version", "1.2.3")
+	)
+
+	assert.True(p("http://host1:8080", Metadata{"version": "1.2.3", DatacenterKey: "east"}))
+	assert.False(p("http://host1:8080", Metadata{"version": "1.2.4"}))
+	assert.False(p("http://host1:8080", Metadata{}))
+	assert.False(p("http://host1:8080", nil))
+	assert.True(HasMetadata("version", "")("http://host1:8080", Metadata{"version": ""}))
+	assert.False(HasMetadata("version", "")("http://host1:8080", nil))
+}
+
+func testInstancePredicateAllOf(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = AllOf(HasScheme("https"), HasMetadata(DatacenterKey, "east"))
+	)
+
+	assert.True(p("https://host1:8443", Metadata{DatacenterKey: "east"}))
+	assert.False(p("http://host1:8080", Metadata{DatacenterKey: "east"}))
+	assert.False(p("https://host1:8443", Metadata{DatacenterKey: "west"}))
+	assert.True(AllOf()("anything", nil))
+}
+
+func TestInstancePredicate(t *testing.T) {
+	t.Run("HasScheme", testInstancePredicateHasScheme)
+	t.Run("PortInRange", testInstancePredicatePortInRange)
+	t.Run("HasMetadata", testInstancePredicateHasMetadata)
+	t.Run("AllOf", testInstancePredicateAllOf)
+}
+
+func TestFilterInstancer(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		instancer = new(mockInstancer)
+		relay     chan<- sd.Event
+
+		fi     = FilterInstancer(newMetadataInstancer(instancer), AllOf(HasScheme("https"), HasMetadata("version", "1.2.3")))
+		events = make(chan sd.Event, 1)
+	)
+
+	require.Implements((*MetadataSource)(nil), fi)
+
+	instancer.On("Register", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		relay = ch
+		return true
+	})).Once()
+
+	instancer.On("Deregister", mock.Anything).Once()
+	instancer.On("Stop").Once()
+
+	fi.Register(events)
+	require.NotNil(relay)
+
+	relay <- sd.Event{Instances: []string{
+		EncodeRegistration("https://host1:8443", Metadata{"version": "1.2.3"}),
+		EncodeRegistration("https://host2:8443", Metadata{"version": "1.2.4"}),
+		EncodeRegistration("http://host3:8080", Metadata{"version": "1.2.3"}),
+		"https://host4:8443",
+	}}
+
+	select {
+	case e := <-events:
+		assert.Equal(sd.Event{Instances: []string{"https://host1:8443"}}, e)
+	case <-time.After(time.Second):
+		require.Fail("No event was relayed")
+	}
+
+	assert.Equal(Metadata{"version": "1.2.3"}, fi.(MetadataSource).Metadata("https://host1:8443"))
+
+	// errors are passed through unfiltered
+	relay <- sd.Event{Err: ErrNoInstances}
+	select {
+	case e := <-events:
+		assert.Equal(sd.Event{Err: ErrNoInstances}, e)
+	case <-time.After(time.Second):
+		require.Fail("No event was relayed")
+	}
+
+	fi.Deregister(events)
+	fi.Stop()
+	instancer.AssertExpectations(t)
+
+	// without a metadata source, the predicate sees no metadata
+	assert.Nil(FilterInstancer(instancer, AllOf()).(MetadataSource).Metadata("https://host1:8443"))
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// HealthCheck reports whether this process is fit to receive traffic.  A nil error indicates a healthy process.
+type HealthCheck func() error
+
+// healthRegistrar is a Registrar decorator which gates a registration on a HealthCheck.  While registered,
+// the check is run periodically.  Sustained failures deregister this process, so that it drops out of the
+// consistent hash of its clients, and sustained successes afterward register it again.
+type healthRegistrar struct {
+	logger           log.Logger
+	registrar        Registrar
+	check            HealthCheck
+	interval         time.Duration
+	failureThreshold int
+	successThreshold int
+	after            func(time.Duration) <-chan time.Time
+	lost             func(error)
+	restored         func()
+
+	lock sync.Mutex
+	stop chan struct{}
+	done chan bool
+}
+
+func newHealthRegistrar(logger log.Logger, registrar Registrar, o *Options) *healthRegistrar {
+	ho := o.health()
+	return &healthRegistrar{
+		logger:           logger,
+		registrar:        registrar,
+		check:            ho.check(),
+		interval:         ho.interval(),
+		failureThreshold: ho.failureThreshold(),
+		successThreshold: ho.successThreshold(),
+		after:            o.after(),
+		lost:             o.registrationLost(),
+		restored:         o.registrationRestored(),
+	}
+}
+
+func (h *healthRegistrar) Register() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.stop != nil {
+		return
+	}
+
+	h.registrar.Register()
+	h.stop, h.done = make(chan struct{}), make(chan bool, 1)
+	go h.monitor(h.stop, h.done)
+}
+
+func (h *healthRegistrar) Deregister() {
+	h.lock.Lock()
+	stop, done := h.stop, h.done
+	h.stop, h.done = nil, nil
+	h.lock.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	if registered := <-done; registered {
+		h.registrar.Deregister()
+	}
+}
+
+// monitor runs the health check until stop is closed, then sends on done whether the decorated
+// Registrar is still registered.
+func (h *healthRegistrar) monitor(stop <-chan struct{}, done chan<- bool) {
+	var (
+		registered = true
+		failures   int
+		successes  int
+	)
+
+	defer func() { done <- registered }()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-h.after(h.interval):
+		}
+
+		if err := h.check(); err != nil {
+			failures, successes = failures+1, 0
+			h.logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "health check failed", "failures", failures, logging.ErrorKey(), err)
+			if registered && failures >= h.failureThreshold {
+				h.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "deregistering unhealthy service", logging.ErrorKey(), err)
+				h.registrar.Deregister()
+				registered = false
+				h.lost(err)
+			}
+		} else {
+			failures, successes = 0, successes+1
+			if !registered && successes >= h.successThreshold {
+				h.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "registering recovered service")
+				h.registrar.Register()
+				registered = true
+				h.restored()
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestHealthRegistrar produces a healthRegistrar whose checks are driven by the returned function, which
+// delivers one interval tick and then supplies the given result to the health check
+func newTestHealthRegistrar(t *testing.T, registrar Registrar, o *Options) (*healthRegistrar, func(error)) {
+	var (
+		ticks   = make(chan time.Time)
+		results = make(chan error)
+	)
+
+	if o.Health == nil {
+		o.Health = new(HealthOptions)
+	}
+
+	o.Health.Check = func() error { return <-results }
+	o.After = func(d time.Duration) <-chan time.Time {
+		assert.Equal(t, o.Health.interval(), d)
+		return ticks
+	}
+
+	h := newHealthRegistrar(logging.NewTestLogger(nil, t), registrar, o)
+	return h, func(err error) {
+		ticks <- time.Time{}
+		results <- err
+	}
+}
+
+func testHealthRegistrarHealthy(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		registrar = new(mockRegistrar)
+		lost      bool
+
+		h, step = newTestHealthRegistrar(t, registrar, &Options{
+			Health:           &HealthOptions{Interval: 5 * time.Second, FailureThreshold: 2},
+			RegistrationLost: func(error) { lost = true },
+		})
+	)
+
+	registrar.On("Register").Once()
+	registrar.On("Deregister").Once()
+
+	h.Register()
+	h.Register() // idempotency
+
+	// isolated failures do not deregister
+	step(nil)
+	step(errors.New("expected"))
+	step(nil)
+	step(errors.New("expected"))
+	step(nil)
+
+	h.Deregister()
+	h.Deregister() // idempotency
+
+	assert.False(lost)
+	registrar.AssertExpectations(t)
+}
+
+func testHealthRegistrarUnhealthy(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		registrar     = new(mockRegistrar)
+		expectedError = errors.New("expected")
+		lostError     error
+		restored      bool
+
+		h, step = newTestHealthRegistrar(t, registrar, &Options{
+			Health:               &HealthOptions{FailureThreshold: 2, SuccessThreshold: 2},
+			RegistrationLost:     func(err error) { lostError = err },
+			RegistrationRestored: func() { restored = true },
+		})
+	)
+
+	registrar.On("Register").Twice()
+	registrar.On("Deregister").Twice()
+
+	h.Register()
+
+	step(expectedError)
+	step(expectedError)
+	step(expectedError)
+
+	// a success followed by a failure does not recover
+	step(nil)
+	step(expectedError)
+
+	step(nil)
+	step(nil)
+	step(nil)
+
+	h.Deregister()
+
+	assert.Equal(expectedError, lostError)
+	assert.True(restored)
+	registrar.AssertExpectations(t)
+}
+
+func testHealthRegistrarDeregisterWhileUnhealthy(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		registrar = new(mockRegistrar)
+		restored  bool
+
+		h, step = newTestHealthRegistrar(t, registrar, &Options{
+			Health:               &HealthOptions{FailureThreshold: 1},
+			RegistrationRestored: func() { restored = true },
+		})
+	)
+
+	registrar.On("Register").Once()
+	registrar.On("Deregister").Once()
+
+	h.Register()
+	step(errors.New("expected"))
+
+	// the registration was already removed, so this must not deregister again
+	h.Deregister()
+
+	assert.False(restored)
+	registrar.AssertExpectations(t)
+}
+
+func TestHealthRegistrar(t *testing.T) {
+	t.Run("Healthy", testHealthRegistrarHealthy)
+	t.Run("Unhealthy", testHealthRegistrarUnhealthy)
+	t.Run("DeregisterWhileUnhealthy", testHealthRegistrarDeregisterWhileUnhealthy)
+}
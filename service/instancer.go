@@ -0,0 +1,157 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// Instancer is an sd.Instancer which watches a service discovery backend until stopped.  All of the go-kit
+// instancers implement this interface, as do the instancers implemented by this package.
+type Instancer interface {
+	sd.Instancer
+
+	// Stop terminates the watch on the backend.  Registered channels receive no further events.
+	Stop()
+}
+
+// instanceCache holds the current state of an instancer implemented by this package, and dispatches changes
+// to registered channels in the same way as the go-kit instancers.  In particular, the current state is sent
+// to each channel as it is registered.
+type instanceCache struct {
+	lock     sync.Mutex
+	state    sd.Event
+	registry map[chan<- sd.Event]bool
+}
+
+func newInstanceCache(initial sd.Event) *instanceCache {
+	return &instanceCache{
+		state:    initial,
+		registry: make(map[chan<- sd.Event]bool),
+	}
+}
+
+// dispatch sends an event to every registered channel, unless it is identical to the current state.
+// This method returns true if the event was dispatched.
+func (ic *instanceCache) dispatch(e sd.Event) bool {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+
+	if e.Err == nil && ic.state.Err == nil && sameInstances(e.Instances, ic.state.Instances) {
+		return false
+	}
+
+	ic.state = e
+	for ch := range ic.registry {
+		ch <- e
+	}
+
+	return true
+}
+
+func sameInstances(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i := range left {
+		if left[i] != right[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (ic *instanceCache) Register(ch chan<- sd.Event) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+
+	ic.registry[ch] = true
+	ch <- ic.state
+}
+
+func (ic *instanceCache) Deregister(ch chan<- sd.Event) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+
+	delete(ic.registry, ch)
+}
+
+// withScheme produces an instance transform which prepends the given scheme, e.g. turning host:port into
+// https://host:port
+func withScheme(scheme string) func(string) string {
+	prefix := scheme + "://"
+	return func(instance string) string {
+		return prefix + instance
+	}
+}
+
+// transformInstancer decorates an instancer so that each event is rewritten, typically so that instances
+// from backends which only store host:port have the same scheme://host:port form as registrations
+// stored in Zookeeper.  Each registered channel is fed by a relay goroutine.
+type transformInstancer struct {
+	Instancer
+	transformEvent func(sd.Event) sd.Event
+
+	lock   sync.Mutex
+	relays map[chan<- sd.Event]chan sd.Event
+}
+
+// newTransformInstancer decorates an instancer so that each discovered instance is rewritten
+func newTransformInstancer(i Instancer, transform func(string) string) *transformInstancer {
+	return newEventTransformInstancer(i, func(e sd.Event) sd.Event {
+		if e.Err != nil || len(e.Instances) == 0 {
+			return e
+		}
+
+		instances := make([]string, len(e.Instances))
+		for i, instance := range e.Instances {
+			instances[i] = transform(instance)
+		}
+
+		return sd.Event{Instances: instances}
+	})
+}
+
+// newEventTransformInstancer decorates an instancer so that each event as a whole is rewritten
+func newEventTransformInstancer(i Instancer, transformEvent func(sd.Event) sd.Event) *transformInstancer {
+	return &transformInstancer{
+		Instancer:      i,
+		transformEvent: transformEvent,
+		relays:         make(map[chan<- sd.Event]chan sd.Event),
+	}
+}
+
+func (ti *transformInstancer) Register(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	if _, ok := ti.relays[ch]; ok {
+		ti.lock.Unlock()
+		return
+	}
+
+	relay := make(chan sd.Event)
+	ti.relays[ch] = relay
+	ti.lock.Unlock()
+
+	// the relay must be running first, as the current state is sent as part of registration
+	go func() {
+		for e := range relay {
+			ch <- ti.transformEvent(e)
+		}
+	}()
+
+	ti.Instancer.Register(relay)
+}
+
+func (ti *transformInstancer) Deregister(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	relay, ok := ti.relays[ch]
+	delete(ti.relays, ch)
+	ti.lock.Unlock()
+
+	if ok {
+		ti.Instancer.Deregister(relay)
+		close(relay)
+	}
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+)
+
+const (
+	// kubernetesRetryInterval is the time a Kubernetes instancer waits before retrying after it failed to list instances
+	kubernetesRetryInterval = time.Second
+
+	// kubernetesRequestTimeout bounds each list request made to the API server.  Watches are not bounded.
+	kubernetesRequestTimeout = 10 * time.Second
+
+	// kubernetesServiceNameLabel is the label that associates an EndpointSlice with its service
+	kubernetesServiceNameLabel = "kubernetes.io/service-name"
+)
+
+var (
+	errInvalidCAFile = errors.New("No certificates found in the Kubernetes CA file")
+)
+
+// kubernetesPort is a port, as it appears in both Endpoints subsets and EndpointSlices
+type kubernetesPort struct {
+	Name string `json:"name"`
+	Port int32  `json:"port"`
+}
+
+// kubernetesObject is the subset of either an Endpoints or an EndpointSlice resource used by this package.
+// Endpoints resources have Subsets, while EndpointSlice resources have Endpoints and Ports.
+type kubernetesObject struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+
+		Ports []kubernetesPort `json:"ports"`
+	} `json:"subsets"`
+
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+
+	Ports []kubernetesPort `json:"ports"`
+}
+
+// kubernetesList is a list response from the API server
+type kubernetesList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+
+	Items []kubernetesObject `json:"items"`
+}
+
+// kubernetesWatchEvent is a single event of a watch response from the API server
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// kubernetesStatus is the object of an ERROR watch event, or the body of an unsuccessful response
+type kubernetesStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+// findKubernetesPort returns the port with the given name, or the first port if name is empty
+func findKubernetesPort(ports []kubernetesPort, name string) (int32, bool) {
+	for _, p := range ports {
+		if len(name) == 0 || p.Name == name {
+			return p.Port, true
+		}
+	}
+
+	return 0, false
+}
+
+// kubernetesInstancer is an sd.Instancer which lists, then watches, the Endpoints or EndpointSlices of a
+// Kubernetes service.  Only ready addresses are instances.
+type kubernetesInstancer struct {
+	logger   log.Logger
+	client   *http.Client
+	token    func() (string, error)
+	resource string
+	query    url.Values
+	portName string
+	scheme   string
+	after    func(time.Duration) <-chan time.Time
+
+	cancel func()
+	done   chan struct{}
+
+	*instanceCache
+}
+
+// instances produces the instances represented by a single Endpoints or EndpointSlice object
+func (i *kubernetesInstancer) instances(o *kubernetesObject) []string {
+	var instances []string
+	add := func(address string, port int32) {
+		instance := net.JoinHostPort(address, strconv.Itoa(int(port)))
+		if len(i.scheme) > 0 {
+			instance = i.scheme + "://" + instance
+		}
+
+		instances = append(instances, instance)
+	}
+
+	for _, subset := range o.Subsets {
+		if port, ok := findKubernetesPort(subset.Ports, i.portName); ok {
+			for _, address := range subset.Addresses {
+				add(address.IP, port)
+			}
+		}
+	}
+
+	if port, ok := findKubernetesPort(o.Ports, i.portName); ok {
+		for _, endpoint := range o.Endpoints {
+			// an unknown readiness is interpreted as ready, per the EndpointSlice API
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				for _, address := range endpoint.Addresses {
+					add(address, port)
+				}
+			}
+		}
+	}
+
+	return instances
+}
+
+// mergeKubernetesInstances produces the sorted, distinct instances across all objects.  A single service can have several
+// EndpointSlices, and the same address may briefly appear in more than one of them.
+func mergeKubernetesInstances(objects map[string][]string) []string {
+	var (
+		seen      = make(map[string]bool)
+		instances = make([]string, 0, len(objects))
+	)
+
+	for _, o := range objects {
+		for _, instance := range o {
+			if !seen[instance] {
+				seen[instance] = true
+				instances = append(instances, instance)
+			}
+		}
+	}
+
+	sort.Strings(instances)
+	return instances
+}
+
+// newRequest creates an API server request for this instancer's resource, using the bearer token if there is one
+func (i *kubernetesInstancer) newRequest(ctx context.Context, query url.Values) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodGet, i.resource+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := i.token()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	request.Header.Set("Accept", "application/json")
+	return request.WithContext(ctx), nil
+}
+
+// do executes an API server request, returning an error if the response was not successful
+func (i *kubernetesInstancer) do(request *http.Request) (*http.Response, error) {
+	response, err := i.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+
+		var status kubernetesStatus
+		if err := json.NewDecoder(response.Body).Decode(&status); err == nil && len(status.Message) > 0 {
+			return nil, fmt.Errorf("Kubernetes API server responded with %d: %s", response.StatusCode, status.Message)
+		}
+
+		return nil, fmt.Errorf("Kubernetes API server responded with %d", response.StatusCode)
+	}
+
+	return response, nil
+}
+
+// list fetches the current instances of each object, along with the resource version of the list
+func (i *kubernetesInstancer) list(ctx context.Context) (map[string][]string, string, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, kubernetesRequestTimeout)
+	defer cancel()
+
+	request, err := i.newRequest(requestCtx, i.query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	response, err := i.do(request)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer response.Body.Close()
+
+	var list kubernetesList
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return nil, "", err
+	}
+
+	objects := make(map[string][]string, len(list.Items))
+	for k := range list.Items {
+		objects[list.Items[k].Metadata.Name] = i.instances(&list.Items[k])
+	}
+
+	return objects, list.Metadata.ResourceVersion, nil
+}
+
+// watch streams changes to the objects that occur after the given resource version, dispatching the instances
+// after each change.  This method returns when the watch ends, which the API server does periodically.
+func (i *kubernetesInstancer) watch(ctx context.Context, objects map[string][]string, resourceVersion string) error {
+	query := make(url.Values, len(i.query)+2)
+	for k, v := range i.query {
+		query[k] = v
+	}
+
+	query.Set("watch", "true")
+	query.Set("resourceVersion", resourceVersion)
+	request, err := i.newRequest(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	response, err := i.do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			var o kubernetesObject
+			if err := json.Unmarshal(event.Object, &o); err != nil {
+				return err
+			}
+
+			if event.Type == "DELETED" {
+				delete(objects, o.Metadata.Name)
+			} else {
+				objects[o.Metadata.Name] = i.instances(&o)
+			}
+
+			i.update(sd.Event{Instances: mergeKubernetesInstances(objects)})
+
+		case "ERROR":
+			// most commonly, the resource version is too old and the objects must be listed again
+			var status kubernetesStatus
+			json.Unmarshal(event.Object, &status)
+			return fmt.Errorf("Kubernetes watch failed with %d: %s", status.Code, status.Message)
+		}
+	}
+}
+
+// loop watches for changes until this instancer is stopped.  Whenever a watch ends, the objects are listed
+// again and a new watch is started from the resource version of that list.
+func (i *kubernetesInstancer) loop(ctx context.Context, objects map[string][]string, resourceVersion string) {
+	defer close(i.done)
+
+	for {
+		if err := i.watch(ctx, objects, resourceVersion); err != nil && ctx.Err() == nil {
+			i.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "kubernetes watch failed", logging.ErrorKey(), err)
+
+			// avoid hammering an API server which accepts lists but rejects watches
+			select {
+			case <-ctx.Done():
+				return
+			case <-i.after(kubernetesRetryInterval):
+			}
+		}
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			var err error
+			if objects, resourceVersion, err = i.list(ctx); err == nil {
+				i.update(sd.Event{Instances: mergeKubernetesInstances(objects)})
+				break
+			}
+
+			i.update(sd.Event{Err: err})
+			select {
+			case <-ctx.Done():
+				return
+			case <-i.after(kubernetesRetryInterval):
+			}
+		}
+	}
+}
+
+// update dispatches an event, logging any error
+func (i *kubernetesInstancer) update(e sd.Event) {
+	if i.dispatch(e) && e.Err != nil {
+		i.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to list instances from kubernetes", logging.ErrorKey(), e.Err)
+	}
+}
+
+// Stop terminates the watch on the API server and waits for it to finish.  This method is idempotent.
+func (i *kubernetesInstancer) Stop() {
+	i.cancel()
+	<-i.done
+}
+
+// kubernetesProvider is the Provider for the Kubernetes API.  Kubernetes maintains the endpoints of each service
+// itself, so registration is not supported.
+type kubernetesProvider struct {
+	logger         log.Logger
+	client         *http.Client
+	token          func() (string, error)
+	apiServer      string
+	namespace      string
+	service        string
+	endpointSlices bool
+	portName       string
+	scheme         string
+	after          func(time.Duration) <-chan time.Time
+}
+
+func (k *kubernetesProvider) Registrar() Registrar {
+	return nil
+}
+
+// NewInstancer lists the current endpoints of the service, then starts watching for changes.
+// If the initial list cannot be obtained, this method returns an error.
+func (k *kubernetesProvider) NewInstancer() (Instancer, error) {
+	return k.NewTargetInstancer(WatchTarget{})
+}
+
+// NewTargetInstancer watches the service named by the target's ServiceName, within the namespace
+// given by the target's Path.
+func (k *kubernetesProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	namespace, service := k.namespace, k.service
+	if len(t.Path) > 0 {
+		namespace = t.Path
+	}
+
+	if len(t.ServiceName) > 0 {
+		service = t.ServiceName
+	}
+
+	var (
+		resource string
+		query    = make(url.Values, 1)
+	)
+
+	if k.endpointSlices {
+		resource = k.apiServer + "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(namespace) + "/endpointslices"
+		query.Set("labelSelector", kubernetesServiceNameLabel+"="+service)
+	} else {
+		resource = k.apiServer + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/endpoints"
+		query.Set("fieldSelector", "metadata.name="+service)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &kubernetesInstancer{
+		logger:   k.logger,
+		client:   k.client,
+		token:    k.token,
+		resource: resource,
+		query:    query,
+		portName: k.portName,
+		scheme:   k.scheme,
+		after:    k.after,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	objects, resourceVersion, err := i.list(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	i.instanceCache = newInstanceCache(sd.Event{Instances: mergeKubernetesInstances(objects)})
+	go i.loop(ctx, objects, resourceVersion)
+	return i, nil
+}
+
+func (k *kubernetesProvider) Close() error {
+	return nil
+}
+
+// newKubernetesHTTPClient produces the client for API server requests, trusting the certificate authorities
+// in the CA file if there is one
+func newKubernetesHTTPClient(ko *KubernetesOptions) (*http.Client, error) {
+	if client := ko.httpClient(); client != nil {
+		return client, nil
+	}
+
+	caFile, required := ko.caFile()
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		if required || !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		return new(http.Client), nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errInvalidCAFile
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		},
+	}, nil
+}
+
+// newKubernetesToken produces the function which reads the bearer token.  The token file is read for each request,
+// as Kubernetes rotates service account tokens.
+func newKubernetesToken(ko *KubernetesOptions) func() (string, error) {
+	tokenFile, required := ko.tokenFile()
+	return func() (string, error) {
+		token, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			if required || !os.IsNotExist(err) {
+				return "", err
+			}
+
+			return "", nil
+		}
+
+		return string(bytes.TrimSpace(token)), nil
+	}
+}
+
+// newKubernetesProvider constructs the Kubernetes provider.  No network activity takes place until an instancer is created.
+func newKubernetesProvider(o *Options) (Provider, error) {
+	var (
+		ko           = o.kubernetes()
+		registration = o.registration()
+		namespace    = ko.namespace()
+		service      = ko.service()
+		scheme       = ko.scheme()
+	)
+
+	if len(service) == 0 {
+		service = o.serviceName()
+	}
+
+	if len(scheme) == 0 && len(registration) > 0 {
+		registrationScheme, _, _, err := parseRegistration(registration)
+		if err != nil {
+			return nil, err
+		}
+
+		scheme = registrationScheme
+	}
+
+	client, err := newKubernetesHTTPClient(ko)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.DefaultCaller(o.logger(), "serviceName", o.serviceName(), "backend", KubernetesBackend, "namespace", namespace, "service", service)
+	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
+
+	return &kubernetesProvider{
+		logger:         logger,
+		client:         client,
+		token:          newKubernetesToken(ko),
+		apiServer:      ko.apiServer(),
+		namespace:      namespace,
+		service:        service,
+		endpointSlices: ko.endpointSlices(),
+		portName:       ko.portName(),
+		scheme:         scheme,
+		after:          o.after(),
+	}, nil
+}
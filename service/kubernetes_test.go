@@ -0,0 +1,443 @@
+package service
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kubernetesResponse is a canned API server response to a list request
+type kubernetesResponse struct {
+	code int
+	body string
+}
+
+// fakeKubernetesAPI is an http.Handler which serves canned list responses, and streams watch events
+type fakeKubernetesAPI struct {
+	requests chan *http.Request
+	lists    chan kubernetesResponse
+	watches  chan chan string
+}
+
+func newFakeKubernetesAPI() *fakeKubernetesAPI {
+	return &fakeKubernetesAPI{
+		requests: make(chan *http.Request, 10),
+		lists:    make(chan kubernetesResponse, 10),
+		watches:  make(chan chan string, 10),
+	}
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	f.requests <- request
+
+	if request.URL.Query().Get("watch") != "true" {
+		select {
+		case <-request.Context().Done():
+		case r := <-f.lists:
+			response.WriteHeader(r.code)
+			response.Write([]byte(r.body))
+		}
+
+		return
+	}
+
+	var events chan string
+	select {
+	case <-request.Context().Done():
+		return
+	case events = <-f.watches:
+	}
+
+	response.WriteHeader(http.StatusOK)
+	response.(http.Flusher).Flush()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintln(response, event)
+			response.(http.Flusher).Flush()
+		}
+	}
+}
+
+func writeTempFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "kubernetes")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(contents)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func testKubernetesFacadeEndpoints(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		api            = newFakeKubernetesAPI()
+		server         = httptest.NewServer(api)
+		tokenFile      = writeTempFile(t, "token\n")
+		events         = make(chan string, 2)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	defer server.Close()
+	defer os.Remove(tokenFile)
+
+	api.lists <- kubernetesResponse{
+		code: http.StatusOK,
+		body: `{"metadata": {"resourceVersion": "100"}, "items": [{
+			"metadata": {"name": "talaria", "resourceVersion": "99"},
+			"subsets": [
+				{"addresses": [{"ip": "10.0.0.2"}, {"ip": "10.0.0.1"}], "ports": [{"name": "metrics", "port": 9090}, {"name": "http", "port": 8080}]},
+				{"addresses": [{"ip": "10.0.0.3"}], "ports": [{"name": "metrics", "port": 9090}]}
+			]
+		}]}`,
+	}
+
+	api.watches <- events
+
+	service, err := New(&Options{
+		Logger:       logging.NewTestLogger(nil, t),
+		Backend:      KubernetesBackend,
+		ServiceName:  "talaria",
+		Registration: "https://talaria-0.talaria.xmidt.svc:8080",
+		Kubernetes: &KubernetesOptions{
+			APIServer: server.URL,
+			Namespace: "xmidt",
+			PortName:  "http",
+			TokenFile: tokenFile,
+		},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+
+	// registration is a nop
+	service.Register()
+	service.Deregister()
+	assert.Empty(api.requests)
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	require.NoError(err)
+
+	list := <-api.requests
+	assert.Equal("/api/v1/namespaces/xmidt/endpoints", list.URL.Path)
+	assert.Equal(url.Values{"fieldSelector": {"metadata.name=talaria"}}, list.URL.Query())
+	assert.Equal("Bearer token", list.Header.Get("Authorization"))
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"https://10.0.0.1:8080", "https://10.0.0.2:8080"}}, <-instanceEvents)
+
+	watch := <-api.requests
+	assert.Equal("/api/v1/namespaces/xmidt/endpoints", watch.URL.Path)
+	assert.Equal(
+		url.Values{"fieldSelector": {"metadata.name=talaria"}, "watch": {"true"}, "resourceVersion": {"100"}},
+		watch.URL.Query(),
+	)
+
+	events <- `{"type": "MODIFIED", "object": {"metadata": {"name": "talaria"}, "subsets": [{"addresses": [{"ip": "10.0.0.4"}], "ports": [{"name": "http", "port": 8080}]}]}}`
+	assert.Equal(sd.Event{Instances: []string{"https://10.0.0.4:8080"}}, <-instanceEvents)
+
+	events <- `{"type": "DELETED", "object": {"metadata": {"name": "talaria"}}}`
+	assert.Equal(sd.Event{Instances: []string{}}, <-instanceEvents)
+
+	i.Deregister(instanceEvents)
+	i.Stop()
+	i.Stop() // idempotency
+
+	assert.NoError(service.Close())
+}
+
+func testKubernetesFacadeTarget(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		api            = newFakeKubernetesAPI()
+		server         = httptest.NewServer(api)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	defer server.Close()
+
+	api.lists <- kubernetesResponse{
+		code: http.StatusOK,
+		body: `{"metadata": {"resourceVersion": "100"}, "items": [{"metadata": {"name": "petasos"}, "subsets": [{"addresses": [{"ip": "10.0.0.1"}], "ports": [{"port": 6400}]}]}]}`,
+	}
+
+	api.watches <- make(chan string)
+
+	service, err := New(&Options{
+		Logger:      logging.NewTestLogger(nil, t),
+		Backend:     KubernetesBackend,
+		ServiceName: "talaria",
+		Kubernetes: &KubernetesOptions{
+			APIServer: server.URL,
+			Namespace: "xmidt",
+		},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(WatchTarget{Path: "codex", ServiceName: "petasos"})
+	require.NotNil(i)
+	require.NoError(err)
+
+	list := <-api.requests
+	assert.Equal("/api/v1/namespaces/codex/endpoints", list.URL.Path)
+	assert.Equal(url.Values{"fieldSelector": {"metadata.name=petasos"}}, list.URL.Query())
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"10.0.0.1:6400"}}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+
+	i.Stop()
+	assert.NoError(service.Close())
+}
+
+func testKubernetesFacadeEndpointSlices(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		api            = newFakeKubernetesAPI()
+		server         = httptest.NewTLSServer(api)
+		caFile         = writeTempFile(t, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]})))
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	defer server.Close()
+	defer os.Remove(caFile)
+
+	api.lists <- kubernetesResponse{
+		code: http.StatusOK,
+		body: `{"metadata": {"resourceVersion": "100"}, "items": [
+			{
+				"metadata": {"name": "talaria-abcde"},
+				"endpoints": [
+					{"addresses": ["10.0.0.2"], "conditions": {"ready": true}},
+					{"addresses": ["10.0.0.3"], "conditions": {"ready": false}},
+					{"addresses": ["10.0.0.1"]}
+				],
+				"ports": [{"name": "http", "port": 8080}]
+			},
+			{
+				"metadata": {"name": "talaria-fghij"},
+				"endpoints": [{"addresses": ["10.0.0.1"]}, {"addresses": ["10.0.0.5"]}],
+				"ports": [{"name": "http", "port": 8080}]
+			}
+		]}`,
+	}
+
+	service, err := New(&Options{
+		Logger:      logging.NewTestLogger(nil, t),
+		Backend:     KubernetesBackend,
+		ServiceName: "ignored",
+		Kubernetes: &KubernetesOptions{
+			APIServer:      server.URL,
+			Namespace:      "xmidt",
+			Service:        "talaria",
+			EndpointSlices: true,
+			CAFile:         caFile,
+		},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	require.NoError(err)
+
+	list := <-api.requests
+	assert.Equal("/apis/discovery.k8s.io/v1/namespaces/xmidt/endpointslices", list.URL.Path)
+	assert.Equal(url.Values{"labelSelector": {"kubernetes.io/service-name=talaria"}}, list.URL.Query())
+	assert.Empty(list.Header.Get("Authorization"))
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.5:8080"}}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+
+	i.Stop()
+	assert.NoError(service.Close())
+}
+
+func testKubernetesFacadeInstancerError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		api    = newFakeKubernetesAPI()
+		server = httptest.NewServer(api)
+	)
+
+	defer server.Close()
+
+	api.lists <- kubernetesResponse{
+		code: http.StatusForbidden,
+		body: `{"kind": "Status", "message": "endpoints is forbidden", "reason": "Forbidden", "code": 403}`,
+	}
+
+	service, err := New(&Options{
+		Logger:     logging.NewTestLogger(nil, t),
+		Backend:    KubernetesBackend,
+		Kubernetes: &KubernetesOptions{APIServer: server.URL},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewInstancer()
+	assert.Nil(i)
+	assert.Error(err)
+
+	// a missing token file that was explicitly configured is an error
+	service, err = New(&Options{
+		Logger:     logging.NewTestLogger(nil, t),
+		Backend:    KubernetesBackend,
+		Kubernetes: &KubernetesOptions{APIServer: server.URL, TokenFile: "/nosuch/token"},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err = service.NewInstancer()
+	assert.Nil(i)
+	assert.Error(err)
+}
+
+func testKubernetesFacadeCAFileError(t *testing.T) {
+	assert := assert.New(t)
+
+	invalidCAFile := writeTempFile(t, "this is not a PEM file")
+	defer os.Remove(invalidCAFile)
+
+	service, err := New(&Options{Backend: KubernetesBackend, Kubernetes: &KubernetesOptions{CAFile: invalidCAFile}})
+	assert.Nil(service)
+	assert.Equal(errInvalidCAFile, err)
+
+	service, err = New(&Options{Backend: KubernetesBackend, Kubernetes: &KubernetesOptions{CAFile: "/nosuch/ca.crt"}})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func testKubernetesFacadeInvalidRegistration(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := New(&Options{Backend: KubernetesBackend, Registration: "https://talaria:notaport"})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func TestKubernetesFacade(t *testing.T) {
+	t.Run("Endpoints", testKubernetesFacadeEndpoints)
+	t.Run("EndpointSlices", testKubernetesFacadeEndpointSlices)
+	t.Run("Target", testKubernetesFacadeTarget)
+	t.Run("InstancerError", testKubernetesFacadeInstancerError)
+	t.Run("CAFileError", testKubernetesFacadeCAFileError)
+	t.Run("InvalidRegistration", testKubernetesFacadeInvalidRegistration)
+}
+
+func testKubernetesInstancerResynchronize(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		api            = newFakeKubernetesAPI()
+		server         = httptest.NewServer(api)
+		events         = make(chan string, 1)
+		instanceEvents = make(chan sd.Event, 1)
+
+		afterCalled = make(chan time.Duration, 1)
+		afterFire   = make(chan time.Time)
+		after       = func(d time.Duration) <-chan time.Time {
+			afterCalled <- d
+			return afterFire
+		}
+	)
+
+	defer server.Close()
+
+	api.lists <- kubernetesResponse{
+		code: http.StatusOK,
+		body: `{"metadata": {"resourceVersion": "100"}, "items": [{"metadata": {"name": "talaria"}, "subsets": [{"addresses": [{"ip": "10.0.0.1"}], "ports": [{"port": 8080}]}]}]}`,
+	}
+
+	api.watches <- events
+
+	provider, err := newKubernetesProvider(&Options{
+		Logger:      logging.NewTestLogger(nil, t),
+		Backend:     KubernetesBackend,
+		ServiceName: "talaria",
+		Kubernetes:  &KubernetesOptions{APIServer: server.URL, Namespace: "xmidt"},
+		After:       after,
+	})
+
+	require.NotNil(provider)
+	require.NoError(err)
+
+	i, err := provider.NewInstancer()
+	require.NotNil(i)
+	require.NoError(err)
+	<-api.requests
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"10.0.0.1:8080"}}, <-instanceEvents)
+	<-api.requests
+
+	// an expired resource version ends the watch, after which the objects are listed again
+	events <- `{"type": "ERROR", "object": {"kind": "Status", "message": "too old resource version", "reason": "Expired", "code": 410}}`
+	assert.Equal(kubernetesRetryInterval, <-afterCalled)
+
+	// a list failure is dispatched, and the list is retried
+	api.lists <- kubernetesResponse{code: http.StatusInternalServerError, body: "internal error"}
+	afterFire <- time.Now()
+	<-api.requests
+
+	e := <-instanceEvents
+	assert.Error(e.Err)
+	assert.Equal(kubernetesRetryInterval, <-afterCalled)
+
+	// the retried list succeeds and a new watch is started from its resource version
+	events = make(chan string, 1)
+	api.watches <- events
+	api.lists <- kubernetesResponse{
+		code: http.StatusOK,
+		body: `{"metadata": {"resourceVersion": "200"}, "items": [{"metadata": {"name": "talaria"}, "subsets": [{"addresses": [{"ip": "10.0.0.2"}], "ports": [{"port": 8080}]}]}]}`,
+	}
+
+	afterFire <- time.Now()
+	<-api.requests
+	assert.Equal(sd.Event{Instances: []string{"10.0.0.2:8080"}}, <-instanceEvents)
+
+	watch := <-api.requests
+	assert.Equal("200", watch.URL.Query().Get("resourceVersion"))
+
+	i.Deregister(instanceEvents)
+	i.Stop()
+	assert.NoError(provider.Close())
+}
+
+func TestKubernetesInstancer(t *testing.T) {
+	t.Run("Resynchronize", testKubernetesInstancerResynchronize)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// DatacenterKey is the Metadata key holding the datacenter, or zone, of an instance.  See Options.Datacenter.
+const DatacenterKey = "datacenter"
+
+// Metadata is the set of arbitrary key/value pairs published along with a registration, e.g. a build version,
+// datacenter, or weight.
+type Metadata map[string]string
+
+// encodedRegistration is the form in which a registration with metadata is stored in a backend
+type encodedRegistration struct {
+	Instance string   `json:"instance"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// EncodeRegistration produces the value stored in a service discovery backend for the given instance and metadata.
+// A registration without metadata is stored as is, so that it is readable by clients unaware of metadata.
+func EncodeRegistration(instance string, m Metadata) string {
+	if len(m) == 0 {
+		return instance
+	}
+
+	// marshalling a struct of strings cannot fail
+	data, _ := json.Marshal(encodedRegistration{Instance: instance, Metadata: m})
+	return string(data)
+}
+
+// ParseRegistration is the inverse of EncodeRegistration.  Values which are not encoded registrations,
+// such as instances registered without metadata, are returned unchanged with nil Metadata.
+func ParseRegistration(value string) (string, Metadata) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return value, nil
+	}
+
+	var r encodedRegistration
+	if err := json.Unmarshal([]byte(value), &r); err != nil || len(r.Instance) == 0 {
+		return value, nil
+	}
+
+	return r.Instance, r.Metadata
+}
+
+// MetadataSource is implemented by Instancers which know the metadata published with each instance.
+// The Instancers returned by Interface.NewInstancer implement this interface.
+type MetadataSource interface {
+	// Metadata returns the metadata for an instance in the most recent event, or nil if that instance
+	// was registered without metadata
+	Metadata(instance string) Metadata
+}
+
+// metadataInstancer decodes the registrations from a backend, so that consumers only ever see instances,
+// and retains the metadata from the most recent event.
+type metadataInstancer struct {
+	*transformInstancer
+
+	lock     sync.RWMutex
+	metadata map[string]Metadata
+}
+
+func newMetadataInstancer(i Instancer) *metadataInstancer {
+	mi := new(metadataInstancer)
+	mi.transformInstancer = newEventTransformInstancer(i, mi.decode)
+	return mi
+}
+
+func (mi *metadataInstancer) decode(e sd.Event) sd.Event {
+	if e.Err != nil {
+		return e
+	}
+
+	var (
+		instances = make([]string, len(e.Instances))
+		metadata  = make(map[string]Metadata)
+	)
+
+	for i, value := range e.Instances {
+		instance, m := ParseRegistration(value)
+		instances[i] = instance
+		if len(m) > 0 {
+			metadata[strings.TrimSpace(instance)] = m
+		}
+	}
+
+	mi.lock.Lock()
+	mi.metadata = metadata
+	mi.lock.Unlock()
+
+	if len(e.Instances) == 0 {
+		return e
+	}
+
+	return sd.Event{Instances: instances}
+}
+
+func (mi *metadataInstancer) Metadata(instance string) Metadata {
+	mi.lock.RLock()
+	defer mi.lock.RUnlock()
+
+	return mi.metadata[instance]
+}
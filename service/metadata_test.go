@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegistrationEncode(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = Metadata{"version": "1.2.3", "datacenter": "east", "weight": "10"}
+	)
+
+	assert.Equal("http://localhost:8080", EncodeRegistration("http://localhost:8080", nil))
+	assert.Equal("http://localhost:8080", EncodeRegistration("http://localhost:8080", Metadata{}))
+
+	encoded := EncodeRegistration("http://localhost:8080", metadata)
+	assert.NotEqual("http://localhost:8080", encoded)
+
+	instance, actual := ParseRegistration(encoded)
+	assert.Equal("http://localhost:8080", instance)
+	assert.Equal(metadata, actual)
+}
+
+func testRegistrationParse(t *testing.T) {
+	testData := []struct {
+		value            string
+		expectedInstance string
+		expectedMetadata Metadata
+	}{
+		{"", "", nil},
+		{"localhost:8080", "localhost:8080", nil},
+		{"http://localhost:8080", "http://localhost:8080", nil},
+		{"{not json", "{not json", nil},
+		{`{"metadata": {"version": "1.2.3"}}`, `{"metadata": {"version": "1.2.3"}}`, nil},
+		{`{"instance": "http://localhost:8080"}`, "http://localhost:8080", nil},
+		{
+			`{"instance": "http://localhost:8080", "metadata": {"version": "1.2.3"}}`,
+			"http://localhost:8080",
+			Metadata{"version": "1.2.3"},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.value, func(t *testing.T) {
+			assert := assert.New(t)
+
+			instance, metadata := ParseRegistration(record.value)
+			assert.Equal(record.expectedInstance, instance)
+			assert.Equal(record.expectedMetadata, metadata)
+		})
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	t.Run("Encode", testRegistrationEncode)
+	t.Run("Parse", testRegistrationParse)
+}
+
+func TestMetadataInstancer(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		instancer = new(mockInstancer)
+		relay     chan<- sd.Event
+
+		mi     = newMetadataInstancer(instancer)
+		events = make(chan sd.Event, 1)
+	)
+
+	require.Implements((*MetadataSource)(nil), mi)
+
+	instancer.On("Register", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		relay = ch
+		return true
+	})).Once()
+
+	instancer.On("Deregister", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		return ch == relay
+	})).Once()
+
+	mi.Register(events)
+	require.NotNil(relay)
+
+	relay <- sd.Event{Instances: []string{
+		EncodeRegistration("http://host1:8080", Metadata{"version": "1.2.3"}),
+		"http://host2:8080",
+	}}
+
+	select {
+	case e := <-events:
+		assert.Equal(sd.Event{Instances: []string{"http://host1:8080", "http://host2:8080"}}, e)
+	case <-time.After(time.Second):
+		require.Fail("No event was relayed")
+	}
+
+	assert.Equal(Metadata{"version": "1.2.3"}, mi.Metadata("http://host1:8080"))
+	assert.Nil(mi.Metadata("http://host2:8080"))
+
+	// metadata only reflects the most recent event
+	relay <- sd.Event{Instances: []string{"http://host1:8080"}}
+	select {
+	case e := <-events:
+		assert.Equal(sd.Event{Instances: []string{"http://host1:8080"}}, e)
+	case <-time.After(time.Second):
+		require.Fail("No event was relayed")
+	}
+
+	assert.Nil(mi.Metadata("http://host1:8080"))
+
+	mi.Deregister(events)
+	instancer.AssertExpectations(t)
+}
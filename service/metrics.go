@@ -0,0 +1,72 @@
+package service
+
+import (
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	RegistrationAttemptCounter = "sd_registration_attempt_count"
+	RegistrationFailureCounter = "sd_registration_failure_count"
+	WatchUpdateCounter         = "sd_watch_update_count"
+	InstanceGauge              = "sd_instance_count"
+	ZookeeperSessionCounter    = "sd_zookeeper_session_event_count"
+
+	// ServiceLabel is the label for the name of the service being registered or watched
+	ServiceLabel = "service"
+
+	// StateLabel is the label for the Zookeeper session state that was transitioned to
+	StateLabel = "state"
+)
+
+// Metrics is the service discovery module function that adds default service discovery metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		xmetrics.Metric{
+			Name:       RegistrationAttemptCounter,
+			Type:       "counter",
+			LabelNames: []string{ServiceLabel},
+		},
+		xmetrics.Metric{
+			Name:       RegistrationFailureCounter,
+			Type:       "counter",
+			LabelNames: []string{ServiceLabel},
+		},
+		xmetrics.Metric{
+			Name:       WatchUpdateCounter,
+			Type:       "counter",
+			LabelNames: []string{ServiceLabel},
+		},
+		xmetrics.Metric{
+			Name:       InstanceGauge,
+			Type:       "gauge",
+			LabelNames: []string{ServiceLabel},
+		},
+		xmetrics.Metric{
+			Name:       ZookeeperSessionCounter,
+			Type:       "counter",
+			LabelNames: []string{StateLabel},
+		},
+	}
+}
+
+// Measures is a convenient struct that holds all the service discovery metric objects for runtime consumption
+type Measures struct {
+	RegistrationAttempts metrics.Counter
+	RegistrationFailures metrics.Counter
+	WatchUpdates         metrics.Counter
+	Instances            metrics.Gauge
+	ZookeeperSession     metrics.Counter
+}
+
+// NewMeasures constructs a Measures given a go-kit metrics Provider
+func NewMeasures(p provider.Provider) Measures {
+	return Measures{
+		RegistrationAttempts: p.NewCounter(RegistrationAttemptCounter),
+		RegistrationFailures: p.NewCounter(RegistrationFailureCounter),
+		WatchUpdates:         p.NewCounter(WatchUpdateCounter),
+		Instances:            p.NewGauge(InstanceGauge),
+		ZookeeperSession:     p.NewCounter(ZookeeperSessionCounter),
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	var (
+		require = require.New(t)
+	)
+
+	r, err := xmetrics.NewRegistry(nil, Metrics)
+	require.NoError(err)
+	require.NotNil(r)
+
+	for _, counterName := range []string{RegistrationAttemptCounter, RegistrationFailureCounter, WatchUpdateCounter} {
+		counter := r.NewCounter(counterName)
+		counter.With(ServiceLabel, "talaria").Add(1.0)
+	}
+
+	gauge := r.NewGauge(InstanceGauge)
+	gauge.With(ServiceLabel, "talaria").Set(3.0)
+
+	session := r.NewCounter(ZookeeperSessionCounter)
+	session.With(StateLabel, "StateHasSession").Add(1.0)
+}
+
+func TestNewMeasures(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		m      = NewMeasures(provider.NewDiscardProvider())
+	)
+
+	assert.NotNil(m.RegistrationAttempts)
+	assert.NotNil(m.RegistrationFailures)
+	assert.NotNil(m.WatchUpdates)
+	assert.NotNil(m.Instances)
+	assert.NotNil(m.ZookeeperSession)
+}
@@ -1,8 +1,16 @@
 package service
 
 import (
+	"context"
+	"math/rand"
+	"net"
+	"os/signal"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/sd"
 	"github.com/go-kit/kit/sd/zk"
+	consulapi "github.com/hashicorp/consul/api"
 	zkclient "github.com/samuel/go-zookeeper/zk"
 	"github.com/stretchr/testify/mock"
 )
@@ -40,6 +48,110 @@ func (m *mockClient) Stop() {
 	m.Called()
 }
 
+// resetConsulClientFactory resets the global singleton factory function
+// to its original value.  This function is handy as a defer for tests.
+func resetConsulClientFactory() {
+	consulClientFactory = newConsulClient
+}
+
+type mockConsulClient struct {
+	mock.Mock
+}
+
+func (m *mockConsulClient) Register(r *consulapi.AgentServiceRegistration) error {
+	return m.Called(r).Error(0)
+}
+
+func (m *mockConsulClient) Deregister(r *consulapi.AgentServiceRegistration) error {
+	return m.Called(r).Error(0)
+}
+
+func (m *mockConsulClient) Service(service, tag string, passingOnly bool, queryOpts *consulapi.QueryOptions) ([]*consulapi.ServiceEntry, *consulapi.QueryMeta, error) {
+	arguments := m.Called(service, tag, passingOnly, queryOpts)
+	entries, _ := arguments.Get(0).([]*consulapi.ServiceEntry)
+	meta, _ := arguments.Get(1).(*consulapi.QueryMeta)
+	return entries, meta, arguments.Error(2)
+}
+
+// resetEtcdClientFactory resets the global singleton factory function
+// to its original value.  This function is handy as a defer for tests.
+func resetEtcdClientFactory() {
+	etcdClientFactory = newEtcdClient
+}
+
+// resetDNSSRVLookup resets the global SRV lookup function
+// to its original value.  This function is handy as a defer for tests.
+func resetDNSSRVLookup() {
+	dnsSRVLookup = net.LookupSRV
+}
+
+// mockEtcdKV mocks the clientv3.KV methods used by this package.  Calling any other method panics.
+// resetBackoffRandom resets the global source of backoff jitter
+func resetBackoffRandom() {
+	backoffRandom = rand.Float64
+}
+
+// resetSignals resets the global singleton signal functions to their original values.
+// This function is handy as a defer for tests.
+func resetSignals() {
+	signalNotify = signal.Notify
+	signalStop = signal.Stop
+}
+
+type mockEtcdKV struct {
+	clientv3.KV
+	mock.Mock
+}
+
+func (m *mockEtcdKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	arguments := m.Called(key, val)
+	response, _ := arguments.Get(0).(*clientv3.PutResponse)
+	return response, arguments.Error(1)
+}
+
+func (m *mockEtcdKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	arguments := m.Called(key)
+	response, _ := arguments.Get(0).(*clientv3.GetResponse)
+	return response, arguments.Error(1)
+}
+
+// mockEtcdLease mocks the clientv3.Lease methods used by this package.  Calling any other method panics.
+type mockEtcdLease struct {
+	clientv3.Lease
+	mock.Mock
+}
+
+func (m *mockEtcdLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	arguments := m.Called(ttl)
+	response, _ := arguments.Get(0).(*clientv3.LeaseGrantResponse)
+	return response, arguments.Error(1)
+}
+
+func (m *mockEtcdLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	arguments := m.Called(id)
+	response, _ := arguments.Get(0).(*clientv3.LeaseRevokeResponse)
+	return response, arguments.Error(1)
+}
+
+func (m *mockEtcdLease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	arguments := m.Called(ctx, id)
+	responses, _ := arguments.Get(0).(<-chan *clientv3.LeaseKeepAliveResponse)
+	return responses, arguments.Error(1)
+}
+
+// mockEtcdWatcher mocks the clientv3.Watcher methods used by this package
+type mockEtcdWatcher struct {
+	mock.Mock
+}
+
+func (m *mockEtcdWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	return m.Called(ctx, key).Get(0).(clientv3.WatchChan)
+}
+
+func (m *mockEtcdWatcher) Close() error {
+	return m.Called().Error(0)
+}
+
 type mockInstancer struct {
 	mock.Mock
 }
@@ -52,6 +164,19 @@ func (m *mockInstancer) Deregister(events chan<- sd.Event) {
 	m.Called(events)
 }
 
+func (m *mockInstancer) Stop() {
+	m.Called()
+}
+
+type mockMetadataSource struct {
+	mock.Mock
+}
+
+func (m *mockMetadataSource) Metadata(instance string) Metadata {
+	md, _ := m.Called(instance).Get(0).(Metadata)
+	return md
+}
+
 type mockAccessor struct {
 	mock.Mock
 }
@@ -76,3 +201,100 @@ func (m *mockSubscription) Stop() {
 func (m *mockSubscription) Updates() <-chan Accessor {
 	return m.Called().Get(0).(<-chan Accessor)
 }
+
+type mockRegistrar struct {
+	mock.Mock
+}
+
+func (m *mockRegistrar) Register() {
+	m.Called()
+}
+
+func (m *mockRegistrar) Deregister() {
+	m.Called()
+}
+
+type mockProvider struct {
+	mock.Mock
+}
+
+func (m *mockProvider) Registrar() Registrar {
+	r, _ := m.Called().Get(0).(Registrar)
+	return r
+}
+
+func (m *mockProvider) NewInstancer() (Instancer, error) {
+	arguments := m.Called()
+	i, _ := arguments.Get(0).(Instancer)
+	return i, arguments.Error(1)
+}
+
+func (m *mockProvider) Close() error {
+	return m.Called().Error(0)
+}
+
+// mockTargetProvider is a mockProvider which also implements TargetProvider
+type mockTargetProvider struct {
+	mockProvider
+}
+
+func (m *mockTargetProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	arguments := m.Called(t)
+	i, _ := arguments.Get(0).(Instancer)
+	return i, arguments.Error(1)
+}
+
+type mockCounter struct {
+	mock.Mock
+}
+
+func (m *mockCounter) With(labelValues ...string) metrics.Counter {
+	c, _ := m.Called(labelValues).Get(0).(metrics.Counter)
+	return c
+}
+
+func (m *mockCounter) Add(delta float64) {
+	m.Called(delta)
+}
+
+type mockGauge struct {
+	mock.Mock
+}
+
+func (m *mockGauge) With(labelValues ...string) metrics.Gauge {
+	g, _ := m.Called(labelValues).Get(0).(metrics.Gauge)
+	return g
+}
+
+func (m *mockGauge) Set(value float64) {
+	m.Called(value)
+}
+
+func (m *mockGauge) Add(delta float64) {
+	m.Called(delta)
+}
+
+// mockMetricsProvider is a go-kit metrics provider.Provider which supplies discarded metrics unless
+// a metric has been set up with an expectation
+type mockMetricsProvider struct {
+	mock.Mock
+}
+
+func (m *mockMetricsProvider) NewCounter(name string) metrics.Counter {
+	c, _ := m.Called(name).Get(0).(metrics.Counter)
+	return c
+}
+
+func (m *mockMetricsProvider) NewGauge(name string) metrics.Gauge {
+	g, _ := m.Called(name).Get(0).(metrics.Gauge)
+	return g
+}
+
+func (m *mockMetricsProvider) NewHistogram(name string, buckets int) metrics.Histogram {
+	h, _ := m.Called(name, buckets).Get(0).(metrics.Histogram)
+	return h
+}
+
+func (m *mockMetricsProvider) Stop() {
+	m.Called()
+}
@@ -2,11 +2,17 @@ package service
 
 import (
 	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/go-kit/kit/sd"
 )
 
 const (
@@ -16,14 +22,482 @@ const (
 	DefaultPath           = "/xmidt"
 	DefaultServiceName    = "test"
 	DefaultVnodeCount     = 211
+	DefaultWatchBuffer    = 10
+
+	// ZookeeperBackend is the Backend value that selects Zookeeper, via go.serversets.  This is the default backend.
+	ZookeeperBackend = "zookeeper"
+
+	// ConsulBackend is the Backend value that selects Consul.
+	ConsulBackend = "consul"
+
+	// EtcdBackend is the Backend value that selects etcd, using the v3 API.
+	EtcdBackend = "etcd"
+
+	// DNSSRVBackend is the Backend value that selects DNS SRV records, e.g. for Kubernetes headless services.
+	// This backend is discovery-only, as the records are maintained externally.
+	DNSSRVBackend = "dnssrv"
+
+	// KubernetesBackend is the Backend value that selects the Endpoints, or EndpointSlices, of a Kubernetes service.
+	// This backend is discovery-only, as Kubernetes maintains the endpoints of each service.
+	KubernetesBackend = "kubernetes"
+
+	DefaultConsulAddress = "localhost:8500"
+
+	DefaultEtcdEndpoint    = "localhost:2379"
+	DefaultEtcdDialTimeout = 5 * time.Second
+	DefaultEtcdTTL         = 30 * time.Second
+
+	DefaultDNSSRVRefreshInterval = 30 * time.Second
+
+	DefaultKubernetesTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultKubernetesCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultKubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	DefaultKubernetesNamespace     = "default"
+
+	DefaultBackoffInitial         = 1 * time.Second
+	DefaultBackoffMax             = 1 * time.Minute
+	DefaultBackoffMultiplier      = 2.0
+	DefaultBackoffJitter          = 0.2
+	DefaultBackoffConnectAttempts = 1
+
+	DefaultHealthInterval         = 10 * time.Second
+	DefaultHealthFailureThreshold = 3
+	DefaultHealthSuccessThreshold = 1
 )
 
+// KubernetesOptions holds the configuration that only applies to the Kubernetes backend.  A nil KubernetesOptions
+// is valid and uses the default for each setting, which are appropriate for a process running in a pod.
+type KubernetesOptions struct {
+	// APIServer is the base URL of the Kubernetes API server.  If unset, the in-cluster address given by the
+	// KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT environment variables is used.
+	APIServer string `json:"apiServer,omitempty"`
+
+	// Namespace is the namespace of the Kubernetes service.  If unset, the namespace of the pod's service account
+	// is used if available, and otherwise DefaultKubernetesNamespace.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Service is the name of the Kubernetes service.  If unset, the ServiceName is used.
+	Service string `json:"service,omitempty"`
+
+	// PortName is the name of the service port that instances are discovered on.  If unset, the first port is used.
+	PortName string `json:"portName,omitempty"`
+
+	// Scheme is prepended to each discovered instance, since endpoints only hold addresses and ports.
+	// If unset, the scheme of the Registration is used, if it has one.
+	Scheme string `json:"scheme,omitempty"`
+
+	// EndpointSlices selects the discovery.k8s.io EndpointSlice API rather than the core Endpoints API.
+	EndpointSlices bool `json:"endpointSlices"`
+
+	// TokenFile is the file holding the bearer token for the API server, which is read for each request so that
+	// rotated tokens are honored.  If unset, DefaultKubernetesTokenFile is used if it exists, and otherwise
+	// requests are not authenticated, e.g. when using kubectl proxy.
+	TokenFile string `json:"tokenFile,omitempty"`
+
+	// CAFile is the PEM file of certificate authorities for the API server.  If unset, DefaultKubernetesCAFile is
+	// used if it exists, and otherwise the system roots are used.
+	CAFile string `json:"caFile,omitempty"`
+
+	// HTTPClient is the optional client used for API server requests, in which case CAFile is ignored.
+	HTTPClient *http.Client `json:"-"`
+}
+
+func (ko *KubernetesOptions) apiServer() string {
+	if ko != nil && len(ko.APIServer) > 0 {
+		return strings.TrimSuffix(ko.APIServer, "/")
+	}
+
+	return "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+}
+
+func (ko *KubernetesOptions) namespace() string {
+	if ko != nil && len(ko.Namespace) > 0 {
+		return ko.Namespace
+	}
+
+	if namespace, err := ioutil.ReadFile(DefaultKubernetesNamespaceFile); err == nil && len(bytes.TrimSpace(namespace)) > 0 {
+		return string(bytes.TrimSpace(namespace))
+	}
+
+	return DefaultKubernetesNamespace
+}
+
+func (ko *KubernetesOptions) service() string {
+	if ko != nil {
+		return ko.Service
+	}
+
+	return ""
+}
+
+func (ko *KubernetesOptions) portName() string {
+	if ko != nil {
+		return ko.PortName
+	}
+
+	return ""
+}
+
+func (ko *KubernetesOptions) scheme() string {
+	if ko != nil {
+		return ko.Scheme
+	}
+
+	return ""
+}
+
+func (ko *KubernetesOptions) endpointSlices() bool {
+	if ko != nil {
+		return ko.EndpointSlices
+	}
+
+	return false
+}
+
+// tokenFile returns the configured token file, and whether that file is required to exist
+func (ko *KubernetesOptions) tokenFile() (string, bool) {
+	if ko != nil && len(ko.TokenFile) > 0 {
+		return ko.TokenFile, true
+	}
+
+	return DefaultKubernetesTokenFile, false
+}
+
+// caFile returns the configured CA file, and whether that file is required to exist
+func (ko *KubernetesOptions) caFile() (string, bool) {
+	if ko != nil && len(ko.CAFile) > 0 {
+		return ko.CAFile, true
+	}
+
+	return DefaultKubernetesCAFile, false
+}
+
+func (ko *KubernetesOptions) httpClient() *http.Client {
+	if ko != nil {
+		return ko.HTTPClient
+	}
+
+	return nil
+}
+
+// DNSSRVOptions holds the configuration that only applies to the DNS SRV backend.  A nil DNSSRVOptions is valid
+// and uses the default for each setting.
+type DNSSRVOptions struct {
+	// Name is the fully qualified name of the SRV record, e.g. _http._tcp.talaria.xmidt.svc.cluster.local.
+	// If unset, the ServiceName is used.
+	Name string `json:"name,omitempty"`
+
+	// RefreshInterval is the time between lookups of the SRV record.  If unset, DefaultDNSSRVRefreshInterval is used.
+	RefreshInterval time.Duration `json:"refreshInterval"`
+
+	// Scheme is prepended to each discovered instance, since SRV records only hold a target and port.
+	// If unset, the scheme of the Registration is used, if it has one.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+func (do *DNSSRVOptions) name() string {
+	if do != nil {
+		return do.Name
+	}
+
+	return ""
+}
+
+func (do *DNSSRVOptions) refreshInterval() time.Duration {
+	if do != nil && do.RefreshInterval > 0 {
+		return do.RefreshInterval
+	}
+
+	return DefaultDNSSRVRefreshInterval
+}
+
+func (do *DNSSRVOptions) scheme() string {
+	if do != nil {
+		return do.Scheme
+	}
+
+	return ""
+}
+
+// ConsulOptions holds the configuration that only applies to the Consul backend.  A nil ConsulOptions is valid
+// and uses the default for each setting.
+type ConsulOptions struct {
+	// Address is the host:port of the Consul agent.  If unset, DefaultConsulAddress is used.
+	Address string `json:"address,omitempty"`
+
+	// Datacenter is the optional Consul datacenter.  If unset, the agent's datacenter is used.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Token is the optional ACL token used for all Consul requests.
+	Token string `json:"token,omitempty"`
+
+	// Tags are attached to this service's registration, and discovered instances must have all of them.
+	Tags []string `json:"tags,omitempty"`
+
+	// PassingOnly restricts discovered instances to those whose health checks are passing.
+	PassingOnly bool `json:"passingOnly"`
+
+	// Scheme is prepended to each discovered instance, since Consul only stores the host and port of each service.
+	// If unset, the scheme of the Registration is used, if it has one.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+func (co *ConsulOptions) address() string {
+	if co != nil && len(co.Address) > 0 {
+		return co.Address
+	}
+
+	return DefaultConsulAddress
+}
+
+func (co *ConsulOptions) datacenter() string {
+	if co != nil {
+		return co.Datacenter
+	}
+
+	return ""
+}
+
+func (co *ConsulOptions) token() string {
+	if co != nil {
+		return co.Token
+	}
+
+	return ""
+}
+
+func (co *ConsulOptions) tags() (tags []string) {
+	if co != nil && len(co.Tags) > 0 {
+		tags = make([]string, len(co.Tags))
+		copy(tags, co.Tags)
+	}
+
+	return
+}
+
+func (co *ConsulOptions) passingOnly() bool {
+	if co != nil {
+		return co.PassingOnly
+	}
+
+	return false
+}
+
+func (co *ConsulOptions) scheme() string {
+	if co != nil {
+		return co.Scheme
+	}
+
+	return ""
+}
+
+// BackoffOptions configures the exponential backoff, with jitter, between attempts to connect to Zookeeper and
+// to restore a registration after a session expires.  A nil BackoffOptions is valid and uses the default for each setting.
+type BackoffOptions struct {
+	// Initial is the delay before the first retry.  If unset, DefaultBackoffInitial is used.
+	Initial time.Duration `json:"initial"`
+
+	// Max is the upper bound on the delay between attempts, before jitter is applied.  If unset, DefaultBackoffMax is used.
+	Max time.Duration `json:"max"`
+
+	// Multiplier is the factor by which the delay grows after each failed attempt.  If unset or less than 1,
+	// DefaultBackoffMultiplier is used.
+	Multiplier float64 `json:"multiplier,omitempty"`
+
+	// Jitter is the fraction of each delay which is randomized, so that delays fall within
+	// [delay*(1-Jitter), delay*(1+Jitter)].  This keeps a fleet of processes from retrying in lockstep
+	// after a Zookeeper outage.  If unset, DefaultBackoffJitter is used.  Negative values disable jitter.
+	Jitter float64 `json:"jitter,omitempty"`
+
+	// ConnectAttempts is the number of times the initial connection is attempted before New fails.  If unset,
+	// DefaultBackoffConnectAttempts is used, which fails on the first error.  Restoring a registration is
+	// always retried until it succeeds or the service is deregistered.
+	ConnectAttempts int `json:"connectAttempts,omitempty"`
+}
+
+func (bo *BackoffOptions) initial() time.Duration {
+	if bo != nil && bo.Initial > 0 {
+		return bo.Initial
+	}
+
+	return DefaultBackoffInitial
+}
+
+func (bo *BackoffOptions) max() time.Duration {
+	if bo != nil && bo.Max > 0 {
+		return bo.Max
+	}
+
+	return DefaultBackoffMax
+}
+
+func (bo *BackoffOptions) multiplier() float64 {
+	if bo != nil && bo.Multiplier >= 1.0 {
+		return bo.Multiplier
+	}
+
+	return DefaultBackoffMultiplier
+}
+
+func (bo *BackoffOptions) jitter() float64 {
+	switch {
+	case bo == nil || bo.Jitter == 0.0:
+		return DefaultBackoffJitter
+	case bo.Jitter < 0.0:
+		return 0.0
+	case bo.Jitter > 1.0:
+		return 1.0
+	default:
+		return bo.Jitter
+	}
+}
+
+func (bo *BackoffOptions) connectAttempts() int {
+	if bo != nil && bo.ConnectAttempts > 0 {
+		return bo.ConnectAttempts
+	}
+
+	return DefaultBackoffConnectAttempts
+}
+
+// HealthOptions configures health-gated registration.  A nil HealthOptions, or one without a Check, is valid
+// and disables health checking, so that a registration lasts until Deregister is called.
+type HealthOptions struct {
+	// Check is the health check for this process.  If unset, no health checking is done.
+	Check HealthCheck `json:"-"`
+
+	// Interval is the time between health checks while registered.  If unset, DefaultHealthInterval is used.
+	Interval time.Duration `json:"interval"`
+
+	// FailureThreshold is the number of consecutive failed checks after which this process is deregistered.
+	// If unset, DefaultHealthFailureThreshold is used.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// SuccessThreshold is the number of consecutive successful checks after which a process deregistered for
+	// failing its checks is registered again.  If unset, DefaultHealthSuccessThreshold is used.
+	SuccessThreshold int `json:"successThreshold,omitempty"`
+}
+
+func (ho *HealthOptions) check() HealthCheck {
+	if ho != nil {
+		return ho.Check
+	}
+
+	return nil
+}
+
+func (ho *HealthOptions) interval() time.Duration {
+	if ho != nil && ho.Interval > 0 {
+		return ho.Interval
+	}
+
+	return DefaultHealthInterval
+}
+
+func (ho *HealthOptions) failureThreshold() int {
+	if ho != nil && ho.FailureThreshold > 0 {
+		return ho.FailureThreshold
+	}
+
+	return DefaultHealthFailureThreshold
+}
+
+func (ho *HealthOptions) successThreshold() int {
+	if ho != nil && ho.SuccessThreshold > 0 {
+		return ho.SuccessThreshold
+	}
+
+	return DefaultHealthSuccessThreshold
+}
+
+// EtcdOptions holds the configuration that only applies to the etcd backend.  A nil EtcdOptions is valid
+// and uses the default for each setting.
+type EtcdOptions struct {
+	// Endpoints are the etcd cluster members, each of the form host:port.  If unset, DefaultEtcdEndpoint is used.
+	Endpoints []string `json:"endpoints,omitempty"`
+
+	// DialTimeout is the etcd connection timeout.  If unset, DefaultEtcdDialTimeout is used.
+	DialTimeout time.Duration `json:"dialTimeout"`
+
+	// TTL is the time-to-live of the lease attached to this service's registration.  The lease is kept alive
+	// while registered, so this is how long a registration survives after this process stops without deregistering.
+	// If unset, DefaultEtcdTTL is used.  Etcd leases have a granularity of one second.
+	TTL time.Duration `json:"ttl"`
+
+	// Username is the optional etcd user name used for authentication.
+	Username string `json:"username,omitempty"`
+
+	// Password is the optional etcd password used for authentication.
+	Password string `json:"password,omitempty"`
+}
+
+func (eo *EtcdOptions) endpoints() []string {
+	if eo != nil && len(eo.Endpoints) > 0 {
+		endpoints := make([]string, len(eo.Endpoints))
+		copy(endpoints, eo.Endpoints)
+		return endpoints
+	}
+
+	return []string{DefaultEtcdEndpoint}
+}
+
+func (eo *EtcdOptions) dialTimeout() time.Duration {
+	if eo != nil && eo.DialTimeout > 0 {
+		return eo.DialTimeout
+	}
+
+	return DefaultEtcdDialTimeout
+}
+
+// ttlSeconds returns the lease TTL in the whole seconds used by etcd, rounding up
+func (eo *EtcdOptions) ttlSeconds() int64 {
+	ttl := DefaultEtcdTTL
+	if eo != nil && eo.TTL > 0 {
+		ttl = eo.TTL
+	}
+
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+func (eo *EtcdOptions) username() string {
+	if eo != nil {
+		return eo.Username
+	}
+
+	return ""
+}
+
+func (eo *EtcdOptions) password() string {
+	if eo != nil {
+		return eo.Password
+	}
+
+	return ""
+}
+
+// WatchTarget identifies a service to watch other than the one described by Options, such as a different
+// service or environment.  Empty fields default to the configured service.  Zookeeper watches Path, etcd
+// watches ServiceName under Path, Kubernetes watches ServiceName within the namespace given by Path, and
+// Consul and DNS SRV watch ServiceName.
+type WatchTarget struct {
+	Path        string `json:"path,omitempty"`
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
 // Options represents the set of configurable attributes for service discovery and registration
 type Options struct {
 	// Logger is used by any component configured via this Options.  If unset, a default
 	// logger is used.
 	Logger log.Logger `json:"-"`
 
+	// MetricsProvider is the go-kit factory for the metrics described by Metrics.  If unset, metrics are discarded.
+	MetricsProvider provider.Provider `json:"-"`
+
+	// Backend selects the service discovery system, and is one of ZookeeperBackend, ConsulBackend,
+	// EtcdBackend, DNSSRVBackend, or KubernetesBackend.
+	// If unset, ZookeeperBackend is used.  The remaining fields apply to all backends unless noted.
+	Backend string `json:"backend,omitempty"`
+
 	// Connection is the comma-delimited Zookeeper connection string.  Both this and
 	// Servers may be set, and they will be merged together when connecting to Zookeeper.
 	Connection string `json:"connection,omitempty"`
@@ -38,12 +512,30 @@ type Options struct {
 	// SessionTimeout is the Zookeeper session timeout.
 	SessionTimeout time.Duration `json:"sessionTimeout"`
 
+	// Backoff configures the delays between Zookeeper connection attempts, and between attempts to restore
+	// a registration lost to an expired session.
+	Backoff *BackoffOptions `json:"backoff,omitempty"`
+
+	// Health gates this process's registration on a health check.  A process which fails its check for long
+	// enough is deregistered, and registered again once it recovers.  RegistrationLost and RegistrationRestored
+	// are invoked for these transitions as well.  If unset, no health checking is done.
+	Health *HealthOptions `json:"health,omitempty"`
+
 	// UpdateDelay specifies the period of time between a service discovery update and when a client
 	// is notified.  Updates during the wait time simply replace the waiting set of instances.
 	// There is no default for this field.  If unset, all updates are immediately processed.
 	UpdateDelay time.Duration `json:"updateDelay"`
 
-	// Path is the base path for all znodes created via this Options.
+	// WatchBuffer is the number of events buffered for each subscriber of a Watch.  When a subscriber's buffer
+	// is full, its oldest event is discarded in favor of the newest.  If unset, DefaultWatchBuffer is used.
+	WatchBuffer int `json:"watchBuffer,omitempty"`
+
+	// Watches is the optional set of other services to watch through the same connection as this service,
+	// keyed by a name chosen by the application.  See NewWatches.
+	Watches map[string]WatchTarget `json:"watches,omitempty"`
+
+	// Path is the base path for all znodes created via this Options, and the key prefix used by the etcd backend.
+	// This field is ignored by the Consul backend.
 	Path string `json:"path,omitempty"`
 
 	// ServiceName is the name of the service being registered.
@@ -52,9 +544,45 @@ type Options struct {
 	// Registration is the data stored about this service, typically host:port or scheme://host:port.
 	Registration string `json:"registration,omitempty"`
 
+	// Metadata is the optional set of key/value pairs published along with the Registration, e.g. a build
+	// version, datacenter, or weight.  The Zookeeper and etcd backends store metadata with the registration,
+	// and it is surfaced on watched instances through Event.Metadata.  Other backends ignore it.
+	Metadata Metadata `json:"metadata,omitempty"`
+
+	// Datacenter is the datacenter, or zone, in which this process runs.  If set, it is published in the
+	// registration's Metadata under DatacenterKey unless the Metadata already has that key.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// PreferLocalDatacenter makes subscriptions and watches route keys only to instances in this process's
+	// Datacenter, failing over to instances in other datacenters when there are no local instances.  This
+	// requires a backend which stores metadata, and uses DatacenterAccessorFactory around the AccessorFactory.
+	PreferLocalDatacenter bool `json:"preferLocalDatacenter,omitempty"`
+
+	// Etcd is the etcd-specific configuration, used when Backend is EtcdBackend.  Of the Zookeeper-specific fields,
+	// only Path is used by the etcd backend, as the prefix for service keys.
+	Etcd *EtcdOptions `json:"etcd,omitempty"`
+
+	// DNSSRV is the configuration for the DNS SRV backend, used when Backend is DNSSRVBackend.  That backend
+	// never registers, and uses the Registration only to determine the scheme of discovered instances.
+	DNSSRV *DNSSRVOptions `json:"dnssrv,omitempty"`
+
+	// Kubernetes is the configuration for the Kubernetes backend, used when Backend is KubernetesBackend.  That backend
+	// never registers, and uses the Registration only to determine the scheme of discovered instances.
+	Kubernetes *KubernetesOptions `json:"kubernetes,omitempty"`
+
+	// Consul is the Consul-specific configuration, used when Backend is ConsulBackend.  The Zookeeper-specific
+	// fields, i.e. Connection, Servers, ConnectTimeout, SessionTimeout, and Path, are ignored by the Consul backend.
+	Consul *ConsulOptions `json:"consul,omitempty"`
+
 	// VnodeCount is used to tune the underlying consistent hash algorithm for servers.
 	VnodeCount uint `json:"vnodeCount"`
 
+	// Hash is the name of the hash function for the consistent hash of this service's instances,
+	// one of CRC32Hash, FNV32Hash, or FNV32aHash.  If unset, the original consistent hash is used.
+	// Together with VnodeCount, this allows the consistent hash to be tuned per service, since each
+	// watched service has its own Options.  This field is ignored if AccessorFactory is set.
+	Hash string `json:"hash,omitempty"`
+
 	// InstancesFilter is the optional filter for discovered instances.  If not set,
 	// DefaultInstancesFilter will be used.
 	InstancesFilter InstancesFilter `json:"-"`
@@ -63,6 +591,20 @@ type Options struct {
 	// ConsistentAccessorFactory will be used.
 	AccessorFactory AccessorFactory `json:"-"`
 
+	// RegistrationLost is the optional callback invoked when this process's registration is lost without
+	// Deregister having been called, e.g. because the Zookeeper session expired or the etcd lease could not be
+	// kept alive.  Services can use this to stop accepting traffic until RegistrationRestored is invoked.
+	// The Zookeeper and etcd backends detect lost registrations, and restore them automatically.
+	RegistrationLost func(error) `json:"-"`
+
+	// RegistrationRestored is the optional callback invoked when a registration that was lost has been
+	// re-established.  It is not invoked for the initial registration.
+	RegistrationRestored func() `json:"-"`
+
+	// OnBackoff is the optional callback invoked each time a failed attempt is about to be retried, e.g. to
+	// record metrics about connectivity to the backend.
+	OnBackoff func(BackoffEvent) `json:"-"`
+
 	// After is the optional function to use to obtain a channel which receives a time.Time
 	// after a delay.  If not set, time.After is used.
 	After func(time.Duration) <-chan time.Time `json:"-"`
@@ -73,6 +615,11 @@ func (o *Options) String() string {
 	if o == nil {
 		output.WriteString("<nil>")
 	} else {
+		if len(o.Backend) > 0 {
+			output.WriteString("backend=")
+			output.WriteString(o.Backend)
+		}
+
 		if len(o.Connection) > 0 {
 			if output.Len() > 0 {
 				output.WriteString(", ")
@@ -150,6 +697,54 @@ func (o *Options) logger() log.Logger {
 	return log.NewNopLogger()
 }
 
+func (o *Options) metricsProvider() provider.Provider {
+	if o != nil && o.MetricsProvider != nil {
+		return o.MetricsProvider
+	}
+
+	return provider.NewDiscardProvider()
+}
+
+func (o *Options) backend() string {
+	if o != nil && len(o.Backend) > 0 {
+		return o.Backend
+	}
+
+	return ZookeeperBackend
+}
+
+func (o *Options) consul() *ConsulOptions {
+	if o != nil {
+		return o.Consul
+	}
+
+	return nil
+}
+
+func (o *Options) kubernetes() *KubernetesOptions {
+	if o != nil {
+		return o.Kubernetes
+	}
+
+	return nil
+}
+
+func (o *Options) dnsSRV() *DNSSRVOptions {
+	if o != nil {
+		return o.DNSSRV
+	}
+
+	return nil
+}
+
+func (o *Options) etcd() *EtcdOptions {
+	if o != nil {
+		return o.Etcd
+	}
+
+	return nil
+}
+
 func (o *Options) servers() []string {
 	servers := make([]string, 0, 10)
 
@@ -196,6 +791,41 @@ func (o *Options) updateDelay() time.Duration {
 	return 0
 }
 
+func (o *Options) watchBuffer() int {
+	if o != nil && o.WatchBuffer > 0 {
+		return o.WatchBuffer
+	}
+
+	return DefaultWatchBuffer
+}
+
+func (o *Options) watches() map[string]WatchTarget {
+	if o != nil {
+		return o.Watches
+	}
+
+	return nil
+}
+
+// forTarget produces a copy of these options which describes the given target, so that components
+// such as a Watch identify the target in their logging
+func (o *Options) forTarget(t WatchTarget) *Options {
+	var clone Options
+	if o != nil {
+		clone = *o
+	}
+
+	if len(t.Path) > 0 {
+		clone.Path = t.Path
+	}
+
+	if len(t.ServiceName) > 0 {
+		clone.ServiceName = t.ServiceName
+	}
+
+	return &clone
+}
+
 func (o *Options) path() string {
 	if o != nil && len(o.Path) > 0 {
 		return o.Path
@@ -220,6 +850,50 @@ func (o *Options) registration() string {
 	return ""
 }
 
+func (o *Options) metadata() Metadata {
+	if o == nil {
+		return nil
+	}
+
+	if _, ok := o.Metadata[DatacenterKey]; ok || len(o.Datacenter) == 0 {
+		return o.Metadata
+	}
+
+	m := make(Metadata, len(o.Metadata)+1)
+	for k, v := range o.Metadata {
+		m[k] = v
+	}
+
+	m[DatacenterKey] = o.Datacenter
+	return m
+}
+
+func (o *Options) datacenter() string {
+	if o != nil {
+		return o.Datacenter
+	}
+
+	return ""
+}
+
+// instancesAccessorFactory is the AccessorFactory for the instances of the given Instancer, which takes
+// PreferLocalDatacenter into account
+func (o *Options) instancesAccessorFactory(i sd.Instancer) AccessorFactory {
+	factory := o.accessorFactory()
+	if o != nil && o.PreferLocalDatacenter {
+		if source, ok := i.(MetadataSource); ok {
+			return DatacenterAccessorFactory(o.datacenter(), source, factory)
+		}
+	}
+
+	return factory
+}
+
+// encodedRegistration is the value stored in a backend which supports metadata
+func (o *Options) encodedRegistration() string {
+	return EncodeRegistration(o.registration(), o.metadata())
+}
+
 func (o *Options) vnodeCount() int {
 	if o != nil && o.VnodeCount > 0 {
 		return int(o.VnodeCount)
@@ -228,6 +902,14 @@ func (o *Options) vnodeCount() int {
 	return DefaultVnodeCount
 }
 
+func (o *Options) hash() string {
+	if o != nil {
+		return o.Hash
+	}
+
+	return ""
+}
+
 func (o *Options) instancesFilter() InstancesFilter {
 	if o != nil && o.InstancesFilter != nil {
 		return o.InstancesFilter
@@ -241,7 +923,53 @@ func (o *Options) accessorFactory() AccessorFactory {
 		return o.AccessorFactory
 	}
 
-	return ConsistentAccessorFactory(o.vnodeCount())
+	factory, err := NewConsistentAccessorFactory(o.vnodeCount(), o.hash())
+	if err != nil {
+		// FromViper rejects unsupported hashes, so this only happens for Options built in code
+		return func([]string) Accessor { return failedAccessor{err} }
+	}
+
+	return factory
+}
+
+func (o *Options) backoff() *BackoffOptions {
+	if o != nil {
+		return o.Backoff
+	}
+
+	return nil
+}
+
+func (o *Options) health() *HealthOptions {
+	if o != nil {
+		return o.Health
+	}
+
+	return nil
+}
+
+func (o *Options) onBackoff() func(BackoffEvent) {
+	if o != nil && o.OnBackoff != nil {
+		return o.OnBackoff
+	}
+
+	return func(BackoffEvent) {}
+}
+
+func (o *Options) registrationLost() func(error) {
+	if o != nil && o.RegistrationLost != nil {
+		return o.RegistrationLost
+	}
+
+	return func(error) {}
+}
+
+func (o *Options) registrationRestored() func() {
+	if o != nil && o.RegistrationRestored != nil {
+		return o.RegistrationRestored
+	}
+
+	return func() {}
 }
 
 func (o *Options) after() func(time.Duration) <-chan time.Time {
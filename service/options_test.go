@@ -1,6 +1,8 @@
 package service
 
 import (
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
@@ -15,6 +17,12 @@ func testOptionsDefault(t *testing.T) {
 		t.Logf("%#v", o)
 
 		assert.NotNil(o.logger())
+		assert.NotNil(o.metricsProvider())
+		assert.Equal(ZookeeperBackend, o.backend())
+		assert.Nil(o.consul())
+		assert.Nil(o.etcd())
+		assert.Nil(o.dnsSRV())
+		assert.Nil(o.kubernetes())
 		assert.Equal([]string{DefaultServer}, o.servers())
 		assert.Equal(DefaultConnectTimeout, o.connectTimeout())
 		assert.Equal(DefaultSessionTimeout, o.sessionTimeout())
@@ -22,11 +30,26 @@ func testOptionsDefault(t *testing.T) {
 		assert.Equal(DefaultPath, o.path())
 		assert.Equal(DefaultServiceName, o.serviceName())
 		assert.Empty(o.registration())
+		assert.Empty(o.metadata())
+		assert.Empty(o.datacenter())
+		assert.Empty(o.watches())
+		assert.Nil(o.backoff())
+		assert.NotNil(o.onBackoff())
+		assert.Nil(o.health())
+		assert.Empty(o.encodedRegistration())
 		assert.Equal(DefaultVnodeCount, o.vnodeCount())
+		assert.Equal(DefaultWatchBuffer, o.watchBuffer())
 		assert.NotNil(o.instancesFilter())
 		assert.NotNil(o.accessorFactory())
 		assert.NotNil(o.after())
+		assert.NotNil(o.registrationLost())
+		assert.NotNil(o.registrationRestored())
 		assert.NotEmpty(o.String())
+
+		// the default callbacks must be safe to call
+		o.registrationLost()(errors.New("expected"))
+		o.registrationRestored()()
+		o.onBackoff()(BackoffEvent{})
 	}
 }
 
@@ -55,6 +78,7 @@ func testOptionsCustom(t *testing.T) {
 					ConnectTimeout:  16 * time.Minute,
 					SessionTimeout:  2 * time.Hour,
 					UpdateDelay:     3 * time.Minute,
+					WatchBuffer:     50,
 					Path:            "/testOptions/workspace",
 					ServiceName:     "options",
 					Registration:    "https://comcast.net:8080",
@@ -132,6 +156,12 @@ func testOptionsCustom(t *testing.T) {
 
 		assert.Equal(options.Logger, options.logger())
 		assert.Equal(options.ConnectTimeout, options.connectTimeout())
+		if options.WatchBuffer > 0 {
+			assert.Equal(options.WatchBuffer, options.watchBuffer())
+		} else {
+			assert.Equal(DefaultWatchBuffer, options.watchBuffer())
+		}
+
 		assert.Equal(options.SessionTimeout, options.sessionTimeout())
 		assert.Equal(options.UpdateDelay, options.updateDelay())
 		assert.Equal(options.Path, options.path())
@@ -154,7 +184,310 @@ func testOptionsCustom(t *testing.T) {
 	}
 }
 
+func testOptionsConsul(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, co := range []*ConsulOptions{nil, new(ConsulOptions)} {
+		assert.Equal(DefaultConsulAddress, co.address())
+		assert.Empty(co.datacenter())
+		assert.Empty(co.token())
+		assert.Empty(co.tags())
+		assert.False(co.passingOnly())
+		assert.Empty(co.scheme())
+	}
+
+	var (
+		co = &ConsulOptions{
+			Address:     "consul.comcast.net:8500",
+			Datacenter:  "dc1",
+			Token:       "token",
+			Tags:        []string{"tag1", "tag2"},
+			PassingOnly: true,
+			Scheme:      "https",
+		}
+
+		o = &Options{Backend: ConsulBackend, Consul: co}
+	)
+
+	assert.Equal(ConsulBackend, o.backend())
+	assert.Equal(co, o.consul())
+	assert.Contains(o.String(), "backend=consul")
+
+	assert.Equal("consul.comcast.net:8500", co.address())
+	assert.Equal("dc1", co.datacenter())
+	assert.Equal("token", co.token())
+	assert.True(co.passingOnly())
+	assert.Equal("https", co.scheme())
+
+	tags := co.tags()
+	assert.Equal([]string{"tag1", "tag2"}, tags)
+	tags[0] = "changed"
+	assert.Equal("tag1", co.Tags[0])
+}
+
+func testOptionsEtcd(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, eo := range []*EtcdOptions{nil, new(EtcdOptions)} {
+		assert.Equal([]string{DefaultEtcdEndpoint}, eo.endpoints())
+		assert.Equal(DefaultEtcdDialTimeout, eo.dialTimeout())
+		assert.Equal(int64(DefaultEtcdTTL/time.Second), eo.ttlSeconds())
+		assert.Empty(eo.username())
+		assert.Empty(eo.password())
+	}
+
+	var (
+		eo = &EtcdOptions{
+			Endpoints:   []string{"host1:2379", "host2:2379"},
+			DialTimeout: 17 * time.Second,
+			TTL:         1500 * time.Millisecond,
+			Username:    "user",
+			Password:    "password",
+		}
+
+		o = &Options{Backend: EtcdBackend, Etcd: eo}
+	)
+
+	assert.Equal(EtcdBackend, o.backend())
+	assert.Equal(eo, o.etcd())
+
+	assert.Equal(17*time.Second, eo.dialTimeout())
+	assert.Equal(int64(2), eo.ttlSeconds())
+	assert.Equal("user", eo.username())
+	assert.Equal("password", eo.password())
+
+	endpoints := eo.endpoints()
+	assert.Equal([]string{"host1:2379", "host2:2379"}, endpoints)
+	endpoints[0] = "changed"
+	assert.Equal("host1:2379", eo.Endpoints[0])
+}
+
+func testOptionsDNSSRV(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, do := range []*DNSSRVOptions{nil, new(DNSSRVOptions)} {
+		assert.Empty(do.name())
+		assert.Equal(DefaultDNSSRVRefreshInterval, do.refreshInterval())
+		assert.Empty(do.scheme())
+	}
+
+	var (
+		do = &DNSSRVOptions{
+			Name:            "_http._tcp.talaria.xmidt.svc.cluster.local",
+			RefreshInterval: 15 * time.Second,
+			Scheme:          "https",
+		}
+
+		o = &Options{Backend: DNSSRVBackend, DNSSRV: do}
+	)
+
+	assert.Equal(DNSSRVBackend, o.backend())
+	assert.Equal(do, o.dnsSRV())
+	assert.Equal("_http._tcp.talaria.xmidt.svc.cluster.local", do.name())
+	assert.Equal(15*time.Second, do.refreshInterval())
+	assert.Equal("https", do.scheme())
+}
+
+func testOptionsKubernetes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, ko := range []*KubernetesOptions{nil, new(KubernetesOptions)} {
+		assert.Empty(ko.service())
+		assert.Empty(ko.portName())
+		assert.Empty(ko.scheme())
+		assert.False(ko.endpointSlices())
+		assert.Nil(ko.httpClient())
+
+		tokenFile, required := ko.tokenFile()
+		assert.Equal(DefaultKubernetesTokenFile, tokenFile)
+		assert.False(required)
+
+		caFile, required := ko.caFile()
+		assert.Equal(DefaultKubernetesCAFile, caFile)
+		assert.False(required)
+	}
+
+	var (
+		client = new(http.Client)
+		ko     = &KubernetesOptions{
+			APIServer:      "https://kubernetes.default.svc/",
+			Namespace:      "xmidt",
+			Service:        "talaria",
+			PortName:       "http",
+			Scheme:         "https",
+			EndpointSlices: true,
+			TokenFile:      "/etc/talaria/token",
+			CAFile:         "/etc/talaria/ca.crt",
+			HTTPClient:     client,
+		}
+
+		o = &Options{Backend: KubernetesBackend, Kubernetes: ko}
+	)
+
+	assert.Equal(KubernetesBackend, o.backend())
+	assert.Equal(ko, o.kubernetes())
+	assert.Equal("https://kubernetes.default.svc", ko.apiServer())
+	assert.Equal("xmidt", ko.namespace())
+	assert.Equal("talaria", ko.service())
+	assert.Equal("http", ko.portName())
+	assert.Equal("https", ko.scheme())
+	assert.True(ko.endpointSlices())
+	assert.Equal(client, ko.httpClient())
+
+	tokenFile, required := ko.tokenFile()
+	assert.Equal("/etc/talaria/token", tokenFile)
+	assert.True(required)
+
+	caFile, required := ko.caFile()
+	assert.Equal("/etc/talaria/ca.crt", caFile)
+	assert.True(required)
+}
+
+func testOptionsHash(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, o := range []*Options{nil, new(Options)} {
+		assert.Empty(o.hash())
+	}
+
+	o := &Options{Hash: FNV32Hash, VnodeCount: 100}
+	assert.Equal(FNV32Hash, o.hash())
+
+	instance, err := o.accessorFactory()([]string{"abc.com"}).Get([]byte("random key"))
+	assert.Equal("abc.com", instance)
+	assert.NoError(err)
+
+	// an unsupported hash produces accessors which always fail
+	o = &Options{Hash: "md5"}
+	instance, err = o.accessorFactory()([]string{"abc.com"}).Get([]byte("random key"))
+	assert.Empty(instance)
+	assert.Equal(ErrUnsupportedHash, err)
+}
+
+func testOptionsMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o      = &Options{Registration: "http://localhost:8080"}
+	)
+
+	assert.Equal("http://localhost:8080", o.encodedRegistration())
+
+	o.Metadata = Metadata{"version": "1.2.3"}
+	assert.Equal(Metadata{"version": "1.2.3"}, o.metadata())
+
+	instance, metadata := ParseRegistration(o.encodedRegistration())
+	assert.Equal("http://localhost:8080", instance)
+	assert.Equal(o.Metadata, metadata)
+}
+
+func testOptionsWatches(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		targets = map[string]WatchTarget{"petasos": {Path: "/xmidt/prod", ServiceName: "petasos"}}
+		o       = &Options{Path: "/xmidt/test", ServiceName: "talaria", Watches: targets}
+	)
+
+	assert.Equal(targets, o.watches())
+
+	target := o.forTarget(targets["petasos"])
+	assert.Equal("/xmidt/prod", target.path())
+	assert.Equal("petasos", target.serviceName())
+	assert.Equal("talaria", o.serviceName())
+
+	target = o.forTarget(WatchTarget{ServiceName: "codex"})
+	assert.Equal("/xmidt/test", target.path())
+	assert.Equal("codex", target.serviceName())
+
+	target = (*Options)(nil).forTarget(WatchTarget{ServiceName: "codex"})
+	assert.Equal(DefaultPath, target.path())
+	assert.Equal("codex", target.serviceName())
+}
+
+func testOptionsRegistrationCallbacks(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		lostError     error
+		restored      bool
+
+		o = &Options{
+			RegistrationLost:     func(err error) { lostError = err },
+			RegistrationRestored: func() { restored = true },
+		}
+	)
+
+	o.registrationLost()(expectedError)
+	assert.Equal(expectedError, lostError)
+
+	o.registrationRestored()()
+	assert.True(restored)
+}
+
+func testOptionsDatacenter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o      = &Options{Registration: "http://localhost:8080", Datacenter: "east"}
+	)
+
+	assert.Equal("east", o.datacenter())
+	assert.Equal(Metadata{DatacenterKey: "east"}, o.metadata())
+
+	o.Metadata = Metadata{"version": "1.2.3"}
+	assert.Equal(Metadata{"version": "1.2.3", DatacenterKey: "east"}, o.metadata())
+	assert.Equal(Metadata{"version": "1.2.3"}, o.Metadata)
+
+	instance, metadata := ParseRegistration(o.encodedRegistration())
+	assert.Equal("http://localhost:8080", instance)
+	assert.Equal(Metadata{"version": "1.2.3", DatacenterKey: "east"}, metadata)
+
+	// an explicit metadata entry takes precedence
+	o.Metadata = Metadata{DatacenterKey: "west"}
+	assert.Equal(Metadata{DatacenterKey: "west"}, o.metadata())
+
+}
+
+func testOptionsHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, ho := range []*HealthOptions{nil, new(HealthOptions), {FailureThreshold: -1}} {
+		t.Logf("%#v", ho)
+
+		assert.Nil(ho.check())
+		assert.Equal(DefaultHealthInterval, ho.interval())
+		assert.Equal(DefaultHealthFailureThreshold, ho.failureThreshold())
+		assert.Equal(DefaultHealthSuccessThreshold, ho.successThreshold())
+	}
+
+	var (
+		expectedError = errors.New("expected")
+		o             = &Options{
+			Health: &HealthOptions{
+				Check:            func() error { return expectedError },
+				Interval:         30 * time.Second,
+				FailureThreshold: 5,
+				SuccessThreshold: 2,
+			},
+		}
+	)
+
+	assert.Equal(o.Health, o.health())
+	assert.Equal(expectedError, o.health().check()())
+	assert.Equal(30*time.Second, o.health().interval())
+	assert.Equal(5, o.health().failureThreshold())
+	assert.Equal(2, o.health().successThreshold())
+}
+
 func TestOptions(t *testing.T) {
 	t.Run("Default", testOptionsDefault)
 	t.Run("Custom", testOptionsCustom)
+	t.Run("Consul", testOptionsConsul)
+	t.Run("Etcd", testOptionsEtcd)
+	t.Run("DNSSRV", testOptionsDNSSRV)
+	t.Run("Kubernetes", testOptionsKubernetes)
+	t.Run("Hash", testOptionsHash)
+	t.Run("Metadata", testOptionsMetadata)
+	t.Run("Datacenter", testOptionsDatacenter)
+	t.Run("Watches", testOptionsWatches)
+	t.Run("RegistrationCallbacks", testOptionsRegistrationCallbacks)
+	t.Run("Health", testOptionsHealth)
 }
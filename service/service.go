@@ -1,117 +1,255 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"github.com/Comcast/webpa-common/logging"
-	"github.com/go-kit/kit/log"
-	"github.com/go-kit/kit/log/level"
-	"github.com/go-kit/kit/sd"
-	"github.com/go-kit/kit/sd/zk"
 )
 
+// ErrTargetsNotSupported is returned when watching a WatchTarget with a Provider that is not a TargetProvider
+var ErrTargetsNotSupported = errors.New("This service discovery backend does not support watch targets")
+
 // Interface represents a service discovery facade.  It's a very thin layer
-// on top of a go-kit/kit/sd subpackage.
+// on top of a Provider, which is typically backed by a go-kit/kit/sd subpackage.
 type Interface interface {
-	sd.Registrar
+	RegistrarFacade
+
+	// NewInstancer creates an Instancer appropriate for listening for service
+	// changes.  Note that this only supports (1) service at this time.  Registrations
+	// published with metadata are reported as plain instances, and the returned Instancer
+	// is also a MetadataSource.
+	NewInstancer() (Instancer, error)
 
-	// NewInstancer creates an sd.Instancer appropriate for listening for service
-	// changes.  Note that this only supports (1) service at this time.
-	NewInstancer() (sd.Instancer, error)
+	// NewTargetInstancer creates an Instancer for a service other than the configured one, using the
+	// same connection to the backend.  The returned Instancer behaves the same as those from NewInstancer.
+	// If the backend cannot watch other services, ErrTargetsNotSupported is returned.
+	NewTargetInstancer(WatchTarget) (Instancer, error)
 
 	// Close shuts down this facade.  Calling any other method on this instance after
 	// a call to this method is undefined.  However, this method is itself idempotent.
 	Close() error
 }
 
-// zkFacade is the facade for go-kit/kit/sd/zk
-type zkFacade struct {
-	logger    log.Logger
+// Registrar advertises this process with a service discovery backend.  This is the same
+// method set as go-kit's sd.Registrar, so any go-kit registrar can be used.
+type Registrar interface {
+	Register()
+	Deregister()
+}
+
+// RegistrarFacade is a Registrar whose registration can be temporarily removed, e.g. to drain traffic
+// from this process before maintenance, without releasing the connection to the backend.
+type RegistrarFacade interface {
+	Registrar
+
+	// Pause removes this process's registration, if any, until Resume is called.  While paused, Register
+	// and Deregister only record whether a registration is wanted.  This method is idempotent.
+	Pause()
+
+	// Resume restores the registration removed by Pause, if this process is still meant to be registered.
+	// The existing connection, e.g. the Zookeeper session, is reused.  This method is idempotent.
+	Resume()
+
+	// Paused tests if this registrar is currently paused
+	Paused() bool
+}
+
+// Provider is a service discovery backend.  New adapts a Provider into an Interface, which handles
+// the lifecycle that is common to all backends.  Consumers of this package only use Interface, so
+// backends can be added without changing any consuming code.
+type Provider interface {
+	// Registrar returns the Registrar for this process's registration.  This method returns nil if there is no
+	// registration, or if the backend does not support registration.
+	Registrar() Registrar
+
+	// NewInstancer creates an Instancer which watches the configured service
+	NewInstancer() (Instancer, error)
+
+	// Close releases the resources held by this provider, such as connections.  This method is called
+	// at most once, after deregistration.
+	Close() error
+}
+
+// TargetProvider is implemented by Providers which can watch services other than the configured one.
+// All of the providers built into this package implement this interface.
+type TargetProvider interface {
+	// NewTargetInstancer creates an Instancer which watches the given target.  Empty fields of the
+	// target default to the configured service.
+	NewTargetInstancer(WatchTarget) (Instancer, error)
+}
+
+// ProviderFactory creates the Provider for a backend from a set of Options
+type ProviderFactory func(*Options) (Provider, error)
+
+// providers is the registry of backends, keyed by the value of Options.Backend
+var providers = struct {
+	lock      sync.RWMutex
+	factories map[string]ProviderFactory
+}{
+	factories: map[string]ProviderFactory{
+		ZookeeperBackend:  newZkProvider,
+		ConsulBackend:     newConsulProvider,
+		EtcdBackend:       newEtcdProvider,
+		DNSSRVBackend:     newDNSSRVProvider,
+		KubernetesBackend: newKubernetesProvider,
+	},
+}
+
+// RegisterProvider makes a backend available to New under the given name, which is matched against
+// Options.Backend.  Registering a name that is already in use replaces that backend, including the ones
+// built into this package.  This function is safe for concurrent use, but is normally called during initialization.
+func RegisterProvider(backend string, f ProviderFactory) {
+	if len(backend) == 0 {
+		panic("No backend name supplied")
+	}
+
+	if f == nil {
+		panic("No ProviderFactory supplied")
+	}
+
+	providers.lock.Lock()
+	providers.factories[backend] = f
+	providers.lock.Unlock()
+}
+
+func providerFactory(backend string) (ProviderFactory, bool) {
+	providers.lock.RLock()
+	f, ok := providers.factories[backend]
+	providers.lock.RUnlock()
+	return f, ok
+}
+
+// facade is the Interface implementation for every Provider
+type facade struct {
 	state     uint32
-	client    zk.Client
-	path      string
-	registrar sd.Registrar
+	registrar Registrar
+	provider  Provider
+
+	lock       sync.Mutex
+	registered bool
+	paused     bool
 }
 
-func (z *zkFacade) Register() {
-	if z.registrar != nil {
-		z.registrar.Register()
+func (f *facade) Register() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.registered = true
+	if f.registrar != nil && !f.paused {
+		f.registrar.Register()
 	}
 }
 
-func (z *zkFacade) Deregister() {
-	if z.registrar != nil {
-		z.registrar.Deregister()
+func (f *facade) Deregister() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.registered = false
+	if f.registrar != nil && !f.paused {
+		f.registrar.Deregister()
 	}
 }
 
-func (z *zkFacade) NewInstancer() (sd.Instancer, error) {
-	return zk.NewInstancer(
-		z.client,
-		z.path,
-		z.logger,
-	)
+func (f *facade) Pause() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.paused {
+		return
+	}
+
+	f.paused = true
+	if f.registrar != nil && f.registered {
+		f.registrar.Deregister()
+	}
 }
 
-func (z *zkFacade) Close() error {
-	if atomic.CompareAndSwapUint32(&z.state, 0, 1) {
-		z.Deregister()
-		z.client.Stop()
+func (f *facade) Resume() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.paused {
+		return
 	}
 
-	return nil
+	f.paused = false
+	if f.registrar != nil && f.registered {
+		f.registrar.Register()
+	}
 }
 
-var (
-	// zkClientFactory is the factory function used to produce a go-kit zk.Client.
-	// Tests can replace this internal member to take over control of client creation.
-	zkClientFactory func([]string, log.Logger, ...zk.Option) (zk.Client, error) = zk.NewClient
-)
+func (f *facade) Paused() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.paused
+}
+
+func (f *facade) NewInstancer() (Instancer, error) {
+	i, err := f.provider.NewInstancer()
+	if err != nil {
+		return nil, err
+	}
+
+	return newMetadataInstancer(i), nil
+}
+
+func (f *facade) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	tp, ok := f.provider.(TargetProvider)
+	if !ok {
+		return nil, ErrTargetsNotSupported
+	}
+
+	i, err := tp.NewTargetInstancer(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMetadataInstancer(i), nil
+}
 
-// New constructs a service discovery facade from a set of Options.
+func (f *facade) Close() error {
+	if atomic.CompareAndSwapUint32(&f.state, 0, 1) {
+		f.Deregister()
+		return f.provider.Close()
+	}
+
+	return nil
+}
+
+// New constructs a service discovery facade from a set of Options.  The Backend field of the Options
+// selects the service discovery system, with Zookeeper being the default.  Backends other than those
+// built into this package can be made available with RegisterProvider.
 //
 // The returned facade will only be connected to the service discovery backed, e.g. zookeeper.
 // No registration or listening will be active when this function returns.  This allows clients
 // to call Register when the application is truly ready to begin serving requests.
 func New(o *Options) (Interface, error) {
-	var (
-		registration = o.registration()
-		path         = o.path()
-		serviceName  = o.serviceName()
-		registrar    sd.Registrar
-		logger       = logging.DefaultCaller(o.logger(), "serviceName", o.serviceName(), "path", path, "registration", registration)
-
-		// use the internal singleton factory function, which is set to zk.NewClient normally
-		client, err = zkClientFactory(
-			o.servers(),
-			logger,
-			zk.ConnectTimeout(o.connectTimeout()),
-			zk.SessionTimeout(o.sessionTimeout()),
-		)
-	)
+	backend := o.backend()
+	f, ok := providerFactory(backend)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported service discovery backend: %s", backend)
+	}
 
+	p, err := f(o)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(registration) > 0 {
-		registrar = zk.NewRegistrar(
-			client,
-			zk.Service{
-				Path: path,
-				Name: serviceName,
-				Data: []byte(registration),
-			},
-			logger,
+	registrar := p.Registrar()
+	if registrar != nil && o.health().check() != nil {
+		registrar = newHealthRegistrar(
+			logging.DefaultCaller(o.logger(), "serviceName", o.serviceName(), "registration", o.registration()),
+			registrar,
+			o,
 		)
 	}
 
-	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
-
-	return &zkFacade{
-		logger:    logger,
-		client:    client,
-		path:      path,
+	return &facade{
 		registrar: registrar,
+		provider:  p,
 	}, nil
 }
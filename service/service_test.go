@@ -4,58 +4,40 @@ import (
 	"errors"
 	"testing"
 
-	zkclient "github.com/samuel/go-zookeeper/zk"
-
-	"github.com/go-kit/kit/log"
-	"github.com/go-kit/kit/sd"
-	"github.com/go-kit/kit/sd/zk"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func testZkFacade(t *testing.T, o *Options) {
-	defer resetZkClientFactory()
+// registerTestProvider registers a ProviderFactory under a backend name for the duration of a test.
+// The returned function removes the registration.
+func registerTestProvider(backend string, f ProviderFactory) func() {
+	RegisterProvider(backend, f)
+	return func() {
+		providers.lock.Lock()
+		delete(providers.factories, backend)
+		providers.lock.Unlock()
+	}
+}
 
+func testNew(t *testing.T, registrar Registrar) {
 	var (
-		assert  = assert.New(t)
-		require = require.New(t)
-		client  = new(mockClient)
-
-		clientEvents     = make(chan zkclient.Event, 1)
-		initialInstances = []string{"instance1", "instance2"}
-
-		instanceEvents = make(chan sd.Event, 1)
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = new(mockProvider)
+		expected = new(mockInstancer)
+		options  = &Options{Backend: "test"}
 	)
 
-	zkClientFactory = func(servers []string, logger log.Logger, options ...zk.Option) (zk.Client, error) {
-		assert.Equal(o.servers(), servers)
-		assert.NotNil(logger)
-		assert.NotEmpty(options)
-		return client, nil
-	}
-
-	if len(o.registration()) > 0 {
-		client.On("Register", mock.MatchedBy(func(s *zk.Service) bool {
-			assert.Equal(o.path(), s.Path)
-			assert.Equal(o.serviceName(), s.Name)
-			assert.Equal(o.registration(), string(s.Data))
-			return true
-		})).Return(error(nil)).Once()
-
-		client.On("Deregister", mock.MatchedBy(func(s *zk.Service) bool {
-			assert.Equal(o.path(), s.Path)
-			assert.Equal(o.serviceName(), s.Name)
-			assert.Equal(o.registration(), string(s.Data))
-			return true
-		})).Return(error(nil)).Twice() // once during Register/Degister, and once during Stop
-	}
+	defer registerTestProvider("test", func(o *Options) (Provider, error) {
+		assert.Equal(options, o)
+		return provider, nil
+	})()
 
-	client.On("CreateParentNodes", o.path()).Return(error(nil)).Once()
-	client.On("GetEntries", o.path()).Return(initialInstances, (<-chan zkclient.Event)(clientEvents), error(nil)).Once()
-	client.On("Stop").Once()
+	provider.On("Registrar").Return(registrar).Once()
+	provider.On("NewInstancer").Return(expected, error(nil)).Once()
+	provider.On("Close").Return(error(nil)).Once()
 
-	service, err := New(o)
+	service, err := New(options)
 	require.NotNil(service)
 	require.NoError(err)
 
@@ -63,50 +45,242 @@ func testZkFacade(t *testing.T, o *Options) {
 	service.Deregister()
 
 	i, err := service.NewInstancer()
-	require.NotNil(i)
+	require.IsType((*metadataInstancer)(nil), i)
+	assert.Equal(expected, i.(*metadataInstancer).Instancer)
 	assert.NoError(err)
 
-	i.Register(instanceEvents)
-	assert.Equal(sd.Event{Instances: initialInstances}, <-instanceEvents)
-	i.Deregister(instanceEvents)
+	assert.NoError(service.Close())
+	assert.NoError(service.Close()) // idempotency
+
+	provider.AssertExpectations(t)
+}
+
+func testNewWithRegistrar(t *testing.T) {
+	registrar := new(mockRegistrar)
+
+	// once during Register/Deregister, and once during Close
+	registrar.On("Register").Once()
+	registrar.On("Deregister").Twice()
+
+	testNew(t, registrar)
+	registrar.AssertExpectations(t)
+}
+
+func testNewWithHealthCheck(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = new(mockProvider)
+		registrar = new(mockRegistrar)
+	)
+
+	defer registerTestProvider("test", func(o *Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(registrar).Once()
+	service, err := New(&Options{
+		Backend: "test",
+		Health:  &HealthOptions{Check: func() error { return nil }},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+	require.IsType((*healthRegistrar)(nil), service.(*facade).registrar)
+	assert.Equal(registrar, service.(*facade).registrar.(*healthRegistrar).registrar)
+
+	provider.AssertExpectations(t)
+}
+
+func testNewPauseResume(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = new(mockProvider)
+		registrar = new(mockRegistrar)
+	)
+
+	defer registerTestProvider("test", func(o *Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(registrar).Once()
+	provider.On("Close").Return(error(nil)).Once()
 
-	// need to do this to terminate the goroutine
-	i.(*zk.Instancer).Stop()
+	service, err := New(&Options{Backend: "test"})
+	require.NotNil(service)
+	require.NoError(err)
 
+	// pausing before registration has nothing to remove, but registration waits for Resume
+	assert.False(service.Paused())
+	service.Pause()
+	assert.True(service.Paused())
+	service.Register()
+	registrar.AssertNotCalled(t, "Register")
+
+	registrar.On("Register").Once()
+	service.Resume()
+	service.Resume() // idempotency
+	assert.False(service.Paused())
+	registrar.AssertExpectations(t)
+
+	registrar.On("Deregister").Once()
+	service.Pause()
+	service.Pause() // idempotency
+	assert.True(service.Paused())
+	registrar.AssertExpectations(t)
+
+	registrar.On("Register").Once()
+	service.Resume()
+	registrar.AssertExpectations(t)
+
+	// deregistering while paused leaves nothing to restore
+	registrar.On("Deregister").Once()
+	service.Pause()
+	service.Deregister()
+	service.Resume()
+	registrar.AssertExpectations(t)
+
+	// Close deregisters unless paused
+	service.Pause()
 	assert.NoError(service.Close())
-	assert.NoError(service.Close()) // idempotency
 
-	client.AssertExpectations(t)
+	registrar.AssertExpectations(t)
+	provider.AssertExpectations(t)
 }
 
-func testZkFacadeClientFactoryError(t *testing.T) {
-	defer resetZkClientFactory()
+func testNewPauseResumeNoRegistrar(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = new(mockProvider)
+	)
+
+	defer registerTestProvider("test", func(o *Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(nil).Once()
+	provider.On("Close").Return(error(nil)).Once()
 
+	service, err := New(&Options{Backend: "test"})
+	require.NotNil(service)
+	require.NoError(err)
+
+	service.Register()
+	service.Pause()
+	assert.True(service.Paused())
+	service.Resume()
+	assert.False(service.Paused())
+
+	assert.NoError(service.Close())
+	provider.AssertExpectations(t)
+}
+
+func testNewProviderError(t *testing.T) {
 	var (
 		assert        = assert.New(t)
 		expectedError = errors.New("expected")
 	)
 
-	zkClientFactory = func([]string, log.Logger, ...zk.Option) (zk.Client, error) {
+	defer registerTestProvider("test", func(*Options) (Provider, error) {
 		return nil, expectedError
-	}
+	})()
 
-	service, err := New(nil)
+	service, err := New(&Options{Backend: "test"})
 	assert.Nil(service)
 	assert.Equal(expectedError, err)
 }
 
-func TestZkFacade(t *testing.T) {
-	t.Run("Nil", func(t *testing.T) { testZkFacade(t, nil) })
-	t.Run("Default", func(t *testing.T) { testZkFacade(t, new(Options)) })
-	t.Run("Nontrivial", func(t *testing.T) {
-		testZkFacade(t, &Options{
-			Connection:   "host1:2181,host2:2181",
-			Path:         "/foo/bar",
-			ServiceName:  "testing",
-			Registration: "localhost:1400",
-		})
+func testNewTargetInstancer(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		provider      = new(mockTargetProvider)
+		expected      = new(mockInstancer)
+		expectedError = errors.New("expected")
+		target        = WatchTarget{Path: "/xmidt/prod", ServiceName: "petasos"}
+	)
+
+	defer registerTestProvider("test", func(*Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(nil).Once()
+	provider.On("NewTargetInstancer", target).Return(expected, error(nil)).Once()
+	provider.On("NewTargetInstancer", WatchTarget{}).Return(nil, expectedError).Once()
+
+	service, err := New(&Options{Backend: "test"})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(target)
+	require.IsType((*metadataInstancer)(nil), i)
+	assert.Equal(expected, i.(*metadataInstancer).Instancer)
+	assert.NoError(err)
+
+	i, err = service.NewTargetInstancer(WatchTarget{})
+	assert.Nil(i)
+	assert.Equal(expectedError, err)
+
+	provider.AssertExpectations(t)
+}
+
+func testNewTargetsNotSupported(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = new(mockProvider)
+	)
+
+	defer registerTestProvider("test", func(*Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(nil).Once()
+
+	service, err := New(&Options{Backend: "test"})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(WatchTarget{ServiceName: "petasos"})
+	assert.Nil(i)
+	assert.Equal(ErrTargetsNotSupported, err)
+
+	provider.AssertExpectations(t)
+}
+
+func testNewUnsupportedBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	service, err := New(&Options{Backend: "nosuch"})
+	assert.Nil(service)
+	assert.Error(err)
+}
+
+func TestNew(t *testing.T) {
+	t.Run("NoRegistrar", func(t *testing.T) { testNew(t, nil) })
+	t.Run("WithRegistrar", testNewWithRegistrar)
+	t.Run("WithHealthCheck", testNewWithHealthCheck)
+	t.Run("PauseResume", testNewPauseResume)
+	t.Run("PauseResumeNoRegistrar", testNewPauseResumeNoRegistrar)
+	t.Run("ProviderError", testNewProviderError)
+	t.Run("TargetInstancer", testNewTargetInstancer)
+	t.Run("TargetsNotSupported", testNewTargetsNotSupported)
+	t.Run("UnsupportedBackend", testNewUnsupportedBackend)
+}
+
+func TestRegisterProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() {
+		RegisterProvider("", newZkProvider)
+	})
+
+	assert.Panics(func() {
+		RegisterProvider("test", nil)
 	})
 
-	t.Run("ClientFactoryError", testZkFacadeClientFactoryError)
+	_, ok := providerFactory("test")
+	assert.False(ok)
 }
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+var (
+	// signalNotify and signalStop are the functions used to receive signals.
+	// Tests can replace these internal members to take over control of signal delivery.
+	signalNotify = signal.Notify
+	signalStop   = signal.Stop
+)
+
+// DeregisterOnSignal closes a service discovery facade, which deregisters this process, when any of the
+// given signals is received.  If no signals are supplied, SIGTERM and os.Interrupt are used.  This removes a
+// registration as soon as a deploy begins to stop this process, rather than when its session expires in
+// Zookeeper, so clients stop being routed to this process sooner.
+//
+// Signals are still delivered to any other channels registered with the os/signal package, so an application's
+// own shutdown logic is unaffected.  However, watching a signal with os/signal disables its default behavior, so
+// a SIGTERM or os.Interrupt no longer terminates the process by itself.  This function does not exit or re-raise
+// the signal:  the caller must exit once the returned channel is closed, either through its own signal handling or
+// by waiting on the channel.  The channel is closed once the facade has been closed, and an application should also
+// wait on it before exiting so that deregistration completes.  The returned function stops watching for signals
+// without closing the facade, and is idempotent.
+func DeregisterOnSignal(logger log.Logger, i Interface, signals ...os.Signal) (<-chan struct{}, func()) {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+
+	var (
+		received = make(chan os.Signal, 1)
+		closed   = make(chan struct{})
+		cancel   = make(chan struct{})
+		once     sync.Once
+	)
+
+	signalNotify(received, signals...)
+	go func() {
+		defer signalStop(received)
+
+		select {
+		case s := <-received:
+			logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "deregistering from service discovery", "signal", s.String())
+			if err := i.Close(); err != nil {
+				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to close service discovery", logging.ErrorKey(), err)
+			}
+
+			close(closed)
+
+		case <-cancel:
+		}
+	}()
+
+	return closed, func() {
+		once.Do(func() { close(cancel) })
+	}
+}
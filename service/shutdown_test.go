@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSignals replaces the signal functions, returning channels which receive the channel passed to
+// signalNotify, along with the signals, and the channel passed to signalStop
+func fakeSignals() (<-chan chan<- os.Signal, <-chan []os.Signal, <-chan chan<- os.Signal) {
+	var (
+		notified = make(chan chan<- os.Signal, 1)
+		signals  = make(chan []os.Signal, 1)
+		stopped  = make(chan chan<- os.Signal, 1)
+	)
+
+	signalNotify = func(c chan<- os.Signal, s ...os.Signal) {
+		notified <- c
+		signals <- s
+	}
+
+	signalStop = func(c chan<- os.Signal) {
+		stopped <- c
+	}
+
+	return notified, signals, stopped
+}
+
+func testDeregisterOnSignal(t *testing.T, closeError error, expectedSignals []os.Signal, s ...os.Signal) {
+	defer resetSignals()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		registrar = new(mockRegistrar)
+		provider  = new(mockProvider)
+		f         = &facade{registrar: registrar, provider: provider}
+
+		notified, signals, stopped = fakeSignals()
+	)
+
+	registrar.On("Deregister").Once()
+	provider.On("Close").Return(closeError).Once()
+
+	closed, cancel := DeregisterOnSignal(logging.NewTestLogger(nil, t), f, s...)
+	require.NotNil(closed)
+	require.NotNil(cancel)
+
+	c := <-notified
+	assert.Equal(expectedSignals, <-signals)
+
+	c <- syscall.SIGTERM
+	select {
+	case <-closed:
+		// passing
+	case <-time.After(time.Second):
+		assert.Fail("The facade was not closed")
+	}
+
+	assert.Equal(c, <-stopped)
+	cancel()
+	cancel() // idempotency
+
+	registrar.AssertExpectations(t)
+	provider.AssertExpectations(t)
+}
+
+func testDeregisterOnSignalCancel(t *testing.T) {
+	defer resetSignals()
+
+	var (
+		assert   = assert.New(t)
+		provider = new(mockProvider)
+		f        = &facade{provider: provider}
+
+		notified, _, stopped = fakeSignals()
+	)
+
+	closed, cancel := DeregisterOnSignal(nil, f)
+	c := <-notified
+
+	cancel()
+	assert.Equal(c, <-stopped)
+
+	select {
+	case <-closed:
+		assert.Fail("The facade should not have been closed")
+	default:
+		// passing
+	}
+
+	provider.AssertNotCalled(t, "Close")
+}
+
+func TestDeregisterOnSignal(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testDeregisterOnSignal(t, nil, []os.Signal{syscall.SIGTERM, os.Interrupt})
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		testDeregisterOnSignal(t, nil, []os.Signal{syscall.SIGTERM}, syscall.SIGTERM)
+	})
+
+	t.Run("CloseError", func(t *testing.T) {
+		testDeregisterOnSignal(t, errors.New("expected"), []os.Signal{syscall.SIGTERM, os.Interrupt})
+	})
+
+	t.Run("Cancel", testDeregisterOnSignalCancel)
+}
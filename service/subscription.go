@@ -7,6 +7,7 @@ import (
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/sd"
 )
 
@@ -47,6 +48,8 @@ type subscription struct {
 	after           func(time.Duration) <-chan time.Time
 	instancesFilter InstancesFilter
 	accessorFactory AccessorFactory
+	updateCount     metrics.Counter
+	instanceCount   metrics.Gauge
 }
 
 // String returns a string representation of this Subscription, useful
@@ -78,6 +81,8 @@ func (s *subscription) Stop() {
 // over the Updates channel
 func (s *subscription) dispatch(instances []string) {
 	filtered := s.instancesFilter(instances)
+	s.updateCount.Add(1)
+	s.instanceCount.Set(float64(len(filtered)))
 	s.infoLog.Log(logging.MessageKey(), "dispatching updated instances", "instances", filtered)
 	s.updates <- s.accessorFactory(filtered)
 }
@@ -161,6 +166,7 @@ func Subscribe(o *Options, i sd.Instancer) Subscription {
 		serviceName = o.serviceName()
 		path        = o.path()
 		updateDelay = o.updateDelay()
+		measures    = NewMeasures(o.metricsProvider())
 
 		s = &subscription{
 			errorLog:        logging.Error(logger, "serviceName", serviceName, "path", path, "updateDelay", updateDelay),
@@ -173,7 +179,9 @@ func Subscribe(o *Options, i sd.Instancer) Subscription {
 			updateDelay:     updateDelay,
 			after:           o.after(),
 			instancesFilter: o.instancesFilter(),
-			accessorFactory: o.accessorFactory(),
+			accessorFactory: o.instancesAccessorFactory(i),
+			updateCount:     measures.WatchUpdates.With(ServiceLabel, serviceName),
+			instanceCount:   measures.Instances.With(ServiceLabel, serviceName),
 		}
 	)
 
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes every problem found with an Options, so that all of them can be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return "Invalid service discovery configuration: " + strings.Join(ve.Problems, "; ")
+}
+
+// Validate checks an Options for settings which cannot work, or which would be silently ignored, so that
+// misconfiguration is reported at startup rather than as a failure to register or discover.  A nil Options is
+// valid.  If there are any problems, the returned error is a *ValidationError.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	var (
+		problems []string
+		backend  = o.backend()
+	)
+
+	if _, ok := providerFactory(backend); !ok {
+		problems = append(problems, fmt.Sprintf("backend %q is not supported, use one of %s", backend, strings.Join(providerNames(), ", ")))
+	}
+
+	if backend == ZookeeperBackend {
+		problems = append(problems, o.validateServers()...)
+
+		if o.ConnectTimeout > 0 && o.SessionTimeout > 0 && o.SessionTimeout < o.ConnectTimeout {
+			problems = append(problems, fmt.Sprintf("sessionTimeout (%s) must be at least connectTimeout (%s)", o.SessionTimeout, o.ConnectTimeout))
+		}
+	} else if len(o.Connection) > 0 || len(o.Servers) > 0 {
+		problems = append(problems, fmt.Sprintf("connection and servers only apply to the %s backend, not %s", ZookeeperBackend, backend))
+	}
+
+	for _, d := range []struct {
+		name  string
+		value fmt.Stringer
+		ok    bool
+	}{
+		{"connectTimeout", o.ConnectTimeout, o.ConnectTimeout >= 0},
+		{"sessionTimeout", o.SessionTimeout, o.SessionTimeout >= 0},
+		{"updateDelay", o.UpdateDelay, o.UpdateDelay >= 0},
+	} {
+		if !d.ok {
+			problems = append(problems, fmt.Sprintf("%s (%s) must not be negative", d.name, d.value))
+		}
+	}
+
+	if _, err := NewConsistentAccessorFactory(o.vnodeCount(), o.hash()); err != nil {
+		problems = append(problems, fmt.Sprintf("hash %q is not supported, use one of %s, %s, or %s", o.Hash, CRC32Hash, FNV32Hash, FNV32aHash))
+	}
+
+	if o.PreferLocalDatacenter && len(o.Datacenter) == 0 {
+		problems = append(problems, "preferLocalDatacenter requires a datacenter")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// validateServers checks the Zookeeper servers given by Connection and Servers, which are merged together
+func (o *Options) validateServers() (problems []string) {
+	var (
+		seen    = make(map[string]bool)
+		blank   bool
+		servers []string
+	)
+
+	if len(o.Connection) > 0 {
+		servers = append(servers, strings.Split(o.Connection, ",")...)
+	}
+
+	servers = append(servers, o.Servers...)
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		switch {
+		case len(server) == 0:
+			if !blank {
+				blank = true
+				problems = append(problems, "connection and servers must not contain blank entries")
+			}
+
+		case seen[server]:
+			problems = append(problems, fmt.Sprintf("server %s is listed more than once across connection and servers", server))
+
+		default:
+			seen[server] = true
+		}
+	}
+
+	return
+}
+
+// providerNames returns the sorted names of the registered backends
+func providerNames() []string {
+	providers.lock.RLock()
+	names := make([]string, 0, len(providers.factories))
+	for name := range providers.factories {
+		names = append(names, name)
+	}
+
+	providers.lock.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
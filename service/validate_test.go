@@ -0,0 +1,105 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptionsValidateValid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, o := range []*Options{
+		nil,
+		new(Options),
+		{Connection: "host1:2181, host2:2181", Servers: []string{"host3:2181"}, ConnectTimeout: time.Second, SessionTimeout: time.Minute},
+		{SessionTimeout: time.Second},
+		{Backend: ConsulBackend, Hash: FNV32aHash},
+		{Backend: EtcdBackend, Datacenter: "east", PreferLocalDatacenter: true},
+	} {
+		t.Logf("%#v", o)
+		assert.NoError(o.Validate())
+	}
+}
+
+func testOptionsValidateInvalid(t *testing.T) {
+	testData := []struct {
+		name     string
+		options  Options
+		problems []string
+	}{
+		{
+			"UnsupportedBackend",
+			Options{Backend: "nosuch"},
+			[]string{`backend "nosuch" is not supported, use one of consul, dnssrv, etcd, kubernetes, zookeeper`},
+		},
+		{
+			"BlankServers",
+			Options{Connection: "host1:2181,,host2:2181,", Servers: []string{" "}},
+			[]string{"connection and servers must not contain blank entries"},
+		},
+		{
+			"DuplicateServers",
+			Options{Connection: "host1:2181,host2:2181", Servers: []string{"host2:2181"}},
+			[]string{"server host2:2181 is listed more than once across connection and servers"},
+		},
+		{
+			"ServersWithOtherBackend",
+			Options{Backend: ConsulBackend, Servers: []string{"host1:2181"}},
+			[]string{"connection and servers only apply to the zookeeper backend, not consul"},
+		},
+		{
+			"SessionTimeout",
+			Options{ConnectTimeout: time.Minute, SessionTimeout: time.Second},
+			[]string{"sessionTimeout (1s) must be at least connectTimeout (1m0s)"},
+		},
+		{
+			"NegativeDurations",
+			Options{ConnectTimeout: -time.Second, SessionTimeout: -time.Minute, UpdateDelay: -time.Hour},
+			[]string{
+				"connectTimeout (-1s) must not be negative",
+				"sessionTimeout (-1m0s) must not be negative",
+				"updateDelay (-1h0m0s) must not be negative",
+			},
+		},
+		{
+			"UnsupportedHash",
+			Options{Hash: "md5"},
+			[]string{`hash "md5" is not supported, use one of crc32, fnv32, or fnv32a`},
+		},
+		{
+			"PreferLocalDatacenter",
+			Options{PreferLocalDatacenter: true},
+			[]string{"preferLocalDatacenter requires a datacenter"},
+		},
+		{
+			"Several",
+			Options{Backend: EtcdBackend, Connection: "host1:2181", UpdateDelay: -time.Second},
+			[]string{
+				"connection and servers only apply to the zookeeper backend, not etcd",
+				"updateDelay (-1s) must not be negative",
+			},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+				err     = record.options.Validate()
+			)
+
+			require.IsType((*ValidationError)(nil), err)
+			assert.Equal(record.problems, err.(*ValidationError).Problems)
+			assert.Contains(err.Error(), record.problems[0])
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Run("Valid", testOptionsValidateValid)
+	t.Run("Invalid", testOptionsValidateInvalid)
+}
@@ -19,14 +19,19 @@ func Sub(v *viper.Viper) *viper.Viper {
 	return nil
 }
 
-// FromViper returns an Options from a Viper environment.  This function accepts nil,
-// in which case a non-nil default Options instance is returned.
+// FromViper returns an Options from a Viper environment, typically the subtree returned by Sub.  This function
+// accepts nil, in which case a non-nil default Options instance is returned.  Any problem reported by Options.Validate,
+// such as an unsupported Hash, is an error, so that misconfiguration fails fast at startup.
 func FromViper(v *viper.Viper) (*Options, error) {
 	o := new(Options)
 	if v != nil {
 		if err := v.Unmarshal(o); err != nil {
 			return nil, err
 		}
+
+		if err := o.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
 	return o, nil
@@ -86,7 +86,8 @@ func testFromViperUnmarshal(t *testing.T) {
 				"path": "/foo/bar",
 				"serviceName": "fantastical",
 				"registration": "https://foobar.com:8080",
-				"vnodeCount": 567829
+				"vnodeCount": 567829,
+				"hash": "fnv32a"
 			}
 		`
 
@@ -108,6 +109,40 @@ func testFromViperUnmarshal(t *testing.T) {
 	assert.Equal("fantastical", o.ServiceName)
 	assert.Equal("https://foobar.com:8080", o.Registration)
 	assert.Equal(uint(567829), o.VnodeCount)
+	assert.Equal(FNV32aHash, o.Hash)
+}
+
+func testFromViperUnsupportedHash(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = viper.New()
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`{"hash": "md5"}`)))
+
+	o, err := FromViper(v)
+	assert.Nil(o)
+	require.IsType((*ValidationError)(nil), err)
+	require.Len(err.(*ValidationError).Problems, 1)
+	assert.Contains(err.(*ValidationError).Problems[0], `hash "md5"`)
+}
+
+func testFromViperInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = viper.New()
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`{"connectTimeout": "1m", "sessionTimeout": "10s"}`)))
+
+	o, err := FromViper(v)
+	assert.Nil(o)
+	require.IsType((*ValidationError)(nil), err)
+	assert.Len(err.(*ValidationError).Problems, 1)
 }
 
 func TestFromViper(t *testing.T) {
@@ -115,4 +150,6 @@ func TestFromViper(t *testing.T) {
 	t.Run("Missing", testFromViperMissing)
 	t.Run("Error", testFromViperError)
 	t.Run("Unmarshal", testFromViperUnmarshal)
+	t.Run("UnsupportedHash", testFromViperUnsupportedHash)
+	t.Run("Invalid", testFromViperInvalid)
 }
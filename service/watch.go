@@ -0,0 +1,414 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+)
+
+// Event describes the current instances of a watched service.  Each Event is a complete snapshot, which is
+// why intermediate events can be discarded for slow subscribers without losing information.
+type Event struct {
+	// Instances is the filtered set of instances
+	Instances []string
+
+	// Accessor is the Accessor created from Instances.  This Accessor is shared by all subscribers.
+	Accessor Accessor
+
+	// Metadata is the metadata published with each instance's registration, keyed by instance.  Instances
+	// registered without metadata have no entry.  This map is shared by all subscribers and must not be modified.
+	Metadata map[string]Metadata
+
+	// Added is the set of instances which have appeared since the previous event received by the subscriber.
+	// The first event a subscriber receives reports all of its instances as added.
+	Added []string
+
+	// Removed is the set of instances which have disappeared since the previous event received by the subscriber
+	Removed []string
+}
+
+// diffInstances computes the instances added to and removed from a previous set of instances.  Both are sorted.
+func diffInstances(previous, current []string) (added, removed []string) {
+	before := make(map[string]bool, len(previous))
+	for _, i := range previous {
+		before[i] = true
+	}
+
+	after := make(map[string]bool, len(current))
+	for _, i := range current {
+		after[i] = true
+		if !before[i] {
+			added = append(added, i)
+		}
+	}
+
+	for _, i := range previous {
+		if !after[i] {
+			removed = append(removed, i)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+// previousInstances reconstructs the instances prior to an event from its diff
+func (e Event) previousInstances() []string {
+	var (
+		added    = make(map[string]bool, len(e.Added))
+		previous = make([]string, 0, len(e.Instances)+len(e.Removed))
+	)
+
+	for _, i := range e.Added {
+		added[i] = true
+	}
+
+	for _, i := range e.Instances {
+		if !added[i] {
+			previous = append(previous, i)
+		}
+	}
+
+	return append(previous, e.Removed...)
+}
+
+// Subscriber is a single consumer of a Watch's events.  Each Subscriber has its own buffer, so a slow
+// Subscriber never delays the others.
+type Subscriber interface {
+	// Events returns the channel that receives events.  This channel is never closed.  Use Stopped
+	// to react to this subscriber being stopped.
+	//
+	// When the buffer is full, the oldest event is discarded in favor of the newest, so a slow
+	// consumer always catches up to the most recent set of instances.
+	Events() <-chan Event
+
+	// Stopped returns a channel that will be closed when this subscriber has been stopped, either
+	// explicitly or because its Watch was stopped.
+	Stopped() <-chan struct{}
+
+	// Stop removes this subscriber from its Watch.  This method is idempotent.
+	Stop()
+}
+
+// Watch monitors an Instancer with a single registration, and delivers events to any number of Subscribers.
+// This lets multiple components in a process react to changes in a service independently.
+type Watch interface {
+	// Subscribe creates a new Subscriber.  If the Watch has already received instances, the most recent
+	// event is placed into the Subscriber's buffer immediately.  If this Watch is stopped, the returned
+	// Subscriber is already stopped.
+	Subscribe() Subscriber
+
+	// Stopped returns a channel that will be closed when this watch has been stopped
+	Stopped() <-chan struct{}
+
+	// Stop deregisters from the Instancer and stops all Subscribers.  This method is idempotent.
+	Stop()
+}
+
+// subscriber is the internal Subscriber implementation
+type subscriber struct {
+	w       *watch
+	events  chan Event
+	once    sync.Once
+	stopped chan struct{}
+}
+
+func (s *subscriber) Events() <-chan Event {
+	return s.events
+}
+
+func (s *subscriber) Stopped() <-chan struct{} {
+	return s.stopped
+}
+
+func (s *subscriber) Stop() {
+	s.w.remove(s)
+	s.stop()
+}
+
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.stopped) })
+}
+
+// send places an event into this subscriber's buffer without blocking, discarding the oldest events
+// as necessary.  The diff of a discarded event is folded into the event queued after it, so that the
+// diffs the subscriber receives still apply on top of each other.  Only the watch's goroutine sends, so
+// the buffer can only drain while this method runs, and refilling it never blocks.
+func (s *subscriber) send(e Event) {
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+
+	var queued []Event
+	for drained := false; !drained; {
+		select {
+		case q := <-s.events:
+			queued = append(queued, q)
+		default:
+			drained = true
+		}
+	}
+
+	queued = append(queued, e)
+	for len(queued) > cap(s.events) {
+		previous := queued[0].previousInstances()
+		queued = queued[1:]
+		queued[0].Added, queued[0].Removed = diffInstances(previous, queued[0].Instances)
+	}
+
+	for _, q := range queued {
+		s.events <- q
+	}
+}
+
+// watch is the internal Watch implementation
+type watch struct {
+	errorLog log.Logger
+	infoLog  log.Logger
+	debugLog log.Logger
+
+	state   uint32
+	stopped chan struct{}
+
+	buffer          int
+	updateDelay     time.Duration
+	after           func(time.Duration) <-chan time.Time
+	instancesFilter InstancesFilter
+	accessorFactory AccessorFactory
+	metadataSource  MetadataSource
+	updates         metrics.Counter
+	instances       metrics.Gauge
+
+	// release is invoked once the monitor has deregistered from its Instancer
+	release func()
+
+	lock        sync.Mutex
+	last        *Event
+	subscribers map[*subscriber]bool
+}
+
+func (w *watch) Subscribe() Subscriber {
+	s := &subscriber{
+		w:       w,
+		events:  make(chan Event, w.buffer),
+		stopped: make(chan struct{}),
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.subscribers == nil {
+		// this watch has been stopped
+		s.stop()
+		return s
+	}
+
+	if w.last != nil {
+		// this subscriber has seen no instances, so all of them are new to it
+		initial := *w.last
+		initial.Added = make([]string, len(initial.Instances))
+		copy(initial.Added, initial.Instances)
+		initial.Removed = nil
+		s.send(initial)
+	}
+
+	w.subscribers[s] = true
+	return s
+}
+
+func (w *watch) remove(s *subscriber) {
+	w.lock.Lock()
+	delete(w.subscribers, s)
+	w.lock.Unlock()
+}
+
+func (w *watch) Stopped() <-chan struct{} {
+	return w.stopped
+}
+
+func (w *watch) Stop() {
+	if atomic.CompareAndSwapUint32(&w.state, 0, 1) {
+		close(w.stopped)
+	}
+}
+
+// dispatch creates an Event for the given instances, and sends it to every subscriber
+func (w *watch) dispatch(instances []string) {
+	filtered := w.instancesFilter(instances)
+	w.updates.Add(1)
+	w.instances.Set(float64(len(filtered)))
+	e := Event{Instances: filtered, Accessor: w.accessorFactory(filtered)}
+
+	// only the monitor goroutine dispatches, and it is the only writer of last
+	var previous []string
+	if w.last != nil {
+		previous = w.last.Instances
+	}
+
+	e.Added, e.Removed = diffInstances(previous, filtered)
+	w.infoLog.Log(logging.MessageKey(), "dispatching updated instances", "instances", filtered, "added", e.Added, "removed", e.Removed)
+
+	if w.metadataSource != nil {
+		e.Metadata = make(map[string]Metadata)
+		for _, instance := range filtered {
+			if m := w.metadataSource.Metadata(instance); len(m) > 0 {
+				e.Metadata[instance] = m
+			}
+		}
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.last = &e
+	for s := range w.subscribers {
+		s.send(e)
+	}
+}
+
+// monitor is the goroutine that receives Instancer events, coalesces them according to the update delay,
+// and dispatches them to subscribers.
+func (w *watch) monitor(i sd.Instancer) {
+	w.infoLog.Log(logging.MessageKey(), "watch monitor starting")
+
+	var (
+		first            = true
+		events           = make(chan sd.Event, 10)
+		delayedInstances []string
+		delay            <-chan time.Time
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			w.errorLog.Log(logging.MessageKey(), "watch monitor exiting", logging.ErrorKey(), r)
+		} else {
+			w.infoLog.Log(logging.MessageKey(), "watch monitor exiting")
+		}
+
+		i.Deregister(events)
+		w.release()
+
+		// as with subscriptions, ensure that Stop is called to reflect our state in the case of a panic
+		w.Stop()
+
+		w.lock.Lock()
+		subscribers := w.subscribers
+		w.subscribers = nil
+		w.lock.Unlock()
+
+		for s := range subscribers {
+			s.stop()
+		}
+	}()
+
+	i.Register(events)
+
+	for {
+		select {
+		case e := <-events:
+			w.debugLog.Log(logging.MessageKey(), "service discovery event", "instances", e.Instances, logging.ErrorKey(), e.Err)
+
+			switch {
+			case e.Err != nil:
+				w.errorLog.Log(logging.MessageKey(), "service discovery error", logging.ErrorKey(), e.Err)
+
+			case first:
+				// for the very first event, we want to dispatch immediately no matter what
+				first = false
+				w.dispatch(e.Instances)
+
+			case w.updateDelay > 0:
+				if delay == nil {
+					delay = w.after(w.updateDelay)
+				}
+
+				delayedInstances = make([]string, len(e.Instances))
+				copy(delayedInstances, e.Instances)
+				w.infoLog.Log(logging.MessageKey(), "waiting to dispatch updated instances", "instances", delayedInstances)
+
+			default:
+				w.dispatch(e.Instances)
+			}
+
+		case <-delay:
+			w.dispatch(delayedInstances)
+			delay = nil
+			delayedInstances = nil
+
+		case <-w.stopped:
+			w.infoLog.Log(logging.MessageKey(), "watch stopped")
+			return
+		}
+	}
+}
+
+// NewWatch starts monitoring an Instancer on behalf of any number of Subscribers.  Unlike Subscribe, which
+// registers with the Instancer once per Subscription, a Watch registers only once.  Rapid updates are coalesced
+// using the UpdateDelay, and each Subscriber buffers up to WatchBuffer events.  If the Instancer is a MetadataSource,
+// as the Instancers created by Interface.NewInstancer are, each Event carries the metadata of its instances.
+func NewWatch(o *Options, i sd.Instancer) Watch {
+	return newWatch(o, i, func() {})
+}
+
+// newWatch creates a Watch which invokes release after it is finished with its Instancer
+func newWatch(o *Options, i sd.Instancer, release func()) Watch {
+	var (
+		logger      = o.logger()
+		serviceName = o.serviceName()
+		path        = o.path()
+		updateDelay = o.updateDelay()
+		measures    = NewMeasures(o.metricsProvider())
+
+		w = &watch{
+			errorLog:        logging.Error(logger, "serviceName", serviceName, "path", path, "updateDelay", updateDelay),
+			infoLog:         logging.Info(logger, "serviceName", serviceName, "path", path, "updateDelay", updateDelay),
+			debugLog:        logging.Debug(logger, "serviceName", serviceName, "path", path, "updateDelay", updateDelay),
+			stopped:         make(chan struct{}),
+			buffer:          o.watchBuffer(),
+			updateDelay:     updateDelay,
+			after:           o.after(),
+			instancesFilter: o.instancesFilter(),
+			accessorFactory: o.instancesAccessorFactory(i),
+			updates:         measures.WatchUpdates.With(ServiceLabel, serviceName),
+			instances:       measures.Instances.With(ServiceLabel, serviceName),
+			release:         release,
+			subscribers:     make(map[*subscriber]bool),
+		}
+	)
+
+	w.metadataSource, _ = i.(MetadataSource)
+	go w.monitor(i)
+	return w
+}
+
+// NewWatches creates a Watch for each of the Options.Watches targets, keyed by the same names.  All of the
+// watches share the facade's connection to the backend, and stopping a Watch also stops its Instancer.
+// If any target cannot be watched, the watches created so far are stopped and the error is returned.
+func NewWatches(o *Options, i Interface) (map[string]Watch, error) {
+	targets := o.watches()
+	watches := make(map[string]Watch, len(targets))
+
+	for name, target := range targets {
+		instancer, err := i.NewTargetInstancer(target)
+		if err != nil {
+			for _, w := range watches {
+				w.Stop()
+			}
+
+			return nil, err
+		}
+
+		watches[name] = newWatch(o.forTarget(target), instancer, instancer.Stop)
+	}
+
+	return watches, nil
+}
@@ -0,0 +1,571 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// startWatch creates a Watch over a mock Instancer, returning the channel the Watch registered
+// along with a channel that is closed when the Watch deregisters
+func startWatch(t *testing.T, o *Options) (Watch, chan<- sd.Event, <-chan struct{}) {
+	var (
+		instancer = new(mockInstancer)
+
+		registeredChannel chan<- sd.Event
+		registerCalled    = make(chan struct{})
+		deregisterCalled  = make(chan struct{})
+	)
+
+	instancer.On("Register", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		registeredChannel = ch
+		return true
+	})).Run(func(mock.Arguments) { close(registerCalled) }).Once()
+
+	instancer.On("Deregister", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		assert.Equal(t, registeredChannel, ch)
+		return true
+	})).Run(func(mock.Arguments) { close(deregisterCalled) }).Once()
+
+	w := NewWatch(o, instancer)
+
+	select {
+	case <-registerCalled:
+		// passing
+	case <-time.After(time.Second):
+		require.Fail(t, "Instancer.Register was not called")
+	}
+
+	return w, registeredChannel, deregisterCalled
+}
+
+// stopWatch stops a Watch and waits for its monitor to exit, so that nothing logs after the test completes
+func stopWatch(w Watch, deregisterCalled <-chan struct{}) {
+	w.Stop()
+	<-deregisterCalled
+}
+
+func expectEvent(t *testing.T, s Subscriber, expected ...string) {
+	select {
+	case e := <-s.Events():
+		assert.Equal(t, expected, e.Instances)
+		require.NotNil(t, e.Accessor)
+
+		instance, err := e.Accessor.Get([]byte("some key"))
+		assert.Contains(t, expected, instance)
+		assert.NoError(t, err)
+
+	case <-s.Stopped():
+		assert.Fail(t, "The subscriber should not have stopped")
+
+	case <-time.After(time.Second):
+		assert.Fail(t, "No event occurred")
+	}
+}
+
+func testWatchSubscribers(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		options = &Options{
+			Logger: logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			After: func(time.Duration) <-chan time.Time {
+				assert.Fail("The after function should not have been called")
+				return nil
+			},
+		}
+
+		w, events, deregisterCalled = startWatch(t, options)
+		first                       = w.Subscribe()
+	)
+
+	assert.Zero(len(first.Events()))
+
+	events <- sd.Event{Err: errors.New("expected")}
+	events <- sd.Event{Instances: []string{"localhost:8888"}}
+	expectEvent(t, first, "localhost:8888")
+
+	// a subscriber immediately receives the most recent event
+	second := w.Subscribe()
+	expectEvent(t, second, "localhost:8888")
+
+	events <- sd.Event{Instances: []string{"localhost:1234"}}
+	expectEvent(t, first, "localhost:1234")
+	expectEvent(t, second, "localhost:1234")
+
+	first.Stop()
+	first.Stop() // idempotency
+	<-first.Stopped()
+
+	events <- sd.Event{Instances: []string{"localhost:5678"}}
+	expectEvent(t, second, "localhost:5678")
+	assert.Zero(len(first.Events()))
+
+	w.Stop()
+
+	select {
+	case <-deregisterCalled:
+		// passing
+	case <-time.After(time.Second):
+		assert.Fail("Instancer.Deregister was not called")
+	}
+
+	w.Stop() // idempotency
+	<-w.Stopped()
+	<-second.Stopped()
+
+	// subscribing to a stopped watch produces a stopped subscriber
+	third := w.Subscribe()
+	require.NotNil(t, third)
+	<-third.Stopped()
+	assert.Zero(len(third.Events()))
+}
+
+func testWatchSlowSubscriber(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		options = &Options{
+			Logger:      logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			WatchBuffer: 1,
+		}
+
+		w, events, deregisterCalled = startWatch(t, options)
+		slow                        = w.Subscribe()
+		fast                        = w.Subscribe()
+	)
+
+	defer stopWatch(w, deregisterCalled)
+
+	// the fast subscriber shows that each event was dispatched, while the slow subscriber never blocks the watch
+	for _, instance := range []string{"localhost:1", "localhost:2", "localhost:3"} {
+		events <- sd.Event{Instances: []string{instance}}
+		expectEvent(t, fast, instance)
+	}
+
+	assert.Equal(1, len(slow.Events()))
+	expectEvent(t, slow, "localhost:3")
+}
+
+// expectDiff receives the next event from a subscriber and verifies its diff
+func expectDiff(t *testing.T, s Subscriber, added, removed []string) {
+	select {
+	case e := <-s.Events():
+		assert.Equal(t, added, e.Added)
+		assert.Equal(t, removed, e.Removed)
+
+	case <-time.After(time.Second):
+		assert.Fail(t, "No event occurred")
+	}
+}
+
+func testWatchDiff(t *testing.T) {
+	var (
+		options = &Options{
+			Logger:      logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			WatchBuffer: 1,
+		}
+
+		w, events, deregisterCalled = startWatch(t, options)
+		fast                        = w.Subscribe()
+		slow                        = w.Subscribe()
+	)
+
+	defer stopWatch(w, deregisterCalled)
+
+	events <- sd.Event{Instances: []string{"localhost:1", "localhost:2"}}
+	expectDiff(t, fast, []string{"localhost:1", "localhost:2"}, nil)
+
+	// a new subscriber has seen no instances, so every instance is added
+	late := w.Subscribe()
+	expectDiff(t, late, []string{"localhost:1", "localhost:2"}, nil)
+
+	events <- sd.Event{Instances: []string{"localhost:2", "localhost:3"}}
+	expectDiff(t, fast, []string{"localhost:3"}, []string{"localhost:1"})
+
+	events <- sd.Event{Instances: []string{"localhost:3", "localhost:4"}}
+	expectDiff(t, fast, []string{"localhost:4"}, []string{"localhost:2"})
+
+	events <- sd.Event{Instances: []string{"localhost:3", "localhost:4"}}
+	expectDiff(t, fast, nil, nil)
+
+	// the diffs of discarded events are folded into the events that replace them
+	expectDiff(t, late, []string{"localhost:3", "localhost:4"}, []string{"localhost:1", "localhost:2"})
+	expectDiff(t, slow, []string{"localhost:3", "localhost:4"}, nil)
+}
+
+func testWatchDiffDiscarded(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		options = &Options{
+			Logger:      logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			WatchBuffer: 3,
+		}
+
+		w, events, deregisterCalled = startWatch(t, options)
+		fast                        = w.Subscribe()
+		slow                        = w.Subscribe()
+
+		updates = [][]string{
+			{"localhost:1", "localhost:2"},
+			{"localhost:2", "localhost:3"},
+			{"localhost:3"},
+			{"localhost:1", "localhost:3", "localhost:4"},
+			{"localhost:4", "localhost:5"},
+			{"localhost:2", "localhost:5", "localhost:6"},
+			{"localhost:6"},
+		}
+	)
+
+	defer stopWatch(w, deregisterCalled)
+
+	for _, instances := range updates {
+		events <- sd.Event{Instances: instances}
+		expectEvent(t, fast, instances...)
+	}
+
+	// several events were discarded, yet each diff the slow subscriber sees applies cleanly to the previous one
+	require.Equal(3, len(slow.Events()))
+	current := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		e := <-slow.Events()
+		for _, removed := range e.Removed {
+			assert.True(current[removed], "removed instance %s was not present", removed)
+			delete(current, removed)
+		}
+
+		for _, added := range e.Added {
+			assert.False(current[added], "added instance %s was already present", added)
+			current[added] = true
+		}
+
+		assert.Len(current, len(e.Instances))
+		for _, instance := range e.Instances {
+			assert.True(current[instance], "instance %s is missing after applying the diff", instance)
+		}
+	}
+
+	assert.Equal(map[string]bool{"localhost:6": true}, current)
+
+	// the initial event of a new subscriber does not share its Added slice with the watch's Instances
+	late := w.Subscribe()
+	e := <-late.Events()
+	require.Len(e.Added, 1)
+	e.Added[0] = "mutated"
+	assert.Equal([]string{"localhost:6"}, e.Instances)
+}
+
+func testWatchDelay(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		delay   = make(chan time.Time, 1)
+		options = &Options{
+			Logger:      logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			UpdateDelay: 5 * time.Minute,
+			After: func(d time.Duration) <-chan time.Time {
+				assert.Equal(5*time.Minute, d)
+				return delay
+			},
+		}
+
+		w, events, deregisterCalled = startWatch(t, options)
+		s                           = w.Subscribe()
+	)
+
+	defer stopWatch(w, deregisterCalled)
+
+	// the first event is always dispatched immediately
+	events <- sd.Event{Instances: []string{"localhost:8888"}}
+	expectEvent(t, s, "localhost:8888")
+
+	// rapid updates are coalesced until the delay elapses
+	events <- sd.Event{Instances: []string{"localhost:1"}}
+	events <- sd.Event{Instances: []string{"localhost:2"}}
+	events <- sd.Event{Instances: []string{"localhost:3"}}
+	assert.Zero(len(s.Events()))
+
+	// once the last update has been received, the monitor handles it before the delay
+	for len(events) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	delay <- time.Now()
+	expectEvent(t, s, "localhost:3")
+	assert.Zero(len(s.Events()))
+}
+
+func testWatchMetadata(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		instancer = new(mockInstancer)
+
+		relay            chan<- sd.Event
+		registerCalled   = make(chan struct{})
+		deregisterCalled = make(chan struct{})
+	)
+
+	instancer.On("Register", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		relay = ch
+		return true
+	})).Run(func(mock.Arguments) { close(registerCalled) }).Once()
+
+	instancer.On("Deregister", mock.Anything).Run(func(mock.Arguments) { close(deregisterCalled) }).Once()
+
+	w := NewWatch(
+		&Options{Logger: logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t)},
+		newMetadataInstancer(instancer),
+	)
+
+	defer stopWatch(w, deregisterCalled)
+	s := w.Subscribe()
+
+	select {
+	case <-registerCalled:
+		// passing
+	case <-time.After(time.Second):
+		require.Fail("Instancer.Register was not called")
+	}
+
+	relay <- sd.Event{Instances: []string{
+		EncodeRegistration("http://host1:8080", Metadata{"version": "1.2.3"}),
+		"http://host2:8080",
+	}}
+
+	select {
+	case e := <-s.Events():
+		assert.Equal([]string{"http://host1:8080", "http://host2:8080"}, e.Instances)
+		assert.Equal(map[string]Metadata{"http://host1:8080": {"version": "1.2.3"}}, e.Metadata)
+	case <-time.After(time.Second):
+		require.Fail("No event occurred")
+	}
+}
+
+func testWatchPreferLocalDatacenter(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		instancer = new(mockInstancer)
+
+		relay            chan<- sd.Event
+		registerCalled   = make(chan struct{})
+		deregisterCalled = make(chan struct{})
+	)
+
+	instancer.On("Register", mock.MatchedBy(func(ch chan<- sd.Event) bool {
+		relay = ch
+		return true
+	})).Run(func(mock.Arguments) { close(registerCalled) }).Once()
+
+	instancer.On("Deregister", mock.Anything).Run(func(mock.Arguments) { close(deregisterCalled) }).Once()
+
+	w := NewWatch(
+		&Options{
+			Logger:                logging.NewTestLogger(nil, t),
+			Datacenter:            "east",
+			PreferLocalDatacenter: true,
+		},
+		newMetadataInstancer(instancer),
+	)
+
+	defer stopWatch(w, deregisterCalled)
+	s := w.Subscribe()
+
+	select {
+	case <-registerCalled:
+		// passing
+	case <-time.After(time.Second):
+		require.Fail("Instancer.Register was not called")
+	}
+
+	relay <- sd.Event{Instances: []string{
+		EncodeRegistration("http://host1:8080", Metadata{DatacenterKey: "east"}),
+		EncodeRegistration("http://host2:8080", Metadata{DatacenterKey: "west"}),
+		"http://host3:8080",
+	}}
+
+	select {
+	case e := <-s.Events():
+		assert.Len(e.Instances, 3)
+		for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+			instance, err := e.Accessor.Get([]byte(key))
+			assert.Equal("http://host1:8080", instance)
+			assert.NoError(err)
+		}
+	case <-time.After(time.Second):
+		require.Fail("No event occurred")
+	}
+
+	// without local instances, keys fail over to the remote instances
+	relay <- sd.Event{Instances: []string{
+		EncodeRegistration("http://host2:8080", Metadata{DatacenterKey: "west"}),
+	}}
+
+	select {
+	case e := <-s.Events():
+		instance, err := e.Accessor.Get([]byte("a"))
+		assert.Equal("http://host2:8080", instance)
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		require.Fail("No event occurred")
+	}
+}
+
+func testWatchMetrics(t *testing.T) {
+	var (
+		assert          = assert.New(t)
+		metricsProvider = new(mockMetricsProvider)
+		updates         = new(mockCounter)
+		instances       = new(mockGauge)
+		updateCount     = generic.NewCounter("updates")
+		instanceCount   = generic.NewGauge("instances")
+	)
+
+	metricsProvider.On("NewCounter", WatchUpdateCounter).Return(updates).Once()
+	metricsProvider.On("NewCounter", mock.AnythingOfType("string")).Return(discard.NewCounter())
+	metricsProvider.On("NewGauge", InstanceGauge).Return(instances).Once()
+	metricsProvider.On("NewGauge", mock.AnythingOfType("string")).Return(discard.NewGauge())
+	updates.On("With", []string{ServiceLabel, "talaria"}).Return(updateCount).Once()
+	instances.On("With", []string{ServiceLabel, "talaria"}).Return(instanceCount).Once()
+
+	w, events, deregisterCalled := startWatch(t, &Options{
+		Logger:          logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+		ServiceName:     "talaria",
+		MetricsProvider: metricsProvider,
+	})
+
+	defer stopWatch(w, deregisterCalled)
+	s := w.Subscribe()
+
+	events <- sd.Event{Instances: []string{"localhost:1", "localhost:2"}}
+	expectEvent(t, s, "localhost:1", "localhost:2")
+	events <- sd.Event{Instances: []string{"localhost:3"}}
+	expectEvent(t, s, "localhost:3")
+
+	assert.Equal(2.0, updateCount.Value())
+	assert.Equal(1.0, instanceCount.Value())
+
+	metricsProvider.AssertExpectations(t)
+	updates.AssertExpectations(t)
+	instances.AssertExpectations(t)
+}
+
+func testNewWatches(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = new(mockTargetProvider)
+		targets  = map[string]WatchTarget{
+			"petasos": {ServiceName: "petasos"},
+			"codex":   {Path: "/xmidt/prod", ServiceName: "codex"},
+		}
+
+		options = &Options{
+			Logger:  logging.NewTestLogger(&logging.Options{Level: "debug", JSON: true}, t),
+			Backend: "test",
+			Watches: targets,
+		}
+
+		instancers = make(map[string]*mockInstancer)
+		stopped    = make(chan string, len(targets))
+	)
+
+	defer registerTestProvider("test", func(*Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(nil).Once()
+	for name, target := range targets {
+		name := name
+		instancer := new(mockInstancer)
+		instancer.On("Register", mock.AnythingOfType("chan<- sd.Event")).Once()
+		instancer.On("Deregister", mock.AnythingOfType("chan<- sd.Event")).Once()
+		instancer.On("Stop").Run(func(mock.Arguments) { stopped <- name }).Once()
+
+		instancers[name] = instancer
+		provider.On("NewTargetInstancer", target).Return(instancer, error(nil)).Once()
+	}
+
+	service, err := New(options)
+	require.NotNil(service)
+	require.NoError(err)
+
+	watches, err := NewWatches(options, service)
+	require.NoError(err)
+	require.Len(watches, len(targets))
+
+	for name := range targets {
+		w := watches[name]
+		require.NotNil(w)
+		w.Stop()
+
+		select {
+		case actual := <-stopped:
+			assert.Equal(name, actual)
+		case <-time.After(time.Second):
+			assert.Fail("The instancer was not stopped", name)
+		}
+
+		instancers[name].AssertExpectations(t)
+	}
+
+	provider.AssertExpectations(t)
+}
+
+func testNewWatchesError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		provider      = new(mockTargetProvider)
+		expectedError = errors.New("expected")
+		options       = &Options{
+			Backend: "test",
+			Watches: map[string]WatchTarget{"petasos": {ServiceName: "petasos"}},
+		}
+	)
+
+	defer registerTestProvider("test", func(*Options) (Provider, error) {
+		return provider, nil
+	})()
+
+	provider.On("Registrar").Return(nil).Once()
+	provider.On("NewTargetInstancer", WatchTarget{ServiceName: "petasos"}).Return(nil, expectedError).Once()
+
+	service, err := New(options)
+	require.NotNil(service)
+	require.NoError(err)
+
+	watches, err := NewWatches(options, service)
+	assert.Nil(watches)
+	assert.Equal(expectedError, err)
+
+	// no targets produces no watches
+	watches, err = NewWatches(nil, service)
+	assert.Empty(watches)
+	assert.NoError(err)
+
+	provider.AssertExpectations(t)
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("Subscribers", testWatchSubscribers)
+	t.Run("SlowSubscriber", testWatchSlowSubscriber)
+	t.Run("Delay", testWatchDelay)
+	t.Run("Diff", testWatchDiff)
+	t.Run("DiffDiscarded", testWatchDiffDiscarded)
+	t.Run("Metadata", testWatchMetadata)
+	t.Run("PreferLocalDatacenter", testWatchPreferLocalDatacenter)
+	t.Run("Metrics", testWatchMetrics)
+}
+
+func TestNewWatches(t *testing.T) {
+	t.Run("Targets", testNewWatches)
+	t.Run("Error", testNewWatchesError)
+}
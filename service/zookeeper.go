@@ -0,0 +1,288 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/zk"
+	zkclient "github.com/samuel/go-zookeeper/zk"
+)
+
+var (
+	// zkClientFactory is the factory function used to produce a go-kit zk.Client.
+	// Tests can replace this internal member to take over control of client creation.
+	zkClientFactory func([]string, log.Logger, ...zk.Option) (zk.Client, error) = zk.NewClient
+)
+
+// zkRegistrar is an sd.Registrar which stores a service's registration in an ephemeral znode.  Unlike go-kit's
+// zk.Registrar, the znode is recreated when the Zookeeper session expires while registered, as Zookeeper
+// deletes ephemeral znodes along with their session.  A disconnection alone does not lose the registration.
+type zkRegistrar struct {
+	logger    log.Logger
+	service   zk.Service
+	backoff   backoff
+	onBackoff func(BackoffEvent)
+	after     func(time.Duration) <-chan time.Time
+	lost      func(error)
+	restored  func()
+	attempts  metrics.Counter
+	failures  metrics.Counter
+
+	lock       sync.Mutex
+	client     zk.Client
+	registered bool
+	expired    bool
+	restoring  bool
+}
+
+func (r *zkRegistrar) Register() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.registered {
+		return
+	}
+
+	if err := r.register(); err != nil {
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to register with zookeeper", logging.ErrorKey(), err)
+		return
+	}
+
+	r.registered = true
+	r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "registered with zookeeper")
+}
+
+// register makes a single attempt to create this registration's znode.  The lock must be held.
+func (r *zkRegistrar) register() error {
+	r.attempts.Add(1)
+	err := r.client.Register(&r.service)
+	if err != nil {
+		r.failures.Add(1)
+	}
+
+	return err
+}
+
+func (r *zkRegistrar) Deregister() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.registered {
+		return
+	}
+
+	expired := r.expired
+	r.registered, r.expired = false, false
+	if expired {
+		// the znode went away with the expired session
+		r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "deregistered from zookeeper")
+		return
+	}
+
+	if err := r.client.Deregister(&r.service); err != nil {
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to deregister from zookeeper", logging.ErrorKey(), err)
+		return
+	}
+
+	r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "deregistered from zookeeper")
+}
+
+// handleEvent is the Zookeeper session event handler.  An expired session loses the registration, which is
+// restored once a new session is established.
+func (r *zkRegistrar) handleEvent(e zkclient.Event) {
+	switch e.State {
+	case zkclient.StateExpired:
+		r.lock.Lock()
+		if !r.registered || r.expired {
+			r.lock.Unlock()
+			return
+		}
+
+		r.expired = true
+		r.lock.Unlock()
+
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "zookeeper session expired, restoring the registration")
+		r.lost(zkclient.ErrSessionExpired)
+
+	case zkclient.StateHasSession:
+		r.lock.Lock()
+		if !r.registered || !r.expired || r.restoring {
+			r.lock.Unlock()
+			return
+		}
+
+		r.restoring = true
+		r.lock.Unlock()
+
+		// events are dispatched serially, so the handler must not block
+		go r.restore()
+	}
+}
+
+// restore retries the registration, backing off between attempts, until it succeeds or this registration is deregistered
+func (r *zkRegistrar) restore() {
+	for attempt := 1; ; attempt++ {
+		r.lock.Lock()
+		if !r.registered || !r.expired {
+			r.restoring = false
+			r.lock.Unlock()
+			return
+		}
+
+		err := r.register()
+		if err == nil {
+			r.expired, r.restoring = false, false
+		}
+
+		r.lock.Unlock()
+
+		if err == nil {
+			r.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "restored registration with zookeeper")
+			r.restored()
+			return
+		}
+
+		delay := r.backoff.delay(attempt)
+		r.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to restore registration with zookeeper", "attempt", attempt, "delay", delay, logging.ErrorKey(), err)
+		r.onBackoff(BackoffEvent{Operation: BackoffRegister, Attempt: attempt, Delay: delay, Err: err})
+		<-r.after(delay)
+	}
+}
+
+// newZkEventHandler produces the Zookeeper session event handler, which replaces go-kit's default handler.  Every
+// session state transition is logged and counted, and passed along to the registrar if there is one.
+func newZkEventHandler(logger log.Logger, session metrics.Counter, registrar *zkRegistrar) func(zkclient.Event) {
+	return func(e zkclient.Event) {
+		logger.Log(level.Key(), level.DebugValue(), "eventType", e.Type.String(), "server", e.Server, "state", e.State.String(), logging.ErrorKey(), e.Err)
+		session.With(StateLabel, e.State.String()).Add(1)
+
+		if registrar != nil {
+			registrar.handleEvent(e)
+		}
+	}
+}
+
+// zkProvider is the Provider for go-kit/kit/sd/zk
+type zkProvider struct {
+	logger    log.Logger
+	client    zk.Client
+	path      string
+	registrar sd.Registrar
+}
+
+func (z *zkProvider) Registrar() Registrar {
+	return z.registrar
+}
+
+func (z *zkProvider) NewInstancer() (Instancer, error) {
+	return z.NewTargetInstancer(WatchTarget{})
+}
+
+// NewTargetInstancer watches the target's Path.  Zookeeper registrations are not organized by service name,
+// so the target's ServiceName is not used.
+func (z *zkProvider) NewTargetInstancer(t WatchTarget) (Instancer, error) {
+	path := z.path
+	if len(t.Path) > 0 {
+		path = t.Path
+	}
+
+	i, err := zk.NewInstancer(
+		z.client,
+		path,
+		z.logger,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (z *zkProvider) Close() error {
+	z.client.Stop()
+	return nil
+}
+
+// newZkProvider constructs the Zookeeper provider
+func newZkProvider(o *Options) (Provider, error) {
+	var (
+		registration = o.registration()
+		path         = o.path()
+		serviceName  = o.serviceName()
+		backoff      = newBackoff(o.backoff())
+		onBackoff    = o.onBackoff()
+		after        = o.after()
+		measures     = NewMeasures(o.metricsProvider())
+		registrar    *zkRegistrar
+		logger       = logging.DefaultCaller(o.logger(), "serviceName", o.serviceName(), "path", path, "registration", registration)
+
+		options = []zk.Option{
+			zk.ConnectTimeout(o.connectTimeout()),
+			zk.SessionTimeout(o.sessionTimeout()),
+		}
+	)
+
+	if len(registration) > 0 {
+		registrar = &zkRegistrar{
+			logger: logger,
+			service: zk.Service{
+				Path: path,
+				Name: serviceName,
+				Data: []byte(o.encodedRegistration()),
+			},
+			backoff:   backoff,
+			onBackoff: onBackoff,
+			after:     after,
+			lost:      o.registrationLost(),
+			restored:  o.registrationRestored(),
+			attempts:  measures.RegistrationAttempts.With(ServiceLabel, serviceName),
+			failures:  measures.RegistrationFailures.With(ServiceLabel, serviceName),
+		}
+	}
+
+	options = append(options, zk.EventHandler(newZkEventHandler(logger, measures.ZookeeperSession, registrar)))
+
+	var (
+		client          zk.Client
+		err             error
+		connectAttempts = o.backoff().connectAttempts()
+	)
+
+	for attempt := 1; ; attempt++ {
+		// use the internal singleton factory function, which is set to zk.NewClient normally
+		client, err = zkClientFactory(o.servers(), logger, options...)
+		if err == nil {
+			break
+		} else if attempt >= connectAttempts {
+			return nil, err
+		}
+
+		delay := backoff.delay(attempt)
+		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to connect to zookeeper", "attempt", attempt, "delay", delay, logging.ErrorKey(), err)
+		onBackoff(BackoffEvent{Operation: BackoffConnect, Attempt: attempt, Delay: delay, Err: err})
+		<-after(delay)
+	}
+
+	p := &zkProvider{
+		logger: logger,
+		client: client,
+		path:   path,
+	}
+
+	if registrar != nil {
+		// session events can arrive as soon as the client exists
+		registrar.lock.Lock()
+		registrar.client = client
+		registrar.lock.Unlock()
+		p.registrar = registrar
+	}
+
+	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "service discovery initialized")
+	return p, nil
+}
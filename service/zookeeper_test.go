@@ -0,0 +1,385 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	zkclient "github.com/samuel/go-zookeeper/zk"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/zk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testZkFacade(t *testing.T, o *Options) {
+	defer resetZkClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		client  = new(mockClient)
+
+		clientEvents     = make(chan zkclient.Event, 1)
+		initialInstances = []string{"instance1", "instance2"}
+
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	zkClientFactory = func(servers []string, logger log.Logger, options ...zk.Option) (zk.Client, error) {
+		assert.Equal(o.servers(), servers)
+		assert.NotNil(logger)
+		assert.NotEmpty(options)
+		return client, nil
+	}
+
+	if len(o.registration()) > 0 {
+		client.On("Register", mock.MatchedBy(func(s *zk.Service) bool {
+			assert.Equal(o.path(), s.Path)
+			assert.Equal(o.serviceName(), s.Name)
+			assert.Equal(o.encodedRegistration(), string(s.Data))
+			return true
+		})).Return(error(nil)).Once()
+
+		client.On("Deregister", mock.MatchedBy(func(s *zk.Service) bool {
+			assert.Equal(o.path(), s.Path)
+			assert.Equal(o.serviceName(), s.Name)
+			assert.Equal(o.encodedRegistration(), string(s.Data))
+			return true
+		})).Return(error(nil)).Once() // Close does not deregister again, since Deregister was already called
+	}
+
+	client.On("CreateParentNodes", o.path()).Return(error(nil)).Once()
+	client.On("GetEntries", o.path()).Return(initialInstances, (<-chan zkclient.Event)(clientEvents), error(nil)).Once()
+	client.On("Stop").Once()
+
+	service, err := New(o)
+	require.NotNil(service)
+	require.NoError(err)
+
+	service.Register()
+	service.Deregister()
+
+	i, err := service.NewInstancer()
+	require.NotNil(i)
+	assert.NoError(err)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: initialInstances}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+
+	// need to do this to terminate the goroutine
+	i.Stop()
+
+	assert.NoError(service.Close())
+	assert.NoError(service.Close()) // idempotency
+
+	client.AssertExpectations(t)
+}
+
+func testZkFacadeClientFactoryError(t *testing.T) {
+	defer resetZkClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	zkClientFactory = func([]string, log.Logger, ...zk.Option) (zk.Client, error) {
+		return nil, expectedError
+	}
+
+	service, err := New(nil)
+	assert.Nil(service)
+	assert.Equal(expectedError, err)
+}
+
+// newTestZkRegistrar creates a zkRegistrar over a mock client, which reports lost and restored
+// registrations through the returned channels
+func newTestZkRegistrar(t *testing.T, client *mockClient, after chan time.Time) (*zkRegistrar, <-chan error, <-chan struct{}) {
+	var (
+		lost     = make(chan error, 2)
+		restored = make(chan struct{}, 2)
+	)
+
+	return &zkRegistrar{
+		logger: logging.NewTestLogger(nil, t),
+		client: client,
+		service: zk.Service{
+			Path: "/test",
+			Name: "test",
+			Data: []byte("localhost:8080"),
+		},
+		backoff:   backoff{initial: time.Second, max: time.Minute, multiplier: 2.0},
+		onBackoff: func(BackoffEvent) {},
+		after: func(d time.Duration) <-chan time.Time {
+			assert.Equal(t, time.Second, d)
+			return after
+		},
+		lost:     func(err error) { lost <- err },
+		restored: func() { restored <- struct{}{} },
+		attempts: generic.NewCounter("attempts"),
+		failures: generic.NewCounter("failures"),
+	}, lost, restored
+}
+
+func testZkRegistrarSessionExpired(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		client  = new(mockClient)
+		after   = make(chan time.Time, 1)
+
+		registrar, lost, restored = newTestZkRegistrar(t, client, after)
+		restoreError              = errors.New("expected")
+		backoffs                  = make(chan BackoffEvent, 1)
+	)
+
+	registrar.onBackoff = func(e BackoffEvent) { backoffs <- e }
+
+	client.On("Register", mock.AnythingOfType("*zk.Service")).Return(error(nil)).Once()
+	registrar.Register()
+	registrar.Register() // idempotency
+	client.AssertExpectations(t)
+
+	// a disconnection does not lose the registration
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateDisconnected})
+	assert.Zero(len(lost))
+
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateExpired})
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateExpired})
+	select {
+	case err := <-lost:
+		assert.Equal(zkclient.ErrSessionExpired, err)
+	case <-time.After(time.Second):
+		require.Fail("The lost callback was not called")
+	}
+
+	assert.Zero(len(lost))
+
+	client.On("Register", mock.AnythingOfType("*zk.Service")).Return(restoreError).Once()
+	client.On("Register", mock.AnythingOfType("*zk.Service")).Return(error(nil)).Once()
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateHasSession})
+
+	// the first attempt fails, so the registrar backs off and retries
+	after <- time.Now()
+	select {
+	case e := <-backoffs:
+		assert.Equal(BackoffEvent{Operation: BackoffRegister, Attempt: 1, Delay: time.Second, Err: restoreError}, e)
+	case <-time.After(time.Second):
+		require.Fail("The backoff callback was not called")
+	}
+
+	select {
+	case <-restored:
+		// passing
+	case <-time.After(time.Second):
+		require.Fail("The restored callback was not called")
+	}
+
+	client.On("Deregister", mock.AnythingOfType("*zk.Service")).Return(error(nil)).Once()
+	registrar.Deregister()
+	registrar.Deregister() // idempotency
+
+	assert.Equal(3.0, registrar.attempts.(*generic.Counter).Value())
+	assert.Equal(1.0, registrar.failures.(*generic.Counter).Value())
+
+	assert.Zero(len(restored))
+	client.AssertExpectations(t)
+}
+
+func testZkRegistrarDeregisterWhileExpired(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		client = new(mockClient)
+
+		registrar, lost, restored = newTestZkRegistrar(t, client, nil)
+	)
+
+	client.On("Register", mock.AnythingOfType("*zk.Service")).Return(error(nil)).Once()
+	registrar.Register()
+
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateExpired})
+	assert.Equal(zkclient.ErrSessionExpired, <-lost)
+
+	// the znode went away with the session, so there is nothing to delete
+	registrar.Deregister()
+	registrar.handleEvent(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateHasSession})
+
+	assert.Zero(len(restored))
+	client.AssertExpectations(t)
+}
+
+func TestZkEventHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		client  = new(mockClient)
+		session = new(mockCounter)
+		counted = generic.NewCounter("session")
+
+		registrar, lost, _ = newTestZkRegistrar(t, client, nil)
+	)
+
+	session.On("With", []string{StateLabel, zkclient.StateHasSession.String()}).Return(counted).Once()
+	session.On("With", []string{StateLabel, zkclient.StateExpired.String()}).Return(counted).Once()
+
+	// without a registration, events are only logged and counted
+	handler := newZkEventHandler(logging.NewTestLogger(nil, t), session, nil)
+	handler(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateHasSession})
+	assert.Equal(1.0, counted.Value())
+
+	client.On("Register", mock.AnythingOfType("*zk.Service")).Return(error(nil)).Once()
+	registrar.Register()
+
+	handler = newZkEventHandler(logging.NewTestLogger(nil, t), session, registrar)
+	handler(zkclient.Event{Type: zkclient.EventSession, State: zkclient.StateExpired})
+	assert.Equal(2.0, counted.Value())
+	assert.Equal(zkclient.ErrSessionExpired, <-lost)
+
+	session.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestZkRegistrar(t *testing.T) {
+	t.Run("SessionExpired", testZkRegistrarSessionExpired)
+	t.Run("DeregisterWhileExpired", testZkRegistrarDeregisterWhileExpired)
+}
+
+func testZkFacadeTarget(t *testing.T) {
+	defer resetZkClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		client  = new(mockClient)
+
+		clientEvents   = make(chan zkclient.Event, 1)
+		instanceEvents = make(chan sd.Event, 1)
+	)
+
+	zkClientFactory = func([]string, log.Logger, ...zk.Option) (zk.Client, error) {
+		return client, nil
+	}
+
+	client.On("CreateParentNodes", "/xmidt/prod").Return(error(nil)).Once()
+	client.On("GetEntries", "/xmidt/prod").Return([]string{"instance1"}, (<-chan zkclient.Event)(clientEvents), error(nil)).Once()
+	client.On("Stop").Once()
+
+	service, err := New(&Options{Path: "/xmidt/test"})
+	require.NotNil(service)
+	require.NoError(err)
+
+	i, err := service.NewTargetInstancer(WatchTarget{Path: "/xmidt/prod", ServiceName: "ignored"})
+	require.NotNil(i)
+	require.NoError(err)
+
+	i.Register(instanceEvents)
+	assert.Equal(sd.Event{Instances: []string{"instance1"}}, <-instanceEvents)
+	i.Deregister(instanceEvents)
+	i.Stop()
+
+	assert.NoError(service.Close())
+	client.AssertExpectations(t)
+}
+
+func testZkFacadeConnectBackoff(t *testing.T) {
+	defer resetZkClientFactory()
+	defer resetBackoffRandom()
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		client        = new(mockClient)
+		expectedError = errors.New("expected")
+		attempts      = 0
+		delays        []time.Duration
+		backoffs      []BackoffEvent
+	)
+
+	// no jitter is applied at the midpoint of the random range
+	backoffRandom = func() float64 { return 0.5 }
+
+	zkClientFactory = func([]string, log.Logger, ...zk.Option) (zk.Client, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, expectedError
+		}
+
+		return client, nil
+	}
+
+	client.On("Stop").Once()
+
+	service, err := New(&Options{
+		Backoff:   &BackoffOptions{Initial: 100 * time.Millisecond, ConnectAttempts: 3},
+		OnBackoff: func(e BackoffEvent) { backoffs = append(backoffs, e) },
+		After: func(d time.Duration) <-chan time.Time {
+			delays = append(delays, d)
+			c := make(chan time.Time, 1)
+			c <- time.Now()
+			return c
+		},
+	})
+
+	require.NotNil(service)
+	require.NoError(err)
+	assert.Equal(3, attempts)
+	assert.Equal([]time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, delays)
+	assert.Equal(
+		[]BackoffEvent{
+			{Operation: BackoffConnect, Attempt: 1, Delay: 100 * time.Millisecond, Err: expectedError},
+			{Operation: BackoffConnect, Attempt: 2, Delay: 200 * time.Millisecond, Err: expectedError},
+		},
+		backoffs,
+	)
+
+	assert.NoError(service.Close())
+	client.AssertExpectations(t)
+
+	// once the attempts are exhausted, the last error is returned
+	attempts = -10
+	delays = nil
+	service, err = New(&Options{
+		Backoff: &BackoffOptions{ConnectAttempts: 2},
+		After: func(d time.Duration) <-chan time.Time {
+			delays = append(delays, d)
+			c := make(chan time.Time, 1)
+			c <- time.Now()
+			return c
+		},
+	})
+
+	assert.Nil(service)
+	assert.Equal(expectedError, err)
+	assert.Equal(-8, attempts)
+	assert.Equal([]time.Duration{DefaultBackoffInitial}, delays)
+}
+
+func TestZkFacade(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) { testZkFacade(t, nil) })
+	t.Run("Default", func(t *testing.T) { testZkFacade(t, new(Options)) })
+	t.Run("Nontrivial", func(t *testing.T) {
+		testZkFacade(t, &Options{
+			Connection:   "host1:2181,host2:2181",
+			Path:         "/foo/bar",
+			ServiceName:  "testing",
+			Registration: "localhost:1400",
+		})
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		testZkFacade(t, &Options{
+			Registration: "http://localhost:1400",
+			Metadata:     Metadata{"version": "1.2.3", "datacenter": "east"},
+		})
+	})
+
+	t.Run("Target", testZkFacadeTarget)
+	t.Run("ClientFactoryError", testZkFacadeClientFactoryError)
+	t.Run("ConnectBackoff", testZkFacadeConnectBackoff)
+}
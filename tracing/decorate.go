@@ -0,0 +1,50 @@
+package tracing
+
+// Annotated can be implemented by Span objects that carry arbitrary, extra data in addition to the
+// standard span fields.  For example, an HTTP client may annotate a span with the response status code.
+type Annotated interface {
+	// Data returns the extra data associated with a span.  The returned map must not be modified.
+	Data() map[string]interface{}
+}
+
+// decoratedSpan is a Span which overrides the name of another span and carries extra data
+type decoratedSpan struct {
+	Span
+	name string
+	data map[string]interface{}
+}
+
+func (ds *decoratedSpan) Name() string {
+	return ds.name
+}
+
+func (ds *decoratedSpan) Data() map[string]interface{} {
+	return ds.data
+}
+
+// Decorate produces a Span with the same start, duration, and error as an existing span, but with the given
+// name and extra data.  If name is empty, the original span's name is used.  Any data the original span carries,
+// via the Annotated interface, is copied to the new span and then overlaid with the given data.  The original span is
+// not modified.
+func Decorate(s Span, name string, data map[string]interface{}) Span {
+	if len(name) == 0 {
+		name = s.Name()
+	}
+
+	merged := make(map[string]interface{}, len(data))
+	if a, ok := s.(Annotated); ok {
+		for k, v := range a.Data() {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range data {
+		merged[k] = v
+	}
+
+	return &decoratedSpan{
+		Span: s,
+		name: name,
+		data: merged,
+	}
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecorate(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		original      = NewSpanner().Start("original")(expectedError)
+	)
+
+	renamed := Decorate(original, "renamed", map[string]interface{}{"status": 503})
+	require.NotNil(renamed)
+	assert.Equal("renamed", renamed.Name())
+	assert.Equal(original.Start(), renamed.Start())
+	assert.Equal(original.Duration(), renamed.Duration())
+	assert.Equal(expectedError, renamed.Error())
+	assert.Equal("original", original.Name())
+
+	annotated, ok := renamed.(Annotated)
+	require.True(ok)
+	assert.Equal(map[string]interface{}{"status": 503}, annotated.Data())
+
+	redecorated := Decorate(renamed, "", map[string]interface{}{"retries": 2})
+	assert.Equal("renamed", redecorated.Name())
+	assert.Equal(map[string]interface{}{"status": 503, "retries": 2}, redecorated.(Annotated).Data())
+	assert.Equal(map[string]interface{}{"status": 503}, annotated.Data())
+
+	unnamed := Decorate(original, "", nil)
+	assert.Equal("original", unnamed.Name())
+	assert.Empty(unnamed.(Annotated).Data())
+}
@@ -0,0 +1,120 @@
+package wrp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// ContentEncodingKey is the metadata key which indicates the encoding applied to a message's payload.
+	// When absent, the payload is not encoded.
+	ContentEncodingKey = "content-encoding"
+
+	// AcceptEncodingKey is the metadata key a request uses to indicate the payload encodings it accepts
+	// in a response, as a comma-delimited list
+	AcceptEncodingKey = "accept-encoding"
+
+	// GzipEncoding is the content encoding for gzip-compressed payloads
+	GzipEncoding = "gzip"
+
+	// DefaultCompressionThreshold is the payload size, in bytes, above which payloads are compressed
+	// when no threshold is supplied
+	DefaultCompressionThreshold = 1024
+)
+
+// ErrUnsupportedContentEncoding indicates that a message's payload uses an encoding this package cannot decode
+var ErrUnsupportedContentEncoding = errors.New("Unsupported WRP payload content encoding")
+
+// PayloadEncoding returns the content encoding applied to this message's payload, or the empty string if
+// the payload is not encoded
+func (msg *Message) PayloadEncoding() string {
+	return msg.Metadata[ContentEncodingKey]
+}
+
+// AcceptsEncoding tests if the sender of this message accepts responses whose payloads have the given encoding
+func (msg *Message) AcceptsEncoding(encoding string) bool {
+	for _, accepted := range strings.Split(msg.Metadata[AcceptEncodingKey], ",") {
+		if strings.EqualFold(strings.TrimSpace(accepted), encoding) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetAcceptEncoding records the payload encodings that this message's sender accepts in a response
+func (msg *Message) SetAcceptEncoding(encodings ...string) *Message {
+	msg.setMetadata(AcceptEncodingKey, strings.Join(encodings, ","))
+	return msg
+}
+
+// CompressPayload gzip-compresses the message's payload if it is larger than threshold bytes, recording
+// the encoding in the message's metadata.  A payload that is already encoded is left as is.  If threshold
+// is nonpositive, DefaultCompressionThreshold is used.  This function returns true if the payload was compressed.
+func CompressPayload(m *Message, threshold int) (bool, error) {
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+
+	if len(m.Payload) <= threshold || len(m.PayloadEncoding()) > 0 {
+		return false, nil
+	}
+
+	var (
+		output bytes.Buffer
+		writer = gzip.NewWriter(&output)
+	)
+
+	if _, err := writer.Write(m.Payload); err != nil {
+		return false, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return false, err
+	}
+
+	m.Payload = output.Bytes()
+	m.setMetadata(ContentEncodingKey, GzipEncoding)
+	return true, nil
+}
+
+// DecompressPayload reverses CompressPayload, restoring the original payload and removing the encoding
+// from the message's metadata.  If the payload is not encoded, this function does nothing and returns false.
+// ErrUnsupportedContentEncoding is returned for any encoding other than GzipEncoding.
+func DecompressPayload(m *Message) (bool, error) {
+	switch m.PayloadEncoding() {
+	case "":
+		return false, nil
+
+	case GzipEncoding:
+		reader, err := gzip.NewReader(bytes.NewReader(m.Payload))
+		if err != nil {
+			return false, err
+		}
+
+		payload, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return false, err
+		}
+
+		m.Payload = payload
+		m.deleteMetadata(ContentEncodingKey)
+		return true, nil
+
+	default:
+		return false, ErrUnsupportedContentEncoding
+	}
+}
+
+// CompressResponse compresses a response's payload when the request that produced it accepts gzip
+// via its accept-encoding metadata.  This function returns true if the payload was compressed.
+func CompressResponse(request, response *Message, threshold int) (bool, error) {
+	if !request.AcceptsEncoding(GzipEncoding) {
+		return false, nil
+	}
+
+	return CompressPayload(response, threshold)
+}
@@ -0,0 +1,128 @@
+package wrp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageAcceptsEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(new(Message).AcceptsEncoding(GzipEncoding))
+	assert.True(new(Message).SetAcceptEncoding(GzipEncoding).AcceptsEncoding(GzipEncoding))
+	assert.True(new(Message).SetAcceptEncoding("br", "GZIP").AcceptsEncoding(GzipEncoding))
+	assert.True((&Message{Metadata: map[string]string{AcceptEncodingKey: "br, gzip"}}).AcceptsEncoding(GzipEncoding))
+	assert.False(new(Message).SetAcceptEncoding("br").AcceptsEncoding(GzipEncoding))
+}
+
+func testCompressPayloadRoundTrip(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		original = bytes.Repeat([]byte("TR-181 parameter data "), 500)
+		metadata = map[string]string{"/boot-time": "1234"}
+		message  = Message{
+			Type:     SimpleRequestResponseMessageType,
+			Payload:  original,
+			Metadata: metadata,
+		}
+
+		encoded []byte
+		decoded Message
+	)
+
+	compressed, err := CompressPayload(&message, 0)
+	require.NoError(err)
+	require.True(compressed)
+	assert.Equal(GzipEncoding, message.PayloadEncoding())
+	assert.True(len(message.Payload) < len(original))
+	assert.Equal(map[string]string{"/boot-time": "1234"}, metadata, "the original metadata should not be modified")
+
+	// compressing an already compressed payload does nothing
+	compressed, err = CompressPayload(&message, 0)
+	assert.False(compressed)
+	assert.NoError(err)
+
+	require.NoError(NewEncoderBytes(&encoded, f).Encode(&message))
+	require.NoError(NewDecoderBytes(encoded, f).Decode(&decoded))
+
+	decompressed, err := DecompressPayload(&decoded)
+	require.NoError(err)
+	require.True(decompressed)
+	assert.Equal(original, decoded.Payload)
+	assert.Empty(decoded.PayloadEncoding())
+	assert.Equal(metadata, decoded.Metadata)
+}
+
+func TestCompressPayload(t *testing.T) {
+	t.Run("BelowThreshold", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message = Message{Payload: []byte("small")}
+		)
+
+		compressed, err := CompressPayload(&message, 0)
+		assert.False(compressed)
+		assert.NoError(err)
+		assert.Equal([]byte("small"), message.Payload)
+		assert.Empty(message.Metadata)
+
+		compressed, err = CompressPayload(&message, 2)
+		assert.True(compressed)
+		assert.NoError(err)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, f := range AllFormats() {
+			t.Run(f.String(), func(t *testing.T) {
+				testCompressPayloadRoundTrip(t, f)
+			})
+		}
+	})
+}
+
+func TestDecompressPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	decompressed, err := DecompressPayload(&Message{Payload: []byte("plain")})
+	assert.False(decompressed)
+	assert.NoError(err)
+
+	decompressed, err = DecompressPayload(&Message{
+		Payload:  []byte("not gzip"),
+		Metadata: map[string]string{ContentEncodingKey: GzipEncoding},
+	})
+
+	assert.False(decompressed)
+	assert.Error(err)
+
+	decompressed, err = DecompressPayload(&Message{
+		Payload:  []byte("unknown"),
+		Metadata: map[string]string{ContentEncodingKey: "br"},
+	})
+
+	assert.False(decompressed)
+	assert.Equal(ErrUnsupportedContentEncoding, err)
+}
+
+func TestCompressResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		payload = bytes.Repeat([]byte("x"), DefaultCompressionThreshold+1)
+	)
+
+	response := &Message{Payload: payload}
+	compressed, err := CompressResponse(new(Message), response, 0)
+	assert.False(compressed)
+	assert.NoError(err)
+	assert.Empty(response.PayloadEncoding())
+
+	compressed, err = CompressResponse(new(Message).SetAcceptEncoding(GzipEncoding), response, 0)
+	assert.True(compressed)
+	assert.NoError(err)
+	assert.Equal(GzipEncoding, response.PayloadEncoding())
+}
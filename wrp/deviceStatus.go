@@ -0,0 +1,115 @@
+package wrp
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeviceStatusContentType is the content type of device status event payloads
+const DeviceStatusContentType = "application/json"
+
+// DeviceStatusPayload is the JSON payload of a device status event.  Online and fully-manageable events only carry
+// the device's identifier and the time of the event, while offline events also describe the connection that closed.
+type DeviceStatusPayload struct {
+	// ID is the canonical identifier of the device, e.g. "mac:112233445566"
+	ID string `json:"id"`
+
+	// Timestamp is the time at which the device's status changed
+	Timestamp time.Time `json:"ts"`
+
+	// BytesSent is the number of bytes sent to the device over its connection
+	BytesSent int64 `json:"bytes-sent,omitempty"`
+
+	// MessagesSent is the number of WRP messages sent to the device over its connection
+	MessagesSent int64 `json:"messages-sent,omitempty"`
+
+	// BytesReceived is the number of bytes received from the device over its connection
+	BytesReceived int64 `json:"bytes-received,omitempty"`
+
+	// MessagesReceived is the number of WRP messages received from the device over its connection
+	MessagesReceived int64 `json:"messages-received,omitempty"`
+
+	// ConnectedAt is the time at which the device connected
+	ConnectedAt *time.Time `json:"connected-at,omitempty"`
+
+	// UpTime is the length of time that the device was connected, e.g. "1h30m0s"
+	UpTime string `json:"up-time,omitempty"`
+
+	// ReasonForClosure describes why the device's connection closed
+	ReasonForClosure string `json:"reason-for-closure,omitempty"`
+}
+
+// NewDeviceStatusEvent produces a device status event message originating from the given source, e.g. "dns:talaria".
+// The payload's ID is canonicalized and is used, along with the status, to build the event destination.  The timestamps
+// in the payload are converted to UTC.  The metadata, which may be nil, is copied into the returned message.
+//
+// ErrInvalidDeviceStatusEvent is returned if the payload does not identify a device or the status is empty.
+func NewDeviceStatusEvent(source, status string, payload DeviceStatusPayload, metadata map[string]string) (*Message, error) {
+	device, err := ParseLocator(payload.ID)
+	if err != nil || !device.IsDevice() || len(status) == 0 {
+		return nil, ErrInvalidDeviceStatusEvent
+	}
+
+	payload.ID = device.ID()
+	payload.Timestamp = payload.Timestamp.UTC()
+	if payload.ConnectedAt != nil {
+		connectedAt := payload.ConnectedAt.UTC()
+		payload.ConnectedAt = &connectedAt
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &Message{
+		Type:        SimpleEventMessageType,
+		Source:      source,
+		Destination: DeviceStatusDestination(payload.ID, status),
+		ContentType: DeviceStatusContentType,
+		Payload:     encoded,
+	}
+
+	if len(metadata) > 0 {
+		message.Metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			message.Metadata[k] = v
+		}
+	}
+
+	return message, nil
+}
+
+// NewOnlineEvent produces the event emitted when a device connects
+func NewOnlineEvent(source, deviceID string, timestamp time.Time, metadata map[string]string) (*Message, error) {
+	return NewDeviceStatusEvent(source, OnlineStatus, DeviceStatusPayload{ID: deviceID, Timestamp: timestamp}, metadata)
+}
+
+// NewFullyManageableEvent produces the event emitted when a connected device is ready to receive requests
+func NewFullyManageableEvent(source, deviceID string, timestamp time.Time, metadata map[string]string) (*Message, error) {
+	return NewDeviceStatusEvent(source, FullyManageableStatus, DeviceStatusPayload{ID: deviceID, Timestamp: timestamp}, metadata)
+}
+
+// NewOfflineEvent produces the event emitted when a device disconnects.  The payload should describe the closed
+// connection in addition to identifying the device.
+func NewOfflineEvent(source string, payload DeviceStatusPayload, metadata map[string]string) (*Message, error) {
+	return NewDeviceStatusEvent(source, OfflineStatus, payload, metadata)
+}
+
+// DecodeDeviceStatusPayload parses the JSON payload of a device status event.  ErrInvalidDeviceStatusEvent is
+// returned if the message's destination is not a valid device status event.
+func DecodeDeviceStatusPayload(m *Message) (DeviceStatusPayload, error) {
+	var payload DeviceStatusPayload
+
+	ed, err := ParseEventDestination(m.Destination)
+	if err != nil {
+		return payload, ErrInvalidDeviceStatusEvent
+	}
+
+	if _, _, err := ed.DeviceStatus(); err != nil {
+		return payload, err
+	}
+
+	err = json.Unmarshal(m.Payload, &payload)
+	return payload, err
+}
@@ -0,0 +1,125 @@
+package wrp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDeviceStatusEventOnline(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		timestamp = time.Date(2018, 3, 4, 5, 6, 7, 0, time.FixedZone("test", 3600))
+		metadata  = map[string]string{"hw-model": "model"}
+	)
+
+	for status, constructor := range map[string]func(string, string, time.Time, map[string]string) (*Message, error){
+		OnlineStatus:          NewOnlineEvent,
+		FullyManageableStatus: NewFullyManageableEvent,
+	} {
+		t.Run(status, func(t *testing.T) {
+			message, err := constructor("dns:talaria", "MAC:11:22:33:AA:BB:CC", timestamp, metadata)
+			require.NoError(err)
+			require.NotNil(message)
+
+			assert.Equal(SimpleEventMessageType, message.Type)
+			assert.Equal("dns:talaria", message.Source)
+			assert.Equal("event:device-status/mac:112233aabbcc/"+status, message.Destination)
+			assert.Equal(DeviceStatusContentType, message.ContentType)
+			assert.Equal(metadata, message.Metadata)
+			assert.JSONEq(`{"id": "mac:112233aabbcc", "ts": "2018-03-04T04:06:07Z"}`, string(message.Payload))
+
+			// the metadata is copied
+			message.Metadata["hw-model"] = "changed"
+			assert.Equal("model", metadata["hw-model"])
+
+			payload, err := DecodeDeviceStatusPayload(message)
+			require.NoError(err)
+			assert.Equal("mac:112233aabbcc", payload.ID)
+			assert.True(timestamp.Equal(payload.Timestamp))
+		})
+	}
+}
+
+func testDeviceStatusEventOffline(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		timestamp   = time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+		connectedAt = timestamp.Add(-90 * time.Minute)
+	)
+
+	message, err := NewOfflineEvent(
+		"dns:talaria",
+		DeviceStatusPayload{
+			ID:               "mac:112233445566",
+			Timestamp:        timestamp,
+			BytesSent:        100,
+			MessagesSent:     2,
+			BytesReceived:    300,
+			MessagesReceived: 4,
+			ConnectedAt:      &connectedAt,
+			UpTime:           timestamp.Sub(connectedAt).String(),
+			ReasonForClosure: "ping miss",
+		},
+		nil,
+	)
+
+	require.NoError(err)
+	require.NotNil(message)
+	assert.Equal("event:device-status/mac:112233445566/offline", message.Destination)
+	assert.Nil(message.Metadata)
+	assert.JSONEq(
+		`{
+			"id": "mac:112233445566",
+			"ts": "2018-03-04T05:06:07Z",
+			"bytes-sent": 100,
+			"messages-sent": 2,
+			"bytes-received": 300,
+			"messages-received": 4,
+			"connected-at": "2018-03-04T03:36:07Z",
+			"up-time": "1h30m0s",
+			"reason-for-closure": "ping miss"
+		}`,
+		string(message.Payload),
+	)
+
+	payload, err := DecodeDeviceStatusPayload(message)
+	require.NoError(err)
+	assert.Equal("ping miss", payload.ReasonForClosure)
+	require.NotNil(payload.ConnectedAt)
+	assert.True(connectedAt.Equal(*payload.ConnectedAt))
+}
+
+func testDeviceStatusEventInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, payload := range []DeviceStatusPayload{{}, {ID: "event:iot"}, {ID: "mac:nonsense"}} {
+		message, err := NewOfflineEvent("dns:talaria", payload, nil)
+		assert.Nil(message)
+		assert.Equal(ErrInvalidDeviceStatusEvent, err)
+	}
+
+	message, err := NewDeviceStatusEvent("dns:talaria", "", DeviceStatusPayload{ID: "mac:112233445566"}, nil)
+	assert.Nil(message)
+	assert.Equal(ErrInvalidDeviceStatusEvent, err)
+
+	for _, destination := range []string{"", "event:iot/mac:112233445566/online", "mac:112233445566"} {
+		_, err = DecodeDeviceStatusPayload(&Message{Destination: destination, Payload: []byte(`{}`)})
+		assert.Equal(ErrInvalidDeviceStatusEvent, err)
+	}
+
+	_, err = DecodeDeviceStatusPayload(&Message{Destination: DeviceStatusDestination("mac:112233445566", OnlineStatus), Payload: []byte(`not json`)})
+	assert.Error(err)
+}
+
+func TestDeviceStatusEvent(t *testing.T) {
+	t.Run("Online", testDeviceStatusEventOnline)
+	t.Run("Offline", testDeviceStatusEventOffline)
+	t.Run("Invalid", testDeviceStatusEventInvalid)
+}
@@ -77,5 +77,27 @@ Some common uses of this package include:
 		return buffer.Bytes(), nil
 	}
 
+(5) Streaming large payloads without buffering them in memory:
+
+	func sendFile(output io.Writer, file *os.File, size int64) error {
+		encoder := NewStreamEncoder(output, Msgpack)
+		return encoder.Encode(&Message{Type: SimpleEventMessageType, Destination: "event:upload"}, file, size)
+	}
+
+	func receiveFile(input io.Reader, file *os.File) (*Message, error) {
+		var (
+			decoder = NewStreamDecoder(input, Msgpack)
+			header  = new(Message)
+		)
+
+		payload, err := decoder.Decode(header)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.Copy(file, payload)
+		return header, err
+	}
+
 */
 package wrp
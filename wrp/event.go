@@ -0,0 +1,141 @@
+package wrp
+
+import (
+	"errors"
+	"strings"
+)
+
+//go:generate stringer -type=EventClass
+
+const (
+	// DeviceStatusEventType is the event type for changes in a device's connection status.  Device status event
+	// destinations are of the form event:device-status/{device id}/{status}.
+	DeviceStatusEventType = "device-status"
+
+	// IOTEventType is the event type for events originated by IoT devices on behalf of their own components.
+	// IoT event destinations are of the form event:iot[/{path}].
+	IOTEventType = "iot"
+
+	// OnlineStatus is the device status emitted when a device connects
+	OnlineStatus = "online"
+
+	// OfflineStatus is the device status emitted when a device disconnects
+	OfflineStatus = "offline"
+
+	// FullyManageableStatus is the device status emitted when a connected device is ready to receive requests
+	FullyManageableStatus = "fully-manageable"
+)
+
+var (
+	// ErrNotAnEvent indicates a locator that does not use the event scheme
+	ErrNotAnEvent = errors.New("The WRP locator is not an event")
+
+	// ErrInvalidDeviceStatusEvent indicates a device status event destination that does not identify
+	// both a device and a status
+	ErrInvalidDeviceStatusEvent = errors.New("Invalid WRP device status event")
+)
+
+// EventClass categorizes events by their type, so that consumers can dispatch on the kind of event
+// without parsing destinations by hand
+type EventClass int
+
+const (
+	// UnknownEventClass is the class of any event whose type this package does not recognize
+	UnknownEventClass EventClass = iota
+
+	// DeviceStatusEventClass is the class of events with the DeviceStatusEventType
+	DeviceStatusEventClass
+
+	// IOTEventClass is the class of events with the IOTEventType
+	IOTEventClass
+)
+
+// EventDestination is a parsed WRP event destination, of the form event:{type}[/{path}].  For example,
+// "event:device-status/mac:112233445566/online" has the type "device-status" and the path "mac:112233445566/online".
+type EventDestination struct {
+	Type string
+	Path string
+}
+
+// NewEventDestination produces the text of an event destination with the given type.  Each nonempty path
+// segment is appended, separated by slashes.
+func NewEventDestination(eventType string, segments ...string) string {
+	destination := EventScheme + ":" + eventType
+	for _, segment := range segments {
+		if len(segment) > 0 {
+			destination += "/" + segment
+		}
+	}
+
+	return destination
+}
+
+// DeviceStatusDestination produces the destination of an event describing a device's status, e.g.
+// DeviceStatusDestination("mac:112233445566", OnlineStatus) returns "event:device-status/mac:112233445566/online"
+func DeviceStatusDestination(deviceID, status string) string {
+	return NewEventDestination(DeviceStatusEventType, deviceID, status)
+}
+
+// IOTDestination produces the destination of an IoT event, with an optional path
+func IOTDestination(path ...string) string {
+	return NewEventDestination(IOTEventType, path...)
+}
+
+// ParseEventDestination parses the destination of an event.  ErrNotAnEvent is returned if the destination
+// is a valid locator which does not use the event scheme.
+func ParseEventDestination(destination string) (EventDestination, error) {
+	l, err := ParseLocator(destination)
+	if err != nil {
+		return EventDestination{}, err
+	}
+
+	if l.IsDevice() {
+		return EventDestination{}, ErrNotAnEvent
+	}
+
+	return EventDestination{Type: l.Authority, Path: l.ServicePath()}, nil
+}
+
+// ClassifyEvent determines the class of the event with the given destination.  Destinations which are not
+// events, including invalid destinations, are of the UnknownEventClass.
+func ClassifyEvent(destination string) EventClass {
+	ed, err := ParseEventDestination(destination)
+	if err != nil {
+		return UnknownEventClass
+	}
+
+	return ed.Class()
+}
+
+// Class returns the EventClass corresponding to this destination's type
+func (ed EventDestination) Class() EventClass {
+	switch ed.Type {
+	case DeviceStatusEventType:
+		return DeviceStatusEventClass
+	case IOTEventType:
+		return IOTEventClass
+	default:
+		return UnknownEventClass
+	}
+}
+
+// DeviceStatus returns the canonical device identifier and the status described by a device status event.
+// The status is the remainder of the path after the device identifier, e.g. "online".
+func (ed EventDestination) DeviceStatus() (string, string, error) {
+	slash := strings.IndexByte(ed.Path, '/')
+	if ed.Class() != DeviceStatusEventClass || slash < 0 || slash == len(ed.Path)-1 {
+		return "", "", ErrInvalidDeviceStatusEvent
+	}
+
+	device, err := ParseLocator(ed.Path[:slash])
+	if err != nil || !device.IsDevice() {
+		return "", "", ErrInvalidDeviceStatusEvent
+	}
+
+	return device.ID(), ed.Path[slash+1:], nil
+}
+
+// String returns the text of this event destination
+func (ed EventDestination) String() string {
+	return NewEventDestination(ed.Type, ed.Path)
+}
@@ -0,0 +1,137 @@
+package wrp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventDestination(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("event:node-change", NewEventDestination("node-change"))
+	assert.Equal("event:node-change/a/b", NewEventDestination("node-change", "a", "", "b"))
+	assert.Equal("event:device-status/mac:112233445566/online", DeviceStatusDestination("mac:112233445566", OnlineStatus))
+	assert.Equal("event:device-status/mac:112233445566/fully-manageable", DeviceStatusDestination("mac:112233445566", FullyManageableStatus))
+	assert.Equal("event:iot", IOTDestination())
+	assert.Equal("event:iot/sensor/temperature", IOTDestination("sensor", "temperature"))
+}
+
+func testParseEventDestinationValid(t *testing.T) {
+	testData := []struct {
+		destination string
+		expected    EventDestination
+		class       EventClass
+	}{
+		{
+			"event:device-status/mac:112233445566/offline",
+			EventDestination{Type: DeviceStatusEventType, Path: "mac:112233445566/offline"},
+			DeviceStatusEventClass,
+		},
+		{
+			"EVENT:iot/sensor/",
+			EventDestination{Type: IOTEventType, Path: "sensor"},
+			IOTEventClass,
+		},
+		{
+			"event:iot",
+			EventDestination{Type: IOTEventType},
+			IOTEventClass,
+		},
+		{
+			"event:node-change/foo",
+			EventDestination{Type: "node-change", Path: "foo"},
+			UnknownEventClass,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.destination, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			actual, err := ParseEventDestination(record.destination)
+			require.NoError(err)
+			assert.Equal(record.expected, actual)
+			assert.Equal(record.class, actual.Class())
+			assert.Equal(record.class, ClassifyEvent(record.destination))
+
+			reparsed, err := ParseEventDestination(actual.String())
+			require.NoError(err)
+			assert.Equal(actual, reparsed)
+		})
+	}
+}
+
+func testParseEventDestinationInvalid(t *testing.T) {
+	testData := []struct {
+		destination string
+		expected    error
+	}{
+		{"", ErrInvalidLocator},
+		{"event:", ErrInvalidLocator},
+		{"mac:112233445566/config", ErrNotAnEvent},
+		{"nosuch:foo", ErrInvalidLocatorScheme},
+	}
+
+	for _, record := range testData {
+		t.Run(record.destination, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := ParseEventDestination(record.destination)
+			assert.Equal(EventDestination{}, actual)
+			assert.Equal(record.expected, err)
+			assert.Equal(UnknownEventClass, ClassifyEvent(record.destination))
+		})
+	}
+}
+
+func TestParseEventDestination(t *testing.T) {
+	t.Run("Valid", testParseEventDestinationValid)
+	t.Run("Invalid", testParseEventDestinationInvalid)
+}
+
+func TestEventDestinationDeviceStatus(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		ed, err := ParseEventDestination(DeviceStatusDestination("mac:11:22:33:AA:BB:CC", OnlineStatus))
+		require.NoError(err)
+
+		deviceID, status, err := ed.DeviceStatus()
+		assert.NoError(err)
+		assert.Equal("mac:112233aabbcc", deviceID)
+		assert.Equal(OnlineStatus, status)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, ed := range []EventDestination{
+			{Type: IOTEventType, Path: "mac:112233445566/online"},
+			{Type: DeviceStatusEventType},
+			{Type: DeviceStatusEventType, Path: "mac:112233445566"},
+			{Type: DeviceStatusEventType, Path: "mac:112233445566/"},
+			{Type: DeviceStatusEventType, Path: "mac:nonsense/online"},
+			{Type: DeviceStatusEventType, Path: "event:iot/online"},
+		} {
+			deviceID, status, err := ed.DeviceStatus()
+			assert.Empty(t, deviceID)
+			assert.Empty(t, status)
+			assert.Equal(t, ErrInvalidDeviceStatusEvent, err, ed.String())
+		}
+	})
+}
+
+func TestEventClassString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("UnknownEventClass", UnknownEventClass.String())
+	assert.Equal("DeviceStatusEventClass", DeviceStatusEventClass.String())
+	assert.Equal("IOTEventClass", IOTEventClass.String())
+	assert.Equal("EventClass(99)", EventClass(99).String())
+}
@@ -19,12 +19,14 @@ type Format int
 const (
 	Msgpack Format = iota
 	JSON
+	CBOR
+	Protobuf
 	lastFormat
 )
 
 // AllFormats returns a distinct slice of all supported formats.
 func AllFormats() []Format {
-	return []Format{Msgpack, JSON}
+	return []Format{Msgpack, JSON, CBOR, Protobuf}
 }
 
 var (
@@ -35,6 +37,14 @@ var (
 		IntegerAsString: 'L',
 	}
 
+	// cborHandle is the RFC 7049 configuration.  CBOR distinguishes byte strings from text strings,
+	// so the Payload field round trips without any extra configuration.
+	cborHandle = codec.CborHandle{
+		BasicHandle: codec.BasicHandle{
+			TypeInfos: codec.NewTypeInfos([]string{"wrp"}),
+		},
+	}
+
 	// msgpackHandle uses the configuration required for the updated msgpack spec.
 	// this is what's required to ensure that the Payload field is encoded and decoded properly.
 	// See: http://ugorji.net/blog/go-codec-primer#format-specific-runtime-configuration
@@ -45,6 +55,32 @@ var (
 			TypeInfos: codec.NewTypeInfos([]string{"wrp"}),
 		},
 	}
+
+	// the canonical handles are identical to the handles above, except that map keys are always written in sorted order.
+	// struct fields are always written in declaration order, so this is enough to make the output byte-stable.
+	canonicalJSONHandle = codec.JsonHandle{
+		BasicHandle: codec.BasicHandle{
+			TypeInfos:     codec.NewTypeInfos([]string{"wrp"}),
+			EncodeOptions: codec.EncodeOptions{Canonical: true},
+		},
+		IntegerAsString: 'L',
+	}
+
+	canonicalCBORHandle = codec.CborHandle{
+		BasicHandle: codec.BasicHandle{
+			TypeInfos:     codec.NewTypeInfos([]string{"wrp"}),
+			EncodeOptions: codec.EncodeOptions{Canonical: true},
+		},
+	}
+
+	canonicalMsgpackHandle = codec.MsgpackHandle{
+		WriteExt:    true,
+		RawToString: true,
+		BasicHandle: codec.BasicHandle{
+			TypeInfos:     codec.NewTypeInfos([]string{"wrp"}),
+			EncodeOptions: codec.EncodeOptions{Canonical: true},
+		},
+	}
 )
 
 // ContentType returns the MIME type associated with this format
@@ -54,6 +90,10 @@ func (f Format) ContentType() string {
 		return "application/msgpack"
 	case JSON:
 		return "application/json"
+	case CBOR:
+		return "application/cbor"
+	case Protobuf:
+		return "application/x-protobuf"
 	default:
 		return "application/octet-stream"
 	}
@@ -67,19 +107,40 @@ func FormatFromContentType(contentType string) (Format, error) {
 		return JSON, nil
 	} else if strings.Contains(contentType, "msgpack") {
 		return Msgpack, nil
+	} else if strings.Contains(contentType, "cbor") {
+		return CBOR, nil
+	} else if strings.Contains(contentType, "protobuf") {
+		return Protobuf, nil
 	}
 
 	return Format(-1), fmt.Errorf("Invalid WRP content type: %s", contentType)
 }
 
 // handle looks up the appropriate codec.Handle for this format constant.
-// This method panics if the format is not a valid value.
+// This method panics if the format is not a valid value.  Protobuf does not use
+// a codec.Handle, so this method panics for that format as well.
 func (f Format) handle() codec.Handle {
 	switch f {
 	case Msgpack:
 		return &msgpackHandle
 	case JSON:
 		return &jsonHandle
+	case CBOR:
+		return &cborHandle
+	}
+
+	panic(fmt.Errorf("Invalid format constant: %d", f))
+}
+
+// canonicalHandle is like handle, except that the returned codec.Handle produces canonical output
+func (f Format) canonicalHandle() codec.Handle {
+	switch f {
+	case Msgpack:
+		return &canonicalMsgpackHandle
+	case JSON:
+		return &canonicalJSONHandle
+	case CBOR:
+		return &canonicalCBORHandle
 	}
 
 	panic(fmt.Errorf("Invalid format constant: %d", f))
@@ -127,6 +188,10 @@ type Decoder interface {
 // NewEncoder produces a ugorji Encoder using the appropriate WRP configuration
 // for the given format
 func NewEncoder(output io.Writer, f Format) Encoder {
+	if f == Protobuf {
+		return &protobufEncoder{output: output}
+	}
+
 	return &encoderDecorator{
 		codec.NewEncoder(output, f.handle()),
 	}
@@ -135,20 +200,64 @@ func NewEncoder(output io.Writer, f Format) Encoder {
 // NewEncoderBytes produces a ugorji Encoder using the appropriate WRP configuration
 // for the given format
 func NewEncoderBytes(output *[]byte, f Format) Encoder {
+	if f == Protobuf {
+		return &protobufEncoder{outputBytes: output}
+	}
+
 	return &encoderDecorator{
 		codec.NewEncoderBytes(output, f.handle()),
 	}
 }
 
+// NewCanonicalEncoder produces an Encoder whose output is canonical: encoding equal values always produces
+// the same bytes, regardless of map iteration order.  This allows encoded messages to be hashed, signed, and
+// compared across services.  Canonical output is still valid for the format, and is decoded as usual.
+//
+// The Protobuf encoder always produces canonical output, so for that format this function is equivalent to NewEncoder.
+func NewCanonicalEncoder(output io.Writer, f Format) Encoder {
+	if f == Protobuf {
+		return &protobufEncoder{output: output}
+	}
+
+	return &encoderDecorator{
+		codec.NewEncoder(output, f.canonicalHandle()),
+	}
+}
+
+// NewCanonicalEncoderBytes is like NewCanonicalEncoder, except that the output is a byte slice
+func NewCanonicalEncoderBytes(output *[]byte, f Format) Encoder {
+	if f == Protobuf {
+		return &protobufEncoder{outputBytes: output}
+	}
+
+	return &encoderDecorator{
+		codec.NewEncoderBytes(output, f.canonicalHandle()),
+	}
+}
+
 // NewDecoder produces a ugorji Decoder using the appropriate WRP configuration
 // for the given format
 func NewDecoder(input io.Reader, f Format) Decoder {
+	switch f {
+	case Protobuf:
+		return &protobufDecoder{input: input}
+	case Msgpack:
+		return newMsgpackDecoder(input)
+	}
+
 	return codec.NewDecoder(input, f.handle())
 }
 
 // NewDecoderBytes produces a ugorji Decoder using the appropriate WRP configuration
 // for the given format
 func NewDecoderBytes(input []byte, f Format) Decoder {
+	switch f {
+	case Protobuf:
+		return &protobufDecoder{inputBytes: input}
+	case Msgpack:
+		return newMsgpackDecoderBytes(input)
+	}
+
 	return codec.NewDecoderBytes(input, f.handle())
 }
 
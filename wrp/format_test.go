@@ -147,7 +147,9 @@ func testFormatString(t *testing.T) {
 	assert.NotEmpty(JSON.String())
 	assert.NotEmpty(Msgpack.String())
 	assert.NotEmpty(Format(-1).String())
+	assert.NotEmpty(CBOR.String())
 	assert.NotEqual(JSON.String(), Msgpack.String())
+	assert.NotEqual(CBOR.String(), Msgpack.String())
 }
 
 func testFormatHandle(t *testing.T) {
@@ -155,6 +157,8 @@ func testFormatHandle(t *testing.T) {
 
 	assert.NotNil(JSON.handle())
 	assert.NotNil(Msgpack.handle())
+	assert.NotNil(CBOR.handle())
+	assert.Panics(func() { Protobuf.handle() })
 	assert.Panics(func() { Format(999).handle() })
 }
 
@@ -164,6 +168,8 @@ func testFormatContentType(t *testing.T) {
 	assert.NotEmpty(JSON.ContentType())
 	assert.NotEmpty(Msgpack.ContentType())
 	assert.NotEqual(JSON.ContentType(), Msgpack.ContentType())
+	assert.Equal("application/cbor", CBOR.ContentType())
+	assert.Equal("application/x-protobuf", Protobuf.ContentType())
 	assert.Equal("application/octet-stream", Format(999).ContentType())
 }
 
@@ -178,6 +184,8 @@ func testFormatFromContentType(t *testing.T) {
 			{"application/json", JSON, false},
 			{"application/json;charset=utf-8", JSON, false},
 			{"application/msgpack", Msgpack, false},
+			{"application/cbor", CBOR, false},
+			{"application/x-protobuf", Protobuf, false},
 			{"text/plain", Format(-1), true},
 		}
 	)
@@ -270,7 +278,7 @@ func testMustEncodePanic(t *testing.T, f Format) {
 }
 
 func TestMustEncode(t *testing.T) {
-	for _, f := range []Format{Msgpack, JSON} {
+	for _, f := range allFormats {
 		t.Run(f.String(), func(t *testing.T) {
 			t.Run("Valid", func(t *testing.T) { testMustEncodeValid(t, f) })
 			t.Run("Panic", func(t *testing.T) { testMustEncodePanic(t, f) })
@@ -335,3 +343,56 @@ func TestTranscodeMessage(t *testing.T) {
 		}
 	}
 }
+
+func testNewCanonicalEncoder(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expected []byte
+	)
+
+	for repeat := 0; repeat < 10; repeat++ {
+		// build equal metadata with a different insertion order each time
+		metadata := make(map[string]string)
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key-%02d", (i+repeat*7)%50)
+			metadata[key] = "value-" + key
+		}
+
+		var (
+			message = Message{
+				Type:            SimpleEventMessageType,
+				Source:          "test.com",
+				Destination:     "event:iot/test",
+				TransactionUUID: "canonical",
+				Metadata:        metadata,
+				Payload:         []byte("payload"),
+			}
+
+			output  bytes.Buffer
+			encoded []byte
+		)
+
+		require.NoError(NewCanonicalEncoder(&output, f).Encode(&message))
+		require.NoError(NewCanonicalEncoderBytes(&encoded, f).Encode(&message))
+		assert.Equal(output.Bytes(), encoded)
+
+		if expected == nil {
+			expected = encoded
+		} else {
+			assert.Equal(expected, encoded)
+		}
+
+		// canonical output is decoded as usual
+		var decoded Message
+		require.NoError(NewDecoderBytes(encoded, f).Decode(&decoded))
+		assert.Equal(message, decoded)
+	}
+}
+
+func TestNewCanonicalEncoder(t *testing.T) {
+	for _, f := range allFormats {
+		t.Run(f.String(), func(t *testing.T) { testNewCanonicalEncoder(t, f) })
+	}
+}
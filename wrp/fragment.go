@@ -0,0 +1,207 @@
+package wrp
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// FragmentIDKey is the metadata key holding the identifier shared by all fragments of a message
+	FragmentIDKey = "fragment-id"
+
+	// FragmentIndexKey is the metadata key holding the zero-based position of a fragment within its message
+	FragmentIndexKey = "fragment-index"
+
+	// FragmentCountKey is the metadata key holding the total number of fragments in a message
+	FragmentCountKey = "fragment-count"
+
+	// DefaultReassemblyTimeout is the time a Reassembler waits for the remaining fragments of a message
+	// when no timeout is configured
+	DefaultReassemblyTimeout time.Duration = time.Minute
+)
+
+var (
+	// ErrInvalidFragment indicates that a message's fragment metadata is missing or malformed
+	ErrInvalidFragment = errors.New("Invalid WRP fragment metadata")
+
+	// ErrFragmentMismatch indicates that a fragment disagrees with the other fragments of its message,
+	// e.g. a different fragment count or a duplicate index
+	ErrFragmentMismatch = errors.New("The WRP fragment does not match its message")
+)
+
+// IsFragment tests if this message is one fragment of a larger message
+func (msg *Message) IsFragment() bool {
+	_, ok := msg.Metadata[FragmentIDKey]
+	return ok
+}
+
+// Fragment splits a message whose payload exceeds maxPayloadSize bytes into a sequence of messages, each
+// carrying a portion of the payload along with metadata that allows a Reassembler to restore the original.
+// Every fragment is a copy of the original message, apart from its payload and metadata.  A message whose
+// payload fits within maxPayloadSize is returned as is, as the only element of the returned slice.
+//
+// If maxPayloadSize is nonpositive, this function panics.
+func Fragment(m *Message, maxPayloadSize int) ([]*Message, error) {
+	if maxPayloadSize < 1 {
+		panic("maxPayloadSize must be positive")
+	}
+
+	if len(m.Payload) <= maxPayloadSize {
+		return []*Message{m}, nil
+	}
+
+	fragmentID, err := NewTransactionUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		count     = (len(m.Payload) + maxPayloadSize - 1) / maxPayloadSize
+		fragments = make([]*Message, count)
+	)
+
+	for i := 0; i < count; i++ {
+		var (
+			fragment = *m
+			end      = (i + 1) * maxPayloadSize
+		)
+
+		if end > len(m.Payload) {
+			end = len(m.Payload)
+		}
+
+		fragment.Payload = m.Payload[i*maxPayloadSize : end]
+		fragment.setMetadata(FragmentIDKey, fragmentID)
+		fragment.setMetadata(FragmentIndexKey, strconv.Itoa(i))
+		fragment.setMetadata(FragmentCountKey, strconv.Itoa(count))
+		fragments[i] = &fragment
+	}
+
+	return fragments, nil
+}
+
+// fragmentInfo extracts and validates the fragment metadata from a message
+func fragmentInfo(m *Message) (id string, index, count int, err error) {
+	id = m.Metadata[FragmentIDKey]
+	if len(id) == 0 {
+		err = ErrInvalidFragment
+		return
+	}
+
+	if count, err = strconv.Atoi(m.Metadata[FragmentCountKey]); err != nil || count < 1 {
+		err = ErrInvalidFragment
+		return
+	}
+
+	if index, err = strconv.Atoi(m.Metadata[FragmentIndexKey]); err != nil || index < 0 || index >= count {
+		err = ErrInvalidFragment
+	}
+
+	return
+}
+
+// pendingMessage is a message whose fragments are still arriving
+type pendingMessage struct {
+	fragments []*Message
+	received  int
+	timer     *time.Timer
+}
+
+// Reassembler collects fragments produced by Fragment and restores the original messages.  Fragments may
+// arrive in any order.  A message whose fragments do not all arrive within the Reassembler's timeout is
+// discarded.  Instances are safe for concurrent access.
+type Reassembler struct {
+	lock    sync.Mutex
+	pending map[string]*pendingMessage
+	timeout time.Duration
+}
+
+// NewReassembler creates a Reassembler that discards incomplete messages after the given timeout.  If timeout
+// is nonpositive, DefaultReassemblyTimeout is used.
+func NewReassembler(timeout time.Duration) *Reassembler {
+	if timeout <= 0 {
+		timeout = DefaultReassemblyTimeout
+	}
+
+	return &Reassembler{
+		pending: make(map[string]*pendingMessage),
+		timeout: timeout,
+	}
+}
+
+// Len returns the number of messages with outstanding fragments
+func (r *Reassembler) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.pending)
+}
+
+// Add accepts a fragment.  When the fragment completes its message, the reassembled message is returned.
+// Otherwise, this method returns nil until the remaining fragments arrive.  A message that is not a fragment
+// is returned as is.
+func (r *Reassembler) Add(m *Message) (*Message, error) {
+	if !m.IsFragment() {
+		return m, nil
+	}
+
+	id, index, count, err := fragmentInfo(m)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	pm, ok := r.pending[id]
+	if !ok {
+		pm = &pendingMessage{fragments: make([]*Message, count)}
+		pm.timer = time.AfterFunc(r.timeout, func() {
+			r.remove(id, pm)
+		})
+
+		r.pending[id] = pm
+	} else if len(pm.fragments) != count || pm.fragments[index] != nil {
+		return nil, ErrFragmentMismatch
+	}
+
+	pm.fragments[index] = m
+	pm.received++
+	if pm.received < count {
+		return nil, nil
+	}
+
+	delete(r.pending, id)
+	pm.timer.Stop()
+	return reassemble(pm.fragments), nil
+}
+
+// remove discards a pending message, if it is still registered with this Reassembler
+func (r *Reassembler) remove(id string, pm *pendingMessage) {
+	r.lock.Lock()
+	if r.pending[id] == pm {
+		delete(r.pending, id)
+	}
+
+	r.lock.Unlock()
+}
+
+// reassemble produces the original message from a complete, ordered set of fragments
+func reassemble(fragments []*Message) *Message {
+	size := 0
+	for _, f := range fragments {
+		size += len(f.Payload)
+	}
+
+	message := *fragments[0]
+	message.Payload = make([]byte, 0, size)
+	for _, f := range fragments {
+		message.Payload = append(message.Payload, f.Payload...)
+	}
+
+	message.deleteMetadata(FragmentIDKey)
+	message.deleteMetadata(FragmentIndexKey)
+	message.deleteMetadata(FragmentCountKey)
+	return &message
+}
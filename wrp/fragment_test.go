@@ -0,0 +1,196 @@
+package wrp
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFragment(t *testing.T) {
+	t.Run("SmallPayload", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			message = &Message{Payload: []byte("small")}
+		)
+
+		fragments, err := Fragment(message, 5)
+		require.NoError(err)
+		require.Len(fragments, 1)
+		assert.True(message == fragments[0])
+		assert.False(fragments[0].IsFragment())
+	})
+
+	t.Run("LargePayload", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			metadata = map[string]string{"/boot-time": "1234"}
+			message  = &Message{
+				Type:        SimpleEventMessageType,
+				Source:      "mac:112233445566",
+				Destination: "event:device-status",
+				Payload:     []byte("0123456789abcdefghij+"),
+				Metadata:    metadata,
+			}
+		)
+
+		fragments, err := Fragment(message, 10)
+		require.NoError(err)
+		require.Len(fragments, 3)
+		assert.Len(metadata, 1, "the original metadata should not be modified")
+
+		fragmentID := fragments[0].Metadata[FragmentIDKey]
+		assert.NotEmpty(fragmentID)
+		for i, expectedPayload := range []string{"0123456789", "abcdefghij", "+"} {
+			f := fragments[i]
+			assert.True(f.IsFragment())
+			assert.Equal(expectedPayload, string(f.Payload))
+			assert.Equal(message.Source, f.Source)
+			assert.Equal(message.Destination, f.Destination)
+			assert.Equal("1234", f.Metadata["/boot-time"])
+			assert.Equal(fragmentID, f.Metadata[FragmentIDKey])
+			assert.Equal(strconv.Itoa(i), f.Metadata[FragmentIndexKey])
+			assert.Equal("3", f.Metadata[FragmentCountKey])
+		}
+	})
+
+	t.Run("InvalidSize", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Fragment(new(Message), 0)
+		})
+	})
+}
+
+func testReassemblerOrder(t *testing.T, order []int) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		reassembler = NewReassembler(time.Hour)
+		original    = &Message{
+			Type:     SimpleRequestResponseMessageType,
+			Source:   "test",
+			Payload:  bytes.Repeat([]byte("abcdefghijklmnopqrstuvwxyz"), 10),
+			Metadata: map[string]string{"/boot-time": "1234"},
+		}
+	)
+
+	fragments, err := Fragment(original, 64)
+	require.NoError(err)
+	require.Len(fragments, len(order))
+
+	var reassembled *Message
+	for i, index := range order {
+		reassembled, err = reassembler.Add(fragments[index])
+		require.NoError(err)
+		if i < len(order)-1 {
+			assert.Nil(reassembled)
+			assert.Equal(1, reassembler.Len())
+		}
+	}
+
+	require.NotNil(reassembled)
+	assert.Equal(*original, *reassembled)
+	assert.Zero(reassembler.Len())
+}
+
+func testReassemblerNotFragment(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		message = &Message{Payload: []byte("not a fragment")}
+	)
+
+	reassembled, err := NewReassembler(0).Add(message)
+	assert.True(message == reassembled)
+	assert.NoError(err)
+}
+
+func testReassemblerInvalid(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		reassembler = NewReassembler(time.Hour)
+	)
+
+	for _, metadata := range []map[string]string{
+		{FragmentIDKey: ""},
+		{FragmentIDKey: "1", FragmentIndexKey: "0"},
+		{FragmentIDKey: "1", FragmentIndexKey: "0", FragmentCountKey: "0"},
+		{FragmentIDKey: "1", FragmentIndexKey: "x", FragmentCountKey: "2"},
+		{FragmentIDKey: "1", FragmentIndexKey: "2", FragmentCountKey: "2"},
+		{FragmentIDKey: "1", FragmentIndexKey: "-1", FragmentCountKey: "2"},
+	} {
+		reassembled, err := reassembler.Add(&Message{Metadata: metadata})
+		assert.Nil(reassembled)
+		assert.Equal(ErrInvalidFragment, err)
+	}
+
+	assert.Zero(reassembler.Len())
+}
+
+func testReassemblerMismatch(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		reassembler = NewReassembler(time.Hour)
+		first       = &Message{Metadata: map[string]string{FragmentIDKey: "1", FragmentIndexKey: "0", FragmentCountKey: "2"}}
+	)
+
+	reassembled, err := reassembler.Add(first)
+	require.NoError(err)
+	require.Nil(reassembled)
+
+	reassembled, err = reassembler.Add(first)
+	assert.Nil(reassembled)
+	assert.Equal(ErrFragmentMismatch, err)
+
+	reassembled, err = reassembler.Add(&Message{Metadata: map[string]string{FragmentIDKey: "1", FragmentIndexKey: "1", FragmentCountKey: "3"}})
+	assert.Nil(reassembled)
+	assert.Equal(ErrFragmentMismatch, err)
+	assert.Equal(1, reassembler.Len())
+}
+
+func testReassemblerExpiry(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		reassembler = NewReassembler(10 * time.Millisecond)
+	)
+
+	fragments, err := Fragment(&Message{Payload: []byte("0123456789")}, 5)
+	require.NoError(err)
+
+	reassembled, err := reassembler.Add(fragments[0])
+	require.NoError(err)
+	require.Nil(reassembled)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for reassembler.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Zero(reassembler.Len())
+
+	// the remaining fragment starts a new, incomplete message
+	reassembled, err = reassembler.Add(fragments[1])
+	assert.Nil(reassembled)
+	assert.NoError(err)
+}
+
+func TestReassembler(t *testing.T) {
+	t.Run("InOrder", func(t *testing.T) {
+		testReassemblerOrder(t, []int{0, 1, 2, 3, 4})
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		testReassemblerOrder(t, []int{3, 0, 4, 2, 1})
+	})
+
+	t.Run("NotFragment", testReassemblerNotFragment)
+	t.Run("Invalid", testReassemblerInvalid)
+	t.Run("Mismatch", testReassemblerMismatch)
+	t.Run("Expiry", testReassemblerExpiry)
+}
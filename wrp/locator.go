@@ -0,0 +1,152 @@
+package wrp
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	MACScheme    = "mac"
+	UUIDScheme   = "uuid"
+	DNSScheme    = "dns"
+	SerialScheme = "serial"
+	EventScheme  = "event"
+
+	hexDigits     = "0123456789abcdefABCDEF"
+	macDelimiters = ":-.,"
+	macLength     = 12
+)
+
+var (
+	// ErrInvalidLocator indicates a locator that is not of the form scheme:authority[/service[/path]]
+	ErrInvalidLocator = errors.New("Invalid WRP locator")
+
+	// ErrInvalidLocatorScheme indicates a locator whose scheme is not one of the WRP schemes
+	ErrInvalidLocatorScheme = errors.New("Invalid WRP locator scheme")
+
+	// ErrInvalidMAC indicates a mac locator whose authority is not a 48-bit MAC address
+	ErrInvalidMAC = errors.New("Invalid MAC address in WRP locator")
+)
+
+// Locator is a parsed WRP source or destination, of the form scheme:authority[/service[/path]].  For example,
+// "mac:112233445566/config/wifi/ssid" has the scheme "mac", the authority "112233445566", the service "config",
+// and the path "wifi/ssid".  For device schemes, the authority is the device identifier.  For the event scheme,
+// it is the event name.
+type Locator struct {
+	Scheme    string
+	Authority string
+	Service   string
+	Path      string
+}
+
+// ParseLocator parses and canonicalizes a WRP locator.  The scheme is lowercased and must be one of the WRP schemes.
+// MAC addresses are lowercased with any delimiters removed, and dns and uuid authorities are lowercased, so that equivalent
+// locators produce equal Locator values.  Empty path segments, such as those produced by trailing slashes, are removed.
+func ParseLocator(locator string) (Locator, error) {
+	colon := strings.IndexByte(locator, ':')
+	if colon < 1 {
+		return Locator{}, ErrInvalidLocator
+	}
+
+	var (
+		l         = Locator{Scheme: strings.ToLower(locator[:colon])}
+		remainder = locator[colon+1:]
+	)
+
+	switch l.Scheme {
+	case MACScheme, UUIDScheme, DNSScheme, SerialScheme, EventScheme:
+	default:
+		return Locator{}, ErrInvalidLocatorScheme
+	}
+
+	if slash := strings.IndexByte(remainder, '/'); slash >= 0 {
+		l.Authority, remainder = remainder[:slash], remainder[slash+1:]
+	} else {
+		l.Authority, remainder = remainder, ""
+	}
+
+	if len(l.Authority) == 0 {
+		return Locator{}, ErrInvalidLocator
+	}
+
+	switch l.Scheme {
+	case MACScheme:
+		authority, err := canonicalMAC(l.Authority)
+		if err != nil {
+			return Locator{}, err
+		}
+
+		l.Authority = authority
+
+	case UUIDScheme, DNSScheme:
+		l.Authority = strings.ToLower(l.Authority)
+	}
+
+	segments := make([]string, 0, strings.Count(remainder, "/")+1)
+	for _, segment := range strings.Split(remainder, "/") {
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) > 0 {
+		l.Service = segments[0]
+		l.Path = strings.Join(segments[1:], "/")
+	}
+
+	return l, nil
+}
+
+// canonicalMAC removes delimiters from a MAC address and lowercases its hexadecimal digits
+func canonicalMAC(value string) (string, error) {
+	invalid := false
+	canonical := strings.Map(
+		func(r rune) rune {
+			switch {
+			case strings.ContainsRune(hexDigits, r):
+				return unicode.ToLower(r)
+			case strings.ContainsRune(macDelimiters, r):
+				return -1
+			default:
+				invalid = true
+				return -1
+			}
+		},
+		value,
+	)
+
+	if invalid || len(canonical) != macLength {
+		return "", ErrInvalidMAC
+	}
+
+	return canonical, nil
+}
+
+// ID returns the scheme and authority of this locator, e.g. "mac:112233445566", which identifies a device for the device schemes
+func (l Locator) ID() string {
+	return l.Scheme + ":" + l.Authority
+}
+
+// ServicePath returns the service and path of this locator, joined by a slash, e.g. "config/wifi/ssid"
+func (l Locator) ServicePath() string {
+	if len(l.Path) > 0 {
+		return l.Service + "/" + l.Path
+	}
+
+	return l.Service
+}
+
+// IsDevice tests if this locator identifies a device, as opposed to an event
+func (l Locator) IsDevice() bool {
+	return l.Scheme != EventScheme
+}
+
+// String returns the canonical text of this locator
+func (l Locator) String() string {
+	if len(l.Service) > 0 {
+		return l.ID() + "/" + l.ServicePath()
+	}
+
+	return l.ID()
+}
@@ -0,0 +1,104 @@
+package wrp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testParseLocatorValid(t *testing.T) {
+	testData := []struct {
+		locator     string
+		expected    Locator
+		canonical   string
+		servicePath string
+		isDevice    bool
+	}{
+		{
+			"mac:112233445566",
+			Locator{Scheme: MACScheme, Authority: "112233445566"},
+			"mac:112233445566", "", true,
+		},
+		{
+			"MAC:11:22:33:AA:BB:CC/config",
+			Locator{Scheme: MACScheme, Authority: "112233aabbcc", Service: "config"},
+			"mac:112233aabbcc/config", "config", true,
+		},
+		{
+			"mac:11-22-33-44-55-66/config/wifi/ssid/",
+			Locator{Scheme: MACScheme, Authority: "112233445566", Service: "config", Path: "wifi/ssid"},
+			"mac:112233445566/config/wifi/ssid", "config/wifi/ssid", true,
+		},
+		{
+			"uuid:ABCD-1234//iot",
+			Locator{Scheme: UUIDScheme, Authority: "abcd-1234", Service: "iot"},
+			"uuid:abcd-1234/iot", "iot", true,
+		},
+		{
+			"dns:Talaria.Comcast.NET/config",
+			Locator{Scheme: DNSScheme, Authority: "talaria.comcast.net", Service: "config"},
+			"dns:talaria.comcast.net/config", "config", true,
+		},
+		{
+			"serial:ABC123/",
+			Locator{Scheme: SerialScheme, Authority: "ABC123"},
+			"serial:ABC123", "", true,
+		},
+		{
+			"event:device-status/mac:112233445566/online",
+			Locator{Scheme: EventScheme, Authority: "device-status", Service: "mac:112233445566", Path: "online"},
+			"event:device-status/mac:112233445566/online", "mac:112233445566/online", false,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.locator, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := ParseLocator(record.locator)
+			assert.NoError(err)
+			assert.Equal(record.expected, actual)
+			assert.Equal(record.canonical, actual.String())
+			assert.Equal(record.servicePath, actual.ServicePath())
+			assert.Equal(record.expected.Scheme+":"+record.expected.Authority, actual.ID())
+			assert.Equal(record.isDevice, actual.IsDevice())
+
+			// the canonical form is a fixed point
+			reparsed, err := ParseLocator(actual.String())
+			assert.NoError(err)
+			assert.Equal(actual, reparsed)
+		})
+	}
+}
+
+func testParseLocatorInvalid(t *testing.T) {
+	testData := []struct {
+		locator  string
+		expected error
+	}{
+		{"", ErrInvalidLocator},
+		{"112233445566", ErrInvalidLocator},
+		{":112233445566", ErrInvalidLocator},
+		{"mac:", ErrInvalidLocator},
+		{"mac:/config", ErrInvalidLocator},
+		{"http://somewhere.comcast.net", ErrInvalidLocatorScheme},
+		{"mac:1122334455", ErrInvalidMAC},
+		{"mac:11223344556677", ErrInvalidMAC},
+		{"mac:11223344556G", ErrInvalidMAC},
+	}
+
+	for _, record := range testData {
+		t.Run(record.locator, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := ParseLocator(record.locator)
+			assert.Equal(Locator{}, actual)
+			assert.Equal(record.expected, err)
+		})
+	}
+}
+
+func TestParseLocator(t *testing.T) {
+	t.Run("Valid", testParseLocatorValid)
+	t.Run("Invalid", testParseLocatorInvalid)
+}
@@ -2,6 +2,27 @@ package wrp
 
 //go:generate codecgen -st "wrp" -o messages_codec.go messages.go
 
+// The WRP field names, which are the names used in the wrp struct tags and therefore in every encoded format
+const (
+	MsgTypeField                 = "msg_type"
+	SourceField                  = "source"
+	DestinationField             = "dest"
+	TransactionUUIDField         = "transaction_uuid"
+	ContentTypeField             = "content_type"
+	AcceptField                  = "accept"
+	StatusField                  = "status"
+	RequestDeliveryResponseField = "rdr"
+	HeadersField                 = "headers"
+	MetadataField                = "metadata"
+	SpansField                   = "spans"
+	IncludeSpansField            = "include_spans"
+	PathField                    = "path"
+	PayloadField                 = "payload"
+	ServiceNameField             = "service_name"
+	URLField                     = "url"
+	QualityOfServiceField        = "qos"
+)
+
 // Typed is implemented by any WRP type which is associated with a MessageType.  All
 // message types implement this interface.
 type Typed interface {
@@ -79,6 +100,7 @@ type Message struct {
 	Payload                 []byte            `wrp:"payload,omitempty"`
 	ServiceName             string            `wrp:"service_name,omitempty"`
 	URL                     string            `wrp:"url,omitempty"`
+	QualityOfService        QOSValue          `wrp:"qos,omitempty"`
 }
 
 func (msg *Message) MessageType() MessageType {
@@ -129,6 +151,32 @@ func (msg *Message) SetIncludeSpans(value bool) *Message {
 	return msg
 }
 
+// Reset clears this message so that it can be reused, e.g. via a MessagePool.  The Headers, Spans, and Payload
+// slices and the Metadata map are emptied but retain their storage, which the Msgpack decoder reuses when decoding
+// onto this message.  A message that has been reset encodes exactly as the zero value of Message.
+//
+// Since the storage is retained, this method must not be used on a message that shares its slices or maps with
+// other messages, such as a shallow copy.
+func (msg *Message) Reset() {
+	var (
+		headers  = msg.Headers[:0]
+		metadata = msg.Metadata
+		spans    = msg.Spans[:0]
+		payload  = msg.Payload[:0]
+	)
+
+	for k := range metadata {
+		delete(metadata, k)
+	}
+
+	*msg = Message{
+		Headers:  headers,
+		Metadata: metadata,
+		Spans:    spans,
+		Payload:  payload,
+	}
+}
+
 // AuthorizationStatus represents a WRP message of type AuthMessageType.
 //
 // https://github.com/Comcast/wrp-c/wiki/Web-Routing-Protocol#authorization-status-definition
@@ -167,6 +215,7 @@ type SimpleRequestResponse struct {
 	Spans                   [][]string        `wrp:"spans,omitempty"`
 	IncludeSpans            *bool             `wrp:"include_spans,omitempty"`
 	Payload                 []byte            `wrp:"payload,omitempty"`
+	QualityOfService        QOSValue          `wrp:"qos,omitempty"`
 }
 
 // SetStatus simplifies setting the optional Status field, which is a pointer type tagged with omitempty.
@@ -232,13 +281,14 @@ func (msg *SimpleRequestResponse) Response(newSource string, requestDeliveryResp
 type SimpleEvent struct {
 	// Type is exposed principally for encoding.  This field *must* be set to SimpleEventMessageType,
 	// and is automatically set by the BeforeEncode method.
-	Type        MessageType       `wrp:"msg_type"`
-	Source      string            `wrp:"source"`
-	Destination string            `wrp:"dest"`
-	ContentType string            `wrp:"content_type,omitempty"`
-	Headers     []string          `wrp:"headers,omitempty"`
-	Metadata    map[string]string `wrp:"metadata,omitempty"`
-	Payload     []byte            `wrp:"payload,omitempty"`
+	Type             MessageType       `wrp:"msg_type"`
+	Source           string            `wrp:"source"`
+	Destination      string            `wrp:"dest"`
+	ContentType      string            `wrp:"content_type,omitempty"`
+	Headers          []string          `wrp:"headers,omitempty"`
+	Metadata         map[string]string `wrp:"metadata,omitempty"`
+	Payload          []byte            `wrp:"payload,omitempty"`
+	QualityOfService QOSValue          `wrp:"qos,omitempty"`
 }
 
 func (msg *SimpleEvent) BeforeEncode() error {
@@ -294,6 +344,7 @@ type CRUD struct {
 	RequestDeliveryResponse *int64            `wrp:"rdr,omitempty"`
 	Path                    string            `wrp:"path"`
 	Payload                 []byte            `wrp:"payload,omitempty"`
+	QualityOfService        QOSValue          `wrp:"qos,omitempty"`
 }
 
 // SetStatus simplifies setting the optional Status field, which is a pointer type tagged with omitempty.
@@ -343,6 +394,86 @@ func (msg *CRUD) Response(newSource string, requestDeliveryResponse int64) Routa
 	return &response
 }
 
+// Create represents a WRP message of type CreateMessageType.  Unlike CRUD, this type implements BeforeEncode,
+// which sets the Type field automatically.  The CRUD fields are embedded and encoded as if declared here.
+type Create struct {
+	CRUD
+}
+
+func (msg *Create) BeforeEncode() error {
+	msg.Type = CreateMessageType
+	return nil
+}
+
+func (msg *Create) Response(newSource string, requestDeliveryResponse int64) Routable {
+	response := *msg
+	response.Destination = msg.Source
+	response.Source = newSource
+	response.RequestDeliveryResponse = &requestDeliveryResponse
+
+	return &response
+}
+
+// Retrieve represents a WRP message of type RetrieveMessageType.  Unlike CRUD, this type implements BeforeEncode,
+// which sets the Type field automatically.  The CRUD fields are embedded and encoded as if declared here.
+type Retrieve struct {
+	CRUD
+}
+
+func (msg *Retrieve) BeforeEncode() error {
+	msg.Type = RetrieveMessageType
+	return nil
+}
+
+func (msg *Retrieve) Response(newSource string, requestDeliveryResponse int64) Routable {
+	response := *msg
+	response.Destination = msg.Source
+	response.Source = newSource
+	response.RequestDeliveryResponse = &requestDeliveryResponse
+
+	return &response
+}
+
+// Update represents a WRP message of type UpdateMessageType.  Unlike CRUD, this type implements BeforeEncode,
+// which sets the Type field automatically.  The CRUD fields are embedded and encoded as if declared here.
+type Update struct {
+	CRUD
+}
+
+func (msg *Update) BeforeEncode() error {
+	msg.Type = UpdateMessageType
+	return nil
+}
+
+func (msg *Update) Response(newSource string, requestDeliveryResponse int64) Routable {
+	response := *msg
+	response.Destination = msg.Source
+	response.Source = newSource
+	response.RequestDeliveryResponse = &requestDeliveryResponse
+
+	return &response
+}
+
+// Delete represents a WRP message of type DeleteMessageType.  Unlike CRUD, this type implements BeforeEncode,
+// which sets the Type field automatically.  The CRUD fields are embedded and encoded as if declared here.
+type Delete struct {
+	CRUD
+}
+
+func (msg *Delete) BeforeEncode() error {
+	msg.Type = DeleteMessageType
+	return nil
+}
+
+func (msg *Delete) Response(newSource string, requestDeliveryResponse int64) Routable {
+	response := *msg
+	response.Destination = msg.Source
+	response.Source = newSource
+	response.RequestDeliveryResponse = &requestDeliveryResponse
+
+	return &response
+}
+
 // ServiceRegistration represents a WRP message of type ServiceRegistrationMessageType.
 //
 // https://github.com/Comcast/wrp-c/wiki/Web-Routing-Protocol#on-device-service-registration-message-definition
@@ -359,6 +490,10 @@ func (msg *ServiceRegistration) BeforeEncode() error {
 	return nil
 }
 
+func (msg *ServiceRegistration) MessageType() MessageType {
+	return msg.Type
+}
+
 // ServiceAlive represents a WRP message of type ServiceAliveMessageType.
 //
 // https://github.com/Comcast/wrp-c/wiki/Web-Routing-Protocol#on-device-service-alive-message-definition
@@ -372,3 +507,26 @@ func (msg *ServiceAlive) BeforeEncode() error {
 	msg.Type = ServiceAliveMessageType
 	return nil
 }
+
+func (msg *ServiceAlive) MessageType() MessageType {
+	return msg.Type
+}
+
+// Unknown represents a WRP message of type UnknownMessageType, which is sent in reply to a message
+// whose msg_type the recipient does not recognize.
+//
+// https://github.com/Comcast/wrp-c/wiki/Web-Routing-Protocol#unknown-message-definition
+type Unknown struct {
+	// Type is exposed principally for encoding.  This field *must* be set to UnknownMessageType,
+	// and is automatically set by the BeforeEncode method.
+	Type MessageType `wrp:"msg_type"`
+}
+
+func (msg *Unknown) BeforeEncode() error {
+	msg.Type = UnknownMessageType
+	return nil
+}
+
+func (msg *Unknown) MessageType() MessageType {
+	return msg.Type
+}
@@ -11,7 +11,7 @@ import (
 
 var (
 	// allFormats enumerates all of the supported formats to use in testing
-	allFormats = []Format{JSON, Msgpack}
+	allFormats = []Format{JSON, Msgpack, CBOR, Protobuf}
 )
 
 func testMessageSetStatus(t *testing.T) {
@@ -104,10 +104,59 @@ func testMessageEncode(t *testing.T, f Format, original Message) {
 	assert.Equal(original, decoded)
 }
 
+func testMessageReset(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = Message{
+			Type:             SimpleRequestResponseMessageType,
+			Source:           "dns:talaria.comcast.net",
+			Destination:      "mac:112233445566",
+			TransactionUUID:  "1234",
+			Headers:          []string{"header"},
+			Metadata:         map[string]string{"key": "value"},
+			Spans:            [][]string{{"span", "start", "duration"}},
+			Payload:          []byte("payload"),
+			QualityOfService: 50,
+		}
+
+		metadata = message.Metadata
+	)
+
+	message.SetStatus(200).SetRequestDeliveryResponse(1).SetIncludeSpans(true)
+	message.Reset()
+
+	assert.Empty(message.Headers)
+	assert.Equal(1, cap(message.Headers))
+	assert.Empty(message.Spans)
+	assert.Equal(1, cap(message.Spans))
+	assert.Empty(message.Payload)
+	assert.Equal(len("payload"), cap(message.Payload))
+	assert.Empty(message.Metadata)
+
+	// the metadata map itself is retained
+	metadata["reused"] = "true"
+	assert.Equal("true", message.Metadata["reused"])
+	delete(metadata, "reused")
+
+	// a reset message encodes just like the zero value
+	for _, f := range allFormats {
+		var encoded []byte
+		require.NoError(NewEncoderBytes(&encoded, f).Encode(&message))
+		assert.Equal(MustEncode(new(Message), f), encoded, f.String())
+	}
+
+	var zero Message
+	zero.Reset()
+	assert.Equal(Message{}, zero)
+}
+
 func TestMessage(t *testing.T) {
 	t.Run("SetStatus", testMessageSetStatus)
 	t.Run("SetRequestDeliveryResponse", testMessageSetRequestDeliveryResponse)
 	t.Run("SetIncludeSpans", testMessageSetIncludeSpans)
+	t.Run("Reset", testMessageReset)
 
 	var (
 		expectedStatus                  int64 = 3471
@@ -152,6 +201,12 @@ func TestMessage(t *testing.T) {
 				Path:        "/some/where/over/the/rainbow",
 				Payload:     []byte{1, 2, 3, 4, 0xff, 0xce},
 			},
+			{
+				Type:             SimpleEventMessageType,
+				Source:           "mac:121234345656",
+				Destination:      "event:device-status",
+				QualityOfService: QOSCriticalValue,
+			},
 		}
 	)
 
@@ -400,6 +455,11 @@ func TestSimpleEvent(t *testing.T) {
 			Metadata:    map[string]string{"a": "b", "c": "d"},
 			Payload:     []byte("check this out!"),
 		},
+		{
+			Source:           "mac:123123123123123123",
+			Destination:      "event:device-status",
+			QualityOfService: 60,
+		},
 	}
 
 	t.Run("Routable", func(t *testing.T) {
@@ -564,6 +624,67 @@ func TestCRUD(t *testing.T) {
 	}
 }
 
+func testTypedCRUDEncode(t *testing.T, f Format, expectedType MessageType, original, decoded interface {
+	Routable
+	EncodeListener
+}) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		buffer  bytes.Buffer
+		encoder = NewEncoder(&buffer, f)
+		decoder = NewDecoder(&buffer, f)
+	)
+
+	assert.NoError(encoder.Encode(original))
+	assert.True(buffer.Len() > 0)
+	assert.Equal(expectedType, original.MessageType())
+
+	// the typed structs must be wire compatible with the generic Message
+	var message Message
+	require.NoError(NewDecoderBytes(buffer.Bytes(), f).Decode(&message))
+	assert.Equal(expectedType, message.Type)
+	assert.Equal(original.From(), message.Source)
+	assert.Equal(original.To(), message.Destination)
+	assert.Equal(original.TransactionKey(), message.TransactionUUID)
+
+	assert.NoError(decoder.Decode(decoded))
+	assert.Equal(original, decoded)
+
+	response := original.Response("testTypedCRUDEncode", 123)
+	require.NotNil(response)
+	assert.IsType(original, response)
+	assert.Equal(original.From(), response.To())
+	assert.Equal("testTypedCRUDEncode", response.From())
+}
+
+func TestTypedCRUD(t *testing.T) {
+	var (
+		expectedStatus int64 = 200
+
+		crud = CRUD{
+			Source:          "mac:121234345656",
+			Destination:     "foobar.com/service",
+			TransactionUUID: "a unique identifier",
+			ContentType:     "application/json",
+			Metadata:        map[string]string{"name": "value"},
+			Status:          &expectedStatus,
+			Path:            "/a/b/c/d",
+			Payload:         []byte(`{"value": 1}`),
+		}
+	)
+
+	for _, format := range allFormats {
+		t.Run(fmt.Sprintf("Encode%s", format), func(t *testing.T) {
+			testTypedCRUDEncode(t, format, CreateMessageType, &Create{CRUD: crud}, new(Create))
+			testTypedCRUDEncode(t, format, RetrieveMessageType, &Retrieve{CRUD: crud}, new(Retrieve))
+			testTypedCRUDEncode(t, format, UpdateMessageType, &Update{CRUD: crud}, new(Update))
+			testTypedCRUDEncode(t, format, DeleteMessageType, &Delete{CRUD: crud}, new(Delete))
+		})
+	}
+}
+
 func testServiceRegistrationEncode(t *testing.T, f Format, original ServiceRegistration) {
 	var (
 		assert  = assert.New(t)
@@ -577,6 +698,7 @@ func testServiceRegistrationEncode(t *testing.T, f Format, original ServiceRegis
 	assert.NoError(encoder.Encode(&original))
 	assert.True(buffer.Len() > 0)
 	assert.Equal(ServiceRegistrationMessageType, original.Type)
+	assert.Equal(ServiceRegistrationMessageType, original.MessageType())
 	assert.NoError(decoder.Decode(&decoded))
 	assert.Equal(original, decoded)
 }
@@ -617,6 +739,7 @@ func testServiceAliveEncode(t *testing.T, f Format) {
 	assert.NoError(encoder.Encode(&original))
 	assert.True(buffer.Len() > 0)
 	assert.Equal(ServiceAliveMessageType, original.Type)
+	assert.Equal(ServiceAliveMessageType, original.MessageType())
 	assert.NoError(decoder.Decode(&decoded))
 	assert.Equal(original, decoded)
 }
@@ -628,3 +751,31 @@ func TestServiceAlive(t *testing.T) {
 		})
 	}
 }
+
+func testUnknownEncode(t *testing.T, f Format) {
+	var (
+		assert   = assert.New(t)
+		original = Unknown{}
+
+		decoded Unknown
+
+		buffer  bytes.Buffer
+		encoder = NewEncoder(&buffer, f)
+		decoder = NewDecoder(&buffer, f)
+	)
+
+	assert.NoError(encoder.Encode(&original))
+	assert.True(buffer.Len() > 0)
+	assert.Equal(UnknownMessageType, original.Type)
+	assert.Equal(UnknownMessageType, original.MessageType())
+	assert.NoError(decoder.Decode(&decoded))
+	assert.Equal(original, decoded)
+}
+
+func TestUnknown(t *testing.T) {
+	for _, format := range allFormats {
+		t.Run(fmt.Sprintf("Encode%s", format), func(t *testing.T) {
+			testUnknownEncode(t, format)
+		})
+	}
+}
@@ -20,6 +20,7 @@ const (
 	DeleteMessageType
 	ServiceRegistrationMessageType
 	ServiceAliveMessageType
+	UnknownMessageType
 	lastMessageType
 
 	AuthStatusAuthorized      = 200
@@ -41,11 +42,28 @@ func (mt MessageType) SupportsTransaction() bool {
 		return false
 	case ServiceAliveMessageType:
 		return false
+	case UnknownMessageType:
+		return false
 	default:
 		return true
 	}
 }
 
+// IsIdempotent tests if processing a message of this type more than once has the same effect as processing it once.
+// Messages of these types can be safely retried when delivery fails.
+func (mt MessageType) IsIdempotent() bool {
+	switch mt {
+	case RetrieveMessageType:
+		return true
+	case UpdateMessageType:
+		return true
+	case DeleteMessageType:
+		return true
+	default:
+		return false
+	}
+}
+
 // FriendlyName is just the String version of this type minus the "MessageType" suffix.
 // This is used in most textual representations, such as HTTP headers.
 func (mt MessageType) FriendlyName() string {
@@ -4,9 +4,9 @@ package wrp
 
 import "fmt"
 
-const _MessageType_name = "AuthorizationStatusMessageTypeSimpleRequestResponseMessageTypeSimpleEventMessageTypeCreateMessageTypeRetrieveMessageTypeUpdateMessageTypeDeleteMessageTypeServiceRegistrationMessageTypeServiceAliveMessageTypelastMessageType"
+const _MessageType_name = "AuthorizationStatusMessageTypeSimpleRequestResponseMessageTypeSimpleEventMessageTypeCreateMessageTypeRetrieveMessageTypeUpdateMessageTypeDeleteMessageTypeServiceRegistrationMessageTypeServiceAliveMessageTypeUnknownMessageTypelastMessageType"
 
-var _MessageType_index = [...]uint8{0, 30, 62, 84, 101, 120, 137, 154, 184, 207, 222}
+var _MessageType_index = [...]uint8{0, 30, 62, 84, 101, 120, 137, 154, 184, 207, 225, 240}
 
 func (i MessageType) String() string {
 	i -= 2
@@ -20,6 +20,7 @@ func TestMessageTypeString(t *testing.T) {
 			DeleteMessageType,
 			ServiceRegistrationMessageType,
 			ServiceAliveMessageType,
+			UnknownMessageType,
 			MessageType(-1),
 		}
 
@@ -50,6 +51,7 @@ func TestMessageTypeSupportsTransaction(t *testing.T) {
 			DeleteMessageType:                true,
 			ServiceRegistrationMessageType:   false,
 			ServiceAliveMessageType:          false,
+			UnknownMessageType:               false,
 		}
 	)
 
@@ -58,6 +60,28 @@ func TestMessageTypeSupportsTransaction(t *testing.T) {
 	}
 }
 
+func TestMessageTypeIsIdempotent(t *testing.T) {
+	var (
+		assert             = assert.New(t)
+		expectedIdempotent = map[MessageType]bool{
+			AuthorizationStatusMessageType:   false,
+			SimpleRequestResponseMessageType: false,
+			SimpleEventMessageType:           false,
+			CreateMessageType:                false,
+			RetrieveMessageType:              true,
+			UpdateMessageType:                true,
+			DeleteMessageType:                true,
+			ServiceRegistrationMessageType:   false,
+			ServiceAliveMessageType:          false,
+			UnknownMessageType:               false,
+		}
+	)
+
+	for messageType, expected := range expectedIdempotent {
+		assert.Equal(expected, messageType.IsIdempotent())
+	}
+}
+
 func testStringToMessageTypeValid(t *testing.T, expected MessageType) {
 	var (
 		assert         = assert.New(t)
@@ -0,0 +1,245 @@
+package wrp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataNamespaceSeparator separates a namespace from the name of a metadata entry, e.g. "talaria/boot-time"
+const MetadataNamespaceSeparator = "/"
+
+var (
+	// ErrMissingMetadata indicates that a message has no metadata entry with a requested key
+	ErrMissingMetadata = errors.New("No such WRP metadata entry")
+
+	// ErrMetadataTooLarge indicates that a message's metadata would exceed the configured MetadataLimits
+	ErrMetadataTooLarge = errors.New("The WRP metadata exceeds its size limits")
+)
+
+// MetadataKey produces the key of a metadata entry within a namespace.  An empty namespace produces
+// the name as is.
+func MetadataKey(namespace, name string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+
+	return namespace + MetadataNamespaceSeparator + name
+}
+
+// setMetadata sets a metadata value on a copy of the metadata map, as messages are frequently
+// shallow copies that share their metadata with other messages
+func (msg *Message) setMetadata(key, value string) {
+	metadata := make(map[string]string, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+
+	metadata[key] = value
+	msg.Metadata = metadata
+}
+
+// deleteMetadata removes a metadata value, again using a copy of the metadata map
+func (msg *Message) deleteMetadata(key string) {
+	if _, ok := msg.Metadata[key]; !ok {
+		return
+	}
+
+	var metadata map[string]string
+	if len(msg.Metadata) > 1 {
+		metadata = make(map[string]string, len(msg.Metadata)-1)
+		for k, v := range msg.Metadata {
+			if k != key {
+				metadata[k] = v
+			}
+		}
+	}
+
+	msg.Metadata = metadata
+}
+
+// GetMetadata returns the value of the metadata entry with the given name in a namespace
+func (msg *Message) GetMetadata(namespace, name string) (string, bool) {
+	value, ok := msg.Metadata[MetadataKey(namespace, name)]
+	return value, ok
+}
+
+// SetMetadata sets a metadata entry in a namespace.  As with all of the metadata setters, the metadata
+// map is copied rather than modified, as messages are frequently shallow copies of each other.
+func (msg *Message) SetMetadata(namespace, name, value string) *Message {
+	msg.setMetadata(MetadataKey(namespace, name), value)
+	return msg
+}
+
+// DeleteMetadata removes a metadata entry from a namespace
+func (msg *Message) DeleteMetadata(namespace, name string) *Message {
+	msg.deleteMetadata(MetadataKey(namespace, name))
+	return msg
+}
+
+// MetadataNamespace returns the entries in the given namespace, keyed by their names without the namespace.
+// This function returns nil if the namespace has no entries.
+func (msg *Message) MetadataNamespace(namespace string) map[string]string {
+	var (
+		prefix  = namespace + MetadataNamespaceSeparator
+		entries map[string]string
+	)
+
+	for k, v := range msg.Metadata {
+		if strings.HasPrefix(k, prefix) {
+			if entries == nil {
+				entries = make(map[string]string)
+			}
+
+			entries[k[len(prefix):]] = v
+		}
+	}
+
+	return entries
+}
+
+// metadataValue returns the value of a namespaced entry, or ErrMissingMetadata if there is no such entry
+func (msg *Message) metadataValue(namespace, name string) (string, error) {
+	value, ok := msg.GetMetadata(namespace, name)
+	if !ok {
+		return "", ErrMissingMetadata
+	}
+
+	return value, nil
+}
+
+// MetadataInt returns the value of a metadata entry parsed as a base 10 integer
+func (msg *Message) MetadataInt(namespace, name string) (int64, error) {
+	value, err := msg.metadataValue(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetMetadataInt sets a metadata entry to the base 10 representation of an integer
+func (msg *Message) SetMetadataInt(namespace, name string, value int64) *Message {
+	return msg.SetMetadata(namespace, name, strconv.FormatInt(value, 10))
+}
+
+// MetadataBool returns the value of a metadata entry parsed as a boolean, using strconv.ParseBool
+func (msg *Message) MetadataBool(namespace, name string) (bool, error) {
+	value, err := msg.metadataValue(namespace, name)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// SetMetadataBool sets a metadata entry to either "true" or "false"
+func (msg *Message) SetMetadataBool(namespace, name string, value bool) *Message {
+	return msg.SetMetadata(namespace, name, strconv.FormatBool(value))
+}
+
+// MetadataDuration returns the value of a metadata entry parsed with time.ParseDuration
+func (msg *Message) MetadataDuration(namespace, name string) (time.Duration, error) {
+	value, err := msg.metadataValue(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.ParseDuration(value)
+}
+
+// SetMetadataDuration sets a metadata entry to the text of a duration, e.g. "1m30s"
+func (msg *Message) SetMetadataDuration(namespace, name string, value time.Duration) *Message {
+	return msg.SetMetadata(namespace, name, value.String())
+}
+
+// MetadataTime returns the value of a metadata entry parsed as an RFC 3339 time
+func (msg *Message) MetadataTime(namespace, name string) (time.Time, error) {
+	value, err := msg.metadataValue(namespace, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// SetMetadataTime sets a metadata entry to the RFC 3339 representation of a time, converted to UTC
+func (msg *Message) SetMetadataTime(namespace, name string, value time.Time) *Message {
+	return msg.SetMetadata(namespace, name, value.UTC().Format(time.RFC3339Nano))
+}
+
+// MetadataLimits constrains the size of a message's metadata.  A nil MetadataLimits imposes no limits.
+type MetadataLimits struct {
+	// MaxEntries is the maximum number of metadata entries.  If nonpositive, the number of entries is not limited.
+	MaxEntries int
+
+	// MaxBytes is the maximum total length of the metadata keys and values.  If nonpositive, the total length
+	// is not limited.
+	MaxBytes int
+}
+
+// MetadataSize returns the total length, in bytes, of the keys and values of the given metadata
+func MetadataSize(metadata map[string]string) int {
+	size := 0
+	for k, v := range metadata {
+		size += len(k) + len(v)
+	}
+
+	return size
+}
+
+// Check returns ErrMetadataTooLarge if the given metadata exceeds these limits
+func (ml *MetadataLimits) Check(metadata map[string]string) error {
+	if ml == nil {
+		return nil
+	}
+
+	if ml.MaxEntries > 0 && len(metadata) > ml.MaxEntries {
+		return ErrMetadataTooLarge
+	}
+
+	if ml.MaxBytes > 0 && MetadataSize(metadata) > ml.MaxBytes {
+		return ErrMetadataTooLarge
+	}
+
+	return nil
+}
+
+// MergePolicy determines which value is kept when merged metadata contains a key that a message already has
+type MergePolicy int
+
+const (
+	// PreferExisting keeps the message's existing values, so that merged metadata only adds new entries
+	PreferExisting MergePolicy = iota
+
+	// PreferIncoming replaces the message's existing values with the merged values
+	PreferIncoming
+)
+
+// MergeMetadata merges incoming metadata into this message's metadata, resolving conflicts with the given policy.
+// If the merged metadata would exceed the given limits, which may be nil, ErrMetadataTooLarge is returned and
+// this message is left unchanged.
+func (msg *Message) MergeMetadata(incoming map[string]string, policy MergePolicy, limits *MetadataLimits) error {
+	if len(incoming) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(msg.Metadata)+len(incoming))
+	for k, v := range msg.Metadata {
+		merged[k] = v
+	}
+
+	for k, v := range incoming {
+		if _, exists := merged[k]; !exists || policy == PreferIncoming {
+			merged[k] = v
+		}
+	}
+
+	if err := limits.Check(merged); err != nil {
+		return err
+	}
+
+	msg.Metadata = merged
+	return nil
+}
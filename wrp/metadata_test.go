@@ -0,0 +1,178 @@
+package wrp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataKey(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("name", MetadataKey("", "name"))
+	assert.Equal("talaria/boot-time", MetadataKey("talaria", "boot-time"))
+}
+
+func testMetadataGetSet(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		original = map[string]string{"existing": "value"}
+		message  = Message{Metadata: original}
+	)
+
+	value, ok := message.GetMetadata("talaria", "partner-id")
+	assert.Empty(value)
+	assert.False(ok)
+
+	assert.Equal(&message, message.SetMetadata("talaria", "partner-id", "comcast"))
+	value, ok = message.GetMetadata("talaria", "partner-id")
+	assert.Equal("comcast", value)
+	assert.True(ok)
+
+	value, ok = message.GetMetadata("", "existing")
+	assert.Equal("value", value)
+	assert.True(ok)
+
+	assert.Equal(map[string]string{"partner-id": "comcast"}, message.MetadataNamespace("talaria"))
+	assert.Nil(message.MetadataNamespace("scytale"))
+
+	// the original map, which may be shared with other messages, is never modified
+	assert.Equal(map[string]string{"existing": "value"}, original)
+
+	message.DeleteMetadata("talaria", "partner-id")
+	assert.Equal(map[string]string{"existing": "value"}, message.Metadata)
+}
+
+func testMetadataTyped(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedTime = time.Date(2018, 3, 4, 5, 6, 7, 8, time.FixedZone("test", 3600))
+		message      Message
+	)
+
+	message.
+		SetMetadataInt("test", "int", -123).
+		SetMetadataBool("test", "bool", true).
+		SetMetadataDuration("test", "duration", 90*time.Second).
+		SetMetadataTime("test", "time", expectedTime)
+
+	assert.Equal(
+		map[string]string{
+			"test/int":      "-123",
+			"test/bool":     "true",
+			"test/duration": "1m30s",
+			"test/time":     "2018-03-04T04:06:07.000000008Z",
+		},
+		message.Metadata,
+	)
+
+	i, err := message.MetadataInt("test", "int")
+	require.NoError(err)
+	assert.Equal(int64(-123), i)
+
+	b, err := message.MetadataBool("test", "bool")
+	require.NoError(err)
+	assert.True(b)
+
+	d, err := message.MetadataDuration("test", "duration")
+	require.NoError(err)
+	assert.Equal(90*time.Second, d)
+
+	tm, err := message.MetadataTime("test", "time")
+	require.NoError(err)
+	assert.True(expectedTime.Equal(tm))
+
+	_, err = message.MetadataInt("test", "missing")
+	assert.Equal(ErrMissingMetadata, err)
+	_, err = message.MetadataBool("test", "missing")
+	assert.Equal(ErrMissingMetadata, err)
+	_, err = message.MetadataDuration("test", "missing")
+	assert.Equal(ErrMissingMetadata, err)
+	_, err = message.MetadataTime("test", "missing")
+	assert.Equal(ErrMissingMetadata, err)
+
+	message.SetMetadata("test", "bad", "this is not valid")
+	_, err = message.MetadataInt("test", "bad")
+	assert.Error(err)
+	_, err = message.MetadataBool("test", "bad")
+	assert.Error(err)
+	_, err = message.MetadataDuration("test", "bad")
+	assert.Error(err)
+	_, err = message.MetadataTime("test", "bad")
+	assert.Error(err)
+}
+
+func TestMessageMetadata(t *testing.T) {
+	t.Run("GetSet", testMetadataGetSet)
+	t.Run("Typed", testMetadataTyped)
+}
+
+func TestMetadataLimits(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = map[string]string{"a": "12", "bc": "3"}
+	)
+
+	assert.Equal(6, MetadataSize(metadata))
+	assert.Zero(MetadataSize(nil))
+
+	assert.NoError((*MetadataLimits)(nil).Check(metadata))
+	assert.NoError(new(MetadataLimits).Check(metadata))
+	assert.NoError((&MetadataLimits{MaxEntries: 2, MaxBytes: 6}).Check(metadata))
+	assert.Equal(ErrMetadataTooLarge, (&MetadataLimits{MaxEntries: 1}).Check(metadata))
+	assert.Equal(ErrMetadataTooLarge, (&MetadataLimits{MaxBytes: 5}).Check(metadata))
+}
+
+func TestMergeMetadata(t *testing.T) {
+	var (
+		existing = map[string]string{"a": "1", "b": "2"}
+		incoming = map[string]string{"b": "incoming", "c": "3"}
+	)
+
+	t.Run("PreferExisting", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message = Message{Metadata: existing}
+		)
+
+		assert.NoError(message.MergeMetadata(incoming, PreferExisting, nil))
+		assert.Equal(map[string]string{"a": "1", "b": "2", "c": "3"}, message.Metadata)
+		assert.Equal(map[string]string{"a": "1", "b": "2"}, existing)
+	})
+
+	t.Run("PreferIncoming", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message = Message{Metadata: existing}
+		)
+
+		assert.NoError(message.MergeMetadata(incoming, PreferIncoming, &MetadataLimits{MaxEntries: 3}))
+		assert.Equal(map[string]string{"a": "1", "b": "incoming", "c": "3"}, message.Metadata)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message Message
+		)
+
+		assert.NoError(message.MergeMetadata(nil, PreferIncoming, nil))
+		assert.Nil(message.Metadata)
+
+		assert.NoError(message.MergeMetadata(incoming, PreferExisting, nil))
+		assert.Equal(incoming, message.Metadata)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message = Message{Metadata: existing}
+		)
+
+		assert.Equal(ErrMetadataTooLarge, message.MergeMetadata(incoming, PreferExisting, &MetadataLimits{MaxEntries: 2}))
+		assert.Equal(existing, message.Metadata)
+	})
+}
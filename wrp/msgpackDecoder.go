@@ -0,0 +1,386 @@
+package wrp
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ugorji/go/codec"
+)
+
+// msgpackDecoder is the Decoder for the Msgpack format.  When decoding a *Message from a byte slice, which is
+// the hot path for servers that handle device traffic, the message is decoded directly from the bytes by a
+// msgpackScanner rather than through reflection.  All of the decoded strings share a single allocation, and the
+// slices, maps, and pointers already held by the target Message are reused.  Every other case is delegated to
+// the ugorji decoder.
+type msgpackDecoder struct {
+	codec *codec.Decoder
+
+	// input is the unread portion of the byte slice being decoded.  This is nil when decoding from an io.Reader.
+	input []byte
+
+	// delegated is set once the ugorji decoder has consumed any of the byte slice.  Since the ugorji decoder does
+	// not expose its position, the fast path cannot be used again until the next reset.
+	delegated bool
+
+	strings stringBlock
+}
+
+func newMsgpackDecoder(input io.Reader) *msgpackDecoder {
+	return &msgpackDecoder{
+		codec: codec.NewDecoder(input, &msgpackHandle),
+	}
+}
+
+func newMsgpackDecoderBytes(input []byte) *msgpackDecoder {
+	return &msgpackDecoder{
+		codec: codec.NewDecoderBytes(input, &msgpackHandle),
+		input: nonNil(input),
+	}
+}
+
+// nonNil ensures that an empty byte slice is distinguishable from decoding an io.Reader
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+
+	return b
+}
+
+func (md *msgpackDecoder) Decode(v interface{}) error {
+	if m, ok := v.(*Message); ok && m != nil && md.input != nil && !md.delegated {
+		if len(md.input) == 0 {
+			return io.EOF
+		}
+
+		ms := msgpackScanner{data: md.input}
+		err := md.decodeMessage(&ms, m)
+		md.input = md.input[ms.pos:]
+		return err
+	}
+
+	if md.input != nil && !md.delegated {
+		md.codec.ResetBytes(md.input)
+		md.delegated = true
+	}
+
+	return md.codec.Decode(v)
+}
+
+func (md *msgpackDecoder) Reset(input io.Reader) {
+	md.codec.Reset(input)
+	md.input = nil
+	md.delegated = false
+}
+
+func (md *msgpackDecoder) ResetBytes(input []byte) {
+	md.codec.ResetBytes(input)
+	md.input = nonNil(input)
+	md.delegated = false
+}
+
+// stringBlock accumulates the bytes of each string decoded from a message, so that a single string can be
+// allocated for all of them.  The references record where each decoded string must be stored.
+type stringBlock struct {
+	buffer   []byte
+	targets  []stringRef
+	metadata []metadataRef
+}
+
+type stringRef struct {
+	target     *string
+	start, end int
+}
+
+type metadataRef struct {
+	target                       map[string]string
+	keyStart, keyEnd, start, end int
+}
+
+// release drops the references to the targets, so that the decoded message is not retained by this block
+func (sb *stringBlock) release() {
+	for i := range sb.targets {
+		sb.targets[i].target = nil
+	}
+
+	for i := range sb.metadata {
+		sb.metadata[i].target = nil
+	}
+
+	sb.buffer = sb.buffer[:0]
+	sb.targets = sb.targets[:0]
+	sb.metadata = sb.metadata[:0]
+}
+
+func (sb *stringBlock) add(b []byte) (int, int) {
+	start := len(sb.buffer)
+	sb.buffer = append(sb.buffer, b...)
+	return start, len(sb.buffer)
+}
+
+func (sb *stringBlock) addString(target *string, b []byte) {
+	start, end := sb.add(b)
+	sb.targets = append(sb.targets, stringRef{target, start, end})
+}
+
+// commit allocates the shared string and stores each decoded string in its target
+func (sb *stringBlock) commit() {
+	block := string(sb.buffer)
+	for _, r := range sb.targets {
+		*r.target = block[r.start:r.end]
+	}
+
+	for _, r := range sb.metadata {
+		r.target[block[r.keyStart:r.keyEnd]] = block[r.start:r.end]
+	}
+}
+
+// isNil tests if the next value is a msgpack nil, consuming it if so
+func (ms *msgpackScanner) isNil() bool {
+	if ms.pos < len(ms.data) && ms.data[ms.pos] == 0xc0 {
+		ms.pos++
+		return true
+	}
+
+	return false
+}
+
+// arrayHeader reads the header of an array, returning its number of elements
+func (ms *msgpackScanner) arrayHeader() (int, error) {
+	t, err := ms.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case t >= 0x90 && t <= 0x9f:
+		return int(t & 0x0f), nil
+	case t == 0xdc:
+		return ms.length(2)
+	case t == 0xdd:
+		return ms.length(4)
+	}
+
+	return 0, fmt.Errorf("Invalid msgpack type for an array: 0x%x", t)
+}
+
+// boolean reads a boolean value
+func (ms *msgpackScanner) boolean() (bool, error) {
+	t, err := ms.readByte()
+	if err != nil {
+		return false, err
+	}
+
+	switch t {
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	}
+
+	return false, fmt.Errorf("Invalid msgpack type for a boolean: 0x%x", t)
+}
+
+// decodeStrings reads an array of strings, reusing the capacity of the given slice
+func (md *msgpackDecoder) decodeStrings(ms *msgpackScanner, values []string) ([]string, error) {
+	if ms.isNil() {
+		return nil, nil
+	}
+
+	n, err := ms.arrayHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(values) < n {
+		values = make([]string, n)
+	} else {
+		values = values[:n]
+	}
+
+	for i := 0; i < n; i++ {
+		b, err := ms.strBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		md.strings.addString(&values[i], b)
+	}
+
+	return values, nil
+}
+
+func (md *msgpackDecoder) decodeMetadata(ms *msgpackScanner, metadata map[string]string) (map[string]string, error) {
+	if ms.isNil() {
+		return nil, nil
+	}
+
+	n, err := ms.mapHeader()
+	if err == ErrNotAMap {
+		return nil, fmt.Errorf("Invalid msgpack type for %s", MetadataField)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string, n)
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := ms.strBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := ms.strBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		keyStart, keyEnd := md.strings.add(key)
+		start, end := md.strings.add(value)
+		md.strings.metadata = append(md.strings.metadata, metadataRef{metadata, keyStart, keyEnd, start, end})
+	}
+
+	return metadata, nil
+}
+
+func (md *msgpackDecoder) decodeSpans(ms *msgpackScanner, spans [][]string) ([][]string, error) {
+	if ms.isNil() {
+		return nil, nil
+	}
+
+	n, err := ms.arrayHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(spans) < n {
+		spans = append(spans[:cap(spans)], make([][]string, n-cap(spans))...)
+	}
+
+	spans = spans[:n]
+	for i := 0; i < n; i++ {
+		if spans[i], err = md.decodeStrings(ms, spans[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return spans, nil
+}
+
+// decodeInt64Pointer decodes an optional integer, reusing the existing pointer if there is one
+func decodeInt64Pointer(ms *msgpackScanner, p *int64) (*int64, error) {
+	if ms.isNil() {
+		return nil, nil
+	}
+
+	v, err := ms.integer()
+	if err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		p = new(int64)
+	}
+
+	*p = v
+	return p, nil
+}
+
+// decodeMessage is the fast path for decoding a *Message
+func (md *msgpackDecoder) decodeMessage(ms *msgpackScanner, m *Message) error {
+	defer md.strings.release()
+	count, err := ms.mapHeader()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		key, err := ms.strBytes()
+		if err != nil {
+			return err
+		}
+
+		// switching on the converted bytes does not allocate
+		switch string(key) {
+		case MsgTypeField:
+			var v int64
+			v, err = ms.integer()
+			m.Type = MessageType(v)
+		case SourceField:
+			err = md.decodeString(ms, &m.Source)
+		case DestinationField:
+			err = md.decodeString(ms, &m.Destination)
+		case TransactionUUIDField:
+			err = md.decodeString(ms, &m.TransactionUUID)
+		case ContentTypeField:
+			err = md.decodeString(ms, &m.ContentType)
+		case AcceptField:
+			err = md.decodeString(ms, &m.Accept)
+		case StatusField:
+			m.Status, err = decodeInt64Pointer(ms, m.Status)
+		case RequestDeliveryResponseField:
+			m.RequestDeliveryResponse, err = decodeInt64Pointer(ms, m.RequestDeliveryResponse)
+		case HeadersField:
+			m.Headers, err = md.decodeStrings(ms, m.Headers)
+		case MetadataField:
+			m.Metadata, err = md.decodeMetadata(ms, m.Metadata)
+		case SpansField:
+			m.Spans, err = md.decodeSpans(ms, m.Spans)
+		case IncludeSpansField:
+			if ms.isNil() {
+				m.IncludeSpans = nil
+			} else {
+				var v bool
+				if v, err = ms.boolean(); err == nil {
+					if m.IncludeSpans == nil {
+						m.IncludeSpans = new(bool)
+					}
+
+					*m.IncludeSpans = v
+				}
+			}
+		case PathField:
+			err = md.decodeString(ms, &m.Path)
+		case PayloadField:
+			var b []byte
+			if b, err = ms.strBytes(); err == nil {
+				switch {
+				case b == nil:
+					m.Payload = nil
+				case len(b) == 0 && m.Payload == nil:
+					m.Payload = []byte{}
+				default:
+					m.Payload = append(m.Payload[:0], b...)
+				}
+			}
+		case ServiceNameField:
+			err = md.decodeString(ms, &m.ServiceName)
+		case URLField:
+			err = md.decodeString(ms, &m.URL)
+		case QualityOfServiceField:
+			var v int64
+			v, err = ms.integer()
+			m.QualityOfService = QOSValue(v)
+		default:
+			err = ms.skip()
+		}
+
+		if err != nil {
+			return fmt.Errorf("Unable to decode WRP field %s: %s", key, err)
+		}
+	}
+
+	md.strings.commit()
+	return nil
+}
+
+func (md *msgpackDecoder) decodeString(ms *msgpackScanner, target *string) error {
+	b, err := ms.strBytes()
+	if err == nil {
+		md.strings.addString(target, b)
+	}
+
+	return err
+}
@@ -0,0 +1,212 @@
+package wrp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+func msgpackDecoderTestMessages() []Message {
+	var (
+		status                  int64 = 200
+		requestDeliveryResponse int64 = 1
+		includeSpans                  = true
+	)
+
+	return []Message{
+		{},
+		{
+			Type:        SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status",
+			Metadata:    map[string]string{"/trust": "1000", "/boot-time": "1234"},
+			Payload:     []byte("an event payload"),
+		},
+		{
+			Type:                    SimpleRequestResponseMessageType,
+			Source:                  "dns:talaria.comcast.net",
+			Destination:             "mac:112233445566/config",
+			TransactionUUID:         "DEADBEEF",
+			ContentType:             "application/json",
+			Accept:                  "application/msgpack",
+			Status:                  &status,
+			RequestDeliveryResponse: &requestDeliveryResponse,
+			Headers:                 []string{"Header1", "Header2"},
+			Metadata:                map[string]string{"key": "value"},
+			Spans:                   [][]string{{"span", "1", "2"}, {"another", "3", "4"}},
+			IncludeSpans:            &includeSpans,
+			Payload:                 make([]byte, 100000),
+			ServiceName:             "config",
+			URL:                     "http://somewhere.comcast.net",
+			QualityOfService:        QOSHighValue,
+		},
+		{
+			Type:        CreateMessageType,
+			Source:      "dns:somewhere.comcast.net",
+			Destination: "mac:112233445566",
+			Path:        "/some/where/over/the/rainbow",
+			Headers:     []string{"Header3"},
+			Spans:       [][]string{{"span", "5", "6"}},
+			Payload:     []byte{1, 2, 3},
+		},
+	}
+}
+
+func encodeMsgpack(t *testing.T, messages ...Message) []byte {
+	var (
+		output  []byte
+		encoder = NewEncoderBytes(&output, Msgpack)
+	)
+
+	for i := range messages {
+		require.NoError(t, encoder.Encode(&messages[i]))
+	}
+
+	return output
+}
+
+func testMsgpackDecoderEquivalence(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		messages = msgpackDecoderTestMessages()
+	)
+
+	for _, original := range messages {
+		var (
+			encoded  = encodeMsgpack(t, original)
+			expected Message
+			actual   Message
+		)
+
+		require.NoError(codec.NewDecoderBytes(encoded, &msgpackHandle).Decode(&expected))
+		require.NoError(newMsgpackDecoderBytes(encoded).Decode(&actual))
+		assert.Equal(expected, actual)
+	}
+}
+
+func testMsgpackDecoderReuse(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		messages = msgpackDecoderTestMessages()
+
+		reference = codec.NewDecoderBytes(nil, &msgpackHandle)
+		decoder   = newMsgpackDecoderBytes(nil)
+
+		expected Message
+		actual   Message
+	)
+
+	// decoding into a previously used message must behave exactly as the ugorji decoder does
+	for i := len(messages) - 1; i >= 0; i-- {
+		encoded := encodeMsgpack(t, messages[i])
+
+		reference.ResetBytes(encoded)
+		require.NoError(reference.Decode(&expected))
+
+		decoder.ResetBytes(encoded)
+		require.NoError(decoder.Decode(&actual))
+		assert.Equal(expected, actual)
+	}
+
+	assert.Empty(decoder.strings.targets)
+	assert.Empty(decoder.strings.metadata)
+}
+
+func testMsgpackDecoderMultipleMessages(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		messages = msgpackDecoderTestMessages()
+		decoder  = newMsgpackDecoderBytes(encodeMsgpack(t, messages...))
+	)
+
+	for _, original := range messages {
+		var actual Message
+		require.NoError(decoder.Decode(&actual))
+		assert.Equal(original.Source, actual.Source)
+		assert.Equal(original.Destination, actual.Destination)
+		assert.Equal(len(original.Payload), len(actual.Payload))
+	}
+
+	assert.Equal(io.EOF, decoder.Decode(new(Message)))
+}
+
+func testMsgpackDecoderDelegation(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		messages = msgpackDecoderTestMessages()
+		encoded  = encodeMsgpack(t, messages[1], messages[3])
+
+		event   SimpleEvent
+		message Message
+	)
+
+	decoder := newMsgpackDecoderBytes(encoded)
+	require.NoError(decoder.Decode(&event))
+	assert.Equal(messages[1].Destination, event.Destination)
+	assert.Equal(messages[1].Payload, event.Payload)
+
+	// once delegated, the remainder of the input is decoded by ugorji
+	require.NoError(decoder.Decode(&message))
+	assert.Equal(messages[3].Path, message.Path)
+	assert.True(decoder.delegated)
+
+	// a reset restores the fast path
+	decoder.ResetBytes(encoded)
+	assert.False(decoder.delegated)
+	require.NoError(decoder.Decode(&message))
+	assert.Equal(messages[1].Destination, message.Destination)
+
+	// decoding from an io.Reader is always delegated
+	decoder.Reset(bytes.NewReader(encoded))
+	message = Message{}
+	require.NoError(decoder.Decode(&message))
+	assert.Equal(messages[1].Destination, message.Destination)
+	require.NoError(decoder.Decode(&message))
+	assert.Equal(messages[3].Path, message.Path)
+}
+
+func testMsgpackDecoderErrors(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		messages = msgpackDecoderTestMessages()
+		encoded  = encodeMsgpack(t, messages[2])
+
+		invalidFields = []map[string]interface{}{
+			{SourceField: 123},
+			{MsgTypeField: "not an integer"},
+			{HeadersField: "not an array"},
+			{MetadataField: []string{"not a map"}},
+			{SpansField: []int{1, 2}},
+			{IncludeSpansField: 1},
+			{StatusField: "not an integer"},
+		}
+	)
+
+	for _, length := range []int{1, 10, len(encoded) / 2, len(encoded) - 1} {
+		assert.Error(newMsgpackDecoderBytes(encoded[:length]).Decode(new(Message)))
+	}
+
+	assert.Error(newMsgpackDecoderBytes([]byte{0x93, 0x01, 0x02, 0x03}).Decode(new(Message)))
+
+	for _, fields := range invalidFields {
+		var invalid []byte
+		require.NoError(t, codec.NewEncoderBytes(&invalid, &msgpackHandle).Encode(fields))
+		assert.Error(newMsgpackDecoderBytes(invalid).Decode(new(Message)), "%v", fields)
+	}
+}
+
+func TestMsgpackDecoder(t *testing.T) {
+	t.Run("Equivalence", testMsgpackDecoderEquivalence)
+	t.Run("Reuse", testMsgpackDecoderReuse)
+	t.Run("MultipleMessages", testMsgpackDecoderMultipleMessages)
+	t.Run("Delegation", testMsgpackDecoderDelegation)
+	t.Run("Errors", testMsgpackDecoderErrors)
+}
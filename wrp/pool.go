@@ -3,34 +3,289 @@ package wrp
 import (
 	"io"
 	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
 )
 
 const (
 	DefaultPoolCapacity = 100
+
+	// DefaultMaxPooledPayload is the largest payload capacity, in bytes, that a MessagePool retains
+	// when no maximum is configured
+	DefaultMaxPooledPayload = 64 * 1024
+
+	// DefaultPoolResizeInterval is the number of Get calls between attempts to shrink an auto-sized pool
+	DefaultPoolResizeInterval = 1000
 )
 
+// PoolOptions configures an EncoderPool or DecoderPool.  A nil PoolOptions is valid, and produces
+// a fixed-size pool with DefaultPoolCapacity and no metrics.
+type PoolOptions struct {
+	// Capacity is the initial capacity of the pool.  If nonpositive, DefaultPoolCapacity is used.
+	Capacity int
+
+	// MaxCapacity enables auto-sizing when it is greater than Capacity.  An auto-sized pool doubles its capacity, up to
+	// MaxCapacity, whenever an object would be discarded because the pool is full.  Periodically, the pool shrinks back
+	// toward Capacity by the number of pooled objects that went unused since the previous check.
+	MaxCapacity int
+
+	// ResizeInterval is the number of Get calls between attempts to shrink an auto-sized pool.  If nonpositive,
+	// DefaultPoolResizeInterval is used.
+	ResizeInterval int
+
+	// Hits counts the Get calls that were satisfied from the pool
+	Hits metrics.Counter
+
+	// Misses counts the Get calls that had to create a new object because the pool was empty
+	Misses metrics.Counter
+
+	// Discards counts the Put calls that dropped their object because the pool was full
+	Discards metrics.Counter
+
+	// Size tracks the number of objects currently in the pool
+	Size metrics.Gauge
+
+	// CapacityGauge tracks the current capacity of the pool, which only changes for auto-sized pools
+	CapacityGauge metrics.Gauge
+
+	// Strict causes a DecoderPool to create its decoders with NewStrictDecoder.  This option has no
+	// effect on an EncoderPool.
+	Strict bool
+
+	// Canonical causes an EncoderPool to create its encoders with NewCanonicalEncoder.  This option has no
+	// effect on a DecoderPool.
+	Canonical bool
+}
+
+func (o *PoolOptions) capacity() int {
+	if o != nil && o.Capacity > 0 {
+		return o.Capacity
+	}
+
+	return DefaultPoolCapacity
+}
+
+func (o *PoolOptions) maxCapacity() int {
+	if o != nil && o.MaxCapacity > o.capacity() {
+		return o.MaxCapacity
+	}
+
+	return o.capacity()
+}
+
+func (o *PoolOptions) resizeInterval() int {
+	if o != nil && o.ResizeInterval > 0 {
+		return o.ResizeInterval
+	}
+
+	return DefaultPoolResizeInterval
+}
+
+func (o *PoolOptions) hits() metrics.Counter {
+	if o != nil && o.Hits != nil {
+		return o.Hits
+	}
+
+	return discard.NewCounter()
+}
+
+func (o *PoolOptions) misses() metrics.Counter {
+	if o != nil && o.Misses != nil {
+		return o.Misses
+	}
+
+	return discard.NewCounter()
+}
+
+func (o *PoolOptions) discards() metrics.Counter {
+	if o != nil && o.Discards != nil {
+		return o.Discards
+	}
+
+	return discard.NewCounter()
+}
+
+func (o *PoolOptions) size() metrics.Gauge {
+	if o != nil && o.Size != nil {
+		return o.Size
+	}
+
+	return discard.NewGauge()
+}
+
+func (o *PoolOptions) capacityGauge() metrics.Gauge {
+	if o != nil && o.CapacityGauge != nil {
+		return o.CapacityGauge
+	}
+
+	return discard.NewGauge()
+}
+
+func (o *PoolOptions) strict() bool {
+	return o != nil && o.Strict
+}
+
+func (o *PoolOptions) canonical() bool {
+	return o != nil && o.Canonical
+}
+
+// objectPool is the pooling strategy shared by EncoderPool and DecoderPool
+type objectPool struct {
+	lock           sync.Mutex
+	items          []interface{}
+	capacity       int
+	minCapacity    int
+	maxCapacity    int
+	resizeInterval int
+
+	// gets is the number of Get calls since the last resize, and lowWater is the fewest pooled objects
+	// seen over those calls.  Objects below the low water mark were never needed.
+	gets     int
+	lowWater int
+
+	hits          metrics.Counter
+	misses        metrics.Counter
+	discards      metrics.Counter
+	size          metrics.Gauge
+	capacityGauge metrics.Gauge
+}
+
+func newObjectPool(o *PoolOptions) *objectPool {
+	op := &objectPool{
+		items:          make([]interface{}, 0, o.capacity()),
+		capacity:       o.capacity(),
+		minCapacity:    o.capacity(),
+		maxCapacity:    o.maxCapacity(),
+		resizeInterval: o.resizeInterval(),
+		hits:           o.hits(),
+		misses:         o.misses(),
+		discards:       o.discards(),
+		size:           o.size(),
+		capacityGauge:  o.capacityGauge(),
+	}
+
+	op.size.Set(0)
+	op.capacityGauge.Set(float64(op.capacity))
+	return op
+}
+
+func (op *objectPool) len() int {
+	op.lock.Lock()
+	length := len(op.items)
+	op.lock.Unlock()
+	return length
+}
+
+func (op *objectPool) cap() int {
+	op.lock.Lock()
+	capacity := op.capacity
+	op.lock.Unlock()
+	return capacity
+}
+
+// get removes an object from the pool, returning nil if the pool is empty
+func (op *objectPool) get() (item interface{}) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if op.maxCapacity > op.minCapacity {
+		op.gets++
+		if op.gets >= op.resizeInterval {
+			op.shrink()
+		}
+	}
+
+	last := len(op.items) - 1
+	if last < 0 {
+		op.lowWater = 0
+		op.misses.Add(1)
+		return nil
+	}
+
+	item, op.items[last] = op.items[last], nil
+	op.items = op.items[:last]
+	if last < op.lowWater {
+		op.lowWater = last
+	}
+
+	op.hits.Add(1)
+	op.size.Set(float64(last))
+	return
+}
+
+// shrink reduces the capacity of an auto-sized pool by the number of objects that went unused during
+// the last interval.  This method must be called under the lock.
+func (op *objectPool) shrink() {
+	if unused := op.lowWater; unused > 0 {
+		op.capacity -= unused
+		if op.capacity < op.minCapacity {
+			op.capacity = op.minCapacity
+		}
+
+		if len(op.items) > op.capacity {
+			for i := op.capacity; i < len(op.items); i++ {
+				op.items[i] = nil
+			}
+
+			op.items = op.items[:op.capacity]
+			op.size.Set(float64(len(op.items)))
+		}
+
+		op.capacityGauge.Set(float64(op.capacity))
+	}
+
+	op.gets = 0
+	op.lowWater = len(op.items)
+}
+
+// put returns an object to the pool, growing an auto-sized pool if necessary
+func (op *objectPool) put(item interface{}) bool {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if len(op.items) >= op.capacity {
+		if op.capacity >= op.maxCapacity {
+			op.discards.Add(1)
+			return false
+		}
+
+		op.capacity *= 2
+		if op.capacity > op.maxCapacity {
+			op.capacity = op.maxCapacity
+		}
+
+		op.capacityGauge.Set(float64(op.capacity))
+	}
+
+	op.items = append(op.items, item)
+	op.size.Set(float64(len(op.items)))
+	return true
+}
+
 // EncoderPool represents a pool of Encoder objects that can be used as is
 // encode WRP messages.  Unlike a sync.Pool, this pool holds on to its pooled
 // encoders across garbage collections.
 type EncoderPool struct {
-	lock     sync.Mutex
-	pool     []Encoder
-	capacity int
-	format   Format
+	objects   *objectPool
+	format    Format
+	canonical bool
 }
 
 // NewEncoderPool returns an EncoderPool for a given format.  The initialBufferSize is
 // used when encoding to byte arrays.  If this value is nonpositive, DefaultInitialBufferSize
 // is used instead.
 func NewEncoderPool(capacity int, f Format) *EncoderPool {
-	if capacity < 1 {
-		capacity = DefaultPoolCapacity
-	}
+	return NewEncoderPoolWithOptions(&PoolOptions{Capacity: capacity}, f)
+}
 
+// NewEncoderPoolWithOptions returns an EncoderPool for a given format, using the supplied options
+// for capacity, auto-sizing, and metrics.  The options may be nil.
+func NewEncoderPoolWithOptions(o *PoolOptions, f Format) *EncoderPool {
 	return &EncoderPool{
-		pool:     make([]Encoder, 0, capacity),
-		capacity: capacity,
-		format:   f,
+		objects:   newObjectPool(o),
+		format:    f,
+		canonical: o.canonical(),
 	}
 }
 
@@ -43,54 +298,42 @@ func (ep *EncoderPool) Format() Format {
 // This method is used internally to populate and manage the pool, but
 // can also be used externally to obtain a new, unpooled instance.
 func (ep *EncoderPool) New() Encoder {
+	if ep.canonical {
+		return NewCanonicalEncoder(nil, ep.format)
+	}
+
 	return NewEncoder(nil, ep.format)
 }
 
 // Len returns the number of pooled elements available for Get.
 func (ep *EncoderPool) Len() int {
-	ep.lock.Lock()
-	length := len(ep.pool)
-	ep.lock.Unlock()
-	return length
+	return ep.objects.len()
 }
 
-// Cap returns the capacity of the pool, which is fixed at the time of creation.
+// Cap returns the current capacity of the pool.  This is fixed at the time of creation, unless
+// the pool is auto-sized.
 func (ep *EncoderPool) Cap() int {
-	return ep.capacity
+	return ep.objects.cap()
 }
 
 // Get returns an Encoder from the pool.  If the pool is empty, a new Encoder is
 // created using the initial pool configuration.  This method never returns nil.
-func (ep *EncoderPool) Get() (encoder Encoder) {
-	ep.lock.Lock()
-
-	last := len(ep.pool) - 1
-	if last >= 0 {
-		encoder, ep.pool[last] = ep.pool[last], nil
-		ep.pool = ep.pool[0:last]
-	} else {
-		encoder = ep.New()
+func (ep *EncoderPool) Get() Encoder {
+	if encoder, ok := ep.objects.get().(Encoder); ok {
+		return encoder
 	}
 
-	ep.lock.Unlock()
-	return
+	return ep.New()
 }
 
 // Put returns an Encoder to the pool.  This method returns true if the encoder
 // was returned to the pool, false if the pool was full or encoder was nil.
-func (ep *EncoderPool) Put(encoder Encoder) (returned bool) {
-	if encoder != nil {
-		ep.lock.Lock()
-
-		if len(ep.pool) < ep.capacity {
-			ep.pool = append(ep.pool, encoder)
-			returned = true
-		}
-
-		ep.lock.Unlock()
+func (ep *EncoderPool) Put(encoder Encoder) bool {
+	if encoder == nil {
+		return false
 	}
 
-	return
+	return ep.objects.put(encoder)
 }
 
 // Encode uses an Encoder from the pool to encode the source into the destination
@@ -116,22 +359,23 @@ func (ep *EncoderPool) EncodeBytes(destination *[]byte, source interface{}) erro
 
 // DecoderPool is a pool of Decoder instances for a specific format
 type DecoderPool struct {
-	lock     sync.Mutex
-	pool     []Decoder
-	capacity int
-	format   Format
+	objects *objectPool
+	format  Format
+	strict  bool
 }
 
 // NewDecoderPool returns a DecoderPool that works with a given Format
 func NewDecoderPool(capacity int, f Format) *DecoderPool {
-	if capacity < 1 {
-		capacity = DefaultPoolCapacity
-	}
+	return NewDecoderPoolWithOptions(&PoolOptions{Capacity: capacity}, f)
+}
 
+// NewDecoderPoolWithOptions returns a DecoderPool for a given format, using the supplied options
+// for capacity, auto-sizing, and metrics.  The options may be nil.
+func NewDecoderPoolWithOptions(o *PoolOptions, f Format) *DecoderPool {
 	return &DecoderPool{
-		pool:     make([]Decoder, 0, capacity),
-		capacity: capacity,
-		format:   f,
+		objects: newObjectPool(o),
+		format:  f,
+		strict:  o.strict(),
 	}
 }
 
@@ -144,54 +388,42 @@ func (ep *DecoderPool) Format() Format {
 // This method is used internally to populate and manage the pool, but
 // can also be used externally to obtain a new, unpooled instance.
 func (dp *DecoderPool) New() Decoder {
+	if dp.strict {
+		return NewStrictDecoder(nil, dp.format)
+	}
+
 	return NewDecoder(nil, dp.format)
 }
 
 // Len returns the number of pooled elements available for Get.
 func (dp *DecoderPool) Len() int {
-	dp.lock.Lock()
-	length := len(dp.pool)
-	dp.lock.Unlock()
-	return length
+	return dp.objects.len()
 }
 
-// Cap returns the capacity of the pool, which is fixed at the time of creation.
+// Cap returns the current capacity of the pool.  This is fixed at the time of creation, unless
+// the pool is auto-sized.
 func (dp *DecoderPool) Cap() int {
-	return dp.capacity
+	return dp.objects.cap()
 }
 
 // Get obtains a Decoder from the pool.  If the pool is empty, a new Decoder is
 // created using the initial pool configuration.  This method never returns nil.
-func (dp *DecoderPool) Get() (decoder Decoder) {
-	dp.lock.Lock()
-
-	last := len(dp.pool) - 1
-	if last >= 0 {
-		decoder, dp.pool[last] = dp.pool[last], nil
-		dp.pool = dp.pool[0:last]
-	} else {
-		decoder = dp.New()
+func (dp *DecoderPool) Get() Decoder {
+	if decoder, ok := dp.objects.get().(Decoder); ok {
+		return decoder
 	}
 
-	dp.lock.Unlock()
-	return
+	return dp.New()
 }
 
 // Put returns a Decoder to the pool.  This method returns true if the decoder
 // was returned to the pool, false if the pool was full or decoder was nil.
-func (dp *DecoderPool) Put(decoder Decoder) (returned bool) {
-	if decoder != nil {
-		dp.lock.Lock()
-
-		if len(dp.pool) < cap(dp.pool) {
-			dp.pool = append(dp.pool, decoder)
-			returned = true
-		}
-
-		dp.lock.Unlock()
+func (dp *DecoderPool) Put(decoder Decoder) bool {
+	if decoder == nil {
+		return false
 	}
 
-	return
+	return dp.objects.put(decoder)
 }
 
 // Decode unmarshals data from the source onto the destination instance, which is
@@ -213,3 +445,64 @@ func (dp *DecoderPool) DecodeBytes(destination interface{}, source []byte) error
 	decoder.ResetBytes(source)
 	return decoder.Decode(destination)
 }
+
+// MessagePool is a sync.Pool of *Message instances, which allows servers that decode every message they handle,
+// such as routers, to reuse messages along with their slices and maps.  Messages are Reset when returned to the pool.
+//
+// Unlike EncoderPool and DecoderPool, a MessagePool has no fixed capacity, as pooled messages are released by
+// the garbage collector whenever they go unused.
+type MessagePool struct {
+	pool               sync.Pool
+	maxPayloadCapacity int
+}
+
+// NewMessagePool creates a MessagePool.  Messages whose payloads have a capacity larger than maxPayloadCapacity
+// bytes have their payloads discarded when put back into the pool, so that occasional large messages do not pin
+// large buffers in memory.  If maxPayloadCapacity is nonpositive, DefaultMaxPooledPayload is used.
+func NewMessagePool(maxPayloadCapacity int) *MessagePool {
+	if maxPayloadCapacity < 1 {
+		maxPayloadCapacity = DefaultMaxPooledPayload
+	}
+
+	return &MessagePool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return new(Message)
+			},
+		},
+		maxPayloadCapacity: maxPayloadCapacity,
+	}
+}
+
+// Get obtains a Message from the pool, creating one if necessary.  The returned message is always
+// equivalent to the zero value of Message.  This method never returns nil.
+func (mp *MessagePool) Get() *Message {
+	return mp.pool.Get().(*Message)
+}
+
+// Put resets a Message and returns it to the pool.  The caller must not use the message, or any of its
+// slices or maps, after this method is called.  A nil message is ignored.
+func (mp *MessagePool) Put(m *Message) {
+	if m == nil {
+		return
+	}
+
+	if cap(m.Payload) > mp.maxPayloadCapacity {
+		m.Payload = nil
+	}
+
+	m.Reset()
+	mp.pool.Put(m)
+}
+
+// DecodeBytes obtains a Message from this pool and decodes the source onto it using the given DecoderPool.
+// If decoding fails, the message is returned to this pool and nil is returned along with the error.
+func (mp *MessagePool) DecodeBytes(dp *DecoderPool, source []byte) (*Message, error) {
+	m := mp.Get()
+	if err := dp.DecodeBytes(m, source); err != nil {
+		mp.Put(m)
+		return nil, err
+	}
+
+	return m, nil
+}
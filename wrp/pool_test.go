@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/go-kit/kit/metrics/generic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -155,6 +156,215 @@ func TestDecoderPool(t *testing.T) {
 		})
 	}
 }
+func testPoolOptionsDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, o := range []*PoolOptions{nil, new(PoolOptions), {Capacity: 10, MaxCapacity: 5}} {
+		ep := NewEncoderPoolWithOptions(o, JSON)
+		assert.Equal(o.capacity(), ep.Cap())
+		assert.Equal(o.capacity(), o.maxCapacity())
+		assert.Equal(DefaultPoolResizeInterval, o.resizeInterval())
+
+		dp := NewDecoderPoolWithOptions(o, JSON)
+		assert.Equal(o.capacity(), dp.Cap())
+	}
+}
+
+func testPoolOptionsMetrics(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		o = &PoolOptions{
+			Capacity:      2,
+			Hits:          generic.NewCounter("hits"),
+			Misses:        generic.NewCounter("misses"),
+			Discards:      generic.NewCounter("discards"),
+			Size:          generic.NewGauge("size"),
+			CapacityGauge: generic.NewGauge("capacity"),
+		}
+
+		ep = NewEncoderPoolWithOptions(o, Msgpack)
+	)
+
+	assert.Equal(2.0, o.CapacityGauge.(*generic.Gauge).Value())
+	ep.Get()
+	assert.Equal(1.0, o.Misses.(*generic.Counter).Value())
+
+	for i := 0; i < 3; i++ {
+		ep.Put(ep.New())
+	}
+
+	assert.Equal(1.0, o.Discards.(*generic.Counter).Value())
+	assert.Equal(2.0, o.Size.(*generic.Gauge).Value())
+
+	ep.Get()
+	assert.Equal(1.0, o.Hits.(*generic.Counter).Value())
+	assert.Equal(1.0, o.Size.(*generic.Gauge).Value())
+}
+
+func testPoolOptionsAutoSize(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		o = &PoolOptions{
+			Capacity:       2,
+			MaxCapacity:    5,
+			ResizeInterval: 4,
+			Discards:       generic.NewCounter("discards"),
+			CapacityGauge:  generic.NewGauge("capacity"),
+		}
+
+		dp = NewDecoderPoolWithOptions(o, Msgpack)
+	)
+
+	// returning more decoders than the capacity grows the pool up to its maximum
+	for i := 0; i < 6; i++ {
+		dp.Put(dp.New())
+	}
+
+	assert.Equal(5, dp.Cap())
+	assert.Equal(5, dp.Len())
+	assert.Equal(1.0, o.Discards.(*generic.Counter).Value())
+	assert.Equal(5.0, o.CapacityGauge.(*generic.Gauge).Value())
+
+	// a steady load of one decoder at a time leaves 4 pooled decoders unused, so the pool shrinks
+	// back to its initial capacity over the following resize intervals
+	for i := 0; i < 2*o.ResizeInterval+1; i++ {
+		dp.Put(dp.Get())
+	}
+
+	assert.Equal(2, dp.Cap())
+	assert.Equal(2, dp.Len())
+	assert.Equal(2.0, o.CapacityGauge.(*generic.Gauge).Value())
+}
+
+func testPoolOptionsCanonical(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = Message{
+			Source:   "test.com",
+			Metadata: map[string]string{"c": "3", "a": "1", "b": "2"},
+		}
+
+		expected []byte
+	)
+
+	require.NoError(NewCanonicalEncoderBytes(&expected, JSON).Encode(&message))
+
+	ep := NewEncoderPoolWithOptions(&PoolOptions{Canonical: true}, JSON)
+	for i := 0; i < 5; i++ {
+		var actual []byte
+		require.NoError(ep.EncodeBytes(&actual, &message))
+		assert.Equal(expected, actual)
+	}
+
+	assert.Contains(string(expected), `{"a":"1","b":"2","c":"3"}`)
+}
+
+func TestPoolOptions(t *testing.T) {
+	t.Run("Defaults", testPoolOptionsDefaults)
+	t.Run("Canonical", testPoolOptionsCanonical)
+	t.Run("Metrics", testPoolOptionsMetrics)
+	t.Run("AutoSize", testPoolOptionsAutoSize)
+}
+
+func TestMessagePool(t *testing.T) {
+	t.Run("GetPut", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			pool   = NewMessagePool(0)
+		)
+
+		assert.Equal(DefaultMaxPooledPayload, pool.maxPayloadCapacity)
+		pool.Put(nil)
+
+		for i := 0; i < 10; i++ {
+			m := pool.Get()
+			assert.Empty(m.Source)
+			assert.Empty(m.Payload)
+
+			m.Source = "test"
+			m.Payload = append(m.Payload, "payload"...)
+			pool.Put(m)
+		}
+	})
+
+	t.Run("LargePayload", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			pool   = NewMessagePool(4)
+			small  = &Message{Payload: make([]byte, 4)}
+			large  = &Message{Payload: make([]byte, 5)}
+		)
+
+		pool.Put(small)
+		assert.NotNil(small.Payload)
+		assert.Empty(small.Payload)
+
+		pool.Put(large)
+		assert.Nil(large.Payload)
+	})
+
+	t.Run("DecodeBytes", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			pool     = NewMessagePool(0)
+			decoders = NewDecoderPool(1, Msgpack)
+
+			first  = Message{Type: SimpleEventMessageType, Source: "first", Metadata: map[string]string{"a": "1"}, Payload: []byte("first payload")}
+			second = Message{Type: SimpleEventMessageType, Source: "second"}
+		)
+
+		m, err := pool.DecodeBytes(decoders, MustEncode(&first, Msgpack))
+		require.NoError(err)
+		assert.Equal(first, *m)
+		pool.Put(m)
+
+		// no state from a previously pooled message may leak into a later one
+		m, err = pool.DecodeBytes(decoders, MustEncode(&second, Msgpack))
+		require.NoError(err)
+		assert.Equal("second", m.Source)
+		assert.Empty(m.Metadata)
+		assert.Empty(m.Payload)
+		pool.Put(m)
+
+		m, err = pool.DecodeBytes(decoders, []byte{0xc1})
+		assert.Nil(m)
+		assert.Error(err)
+	})
+}
+
+func BenchmarkMessagePool(b *testing.B) {
+	var (
+		pool     = NewMessagePool(0)
+		decoders = NewDecoderPool(10, Msgpack)
+		encoded  = MustEncode(
+			&Message{
+				Type:        SimpleRequestResponseMessageType,
+				Source:      "dns:talaria.comcast.net",
+				Destination: "mac:112233445566",
+				Metadata:    map[string]string{"key": "value"},
+				Payload:     make([]byte, 1024),
+			},
+			Msgpack,
+		)
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, err := pool.DecodeBytes(decoders, encoded)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		pool.Put(m)
+	}
+}
+
 func BenchmarkWRP(b *testing.B) {
 	var (
 		require = require.New(b)
@@ -213,6 +423,10 @@ func BenchmarkWRP(b *testing.B) {
 			b.Run("Decoder", func(b *testing.B) {
 				benchmarkDecoder(b, f, encoded[f])
 			})
+
+			b.Run("ReusedDecoder", func(b *testing.B) {
+				benchmarkReusedDecoder(b, f, encoded[f])
+			})
 		})
 	}
 }
@@ -229,6 +443,7 @@ func benchmarkEncoderPool(b *testing.B, pool *EncoderPool, message *Message) {
 }
 
 func benchmarkDecoderPool(b *testing.B, pool *DecoderPool, data []byte) {
+	b.ReportAllocs()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			var message Message
@@ -255,6 +470,7 @@ func benchmarkEncoder(b *testing.B, format Format, message *Message) {
 }
 
 func benchmarkDecoder(b *testing.B, format Format, data []byte) {
+	b.ReportAllocs()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			var (
@@ -268,3 +484,21 @@ func benchmarkDecoder(b *testing.B, format Format, data []byte) {
 		}
 	})
 }
+
+// benchmarkReusedDecoder measures the steady state of a server that reuses both its decoder and its messages
+func benchmarkReusedDecoder(b *testing.B, format Format, data []byte) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var (
+			message Message
+			decoder = NewDecoderBytes(nil, format)
+		)
+
+		for pb.Next() {
+			decoder.ResetBytes(data)
+			if err := decoder.Decode(&message); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
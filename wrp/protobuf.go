@@ -0,0 +1,615 @@
+package wrp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// protobufFields maps each WRP field name onto its field number in the Message type of wrp.proto.
+// This table must be kept in sync with that schema.
+var protobufFields = map[string]uint64{
+	MsgTypeField:                 1,
+	SourceField:                  2,
+	DestinationField:             3,
+	TransactionUUIDField:         4,
+	ContentTypeField:             5,
+	AcceptField:                  6,
+	StatusField:                  7,
+	RequestDeliveryResponseField: 8,
+	HeadersField:                 9,
+	MetadataField:                10,
+	SpansField:                   11,
+	IncludeSpansField:            12,
+	PathField:                    13,
+	PayloadField:                 14,
+	ServiceNameField:             15,
+	URLField:                     16,
+	QualityOfServiceField:        17,
+}
+
+// protobuf wire types used by the WRP schema
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+var (
+	errProtobufTruncated = errors.New("Truncated protobuf WRP message")
+	errProtobufOverflow  = errors.New("Protobuf varint overflows 64 bits")
+)
+
+// msgTypeField is the field number of msg_type, which is always written even when zero.  This ensures that
+// every encoded message is nonempty and explicitly carries its type.
+const msgTypeField uint64 = 1
+
+// protobufField describes a single struct field that is mapped to the protobuf schema
+type protobufField struct {
+	index  []int
+	number uint64
+}
+
+// protobufCodec holds the protobuf mapping for a single struct type
+type protobufCodec struct {
+	fields   []protobufField
+	byNumber map[uint64][]int
+}
+
+var protobufCodecs sync.Map
+
+// protobufCodecFor returns the cached protobufCodec for a struct type, creating it if necessary.  Each struct field
+// with a wrp tag must correspond to a field in the protobuf schema.
+func protobufCodecFor(t reflect.Type) (*protobufCodec, error) {
+	if existing, ok := protobufCodecs.Load(t); ok {
+		return existing.(*protobufCodec), nil
+	}
+
+	pc := &protobufCodec{
+		byNumber: make(map[uint64][]int),
+	}
+
+	if err := pc.addFields(t, nil); err != nil {
+		return nil, err
+	}
+
+	existing, _ := protobufCodecs.LoadOrStore(t, pc)
+	return existing.(*protobufCodec), nil
+}
+
+// addFields maps each tagged field of the given struct type.  Untagged, embedded structs are flattened
+// into the enclosing struct, which matches how the codec formats treat them.
+func (pc *protobufCodec) addFields(t reflect.Type, prefix []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			field = t.Field(i)
+			tag   = field.Tag.Get("wrp")
+			index = append(append([]int(nil), prefix...), i)
+		)
+
+		if len(tag) == 0 && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := pc.addFields(field.Type, index); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		number, ok := protobufFields[name]
+		if !ok {
+			return fmt.Errorf("The WRP field %s of %s has no protobuf mapping", name, t)
+		}
+
+		pc.fields = append(pc.fields, protobufField{index: index, number: number})
+		pc.byNumber[number] = index
+	}
+
+	return nil
+}
+
+// structValue dereferences v until it reaches a struct
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("Cannot use a nil %T with the protobuf WRP format", v)
+		}
+
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("The protobuf WRP format does not support %T", v)
+	}
+
+	return rv, nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(b, byte(v))
+}
+
+func appendKey(b []byte, number uint64, wireType uint64) []byte {
+	return appendVarint(b, number<<3|wireType)
+}
+
+func appendBytesField(b []byte, number uint64, value []byte) []byte {
+	b = appendKey(b, number, wireBytes)
+	b = appendVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendStringField(b []byte, number uint64, value string) []byte {
+	b = appendKey(b, number, wireBytes)
+	b = appendVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendBool(b []byte, number uint64, value bool) []byte {
+	b = appendKey(b, number, wireVarint)
+	if value {
+		return append(b, 1)
+	}
+
+	return append(b, 0)
+}
+
+// appendProtobufField appends the wire representation of a single struct field.  Zero values of non-pointer
+// fields are omitted, as in proto3.  Pointer fields are written whenever they are non-nil.
+func appendProtobufField(b []byte, number uint64, fv reflect.Value) ([]byte, error) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Int() != 0 {
+			b = appendKey(b, number, wireVarint)
+			b = appendVarint(b, uint64(fv.Int()))
+		}
+
+	case reflect.Bool:
+		if fv.Bool() {
+			b = appendBool(b, number, true)
+		}
+
+	case reflect.String:
+		if fv.Len() > 0 {
+			b = appendStringField(b, number, fv.String())
+		}
+
+	case reflect.Ptr:
+		if fv.IsNil() {
+			break
+		}
+
+		switch e := fv.Elem(); e.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			b = appendKey(b, number, wireVarint)
+			b = appendVarint(b, uint64(e.Int()))
+
+		case reflect.Bool:
+			b = appendBool(b, number, e.Bool())
+
+		default:
+			return nil, fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+		}
+
+	case reflect.Slice:
+		switch e := fv.Type().Elem(); {
+		case e.Kind() == reflect.Uint8:
+			if fv.Len() > 0 {
+				b = appendBytesField(b, number, fv.Bytes())
+			}
+
+		case e.Kind() == reflect.String:
+			for i := 0; i < fv.Len(); i++ {
+				b = appendStringField(b, number, fv.Index(i).String())
+			}
+
+		case e.Kind() == reflect.Slice && e.Elem().Kind() == reflect.String:
+			// each element is a Span message
+			var span []byte
+			for i := 0; i < fv.Len(); i++ {
+				span = span[:0]
+				values := fv.Index(i)
+				for j := 0; j < values.Len(); j++ {
+					span = appendStringField(span, 1, values.Index(j).String())
+				}
+
+				b = appendBytesField(b, number, span)
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+		}
+
+	case reflect.Map:
+		if fv.Type().Key().Kind() != reflect.String || fv.Type().Elem().Kind() != reflect.String {
+			return nil, fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+		}
+
+		// sort the keys, so that the output is deterministic
+		keys := make([]string, 0, fv.Len())
+		for _, k := range fv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+
+		sort.Strings(keys)
+		var entry []byte
+		for _, k := range keys {
+			entry = appendStringField(entry[:0], 1, k)
+			entry = appendStringField(entry, 2, fv.MapIndex(reflect.ValueOf(k).Convert(fv.Type().Key())).String())
+			b = appendBytesField(b, number, entry)
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+	}
+
+	return b, nil
+}
+
+// marshalProtobuf produces the protobuf encoding of a WRP message struct, appending it to b
+func marshalProtobuf(b []byte, v interface{}) ([]byte, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := protobufCodecFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range pc.fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.number == msgTypeField && fv.Kind() == reflect.Int64 && fv.Int() == 0 {
+			b = appendVarint(appendKey(b, f.number, wireVarint), 0)
+			continue
+		}
+
+		if b, err = appendProtobufField(b, f.number, fv); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// protobufReader parses the protobuf wire format
+type protobufReader struct {
+	data []byte
+}
+
+func (pr *protobufReader) done() bool {
+	return len(pr.data) == 0
+}
+
+func (pr *protobufReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift >= 64 {
+			return 0, errProtobufOverflow
+		}
+
+		if len(pr.data) == 0 {
+			return 0, errProtobufTruncated
+		}
+
+		c := pr.data[0]
+		pr.data = pr.data[1:]
+		v |= uint64(c&0x7F) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+}
+
+func (pr *protobufReader) bytes() ([]byte, error) {
+	n, err := pr.varint()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(pr.data)) {
+		return nil, errProtobufTruncated
+	}
+
+	value := pr.data[:n]
+	pr.data = pr.data[n:]
+	return value, nil
+}
+
+// skip discards a field value of the given wire type
+func (pr *protobufReader) skip(wireType uint64) error {
+	switch wireType {
+	case wireVarint:
+		_, err := pr.varint()
+		return err
+
+	case 1:
+		if len(pr.data) < 8 {
+			return errProtobufTruncated
+		}
+
+		pr.data = pr.data[8:]
+		return nil
+
+	case wireBytes:
+		_, err := pr.bytes()
+		return err
+
+	case 5:
+		if len(pr.data) < 4 {
+			return errProtobufTruncated
+		}
+
+		pr.data = pr.data[4:]
+		return nil
+
+	default:
+		return fmt.Errorf("Unsupported protobuf wire type: %d", wireType)
+	}
+}
+
+// key reads the next field key, returning the field number and wire type
+func (pr *protobufReader) key() (uint64, uint64, error) {
+	k, err := pr.varint()
+	return k >> 3, k & 0x07, err
+}
+
+// stringsOf parses a nested message consisting of strings, returning the value of each field in order.
+// This handles both Span messages and map entries.
+func stringsOf(data []byte) (map[uint64][]string, error) {
+	var (
+		pr     = protobufReader{data}
+		values = make(map[uint64][]string, 2)
+	)
+
+	for !pr.done() {
+		number, wireType, err := pr.key()
+		if err != nil {
+			return nil, err
+		}
+
+		if wireType != wireBytes {
+			if err := pr.skip(wireType); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		value, err := pr.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		values[number] = append(values[number], string(value))
+	}
+
+	return values, nil
+}
+
+// setProtobufField decodes a single field value into a struct field
+func setProtobufField(pr *protobufReader, wireType uint64, fv reflect.Value) error {
+	target := fv
+	if fv.Kind() == reflect.Ptr {
+		target = reflect.New(fv.Type().Elem()).Elem()
+	}
+
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Bool:
+		if wireType != wireVarint {
+			return fmt.Errorf("Unexpected protobuf wire type %d for %s", wireType, fv.Type())
+		}
+
+		v, err := pr.varint()
+		if err != nil {
+			return err
+		}
+
+		if target.Kind() == reflect.Bool {
+			target.SetBool(v != 0)
+		} else {
+			target.SetInt(int64(v))
+		}
+
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(target.Addr())
+		}
+
+		return nil
+	}
+
+	if wireType != wireBytes {
+		return fmt.Errorf("Unexpected protobuf wire type %d for %s", wireType, fv.Type())
+	}
+
+	data, err := pr.bytes()
+	if err != nil {
+		return err
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(string(data))
+
+	case reflect.Slice:
+		switch e := fv.Type().Elem(); {
+		case e.Kind() == reflect.Uint8:
+			fv.SetBytes(append([]byte(nil), data...))
+
+		case e.Kind() == reflect.String:
+			fv.Set(reflect.Append(fv, reflect.ValueOf(string(data)).Convert(e)))
+
+		case e.Kind() == reflect.Slice && e.Elem().Kind() == reflect.String:
+			values, err := stringsOf(data)
+			if err != nil {
+				return err
+			}
+
+			span := reflect.MakeSlice(e, 0, len(values[1]))
+			for _, v := range values[1] {
+				span = reflect.Append(span, reflect.ValueOf(v).Convert(e.Elem()))
+			}
+
+			fv.Set(reflect.Append(fv, span))
+
+		default:
+			return fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+		}
+
+	case reflect.Map:
+		values, err := stringsOf(data)
+		if err != nil {
+			return err
+		}
+
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+
+		var key, value string
+		if k := values[1]; len(k) > 0 {
+			key = k[len(k)-1]
+		}
+
+		if v := values[2]; len(v) > 0 {
+			value = v[len(v)-1]
+		}
+
+		fv.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()), reflect.ValueOf(value).Convert(fv.Type().Elem()))
+
+	default:
+		return fmt.Errorf("Unsupported protobuf field type: %s", fv.Type())
+	}
+
+	return nil
+}
+
+// unmarshalProtobuf decodes protobuf data into a WRP message struct.  Fields that do not exist in
+// the target struct are skipped.
+func unmarshalProtobuf(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("The protobuf WRP format requires a non-nil pointer, not %T", v)
+	}
+
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	pc, err := protobufCodecFor(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	pr := protobufReader{data}
+	for !pr.done() {
+		number, wireType, err := pr.key()
+		if err != nil {
+			return err
+		}
+
+		index, ok := pc.byNumber[number]
+		if !ok {
+			if err := pr.skip(wireType); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := setProtobufField(&pr, wireType, rv.FieldByIndex(index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// protobufEncoder is the Encoder implementation for the Protobuf format
+type protobufEncoder struct {
+	output      io.Writer
+	outputBytes *[]byte
+	buffer      []byte
+}
+
+func (pe *protobufEncoder) Encode(value interface{}) error {
+	if listener, ok := value.(EncodeListener); ok {
+		if err := listener.BeforeEncode(); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := marshalProtobuf(pe.buffer[:0], value)
+	if err != nil {
+		return err
+	}
+
+	pe.buffer = encoded
+	if pe.outputBytes != nil {
+		*pe.outputBytes = append((*pe.outputBytes)[:0], encoded...)
+		return nil
+	}
+
+	if pe.output == nil {
+		return errors.New("No output configured for the protobuf encoder")
+	}
+
+	_, err = pe.output.Write(encoded)
+	return err
+}
+
+func (pe *protobufEncoder) Reset(output io.Writer) {
+	pe.output = output
+	pe.outputBytes = nil
+}
+
+func (pe *protobufEncoder) ResetBytes(output *[]byte) {
+	pe.output = nil
+	pe.outputBytes = output
+}
+
+// protobufDecoder is the Decoder implementation for the Protobuf format.  Since protobuf messages
+// are not self-delimiting, each call to Decode consumes the remainder of the input.
+type protobufDecoder struct {
+	input      io.Reader
+	inputBytes []byte
+}
+
+func (pd *protobufDecoder) Decode(value interface{}) error {
+	data := pd.inputBytes
+	if pd.input != nil {
+		var err error
+		if data, err = ioutil.ReadAll(pd.input); err != nil {
+			return err
+		}
+	}
+
+	pd.inputBytes = nil
+	return unmarshalProtobuf(data, value)
+}
+
+func (pd *protobufDecoder) Reset(input io.Reader) {
+	pd.input = input
+	pd.inputBytes = nil
+}
+
+func (pd *protobufDecoder) ResetBytes(input []byte) {
+	pd.input = nil
+	pd.inputBytes = input
+}
@@ -0,0 +1,158 @@
+package wrp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtobufWireFormat(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		status  int64 = 0
+		message       = Message{
+			Type:     SimpleRequestResponseMessageType,
+			Source:   "a",
+			Status:   &status,
+			Metadata: map[string]string{"k": "v"},
+			Spans:    [][]string{{"x"}},
+		}
+
+		expected = []byte{
+			0x08, 0x03,
+			0x12, 0x01, 'a',
+			0x38, 0x00,
+			0x52, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, 'v',
+			0x5a, 0x03, 0x0a, 0x01, 'x',
+		}
+
+		actual []byte
+	)
+
+	require.NoError(NewEncoderBytes(&actual, Protobuf).Encode(&message))
+	assert.Equal(expected, actual)
+
+	var decoded Message
+	require.NoError(NewDecoderBytes(expected, Protobuf).Decode(&decoded))
+	assert.Equal(message, decoded)
+}
+
+func TestProtobufMsgTypeAlwaysWritten(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		actual  []byte
+	)
+
+	require.NoError(NewEncoderBytes(&actual, Protobuf).Encode(new(Message)))
+	assert.Equal([]byte{0x08, 0x00}, actual)
+}
+
+func TestProtobufUnknownFields(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// source and dest, surrounded by unknown fields of each wire type
+		encoded = []byte{
+			0xf8, 0x01, 0x96, 0x01, // field 31, varint
+			0x12, 0x01, 'a',
+			0x81, 0x02, 1, 2, 3, 4, 5, 6, 7, 8, // field 32, fixed64
+			0x8a, 0x02, 0x02, 'z', 'z', // field 33, bytes
+			0x1a, 0x01, 'b',
+			0x8d, 0x02, 1, 2, 3, 4, // field 33, fixed32
+		}
+
+		decoded Message
+	)
+
+	require.NoError(NewDecoderBytes(encoded, Protobuf).Decode(&decoded))
+	assert.Equal(Message{Source: "a", Destination: "b"}, decoded)
+
+	// fields which the target struct does not have are also skipped
+	var event SimpleEvent
+	require.NoError(NewDecoderBytes(MustEncode(&Message{Type: SimpleEventMessageType, Source: "a", TransactionUUID: "123"}, Protobuf), Protobuf).Decode(&event))
+	assert.Equal(SimpleEvent{Type: SimpleEventMessageType, Source: "a"}, event)
+}
+
+func TestProtobufDecodeErrors(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		testData = [][]byte{
+			{0x12},            // truncated length
+			{0x12, 0x05, 'a'}, // truncated string
+			{0x08},            // truncated varint
+			{0x08, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, // overflow
+			{0x0a, 0x00},             // wrong wire type for msg_type
+			{0x10, 0x01},             // wrong wire type for source
+			{0xfb, 0x01},             // unsupported wire type
+			{0xf9, 0x01, 1, 2, 3},    // truncated fixed64
+			{0xfd, 0x01, 1},          // truncated fixed32
+			{0x5a, 0x02, 0x0a, 0x05}, // truncated span
+		}
+	)
+
+	for _, encoded := range testData {
+		var decoded Message
+		assert.Error(NewDecoderBytes(encoded, Protobuf).Decode(&decoded), "%x", encoded)
+	}
+}
+
+func TestProtobufUnsupportedTypes(t *testing.T) {
+	type unmapped struct {
+		Unknown string `wrp:"no_such_field"`
+	}
+
+	var (
+		assert  = assert.New(t)
+		encoder = NewEncoder(new(bytes.Buffer), Protobuf)
+		decoder = NewDecoderBytes([]byte{0x08, 0x03}, Protobuf)
+		message *Message
+	)
+
+	assert.Error(encoder.Encode("not a struct"))
+	assert.Error(encoder.Encode(message))
+	assert.Error(encoder.Encode(&unmapped{}))
+
+	assert.Error(decoder.Decode(Message{}))
+	assert.Error(decoder.Decode(message))
+
+	decoder.ResetBytes([]byte{0x08, 0x03})
+	assert.Error(decoder.Decode(&unmapped{}))
+
+	assert.Error(NewEncoder(nil, Protobuf).Encode(new(Message)))
+}
+
+func TestProtobufReset(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output  bytes.Buffer
+		encoded []byte
+		encoder = NewEncoderBytes(&encoded, Protobuf)
+	)
+
+	require.NoError(encoder.Encode(&Message{Source: "first"}))
+	encoder.Reset(&output)
+	require.NoError(encoder.Encode(&Message{Source: "second"}))
+	encoder.ResetBytes(&encoded)
+	require.NoError(encoder.Encode(&Message{Source: "third"}))
+
+	var (
+		decoded Message
+		decoder = NewDecoder(&output, Protobuf)
+	)
+
+	require.NoError(decoder.Decode(&decoded))
+	assert.Equal("second", decoded.Source)
+
+	decoder.ResetBytes(encoded)
+	decoded = Message{}
+	require.NoError(decoder.Decode(&decoded))
+	assert.Equal("third", decoded.Source)
+}
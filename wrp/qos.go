@@ -0,0 +1,55 @@
+package wrp
+
+//go:generate stringer -type=QOSLevel
+
+// QOSValue is the value of the WRP qos field, which ranges from 0 to 99.  Higher values indicate higher priority.
+// Values outside that range are clamped when classified into a QOSLevel.
+type QOSValue int
+
+const (
+	// QOSLowValue is the lowest value in the QOSLow band, and is the default for messages that have no qos field
+	QOSLowValue QOSValue = 0
+
+	// QOSMediumValue is the lowest value in the QOSMedium band
+	QOSMediumValue QOSValue = 25
+
+	// QOSHighValue is the lowest value in the QOSHigh band
+	QOSHighValue QOSValue = 50
+
+	// QOSCriticalValue is the lowest value in the QOSCritical band
+	QOSCriticalValue QOSValue = 75
+
+	// QOSMaxValue is the highest QOS value allowed by the WRP spec
+	QOSMaxValue QOSValue = 99
+)
+
+// QOSLevel is a priority band which groups QOSValues.  Infrastructure uses the level, rather than the
+// exact value, to decide how messages are queued and shed.
+type QOSLevel int
+
+const (
+	QOSLow QOSLevel = iota
+	QOSMedium
+	QOSHigh
+	QOSCritical
+)
+
+// Level classifies this value into its priority band.  Negative values are treated as QOSLow, and
+// values above QOSMaxValue are treated as QOSCritical.
+func (qv QOSValue) Level() QOSLevel {
+	switch {
+	case qv < QOSMediumValue:
+		return QOSLow
+	case qv < QOSHighValue:
+		return QOSMedium
+	case qv < QOSCriticalValue:
+		return QOSHigh
+	default:
+		return QOSCritical
+	}
+}
+
+// QOSLevels returns a distinct slice of all the QOS levels, ordered from lowest to highest priority
+func QOSLevels() []QOSLevel {
+	return []QOSLevel{QOSLow, QOSMedium, QOSHigh, QOSCritical}
+}
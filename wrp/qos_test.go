@@ -0,0 +1,48 @@
+package wrp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQOSValueLevel(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		testData = []struct {
+			value    QOSValue
+			expected QOSLevel
+		}{
+			{-1, QOSLow},
+			{QOSLowValue, QOSLow},
+			{24, QOSLow},
+			{QOSMediumValue, QOSMedium},
+			{49, QOSMedium},
+			{QOSHighValue, QOSHigh},
+			{74, QOSHigh},
+			{QOSCriticalValue, QOSCritical},
+			{QOSMaxValue, QOSCritical},
+			{1000, QOSCritical},
+		}
+	)
+
+	for _, record := range testData {
+		assert.Equal(record.expected, record.value.Level(), "%d", record.value)
+	}
+}
+
+func TestQOSLevelString(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		strings = make(map[string]bool)
+	)
+
+	for _, level := range append(QOSLevels(), QOSLevel(-1), QOSLevel(4)) {
+		s := level.String()
+		assert.NotEmpty(s)
+		assert.NotContains(strings, s)
+		strings[s] = true
+	}
+
+	assert.Equal("QOSCritical", QOSCritical.String())
+}
@@ -0,0 +1,125 @@
+package wrp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoRoute is returned by the default handler of a Router when no pattern matches a message's destination
+var ErrNoRoute = errors.New("No WRP handler matches the destination")
+
+// Handler processes decoded WRP messages.  The returned message is the response, which may be nil for
+// message types that do not expect a response, such as events.
+type Handler interface {
+	ServeWRP(context.Context, *Message) (*Message, error)
+}
+
+// HandlerFunc is a function type that implements Handler
+type HandlerFunc func(context.Context, *Message) (*Message, error)
+
+func (hf HandlerFunc) ServeWRP(ctx context.Context, m *Message) (*Message, error) {
+	return hf(ctx, m)
+}
+
+// noRoute is the default Handler used when a Router has no configured default
+func noRoute(context.Context, *Message) (*Message, error) {
+	return nil, ErrNoRoute
+}
+
+// route associates a pattern with its Handler
+type route struct {
+	pattern string
+	handler Handler
+}
+
+// Router dispatches WRP messages to handlers by destination, in a manner analogous to http.ServeMux.  Patterns
+// are matched against the service and path portion of the destination, i.e. everything after the scheme and authority.
+// A pattern is either a service name, such as "config", or a service name followed by a path prefix, such as "config/wifi".
+// A pattern matches a destination whose service path is equal to the pattern or begins with the pattern followed by a slash,
+// so "config" matches "mac:112233445566/config" and "mac:112233445566/config/wifi" but not "mac:112233445566/configuration".
+// When several patterns match, the longest one wins.  Messages that match no pattern, or whose destination is not a valid
+// Locator, are sent to the default handler.
+//
+// A Router is itself a Handler, and is safe for concurrent use.
+type Router struct {
+	lock           sync.RWMutex
+	routes         []route
+	defaultHandler Handler
+}
+
+// NewRouter creates an empty Router with the given default handler.  If defaultHandler is nil, messages that match
+// no pattern fail with ErrNoRoute.
+func NewRouter(defaultHandler Handler) *Router {
+	if defaultHandler == nil {
+		defaultHandler = HandlerFunc(noRoute)
+	}
+
+	return &Router{
+		defaultHandler: defaultHandler,
+	}
+}
+
+// Handle registers the handler for the given pattern.  Leading and trailing slashes in the pattern are ignored.
+// This method panics if the pattern is empty, the handler is nil, or a handler is already registered for the pattern.
+func (r *Router) Handle(pattern string, handler Handler) {
+	pattern = strings.Trim(pattern, "/")
+	if len(pattern) == 0 {
+		panic("Router patterns cannot be empty")
+	}
+
+	if handler == nil {
+		panic("Router handlers cannot be nil")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, existing := range r.routes {
+		if existing.pattern == pattern {
+			panic("Multiple Router registrations for " + pattern)
+		}
+	}
+
+	r.routes = append(r.routes, route{pattern, handler})
+
+	// keep the longest patterns first, so that the first match is the most specific
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return len(r.routes[i].pattern) > len(r.routes[j].pattern)
+	})
+}
+
+// HandleFunc registers the handler function for the given pattern
+func (r *Router) HandleFunc(pattern string, handler func(context.Context, *Message) (*Message, error)) {
+	r.Handle(pattern, HandlerFunc(handler))
+}
+
+// Handler returns the handler to use for the given message, along with the matching pattern.  If no pattern
+// matches, the default handler and an empty pattern are returned.  This method never returns a nil Handler.
+func (r *Router) Handler(m *Message) (Handler, string) {
+	l, err := ParseLocator(m.Destination)
+	if err != nil {
+		return r.defaultHandler, ""
+	}
+
+	path := l.ServicePath()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, candidate := range r.routes {
+		if strings.HasPrefix(path, candidate.pattern) &&
+			(len(path) == len(candidate.pattern) || path[len(candidate.pattern)] == '/') {
+			return candidate.handler, candidate.pattern
+		}
+	}
+
+	return r.defaultHandler, ""
+}
+
+// ServeWRP dispatches the message to the handler whose pattern most closely matches its destination
+func (r *Router) ServeWRP(ctx context.Context, m *Message) (*Message, error) {
+	handler, _ := r.Handler(m)
+	return handler.ServeWRP(ctx, m)
+}
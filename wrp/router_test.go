@@ -0,0 +1,112 @@
+package wrp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedHandler produces a Handler that responds with a message whose source is the given name
+func namedHandler(name string) Handler {
+	return HandlerFunc(func(_ context.Context, m *Message) (*Message, error) {
+		return &Message{Source: name, Destination: m.Source}, nil
+	})
+}
+
+func testRouterDispatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		router  = NewRouter(namedHandler("default"))
+	)
+
+	router.Handle("config", namedHandler("config"))
+	router.Handle("/config/wifi/", namedHandler("wifi"))
+	router.HandleFunc("iot", namedHandler("iot").ServeWRP)
+
+	testData := []struct {
+		destination     string
+		expectedPattern string
+		expectedHandler string
+	}{
+		{"", "", "default"},
+		{"invalid", "", "default"},
+		{"mac:invalid/config", "", "default"},
+		{"mac:112233445566", "", "default"},
+		{"mac:112233445566/unknown", "", "default"},
+		{"mac:112233445566/configuration", "", "default"},
+		{"mac:112233445566/config", "config", "config"},
+		{"mac:112233445566/config/", "config", "config"},
+		{"mac:112233445566/config/ethernet", "config", "config"},
+		{"mac:112233445566/config/wifi", "config/wifi", "wifi"},
+		{"mac:112233445566/config/wifi/ssid", "config/wifi", "wifi"},
+		{"mac:112233445566/config/wifissid", "config", "config"},
+		{"dns:talaria.comcast.net/iot/some/path", "iot", "iot"},
+		{"MAC:11-22-33-44-55-66//config//wifi", "config/wifi", "wifi"},
+	}
+
+	for _, record := range testData {
+		message := &Message{Source: "test", Destination: record.destination}
+
+		handler, pattern := router.Handler(message)
+		assert.NotNil(handler)
+		assert.Equal(record.expectedPattern, pattern, record.destination)
+
+		response, err := router.ServeWRP(context.Background(), message)
+		require.NoError(err)
+		require.NotNil(response)
+		assert.Equal(record.expectedHandler, response.Source, record.destination)
+		assert.Equal("test", response.Destination)
+	}
+}
+
+func testRouterNoDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		router = NewRouter(nil)
+	)
+
+	response, err := router.ServeWRP(context.Background(), &Message{Destination: "mac:112233445566/config"})
+	assert.Nil(response)
+	assert.Equal(ErrNoRoute, err)
+}
+
+func testRouterHandlerError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		router        = NewRouter(nil)
+	)
+
+	router.HandleFunc("config", func(context.Context, *Message) (*Message, error) {
+		return nil, expectedError
+	})
+
+	response, err := router.ServeWRP(context.Background(), &Message{Destination: "mac:112233445566/config"})
+	assert.Nil(response)
+	assert.Equal(expectedError, err)
+}
+
+func testRouterInvalidRegistrations(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		router = NewRouter(nil)
+	)
+
+	router.Handle("config", namedHandler("config"))
+
+	assert.Panics(func() { router.Handle("", namedHandler("empty")) })
+	assert.Panics(func() { router.Handle("/", namedHandler("empty")) })
+	assert.Panics(func() { router.Handle("iot", nil) })
+	assert.Panics(func() { router.Handle("/config/", namedHandler("duplicate")) })
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("Dispatch", testRouterDispatch)
+	t.Run("NoDefault", testRouterNoDefault)
+	t.Run("HandlerError", testRouterHandlerError)
+	t.Run("InvalidRegistrations", testRouterInvalidRegistrations)
+}
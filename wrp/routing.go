@@ -0,0 +1,302 @@
+package wrp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// RoutingHeader holds only the WRP fields needed to route a message.  Routers that forward messages without
+// inspecting their contents can use DecodeRoutingHeader to obtain these fields without decoding the rest of
+// the message, in particular without materializing the payload.
+type RoutingHeader struct {
+	Type            MessageType `wrp:"msg_type"`
+	Source          string      `wrp:"source,omitempty"`
+	Destination     string      `wrp:"dest,omitempty"`
+	TransactionUUID string      `wrp:"transaction_uuid,omitempty"`
+}
+
+func (rh *RoutingHeader) MessageType() MessageType {
+	return rh.Type
+}
+
+func (rh *RoutingHeader) To() string {
+	return rh.Destination
+}
+
+func (rh *RoutingHeader) From() string {
+	return rh.Source
+}
+
+func (rh *RoutingHeader) IsTransactionPart() bool {
+	return rh.Type.SupportsTransaction() && len(rh.TransactionUUID) > 0
+}
+
+func (rh *RoutingHeader) TransactionKey() string {
+	return rh.TransactionUUID
+}
+
+// DecodeRoutingHeader extracts the routing fields from an encoded WRP message.  For the Msgpack format, the
+// message is scanned in place: the values of other fields, including the payload, are skipped without being
+// decoded or copied.  Other formats use their ordinary decoders, which likewise skip fields absent from RoutingHeader.
+//
+// The given RoutingHeader is reset before decoding, so it may be reused across calls.
+func DecodeRoutingHeader(contents []byte, f Format, rh *RoutingHeader) error {
+	*rh = RoutingHeader{}
+	if f == Msgpack {
+		return scanMsgpackRoutingHeader(contents, rh)
+	}
+
+	return NewDecoderBytes(contents, f).Decode(rh)
+}
+
+// msgpackScanner reads msgpack values from a byte slice without copying them
+type msgpackScanner struct {
+	data []byte
+	pos  int
+}
+
+func (ms *msgpackScanner) next(n int) ([]byte, error) {
+	if n < 0 || len(ms.data)-ms.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := ms.data[ms.pos : ms.pos+n]
+	ms.pos += n
+	return b, nil
+}
+
+func (ms *msgpackScanner) readByte() (byte, error) {
+	b, err := ms.next(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// length reads the big-endian length or count of the given width
+func (ms *msgpackScanner) length(width int) (int, error) {
+	b, err := ms.next(width)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+
+	if n > uint64(len(ms.data)) {
+		// no length can exceed the data, and this check prevents overflow on 32-bit platforms
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return int(n), nil
+}
+
+// mapHeader reads the header of a map, returning its number of entries
+func (ms *msgpackScanner) mapHeader() (int, error) {
+	t, err := ms.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case t >= 0x80 && t <= 0x8f:
+		return int(t & 0x0f), nil
+	case t == 0xde:
+		return ms.length(2)
+	case t == 0xdf:
+		return ms.length(4)
+	}
+
+	return 0, ErrNotAMap
+}
+
+// str reads a string value, which may be encoded as either a msgpack str or bin.  A nil value produces an empty string.
+func (ms *msgpackScanner) str() (string, error) {
+	b, err := ms.strBytes()
+	return string(b), err
+}
+
+// strBytes reads a string value just as str does, returning the string's bytes from the scanned data without copying them
+func (ms *msgpackScanner) strBytes() ([]byte, error) {
+	t, err := ms.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var length int
+	switch {
+	case t == 0xc0:
+		return nil, nil
+	case t >= 0xa0 && t <= 0xbf:
+		length = int(t & 0x1f)
+	case t == 0xc4, t == 0xd9:
+		length, err = ms.length(1)
+	case t == 0xc5, t == 0xda:
+		length, err = ms.length(2)
+	case t == 0xc6, t == 0xdb:
+		length, err = ms.length(4)
+	default:
+		return nil, fmt.Errorf("Invalid msgpack type for a string: 0x%x", t)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ms.next(length)
+}
+
+// integer reads an integer value.  A nil value produces zero.
+func (ms *msgpackScanner) integer() (int64, error) {
+	t, err := ms.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t == 0xc0:
+		return 0, nil
+	case t >= 0xcc && t <= 0xd3:
+		width := 1 << ((t - 0xcc) % 4)
+		b, err := ms.next(width)
+		if err != nil {
+			return 0, err
+		}
+
+		var v [8]byte
+		copy(v[8-width:], b)
+		u := binary.BigEndian.Uint64(v[:])
+		if t >= 0xd0 {
+			// sign extend the signed types
+			shift := uint(64 - 8*width)
+			return int64(u<<shift) >> shift, nil
+		}
+
+		return int64(u), nil
+	}
+
+	return 0, fmt.Errorf("Invalid msgpack type for an integer: 0x%x", t)
+}
+
+// skip advances past one complete value
+func (ms *msgpackScanner) skip() error {
+	t, err := ms.readByte()
+	if err != nil {
+		return err
+	}
+
+	var (
+		length   int
+		elements int
+	)
+
+	switch {
+	case t <= 0x7f, t >= 0xe0, t == 0xc0, t == 0xc2, t == 0xc3:
+		return nil
+	case t >= 0x80 && t <= 0x8f:
+		elements = int(t&0x0f) * 2
+	case t >= 0x90 && t <= 0x9f:
+		elements = int(t & 0x0f)
+	case t >= 0xa0 && t <= 0xbf:
+		length = int(t & 0x1f)
+	case t == 0xc4, t == 0xd9:
+		length, err = ms.length(1)
+	case t == 0xc5, t == 0xda:
+		length, err = ms.length(2)
+	case t == 0xc6, t == 0xdb:
+		length, err = ms.length(4)
+	case t == 0xc7:
+		length, err = ms.length(1)
+		length++
+	case t == 0xc8:
+		length, err = ms.length(2)
+		length++
+	case t == 0xc9:
+		length, err = ms.length(4)
+		length++
+	case t == 0xca, t == 0xce, t == 0xd2:
+		length = 4
+	case t == 0xcb, t == 0xcf, t == 0xd3:
+		length = 8
+	case t == 0xcc, t == 0xd0:
+		length = 1
+	case t == 0xcd, t == 0xd1:
+		length = 2
+	case t >= 0xd4 && t <= 0xd8:
+		length = 1 + (1 << (t - 0xd4))
+	case t == 0xdc:
+		elements, err = ms.length(2)
+	case t == 0xdd:
+		elements, err = ms.length(4)
+	case t == 0xde:
+		elements, err = ms.length(2)
+		elements *= 2
+	case t == 0xdf:
+		elements, err = ms.length(4)
+		elements *= 2
+	default:
+		return fmt.Errorf("Invalid msgpack type: 0x%x", t)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ms.next(length); err != nil {
+		return err
+	}
+
+	for i := 0; i < elements; i++ {
+		if err := ms.skip(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanMsgpackRoutingHeader is the Msgpack fast path for DecodeRoutingHeader
+func scanMsgpackRoutingHeader(contents []byte, rh *RoutingHeader) error {
+	ms := msgpackScanner{data: contents}
+	count, err := ms.mapHeader()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		key, err := ms.strBytes()
+		if err != nil {
+			return err
+		}
+
+		// switching on the converted bytes does not allocate
+		switch string(key) {
+		case MsgTypeField:
+			var v int64
+			v, err = ms.integer()
+			rh.Type = MessageType(v)
+		case SourceField:
+			rh.Source, err = ms.str()
+		case DestinationField:
+			rh.Destination, err = ms.str()
+		case TransactionUUIDField:
+			rh.TransactionUUID, err = ms.str()
+		default:
+			err = ms.skip()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
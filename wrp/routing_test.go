@@ -0,0 +1,222 @@
+package wrp
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRoutingHeaderRoutable(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		rh     = RoutingHeader{
+			Type:            SimpleRequestResponseMessageType,
+			Source:          "dns:talaria.comcast.net",
+			Destination:     "mac:112233445566",
+			TransactionUUID: "1234",
+		}
+	)
+
+	assert.Equal(SimpleRequestResponseMessageType, rh.MessageType())
+	assert.Equal("mac:112233445566", rh.To())
+	assert.Equal("dns:talaria.comcast.net", rh.From())
+	assert.Equal("1234", rh.TransactionKey())
+	assert.True(rh.IsTransactionPart())
+
+	rh.Type = SimpleEventMessageType
+	assert.False(rh.IsTransactionPart())
+}
+
+func testDecodeRoutingHeader(t *testing.T, f Format) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		status  int64 = 200
+
+		messages = []Message{
+			{},
+			{
+				Type:        SimpleEventMessageType,
+				Source:      "mac:112233445566",
+				Destination: "event:device-status",
+				Metadata:    map[string]string{"/trust": "1000"},
+				Payload:     []byte("an event payload"),
+			},
+			{
+				Type:            SimpleRequestResponseMessageType,
+				Source:          "dns:talaria.comcast.net",
+				Destination:     "mac:112233445566/config",
+				TransactionUUID: "DEADBEEF",
+				ContentType:     "application/json",
+				Status:          &status,
+				Headers:         []string{"Header1"},
+				Spans:           [][]string{{"span", "1", "2"}},
+				Payload:         make([]byte, 100000),
+				ServiceName:     "config",
+				URL:             "http://somewhere.comcast.net",
+			},
+		}
+
+		// the header is reset on each decode
+		actual = RoutingHeader{Source: "should be cleared"}
+	)
+
+	for _, message := range messages {
+		require.NoError(DecodeRoutingHeader(MustEncode(&message, f), f, &actual))
+		assert.Equal(
+			RoutingHeader{
+				Type:            message.Type,
+				Source:          message.Source,
+				Destination:     message.Destination,
+				TransactionUUID: message.TransactionUUID,
+			},
+			actual,
+		)
+	}
+}
+
+func testDecodeRoutingHeaderMsgpackTypes(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		testData = []struct {
+			encoded  []byte
+			expected RoutingHeader
+		}{
+			{
+				encoded:  []byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xcc, 0x04},
+				expected: RoutingHeader{Type: SimpleEventMessageType},
+			},
+			{
+				encoded:  []byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xd3, 0, 0, 0, 0, 0, 0, 0, 0x03},
+				expected: RoutingHeader{Type: SimpleRequestResponseMessageType},
+			},
+			{
+				encoded:  []byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xd1, 0xff, 0xff},
+				expected: RoutingHeader{Type: MessageType(-1)},
+			},
+			{
+				encoded:  []byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xff},
+				expected: RoutingHeader{Type: MessageType(-1)},
+			},
+			{
+				encoded:  []byte{0xde, 0x00, 0x02, 0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xc4, 0x01, 'a', 0xa4, 'd', 'e', 's', 't', 0xd9, 0x01, 'b'},
+				expected: RoutingHeader{Source: "a", Destination: "b"},
+			},
+			{
+				encoded:  []byte{0x81, 0xb0, 't', 'r', 'a', 'n', 's', 'a', 'c', 't', 'i', 'o', 'n', '_', 'u', 'u', 'i', 'd', 0xc0},
+				expected: RoutingHeader{},
+			},
+			{
+				// an unknown field holding one of each msgpack type, which must be skipped
+				encoded: []byte{
+					0x82,
+					0xa7, 'u', 'n', 'k', 'n', 'o', 'w', 'n',
+					0xdc, 0x00, 0x0f,
+					0x01, 0xff, 0xc0, 0xc3,
+					0xca, 0, 0, 0, 0,
+					0xcb, 0, 0, 0, 0, 0, 0, 0, 0,
+					0xcd, 0, 0,
+					0xd0, 0,
+					0xd4, 1, 0,
+					0xd8, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+					0xc7, 0x01, 1, 0,
+					0xda, 0x00, 0x01, 'x',
+					0xc6, 0x00, 0x00, 0x00, 0x01, 'x',
+					0x81, 0xa1, 'k', 0xa1, 'v',
+					0xdf, 0x00, 0x00, 0x00, 0x01, 0xa1, 'k', 0xa1, 'v',
+					0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xa1, 'a',
+				},
+				expected: RoutingHeader{Source: "a"},
+			},
+		}
+	)
+
+	for _, record := range testData {
+		var actual RoutingHeader
+		if assert.NoError(DecodeRoutingHeader(record.encoded, Msgpack, &actual), "%x", record.encoded) {
+			assert.Equal(record.expected, actual)
+		}
+	}
+}
+
+func testDecodeRoutingHeaderMsgpackErrors(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		testData = []struct {
+			encoded  []byte
+			expected error
+		}{
+			{[]byte{}, io.ErrUnexpectedEOF},
+			{[]byte{0x91, 0x01}, ErrNotAMap},
+			{[]byte{0xde, 0x00}, io.ErrUnexpectedEOF},
+			{[]byte{0x81}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0xa6, 's', 'o', 'u'}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xc6, 0xff, 0xff, 0xff, 0xff, 'a'}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0xa1, 'a', 0xc4, 0x05, 'x'}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0xa1, 'a', 0x92, 0x01}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xce, 0x00}, io.ErrUnexpectedEOF},
+			{[]byte{0x81, 0x01, 0x01}, nil},
+			{[]byte{0x81, 0xa1, 'a', 0xc1}, nil},
+			{[]byte{0x81, 0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0xa1, 'x'}, nil},
+			{[]byte{0x81, 0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0x01}, nil},
+		}
+	)
+
+	for _, record := range testData {
+		var actual RoutingHeader
+		err := DecodeRoutingHeader(record.encoded, Msgpack, &actual)
+		if record.expected != nil {
+			assert.Equal(record.expected, err, "%x", record.encoded)
+		} else {
+			assert.Error(err, "%x", record.encoded)
+		}
+	}
+}
+
+func TestDecodeRoutingHeader(t *testing.T) {
+	t.Run("Routable", testRoutingHeaderRoutable)
+
+	for _, f := range allFormats {
+		t.Run(fmt.Sprintf("Decode%s", f), func(t *testing.T) {
+			testDecodeRoutingHeader(t, f)
+		})
+	}
+
+	t.Run("MsgpackTypes", testDecodeRoutingHeaderMsgpackTypes)
+	t.Run("MsgpackErrors", testDecodeRoutingHeaderMsgpackErrors)
+}
+
+func BenchmarkDecodeRoutingHeader(b *testing.B) {
+	var (
+		message = Message{
+			Type:            SimpleRequestResponseMessageType,
+			Source:          "dns:talaria.comcast.net",
+			Destination:     "mac:112233445566/config",
+			TransactionUUID: "DEADBEEF",
+			Payload:         make([]byte, 4096),
+		}
+
+		encoded = MustEncode(&message, Msgpack)
+	)
+
+	b.Run("DecodeRoutingHeader", func(b *testing.B) {
+		var rh RoutingHeader
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			DecodeRoutingHeader(encoded, Msgpack, &rh)
+		}
+	})
+
+	b.Run("Message", func(b *testing.B) {
+		decoder := NewDecoderBytes(encoded, Msgpack)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var m Message
+			decoder.ResetBytes(encoded)
+			decoder.Decode(&m)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package wrp
+
+//go:generate go run ./tools/cmd/wrpschema -d schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSONSchemaVersion is the JSON Schema draft that the generated schemas conform to
+const JSONSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// schemaTypes maps each message type onto the struct that defines its fields
+var schemaTypes = map[MessageType]reflect.Type{
+	AuthorizationStatusMessageType:   reflect.TypeOf(AuthorizationStatus{}),
+	SimpleRequestResponseMessageType: reflect.TypeOf(SimpleRequestResponse{}),
+	SimpleEventMessageType:           reflect.TypeOf(SimpleEvent{}),
+	CreateMessageType:                reflect.TypeOf(Create{}),
+	RetrieveMessageType:              reflect.TypeOf(Retrieve{}),
+	UpdateMessageType:                reflect.TypeOf(Update{}),
+	DeleteMessageType:                reflect.TypeOf(Delete{}),
+	ServiceRegistrationMessageType:   reflect.TypeOf(ServiceRegistration{}),
+	ServiceAliveMessageType:          reflect.TypeOf(ServiceAlive{}),
+	UnknownMessageType:               reflect.TypeOf(Unknown{}),
+}
+
+// fieldDescriptions holds the human-readable description of each WRP field
+var fieldDescriptions = map[string]string{
+	MsgTypeField:                 "The WRP message type",
+	SourceField:                  "The locator of the message's originator",
+	DestinationField:             "The locator of the message's recipient",
+	TransactionUUIDField:         "The identifier which correlates a request with its response",
+	ContentTypeField:             "The media type of the payload",
+	AcceptField:                  "The media type expected in the payload of a response",
+	StatusField:                  "The status of the operation described by the message",
+	RequestDeliveryResponseField: "The result of delivering the request to its destination",
+	HeadersField:                 "Arbitrary headers, transmitted as is",
+	MetadataField:                "Arbitrary name/value pairs describing the message",
+	SpansField:                   "Tracing spans, each of which is a name, a start time, and a duration",
+	IncludeSpansField:            "Whether tracing spans are requested in the response",
+	PathField:                    "The path of the resource affected by a CRUD operation",
+	PayloadField:                 "The message payload, base64-encoded in JSON",
+	ServiceNameField:             "The name of the service being registered",
+	URLField:                     "The URL of the service being registered",
+	QualityOfServiceField:        "The priority of the message, from 0 to 99",
+}
+
+// JSONSchema produces the JSON Schema, as a generic JSON object, describing the JSON representation of
+// messages of the given type.  Fields that are not tagged with omitempty are required.
+func JSONSchema(mt MessageType) (map[string]interface{}, error) {
+	t, ok := schemaTypes[mt]
+	if !ok {
+		return nil, fmt.Errorf("No JSON schema exists for message type %d", mt)
+	}
+
+	var (
+		properties = make(map[string]interface{})
+		required   []string
+	)
+
+	schemaFields(t, mt, properties, &required)
+	schema := map[string]interface{}{
+		"$schema":    JSONSchemaVersion,
+		"title":      mt.FriendlyName(),
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema, nil
+}
+
+// MarshalJSONSchema produces the indented JSON text of the schema for messages of the given type
+func MarshalJSONSchema(mt MessageType) ([]byte, error) {
+	schema, err := JSONSchema(mt)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaFields adds the schema of each wrp-tagged field of a struct type, flattening embedded structs
+// in the same way that the encoders do
+func schemaFields(t reflect.Type, mt MessageType, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			schemaFields(field.Type, mt, properties, required)
+			continue
+		}
+
+		tag := field.Tag.Get("wrp")
+		if len(tag) == 0 {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		name := options[0]
+		property := propertySchema(field.Type)
+		property["description"] = fieldDescriptions[name]
+
+		switch name {
+		case MsgTypeField:
+			property["const"] = int64(mt)
+
+		case QualityOfServiceField:
+			property["minimum"] = int(QOSLowValue)
+			property["maximum"] = int(QOSMaxValue)
+		}
+
+		properties[name] = property
+		if len(options) < 2 || options[1] != "omitempty" {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// propertySchema produces the schema for the JSON representation of a given Go type
+func propertySchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return map[string]interface{}{"type": "array", "items": propertySchema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": propertySchema(t.Elem())}
+
+	default:
+		panic(fmt.Errorf("Unsupported WRP field type: %s", t))
+	}
+}
@@ -0,0 +1,104 @@
+package wrp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Run("SimpleEvent", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		schema, err := JSONSchema(SimpleEventMessageType)
+		require.NoError(err)
+		assert.Equal(JSONSchemaVersion, schema["$schema"])
+		assert.Equal("SimpleEvent", schema["title"])
+		assert.Equal("object", schema["type"])
+		assert.Equal([]string{MsgTypeField, SourceField, DestinationField}, schema["required"])
+
+		properties := schema["properties"].(map[string]interface{})
+		assert.Len(properties, 8)
+		assert.Equal(int64(SimpleEventMessageType), properties[MsgTypeField].(map[string]interface{})["const"])
+		assert.Equal("string", properties[SourceField].(map[string]interface{})["type"])
+		assert.Equal("base64", properties[PayloadField].(map[string]interface{})["contentEncoding"])
+		assert.Equal(
+			map[string]interface{}{"type": "string"},
+			properties[HeadersField].(map[string]interface{})["items"],
+		)
+
+		assert.Equal(
+			map[string]interface{}{"type": "string"},
+			properties[MetadataField].(map[string]interface{})["additionalProperties"],
+		)
+
+		assert.Equal(99, properties[QualityOfServiceField].(map[string]interface{})["maximum"])
+		assert.NotContains(properties, TransactionUUIDField)
+	})
+
+	t.Run("CRUD", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		for _, mt := range []MessageType{CreateMessageType, RetrieveMessageType, UpdateMessageType, DeleteMessageType} {
+			schema, err := JSONSchema(mt)
+			require.NoError(err)
+
+			properties := schema["properties"].(map[string]interface{})
+			assert.Equal(int64(mt), properties[MsgTypeField].(map[string]interface{})["const"])
+			assert.Equal("boolean", properties[IncludeSpansField].(map[string]interface{})["type"])
+			assert.Equal("integer", properties[StatusField].(map[string]interface{})["type"])
+			assert.Equal(
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				properties[SpansField].(map[string]interface{})["items"],
+			)
+
+			assert.Equal([]string{MsgTypeField, SourceField, DestinationField, PathField}, schema["required"])
+		}
+	})
+
+	t.Run("AllTypes", func(t *testing.T) {
+		assert := assert.New(t)
+		for mt := AuthorizationStatusMessageType; mt < lastMessageType; mt++ {
+			schema, err := MarshalJSONSchema(mt)
+			assert.NoError(err)
+
+			var decoded map[string]interface{}
+			assert.NoError(json.Unmarshal(schema, &decoded))
+			assert.Equal(mt.FriendlyName(), decoded["title"])
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		schema, err := JSONSchema(lastMessageType)
+		assert.Nil(schema)
+		assert.Error(err)
+
+		text, err := MarshalJSONSchema(MessageType(-1))
+		assert.Nil(text)
+		assert.Error(err)
+	})
+}
+
+func TestGeneratedSchemas(t *testing.T) {
+	assert := assert.New(t)
+	for mt := AuthorizationStatusMessageType; mt < lastMessageType; mt++ {
+		expected, err := MarshalJSONSchema(mt)
+		assert.NoError(err)
+
+		actual, err := ioutil.ReadFile(filepath.Join("schemas", mt.FriendlyName()+".json"))
+		assert.NoError(err)
+		assert.Equal(string(expected)+"\n", string(actual), "The schema for %s is out of date.  Run go generate.", mt)
+	}
+}
@@ -0,0 +1,111 @@
+package wrp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+)
+
+// SpanTimeLayout is the layout of the start time of each span carried in a WRP message
+const SpanTimeLayout = time.RFC3339Nano
+
+// EncodeSpan produces the WRP representation of a span, which is its name, its start time in UTC formatted
+// with SpanTimeLayout, and its duration formatted as by time.Duration.String.  A span's error is not carried by WRP.
+func EncodeSpan(s tracing.Span) []string {
+	return []string{
+		s.Name(),
+		s.Start().UTC().Format(SpanTimeLayout),
+		s.Duration().String(),
+	}
+}
+
+// DecodeSpan parses the WRP representation of a span, as produced by EncodeSpan
+func DecodeSpan(fields []string) (tracing.Span, error) {
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("Invalid WRP span: %v", fields)
+	}
+
+	start, err := time.Parse(SpanTimeLayout, fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid WRP span start time: %s", err)
+	}
+
+	duration, err := time.ParseDuration(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid WRP span duration: %s", err)
+	}
+
+	return &decodedSpan{
+		name:     fields[0],
+		start:    start,
+		duration: duration,
+	}, nil
+}
+
+// decodedSpan is the tracing.Span implementation for spans carried by WRP messages
+type decodedSpan struct {
+	name     string
+	start    time.Time
+	duration time.Duration
+}
+
+func (ds *decodedSpan) Name() string {
+	return ds.name
+}
+
+func (ds *decodedSpan) Start() time.Time {
+	return ds.start
+}
+
+func (ds *decodedSpan) Duration() time.Duration {
+	return ds.duration
+}
+
+func (ds *decodedSpan) Error() error {
+	return nil
+}
+
+// WantsSpans tests if the sender of this message asked for spans to be included in the response
+func (msg *Message) WantsSpans() bool {
+	return msg.IncludeSpans != nil && *msg.IncludeSpans
+}
+
+// AddSpans appends the WRP representation of each span to this message's Spans field
+func (msg *Message) AddSpans(spans ...tracing.Span) *Message {
+	for _, s := range spans {
+		msg.Spans = append(msg.Spans, EncodeSpan(s))
+	}
+
+	return msg
+}
+
+// TracingSpans decodes this message's Spans field.  If any span is invalid, an error is returned.
+func (msg *Message) TracingSpans() ([]tracing.Span, error) {
+	if len(msg.Spans) == 0 {
+		return nil, nil
+	}
+
+	spans := make([]tracing.Span, 0, len(msg.Spans))
+	for _, fields := range msg.Spans {
+		s, err := DecodeSpan(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		spans = append(spans, s)
+	}
+
+	return spans, nil
+}
+
+// IncludeSpans adds the given spans to a response when the request that produced it asked for them
+// via its include_spans field.  This function returns true if the spans were added.
+func IncludeSpans(request, response *Message, spans ...tracing.Span) bool {
+	if !request.WantsSpans() {
+		return false
+	}
+
+	response.AddSpans(spans...)
+	return true
+}
@@ -0,0 +1,135 @@
+package wrp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSpans(start time.Time, duration time.Duration) []tracing.Span {
+	spanner := tracing.NewSpanner(
+		tracing.Now(func() time.Time { return start }),
+		tracing.Since(func(time.Time) time.Duration { return duration }),
+	)
+
+	return []tracing.Span{
+		spanner.Start("first")(nil),
+		spanner.Start("second")(errors.New("errors are not carried")),
+	}
+}
+
+func TestEncodeSpan(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		start    = time.Date(2017, time.November, 14, 12, 30, 15, 123456789, time.FixedZone("EST", -5*60*60))
+		duration = 2342123 * time.Nanosecond
+	)
+
+	assert.Equal(
+		[]string{"first", "2017-11-14T17:30:15.123456789Z", "2.342123ms"},
+		EncodeSpan(testSpans(start, duration)[0]),
+	)
+}
+
+func TestDecodeSpan(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	s, err := DecodeSpan([]string{"first", "2017-11-14T17:30:15.123456789Z", "2.342123ms"})
+	require.NoError(err)
+	assert.Equal("first", s.Name())
+	assert.Equal(time.Date(2017, time.November, 14, 17, 30, 15, 123456789, time.UTC), s.Start())
+	assert.Equal(2342123*time.Nanosecond, s.Duration())
+	assert.NoError(s.Error())
+
+	for _, invalid := range [][]string{
+		nil,
+		{"first", "2017-11-14T17:30:15Z"},
+		{"first", "2017-11-14T17:30:15Z", "1ms", "extra"},
+		{"first", "not a time", "1ms"},
+		{"first", "2017-11-14T17:30:15Z", "not a duration"},
+	} {
+		s, err := DecodeSpan(invalid)
+		assert.Nil(s)
+		assert.Error(err)
+	}
+}
+
+func testMessageSpansRoundTrip(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		start    = time.Now()
+		duration = 15 * time.Millisecond
+		spans    = testSpans(start, duration)
+
+		request  = new(Message).SetIncludeSpans(true)
+		response = new(Message)
+		encoded  []byte
+		decoded  Message
+	)
+
+	require.True(IncludeSpans(request, response, spans...))
+	require.NoError(NewEncoderBytes(&encoded, f).Encode(response))
+	require.NoError(NewDecoderBytes(encoded, f).Decode(&decoded))
+
+	actual, err := decoded.TracingSpans()
+	require.NoError(err)
+	require.Len(actual, len(spans))
+	for i, s := range spans {
+		assert.Equal(s.Name(), actual[i].Name())
+		assert.True(s.Start().Equal(actual[i].Start()))
+		assert.Equal(s.Duration(), actual[i].Duration())
+	}
+}
+
+func TestMessageSpans(t *testing.T) {
+	t.Run("WantsSpans", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.False(new(Message).WantsSpans())
+		assert.False(new(Message).SetIncludeSpans(false).WantsSpans())
+		assert.True(new(Message).SetIncludeSpans(true).WantsSpans())
+	})
+
+	t.Run("IncludeSpans", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			spans    = testSpans(time.Now(), time.Second)
+			response = new(Message)
+		)
+
+		assert.False(IncludeSpans(new(Message), response, spans...))
+		assert.Empty(response.Spans)
+
+		assert.True(IncludeSpans(new(Message).SetIncludeSpans(true), response, spans...))
+		assert.Len(response.Spans, 2)
+	})
+
+	t.Run("TracingSpans", func(t *testing.T) {
+		assert := assert.New(t)
+
+		spans, err := new(Message).TracingSpans()
+		assert.Empty(spans)
+		assert.NoError(err)
+
+		spans, err = (&Message{Spans: [][]string{{"invalid"}}}).TracingSpans()
+		assert.Empty(spans)
+		assert.Error(err)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, f := range AllFormats() {
+			t.Run(f.String(), func(t *testing.T) {
+				testMessageSpansRoundTrip(t, f)
+			})
+		}
+	})
+}
@@ -0,0 +1,569 @@
+package wrp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	// ErrPayloadTooShort is returned by a StreamEncoder when the payload reader has fewer bytes
+	// than the size passed to Encode.
+	ErrPayloadTooShort = errors.New("The payload has fewer bytes than its declared size")
+
+	// ErrNotAMap is returned by a StreamDecoder when the encoded value is not a map, i.e. is not a WRP message
+	ErrNotAMap = errors.New("The encoded value is not a WRP message")
+)
+
+// StreamEncoder writes WRP messages whose payloads are supplied as an io.Reader.  For the Msgpack format,
+// the header fields are written first and the payload is then copied directly to the output, so the payload
+// is never held in memory.  Other formats buffer the payload and use an ordinary Encoder.
+type StreamEncoder interface {
+	// Encode writes the given header, followed by size bytes read from payload.  The header's Payload field is ignored.
+	// If size is negative, the payload is read until EOF in order to determine its size, which requires buffering it.
+	// If payload is nil or size is zero, the message has no payload.
+	Encode(header *Message, payload io.Reader, size int64) error
+
+	Reset(io.Writer)
+}
+
+// StreamDecoder reads WRP messages incrementally from an io.Reader, exposing each message's payload
+// as an io.Reader rather than a byte slice.  For the Msgpack format, when the payload is the last field of
+// a message, which is always the case for messages written by a StreamEncoder, the payload is read directly
+// from the underlying input as the caller consumes it.  Otherwise, the payload is buffered so that the remaining
+// fields can be decoded.
+//
+// The io.Reader returned by Decode is only valid until the next call to Decode or Reset.  Any unread portion
+// of a payload is discarded by the next call to Decode.
+type StreamDecoder interface {
+	// Decode reads the next message's fields into header and returns a reader for its payload.  The returned
+	// reader is never nil.  A message without a payload produces a reader that is immediately at EOF, and the
+	// header's Payload field is never set.
+	Decode(header *Message) (io.Reader, error)
+
+	Reset(io.Reader)
+}
+
+// NewStreamEncoder produces a StreamEncoder for the given format which writes to output
+func NewStreamEncoder(output io.Writer, f Format) StreamEncoder {
+	if f == Msgpack {
+		mse := &msgpackStreamEncoder{output: output}
+		mse.encoder = NewEncoderBytes(&mse.fields, Msgpack)
+		return mse
+	}
+
+	return &bufferedStreamEncoder{
+		encoder: NewEncoder(output, f),
+	}
+}
+
+// NewStreamDecoder produces a StreamDecoder for the given format which reads from input.  Protobuf messages
+// are not self-delimiting, so for that format the input must hold exactly one message.
+func NewStreamDecoder(input io.Reader, f Format) StreamDecoder {
+	if f == Msgpack {
+		return &msgpackStreamDecoder{
+			input:   bufio.NewReader(input),
+			decoder: NewDecoderBytes(nil, Msgpack),
+		}
+	}
+
+	if f == JSON {
+		// JSON values are separated by whitespace, which must be skipped to detect the end of the stream
+		buffered := bufio.NewReader(input)
+		return &bufferedStreamDecoder{
+			decoder: NewDecoder(buffered, f),
+			input:   buffered,
+		}
+	}
+
+	return &bufferedStreamDecoder{
+		decoder: NewDecoder(input, f),
+	}
+}
+
+// readPayload reads an entire payload into memory, enforcing the size if it is nonnegative
+func readPayload(payload io.Reader, size int64) ([]byte, error) {
+	if payload == nil || size == 0 {
+		return nil, nil
+	}
+
+	if size < 0 {
+		return ioutil.ReadAll(payload)
+	}
+
+	contents := make([]byte, size)
+	if _, err := io.ReadFull(payload, contents); err == io.ErrUnexpectedEOF || err == io.EOF {
+		return nil, ErrPayloadTooShort
+	} else if err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// bufferedStreamEncoder is the StreamEncoder for formats which cannot write a payload incrementally
+type bufferedStreamEncoder struct {
+	encoder Encoder
+}
+
+func (bse *bufferedStreamEncoder) Encode(header *Message, payload io.Reader, size int64) error {
+	contents, err := readPayload(payload, size)
+	if err != nil {
+		return err
+	}
+
+	copyOf := *header
+	copyOf.Payload = contents
+	return bse.encoder.Encode(&copyOf)
+}
+
+func (bse *bufferedStreamEncoder) Reset(output io.Writer) {
+	bse.encoder.Reset(output)
+}
+
+// bufferedStreamDecoder is the StreamDecoder for formats which cannot read a payload incrementally
+type bufferedStreamDecoder struct {
+	decoder Decoder
+
+	// input is the buffered input for the JSON format, which is nil for other formats
+	input *bufio.Reader
+}
+
+// skipWhitespace consumes any whitespace before the next JSON value, returning io.EOF if the input is exhausted
+func skipWhitespace(input *bufio.Reader) error {
+	for {
+		b, err := input.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return input.UnreadByte()
+		}
+	}
+}
+
+func (bsd *bufferedStreamDecoder) Decode(header *Message) (io.Reader, error) {
+	if bsd.input != nil {
+		if err := skipWhitespace(bsd.input); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bsd.decoder.Decode(header); err != nil {
+		return nil, err
+	}
+
+	contents := header.Payload
+	header.Payload = nil
+	return bytes.NewReader(contents), nil
+}
+
+func (bsd *bufferedStreamDecoder) Reset(input io.Reader) {
+	if bsd.input != nil {
+		bsd.input.Reset(input)
+		bsd.decoder.Reset(bsd.input)
+		return
+	}
+
+	bsd.decoder.Reset(input)
+}
+
+// writeMsgpackMapHeader appends the most compact msgpack map header for the given number of entries
+func writeMsgpackMapHeader(b *bytes.Buffer, n uint32) {
+	switch {
+	case n < 16:
+		b.WriteByte(0x80 | byte(n))
+	case n <= 0xffff:
+		b.WriteByte(0xde)
+		b.WriteByte(byte(n >> 8))
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(0xdf)
+		var count [4]byte
+		binary.BigEndian.PutUint32(count[:], n)
+		b.Write(count[:])
+	}
+}
+
+// writeMsgpackBinHeader appends the most compact msgpack bin header for a byte string of the given size
+func writeMsgpackBinHeader(b *bytes.Buffer, size int64) {
+	switch {
+	case size <= 0xff:
+		b.WriteByte(0xc4)
+		b.WriteByte(byte(size))
+	case size <= 0xffff:
+		b.WriteByte(0xc5)
+		b.WriteByte(byte(size >> 8))
+		b.WriteByte(byte(size))
+	default:
+		b.WriteByte(0xc6)
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(size))
+		b.Write(length[:])
+	}
+}
+
+// msgpackStreamEncoder writes the payload of each message directly to its output.  The header
+// fields are encoded normally, and the resulting map header is rewritten to include the payload.
+type msgpackStreamEncoder struct {
+	output  io.Writer
+	encoder Encoder
+	fields  []byte
+	buffer  bytes.Buffer
+}
+
+func (mse *msgpackStreamEncoder) Encode(header *Message, payload io.Reader, size int64) error {
+	if payload == nil {
+		size = 0
+	} else if size < 0 {
+		contents, err := ioutil.ReadAll(payload)
+		if err != nil {
+			return err
+		}
+
+		payload, size = bytes.NewReader(contents), int64(len(contents))
+	}
+
+	if size > 0xffffffff {
+		return fmt.Errorf("Payload size %d exceeds the msgpack limit", size)
+	}
+
+	copyOf := *header
+	copyOf.Payload = nil
+	mse.fields = mse.fields[:0]
+	mse.encoder.ResetBytes(&mse.fields)
+	if err := mse.encoder.Encode(&copyOf); err != nil {
+		return err
+	}
+
+	if size == 0 {
+		_, err := mse.output.Write(mse.fields)
+		return err
+	}
+
+	count, fields, err := splitMsgpackMapHeader(mse.fields)
+	if err != nil {
+		return err
+	}
+
+	mse.buffer.Reset()
+	writeMsgpackMapHeader(&mse.buffer, count+1)
+	mse.buffer.Write(fields)
+	mse.buffer.WriteByte(0xa0 | byte(len(PayloadField)))
+	mse.buffer.WriteString(PayloadField)
+	writeMsgpackBinHeader(&mse.buffer, size)
+	if _, err := mse.output.Write(mse.buffer.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(mse.output, payload, size); err == io.EOF {
+		return ErrPayloadTooShort
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mse *msgpackStreamEncoder) Reset(output io.Writer) {
+	mse.output = output
+}
+
+// splitMsgpackMapHeader parses the map header at the start of an encoded msgpack map, returning the number of
+// entries and the encoded entries themselves
+func splitMsgpackMapHeader(encoded []byte) (uint32, []byte, error) {
+	if len(encoded) > 0 {
+		switch b := encoded[0]; {
+		case b >= 0x80 && b <= 0x8f:
+			return uint32(b & 0x0f), encoded[1:], nil
+		case b == 0xde && len(encoded) >= 3:
+			return uint32(binary.BigEndian.Uint16(encoded[1:3])), encoded[3:], nil
+		case b == 0xdf && len(encoded) >= 5:
+			return binary.BigEndian.Uint32(encoded[1:5]), encoded[5:], nil
+		}
+	}
+
+	return 0, nil, ErrNotAMap
+}
+
+// msgpackStreamDecoder scans the top-level map of each msgpack message.  Fields other than the payload are copied
+// into a buffer and decoded normally, while the payload is exposed as a reader over the input.
+type msgpackStreamDecoder struct {
+	input   *bufio.Reader
+	decoder Decoder
+	payload *io.LimitedReader
+	fields  bytes.Buffer
+	header  bytes.Buffer
+}
+
+func (msd *msgpackStreamDecoder) Decode(header *Message) (io.Reader, error) {
+	if msd.payload != nil {
+		if _, err := io.Copy(ioutil.Discard, msd.payload); err != nil {
+			return nil, err
+		}
+
+		msd.payload = nil
+	}
+
+	first, err := msd.input.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	switch {
+	case first >= 0x80 && first <= 0x8f:
+		count = uint32(first & 0x0f)
+	case first == 0xde, first == 0xdf:
+		n, err := readMsgpackLength(msd.input, first)
+		if err != nil {
+			return nil, err
+		}
+
+		count = uint32(n)
+	default:
+		return nil, ErrNotAMap
+	}
+
+	var (
+		fieldCount uint32
+		payload    io.Reader
+	)
+
+	msd.fields.Reset()
+	for i := uint32(0); i < count; i++ {
+		mark := msd.fields.Len()
+		key, err := copyMsgpackString(&msd.fields, msd.input)
+		if err != nil {
+			return nil, err
+		}
+
+		if key != PayloadField {
+			if err := copyMsgpackValue(&msd.fields, msd.input); err != nil {
+				return nil, err
+			}
+
+			fieldCount++
+			continue
+		}
+
+		msd.fields.Truncate(mark)
+		size, err := readMsgpackPayloadHeader(msd.input)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == count-1 {
+			msd.payload = &io.LimitedReader{R: msd.input, N: size}
+			payload = msd.payload
+		} else {
+			contents := make([]byte, size)
+			if _, err := io.ReadFull(msd.input, contents); err != nil {
+				return nil, err
+			}
+
+			payload = bytes.NewReader(contents)
+		}
+	}
+
+	msd.header.Reset()
+	writeMsgpackMapHeader(&msd.header, fieldCount)
+	msd.header.Write(msd.fields.Bytes())
+	msd.decoder.ResetBytes(msd.header.Bytes())
+	if err := msd.decoder.Decode(header); err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		payload = bytes.NewReader(nil)
+	}
+
+	return payload, nil
+}
+
+func (msd *msgpackStreamDecoder) Reset(input io.Reader) {
+	msd.input.Reset(input)
+	msd.payload = nil
+}
+
+// readMsgpackLength reads the big-endian length or count which follows the given msgpack type byte
+func readMsgpackLength(input io.Reader, b byte) (int64, error) {
+	var (
+		length [4]byte
+		width  int
+	)
+
+	switch b {
+	case 0xc4, 0xc7, 0xd9:
+		width = 1
+	case 0xc5, 0xc8, 0xda, 0xdc, 0xde:
+		width = 2
+	default:
+		width = 4
+	}
+
+	if _, err := io.ReadFull(input, length[4-width:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return int64(binary.BigEndian.Uint32(length[:])), nil
+}
+
+// readMsgpackPayloadHeader reads the header of the payload value, returning the payload's size.  Both byte
+// strings and raw strings are accepted, as older encoders do not emit the bin types.
+func readMsgpackPayloadHeader(input *bufio.Reader) (int64, error) {
+	b, err := input.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	switch {
+	case b == 0xc0:
+		return 0, nil
+	case b >= 0xa0 && b <= 0xbf:
+		return int64(b & 0x1f), nil
+	case b == 0xc4, b == 0xc5, b == 0xc6, b == 0xd9, b == 0xda, b == 0xdb:
+		return readMsgpackLength(input, b)
+	}
+
+	return 0, fmt.Errorf("Invalid msgpack type for the payload: 0x%x", b)
+}
+
+// copyMsgpackString copies an encoded msgpack string, such as a map key, into the buffer and returns its value
+func copyMsgpackString(b *bytes.Buffer, input *bufio.Reader) (string, error) {
+	t, err := input.ReadByte()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+
+	b.WriteByte(t)
+
+	var length int64
+	switch {
+	case t >= 0xa0 && t <= 0xbf:
+		length = int64(t & 0x1f)
+	case t == 0xc4, t == 0xc5, t == 0xc6, t == 0xd9, t == 0xda, t == 0xdb:
+		if length, err = copyMsgpackLength(b, input, t); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("Invalid msgpack type for a field name: 0x%x", t)
+	}
+
+	mark := b.Len()
+	if _, err := io.CopyN(b, input, length); err != nil {
+		return "", unexpectedEOF(err)
+	}
+
+	return string(b.Bytes()[mark:]), nil
+}
+
+// copyMsgpackLength reads the length or count following the given type byte, copying the encoded length into the buffer
+func copyMsgpackLength(b *bytes.Buffer, input io.Reader, t byte) (int64, error) {
+	length, err := readMsgpackLength(input, t)
+	if err != nil {
+		return 0, err
+	}
+
+	var encoded [4]byte
+	binary.BigEndian.PutUint32(encoded[:], uint32(length))
+	switch t {
+	case 0xc4, 0xc7, 0xd9:
+		b.Write(encoded[3:])
+	case 0xc5, 0xc8, 0xda, 0xdc, 0xde:
+		b.Write(encoded[2:])
+	default:
+		b.Write(encoded[:])
+	}
+
+	return length, nil
+}
+
+// copyMsgpackValue copies exactly one complete msgpack value from the input into the buffer
+func copyMsgpackValue(b *bytes.Buffer, input *bufio.Reader) error {
+	t, err := input.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	b.WriteByte(t)
+
+	var (
+		length   int64
+		elements int64
+	)
+
+	switch {
+	case t <= 0x7f, t >= 0xe0, t == 0xc0, t == 0xc2, t == 0xc3:
+		return nil
+	case t >= 0x80 && t <= 0x8f:
+		elements = int64(t&0x0f) * 2
+	case t >= 0x90 && t <= 0x9f:
+		elements = int64(t & 0x0f)
+	case t >= 0xa0 && t <= 0xbf:
+		length = int64(t & 0x1f)
+	case t == 0xc4, t == 0xc5, t == 0xc6, t == 0xd9, t == 0xda, t == 0xdb:
+		if length, err = copyMsgpackLength(b, input, t); err != nil {
+			return err
+		}
+	case t == 0xc7, t == 0xc8, t == 0xc9:
+		if length, err = copyMsgpackLength(b, input, t); err != nil {
+			return err
+		}
+
+		// the extension type byte
+		length++
+	case t == 0xca, t == 0xce, t == 0xd2:
+		length = 4
+	case t == 0xcb, t == 0xcf, t == 0xd3:
+		length = 8
+	case t == 0xcc, t == 0xd0:
+		length = 1
+	case t == 0xcd, t == 0xd1:
+		length = 2
+	case t >= 0xd4 && t <= 0xd8:
+		length = 1 + (1 << (t - 0xd4))
+	case t == 0xdc, t == 0xdd:
+		if elements, err = copyMsgpackLength(b, input, t); err != nil {
+			return err
+		}
+	case t == 0xde, t == 0xdf:
+		if elements, err = copyMsgpackLength(b, input, t); err != nil {
+			return err
+		}
+
+		elements *= 2
+	default:
+		return fmt.Errorf("Invalid msgpack type: 0x%x", t)
+	}
+
+	if length > 0 {
+		if _, err := io.CopyN(b, input, length); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	for i := int64(0); i < elements; i++ {
+		if err := copyMsgpackValue(b, input); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unexpectedEOF translates io.EOF into io.ErrUnexpectedEOF, since an EOF in the middle of a message is always an error
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,283 @@
+package wrp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorReader is an io.Reader that always fails
+type errorReader struct {
+	err error
+}
+
+func (er errorReader) Read([]byte) (int, error) {
+	return 0, er.err
+}
+
+func testStreamRoundTrip(t *testing.T, f Format, original Message) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output  bytes.Buffer
+		encoder = NewStreamEncoder(&output, f)
+		payload = original.Payload
+
+		// protobuf messages are not self-delimiting, so a protobuf stream holds exactly one message
+		count = 2
+	)
+
+	if f == Protobuf {
+		count = 1
+	}
+
+	original.Payload = []byte("this should be ignored")
+	require.NoError(encoder.Encode(&original, bytes.NewReader(payload), int64(len(payload))))
+	if count > 1 {
+		require.NoError(encoder.Encode(&original, bytes.NewReader(payload), -1))
+	}
+
+	// the stream encoding must be readable by an ordinary decoder
+	var (
+		expected = original
+		decoded  Message
+		decoder  = NewDecoderBytes(output.Bytes(), f)
+	)
+
+	expected.Payload = payload
+	require.NoError(decoder.Decode(&decoded))
+	assert.Equal(expected, decoded)
+
+	var (
+		header        Message
+		streamDecoder = NewStreamDecoder(bytes.NewReader(output.Bytes()), f)
+	)
+
+	expected.Payload = nil
+	for repeat := 0; repeat < count; repeat++ {
+		header = Message{}
+		reader, err := streamDecoder.Decode(&header)
+		require.NoError(err)
+		require.NotNil(reader)
+		assert.Equal(expected, header)
+
+		actual, err := ioutil.ReadAll(reader)
+		require.NoError(err)
+		assert.Equal(len(payload), len(actual))
+		if len(payload) > 0 {
+			assert.Equal(payload, actual)
+		}
+	}
+
+	if f != Protobuf {
+		reader, err := streamDecoder.Decode(&header)
+		assert.Nil(reader)
+		assert.Equal(io.EOF, err)
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	var (
+		status  int64 = 123
+		rdr     int64 = 0
+		include       = true
+
+		everyField = Message{
+			Type:                    SimpleRequestResponseMessageType,
+			Source:                  "dns:talaria.comcast.net",
+			Destination:             "mac:112233445566",
+			TransactionUUID:         "1234",
+			ContentType:             "text/plain",
+			Accept:                  "text/plain",
+			Status:                  &status,
+			RequestDeliveryResponse: &rdr,
+			Headers:                 []string{"X-Header-1", "X-Header-2"},
+			Metadata:                map[string]string{"/cpe/name": "value"},
+			Spans:                   [][]string{{"span", "1", "2"}},
+			IncludeSpans:            &include,
+			Path:                    "/foo/bar",
+			ServiceName:             "service",
+			URL:                     "http://somewhere.comcast.net",
+			Payload:                 []byte("everything"),
+		}
+
+		messages = []Message{
+			Message{},
+			Message{Type: SimpleEventMessageType, Destination: "event:iot"},
+			Message{Type: SimpleEventMessageType, Payload: []byte{1}},
+			Message{Type: SimpleEventMessageType, Payload: bytes.Repeat([]byte{'x'}, 300)},
+			Message{Type: SimpleEventMessageType, Payload: bytes.Repeat([]byte{'x'}, 70000)},
+			everyField,
+		}
+	)
+
+	for _, f := range AllFormats() {
+		t.Run(f.String(), func(t *testing.T) {
+			for _, m := range messages {
+				testStreamRoundTrip(t, f, m)
+			}
+		})
+	}
+}
+
+func TestStreamDecoderPayloadNotLast(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// the ordinary encoder writes service_name and url after the payload
+		original = Message{
+			Type:        SimpleRequestResponseMessageType,
+			Payload:     []byte("payload"),
+			ServiceName: "service",
+			URL:         "http://somewhere.comcast.net",
+		}
+
+		decoder = NewStreamDecoder(bytes.NewReader(MustEncode(&original, Msgpack)), Msgpack)
+		header  Message
+	)
+
+	reader, err := decoder.Decode(&header)
+	require.NoError(err)
+	assert.Equal(Message{Type: SimpleRequestResponseMessageType, ServiceName: "service", URL: "http://somewhere.comcast.net"}, header)
+
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(err)
+	assert.Equal([]byte("payload"), actual)
+}
+
+func TestStreamDecoderPartialRead(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output  bytes.Buffer
+		encoder = NewStreamEncoder(&output, Msgpack)
+	)
+
+	require.NoError(encoder.Encode(&Message{Source: "first"}, strings.NewReader("a long payload which is not entirely read"), -1))
+	require.NoError(encoder.Encode(&Message{Source: "second"}, strings.NewReader("second payload"), -1))
+
+	var (
+		header  Message
+		decoder = NewStreamDecoder(&output, Msgpack)
+		buffer  = make([]byte, 6)
+	)
+
+	reader, err := decoder.Decode(&header)
+	require.NoError(err)
+	assert.Equal("first", header.Source)
+	_, err = io.ReadFull(reader, buffer)
+	require.NoError(err)
+	assert.Equal("a long", string(buffer))
+
+	header = Message{}
+	reader, err = decoder.Decode(&header)
+	require.NoError(err)
+	assert.Equal("second", header.Source)
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(err)
+	assert.Equal("second payload", string(actual))
+
+	decoder.Reset(bytes.NewReader(MustEncode(&Message{Source: "third"}, Msgpack)))
+	header = Message{}
+	_, err = decoder.Decode(&header)
+	require.NoError(err)
+	assert.Equal("third", header.Source)
+}
+
+func TestStreamEncoderErrors(t *testing.T) {
+	expectedError := errors.New("expected")
+
+	for _, f := range AllFormats() {
+		t.Run(f.String(), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				output  bytes.Buffer
+				encoder = NewStreamEncoder(&output, f)
+			)
+
+			assert.Equal(ErrPayloadTooShort, encoder.Encode(new(Message), strings.NewReader("abc"), 10))
+			assert.Equal(expectedError, encoder.Encode(new(Message), errorReader{expectedError}, -1))
+			assert.Equal(expectedError, encoder.Encode(new(Message), errorReader{expectedError}, 10))
+
+			output.Reset()
+			encoder.Reset(&output)
+			assert.NoError(encoder.Encode(new(Message), nil, 10))
+			assert.True(output.Len() > 0)
+		})
+	}
+}
+
+func TestStreamDecoderErrors(t *testing.T) {
+	testData := [][]byte{
+		{0x91, 0x00},                  // an array, not a map
+		{0xde, 0x00},                  // truncated map16 count
+		{0x81},                        // missing key
+		{0x81, 0x01, 0x01},            // key is not a string
+		{0x81, 0xa1, 'a'},             // missing value
+		{0x81, 0xa1, 'a', 0xc1},       // invalid type
+		{0x81, 0xa1, 'a', 0xa5, 'x'},  // truncated string value
+		{0x81, 0xa1, 'a', 0x92, 0x01}, // truncated array
+		{0x81, 0xa1, 'a', 0xc4},       // truncated bin8 length
+		{0x81, 0xa7, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0x01},            // payload is not a byte string
+		{0x82, 0xa7, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0xc4, 0x05, 'x'}, // truncated payload which must be buffered
+		{0x81, 0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0x91, 0x01},           // source is not a string
+	}
+
+	for _, encoded := range testData {
+		var (
+			header  Message
+			decoder = NewStreamDecoder(bytes.NewReader(encoded), Msgpack)
+		)
+
+		reader, err := decoder.Decode(&header)
+		assert.Nil(t, reader, "%x", encoded)
+		assert.Error(t, err, "%x", encoded)
+	}
+}
+
+func TestStreamDecoderValueTypes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// an unknown field holding one of each msgpack type, which the decoder must skip over
+		encoded = []byte{
+			0x82,
+			0xa7, 'u', 'n', 'k', 'n', 'o', 'w', 'n',
+			0xdc, 0x00, 0x0f,
+			0x01, 0xff, 0xc0, 0xc3,
+			0xca, 0, 0, 0, 0,
+			0xcb, 0, 0, 0, 0, 0, 0, 0, 0,
+			0xcd, 0, 0,
+			0xd0, 0,
+			0xd4, 1, 0,
+			0xd8, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0xc7, 0x01, 1, 0,
+			0xd9, 0x01, 'x',
+			0xc5, 0x00, 0x01, 'x',
+			0x81, 0xa1, 'k', 0xa1, 'v',
+			0xde, 0x00, 0x01, 0xa1, 'k', 0xa1, 'v',
+			0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xa1, 'a',
+		}
+
+		header  Message
+		decoder = NewStreamDecoder(bytes.NewReader(encoded), Msgpack)
+	)
+
+	reader, err := decoder.Decode(&header)
+	require.NoError(err)
+	assert.Equal(Message{Source: "a"}, header)
+
+	actual, err := ioutil.ReadAll(reader)
+	require.NoError(err)
+	assert.Empty(actual)
+}
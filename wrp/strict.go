@@ -0,0 +1,268 @@
+package wrp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownField indicates that a strict decoder encountered a field that the target type does not declare
+	ErrUnknownField = errors.New("Unknown WRP field")
+
+	// ErrDuplicateField indicates that a strict decoder encountered a field more than once in the same message
+	ErrDuplicateField = errors.New("Duplicate WRP field")
+)
+
+// FieldError is returned by strict decoders to identify the field that caused a message to be rejected.
+// Err is always either ErrUnknownField or ErrDuplicateField.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (fe *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", fe.Err, fe.Field)
+}
+
+// strictFields caches the set of WRP field names declared by each struct type
+var strictFields sync.Map
+
+// strictFieldsFor returns the WRP field names declared by the struct that v refers to, or nil if v does not
+// refer to a struct.  Untagged, embedded structs are flattened in the same way that the encoders do.
+func strictFieldsFor(v interface{}) map[string]bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if existing, ok := strictFields.Load(t); ok {
+		return existing.(map[string]bool)
+	}
+
+	fields := make(map[string]bool)
+	addStrictFields(t, fields)
+	existing, _ := strictFields.LoadOrStore(t, fields)
+	return existing.(map[string]bool)
+}
+
+func addStrictFields(t reflect.Type, fields map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			field = t.Field(i)
+			tag   = field.Tag.Get("wrp")
+		)
+
+		if len(tag) == 0 && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addStrictFields(field.Type, fields)
+			continue
+		}
+
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		fields[strings.Split(tag, ",")[0]] = true
+	}
+}
+
+// checkField records a field name found in an encoded message, returning an error if the field is not
+// declared by the target type or has already been seen
+func checkField(fields, seen map[string]bool, name string) error {
+	if !fields[name] {
+		return &FieldError{Field: name, Err: ErrUnknownField}
+	}
+
+	if seen[name] {
+		return &FieldError{Field: name, Err: ErrDuplicateField}
+	}
+
+	seen[name] = true
+	return nil
+}
+
+// strictDecoder checks the top-level field names of each encoded value against the target type before
+// delegating to the ordinary Decoder for its format.  Since the fields must be examined before decoding,
+// input from an io.Reader is read fully into memory on the first call to Decode.
+type strictDecoder struct {
+	format   Format
+	delegate Decoder
+
+	reader io.Reader
+	input  []byte
+	err    error
+}
+
+// NewStrictDecoder produces a Decoder that fails with a *FieldError rather than silently ignoring fields that the
+// target type does not declare, or fields which appear more than once.  This is useful for gateways that must reject
+// malformed or spoofed messages.  The input is read fully into memory before decoding, so this decoder is best suited
+// to bounded sources such as HTTP entities.
+//
+// Only the Msgpack and JSON formats are checked.  For any other format, this function returns the same Decoder
+// as NewDecoder.  Targets that are not structs, such as maps, are decoded without any checks.
+func NewStrictDecoder(input io.Reader, f Format) Decoder {
+	if f != Msgpack && f != JSON {
+		return NewDecoder(input, f)
+	}
+
+	return &strictDecoder{
+		format:   f,
+		delegate: NewDecoderBytes(nil, f),
+		reader:   input,
+	}
+}
+
+// NewStrictDecoderBytes is like NewStrictDecoder, except that the input is a byte slice
+func NewStrictDecoderBytes(input []byte, f Format) Decoder {
+	if f != Msgpack && f != JSON {
+		return NewDecoderBytes(input, f)
+	}
+
+	return &strictDecoder{
+		format:   f,
+		delegate: NewDecoderBytes(nil, f),
+		input:    input,
+	}
+}
+
+func (sd *strictDecoder) Decode(v interface{}) error {
+	if sd.reader != nil {
+		sd.input, sd.err = ioutil.ReadAll(sd.reader)
+		sd.reader = nil
+	}
+
+	if sd.err != nil {
+		return sd.err
+	}
+
+	var (
+		n   int
+		err error
+	)
+
+	if sd.format == JSON {
+		n, err = scanStrictJSON(sd.input, strictFieldsFor(v))
+	} else {
+		n, err = scanStrictMsgpack(sd.input, strictFieldsFor(v))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sd.delegate.ResetBytes(sd.input[:n])
+	sd.input = sd.input[n:]
+	return sd.delegate.Decode(v)
+}
+
+func (sd *strictDecoder) Reset(input io.Reader) {
+	sd.reader = input
+	sd.input = nil
+	sd.err = nil
+}
+
+func (sd *strictDecoder) ResetBytes(input []byte) {
+	sd.reader = nil
+	sd.input = input
+	sd.err = nil
+}
+
+// scanStrictMsgpack checks the keys of the msgpack map at the start of the input, returning the length of the
+// encoded map.  If fields is nil or the value is not a map, the value is skipped without any checks.
+func scanStrictMsgpack(input []byte, fields map[string]bool) (int, error) {
+	if len(input) == 0 {
+		return 0, io.EOF
+	}
+
+	ms := msgpackScanner{data: input}
+	if fields == nil {
+		err := ms.skip()
+		return ms.pos, err
+	}
+
+	count, err := ms.mapHeader()
+	if err == ErrNotAMap {
+		ms.pos = 0
+		err = ms.skip()
+		return ms.pos, err
+	} else if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool, count)
+	for i := 0; i < count; i++ {
+		key, err := ms.strBytes()
+		if err != nil {
+			return 0, err
+		}
+
+		if err := checkField(fields, seen, string(key)); err != nil {
+			return 0, err
+		}
+
+		if err := ms.skip(); err != nil {
+			return 0, err
+		}
+	}
+
+	return ms.pos, nil
+}
+
+// scanStrictJSON checks the keys of the JSON object at the start of the input, returning the length of the
+// encoded object.  If fields is nil or the value is not an object, the value is skipped without any checks.
+func scanStrictJSON(input []byte, fields map[string]bool) (int, error) {
+	if len(bytes.TrimSpace(input)) == 0 {
+		return 0, io.EOF
+	}
+
+	var (
+		decoder = json.NewDecoder(bytes.NewReader(input))
+		raw     json.RawMessage
+	)
+
+	if fields == nil || bytes.TrimSpace(input)[0] != '{' {
+		if err := decoder.Decode(&raw); err != nil {
+			return 0, err
+		}
+
+		return int(decoder.InputOffset()), nil
+	}
+
+	// consume the opening delimiter
+	if _, err := decoder.Token(); err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, err
+		}
+
+		if err := checkField(fields, seen, token.(string)); err != nil {
+			return 0, err
+		}
+
+		if err := decoder.Decode(&raw); err != nil {
+			return 0, err
+		}
+	}
+
+	// consume the closing delimiter
+	if _, err := decoder.Token(); err != nil {
+		return 0, err
+	}
+
+	return int(decoder.InputOffset()), nil
+}
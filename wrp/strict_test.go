@@ -0,0 +1,187 @@
+package wrp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// duplicateSources holds, for each checked format, a message in which the source field appears twice
+var duplicateSources = map[Format][]byte{
+	Msgpack: append(append([]byte{0x82, 0xa6}, "source"...), append(append([]byte{0xa1, 'a', 0xa6}, "source"...), 0xa1, 'b')...),
+	JSON:    []byte(`{"source": "a", "source": "b"}`),
+}
+
+func testStrictDecoderValid(t *testing.T, f Format) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		status  int64 = 200
+
+		expected = []Message{
+			{
+				Type:        SimpleRequestResponseMessageType,
+				Source:      "dns:talaria.comcast.net",
+				Destination: "mac:112233445566",
+				Status:      &status,
+				Metadata:    map[string]string{"key": "value"},
+				Payload:     []byte("payload"),
+			},
+			{
+				Type:   SimpleEventMessageType,
+				Source: "mac:112233445566",
+			},
+		}
+
+		encoded []byte
+		encoder = NewEncoderBytes(&encoded, f)
+	)
+
+	for i := range expected {
+		require.NoError(encoder.Encode(&expected[i]))
+	}
+
+	for _, decoder := range []Decoder{NewStrictDecoderBytes(encoded, f), NewStrictDecoder(bytes.NewReader(encoded), f)} {
+		for _, e := range expected {
+			var actual Message
+			require.NoError(decoder.Decode(&actual))
+			assert.Equal(e, actual)
+		}
+
+		assert.Equal(io.EOF, decoder.Decode(new(Message)))
+	}
+
+	var update Update
+	require.NoError(NewStrictDecoderBytes(MustEncode(&Update{CRUD{Path: "/foo"}}, f), f).Decode(&update))
+	assert.Equal(UpdateMessageType, update.Type)
+	assert.Equal("/foo", update.Path)
+}
+
+func testStrictDecoderUnknownField(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		encoded = MustEncode(&Message{Type: SimpleEventMessageType, Source: "test", Payload: []byte("payload")}, f)
+
+		rh RoutingHeader
+	)
+
+	// the ordinary decoder silently drops the payload
+	assert.NoError(NewDecoderBytes(encoded, f).Decode(&rh))
+	assert.Equal("test", rh.Source)
+
+	err := NewStrictDecoderBytes(encoded, f).Decode(&rh)
+	assert.Equal(&FieldError{Field: PayloadField, Err: ErrUnknownField}, err)
+	assert.Equal("Unknown WRP field: payload", err.Error())
+}
+
+func testStrictDecoderDuplicateField(t *testing.T, f Format) {
+	var (
+		assert = assert.New(t)
+
+		message Message
+	)
+
+	assert.NoError(NewDecoderBytes(duplicateSources[f], f).Decode(&message))
+	assert.Equal("b", message.Source)
+
+	message = Message{}
+	assert.Equal(
+		&FieldError{Field: SourceField, Err: ErrDuplicateField},
+		NewStrictDecoderBytes(duplicateSources[f], f).Decode(&message),
+	)
+
+	assert.Empty(message.Source)
+}
+
+func testStrictDecoderNonStruct(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		encoded = MustEncode(&Message{Type: SimpleEventMessageType, Source: "test"}, f)
+
+		values  map[string]interface{}
+		decoder = NewStrictDecoderBytes(encoded, f)
+	)
+
+	require.NoError(decoder.Decode(&values))
+	assert.NotEmpty(values)
+	assert.Equal(io.EOF, decoder.Decode(&values))
+
+	// values other than maps are left to the ordinary decoder to reject
+	var (
+		contents []byte
+		actual   Message
+	)
+
+	require.NoError(NewEncoderBytes(&contents, f).Encode("a string"))
+	assert.Error(NewStrictDecoderBytes(contents, f).Decode(&actual))
+}
+
+func testStrictDecoderReset(t *testing.T, f Format) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		encoded       = MustEncode(&Message{Type: SimpleEventMessageType, Source: "test"}, f)
+
+		message Message
+		decoder = NewStrictDecoder(errorReader{expectedError}, f)
+	)
+
+	assert.Equal(expectedError, decoder.Decode(&message))
+	assert.Equal(expectedError, decoder.Decode(&message))
+
+	decoder.ResetBytes(encoded)
+	require.NoError(decoder.Decode(&message))
+	assert.Equal("test", message.Source)
+
+	message = Message{}
+	decoder.Reset(bytes.NewReader(encoded))
+	require.NoError(decoder.Decode(&message))
+	assert.Equal("test", message.Source)
+
+	decoder.Reset(bytes.NewReader(duplicateSources[f]))
+	assert.Equal(&FieldError{Field: SourceField, Err: ErrDuplicateField}, decoder.Decode(&message))
+}
+
+func testStrictDecoderPool(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pool    = NewDecoderPoolWithOptions(&PoolOptions{Capacity: 1, Strict: true}, f)
+
+		message Message
+	)
+
+	require.NoError(pool.DecodeBytes(&message, MustEncode(&Message{Type: SimpleEventMessageType, Source: "test"}, f)))
+	assert.Equal("test", message.Source)
+
+	assert.Equal(&FieldError{Field: SourceField, Err: ErrDuplicateField}, pool.DecodeBytes(&message, duplicateSources[f]))
+	assert.Equal(&FieldError{Field: SourceField, Err: ErrDuplicateField}, pool.Decode(&message, bytes.NewReader(duplicateSources[f])))
+	assert.NoError(NewDecoderPool(1, f).DecodeBytes(&message, duplicateSources[f]))
+}
+
+func TestStrictDecoder(t *testing.T) {
+	for _, f := range []Format{Msgpack, JSON} {
+		t.Run(f.String(), func(t *testing.T) {
+			t.Run("Valid", func(t *testing.T) { testStrictDecoderValid(t, f) })
+			t.Run("UnknownField", func(t *testing.T) { testStrictDecoderUnknownField(t, f) })
+			t.Run("DuplicateField", func(t *testing.T) { testStrictDecoderDuplicateField(t, f) })
+			t.Run("NonStruct", func(t *testing.T) { testStrictDecoderNonStruct(t, f) })
+			t.Run("Reset", func(t *testing.T) { testStrictDecoderReset(t, f) })
+			t.Run("Pool", func(t *testing.T) { testStrictDecoderPool(t, f) })
+		})
+	}
+
+	t.Run("UncheckedFormats", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, f := range []Format{CBOR, Protobuf} {
+			assert.IsType(NewDecoder(nil, f), NewStrictDecoder(nil, f))
+			assert.IsType(NewDecoderBytes(nil, f), NewStrictDecoderBytes(nil, f))
+		}
+	})
+}
@@ -0,0 +1,53 @@
+// wrpschema writes the JSON Schema of each WRP message type to a directory, one file per message type.
+// The files are named after each message type's friendly name, e.g. SimpleEvent.json.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+func main() {
+	var (
+		outputDirectory = flag.String("d", ".", "the directory to which schema files are written")
+		messageTypes    = []wrp.MessageType{
+			wrp.AuthorizationStatusMessageType,
+			wrp.SimpleRequestResponseMessageType,
+			wrp.SimpleEventMessageType,
+			wrp.CreateMessageType,
+			wrp.RetrieveMessageType,
+			wrp.UpdateMessageType,
+			wrp.DeleteMessageType,
+			wrp.ServiceRegistrationMessageType,
+			wrp.ServiceAliveMessageType,
+			wrp.UnknownMessageType,
+		}
+	)
+
+	flag.Parse()
+	if err := os.MkdirAll(*outputDirectory, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create output directory: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, mt := range messageTypes {
+		schema, err := wrp.MarshalJSONSchema(mt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to generate schema for %s: %s\n", mt, err)
+			os.Exit(1)
+		}
+
+		path := filepath.Join(*outputDirectory, mt.FriendlyName()+".json")
+		if err := ioutil.WriteFile(path, append(schema, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(path)
+	}
+}
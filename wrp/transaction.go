@@ -0,0 +1,205 @@
+package wrp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultCorrelatorTimeout is the time a Correlator waits for a response when no timeout is configured
+const DefaultCorrelatorTimeout time.Duration = 30 * time.Second
+
+var (
+	// ErrEmptyTransactionKey indicates that a message could not be correlated because it has no transaction_uuid
+	ErrEmptyTransactionKey = errors.New("The WRP message has no transaction key")
+
+	// ErrTransactionAlreadyRegistered indicates a duplicate transaction key, which means that transaction
+	// keys are not being generated uniquely
+	ErrTransactionAlreadyRegistered = errors.New("That WRP transaction key is already registered")
+
+	// ErrNoSuchTransaction is returned by Correlator.Complete when a response does not match any pending transaction,
+	// either because it was never registered or because it has expired
+	ErrNoSuchTransaction = errors.New("No such WRP transaction is pending")
+
+	// ErrTransactionExpired indicates that no response arrived before the transaction's timeout
+	ErrTransactionExpired = errors.New("The WRP transaction expired before a response arrived")
+)
+
+// uuidSource is the source of randomness for transaction UUIDs
+var uuidSource io.Reader = rand.Reader
+
+// NewTransactionUUID generates a random RFC 4122 (version 4) UUID in its canonical, lowercase textual form
+func NewTransactionUUID() (string, error) {
+	var u [16]byte
+	if _, err := io.ReadFull(uuidSource, u[:]); err != nil {
+		return "", err
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// StampTransactionUUID ensures that a message which participates in transactions has a transaction_uuid, generating
+// one if necessary.  The message's transaction key is returned, which is empty for message types that do not support
+// transactions.
+func StampTransactionUUID(m *Message) (string, error) {
+	if !m.Type.SupportsTransaction() {
+		return "", nil
+	}
+
+	if len(m.TransactionUUID) == 0 {
+		transactionUUID, err := NewTransactionUUID()
+		if err != nil {
+			return "", err
+		}
+
+		m.TransactionUUID = transactionUUID
+	}
+
+	return m.TransactionUUID, nil
+}
+
+// pendingTransaction is a registered transaction awaiting its response
+type pendingTransaction struct {
+	result chan *Message
+	timer  *time.Timer
+}
+
+// Correlator matches asynchronous WRP responses to the requests that are waiting for them, using the transaction_uuid
+// field.  Each registered transaction expires if no response arrives within the Correlator's timeout.  Instances are
+// safe for concurrent access.
+type Correlator struct {
+	lock    sync.Mutex
+	pending map[string]*pendingTransaction
+	timeout time.Duration
+}
+
+// NewCorrelator creates a Correlator whose transactions expire after the given timeout.  If timeout is nonpositive,
+// DefaultCorrelatorTimeout is used.
+func NewCorrelator(timeout time.Duration) *Correlator {
+	if timeout <= 0 {
+		timeout = DefaultCorrelatorTimeout
+	}
+
+	return &Correlator{
+		pending: make(map[string]*pendingTransaction),
+		timeout: timeout,
+	}
+}
+
+// Len returns the number of pending transactions
+func (c *Correlator) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.pending)
+}
+
+// Register adds a pending transaction and returns the channel on which its response will be delivered.  The channel
+// receives exactly one response when Complete is called with a matching message.  If the transaction expires or is
+// canceled first, the channel is closed without a response.
+func (c *Correlator) Register(transactionKey string) (<-chan *Message, error) {
+	if len(transactionKey) == 0 {
+		return nil, ErrEmptyTransactionKey
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.pending[transactionKey]; ok {
+		return nil, ErrTransactionAlreadyRegistered
+	}
+
+	pt := &pendingTransaction{result: make(chan *Message, 1)}
+	pt.timer = time.AfterFunc(c.timeout, func() {
+		c.remove(transactionKey, pt)
+	})
+
+	c.pending[transactionKey] = pt
+	return pt.result, nil
+}
+
+// remove closes out a particular pending transaction, if it is still registered.  Checking the identity
+// of the pending transaction prevents a stale timer from removing a later registration of the same key.
+func (c *Correlator) remove(transactionKey string, pt *pendingTransaction) {
+	c.lock.Lock()
+	if c.pending[transactionKey] == pt {
+		delete(c.pending, transactionKey)
+		close(pt.result)
+	}
+
+	c.lock.Unlock()
+}
+
+// Complete delivers a response to the pending transaction with the same transaction_uuid.  ErrNoSuchTransaction is
+// returned if no such transaction is pending, which is normal for responses that arrive after their transactions expired.
+func (c *Correlator) Complete(response *Message) error {
+	if len(response.TransactionUUID) == 0 {
+		return ErrEmptyTransactionKey
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pt, ok := c.pending[response.TransactionUUID]
+	if !ok {
+		return ErrNoSuchTransaction
+	}
+
+	delete(c.pending, response.TransactionUUID)
+	pt.timer.Stop()
+	pt.result <- response
+	close(pt.result)
+	return nil
+}
+
+// Cancel removes a pending transaction, closing its channel.  If the transaction is not pending, this method does nothing.
+func (c *Correlator) Cancel(transactionKey string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if pt, ok := c.pending[transactionKey]; ok {
+		delete(c.pending, transactionKey)
+		pt.timer.Stop()
+		close(pt.result)
+	}
+}
+
+// Exchange stamps a transaction UUID on the request if necessary, registers the transaction, transmits the request
+// with the given send function, and waits for the correlated response.  The request's type must support transactions,
+// or ErrEmptyTransactionKey is returned.  This method returns ErrTransactionExpired if
+// no response arrives within the timeout, or the context's error if the context is canceled first.
+func (c *Correlator) Exchange(ctx context.Context, request *Message, send func(*Message) error) (*Message, error) {
+	transactionKey, err := StampTransactionUUID(request)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.Register(transactionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := send(request); err != nil {
+		c.Cancel(transactionKey)
+		return nil, err
+	}
+
+	select {
+	case response, ok := <-result:
+		if !ok {
+			return nil, ErrTransactionExpired
+		}
+
+		return response, nil
+
+	case <-ctx.Done():
+		c.Cancel(transactionKey)
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,213 @@
+package wrp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewTransactionUUID(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		seen    = make(map[string]bool)
+	)
+
+	for i := 0; i < 100; i++ {
+		transactionUUID, err := NewTransactionUUID()
+		require.NoError(err)
+		assert.Regexp(uuidPattern, transactionUUID)
+		assert.False(seen[transactionUUID])
+		seen[transactionUUID] = true
+	}
+
+	defer func(original io.Reader) {
+		uuidSource = original
+	}(uuidSource)
+
+	uuidSource = bytes.NewReader([]byte{1, 2, 3})
+	transactionUUID, err := NewTransactionUUID()
+	assert.Empty(transactionUUID)
+	assert.Error(err)
+}
+
+func TestStampTransactionUUID(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	event := &Message{Type: SimpleEventMessageType}
+	transactionKey, err := StampTransactionUUID(event)
+	assert.Empty(transactionKey)
+	assert.NoError(err)
+	assert.Empty(event.TransactionUUID)
+
+	request := &Message{Type: SimpleRequestResponseMessageType}
+	transactionKey, err = StampTransactionUUID(request)
+	require.NoError(err)
+	assert.Regexp(uuidPattern, transactionKey)
+	assert.Equal(transactionKey, request.TransactionUUID)
+
+	existing := &Message{Type: CreateMessageType, TransactionUUID: "existing"}
+	transactionKey, err = StampTransactionUUID(existing)
+	assert.Equal("existing", transactionKey)
+	assert.NoError(err)
+}
+
+func testCorrelatorComplete(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		correlator = NewCorrelator(time.Hour)
+	)
+
+	result, err := correlator.Register("1234")
+	require.NoError(err)
+	require.NotNil(result)
+	assert.Equal(1, correlator.Len())
+
+	_, err = correlator.Register("1234")
+	assert.Equal(ErrTransactionAlreadyRegistered, err)
+
+	_, err = correlator.Register("")
+	assert.Equal(ErrEmptyTransactionKey, err)
+
+	assert.Equal(ErrEmptyTransactionKey, correlator.Complete(new(Message)))
+	assert.Equal(ErrNoSuchTransaction, correlator.Complete(&Message{TransactionUUID: "unknown"}))
+
+	response := &Message{Type: SimpleRequestResponseMessageType, TransactionUUID: "1234"}
+	require.NoError(correlator.Complete(response))
+	assert.Zero(correlator.Len())
+	assert.Equal(response, <-result)
+
+	_, ok := <-result
+	assert.False(ok)
+	assert.Equal(ErrNoSuchTransaction, correlator.Complete(response))
+}
+
+func testCorrelatorCancel(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		correlator = NewCorrelator(0)
+	)
+
+	assert.Equal(DefaultCorrelatorTimeout, correlator.timeout)
+
+	result, err := correlator.Register("1234")
+	require.NoError(err)
+
+	correlator.Cancel("1234")
+	correlator.Cancel("1234")
+	correlator.Cancel("unknown")
+	assert.Zero(correlator.Len())
+
+	response, ok := <-result
+	assert.Nil(response)
+	assert.False(ok)
+}
+
+func testCorrelatorExpiry(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		correlator = NewCorrelator(10 * time.Millisecond)
+	)
+
+	result, err := correlator.Register("1234")
+	require.NoError(err)
+
+	select {
+	case response, ok := <-result:
+		assert.Nil(response)
+		assert.False(ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The transaction did not expire")
+	}
+
+	assert.Zero(correlator.Len())
+	assert.Equal(ErrNoSuchTransaction, correlator.Complete(&Message{TransactionUUID: "1234"}))
+
+	// the key can be reused once its transaction has expired
+	_, err = correlator.Register("1234")
+	assert.NoError(err)
+}
+
+func testCorrelatorExchange(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		correlator = NewCorrelator(time.Hour)
+		request    = &Message{Type: SimpleRequestResponseMessageType, Destination: "mac:112233445566"}
+	)
+
+	response, err := correlator.Exchange(context.Background(), request, func(sent *Message) error {
+		// the response arrives asynchronously, as it would from a device
+		go correlator.Complete(&Message{
+			Type:            SimpleRequestResponseMessageType,
+			Source:          sent.Destination,
+			TransactionUUID: sent.TransactionUUID,
+		})
+
+		return nil
+	})
+
+	require.NoError(err)
+	require.NotNil(response)
+	assert.Regexp(uuidPattern, request.TransactionUUID)
+	assert.Equal(request.TransactionUUID, response.TransactionUUID)
+	assert.Equal("mac:112233445566", response.Source)
+}
+
+func testCorrelatorExchangeFailures(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		noop          = func(*Message) error { return nil }
+	)
+
+	response, err := NewCorrelator(time.Hour).Exchange(context.Background(), &Message{Type: SimpleEventMessageType}, noop)
+	assert.Nil(response)
+	assert.Equal(ErrEmptyTransactionKey, err)
+
+	correlator := NewCorrelator(time.Hour)
+	response, err = correlator.Exchange(
+		context.Background(),
+		&Message{Type: SimpleRequestResponseMessageType},
+		func(*Message) error { return expectedError },
+	)
+
+	assert.Nil(response)
+	assert.Equal(expectedError, err)
+	assert.Zero(correlator.Len())
+
+	response, err = NewCorrelator(10*time.Millisecond).Exchange(context.Background(), &Message{Type: SimpleRequestResponseMessageType}, noop)
+	assert.Nil(response)
+	assert.Equal(ErrTransactionExpired, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	correlator = NewCorrelator(time.Hour)
+	response, err = correlator.Exchange(ctx, &Message{Type: SimpleRequestResponseMessageType}, noop)
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+	assert.Zero(correlator.Len())
+}
+
+func TestCorrelator(t *testing.T) {
+	t.Run("Complete", testCorrelatorComplete)
+	t.Run("Cancel", testCorrelatorCancel)
+	t.Run("Expiry", testCorrelatorExpiry)
+	t.Run("Exchange", testCorrelatorExchange)
+	t.Run("ExchangeFailures", testCorrelatorExchangeFailures)
+}
@@ -0,0 +1,55 @@
+package wrp
+
+import (
+	"bytes"
+	"io"
+)
+
+// Transcode reads WRP messages in the src format from r and writes them to w in the dst format, until r is exhausted.
+// The messages are streamed with a StreamDecoder and a StreamEncoder, so callers never handle a decoded Message.  When
+// both formats are Msgpack, payloads are copied from r to w without being held in memory.  When the formats are the same,
+// the input is copied verbatim.
+//
+// Protobuf messages are not self-delimiting, so if either format is Protobuf exactly one message is transcoded.
+func Transcode(dst Format, src Format, r io.Reader, w io.Writer) error {
+	if dst == src {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	var (
+		decoder = NewStreamDecoder(r, src)
+		encoder = NewStreamEncoder(w, dst)
+		single  = src == Protobuf || dst == Protobuf
+	)
+
+	for {
+		var header Message
+		payload, err := decoder.Decode(&header)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := encoder.Encode(&header, payload, payloadSize(payload)); err != nil {
+			return err
+		}
+
+		if single {
+			return nil
+		}
+	}
+}
+
+// payloadSize returns the number of bytes remaining in a payload returned by a StreamDecoder, or -1 if that is not known
+func payloadSize(payload io.Reader) int64 {
+	switch p := payload.(type) {
+	case *io.LimitedReader:
+		return p.N
+	case *bytes.Reader:
+		return int64(p.Len())
+	}
+
+	return -1
+}
@@ -0,0 +1,124 @@
+package wrp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTranscode(t *testing.T, dst, src Format) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		status  int64 = 200
+
+		messages = []Message{
+			{
+				Type:            SimpleRequestResponseMessageType,
+				Source:          "dns:talaria.comcast.net",
+				Destination:     "mac:112233445566/config",
+				TransactionUUID: "DEADBEEF",
+				ContentType:     "application/json",
+				Status:          &status,
+				Headers:         []string{"Header1"},
+				Metadata:        map[string]string{"key": "value"},
+				Payload:         []byte(`{"key": "value"}`),
+			},
+			{
+				Type:        SimpleEventMessageType,
+				Source:      "mac:112233445566",
+				Destination: "event:device-status",
+			},
+			{
+				Type:        CreateMessageType,
+				Source:      "dns:somewhere.comcast.net",
+				Destination: "mac:112233445566",
+				Path:        "/some/where",
+				Payload:     make([]byte, 10000),
+			},
+		}
+
+		input  bytes.Buffer
+		output bytes.Buffer
+	)
+
+	if src == Protobuf || dst == Protobuf {
+		messages = messages[:1]
+	}
+
+	encoder := NewEncoder(&input, src)
+	for i := range messages {
+		require.NoError(encoder.Encode(&messages[i]))
+	}
+
+	require.NoError(Transcode(dst, src, &input, &output))
+
+	decoder := NewStreamDecoder(&output, dst)
+	for _, expected := range messages {
+		var actual Message
+		payload, err := decoder.Decode(&actual)
+		require.NoError(err)
+
+		contents, err := ioutil.ReadAll(payload)
+		require.NoError(err)
+		assert.Equal(len(expected.Payload), len(contents))
+		if len(expected.Payload) > 0 {
+			assert.Equal(expected.Payload, contents)
+		}
+
+		expected.Payload = nil
+		assert.Equal(expected, actual)
+	}
+
+	if dst != Protobuf {
+		_, err := decoder.Decode(new(Message))
+		assert.Equal(io.EOF, err)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (fw failingWriter) Write([]byte) (int, error) {
+	return 0, fw.err
+}
+
+func testTranscodeErrors(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		encoded []byte
+	)
+
+	require.NoError(NewEncoderBytes(&encoded, Msgpack).Encode(&Message{
+		Type:        SimpleEventMessageType,
+		Destination: "event:test",
+		Payload:     []byte("payload"),
+	}))
+
+	assert.Error(Transcode(JSON, Msgpack, bytes.NewReader([]byte{0x93, 0x01, 0x02, 0x03}), new(bytes.Buffer)))
+	assert.Error(Transcode(JSON, Msgpack, bytes.NewReader(encoded[:len(encoded)-1]), new(bytes.Buffer)))
+	assert.Error(Transcode(JSON, Msgpack, bytes.NewReader(encoded), failingWriter{expectedError}))
+	assert.Equal(expectedError, Transcode(Msgpack, Msgpack, bytes.NewReader(encoded), failingWriter{expectedError}))
+}
+
+func TestTranscode(t *testing.T) {
+	for _, src := range AllFormats() {
+		for _, dst := range AllFormats() {
+			t.Run(fmt.Sprintf("%sTo%s", src, dst), func(t *testing.T) {
+				testTranscode(t, dst, src)
+			})
+		}
+	}
+
+	t.Run("Errors", testTranscodeErrors)
+}
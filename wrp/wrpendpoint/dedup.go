@@ -0,0 +1,200 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// DefaultDedupTTL is the length of time that a response is remembered by the Dedup middleware when no TTL is supplied
+const DefaultDedupTTL time.Duration = time.Minute
+
+// ErrDedupPanic is returned to duplicate requests that were waiting on an original request whose endpoint panicked
+var ErrDedupPanic = errors.New("The original WRP request panicked before producing a response")
+
+// DedupKeyFunc produces the key used to detect duplicate requests.  If this function returns false, the request
+// is never treated as a duplicate.
+type DedupKeyFunc func(context.Context, interface{}) (string, bool)
+
+// RequestDedupKey is the default DedupKeyFunc.  It handles any Note, including Request, as well as *wrp.Message, keying
+// each message by its transaction_uuid and source.  A message without a transaction_uuid, any value that is not a WRP
+// message, or a Note without a decoded message is never treated as a duplicate.
+func RequestDedupKey(_ context.Context, v interface{}) (string, bool) {
+	var m *wrp.Message
+	switch r := v.(type) {
+	case Note:
+		m = r.Message()
+	case *wrp.Message:
+		m = r
+	}
+
+	if m == nil || len(m.TransactionUUID) == 0 {
+		return "", false
+	}
+
+	// a transaction_uuid cannot contain a NUL, so this key is unambiguous
+	return m.TransactionUUID + "\x00" + m.Source, true
+}
+
+// DedupOptions describes the configuration of the Dedup middleware.  A nil DedupOptions is valid
+// and uses the default for each setting.
+type DedupOptions struct {
+	// TTL is the length of time that a successful response is returned for duplicates of its request.  If not
+	// supplied, DefaultDedupTTL is used.
+	TTL time.Duration
+
+	// Key determines which requests are duplicates of each other.  If not supplied, RequestDedupKey is used.
+	Key DedupKeyFunc
+}
+
+func (o *DedupOptions) ttl() time.Duration {
+	if o != nil && o.TTL > 0 {
+		return o.TTL
+	}
+
+	return DefaultDedupTTL
+}
+
+func (o *DedupOptions) key() DedupKeyFunc {
+	if o != nil && o.Key != nil {
+		return o.Key
+	}
+
+	return RequestDedupKey
+}
+
+// dedupEntry is the outcome of the first request with a given key.  The response and err fields
+// may only be read after done is closed.
+type dedupEntry struct {
+	done     chan struct{}
+	pending  bool
+	expires  time.Time
+	response interface{}
+	err      error
+}
+
+// dedupCache is a TTL cache of request keys to the outcomes of those requests
+type dedupCache struct {
+	lock      sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	entries   map[string]*dedupEntry
+	lastSweep time.Time
+}
+
+func newDedupCache(ttl time.Duration, now func() time.Time) *dedupCache {
+	return &dedupCache{
+		ttl:       ttl,
+		now:       now,
+		entries:   make(map[string]*dedupEntry),
+		lastSweep: now(),
+	}
+}
+
+// begin returns the entry for a key.  If there is no pending or unexpired entry, a new pending entry is created
+// and this method returns true, indicating that the caller must invoke the decorated endpoint and then call finish.
+func (dc *dedupCache) begin(key string) (*dedupEntry, bool) {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+
+	if entry, ok := dc.entries[key]; ok && (entry.pending || dc.now().Before(entry.expires)) {
+		return entry, false
+	}
+
+	entry := &dedupEntry{
+		done:    make(chan struct{}),
+		pending: true,
+	}
+
+	dc.entries[key] = entry
+	return entry, true
+}
+
+// finish records the outcome of a pending entry and releases any duplicate requests waiting on it.  Failed requests
+// are not remembered, so that a later retransmission is attempted again.  Expired entries are swept at most once per TTL.
+func (dc *dedupCache) finish(key string, entry *dedupEntry, response interface{}, err error) {
+	dc.lock.Lock()
+	now := dc.now()
+	entry.pending = false
+	entry.response, entry.err = response, err
+
+	if err != nil {
+		if dc.entries[key] == entry {
+			delete(dc.entries, key)
+		}
+	} else {
+		entry.expires = now.Add(dc.ttl)
+	}
+
+	if now.Sub(dc.lastSweep) >= dc.ttl {
+		for k, e := range dc.entries {
+			if !e.pending && !now.Before(e.expires) {
+				delete(dc.entries, k)
+			}
+		}
+
+		dc.lastSweep = now
+	}
+
+	dc.lock.Unlock()
+	close(entry.done)
+}
+
+// invoke passes the first request with a key to the decorated endpoint, and always finishes its entry.  If the endpoint
+// panics, the entry is finished with ErrDedupPanic before the panic continues, so that duplicates do not wait forever.
+func (dc *dedupCache) invoke(ctx context.Context, key string, entry *dedupEntry, next endpoint.Endpoint, value interface{}) (response interface{}, err error) {
+	err = ErrDedupPanic
+	defer func() {
+		dc.finish(key, entry, response, err)
+	}()
+
+	response, err = next(ctx, value)
+	return
+}
+
+// Dedup produces a go-kit middleware that detects retransmitted requests, such as a device resending a message
+// it believes was lost, and answers them idempotently.  The first request with a given key is passed to the decorated
+// endpoint, and its successful response is returned for any duplicate received within the TTL.  Duplicates that
+// arrive while the first request is still in progress wait for its outcome, including any error.  Failed requests are
+// not remembered, so a retransmission after a failure is passed to the decorated endpoint again.
+//
+// If the context of a duplicate request is canceled while it waits, the context's error is returned.  If the decorated
+// endpoint panics, waiting duplicates receive ErrDedupPanic.  Requests without a key are always passed to the decorated
+// endpoint.  Each endpoint decorated by the returned middleware has its own cache of requests.
+func Dedup(o *DedupOptions) endpoint.Middleware {
+	return dedup(o, time.Now)
+}
+
+func dedup(o *DedupOptions, now func() time.Time) endpoint.Middleware {
+	var (
+		key = o.key()
+		ttl = o.ttl()
+	)
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		cache := newDedupCache(ttl, now)
+
+		return func(ctx context.Context, value interface{}) (interface{}, error) {
+			k, ok := key(ctx, value)
+			if !ok {
+				return next(ctx, value)
+			}
+
+			entry, first := cache.begin(k)
+			if first {
+				return cache.invoke(ctx, k, entry, next, value)
+			}
+
+			select {
+			case <-entry.done:
+				return entry.response, entry.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestDedupKey(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		message = &wrp.Message{Type: wrp.SimpleEventMessageType, Source: "mac:112233445566", TransactionUUID: "1234"}
+	)
+
+	key, ok := RequestDedupKey(context.Background(), message)
+	assert.True(ok)
+	assert.Equal("1234\x00mac:112233445566", key)
+
+	key, ok = RequestDedupKey(context.Background(), WrapAsRequest(logging.NewTestLogger(nil, t), message))
+	assert.True(ok)
+	assert.Equal("1234\x00mac:112233445566", key)
+
+	for _, v := range []interface{}{&wrp.Message{Source: "mac:112233445566"}, &request{}, "not a WRP request", nil} {
+		key, ok = RequestDedupKey(context.Background(), v)
+		assert.Empty(key)
+		assert.False(ok)
+	}
+}
+
+func TestDedupOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		for _, o := range []*DedupOptions{nil, new(DedupOptions)} {
+			assert := assert.New(t)
+			assert.Equal(DefaultDedupTTL, o.ttl())
+			assert.NotNil(o.key())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			called = false
+			o      = DedupOptions{
+				TTL: time.Hour,
+				Key: func(context.Context, interface{}) (string, bool) { called = true; return "", false },
+			}
+		)
+
+		assert.Equal(time.Hour, o.ttl())
+		o.key()(context.Background(), nil)
+		assert.True(called)
+	})
+}
+
+// testClock is a manually advanced clock
+type testClock struct {
+	lock    sync.Mutex
+	current time.Time
+}
+
+func (tc *testClock) now() time.Time {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	return tc.current
+}
+
+func (tc *testClock) advance(d time.Duration) {
+	tc.lock.Lock()
+	tc.current = tc.current.Add(d)
+	tc.lock.Unlock()
+}
+
+func testDedupDuplicate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = &testClock{current: time.Now()}
+		calls  = 0
+
+		endpoint = dedup(&DedupOptions{TTL: time.Minute}, clock.now)(func(_ context.Context, v interface{}) (interface{}, error) {
+			calls++
+			return calls, nil
+		})
+
+		message = &wrp.Message{Source: "mac:112233445566", TransactionUUID: "1234"}
+		other   = &wrp.Message{Source: "mac:665544332211", TransactionUUID: "1234"}
+	)
+
+	response, err := endpoint(context.Background(), message)
+	assert.Equal(1, response)
+	assert.NoError(err)
+
+	// a retransmission is answered with the original response
+	clock.advance(30 * time.Second)
+	response, err = endpoint(context.Background(), &wrp.Message{Source: "mac:112233445566", TransactionUUID: "1234"})
+	assert.Equal(1, response)
+	assert.NoError(err)
+
+	// the same transaction uuid from a different source is not a duplicate
+	response, err = endpoint(context.Background(), other)
+	assert.Equal(2, response)
+	assert.NoError(err)
+
+	// once the TTL elapses, the request is passed along again
+	clock.advance(31 * time.Second)
+	response, err = endpoint(context.Background(), message)
+	assert.Equal(3, response)
+	assert.NoError(err)
+
+	// requests without a key are never deduplicated
+	response, err = endpoint(context.Background(), &wrp.Message{Source: "mac:112233445566"})
+	assert.Equal(4, response)
+	assert.NoError(err)
+	response, err = endpoint(context.Background(), &wrp.Message{Source: "mac:112233445566"})
+	assert.Equal(5, response)
+	assert.NoError(err)
+}
+
+func testDedupError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		calls         = 0
+
+		endpoint = Dedup(nil)(func(_ context.Context, v interface{}) (interface{}, error) {
+			if calls++; calls == 1 {
+				return nil, expectedError
+			}
+
+			return calls, nil
+		})
+
+		message = &wrp.Message{Source: "mac:112233445566", TransactionUUID: "1234"}
+	)
+
+	response, err := endpoint(context.Background(), message)
+	assert.Nil(response)
+	assert.Equal(expectedError, err)
+
+	// failures are not remembered
+	response, err = endpoint(context.Background(), message)
+	assert.Equal(2, response)
+	assert.NoError(err)
+
+	response, err = endpoint(context.Background(), message)
+	assert.Equal(2, response)
+	assert.NoError(err)
+}
+
+func testDedupInProgress(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		started = make(chan struct{})
+		release = make(chan struct{})
+
+		endpoint = Dedup(nil)(func(_ context.Context, v interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return "response", nil
+		})
+
+		message = &wrp.Message{Source: "mac:112233445566", TransactionUUID: "1234"}
+		first   = make(chan interface{}, 1)
+		waiting = make(chan interface{}, 1)
+	)
+
+	go func() {
+		response, _ := endpoint(context.Background(), message)
+		first <- response
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		require.Fail("The first request was not passed to the decorated endpoint")
+	}
+
+	// a duplicate whose context is canceled gives up waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	response, err := endpoint(ctx, message)
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+
+	go func() {
+		response, _ := endpoint(context.Background(), message)
+		waiting <- response
+	}()
+
+	close(release)
+	for _, c := range []chan interface{}{first, waiting} {
+		select {
+		case response := <-c:
+			assert.Equal("response", response)
+		case <-time.After(5 * time.Second):
+			require.Fail("A request did not complete")
+		}
+	}
+}
+
+func testDedupPanic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cache  = newDedupCache(time.Minute, time.Now)
+		panics = func(context.Context, interface{}) (interface{}, error) { panic("expected") }
+	)
+
+	entry, first := cache.begin("key")
+	assert.True(first)
+
+	func() {
+		defer func() {
+			assert.Equal("expected", recover())
+		}()
+
+		cache.invoke(context.Background(), "key", entry, panics, "request")
+	}()
+
+	// duplicates waiting on the entry are released with an error, and the entry is not remembered
+	select {
+	case <-entry.done:
+		assert.Nil(entry.response)
+		assert.Equal(ErrDedupPanic, entry.err)
+	default:
+		assert.Fail("The entry of a panicked request was not finished")
+	}
+
+	assert.Empty(cache.entries)
+}
+
+func testDedupPerEndpoint(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		middleware = Dedup(nil)
+		first      = middleware(func(context.Context, interface{}) (interface{}, error) { return "first", nil })
+		second     = middleware(func(context.Context, interface{}) (interface{}, error) { return "second", nil })
+		message    = &wrp.Message{Source: "mac:112233445566", TransactionUUID: "1234"}
+	)
+
+	response, err := first(context.Background(), message)
+	assert.Equal("first", response)
+	assert.NoError(err)
+
+	response, err = second(context.Background(), message)
+	assert.Equal("second", response)
+	assert.NoError(err)
+}
+
+func testDedupSweep(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = &testClock{current: time.Now()}
+		cache  = newDedupCache(time.Minute, clock.now)
+	)
+
+	entry, first := cache.begin("expired")
+	assert.True(first)
+	cache.finish("expired", entry, "response", nil)
+
+	pending, first := cache.begin("pending")
+	assert.True(first)
+
+	clock.advance(2 * time.Minute)
+	entry, first = cache.begin("new")
+	assert.True(first)
+	cache.finish("new", entry, "response", nil)
+
+	assert.Len(cache.entries, 2)
+	assert.Contains(cache.entries, "new")
+	assert.Equal(pending, cache.entries["pending"])
+}
+
+func TestDedup(t *testing.T) {
+	t.Run("Duplicate", testDedupDuplicate)
+	t.Run("Error", testDedupError)
+	t.Run("InProgress", testDedupInProgress)
+	t.Run("Panic", testDedupPanic)
+	t.Run("PerEndpoint", testDedupPerEndpoint)
+	t.Run("Sweep", testDedupSweep)
+}
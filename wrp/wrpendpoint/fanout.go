@@ -0,0 +1,65 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ErrNotAResponse is returned by a ServiceFanout when its fanout produced something other than a WRP Response,
+// e.g. because a component was decorated with middleware that replaced its response.
+var ErrNotAResponse = errors.New("The fanout did not produce a WRP response")
+
+// ServiceFanout is a Service which concurrently sends each WRP request to a set of component Services.  The component
+// services are invoked via the middleware/fanout package, so every fanout.Option is honored.
+type ServiceFanout struct {
+	endpoint endpoint.Endpoint
+}
+
+// NewServiceFanout creates a ServiceFanout over the given component services, keyed by name.  The options are passed
+// to fanout.New as is.  If spanner is nil or services is empty, this function panics.
+func NewServiceFanout(spanner tracing.Spanner, services map[string]Service, o ...fanout.Option) *ServiceFanout {
+	components := make(fanout.Components, len(services))
+	for name, s := range services {
+		components[name] = New(s)
+	}
+
+	return &ServiceFanout{
+		endpoint: fanout.New(spanner, components, o...),
+	}
+}
+
+// Endpoint returns the underlying fanout endpoint.  This is useful when the raw fanout response is needed, such as the
+// *fanout.AggregateResponse produced by the PartialSuccess option.
+func (sf *ServiceFanout) Endpoint() endpoint.Endpoint {
+	return sf.endpoint
+}
+
+// ServeWRP fans a WRP request out to the component services.  The response of the winning component is returned with
+// the spans of every component merged into it.  When the PartialSuccess option is used, the winner is the first
+// component to succeed, and the remaining results are only available via Endpoint.  If the fanout fails, its error is
+// returned as is, which is normally a tracing.SpanError.
+func (sf *ServiceFanout) ServeWRP(ctx context.Context, r Request) (Response, error) {
+	v, err := sf.endpoint(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fr := v.(type) {
+	case Response:
+		return fr, nil
+
+	case *fanout.AggregateResponse:
+		for _, result := range fr.Succeeded() {
+			if winner, ok := result.Response.(Response); ok {
+				merged, _ := tracing.MergeSpans(winner, fr.Spans())
+				return merged.(Response), nil
+			}
+		}
+	}
+
+	return nil, ErrNotAResponse
+}
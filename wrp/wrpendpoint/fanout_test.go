@@ -0,0 +1,121 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testServiceFanoutSuccess(t *testing.T, o ...fanout.Option) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request  = WrapAsRequest(logging.NewTestLogger(nil, t), &wrp.Message{Destination: "mac:112233445566"})
+		expected = &wrp.Message{Source: "mac:112233445566"}
+
+		success = new(mockService)
+		failure = new(mockService)
+		sf      = NewServiceFanout(
+			tracing.NewSpanner(),
+			map[string]Service{"success": success, "failure": failure},
+			o...,
+		)
+	)
+
+	success.On("ServeWRP", mock.Anything, request).Return(WrapAsResponse(expected), nil).Once()
+	failure.On("ServeWRP", mock.Anything, request).Return(nil, errors.New("expected")).Once()
+
+	response, err := sf.ServeWRP(context.Background(), request)
+	require.NoError(err)
+	require.NotNil(response)
+	assert.Equal(expected, response.Message())
+	assert.NotEmpty(response.Spans())
+	for _, s := range response.Spans() {
+		assert.Contains([]string{"success", "failure"}, s.Name())
+	}
+
+	assert.NotNil(sf.Endpoint())
+}
+
+func testServiceFanoutFailure(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		request       = WrapAsRequest(logging.NewTestLogger(nil, t), &wrp.Message{Destination: "mac:112233445566"})
+		expectedError = errors.New("expected")
+
+		component1 = new(mockService)
+		component2 = new(mockService)
+		sf         = NewServiceFanout(
+			tracing.NewSpanner(),
+			map[string]Service{"component1": component1, "component2": component2},
+		)
+	)
+
+	component1.On("ServeWRP", mock.Anything, request).Return(nil, expectedError).Once()
+	component2.On("ServeWRP", mock.Anything, request).Return(nil, expectedError).Once()
+
+	response, err := sf.ServeWRP(context.Background(), request)
+	assert.Nil(response)
+	spanError, ok := err.(tracing.SpanError)
+	require.True(t, ok)
+	assert.Equal(expectedError, spanError.Err())
+	assert.Len(spanError.Spans(), 2)
+
+	component1.AssertExpectations(t)
+	component2.AssertExpectations(t)
+}
+
+func testServiceFanoutNotAResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = WrapAsRequest(logging.NewTestLogger(nil, t), &wrp.Message{Destination: "mac:112233445566"})
+
+		component = new(mockService)
+		sf        = NewServiceFanout(
+			tracing.NewSpanner(),
+			map[string]Service{"component": component},
+			fanout.PartialSuccess(),
+			fanout.TransformRequest(func(string, interface{}) interface{} { return "not a WRP request" }),
+		)
+	)
+
+	// the transformed request causes the component to panic, so there is no success
+	response, err := sf.ServeWRP(context.Background(), request)
+	assert.Nil(response)
+	assert.Error(err)
+	component.AssertNotCalled(t, "ServeWRP", mock.Anything, mock.Anything)
+
+	sf.endpoint = func(context.Context, interface{}) (interface{}, error) {
+		return &fanout.AggregateResponse{Results: []fanout.Result{{Name: "component", Response: "not a WRP response"}}}, nil
+	}
+
+	response, err = sf.ServeWRP(context.Background(), request)
+	assert.Nil(response)
+	assert.Equal(ErrNotAResponse, err)
+}
+
+func TestServiceFanout(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		t.Run("FirstSuccess", func(t *testing.T) { testServiceFanoutSuccess(t) })
+		t.Run("PartialSuccess", func(t *testing.T) { testServiceFanoutSuccess(t, fanout.PartialSuccess()) })
+	})
+
+	t.Run("Failure", testServiceFanoutFailure)
+	t.Run("NotAResponse", testServiceFanoutNotAResponse)
+
+	t.Run("NoServices", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewServiceFanout(tracing.NewSpanner(), nil)
+		})
+	})
+}
@@ -0,0 +1,187 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ErrShed is the default error returned for requests that are shed by Prioritize
+var ErrShed = errors.New("WRP request shed due to load")
+
+// QOSFunc extracts the QOS value from an endpoint request
+type QOSFunc func(context.Context, interface{}) wrp.QOSValue
+
+// RequestQOS is the default QOSFunc.  It handles any Note, including Request, as well as *wrp.Message.
+// Any other value, or a Note without a decoded message, is treated as wrp.QOSLowValue.
+func RequestQOS(_ context.Context, v interface{}) wrp.QOSValue {
+	switch r := v.(type) {
+	case Note:
+		if m := r.Message(); m != nil {
+			return m.QualityOfService
+		}
+	case *wrp.Message:
+		return r.QualityOfService
+	}
+
+	return wrp.QOSLowValue
+}
+
+// qosWaiter is a request waiting for a slot.  The ready channel receives true when the request is admitted,
+// or false when the request was displaced from the queue by a higher priority request.
+type qosWaiter struct {
+	ready chan bool
+}
+
+// qosQueue tracks in-flight requests along with the requests waiting for a slot, grouped by QOS level
+type qosQueue struct {
+	lock          sync.Mutex
+	maxConcurrent int
+	maxQueued     int
+	inFlight      int
+	queued        int
+	waiters       [][]*qosWaiter
+}
+
+func newQOSQueue(maxConcurrent, maxQueued int) *qosQueue {
+	return &qosQueue{
+		maxConcurrent: maxConcurrent,
+		maxQueued:     maxQueued,
+		waiters:       make([][]*qosWaiter, len(wrp.QOSLevels())),
+	}
+}
+
+// acquire obtains a slot for a request at the given level.  If no slot is available, the request is queued.
+// When the queue is full, the oldest waiting request at the lowest level below the given level is shed to make room.
+// If there is no such request, this request is shed instead and this method returns false.
+func (q *qosQueue) acquire(ctx context.Context, level wrp.QOSLevel) (bool, error) {
+	q.lock.Lock()
+	if q.inFlight < q.maxConcurrent {
+		q.inFlight++
+		q.lock.Unlock()
+		return true, nil
+	}
+
+	if q.queued >= q.maxQueued && !q.displace(level) {
+		q.lock.Unlock()
+		return false, nil
+	}
+
+	w := &qosWaiter{ready: make(chan bool, 1)}
+	q.waiters[level] = append(q.waiters[level], w)
+	q.queued++
+	q.lock.Unlock()
+
+	select {
+	case admitted := <-w.ready:
+		return admitted, nil
+
+	case <-ctx.Done():
+		q.lock.Lock()
+		removed := q.remove(level, w)
+		q.lock.Unlock()
+
+		if !removed {
+			// the waiter was signaled concurrently with the cancellation
+			if <-w.ready {
+				q.release()
+			}
+		}
+
+		return false, ctx.Err()
+	}
+}
+
+// displace sheds the oldest waiter at the lowest level below the given level.  This method must be
+// called under the lock.
+func (q *qosQueue) displace(level wrp.QOSLevel) bool {
+	for l := wrp.QOSLow; l < level; l++ {
+		if len(q.waiters[l]) > 0 {
+			w := q.waiters[l][0]
+			q.waiters[l] = q.waiters[l][1:]
+			q.queued--
+			w.ready <- false
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove takes a waiter out of the queue, returning false if the waiter was no longer queued.  This method
+// must be called under the lock.
+func (q *qosQueue) remove(level wrp.QOSLevel, w *qosWaiter) bool {
+	for i, candidate := range q.waiters[level] {
+		if candidate == w {
+			q.waiters[level] = append(q.waiters[level][:i], q.waiters[level][i+1:]...)
+			q.queued--
+			return true
+		}
+	}
+
+	return false
+}
+
+// release frees a slot, handing it directly to the oldest waiter at the highest level if one exists
+func (q *qosQueue) release() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for l := len(q.waiters) - 1; l >= 0; l-- {
+		if len(q.waiters[l]) > 0 {
+			w := q.waiters[l][0]
+			q.waiters[l] = q.waiters[l][1:]
+			q.queued--
+			w.ready <- true
+			return
+		}
+	}
+
+	q.inFlight--
+}
+
+// Prioritize produces a go-kit middleware that limits the number of concurrent requests using their WRP QOS levels.
+// When maxConcurrent requests are in flight, new requests wait in a queue of at most maxQueued requests.  Whenever a slot
+// frees up, the oldest waiting request with the highest QOS level is admitted.  When the queue is full, a new request
+// displaces the oldest waiting request with the lowest QOS level below its own, so lower priority traffic is shed first.
+// If no such request is waiting, the new request is shed.
+//
+// A request that waits until its context is canceled fails with the context's error.  Shed requests fail with shedError,
+// or ErrShed if shedError is nil.  If qos is nil, RequestQOS is used.  If maxConcurrent is nonpositive or maxQueued is
+// negative, this function panics.
+func Prioritize(maxConcurrent, maxQueued int, qos QOSFunc, shedError error) endpoint.Middleware {
+	if maxConcurrent < 1 {
+		panic("maxConcurrent must be positive")
+	}
+
+	if maxQueued < 0 {
+		panic("maxQueued cannot be negative")
+	}
+
+	if qos == nil {
+		qos = RequestQOS
+	}
+
+	if shedError == nil {
+		shedError = ErrShed
+	}
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		q := newQOSQueue(maxConcurrent, maxQueued)
+
+		return func(ctx context.Context, value interface{}) (interface{}, error) {
+			admitted, err := q.acquire(ctx, qos(ctx, value).Level())
+			if err != nil {
+				return nil, err
+			} else if !admitted {
+				return nil, shedError
+			}
+
+			defer q.release()
+			return next(ctx, value)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestQOS(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		message = &wrp.Message{QualityOfService: wrp.QOSHighValue}
+	)
+
+	assert.Equal(wrp.QOSHighValue, RequestQOS(context.Background(), message))
+	assert.Equal(wrp.QOSHighValue, RequestQOS(context.Background(), WrapAsRequest(logging.NewTestLogger(nil, t), message)))
+	assert.Equal(wrp.QOSHighValue, RequestQOS(context.Background(), WrapAsResponse(message)))
+	assert.Equal(wrp.QOSLowValue, RequestQOS(context.Background(), &request{}))
+	assert.Equal(wrp.QOSLowValue, RequestQOS(context.Background(), "not a WRP request"))
+}
+
+// waitForQueued blocks until the given number of requests are waiting in the queue
+func waitForQueued(t *testing.T, q *qosQueue, expected int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		q.lock.Lock()
+		queued := q.queued
+		q.lock.Unlock()
+
+		if queued == expected {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("Timed out waiting for %d queued requests", expected)
+}
+
+type qosOutcome struct {
+	name     string
+	admitted bool
+	err      error
+}
+
+func acquireAsync(q *qosQueue, ctx context.Context, name string, level wrp.QOSLevel, outcomes chan<- qosOutcome) {
+	go func() {
+		admitted, err := q.acquire(ctx, level)
+		outcomes <- qosOutcome{name, admitted, err}
+	}()
+}
+
+func testQOSQueueOrder(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		q        = newQOSQueue(1, 10)
+		outcomes = make(chan qosOutcome, 4)
+	)
+
+	admitted, err := q.acquire(context.Background(), wrp.QOSLow)
+	require.True(admitted)
+	require.NoError(err)
+
+	acquireAsync(q, context.Background(), "low", wrp.QOSLow, outcomes)
+	waitForQueued(t, q, 1)
+	acquireAsync(q, context.Background(), "critical", wrp.QOSCritical, outcomes)
+	waitForQueued(t, q, 2)
+	acquireAsync(q, context.Background(), "medium1", wrp.QOSMedium, outcomes)
+	waitForQueued(t, q, 3)
+	acquireAsync(q, context.Background(), "medium2", wrp.QOSMedium, outcomes)
+	waitForQueued(t, q, 4)
+
+	for _, expected := range []string{"critical", "medium1", "medium2", "low"} {
+		q.release()
+		outcome := <-outcomes
+		assert.Equal(expected, outcome.name)
+		assert.True(outcome.admitted)
+		assert.NoError(outcome.err)
+	}
+
+	q.release()
+	assert.Zero(q.inFlight)
+	assert.Zero(q.queued)
+}
+
+func testQOSQueueShedding(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		q        = newQOSQueue(1, 1)
+		outcomes = make(chan qosOutcome, 3)
+	)
+
+	admitted, err := q.acquire(context.Background(), wrp.QOSCritical)
+	require.True(admitted)
+	require.NoError(err)
+
+	acquireAsync(q, context.Background(), "low", wrp.QOSLow, outcomes)
+	waitForQueued(t, q, 1)
+
+	// a higher level request displaces the queued low request
+	acquireAsync(q, context.Background(), "high", wrp.QOSHigh, outcomes)
+	outcome := <-outcomes
+	assert.Equal("low", outcome.name)
+	assert.False(outcome.admitted)
+	assert.NoError(outcome.err)
+	waitForQueued(t, q, 1)
+
+	// requests at the same or lower levels are shed immediately
+	for _, level := range []wrp.QOSLevel{wrp.QOSLow, wrp.QOSHigh} {
+		admitted, err := q.acquire(context.Background(), level)
+		assert.False(admitted)
+		assert.NoError(err)
+	}
+
+	q.release()
+	outcome = <-outcomes
+	assert.Equal("high", outcome.name)
+	assert.True(outcome.admitted)
+
+	q.release()
+	assert.Zero(q.inFlight)
+}
+
+func testQOSQueueCancel(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		q           = newQOSQueue(1, 1)
+		outcomes    = make(chan qosOutcome, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	admitted, err := q.acquire(context.Background(), wrp.QOSLow)
+	require.True(admitted)
+	require.NoError(err)
+
+	acquireAsync(q, ctx, "canceled", wrp.QOSMedium, outcomes)
+	waitForQueued(t, q, 1)
+	cancel()
+
+	outcome := <-outcomes
+	assert.False(outcome.admitted)
+	assert.Equal(context.Canceled, outcome.err)
+	assert.Zero(q.queued)
+
+	q.release()
+	assert.Zero(q.inFlight)
+}
+
+func TestQOSQueue(t *testing.T) {
+	t.Run("Order", testQOSQueueOrder)
+	t.Run("Shedding", testQOSQueueShedding)
+	t.Run("Cancel", testQOSQueueCancel)
+}
+
+func testPrioritizeInvalidParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { Prioritize(0, 1, nil, nil) })
+	assert.Panics(func() { Prioritize(1, -1, nil, nil) })
+}
+
+func testPrioritizeShed(t *testing.T, shedError, expectedError error) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		unblock = make(chan struct{})
+		entered = make(chan struct{})
+		done    = make(chan error, 1)
+
+		qos = func(_ context.Context, v interface{}) wrp.QOSValue {
+			return v.(wrp.QOSValue)
+		}
+
+		prioritized = Prioritize(1, 0, qos, shedError)(func(ctx context.Context, v interface{}) (interface{}, error) {
+			close(entered)
+			<-unblock
+			return "response", nil
+		})
+	)
+
+	go func() {
+		response, err := prioritized(context.Background(), wrp.QOSLowValue)
+		assert.Equal("response", response)
+		done <- err
+	}()
+
+	<-entered
+	response, err := prioritized(context.Background(), wrp.QOSCriticalValue)
+	assert.Nil(response)
+	assert.Equal(expectedError, err)
+
+	close(unblock)
+	require.NoError(<-done)
+}
+
+func testPrioritizeCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+		unblock     = make(chan struct{})
+		entered     = make(chan struct{})
+
+		prioritized = Prioritize(1, 1, nil, nil)(func(ctx context.Context, v interface{}) (interface{}, error) {
+			close(entered)
+			<-unblock
+			return "response", nil
+		})
+	)
+
+	defer close(unblock)
+	go prioritized(context.Background(), WrapAsRequest(logging.NewTestLogger(nil, t), new(wrp.Message)))
+	<-entered
+
+	cancel()
+	response, err := prioritized(ctx, WrapAsRequest(logging.NewTestLogger(nil, t), new(wrp.Message)))
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+}
+
+func TestPrioritize(t *testing.T) {
+	t.Run("InvalidParameters", testPrioritizeInvalidParameters)
+
+	t.Run("Shed", func(t *testing.T) {
+		testPrioritizeShed(t, nil, ErrShed)
+
+		expectedError := errors.New("expected")
+		testPrioritizeShed(t, expectedError, expectedError)
+	})
+
+	t.Run("Canceled", testPrioritizeCanceled)
+}
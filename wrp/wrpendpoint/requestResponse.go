@@ -1,8 +1,9 @@
 package wrpendpoint
 
 import (
+	"bytes"
 	"io"
-	"io/ioutil"
+	"sync"
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/tracing"
@@ -127,9 +128,32 @@ func withLogger(logger log.Logger, m *wrp.Message, keyvals ...interface{}) log.L
 	)
 }
 
+// readBuffers holds the buffers used to read encoded messages from io.Readers
+var readBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// readContents reads the entire source using a pooled buffer.  Since the contents are retained by the
+// decoded Note, they are copied exactly once into a slice of the correct size.
+func readContents(source io.Reader) ([]byte, error) {
+	buffer := readBuffers.Get().(*bytes.Buffer)
+	defer readBuffers.Put(buffer)
+
+	buffer.Reset()
+	if _, err := buffer.ReadFrom(source); err != nil {
+		return nil, err
+	}
+
+	contents := make([]byte, buffer.Len())
+	copy(contents, buffer.Bytes())
+	return contents, nil
+}
+
 // DecodeRequest extracts a WRP request from the given source.
 func DecodeRequest(logger log.Logger, source io.Reader, pool *wrp.DecoderPool) (Request, error) {
-	contents, err := ioutil.ReadAll(source)
+	contents, err := readContents(source)
 	if err != nil {
 		return nil, err
 	}
@@ -138,6 +162,7 @@ func DecodeRequest(logger log.Logger, source io.Reader, pool *wrp.DecoderPool) (
 }
 
 // DecodeRequestBytes returns a Request taken from the contents.  The given pool is used to decode the WRP message.
+// A compressed payload is transparently decompressed, while the original contents are retained as is.
 //
 // This function also enhances the given logger with contextual information about the returned WRP request.  The
 // logger that is passed to this function should never be nil and should never have a Caller or DefaultCaller set.
@@ -151,6 +176,10 @@ func DecodeRequestBytes(logger log.Logger, contents []byte, pool *wrp.DecoderPoo
 		return nil, err
 	}
 
+	if _, err := wrp.DecompressPayload(m); err != nil {
+		return nil, err
+	}
+
 	return &request{
 		note: note{
 			destination:   m.Destination,
@@ -206,7 +235,7 @@ func (r *response) WithSpans(spans ...tracing.Span) interface{} {
 
 // DecodeResponse extracts a WRP response from the given source.
 func DecodeResponse(source io.Reader, pool *wrp.DecoderPool) (Response, error) {
-	contents, err := ioutil.ReadAll(source)
+	contents, err := readContents(source)
 	if err != nil {
 		return nil, err
 	}
@@ -215,6 +244,7 @@ func DecodeResponse(source io.Reader, pool *wrp.DecoderPool) (Response, error) {
 }
 
 // DecodeResponseBytes returns a Response taken from the contents.  The given pool is used to decode the WRP message.
+// As with DecodeRequestBytes, a compressed payload is transparently decompressed.
 func DecodeResponseBytes(contents []byte, pool *wrp.DecoderPool) (Response, error) {
 	d := pool.Get()
 	defer pool.Put(d)
@@ -225,6 +255,10 @@ func DecodeResponseBytes(contents []byte, pool *wrp.DecoderPool) (Response, erro
 		return nil, err
 	}
 
+	if _, err := wrp.DecompressPayload(m); err != nil {
+		return nil, err
+	}
+
 	return &response{
 		note: note{
 			destination:   m.Destination,
@@ -396,3 +396,56 @@ func TestResponse(t *testing.T) {
 		})
 	}
 }
+
+func testDecodeCompressedPayload(t *testing.T, format wrp.Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pool    = wrp.NewDecoderPool(1, format)
+
+		original = bytes.Repeat([]byte("TR-181 parameter data "), 100)
+		message  = wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "test",
+			Destination: "mac:111122223333",
+			Payload:     original,
+		}
+
+		encoded []byte
+	)
+
+	compressed, err := wrp.CompressPayload(&message, 1)
+	require.True(compressed)
+	require.NoError(err)
+	require.NoError(wrp.NewEncoderBytes(&encoded, format).Encode(&message))
+
+	request, err := DecodeRequestBytes(nil, encoded, pool)
+	require.NoError(err)
+	assert.Equal(original, request.Message().Payload)
+	assert.Empty(request.Message().PayloadEncoding())
+
+	response, err := DecodeResponseBytes(encoded, pool)
+	require.NoError(err)
+	assert.Equal(original, response.Message().Payload)
+	assert.Empty(response.Message().PayloadEncoding())
+
+	// an unsupported encoding is a decoding error
+	message.Metadata = map[string]string{wrp.ContentEncodingKey: "unsupported"}
+	require.NoError(wrp.NewEncoderBytes(&encoded, format).Encode(&message))
+
+	request, err = DecodeRequestBytes(nil, encoded, pool)
+	assert.Nil(request)
+	assert.Equal(wrp.ErrUnsupportedContentEncoding, err)
+
+	response, err = DecodeResponseBytes(encoded, pool)
+	assert.Nil(response)
+	assert.Equal(wrp.ErrUnsupportedContentEncoding, err)
+}
+
+func TestDecodeCompressedPayload(t *testing.T) {
+	for _, format := range wrp.AllFormats() {
+		t.Run(format.String(), func(t *testing.T) {
+			testDecodeCompressedPayload(t, format)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package wrpendpoint
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+)
+
+const (
+	DefaultRetries    = 2
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// IdempotentFunc determines if an endpoint request can be safely retried
+type IdempotentFunc func(context.Context, interface{}) bool
+
+// RequestIdempotent is the default IdempotentFunc.  It handles any Note, including Request, as well as *wrp.Message,
+// using the message type to determine idempotency.  Any other value, or a Note without a decoded message, is never retried.
+func RequestIdempotent(_ context.Context, v interface{}) bool {
+	switch r := v.(type) {
+	case Note:
+		if m := r.Message(); m != nil {
+			return m.Type.IsIdempotent()
+		}
+	case *wrp.Message:
+		return r.Type.IsIdempotent()
+	}
+
+	return false
+}
+
+// RetryOptions describes the configuration of the Retry middleware.  A nil RetryOptions is valid
+// and uses the default for each setting.
+type RetryOptions struct {
+	// Retries is the maximum number of times a failed request is retried after the first attempt.
+	// If not supplied, DefaultRetries is used.
+	Retries int
+
+	// Backoff is the time to wait before the first retry.  The wait doubles for each subsequent retry.
+	// If not supplied, DefaultBackoff is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the upper limit on the time to wait between attempts.  If not supplied, DefaultMaxBackoff is used.
+	MaxBackoff time.Duration
+
+	// Spanner is used to record a span for each attempt.  If not supplied, tracing.NewSpanner() is used.
+	Spanner tracing.Spanner
+
+	// Idempotent determines which requests are retried.  If not supplied, RequestIdempotent is used.
+	Idempotent IdempotentFunc
+
+	// Retryable determines if a given error should be retried.  If not supplied, all errors are retried.
+	Retryable func(error) bool
+}
+
+func (o *RetryOptions) retries() int {
+	if o != nil && o.Retries > 0 {
+		return o.Retries
+	}
+
+	return DefaultRetries
+}
+
+func (o *RetryOptions) backoff() time.Duration {
+	if o != nil && o.Backoff > 0 {
+		return o.Backoff
+	}
+
+	return DefaultBackoff
+}
+
+func (o *RetryOptions) maxBackoff() time.Duration {
+	if o != nil && o.MaxBackoff > 0 {
+		return o.MaxBackoff
+	}
+
+	return DefaultMaxBackoff
+}
+
+func (o *RetryOptions) spanner() tracing.Spanner {
+	if o != nil && o.Spanner != nil {
+		return o.Spanner
+	}
+
+	return tracing.NewSpanner()
+}
+
+func (o *RetryOptions) idempotent() IdempotentFunc {
+	if o != nil && o.Idempotent != nil {
+		return o.Idempotent
+	}
+
+	return RequestIdempotent
+}
+
+func (o *RetryOptions) retryable() func(error) bool {
+	if o != nil && o.Retryable != nil {
+		return o.Retryable
+	}
+
+	return func(error) bool { return true }
+}
+
+// Retry produces a go-kit middleware that retries failed idempotent requests.  Requests that are not idempotent
+// are passed to the decorated endpoint exactly once, without any tracing.  Each attempt of an idempotent request is
+// recorded as a span named attempt-N, where N begins at 1.  Between attempts, the middleware waits for an exponentially
+// increasing backoff, bounded by the configured limit.
+//
+// A successful response has the attempts' spans merged into it, if it is tracing.Mergeable.  When every attempt fails,
+// the last error is returned with the spans merged into it, or annotated via tracing.NewSpanError.  If the context
+// is canceled while waiting for a retry, the context's error is returned in the same way.
+func Retry(o *RetryOptions) endpoint.Middleware {
+	var (
+		retries    = o.retries()
+		backoff    = o.backoff()
+		maxBackoff = o.maxBackoff()
+		spanner    = o.spanner()
+		idempotent = o.idempotent()
+		retryable  = o.retryable()
+	)
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, value interface{}) (interface{}, error) {
+			if !idempotent(ctx, value) {
+				return next(ctx, value)
+			}
+
+			var (
+				spans = make([]tracing.Span, 0, retries+1)
+				wait  = backoff
+			)
+
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+
+			for attempt := 1; ; attempt++ {
+				finisher := spanner.Start(fmt.Sprintf("attempt-%d", attempt))
+				response, err := next(ctx, value)
+				spans = append(spans, finisher(err))
+
+				if err == nil {
+					merged, _ := tracing.MergeSpans(response, spans)
+					return merged, nil
+				}
+
+				if attempt > retries || !retryable(err) {
+					return nil, spanError(err, spans)
+				}
+
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, spanError(ctx.Err(), spans)
+				}
+
+				if wait *= 2; wait > maxBackoff {
+					wait = maxBackoff
+				}
+			}
+		}
+	}
+}
+
+// spanError merges spans into an error, creating a tracing.SpanError if the error does not already carry spans
+func spanError(err error, spans []tracing.Span) error {
+	if merged, ok := tracing.MergeSpans(err, spans); ok {
+		return merged.(error)
+	}
+
+	return tracing.NewSpanError(err, spans...)
+}
@@ -0,0 +1,226 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIdempotent(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		retrieve = &wrp.Message{Type: wrp.RetrieveMessageType}
+		create   = &wrp.Message{Type: wrp.CreateMessageType}
+	)
+
+	assert.True(RequestIdempotent(context.Background(), retrieve))
+	assert.True(RequestIdempotent(context.Background(), WrapAsRequest(logging.NewTestLogger(nil, t), retrieve)))
+	assert.False(RequestIdempotent(context.Background(), create))
+	assert.False(RequestIdempotent(context.Background(), WrapAsRequest(logging.NewTestLogger(nil, t), create)))
+	assert.False(RequestIdempotent(context.Background(), &request{}))
+	assert.False(RequestIdempotent(context.Background(), "not a WRP request"))
+}
+
+func TestRetryOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		for _, o := range []*RetryOptions{nil, new(RetryOptions)} {
+			t.Run(func() string {
+				if o == nil {
+					return "Nil"
+				}
+
+				return "Empty"
+			}(), func(t *testing.T) {
+				assert := assert.New(t)
+
+				assert.Equal(DefaultRetries, o.retries())
+				assert.Equal(DefaultBackoff, o.backoff())
+				assert.Equal(DefaultMaxBackoff, o.maxBackoff())
+				assert.NotNil(o.spanner())
+				assert.NotNil(o.idempotent())
+				assert.True(o.retryable()(errors.New("expected")))
+			})
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			spanner = tracing.NewSpanner()
+			o       = RetryOptions{
+				Retries:    5,
+				Backoff:    time.Second,
+				MaxBackoff: time.Minute,
+				Spanner:    spanner,
+				Idempotent: func(context.Context, interface{}) bool { return true },
+				Retryable:  func(error) bool { return false },
+			}
+		)
+
+		assert.Equal(5, o.retries())
+		assert.Equal(time.Second, o.backoff())
+		assert.Equal(time.Minute, o.maxBackoff())
+		assert.Equal(spanner, o.spanner())
+		assert.True(o.idempotent()(context.Background(), "anything"))
+		assert.False(o.retryable()(errors.New("expected")))
+	})
+}
+
+// failingEndpoint produces an endpoint that fails the given number of times before returning the response
+func failingEndpoint(failures int, failure error, response interface{}) (func(context.Context, interface{}) (interface{}, error), *int) {
+	calls := new(int)
+	return func(context.Context, interface{}) (interface{}, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, failure
+		}
+
+		return response, nil
+	}, calls
+}
+
+func testRetrySuccess(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		message  = &wrp.Message{Type: wrp.RetrieveMessageType}
+		expected = WrapAsResponse(message)
+
+		next, calls = failingEndpoint(2, errors.New("expected"), expected)
+		retry       = Retry(&RetryOptions{Retries: 3, Backoff: time.Millisecond})(next)
+	)
+
+	actual, err := retry(context.Background(), message)
+	require.NoError(err)
+	assert.Equal(3, *calls)
+
+	spans, ok := tracing.Spans(actual)
+	require.True(ok)
+	require.Len(spans, 3)
+	for i, name := range []string{"attempt-1", "attempt-2", "attempt-3"} {
+		assert.Equal(name, spans[i].Name())
+	}
+
+	assert.Error(spans[0].Error())
+	assert.Error(spans[1].Error())
+	assert.NoError(spans[2].Error())
+	assert.Equal(message, actual.(Response).Message())
+}
+
+func testRetryExhausted(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		next, calls = failingEndpoint(10, expectedError, nil)
+		retry       = Retry(&RetryOptions{Retries: 2, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})(next)
+	)
+
+	actual, err := retry(context.Background(), &wrp.Message{Type: wrp.DeleteMessageType})
+	assert.Nil(actual)
+	assert.Equal(3, *calls)
+
+	require.Error(err)
+	spanError, ok := err.(tracing.SpanError)
+	require.True(ok)
+	assert.Equal(expectedError, spanError.Err())
+	assert.Len(spanError.Spans(), 3)
+}
+
+func testRetryNotRetryable(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		next, calls = failingEndpoint(10, expectedError, nil)
+		retry       = Retry(&RetryOptions{
+			Backoff:   time.Millisecond,
+			Retryable: func(err error) bool { return err != expectedError },
+		})(next)
+	)
+
+	_, err := retry(context.Background(), &wrp.Message{Type: wrp.UpdateMessageType})
+	assert.Equal(1, *calls)
+
+	spanError, ok := err.(tracing.SpanError)
+	require.True(ok)
+	assert.Equal(expectedError, spanError.Err())
+	assert.Len(spanError.Spans(), 1)
+}
+
+func testRetryNotIdempotent(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+
+		next, calls = failingEndpoint(10, expectedError, nil)
+		retry       = Retry(&RetryOptions{Backoff: time.Millisecond})(next)
+	)
+
+	actual, err := retry(context.Background(), &wrp.Message{Type: wrp.CreateMessageType})
+	assert.Nil(actual)
+	assert.Equal(expectedError, err)
+	assert.Equal(1, *calls)
+}
+
+func testRetryMergesErrorSpans(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		existing = tracing.NewSpanner().Start("existing")(nil)
+
+		next, _ = failingEndpoint(10, tracing.NewSpanError(errors.New("expected"), existing), nil)
+		retry   = Retry(&RetryOptions{Retries: 1, Backoff: time.Millisecond})(next)
+	)
+
+	_, err := retry(context.Background(), &wrp.Message{Type: wrp.RetrieveMessageType})
+	spanError, ok := err.(tracing.SpanError)
+	require.True(ok)
+	require.Len(spanError.Spans(), 3)
+	assert.Equal("existing", spanError.Spans()[0].Name())
+	assert.Equal("attempt-1", spanError.Spans()[1].Name())
+	assert.Equal("attempt-2", spanError.Spans()[2].Name())
+}
+
+func testRetryCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+
+		calls = 0
+		next  = func(context.Context, interface{}) (interface{}, error) {
+			calls++
+			cancel()
+			return nil, errors.New("expected")
+		}
+
+		retry = Retry(&RetryOptions{Backoff: time.Hour})(next)
+	)
+
+	_, err := retry(ctx, &wrp.Message{Type: wrp.RetrieveMessageType})
+	assert.Equal(1, calls)
+
+	spanError, ok := err.(tracing.SpanError)
+	require.True(ok)
+	assert.Equal(context.Canceled, spanError.Err())
+	assert.Len(spanError.Spans(), 1)
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("Success", testRetrySuccess)
+	t.Run("Exhausted", testRetryExhausted)
+	t.Run("NotRetryable", testRetryNotRetryable)
+	t.Run("NotIdempotent", testRetryNotIdempotent)
+	t.Run("MergesErrorSpans", testRetryMergesErrorSpans)
+	t.Run("Canceled", testRetryCanceled)
+}
@@ -0,0 +1,79 @@
+package wrpendpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// DefaultTimeout is the per-request timeout used by Timeout when no timeout is supplied
+const DefaultTimeout time.Duration = 30 * time.Second
+
+// TimeoutError is returned by the Timeout middleware when a request does not complete before its deadline.
+// This type implements go-kit's StatusCoder, so that HTTP transports report expired requests with a 504 status.
+type TimeoutError struct {
+	// Timeout is the per-request timeout that expired
+	Timeout time.Duration
+}
+
+func (te *TimeoutError) Error() string {
+	return fmt.Sprintf("The WRP request did not complete within %s", te.Timeout)
+}
+
+// StatusCode returns http.StatusGatewayTimeout, which is also the WRP status used for expired requests
+func (te *TimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// timeoutResult is the outcome of invoking the decorated endpoint
+type timeoutResult struct {
+	response interface{}
+	err      error
+}
+
+// Timeout produces a go-kit middleware that enforces a deadline on each request.  The decorated endpoint receives
+// a context with the deadline applied, and the context's cancellation function is always called.  If the deadline
+// expires before the decorated endpoint returns, this middleware immediately returns a *TimeoutError, even if the
+// decorated endpoint does not honor its context.  An error from the decorated endpoint caused by the expired
+// deadline is likewise converted to a *TimeoutError.
+//
+// If the caller's context is canceled or reaches its own deadline first, the caller's context error is returned
+// instead.  If timeout is nonpositive, DefaultTimeout is used.
+func Timeout(timeout time.Duration) endpoint.Middleware {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, value interface{}) (interface{}, error) {
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			// buffered, so that the decorated endpoint never blocks after this middleware has returned
+			result := make(chan timeoutResult, 1)
+			go func() {
+				response, err := next(timeoutCtx, value)
+				result <- timeoutResult{response, err}
+			}()
+
+			select {
+			case r := <-result:
+				if r.err != nil && timeoutCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+					return nil, &TimeoutError{Timeout: timeout}
+				}
+
+				return r.response, r.err
+
+			case <-timeoutCtx.Done():
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				return nil, &TimeoutError{Timeout: timeout}
+			}
+		}
+	}
+}
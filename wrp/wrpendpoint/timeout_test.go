@@ -0,0 +1,95 @@
+package wrpendpoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	gokithttp "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutError(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		err    error = &TimeoutError{Timeout: 15 * time.Second}
+	)
+
+	assert.Contains(err.Error(), "15s")
+
+	statusCoder, ok := err.(gokithttp.StatusCoder)
+	assert.True(ok)
+	assert.Equal(http.StatusGatewayTimeout, statusCoder.StatusCode())
+}
+
+func testTimeoutSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		timeout = Timeout(0)(func(ctx context.Context, value interface{}) (interface{}, error) {
+			deadline, ok := ctx.Deadline()
+			assert.True(ok)
+			assert.WithinDuration(time.Now().Add(DefaultTimeout), deadline, time.Second)
+			return "response", nil
+		})
+	)
+
+	response, err := timeout(context.Background(), "request")
+	assert.Equal("response", response)
+	assert.NoError(err)
+}
+
+func testTimeoutError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		timeout       = Timeout(time.Hour)(func(context.Context, interface{}) (interface{}, error) {
+			return nil, expectedError
+		})
+	)
+
+	response, err := timeout(context.Background(), "request")
+	assert.Nil(response)
+	assert.Equal(expectedError, err)
+}
+
+func testTimeoutExpired(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		release = make(chan struct{})
+		timeout = Timeout(10 * time.Millisecond)(func(context.Context, interface{}) (interface{}, error) {
+			// ignores its context entirely
+			<-release
+			return "too late", nil
+		})
+	)
+
+	defer close(release)
+	response, err := timeout(context.Background(), "request")
+	assert.Nil(response)
+	assert.Equal(&TimeoutError{Timeout: 10 * time.Millisecond}, err)
+}
+
+func testTimeoutCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+		timeout     = Timeout(time.Hour)(func(ctx context.Context, value interface{}) (interface{}, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	)
+
+	response, err := timeout(ctx, "request")
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("Success", testTimeoutSuccess)
+	t.Run("Error", testTimeoutError)
+	t.Run("Expired", testTimeoutExpired)
+	t.Run("Canceled", testTimeoutCanceled)
+}
@@ -0,0 +1,109 @@
+package wrphttp
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/tracing/tracinghttp"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+const (
+	// ComponentHeader is the multipart part header holding the name of the fanout component that produced the part
+	ComponentHeader = "X-Xmidt-Component"
+
+	// ComponentStatusHeader is the multipart part header holding the HTTP status code which describes the outcome
+	// of the component that produced the part
+	ComponentStatusHeader = "X-Xmidt-Component-Status"
+
+	// MultipartMixedContentType is the media type of aggregate responses, without the boundary parameter
+	MultipartMixedContentType = "multipart/mixed"
+)
+
+// componentStatusCode determines the HTTP status code reported for a component.  Errors that do not
+// implement go-kit's StatusCoder are treated as internal server errors.
+func componentStatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	if coder, ok := err.(gokithttp.StatusCoder); ok {
+		return coder.StatusCode()
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeComponentPart writes a single fanout component's result as a part of a multipart entity
+func writeComponentPart(writer *multipart.Writer, timeLayout string, pool *wrp.EncoderPool, r fanout.Result) error {
+	var (
+		header = make(textproto.MIMEHeader)
+		body   bytes.Buffer
+	)
+
+	header.Set(ComponentHeader, r.Name)
+	header.Set(ComponentStatusHeader, strconv.Itoa(componentStatusCode(r.Err)))
+	if r.Span != nil {
+		tracinghttp.HeadersForSpans([]tracing.Span{r.Span}, timeLayout, http.Header(header))
+	}
+
+	if r.Err != nil {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		body.WriteString(r.Err.Error())
+	} else {
+		wrpResponse := r.Response.(wrpendpoint.Response)
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, http.Header(header))
+		header.Set("Content-Type", pool.Format().ContentType())
+		if err := wrpResponse.Encode(&body, pool); err != nil {
+			return err
+		}
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = body.WriteTo(part)
+	return err
+}
+
+// ServerEncodeAggregateResponse produces a go-kit transport/http.EncodeResponseFunc that renders the *fanout.AggregateResponse
+// returned by a fanout using the fanout.PartialSuccess option.  The HTTP entity is multipart/mixed, with one part for each component
+// in the order in which the components finished.  Each part carries the component's name in ComponentHeader, its outcome in
+// ComponentStatusHeader, and its span in the same headers used for the spans of any other response.  The part for a successful
+// component holds the WRP response encoded with the given pool, while a failed component's part holds the text of its error.
+//
+// Since the fanout fails unless at least one component succeeds, the HTTP status code is always http.StatusOK.
+func ServerEncodeAggregateResponse(timeLayout string, pool *wrp.EncoderPool) gokithttp.EncodeResponseFunc {
+	return observeEncodeResponse("ServerEncodeAggregateResponse", false, pool.Format(), func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+		var (
+			aggregate = value.(*fanout.AggregateResponse)
+			output    bytes.Buffer
+			writer    = multipart.NewWriter(&output)
+		)
+
+		for _, r := range aggregate.Results {
+			if err := writeComponentPart(writer, timeLayout, pool, r); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		tracinghttp.HeadersForSpans(aggregate.Spans(), timeLayout, httpResponse.Header())
+		httpResponse.Header().Set("Content-Type", MultipartMixedContentType+"; boundary="+writer.Boundary())
+		_, err := output.WriteTo(httpResponse)
+		return err
+	})
+}
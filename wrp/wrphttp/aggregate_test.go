@@ -0,0 +1,208 @@
+package wrphttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/tracing/tracinghttp"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// readParts parses a multipart/mixed HTTP response, returning each part's headers and body
+func readParts(t *testing.T, response *httptest.ResponseRecorder) ([]*multipart.Part, [][]byte) {
+	require := require.New(t)
+
+	mediaType, params, err := mime.ParseMediaType(response.HeaderMap.Get("Content-Type"))
+	require.NoError(err)
+	require.Equal(MultipartMixedContentType, mediaType)
+
+	var (
+		reader = multipart.NewReader(response.Body, params["boundary"])
+		parts  []*multipart.Part
+		bodies [][]byte
+	)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, bodies
+		}
+
+		require.NoError(err)
+		body, err := ioutil.ReadAll(part)
+		require.NoError(err)
+
+		parts = append(parts, part)
+		bodies = append(bodies, body)
+	}
+}
+
+func testServerEncodeAggregateResponse(t *testing.T, format wrp.Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		spanner = tracing.NewSpanner()
+
+		expectedMessage = &wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "mac:121212121212",
+			Destination: "test",
+			Payload:     []byte("component response"),
+		}
+
+		aggregate = &fanout.AggregateResponse{
+			Results: []fanout.Result{
+				{
+					Name:     "success",
+					Span:     spanner.Start("success")(nil),
+					Response: wrpendpoint.WrapAsResponse(expectedMessage),
+				},
+				{
+					Name: "failure",
+					Span: spanner.Start("failure")(&xhttp.Error{Code: http.StatusServiceUnavailable, Text: "unavailable"}),
+					Err:  &xhttp.Error{Code: http.StatusServiceUnavailable, Text: "unavailable"},
+				},
+				{
+					Name: "unfinished",
+					Err:  context.DeadlineExceeded,
+				},
+			},
+		}
+
+		encode   = ServerEncodeAggregateResponse("", wrp.NewEncoderPool(1, format))
+		response = httptest.NewRecorder()
+	)
+
+	value := aggregate.WithSpans(spanner.Start("fanout")(nil))
+	require.NoError(encode(context.Background(), response, value))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Len(response.HeaderMap[tracinghttp.SpanHeader], 1)
+
+	parts, bodies := readParts(t, response)
+	require.Len(parts, 3)
+
+	assert.Equal("success", parts[0].Header.Get(ComponentHeader))
+	assert.Equal("200", parts[0].Header.Get(ComponentStatusHeader))
+	assert.Equal(format.ContentType(), parts[0].Header.Get("Content-Type"))
+	assert.True(strings.HasPrefix(parts[0].Header.Get(tracinghttp.SpanHeader), `"success",`))
+	assert.Empty(parts[0].Header.Get(tracinghttp.ErrorHeader))
+
+	var actualMessage wrp.Message
+	require.NoError(wrp.NewDecoderBytes(bodies[0], format).Decode(&actualMessage))
+	assert.Equal(*expectedMessage, actualMessage)
+
+	assert.Equal("failure", parts[1].Header.Get(ComponentHeader))
+	assert.Equal("503", parts[1].Header.Get(ComponentStatusHeader))
+	assert.Equal("text/plain; charset=utf-8", parts[1].Header.Get("Content-Type"))
+	assert.True(strings.HasPrefix(parts[1].Header.Get(tracinghttp.SpanHeader), `"failure",`))
+	assert.Equal(`"failure",503,"unavailable"`, parts[1].Header.Get(tracinghttp.ErrorHeader))
+	assert.Equal("unavailable", string(bodies[1]))
+
+	assert.Equal("unfinished", parts[2].Header.Get(ComponentHeader))
+	assert.Equal("500", parts[2].Header.Get(ComponentStatusHeader))
+	assert.Empty(parts[2].Header.Get(tracinghttp.SpanHeader))
+	assert.Equal(context.DeadlineExceeded.Error(), string(bodies[2]))
+}
+
+func testServerEncodeAggregateResponseEncodeError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		wrpResponse   = new(mockRequestResponse)
+
+		aggregate = &fanout.AggregateResponse{
+			Results: []fanout.Result{{Name: "component", Response: wrpResponse}},
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	wrpResponse.On("Spans").Return([]tracing.Span(nil)).Once()
+	wrpResponse.On("Encode", mock.Anything, mock.Anything).Return(expectedError).Once()
+
+	assert.Equal(expectedError, ServerEncodeAggregateResponse("", wrp.NewEncoderPool(1, wrp.Msgpack))(context.Background(), response, aggregate))
+	assert.Empty(response.HeaderMap.Get("Content-Type"))
+	wrpResponse.AssertExpectations(t)
+}
+
+func testServerEncodeAggregateResponsePartialSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		expectedMessage = &wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "test",
+			Destination: "mac:123412341234",
+			Payload:     []byte("request"),
+		}
+
+		success = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			contents, _ := ioutil.ReadAll(request.Body)
+			response.Header().Set("Content-Type", wrp.Msgpack.ContentType())
+			response.Write(contents)
+		}))
+
+		failure = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	)
+
+	defer success.Close()
+	defer failure.Close()
+
+	fanoutEndpoint, err := NewFanoutEndpoint(&FanoutOptions{
+		Endpoints:      []string{success.URL, failure.URL},
+		FanoutTimeout:  10 * time.Second,
+		PartialSuccess: true,
+	})
+
+	require.NoError(err)
+	result, err := fanoutEndpoint(context.Background(), wrpendpoint.WrapAsRequest(logger, expectedMessage))
+	require.NoError(err)
+	require.IsType((*fanout.AggregateResponse)(nil), result)
+
+	response := httptest.NewRecorder()
+	require.NoError(ServerEncodeAggregateResponse("", wrp.NewEncoderPool(1, wrp.Msgpack))(context.Background(), response, result))
+
+	parts, _ := readParts(t, response)
+	require.Len(parts, 2)
+
+	statuses := map[string]string{}
+	for _, part := range parts {
+		statuses[part.Header.Get(ComponentHeader)] = part.Header.Get(ComponentStatusHeader)
+	}
+
+	assert.Equal("200", statuses[success.URL])
+	assert.NotEqual("200", statuses[failure.URL])
+	assert.Contains(statuses, failure.URL)
+}
+
+func TestServerEncodeAggregateResponse(t *testing.T) {
+	for _, format := range wrp.AllFormats() {
+		t.Run(format.String(), func(t *testing.T) {
+			testServerEncodeAggregateResponse(t, format)
+		})
+	}
+
+	t.Run("EncodeError", testServerEncodeAggregateResponseEncodeError)
+	t.Run("PartialSuccess", testServerEncodeAggregateResponsePartialSuccess)
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/go-kit/kit/log"
 
@@ -15,6 +17,13 @@ import (
 	gokithttp "github.com/go-kit/kit/transport/http"
 )
 
+// the observed versions of the decoders which are not created by factory functions
+var (
+	observedDecodeRequest               = observeDecodeRequest("DecodeRequest", false, wrp.Msgpack, decodeRequest)
+	observedDecodeRequestHeaders        = observeDecodeRequest("DecodeRequestHeaders", true, wrp.Msgpack, decodeRequestHeaders)
+	observedClientDecodeResponseHeaders = observeDecodeResponse("ClientDecodeResponseHeaders", true, wrp.Msgpack, clientDecodeResponseHeaders)
+)
+
 // Entity is the fanout entity produced by the decoders in this package
 type Entity struct {
 	Format   wrp.Format
@@ -24,7 +33,12 @@ type Entity struct {
 
 // DecodeRequest is a go-kit DecodeRequestFunc that produces an Entity from the given HTTP request.
 // The Content-Type header is used to determine the format, and if not specified wrp.Msgpack is used.
+// A Content-Type that is not a WRP format, or contents that cannot be decoded, produce a *DecodeError.
 func DecodeRequest(ctx context.Context, original *http.Request) (interface{}, error) {
+	return observedDecodeRequest(ctx, original)
+}
+
+func decodeRequest(ctx context.Context, original *http.Request) (interface{}, error) {
 	contents, err := ioutil.ReadAll(original.Body)
 	if err != nil {
 		return nil, err
@@ -37,7 +51,7 @@ func DecodeRequest(ctx context.Context, original *http.Request) (interface{}, er
 	} else {
 		format, err = wrp.FormatFromContentType(contentType)
 		if err != nil {
-			return nil, err
+			return nil, unsupportedFormat(err)
 		}
 	}
 
@@ -46,13 +60,20 @@ func DecodeRequest(ctx context.Context, original *http.Request) (interface{}, er
 		Contents: contents,
 	}
 
-	err = wrp.NewDecoderBytes(contents, format).Decode(&entity.Message)
-	return entity, err
+	if err := wrp.NewDecoderBytes(contents, format).Decode(&entity.Message); err != nil {
+		return entity, malformedEntity(err)
+	}
+
+	return entity, nil
 }
 
 // DecodeRequestHeaders is a go-kit DecodeRequestFunc that uses the HTTP headers as fields of a WRP message.
 // The HTTP entity, if specified, is used as the payload of the WRP message.
 func DecodeRequestHeaders(ctx context.Context, original *http.Request) (interface{}, error) {
+	return observedDecodeRequestHeaders(ctx, original)
+}
+
+func decodeRequestHeaders(ctx context.Context, original *http.Request) (interface{}, error) {
 	payload, err := ioutil.ReadAll(original.Body)
 	if err != nil {
 		return nil, err
@@ -74,6 +95,10 @@ func DecodeRequestHeaders(ctx context.Context, original *http.Request) (interfac
 // ClientDecodeResponseBody produces a go-kit transport/http.DecodeResponseFunc that turns an HTTP response
 // into a WRP response.
 func ClientDecodeResponseBody(pool *wrp.DecoderPool) gokithttp.DecodeResponseFunc {
+	return observeDecodeResponse("ClientDecodeResponseBody", false, pool.Format(), clientDecodeResponseBody(pool))
+}
+
+func clientDecodeResponseBody(pool *wrp.DecoderPool) gokithttp.DecodeResponseFunc {
 	return func(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
 		body, err := ioutil.ReadAll(httpResponse.Body)
 		if err != nil {
@@ -87,14 +112,15 @@ func ClientDecodeResponseBody(pool *wrp.DecoderPool) gokithttp.DecodeResponseFun
 			)
 
 			if err != nil {
-				return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: err.Error()}
+				return nil, unsupportedFormat(err)
 			} else if responseFormat != pool.Format() {
-				return nil, &xhttp.Error{Code: http.StatusUnsupportedMediaType, Text: fmt.Sprintf("Unexpected response Content-Type: %s", contentType)}
+				return nil, unsupportedFormat(fmt.Errorf("Unexpected response Content-Type: %s", contentType))
 			}
 
 			response, err := wrpendpoint.DecodeResponseBytes(body, pool)
 			if err != nil {
-				return nil, &xhttp.Error{Code: http.StatusInternalServerError, Text: err.Error()}
+				// a malformed response is the fault of the server, not the client's request
+				return nil, &DecodeError{Err: ErrMalformedEntity, Code: http.StatusInternalServerError, Cause: err}
 			}
 
 			return response, nil
@@ -107,6 +133,10 @@ func ClientDecodeResponseBody(pool *wrp.DecoderPool) gokithttp.DecodeResponseFun
 // ClientDecodeResponseHeaders is a go-kit transport/http.DecodeResponseFunc that turns an HTTP response
 // formatted using headers for WRP fields into a WRP response.
 func ClientDecodeResponseHeaders(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
+	return observedClientDecodeResponseHeaders(ctx, httpResponse)
+}
+
+func clientDecodeResponseHeaders(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
 	body, err := ioutil.ReadAll(httpResponse.Body)
 	if err != nil {
 		return nil, err
@@ -115,7 +145,7 @@ func ClientDecodeResponseHeaders(ctx context.Context, httpResponse *http.Respons
 	if httpResponse.StatusCode == http.StatusOK {
 		message, err := NewMessageFromHeaders(httpResponse.Header, bytes.NewReader(body))
 		if err != nil {
-			return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: err.Error()}
+			return nil, err
 		}
 
 		response, err := wrpendpoint.WrapAsResponse(message), nil
@@ -129,6 +159,65 @@ func ClientDecodeResponseHeaders(ctx context.Context, httpResponse *http.Respons
 	return nil, &xhttp.Error{Code: httpResponse.StatusCode}
 }
 
+// ClientDecodeResponseBodyWithStatus is like ClientDecodeResponseBody, except that a response with any HTTP status code
+// is decoded as a WRP response if its entity is a WRP message in the pool's format.  If the decoded message has no
+// status, its status is set from the HTTP status code using the given StatusMap.  This is the client-side counterpart of
+// ServerEncodeResponseBodyWithStatus.  Any other non-200 response is reported as an *xhttp.Error, as with ClientDecodeResponseBody.
+func ClientDecodeResponseBodyWithStatus(pool *wrp.DecoderPool, statusMap StatusMap) gokithttp.DecodeResponseFunc {
+	decode := clientDecodeResponseBody(pool)
+	return observeDecodeResponse("ClientDecodeResponseBodyWithStatus", false, pool.Format(), func(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
+		if httpResponse.StatusCode == http.StatusOK {
+			return decode(ctx, httpResponse)
+		}
+
+		if format, err := wrp.FormatFromContentType(httpResponse.Header.Get("Content-Type")); err != nil || format != pool.Format() {
+			return decode(ctx, httpResponse)
+		}
+
+		body, err := ioutil.ReadAll(httpResponse.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := wrpendpoint.DecodeResponseBytes(body, pool)
+		if err != nil {
+			return nil, &DecodeError{Err: ErrMalformedEntity, Code: httpResponse.StatusCode, Cause: err}
+		}
+
+		if message := response.Message(); statusMap.setStatus(message, httpResponse.StatusCode) {
+			// the original contents no longer match the message
+			return wrpendpoint.WrapAsResponse(message), nil
+		}
+
+		return response, nil
+	})
+}
+
+// ClientDecodeResponseHeadersWithStatus is like ClientDecodeResponseHeaders, except that a response with any HTTP status
+// code is decoded as a WRP response if it has WRP message headers.  If the decoded message has no status, its status is
+// set from the HTTP status code using the given StatusMap.  This is the client-side counterpart of
+// ServerEncodeResponseHeadersWithStatus.
+func ClientDecodeResponseHeadersWithStatus(statusMap StatusMap) gokithttp.DecodeResponseFunc {
+	return observeDecodeResponse("ClientDecodeResponseHeadersWithStatus", true, wrp.Msgpack, func(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
+		if httpResponse.StatusCode == http.StatusOK || len(httpResponse.Header.Get(MessageTypeHeader)) == 0 {
+			return clientDecodeResponseHeaders(ctx, httpResponse)
+		}
+
+		body, err := ioutil.ReadAll(httpResponse.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := NewMessageFromHeaders(httpResponse.Header, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		statusMap.setStatus(message, httpResponse.StatusCode)
+		return wrpendpoint.WrapAsResponse(message), nil
+	})
+}
+
 // withLogger enriches the given logger with request-specific information
 func withLogger(logger log.Logger, r *http.Request) log.Logger {
 	return log.WithPrefix(
@@ -141,26 +230,60 @@ func withLogger(logger log.Logger, r *http.Request) log.Logger {
 	)
 }
 
+// checkRequestFormat verifies that the Content-Type of a request, if supplied, matches the given format
+func checkRequestFormat(httpRequest *http.Request, expected wrp.Format) error {
+	contentType := httpRequest.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		return nil
+	}
+
+	format, err := wrp.FormatFromContentType(contentType)
+	if err != nil {
+		return unsupportedFormat(err)
+	} else if format != expected {
+		return unsupportedFormat(fmt.Errorf("Unexpected request Content-Type: %s", contentType))
+	}
+
+	return nil
+}
+
+// decodeRequestBytes decodes the contents of an HTTP request body as a WRP request
+func decodeRequestBytes(logger log.Logger, httpRequest *http.Request, contents []byte, pool *wrp.DecoderPool) (interface{}, error) {
+	request, err := wrpendpoint.DecodeRequestBytes(withLogger(logger, httpRequest), contents, pool)
+	if err != nil {
+		return nil, malformedEntity(err)
+	}
+
+	return request, nil
+}
+
 // ServerDecodeRequestBody creates a go-kit transport/http.DecodeRequestFunc function that parses the body of an HTTP
 // request as a WRP message in the format used by the given pool.  The supplied pool should match the
-// Content-Type of the request, or an error is returned.
+// Content-Type of the request, if supplied, or a *DecodeError of kind ErrUnsupportedFormat is returned.  A body that
+// cannot be decoded produces a *DecodeError of kind ErrMalformedEntity.
 //
 // This decoder function is appropriate when the HTTP request body contains a full WRP message.  For situations
 // where the HTTP body is only the payload, use the Headers decoder.
 func ServerDecodeRequestBody(logger log.Logger, pool *wrp.DecoderPool) gokithttp.DecodeRequestFunc {
-	return func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
-		return wrpendpoint.DecodeRequest(
-			withLogger(logger, httpRequest),
-			httpRequest.Body,
-			pool,
-		)
-	}
+	return observeDecodeRequest("ServerDecodeRequestBody", false, pool.Format(), func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+		if err := checkRequestFormat(httpRequest, pool.Format()); err != nil {
+			return nil, err
+		}
+
+		contents, err := ioutil.ReadAll(httpRequest.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeRequestBytes(logger, httpRequest, contents, pool)
+	})
 }
 
 // ServerDecodeRequestHeaders creates a go-kit transport/http.DecodeRequestFunc that builds a WRP request using HTTP
 // headers for most message fields.  The HTTP entity body, if present, is used as the payload of the WRP message.
+// A missing or malformed header produces a *DecodeError, as described by NewMessageFromHeaders.
 func ServerDecodeRequestHeaders(logger log.Logger) gokithttp.DecodeRequestFunc {
-	return func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+	return observeDecodeRequest("ServerDecodeRequestHeaders", true, wrp.Msgpack, func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
 		message, err := NewMessageFromHeaders(httpRequest.Header, httpRequest.Body)
 		if err != nil {
 			return nil, err
@@ -170,5 +293,136 @@ func ServerDecodeRequestHeaders(logger log.Logger) gokithttp.DecodeRequestFunc {
 			withLogger(logger, httpRequest),
 			message,
 		), nil
+	})
+}
+
+// countingReader tracks the number of bytes read from a delegate reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+// readLimitedBody reads an HTTP request body, enforcing a maximum entity size with http.MaxBytesReader.  If maxBytes is
+// nonpositive, the body is not limited.  This function returns tooLarge as true if the body exceeded maxBytes, in which
+// case the contents are nil.
+func readLimitedBody(httpRequest *http.Request, maxBytes int64) (contents []byte, tooLarge bool, err error) {
+	if maxBytes <= 0 {
+		contents, err = ioutil.ReadAll(httpRequest.Body)
+		return
+	}
+
+	if httpRequest.ContentLength > maxBytes {
+		return nil, true, nil
+	}
+
+	// http.MaxBytesReader reads at most maxBytes+1 bytes from the body, so counting what it reads
+	// distinguishes an oversized entity from any other read error
+	counter := &countingReader{reader: httpRequest.Body}
+	contents, err = ioutil.ReadAll(http.MaxBytesReader(nil, ioutil.NopCloser(counter), maxBytes))
+	if counter.count > maxBytes {
+		return nil, true, nil
+	}
+
+	return
+}
+
+// entityTooLargeMessage produces the WRP message describing an HTTP request entity that exceeded its maximum size
+func entityTooLargeMessage(maxBytes int64) *wrp.Message {
+	status := int64(http.StatusRequestEntityTooLarge)
+	return &wrp.Message{
+		Type:        wrp.SimpleRequestResponseMessageType,
+		Status:      &status,
+		ContentType: "text/plain",
+		Payload:     []byte(fmt.Sprintf("The request entity exceeds the maximum size of %d bytes", maxBytes)),
+	}
+}
+
+// entityTooLargeBody produces an http.StatusRequestEntityTooLarge error whose entity is a WRP message in the given format
+func entityTooLargeBody(maxBytes int64, format wrp.Format) error {
+	var (
+		message = entityTooLargeMessage(maxBytes)
+		entity  []byte
+	)
+
+	if err := wrp.NewEncoderBytes(&entity, format).Encode(message); err != nil {
+		return err
 	}
+
+	return &xhttp.Error{
+		Code:   http.StatusRequestEntityTooLarge,
+		Header: http.Header{"Content-Type": []string{format.ContentType()}},
+		Text:   string(message.Payload),
+		Entity: entity,
+	}
+}
+
+// entityTooLargeHeaders produces an http.StatusRequestEntityTooLarge error whose WRP fields are represented as headers
+func entityTooLargeHeaders(maxBytes int64) error {
+	var (
+		message = entityTooLargeMessage(maxBytes)
+		header  = make(http.Header)
+	)
+
+	AddMessageHeaders(header, message)
+	header.Set("Content-Type", message.ContentType)
+	header.Set("Content-Length", strconv.Itoa(len(message.Payload)))
+
+	return &xhttp.Error{
+		Code:   http.StatusRequestEntityTooLarge,
+		Header: header,
+		Text:   string(message.Payload),
+		Entity: message.Payload,
+	}
+}
+
+// ServerDecodeRequestBodyWithLimit is like ServerDecodeRequestBody, except that the HTTP entity may be at most maxBytes
+// long.  A larger entity is never read fully into memory.  Instead, an *xhttp.Error with an http.StatusRequestEntityTooLarge
+// code is returned, whose entity is a WRP message in the pool's format describing the failure.  Use ServerErrorEncoder
+// to write that entity to clients.  If maxBytes is nonpositive, the HTTP entity is not limited.
+func ServerDecodeRequestBodyWithLimit(logger log.Logger, pool *wrp.DecoderPool, maxBytes int64) gokithttp.DecodeRequestFunc {
+	return observeDecodeRequest("ServerDecodeRequestBodyWithLimit", false, pool.Format(), func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+		if err := checkRequestFormat(httpRequest, pool.Format()); err != nil {
+			return nil, err
+		}
+
+		contents, tooLarge, err := readLimitedBody(httpRequest, maxBytes)
+		if tooLarge {
+			return nil, entityTooLargeBody(maxBytes, pool.Format())
+		} else if err != nil {
+			return nil, err
+		}
+
+		return decodeRequestBytes(logger, httpRequest, contents, pool)
+	})
+}
+
+// ServerDecodeRequestHeadersWithLimit is like ServerDecodeRequestHeaders, except that the HTTP entity, i.e. the payload,
+// may be at most maxBytes long.  A larger entity produces an *xhttp.Error with an http.StatusRequestEntityTooLarge code,
+// whose headers describe the failure as a WRP message and whose entity is that message's payload.  If maxBytes is
+// nonpositive, the HTTP entity is not limited.
+func ServerDecodeRequestHeadersWithLimit(logger log.Logger, maxBytes int64) gokithttp.DecodeRequestFunc {
+	return observeDecodeRequest("ServerDecodeRequestHeadersWithLimit", true, wrp.Msgpack, func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+		payload, tooLarge, err := readLimitedBody(httpRequest, maxBytes)
+		if tooLarge {
+			return nil, entityTooLargeHeaders(maxBytes)
+		} else if err != nil {
+			return nil, err
+		}
+
+		message, err := NewMessageFromHeaders(httpRequest.Header, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+
+		return wrpendpoint.WrapAsRequest(
+			withLogger(logger, httpRequest),
+			message,
+		), nil
+	})
 }
@@ -1,6 +1,7 @@
 package wrphttp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -134,12 +136,44 @@ func testClientDecodeResponseBodySuccess(t *testing.T) {
 	)
 }
 
+func testClientDecodeResponseBodyCBOR(t *testing.T) {
+	var (
+		require = require.New(t)
+		assert  = assert.New(t)
+		pool    = wrp.NewDecoderPool(1, wrp.CBOR)
+
+		expected = wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "test",
+			Destination: "mac:123443211234",
+			Payload:     []byte{0x00, 0x06, 0xFF, 0xF0},
+		}
+
+		httpResponse = &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type": []string{wrp.CBOR.ContentType()},
+			},
+			Body: ioutil.NopCloser(bytes.NewReader(wrp.MustEncode(&expected, wrp.CBOR))),
+		}
+	)
+
+	value, err := ClientDecodeResponseBody(pool)(context.Background(), httpResponse)
+	require.NotNil(value)
+	require.NoError(err)
+
+	wrpResponse, ok := value.(wrpendpoint.Response)
+	require.True(ok)
+	assert.Equal(expected, *wrpResponse.Message())
+}
+
 func TestClientDecodeResponseBody(t *testing.T) {
 	t.Run("ReadError", testClientDecodeResponseBodyReadError)
 	t.Run("HttpError", testClientDecodeResponseBodyHttpError)
 	t.Run("BadContentType", testClientDecodeResponseBodyBadContentType)
 	t.Run("UnexpectedContentType", testClientDecodeResponseBodyUnexpectedContentType)
 	t.Run("Success", testClientDecodeResponseBodySuccess)
+	t.Run("CBOR", testClientDecodeResponseBodyCBOR)
 }
 
 func testClientDecodeResponseHeadersReadError(t *testing.T) {
@@ -346,3 +380,338 @@ func TestServerDecodeRequestHeaders(t *testing.T) {
 	t.Run("Success", testServerDecodeRequestHeadersSuccess)
 	t.Run("BadHeaders", testServerDecodeRequestHeadersBadHeaders)
 }
+
+func testServerDecodeRequestBodyWithLimitSuccess(t *testing.T, maxBytes int64) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		pool        = wrp.NewDecoderPool(1, wrp.JSON)
+		httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"msg_type": 3, "source": "test", "dest": "mac:123412341234"}`))
+	)
+
+	value, err := ServerDecodeRequestBodyWithLimit(logger, pool, maxBytes)(context.Background(), httpRequest)
+	require.NoError(err)
+	require.NotNil(value)
+
+	wrpRequest, ok := value.(wrpendpoint.Request)
+	require.True(ok)
+	assert.Equal(
+		wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "test",
+			Destination: "mac:123412341234",
+		},
+		*wrpRequest.Message(),
+	)
+}
+
+func testServerDecodeRequestBodyWithLimitTooLarge(t *testing.T, contentLength int64) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		pool        = wrp.NewDecoderPool(1, wrp.Msgpack)
+		httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 100)))
+	)
+
+	httpRequest.ContentLength = contentLength
+	value, err := ServerDecodeRequestBodyWithLimit(logger, pool, 10)(context.Background(), httpRequest)
+	assert.Nil(value)
+	require.Error(err)
+
+	httpError, ok := err.(*xhttp.Error)
+	require.True(ok)
+	assert.Equal(http.StatusRequestEntityTooLarge, httpError.StatusCode())
+	assert.Equal(wrp.Msgpack.ContentType(), httpError.Headers().Get("Content-Type"))
+
+	var message wrp.Message
+	require.NoError(wrp.NewDecoderBytes(httpError.Entity, wrp.Msgpack).Decode(&message))
+	assert.Equal(wrp.SimpleRequestResponseMessageType, message.Type)
+	require.NotNil(message.Status)
+	assert.Equal(int64(http.StatusRequestEntityTooLarge), *message.Status)
+	assert.Equal(httpError.Error(), string(message.Payload))
+}
+
+func testServerDecodeRequestBodyWithLimitReadError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		logger = logging.NewTestLogger(nil, t)
+
+		expectedError = errors.New("expected")
+		body          = new(mockReadCloser)
+		pool          = wrp.NewDecoderPool(1, wrp.Msgpack)
+		httpRequest   = httptest.NewRequest("POST", "/", nil)
+	)
+
+	body.On("Read", mock.MatchedBy(func([]byte) bool { return true })).Return(0, expectedError).Once()
+	httpRequest.Body = body
+
+	value, err := ServerDecodeRequestBodyWithLimit(logger, pool, 10)(context.Background(), httpRequest)
+	assert.Nil(value)
+	assert.Equal(expectedError, err)
+	body.AssertExpectations(t)
+}
+
+func TestServerDecodeRequestBodyWithLimit(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		t.Run("Unlimited", func(t *testing.T) { testServerDecodeRequestBodyWithLimitSuccess(t, 0) })
+		t.Run("WithinLimit", func(t *testing.T) { testServerDecodeRequestBodyWithLimitSuccess(t, 1024) })
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		t.Run("ContentLength", func(t *testing.T) { testServerDecodeRequestBodyWithLimitTooLarge(t, 100) })
+		t.Run("Chunked", func(t *testing.T) { testServerDecodeRequestBodyWithLimitTooLarge(t, -1) })
+	})
+
+	t.Run("ReadError", testServerDecodeRequestBodyWithLimitReadError)
+}
+
+func TestServerDecodeRequestHeadersWithLimit(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			logger  = logging.NewTestLogger(nil, t)
+
+			httpRequest = httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+		)
+
+		httpRequest.Header.Set(MessageTypeHeader, "SimpleEvent")
+		httpRequest.Header.Set(SourceHeader, "test")
+		httpRequest.Header.Set(DestinationHeader, "mac:432143214321")
+		httpRequest.Header.Set("Content-Type", "text/plain")
+
+		value, err := ServerDecodeRequestHeadersWithLimit(logger, 7)(context.Background(), httpRequest)
+		require.NoError(err)
+		require.NotNil(value)
+
+		wrpRequest, ok := value.(wrpendpoint.Request)
+		require.True(ok)
+		assert.Equal(
+			wrp.Message{
+				Type:        wrp.SimpleEventMessageType,
+				Source:      "test",
+				Destination: "mac:432143214321",
+				ContentType: "text/plain",
+				Payload:     []byte("payload"),
+			},
+			*wrpRequest.Message(),
+		)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			logger  = logging.NewTestLogger(nil, t)
+
+			httpRequest = httptest.NewRequest("POST", "/", strings.NewReader("payload"))
+		)
+
+		httpRequest.ContentLength = -1
+		value, err := ServerDecodeRequestHeadersWithLimit(logger, 6)(context.Background(), httpRequest)
+		assert.Nil(value)
+		require.Error(err)
+
+		httpError, ok := err.(*xhttp.Error)
+		require.True(ok)
+		assert.Equal(http.StatusRequestEntityTooLarge, httpError.StatusCode())
+		assert.Equal("SimpleRequestResponse", httpError.Headers().Get(MessageTypeHeader))
+		assert.Equal("413", httpError.Headers().Get(StatusHeader))
+		assert.Equal("text/plain", httpError.Headers().Get("Content-Type"))
+		assert.Equal(httpError.Error(), string(httpError.Entity))
+	})
+
+	t.Run("BadHeaders", func(t *testing.T) {
+		var (
+			assert      = assert.New(t)
+			logger      = logging.NewTestLogger(nil, t)
+			httpRequest = httptest.NewRequest("POST", "/", nil)
+		)
+
+		value, err := ServerDecodeRequestHeadersWithLimit(logger, 10)(context.Background(), httpRequest)
+		assert.Nil(value)
+		assert.Error(err)
+	})
+}
+
+func TestClientDecodeResponseBodyWithStatus(t *testing.T) {
+	var (
+		statusMap = StatusMap{531: http.StatusServiceUnavailable}
+		message   = wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "mac:123443211234",
+			Destination: "test",
+		}
+	)
+
+	t.Run("Status", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			pool    = wrp.NewDecoderPool(1, wrp.Msgpack)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{"Content-Type": []string{wrp.Msgpack.ContentType()}},
+				Body:       ioutil.NopCloser(bytes.NewReader(wrp.MustEncode(&message, wrp.Msgpack))),
+			}
+		)
+
+		value, err := ClientDecodeResponseBodyWithStatus(pool, statusMap)(context.Background(), httpResponse)
+		require.NoError(err)
+		require.NotNil(value)
+
+		wrpResponse, ok := value.(wrpendpoint.Response)
+		require.True(ok)
+
+		expected := message
+		expected.SetStatus(531)
+		assert.Equal(expected, *wrpResponse.Message())
+
+		encoded, err := wrpResponse.EncodeBytes(wrp.NewEncoderPool(1, wrp.Msgpack))
+		require.NoError(err)
+		assert.Equal(wrp.MustEncode(&expected, wrp.Msgpack), encoded)
+	})
+
+	t.Run("ExistingStatus", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			pool    = wrp.NewDecoderPool(1, wrp.JSON)
+
+			expected = message
+		)
+
+		expected.SetStatus(404)
+		httpResponse := &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{wrp.JSON.ContentType()}},
+			Body:       ioutil.NopCloser(bytes.NewReader(wrp.MustEncode(&expected, wrp.JSON))),
+		}
+
+		value, err := ClientDecodeResponseBodyWithStatus(pool, statusMap)(context.Background(), httpResponse)
+		require.NoError(err)
+		require.NotNil(value)
+		assert.Equal(expected, *value.(wrpendpoint.Response).Message())
+	})
+
+	t.Run("NotWRP", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			pool   = wrp.NewDecoderPool(1, wrp.Msgpack)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     http.Header{"Content-Type": []string{"text/plain"}},
+				Body:       ioutil.NopCloser(strings.NewReader("bad gateway")),
+			}
+		)
+
+		value, err := ClientDecodeResponseBodyWithStatus(pool, statusMap)(context.Background(), httpResponse)
+		assert.Nil(value)
+		assert.Equal(&xhttp.Error{Code: http.StatusBadGateway}, err)
+	})
+
+	t.Run("BadEntity", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			pool   = wrp.NewDecoderPool(1, wrp.JSON)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{"Content-Type": []string{wrp.JSON.ContentType()}},
+				Body:       ioutil.NopCloser(strings.NewReader("this is not JSON")),
+			}
+		)
+
+		value, err := ClientDecodeResponseBodyWithStatus(pool, statusMap)(context.Background(), httpResponse)
+		assert.Nil(value)
+		assert.True(errors.Is(err, ErrMalformedEntity))
+		require.IsType(t, (*DecodeError)(nil), err)
+		assert.Equal(http.StatusServiceUnavailable, err.(*DecodeError).StatusCode())
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			pool    = wrp.NewDecoderPool(1, wrp.Msgpack)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{wrp.Msgpack.ContentType()}},
+				Body:       ioutil.NopCloser(bytes.NewReader(wrp.MustEncode(&message, wrp.Msgpack))),
+			}
+		)
+
+		value, err := ClientDecodeResponseBodyWithStatus(pool, statusMap)(context.Background(), httpResponse)
+		require.NoError(err)
+		require.NotNil(value)
+		assert.Equal(message, *value.(wrpendpoint.Response).Message())
+	})
+}
+
+func TestClientDecodeResponseHeadersWithStatus(t *testing.T) {
+	statusMap := StatusMap{531: http.StatusServiceUnavailable}
+
+	t.Run("Status", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header: http.Header{
+					MessageTypeHeader: []string{"SimpleRequestResponse"},
+					SourceHeader:      []string{"mac:123443211234"},
+				},
+				Body: ioutil.NopCloser(new(bytes.Buffer)),
+			}
+		)
+
+		value, err := ClientDecodeResponseHeadersWithStatus(statusMap)(context.Background(), httpResponse)
+		require.NoError(err)
+		require.NotNil(value)
+
+		message := value.(wrpendpoint.Response).Message()
+		assert.Equal("mac:123443211234", message.Source)
+		require.NotNil(message.Status)
+		assert.Equal(int64(531), *message.Status)
+	})
+
+	t.Run("NotWRP", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(new(bytes.Buffer)),
+			}
+		)
+
+		value, err := ClientDecodeResponseHeadersWithStatus(statusMap)(context.Background(), httpResponse)
+		assert.Nil(value)
+		assert.Equal(&xhttp.Error{Code: http.StatusServiceUnavailable}, err)
+	})
+
+	t.Run("BadHeaders", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			httpResponse = &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{MessageTypeHeader: []string{"nosuch"}},
+				Body:       ioutil.NopCloser(new(bytes.Buffer)),
+			}
+		)
+
+		value, err := ClientDecodeResponseHeadersWithStatus(statusMap)(context.Background(), httpResponse)
+		assert.Nil(value)
+		assert.Error(err)
+	})
+}
@@ -9,13 +9,14 @@ import (
 	"github.com/Comcast/webpa-common/tracing/tracinghttp"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/xhttp"
 	gokithttp "github.com/go-kit/kit/transport/http"
 )
 
 // EncodeRequest returns a go-kit EncodeRequestFunc that encodes a decoded Entity as an HTTP request,
 // often as the component of a fanout (though not required).  The given WRP format is used as the HTTP entity format.
 func EncodeRequest(format wrp.Format) gokithttp.EncodeRequestFunc {
-	return func(ctx context.Context, component *http.Request, v interface{}) error {
+	return observeEncodeRequest("EncodeRequest", false, format, func(ctx context.Context, component *http.Request, v interface{}) error {
 		entity := v.(*Entity)
 
 		if format == entity.Format && len(entity.Contents) > 0 {
@@ -35,14 +36,14 @@ func EncodeRequest(format wrp.Format) gokithttp.EncodeRequestFunc {
 		component.Header.Set("Content-Type", format.ContentType())
 		component.Header.Set(DestinationHeader, entity.Message.Destination)
 		return nil
-	}
+	})
 }
 
 // ClientEncodeRequestBody produces a go-kit transport/http.EncodeRequestFunc for use when sending WRP requests
 // to HTTP clients.  The returned decoder will set the appropriate headers and set the body to the encoded
 // WRP message in the request.
 func ClientEncodeRequestBody(pool *wrp.EncoderPool, custom http.Header) gokithttp.EncodeRequestFunc {
-	return func(ctx context.Context, httpRequest *http.Request, value interface{}) error {
+	return observeEncodeRequest("ClientEncodeRequestBody", false, pool.Format(), func(ctx context.Context, httpRequest *http.Request, value interface{}) error {
 		var (
 			wrpRequest = value.(wrpendpoint.Request)
 			body       = new(bytes.Buffer)
@@ -63,13 +64,13 @@ func ClientEncodeRequestBody(pool *wrp.EncoderPool, custom http.Header) gokithtt
 		httpRequest.ContentLength = int64(body.Len())
 		httpRequest.Body = ioutil.NopCloser(body)
 		return nil
-	}
+	})
 }
 
 // ClientEncodeRequestHeaders is a go-kit transport/http.EncodeRequestFunc for use when sending WRP requests
 // to HTTP clients using an HTTP header representation of the message fields.
 func ClientEncodeRequestHeaders(custom http.Header) gokithttp.EncodeRequestFunc {
-	return func(ctx context.Context, httpRequest *http.Request, value interface{}) error {
+	return observeEncodeRequest("ClientEncodeRequestHeaders", true, wrp.Msgpack, func(ctx context.Context, httpRequest *http.Request, value interface{}) error {
 		var (
 			wrpRequest = value.(wrpendpoint.Request)
 			body       = new(bytes.Buffer)
@@ -90,13 +91,13 @@ func ClientEncodeRequestHeaders(custom http.Header) gokithttp.EncodeRequestFunc
 		httpRequest.Body = ioutil.NopCloser(body)
 
 		return nil
-	}
+	})
 }
 
 // ServerEncodeResponseBody produces a go-kit transport/http.EncodeResponseFunc that transforms a wrphttp.Response into
 // an HTTP response.
 func ServerEncodeResponseBody(timeLayout string, pool *wrp.EncoderPool) gokithttp.EncodeResponseFunc {
-	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+	return observeEncodeResponse("ServerEncodeResponseBody", false, pool.Format(), func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
 		var (
 			wrpResponse = value.(wrpendpoint.Response)
 			output      bytes.Buffer
@@ -111,16 +112,82 @@ func ServerEncodeResponseBody(timeLayout string, pool *wrp.EncoderPool) gokithtt
 		httpResponse.Header().Set("Content-Type", pool.Format().ContentType())
 		_, err := output.WriteTo(httpResponse)
 		return err
-	}
+	})
 }
 
 // ServerEncodeResponseHeaders encodes a WRP response's fields into the HTTP response's headers.  The payload
 // is written as the HTTP response body.
 func ServerEncodeResponseHeaders(timeLayout string) gokithttp.EncodeResponseFunc {
-	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+	return observeEncodeResponse("ServerEncodeResponseHeaders", true, wrp.Msgpack, func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
 		wrpResponse := value.(wrpendpoint.Response)
 		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
 		AddMessageHeaders(httpResponse.Header(), wrpResponse.Message())
 		return WriteMessagePayload(httpResponse.Header(), httpResponse, wrpResponse.Message())
+	})
+}
+
+// ServerEncodeResponseBodyWithStatus is like ServerEncodeResponseBody, except that the HTTP status code is set from the
+// WRP response's status field using the given StatusMap.  This allows errors reported by devices to be reported as
+// something other than http.StatusOK.
+func ServerEncodeResponseBodyWithStatus(timeLayout string, pool *wrp.EncoderPool, statusMap StatusMap) gokithttp.EncodeResponseFunc {
+	return observeEncodeResponse("ServerEncodeResponseBodyWithStatus", false, pool.Format(), func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+		var (
+			wrpResponse = value.(wrpendpoint.Response)
+			output      bytes.Buffer
+		)
+
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
+
+		if err := wrpResponse.Encode(&output, pool); err != nil {
+			return err
+		}
+
+		httpResponse.Header().Set("Content-Type", pool.Format().ContentType())
+		httpResponse.WriteHeader(statusMap.StatusCode(wrpResponse.Message()))
+		_, err := output.WriteTo(httpResponse)
+		return err
+	})
+}
+
+// ServerEncodeResponseHeadersWithStatus is like ServerEncodeResponseHeaders, except that the HTTP status code is set from
+// the WRP response's status field using the given StatusMap.
+func ServerEncodeResponseHeadersWithStatus(timeLayout string, statusMap StatusMap) gokithttp.EncodeResponseFunc {
+	return observeEncodeResponse("ServerEncodeResponseHeadersWithStatus", true, wrp.Msgpack, func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+		var (
+			wrpResponse = value.(wrpendpoint.Response)
+			message     = wrpResponse.Message()
+			payload     bytes.Buffer
+		)
+
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
+		AddMessageHeaders(httpResponse.Header(), message)
+
+		// the payload headers must be set before the status code is written
+		if err := WriteMessagePayload(httpResponse.Header(), &payload, message); err != nil {
+			return err
+		}
+
+		httpResponse.WriteHeader(statusMap.StatusCode(message))
+		_, err := payload.WriteTo(httpResponse)
+		return err
+	})
+}
+
+// ServerErrorEncoder is a go-kit transport/http.ErrorEncoder that writes any headers and status code carried by an error.
+// If the error is an *xhttp.Error with an entity, such as the errors returned by ServerDecodeRequestBodyWithLimit, that
+// entity is written as the response body.  Errors that do not carry a status code are written as
+// http.StatusInternalServerError.
+func ServerErrorEncoder(ctx context.Context, err error, httpResponse http.ResponseWriter) {
+	if headerer, ok := err.(gokithttp.Headerer); ok {
+		for name, values := range headerer.Headers() {
+			for _, value := range values {
+				httpResponse.Header().Add(name, value)
+			}
+		}
+	}
+
+	httpResponse.WriteHeader(componentStatusCode(err))
+	if httpError, ok := err.(*xhttp.Error); ok && len(httpError.Entity) > 0 {
+		httpResponse.Write(httpError.Entity)
 	}
 }
@@ -11,8 +11,11 @@ import (
 
 	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func testClientEncodeRequestBodyEncodeError(t *testing.T, custom http.Header) {
@@ -327,3 +330,154 @@ func TestServerEncodeResponseHeaders(t *testing.T) {
 	t.Run("NoPayload", testServerEncodeResponseHeadersNoPayload)
 	t.Run("WithPayload", testServerEncodeResponseHeadersWithPayload)
 }
+
+func testServerEncodeResponseBodyWithStatus(t *testing.T, format wrp.Format, status *int64, expectedCode int) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pool    = wrp.NewEncoderPool(1, format)
+
+		message = wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "mac:121212121212",
+			Destination: "test",
+			Status:      status,
+		}
+
+		httpResponse = httptest.NewRecorder()
+		statusMap    = StatusMap{531: http.StatusServiceUnavailable}
+	)
+
+	require.NoError(
+		ServerEncodeResponseBodyWithStatus("", pool, statusMap)(context.Background(), httpResponse, wrpendpoint.WrapAsResponse(&message)),
+	)
+
+	assert.Equal(expectedCode, httpResponse.Code)
+	assert.Equal(format.ContentType(), httpResponse.HeaderMap.Get("Content-Type"))
+
+	var actual wrp.Message
+	require.NoError(wrp.NewDecoderBytes(httpResponse.Body.Bytes(), format).Decode(&actual))
+	assert.Equal(message, actual)
+}
+
+func testServerEncodeResponseBodyWithStatusEncodeError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		pool   = wrp.NewEncoderPool(1, wrp.Msgpack)
+
+		httpResponse = httptest.NewRecorder()
+		wrpResponse  = new(mockRequestResponse)
+	)
+
+	wrpResponse.On("Spans").Return([]tracing.Span{})
+	wrpResponse.On("Encode", mock.MatchedBy(func(io.Writer) bool { return true }), pool).
+		Return(errors.New("expected error")).Once()
+
+	assert.Error(ServerEncodeResponseBodyWithStatus("", pool, nil)(context.Background(), httpResponse, wrpResponse))
+	assert.Empty(httpResponse.HeaderMap)
+	assert.Empty(httpResponse.Body.Bytes())
+
+	wrpResponse.AssertExpectations(t)
+}
+
+func TestServerEncodeResponseBodyWithStatus(t *testing.T) {
+	var (
+		notFound    int64 = 404
+		unavailable int64 = 531
+	)
+
+	for _, format := range wrp.AllFormats() {
+		t.Run(format.String(), func(t *testing.T) {
+			t.Run("NoStatus", func(t *testing.T) {
+				testServerEncodeResponseBodyWithStatus(t, format, nil, http.StatusOK)
+			})
+
+			t.Run("HTTPStatus", func(t *testing.T) {
+				testServerEncodeResponseBodyWithStatus(t, format, &notFound, http.StatusNotFound)
+			})
+
+			t.Run("MappedStatus", func(t *testing.T) {
+				testServerEncodeResponseBodyWithStatus(t, format, &unavailable, http.StatusServiceUnavailable)
+			})
+		})
+	}
+
+	t.Run("EncodeError", testServerEncodeResponseBodyWithStatusEncodeError)
+}
+
+func TestServerEncodeResponseHeadersWithStatus(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		status int64 = 531
+
+		message = wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "mac:121212121212",
+			Destination: "test",
+			Status:      &status,
+			Payload:     []byte("expected payload"),
+			ContentType: "text/plain",
+		}
+
+		httpResponse = httptest.NewRecorder()
+	)
+
+	assert.NoError(
+		ServerEncodeResponseHeadersWithStatus("", StatusMap{531: http.StatusServiceUnavailable})(
+			context.Background(), httpResponse, wrpendpoint.WrapAsResponse(&message),
+		),
+	)
+
+	assert.Equal(http.StatusServiceUnavailable, httpResponse.Code)
+	assert.Equal("531", httpResponse.HeaderMap.Get(StatusHeader))
+	assert.Equal("mac:121212121212", httpResponse.HeaderMap.Get(SourceHeader))
+	assert.Equal("text/plain", httpResponse.HeaderMap.Get("Content-Type"))
+	assert.Equal("16", httpResponse.HeaderMap.Get("Content-Length"))
+	assert.Equal("expected payload", httpResponse.Body.String())
+}
+
+func TestServerErrorEncoder(t *testing.T) {
+	t.Run("Entity", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		ServerErrorEncoder(
+			context.Background(),
+			&xhttp.Error{
+				Code:   http.StatusRequestEntityTooLarge,
+				Header: http.Header{"Content-Type": []string{"text/plain"}},
+				Text:   "too large",
+				Entity: []byte("entity"),
+			},
+			response,
+		)
+
+		assert.Equal(http.StatusRequestEntityTooLarge, response.Code)
+		assert.Equal("text/plain", response.HeaderMap.Get("Content-Type"))
+		assert.Equal("entity", response.Body.String())
+	})
+
+	t.Run("NoEntity", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		ServerErrorEncoder(context.Background(), &xhttp.Error{Code: http.StatusBadRequest}, response)
+		assert.Equal(http.StatusBadRequest, response.Code)
+		assert.Zero(response.Body.Len())
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		ServerErrorEncoder(context.Background(), errors.New("expected"), response)
+		assert.Equal(http.StatusInternalServerError, response.Code)
+		assert.Zero(response.Body.Len())
+	})
+}
@@ -0,0 +1,85 @@
+package wrphttp
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrUnsupportedFormat indicates an HTTP entity whose Content-Type is not a WRP format, or is not the WRP format
+	// that was expected
+	ErrUnsupportedFormat = errors.New("Unsupported WRP format")
+
+	// ErrMalformedEntity indicates an HTTP entity or header that could not be decoded as the corresponding part of a WRP message
+	ErrMalformedEntity = errors.New("Malformed WRP entity")
+
+	// ErrMissingHeader indicates that a header required by the HTTP header representation of WRP messages was not supplied
+	ErrMissingHeader = errors.New("Missing required WRP header")
+)
+
+// DecodeError is the error returned by the decoders in this package when an HTTP request or response cannot be
+// turned into a WRP message.  A DecodeError matches its kind with errors.Is, e.g. errors.Is(err, ErrMalformedEntity),
+// and unwraps to its cause, if any.  This type implements go-kit's StatusCoder, so that HTTP transports report
+// decoding failures with the suggested status code.
+type DecodeError struct {
+	// Err is the kind of decoding failure, which is one of ErrUnsupportedFormat, ErrMalformedEntity, or ErrMissingHeader
+	Err error
+
+	// Code is the suggested HTTP status code for this failure
+	Code int
+
+	// Header is the name of the HTTP header that caused this failure.  This field is empty if the failure
+	// was not caused by a header.
+	Header string
+
+	// Cause is the underlying error, such as an error from a WRP decoder.  This field may be nil.
+	Cause error
+}
+
+func (de *DecodeError) Error() string {
+	text := de.Err.Error()
+	if len(de.Header) > 0 {
+		text += " [" + de.Header + "]"
+	}
+
+	if de.Cause != nil {
+		text += ": " + de.Cause.Error()
+	}
+
+	return text
+}
+
+// StatusCode returns the suggested HTTP status code
+func (de *DecodeError) StatusCode() int {
+	return de.Code
+}
+
+// Is tests if this error is of the given kind
+func (de *DecodeError) Is(target error) bool {
+	return de.Err == target
+}
+
+// Unwrap returns the cause of this error, which may be nil
+func (de *DecodeError) Unwrap() error {
+	return de.Cause
+}
+
+// unsupportedFormat produces a DecodeError for an HTTP entity that does not have the expected WRP format
+func unsupportedFormat(cause error) *DecodeError {
+	return &DecodeError{Err: ErrUnsupportedFormat, Code: http.StatusUnsupportedMediaType, Cause: cause}
+}
+
+// malformedEntity produces a DecodeError for an HTTP entity that could not be decoded
+func malformedEntity(cause error) *DecodeError {
+	return &DecodeError{Err: ErrMalformedEntity, Code: http.StatusBadRequest, Cause: cause}
+}
+
+// malformedHeader produces a DecodeError for an HTTP header whose value could not be decoded
+func malformedHeader(name string, cause error) *DecodeError {
+	return &DecodeError{Err: ErrMalformedEntity, Code: http.StatusBadRequest, Header: name, Cause: cause}
+}
+
+// missingHeader produces a DecodeError for a required HTTP header that was not supplied
+func missingHeader(name string) *DecodeError {
+	return &DecodeError{Err: ErrMissingHeader, Code: http.StatusBadRequest, Header: name}
+}
@@ -0,0 +1,181 @@
+package wrphttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cause  = errors.New("cause")
+	)
+
+	de := malformedHeader(StatusHeader, cause)
+	assert.Equal("Malformed WRP entity [X-Xmidt-Status]: cause", de.Error())
+	assert.Equal(http.StatusBadRequest, de.StatusCode())
+	assert.True(errors.Is(de, ErrMalformedEntity))
+	assert.True(errors.Is(de, cause))
+	assert.False(errors.Is(de, ErrMissingHeader))
+
+	de = missingHeader(MessageTypeHeader)
+	assert.Equal("Missing required WRP header [X-Xmidt-Message-Type]", de.Error())
+	assert.Equal(http.StatusBadRequest, de.StatusCode())
+	assert.True(errors.Is(de, ErrMissingHeader))
+	assert.Nil(de.Unwrap())
+
+	de = unsupportedFormat(cause)
+	assert.Equal("Unsupported WRP format: cause", de.Error())
+	assert.Equal(http.StatusUnsupportedMediaType, de.StatusCode())
+	assert.True(errors.Is(de, ErrUnsupportedFormat))
+
+	de = malformedEntity(nil)
+	assert.Equal("Malformed WRP entity", de.Error())
+	assert.True(errors.Is(de, ErrMalformedEntity))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var (
+		logger = logging.NewTestLogger(nil, t)
+		pool   = wrp.NewDecoderPool(1, wrp.JSON)
+
+		testData = []struct {
+			name        string
+			decoder     func(*http.Request) (interface{}, error)
+			header      http.Header
+			body        string
+			expected    error
+			code        int
+			errorHeader string
+		}{
+			{
+				name: "UnsupportedFormat",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestBody(logger, pool)(context.Background(), r)
+				},
+				header:   http.Header{"Content-Type": []string{"text/plain"}},
+				body:     `{"msg_type": 3}`,
+				expected: ErrUnsupportedFormat,
+				code:     http.StatusUnsupportedMediaType,
+			},
+			{
+				name: "UnexpectedFormat",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestBody(logger, pool)(context.Background(), r)
+				},
+				header:   http.Header{"Content-Type": []string{wrp.Msgpack.ContentType()}},
+				body:     `{"msg_type": 3}`,
+				expected: ErrUnsupportedFormat,
+				code:     http.StatusUnsupportedMediaType,
+			},
+			{
+				name: "MalformedBody",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestBody(logger, pool)(context.Background(), r)
+				},
+				body:     `this is not JSON`,
+				expected: ErrMalformedEntity,
+				code:     http.StatusBadRequest,
+			},
+			{
+				name:     "MalformedEntity",
+				decoder:  func(r *http.Request) (interface{}, error) { return DecodeRequest(context.Background(), r) },
+				header:   http.Header{"Content-Type": []string{wrp.JSON.ContentType()}},
+				body:     `this is not JSON`,
+				expected: ErrMalformedEntity,
+				code:     http.StatusBadRequest,
+			},
+			{
+				name: "MissingMessageType",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestHeaders(logger)(context.Background(), r)
+				},
+				expected:    ErrMissingHeader,
+				code:        http.StatusBadRequest,
+				errorHeader: MessageTypeHeader,
+			},
+			{
+				name: "MalformedStatus",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestHeaders(logger)(context.Background(), r)
+				},
+				header:      http.Header{MessageTypeHeader: []string{"SimpleEvent"}, StatusHeader: []string{"nan"}},
+				expected:    ErrMalformedEntity,
+				code:        http.StatusBadRequest,
+				errorHeader: StatusHeader,
+			},
+			{
+				name: "MalformedSpan",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestHeaders(logger)(context.Background(), r)
+				},
+				header:      http.Header{MessageTypeHeader: []string{"SimpleEvent"}, SpanHeader: []string{"a,b"}},
+				expected:    ErrMalformedEntity,
+				code:        http.StatusBadRequest,
+				errorHeader: SpanHeader,
+			},
+			{
+				name: "MalformedMetadata",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestHeaders(logger)(context.Background(), r)
+				},
+				header:      http.Header{MessageTypeHeader: []string{"SimpleEvent"}, MetadataHeader: []string{"novalue"}},
+				expected:    ErrMalformedEntity,
+				code:        http.StatusBadRequest,
+				errorHeader: MetadataHeader,
+			},
+			{
+				name: "MalformedMessageType",
+				decoder: func(r *http.Request) (interface{}, error) {
+					return ServerDecodeRequestHeaders(logger)(context.Background(), r)
+				},
+				header:      http.Header{MessageTypeHeader: []string{"nosuch"}},
+				expected:    ErrMalformedEntity,
+				code:        http.StatusBadRequest,
+				errorHeader: MessageTypeHeader,
+			},
+		}
+	)
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert      = assert.New(t)
+				require     = require.New(t)
+				httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(record.body))
+			)
+
+			for name, values := range record.header {
+				httpRequest.Header[name] = values
+			}
+
+			_, err := record.decoder(httpRequest)
+			require.Error(err)
+			assert.True(errors.Is(err, record.expected), err.Error())
+
+			var de *DecodeError
+			require.True(errors.As(err, &de))
+			assert.Equal(record.code, de.StatusCode())
+			assert.Equal(record.errorHeader, de.Header)
+		})
+	}
+}
+
+func TestDecodeErrorCause(t *testing.T) {
+	_, err := NewMessageFromHeaders(
+		http.Header{MessageTypeHeader: []string{"SimpleEvent"}, QOSHeader: []string{"nan"}},
+		nil,
+	)
+
+	assert.True(t, errors.Is(err, strconv.ErrSyntax))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/transport/transporthttp"
 	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
 	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -60,6 +61,15 @@ type FanoutOptions struct {
 	// If this is not set, DefaultConcurrency is used.
 	Concurrency int `json:"concurrency"`
 
+	// QOSQueueSize enables QOS-aware queueing when positive.  Rather than a Concurrent middleware, the fanout uses
+	// wrpendpoint.Prioritize, so that requests over the Concurrency limit wait in a queue of this size and are admitted
+	// in order of their WRP QOS levels.  When the queue is full, lower QOS requests are shed first.  By default, there is no QOS queue.
+	QOSQueueSize int `json:"qosQueueSize"`
+
+	// PartialSuccess configures the fanout to wait on every endpoint, using the fanout.PartialSuccess option.  The fanout
+	// response is then a *fanout.AggregateResponse, which can be rendered with ServerEncodeAggregateResponse.
+	PartialSuccess bool `json:"partialSuccess"`
+
 	// EncoderPoolSize is the size of the WRP encoder pool.  If not set, DefaultEncoderPoolSize is used.
 	EncoderPoolSize int
 
@@ -162,6 +172,37 @@ func (f *FanoutOptions) concurrency() int {
 	return DefaultConcurrency
 }
 
+func (f *FanoutOptions) qosQueueSize() int {
+	if f != nil && f.QOSQueueSize > 0 {
+		return f.QOSQueueSize
+	}
+
+	return 0
+}
+
+func (f *FanoutOptions) partialSuccess() bool {
+	return f != nil && f.PartialSuccess
+}
+
+// fanoutOptions returns the options passed to fanout.New
+func (f *FanoutOptions) fanoutOptions() []fanout.Option {
+	if f.partialSuccess() {
+		return []fanout.Option{fanout.PartialSuccess()}
+	}
+
+	return nil
+}
+
+// limiter returns the middleware which limits the number of concurrent fanouts
+func (f *FanoutOptions) limiter() endpoint.Middleware {
+	tooManyRequests := &xhttp.Error{Code: http.StatusTooManyRequests, Text: "Too Many Requests"}
+	if queueSize := f.qosQueueSize(); queueSize > 0 {
+		return wrpendpoint.Prioritize(f.concurrency(), queueSize, nil, tooManyRequests)
+	}
+
+	return middleware.Concurrent(f.concurrency(), tooManyRequests)
+}
+
 func (f *FanoutOptions) encoderPoolSize() int {
 	if f != nil && f.EncoderPoolSize > 0 {
 		return f.EncoderPoolSize
@@ -248,7 +289,7 @@ func NewFanoutEndpoint(o *FanoutOptions) (endpoint.Endpoint, error) {
 				middleware.Logging,
 				middleware.Busy(o.maxClients(), &xhttp.Error{Code: http.StatusServiceUnavailable, Text: "Server Busy"}),
 				middleware.Timeout(o.fanoutTimeout()),
-				middleware.Concurrent(o.concurrency(), &xhttp.Error{Code: http.StatusTooManyRequests, Text: "Too Many Requests"}),
+				o.limiter(),
 			},
 			o.middleware()...,
 		)
@@ -257,6 +298,6 @@ func NewFanoutEndpoint(o *FanoutOptions) (endpoint.Endpoint, error) {
 	return endpoint.Chain(
 			middlewareChain[0],
 			middlewareChain[1:]...,
-		)(fanout.New(tracing.NewSpanner(), fanoutEndpoints)),
+		)(fanout.New(tracing.NewSpanner(), fanoutEndpoints, o.fanoutOptions()...)),
 		nil
 }
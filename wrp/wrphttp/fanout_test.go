@@ -40,6 +40,10 @@ func testFanoutOptionsDefaults(t *testing.T, o *FanoutOptions) {
 	assert.Equal(DefaultClientTimeout, o.clientTimeout())
 	assert.Equal(DefaultMaxClients, o.maxClients())
 	assert.Equal(DefaultConcurrency, o.concurrency())
+	assert.Zero(o.qosQueueSize())
+	assert.False(o.partialSuccess())
+	assert.Empty(o.fanoutOptions())
+	assert.NotNil(o.limiter())
 	assert.Equal(DefaultEncoderPoolSize, o.encoderPoolSize())
 	assert.Equal(DefaultDecoderPoolSize, o.decoderPoolSize())
 	assert.Empty(o.middleware())
@@ -65,6 +69,8 @@ func testFanoutOptionsConfigured(t *testing.T) {
 			ClientTimeout:   37 * time.Second,
 			MaxClients:      38734,
 			Concurrency:     3249,
+			QOSQueueSize:    17,
+			PartialSuccess:  true,
 			EncoderPoolSize: 56,
 			DecoderPoolSize: 98234,
 			Middleware: []endpoint.Middleware{
@@ -96,6 +102,10 @@ func testFanoutOptionsConfigured(t *testing.T) {
 	assert.Equal(37*time.Second, o.clientTimeout())
 	assert.Equal(int64(38734), o.maxClients())
 	assert.Equal(3249, o.concurrency())
+	assert.Equal(17, o.qosQueueSize())
+	assert.True(o.partialSuccess())
+	assert.Len(o.fanoutOptions(), 1)
+	assert.NotNil(o.limiter())
 	assert.Equal(56, o.encoderPoolSize())
 	assert.Equal(98234, o.decoderPoolSize())
 
@@ -128,7 +138,7 @@ func TestFanoutOptions(t *testing.T) {
 	t.Run("BadURL", testFanoutOptionsBadURL)
 }
 
-func testNewFanoutEndpointSendReceive(t *testing.T) {
+func testNewFanoutEndpointSendReceive(t *testing.T, qosQueueSize int) {
 	var (
 		require = require.New(t)
 		assert  = assert.New(t)
@@ -163,6 +173,7 @@ func testNewFanoutEndpointSendReceive(t *testing.T) {
 		o = &FanoutOptions{
 			Endpoints:     []string{server.URL},
 			Authorization: "QWxhZGRpbjpPcGVuU2VzYW1l",
+			QOSQueueSize:  qosQueueSize,
 		}
 	)
 
@@ -195,6 +206,13 @@ func testNewFanoutEndpointBadURL(t *testing.T) {
 }
 
 func TestNewFanoutEndpoint(t *testing.T) {
-	t.Run("SendReceive", testNewFanoutEndpointSendReceive)
+	t.Run("SendReceive", func(t *testing.T) {
+		testNewFanoutEndpointSendReceive(t, 0)
+	})
+
+	t.Run("SendReceiveWithQOS", func(t *testing.T) {
+		testNewFanoutEndpointSendReceive(t, 10)
+	})
+
 	t.Run("BadURL", testNewFanoutEndpointBadURL)
 }
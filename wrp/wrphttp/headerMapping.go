@@ -0,0 +1,205 @@
+package wrphttp
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+var (
+	// ErrInvalidHeaderMapping indicates that a HeaderMapping has no header, or does not map the header onto
+	// exactly one of a supported WRP field or a metadata entry
+	ErrInvalidHeaderMapping = errors.New("A header mapping must map a header onto either a string WRP field or a metadata key")
+
+	// ErrReservedHeader indicates an attempt to register a mapping for one of the headers that this package
+	// already uses to represent WRP messages
+	ErrReservedHeader = errors.New("The header is reserved for the standard WRP header representation")
+
+	// ErrDuplicateHeaderMapping indicates an attempt to register a header, or a metadata key, which already has a mapping
+	ErrDuplicateHeaderMapping = errors.New("A mapping for the header or metadata key is already registered")
+)
+
+// reservedHeaders are the canonical names of the headers used by the standard WRP header representation
+var reservedHeaders = map[string]bool{
+	MessageTypeHeader:             true,
+	TransactionUuidHeader:         true,
+	StatusHeader:                  true,
+	RequestDeliveryResponseHeader: true,
+	IncludeSpansHeader:            true,
+	SpanHeader:                    true,
+	PathHeader:                    true,
+	SourceHeader:                  true,
+	DestinationHeader:             true,
+	AcceptHeader:                  true,
+	HeadersHeader:                 true,
+	MetadataHeader:                true,
+	ServiceNameHeader:             true,
+	URLHeader:                     true,
+	QOSHeader:                     true,
+	"Content-Type":                true,
+	"Content-Length":              true,
+}
+
+// HeaderMapping associates a custom HTTP header, typically an organization-specific X-Webpa-* header, with either
+// a string field of a WRP message or an entry in its metadata.  Exactly one of Field or MetadataKey must be set.
+type HeaderMapping struct {
+	// Header is the name of the HTTP header.  It is canonicalized when the mapping is registered.
+	Header string
+
+	// Field is the name of the WRP field, such as wrp.SourceField, that the header maps onto.  Only the fields
+	// with string values may be mapped.  A mapped field is still represented by its standard header as well.
+	Field string
+
+	// MetadataKey is the metadata entry that the header maps onto.  A mapped metadata entry is represented only by
+	// its custom header, rather than as one of the MetadataHeader values.
+	MetadataKey string
+}
+
+// stringField returns a pointer to the string field of a message with the given WRP field name, or nil if the
+// message has no such string field
+func stringField(m *wrp.Message, field string) *string {
+	switch field {
+	case wrp.SourceField:
+		return &m.Source
+	case wrp.DestinationField:
+		return &m.Destination
+	case wrp.TransactionUUIDField:
+		return &m.TransactionUUID
+	case wrp.ContentTypeField:
+		return &m.ContentType
+	case wrp.AcceptField:
+		return &m.Accept
+	case wrp.PathField:
+		return &m.Path
+	case wrp.ServiceNameField:
+		return &m.ServiceName
+	case wrp.URLField:
+		return &m.URL
+	}
+
+	return nil
+}
+
+// headerMappings is the registry of custom header mappings, sorted by header name so that headers
+// are always written in the same order
+var headerMappings struct {
+	lock     sync.RWMutex
+	mappings []HeaderMapping
+}
+
+// RegisterHeaderMapping adds a custom header to the header representation of WRP messages.  Once registered,
+// SetMessageFromHeaders and NewMessageFromHeaders transfer the header's value onto the mapped field or metadata entry,
+// and AddMessageHeaders writes the header from that field or entry.  When both a custom header and the standard header
+// for a field are present, the custom header takes precedence.
+//
+// Mappings are global, and are normally registered during initialization.  This function is safe for concurrent use.
+func RegisterHeaderMapping(hm HeaderMapping) error {
+	hm.Header = http.CanonicalHeaderKey(hm.Header)
+	if len(hm.Header) == 0 || (len(hm.Field) > 0) == (len(hm.MetadataKey) > 0) {
+		return ErrInvalidHeaderMapping
+	}
+
+	if len(hm.Field) > 0 && stringField(new(wrp.Message), hm.Field) == nil {
+		return ErrInvalidHeaderMapping
+	}
+
+	if reservedHeaders[hm.Header] {
+		return ErrReservedHeader
+	}
+
+	headerMappings.lock.Lock()
+	defer headerMappings.lock.Unlock()
+
+	for _, existing := range headerMappings.mappings {
+		if existing.Header == hm.Header || (len(hm.MetadataKey) > 0 && existing.MetadataKey == hm.MetadataKey) {
+			return ErrDuplicateHeaderMapping
+		}
+	}
+
+	// copy on write, so that readers can safely iterate over a snapshot without holding the lock
+	mappings := append(append([]HeaderMapping(nil), headerMappings.mappings...), hm)
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Header < mappings[j].Header })
+	headerMappings.mappings = mappings
+	return nil
+}
+
+// UnregisterHeaderMapping removes the custom mapping for the given header.  This function returns true if
+// a mapping was removed.
+func UnregisterHeaderMapping(header string) bool {
+	header = http.CanonicalHeaderKey(header)
+
+	headerMappings.lock.Lock()
+	defer headerMappings.lock.Unlock()
+
+	for i, existing := range headerMappings.mappings {
+		if existing.Header == header {
+			mappings := append([]HeaderMapping(nil), headerMappings.mappings[:i]...)
+			headerMappings.mappings = append(mappings, headerMappings.mappings[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// HeaderMappings returns the currently registered custom header mappings, sorted by header name
+func HeaderMappings() []HeaderMapping {
+	return append([]HeaderMapping(nil), currentHeaderMappings()...)
+}
+
+func currentHeaderMappings() []HeaderMapping {
+	headerMappings.lock.RLock()
+	mappings := headerMappings.mappings
+	headerMappings.lock.RUnlock()
+	return mappings
+}
+
+// isMappedMetadata tests if a metadata key is represented by a custom header
+func isMappedMetadata(mappings []HeaderMapping, key string) bool {
+	for _, hm := range mappings {
+		if hm.MetadataKey == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setMappedFields transfers the values of any registered custom headers onto a message
+func setMappedFields(h http.Header, m *wrp.Message, mappings []HeaderMapping) {
+	for _, hm := range mappings {
+		values := h[hm.Header]
+		if len(values) == 0 {
+			continue
+		}
+
+		if len(hm.MetadataKey) > 0 {
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]string)
+			}
+
+			m.Metadata[hm.MetadataKey] = values[0]
+		} else {
+			*stringField(m, hm.Field) = values[0]
+		}
+	}
+}
+
+// addMappedHeaders writes any registered custom headers for which the message has a value
+func addMappedHeaders(h http.Header, m *wrp.Message, mappings []HeaderMapping) {
+	for _, hm := range mappings {
+		var value string
+		if len(hm.MetadataKey) > 0 {
+			value = m.Metadata[hm.MetadataKey]
+		} else {
+			value = *stringField(m, hm.Field)
+		}
+
+		if len(value) > 0 {
+			h.Set(hm.Header, value)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package wrphttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegisterHeaderMappingInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(ErrInvalidHeaderMapping, RegisterHeaderMapping(HeaderMapping{Field: wrp.SourceField}))
+	assert.Equal(ErrInvalidHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Custom"}))
+	assert.Equal(ErrInvalidHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Custom", Field: wrp.SourceField, MetadataKey: "custom"}))
+	assert.Equal(ErrInvalidHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Custom", Field: wrp.StatusField}))
+	assert.Equal(ErrInvalidHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Custom", Field: "nosuchfield"}))
+	assert.Equal(ErrReservedHeader, RegisterHeaderMapping(HeaderMapping{Header: "x-webpa-device-name", Field: wrp.SourceField}))
+	assert.Equal(ErrReservedHeader, RegisterHeaderMapping(HeaderMapping{Header: "content-type", MetadataKey: "custom"}))
+	assert.Empty(HeaderMappings())
+}
+
+func testRegisterHeaderMappingDuplicate(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	require.NoError(RegisterHeaderMapping(HeaderMapping{Header: "x-webpa-partner-id", MetadataKey: "partner-id"}))
+	defer UnregisterHeaderMapping("X-Webpa-Partner-Id")
+
+	assert.Equal(ErrDuplicateHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Partner-Id", Field: wrp.PathField}))
+	assert.Equal(ErrDuplicateHeaderMapping, RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Other", MetadataKey: "partner-id"}))
+	assert.Equal(
+		[]HeaderMapping{{Header: "X-Webpa-Partner-Id", MetadataKey: "partner-id"}},
+		HeaderMappings(),
+	)
+}
+
+func testRegisterHeaderMappingRoundTrip(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expected = wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test",
+			Destination:     "mac:112233445566",
+			TransactionUUID: "1234",
+			Metadata:        map[string]string{"partner-id": "comcast", "other": "value"},
+		}
+	)
+
+	require.NoError(RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Partner-Id", MetadataKey: "partner-id"}))
+	require.NoError(RegisterHeaderMapping(HeaderMapping{Header: "X-Webpa-Transaction-Id", Field: wrp.TransactionUUIDField}))
+	defer func() {
+		assert.True(UnregisterHeaderMapping("X-Webpa-Partner-Id"))
+		assert.True(UnregisterHeaderMapping("X-Webpa-Transaction-Id"))
+		assert.False(UnregisterHeaderMapping("X-Webpa-Transaction-Id"))
+		assert.Empty(HeaderMappings())
+	}()
+
+	header := make(http.Header)
+	AddMessageHeaders(header, &expected)
+	assert.Equal([]string{"comcast"}, header["X-Webpa-Partner-Id"])
+	assert.Equal([]string{"1234"}, header["X-Webpa-Transaction-Id"])
+	assert.Equal([]string{"1234"}, header[TransactionUuidHeader])
+	assert.Equal([]string{"other=value"}, header[MetadataHeader])
+
+	actual, err := NewMessageFromHeaders(header, nil)
+	require.NoError(err)
+	require.NotNil(actual)
+	assert.Equal(expected, *actual)
+
+	// the custom header takes precedence over the standard header
+	header.Set("X-Webpa-Transaction-Id", "5678")
+	header.Del(MetadataHeader)
+
+	var message wrp.Message
+	require.NoError(SetMessageFromHeaders(header, &message))
+	assert.Equal("5678", message.TransactionUUID)
+	assert.Equal(map[string]string{"partner-id": "comcast"}, message.Metadata)
+}
+
+func TestRegisterHeaderMapping(t *testing.T) {
+	t.Run("Invalid", testRegisterHeaderMappingInvalid)
+	t.Run("Duplicate", testRegisterHeaderMappingDuplicate)
+	t.Run("RoundTrip", testRegisterHeaderMappingRoundTrip)
+}
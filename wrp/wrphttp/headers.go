@@ -1,13 +1,15 @@
 package wrphttp
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/wrp"
 )
 
@@ -22,160 +24,301 @@ const (
 	SourceHeader                  = "X-Xmidt-Source"
 	DestinationHeader             = "X-Webpa-Device-Name"
 	AcceptHeader                  = "X-Xmidt-Accept"
+	HeadersHeader                 = "X-Xmidt-Headers"
+	MetadataHeader                = "X-Xmidt-Metadata"
+	ServiceNameHeader             = "X-Xmidt-Service-Name"
+	URLHeader                     = "X-Xmidt-Url"
+	QOSHeader                     = "X-Xmidt-Qos"
 )
 
-var (
-	errMissingMessageTypeHeader = fmt.Errorf("Missing %s header", MessageTypeHeader)
-)
+// maxPayloadPreallocation is the largest buffer allocated for a payload before any of it has been read
+const maxPayloadPreallocation = 64 * 1024
+
+// headerValue returns the first value of a header, with the same semantics as http.Header.Get.  A name already
+// in canonical form is looked up directly, which avoids the canonicalization performed by http.Header.Get.
+func headerValue(h http.Header, name string) string {
+	if values, ok := h[name]; ok {
+		if len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+
+	return h.Get(name)
+}
 
 // getMessageType extracts the wrp.MessageType from header.  This is a required field.
-//
-// This function panics if the message type header is missing or invalid.
-func getMessageType(h http.Header) wrp.MessageType {
-	value := h.Get(MessageTypeHeader)
+func getMessageType(h http.Header) (wrp.MessageType, error) {
+	value := headerValue(h, MessageTypeHeader)
 	if len(value) == 0 {
-		panic(errMissingMessageTypeHeader)
+		return wrp.MessageType(0), missingHeader(MessageTypeHeader)
 	}
 
 	messageType, err := wrp.StringToMessageType(value)
 	if err != nil {
-		panic(err)
+		return wrp.MessageType(0), malformedHeader(MessageTypeHeader, err)
 	}
 
-	return messageType
+	return messageType, nil
 }
 
-// getIntHeader returns the header as a int64, or returns nil if the header is absent.
-// This function panics if the header is present but not a valid integer.
-func getIntHeader(h http.Header, n string) *int64 {
-	value := h.Get(n)
+// getIntHeader parses a header as an int64.  This function returns false if the header is absent.
+func getIntHeader(h http.Header, n string) (int64, bool, error) {
+	value := headerValue(h, n)
 	if len(value) == 0 {
-		return nil
+		return 0, false, nil
 	}
 
 	i, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		panic(err)
+		return 0, false, malformedHeader(n, err)
 	}
 
-	return &i
+	return i, true, nil
 }
 
-func getBoolHeader(h http.Header, n string) *bool {
-	value := h.Get(n)
+// getBoolHeader parses a header as a bool.  This function returns false if the header is absent.
+func getBoolHeader(h http.Header, n string) (bool, bool, error) {
+	value := headerValue(h, n)
 	if len(value) == 0 {
-		return nil
+		return false, false, nil
 	}
 
 	b, err := strconv.ParseBool(value)
 	if err != nil {
-		panic(err)
+		return false, false, malformedHeader(n, err)
 	}
 
-	return &b
+	return b, true, nil
 }
 
-func getSpans(h http.Header) [][]string {
-	var spans [][]string
+// optionalFields holds the values of a message's optional pointer fields, so that a single allocation
+// is shared by all of them
+type optionalFields struct {
+	status                  int64
+	requestDeliveryResponse int64
+	includeSpans            bool
+}
 
-	for _, value := range h[SpanHeader] {
-		fields := strings.Split(value, ",")
-		if len(fields) != 3 {
-			panic(fmt.Errorf("Invalid %s header: %s", SpanHeader, value))
+// setOptionalFields parses the headers for the optional pointer fields of a message
+func setOptionalFields(h http.Header, m *wrp.Message) error {
+	var (
+		fields optionalFields
+		err    error
+
+		hasStatus, hasRequestDeliveryResponse, hasIncludeSpans bool
+	)
+
+	if fields.status, hasStatus, err = getIntHeader(h, StatusHeader); err != nil {
+		return err
+	}
+
+	if fields.requestDeliveryResponse, hasRequestDeliveryResponse, err = getIntHeader(h, RequestDeliveryResponseHeader); err != nil {
+		return err
+	}
+
+	if fields.includeSpans, hasIncludeSpans, err = getBoolHeader(h, IncludeSpansHeader); err != nil {
+		return err
+	}
+
+	m.Status, m.RequestDeliveryResponse, m.IncludeSpans = nil, nil, nil
+	if hasStatus || hasRequestDeliveryResponse || hasIncludeSpans {
+		shared := new(optionalFields)
+		*shared = fields
+
+		if hasStatus {
+			m.Status = &shared.status
 		}
 
-		for i := 0; i < len(fields); i++ {
-			fields[i] = strings.TrimSpace(fields[i])
+		if hasRequestDeliveryResponse {
+			m.RequestDeliveryResponse = &shared.requestDeliveryResponse
 		}
 
-		spans = append(spans, fields)
+		if hasIncludeSpans {
+			m.IncludeSpans = &shared.includeSpans
+		}
 	}
 
-	return spans
+	return nil
 }
 
-func readPayload(h http.Header, p io.Reader) ([]byte, string) {
+// setSpansAndHeaders parses the span headers and copies the WRP headers, each of which is a separate HTTP header value.
+// The spans and headers share a single backing array, and each slice is capped so that appending to it never
+// affects the others.
+func setSpansAndHeaders(h http.Header, m *wrp.Message) error {
+	var (
+		spanValues   = h[SpanHeader]
+		headerValues = h[HeadersHeader]
+	)
+
+	m.Spans, m.Headers = nil, nil
+	if len(spanValues) == 0 && len(headerValues) == 0 {
+		return nil
+	}
+
+	var (
+		backing = make([]string, 3*len(spanValues)+len(headerValues))
+		offset  = 0
+	)
+
+	if len(spanValues) > 0 {
+		m.Spans = make([][]string, len(spanValues))
+		for i, value := range spanValues {
+			fields := backing[offset : offset+3 : offset+3]
+			for j := 0; j < 2; j++ {
+				comma := strings.IndexByte(value, ',')
+				if comma < 0 {
+					m.Spans = nil
+					return malformedHeader(SpanHeader, fmt.Errorf("Expected 3 comma-separated fields: %s", spanValues[i]))
+				}
+
+				fields[j] = strings.TrimSpace(value[:comma])
+				value = value[comma+1:]
+			}
+
+			if strings.IndexByte(value, ',') >= 0 {
+				m.Spans = nil
+				return malformedHeader(SpanHeader, fmt.Errorf("Expected 3 comma-separated fields: %s", spanValues[i]))
+			}
+
+			fields[2] = strings.TrimSpace(value)
+			m.Spans[i] = fields
+			offset += 3
+		}
+	}
+
+	if len(headerValues) > 0 {
+		m.Headers = backing[offset:len(backing):len(backing)]
+		copy(m.Headers, headerValues)
+	}
+
+	return nil
+}
+
+// getMetadata parses each metadata header value, which must be of the form key=value
+func getMetadata(h http.Header) (map[string]string, error) {
+	values := h[MetadataHeader]
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string, len(values))
+	for _, value := range values {
+		i := strings.IndexByte(value, '=')
+		if i < 1 {
+			return nil, malformedHeader(MetadataHeader, fmt.Errorf("Expected key=value: %s", value))
+		}
+
+		metadata[strings.TrimSpace(value[:i])] = strings.TrimSpace(value[i+1:])
+	}
+
+	return metadata, nil
+}
+
+// readPayload reads the payload of a message, using the Content-Length header, if present, to presize the
+// buffer.  The presized buffer is capped at maxPayloadPreallocation, since the header is supplied by the client
+// and the buffer grows as data actually arrives.
+func readPayload(h http.Header, p io.Reader) ([]byte, string, error) {
 	if p == nil {
-		return nil, ""
+		return nil, "", nil
 	}
 
-	payload, err := ioutil.ReadAll(p)
-	if err != nil {
-		panic(err)
+	size, err := strconv.Atoi(headerValue(h, "Content-Length"))
+	if err != nil || size < 0 {
+		size = 0
+	} else if size > maxPayloadPreallocation {
+		size = maxPayloadPreallocation
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := buffer.ReadFrom(p); err != nil {
+		return nil, "", err
 	}
 
+	payload := buffer.Bytes()
 	if len(payload) == 0 {
-		return nil, ""
+		return nil, "", nil
 	}
 
-	contentType := h.Get("Content-Type")
-	if len(contentType) == 0 && len(payload) > 0 {
+	contentType := headerValue(h, "Content-Type")
+	if len(contentType) == 0 {
 		contentType = "application/octet-stream"
 	}
 
-	return payload, contentType
+	return payload, contentType, nil
 }
 
 // NewMessageFromHeaders extracts a WRP message from a set of HTTP headers.  If supplied, the
-// given io.Reader is assumed to contain the payload of the WRP message.
-func NewMessageFromHeaders(h http.Header, p io.Reader) (message *wrp.Message, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			message = nil
-			switch v := r.(type) {
-			case error:
-				err = v
-			default:
-				err = fmt.Errorf("Unable to create WRP message: %s", v)
-			}
-		}
-	}()
+// given io.Reader is assumed to contain the payload of the WRP message.  Any custom headers registered
+// with RegisterHeaderMapping are honored.  The payload is only read if the headers are valid.
+//
+// A missing message type header produces a *DecodeError of kind ErrMissingHeader, while a header whose value
+// cannot be parsed produces a *DecodeError of kind ErrMalformedEntity that names the header.
+func NewMessageFromHeaders(h http.Header, p io.Reader) (*wrp.Message, error) {
+	message := new(wrp.Message)
+	if err := SetMessageFromHeaders(h, message); err != nil {
+		return nil, err
+	}
 
-	payload, contentType := readPayload(h, p)
-	message = new(wrp.Message)
-	err = SetMessageFromHeaders(h, message)
+	payload, contentType, err := readPayload(h, p)
 	if err != nil {
-		message = nil
+		return nil, err
 	}
 
 	message.Payload = payload
 	message.ContentType = contentType
-	return
+	return message, nil
 }
 
-// SetMessageFromHeaders transfers header fields onto the given WRP message.  The payload is not
-// handled by this method.
-func SetMessageFromHeaders(h http.Header, m *wrp.Message) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			switch v := r.(type) {
-			case error:
-				err = v
-			default:
-				err = fmt.Errorf("Unable to create WRP message: %s", v)
-			}
-		}
-	}()
-
-	m.Type = getMessageType(h)
-	m.Source = h.Get(SourceHeader)
-	m.Destination = h.Get(DestinationHeader)
-	m.TransactionUUID = h.Get(TransactionUuidHeader)
-	m.Status = getIntHeader(h, StatusHeader)
-	m.RequestDeliveryResponse = getIntHeader(h, RequestDeliveryResponseHeader)
-	m.IncludeSpans = getBoolHeader(h, IncludeSpansHeader)
-	m.Spans = getSpans(h)
-	m.ContentType = h.Get("Content-Type")
-	m.Accept = h.Get(AcceptHeader)
-	m.Path = h.Get(PathHeader)
-
-	return
+// SetMessageFromHeaders transfers header fields onto the given WRP message, including any custom headers
+// registered with RegisterHeaderMapping.  The payload is not handled by this method.
+// Errors are reported in the same way as NewMessageFromHeaders.
+//
+// This function is on the hot path for clients that use the header representation, so it avoids allocating
+// anything beyond the message's own slices, maps, and pointer fields.
+func SetMessageFromHeaders(h http.Header, m *wrp.Message) error {
+	var err error
+	if m.Type, err = getMessageType(h); err != nil {
+		return err
+	}
+
+	if err = setOptionalFields(h, m); err != nil {
+		return err
+	}
+
+	if err = setSpansAndHeaders(h, m); err != nil {
+		return err
+	}
+
+	if m.Metadata, err = getMetadata(h); err != nil {
+		return err
+	}
+
+	qos, _, err := getIntHeader(h, QOSHeader)
+	if err != nil {
+		return err
+	}
+
+	m.QualityOfService = wrp.QOSValue(qos)
+	m.Source = headerValue(h, SourceHeader)
+	m.Destination = headerValue(h, DestinationHeader)
+	m.TransactionUUID = headerValue(h, TransactionUuidHeader)
+	m.ContentType = headerValue(h, "Content-Type")
+	m.Accept = headerValue(h, AcceptHeader)
+	m.Path = headerValue(h, PathHeader)
+	m.ServiceName = headerValue(h, ServiceNameHeader)
+	m.URL = headerValue(h, URLHeader)
+
+	setMappedFields(h, m, currentHeaderMappings())
+	return nil
 }
 
-// AddMessageHeaders adds the HTTP header representation of a given WRP message.
-// This function does not handle the payload, to allow further headers to be written by
-// calling code.
+// AddMessageHeaders adds the HTTP header representation of a given WRP message, including any custom headers
+// registered with RegisterHeaderMapping.  This function does not handle the payload, to allow further headers
+// to be written by calling code.
 func AddMessageHeaders(h http.Header, m *wrp.Message) {
+	mappings := currentHeaderMappings()
 	h.Set(MessageTypeHeader, m.Type.FriendlyName())
 
 	if len(m.Source) > 0 {
@@ -213,6 +356,38 @@ func AddMessageHeaders(h http.Header, m *wrp.Message) {
 	if len(m.Path) > 0 {
 		h.Set(PathHeader, m.Path)
 	}
+
+	for _, v := range m.Headers {
+		h.Add(HeadersHeader, v)
+	}
+
+	if len(m.Metadata) > 0 {
+		keys := make([]string, 0, len(m.Metadata))
+		for k := range m.Metadata {
+			if !isMappedMetadata(mappings, k) {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			h.Add(MetadataHeader, k+"="+m.Metadata[k])
+		}
+	}
+
+	if len(m.ServiceName) > 0 {
+		h.Set(ServiceNameHeader, m.ServiceName)
+	}
+
+	if len(m.URL) > 0 {
+		h.Set(URLHeader, m.URL)
+	}
+
+	if m.QualityOfService != 0 {
+		h.Set(QOSHeader, strconv.Itoa(int(m.QualityOfService)))
+	}
+
+	addMappedHeaders(h, m, mappings)
 }
 
 // WriteMessagePayload writes the WRP payload to the given io.Writer.  If the message has no
@@ -238,3 +413,31 @@ func WriteMessagePayload(h http.Header, p io.Writer, m *wrp.Message) error {
 	_, err := p.Write(m.Payload)
 	return err
 }
+
+// AddSpanHeaders adds a SpanHeader for each span, using the same representation as the WRP spans field.
+// The resulting headers are parsed back into the message's Spans field by NewMessageFromHeaders and SetMessageFromHeaders.
+func AddSpanHeaders(h http.Header, spans []tracing.Span) {
+	for _, s := range spans {
+		h.Add(SpanHeader, strings.Join(wrp.EncodeSpan(s), ","))
+	}
+}
+
+// SpansFromHeaders decodes the spans in the given header, as written by AddSpanHeaders
+func SpansFromHeaders(h http.Header) ([]tracing.Span, error) {
+	var spans []tracing.Span
+	for _, value := range h[SpanHeader] {
+		fields := strings.Split(value, ",")
+		for i := 0; i < len(fields); i++ {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		s, err := wrp.DecodeSpan(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		spans = append(spans, s)
+	}
+
+	return spans, nil
+}
@@ -8,7 +8,9 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -111,6 +113,25 @@ func testNewMessageFromHeadersSuccess(t *testing.T) {
 					Payload:     []byte("payload"),
 				},
 			},
+			{
+				header: http.Header{
+					MessageTypeHeader: []string{"Retrieve"},
+					HeadersHeader:     []string{"Header1", "Header2"},
+					MetadataHeader:    []string{"/cpe/name=value", "key = a=b"},
+					ServiceNameHeader: []string{"config"},
+					URLHeader:         []string{"local:/config"},
+					QOSHeader:         []string{"75"},
+				},
+				payload: nil,
+				expected: wrp.Message{
+					Type:             wrp.RetrieveMessageType,
+					Headers:          []string{"Header1", "Header2"},
+					Metadata:         map[string]string{"/cpe/name": "value", "key": "a=b"},
+					ServiceName:      "config",
+					URL:              "local:/config",
+					QualityOfService: wrp.QOSCriticalValue,
+				},
+			},
 		}
 	)
 
@@ -180,6 +201,23 @@ func testNewMessageFromHeadersBadSpanHeader(t *testing.T) {
 	assert.Error(err)
 }
 
+func testNewMessageFromHeadersBadMetadataHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, invalid := range []string{"no equals sign", "=no key"} {
+		message, err := NewMessageFromHeaders(
+			http.Header{
+				MessageTypeHeader: []string{wrp.SimpleEventMessageType.FriendlyName()},
+				MetadataHeader:    []string{invalid},
+			},
+			nil,
+		)
+
+		assert.Nil(message)
+		assert.Error(err)
+	}
+}
+
 func testNewMessageFromHeadersBadPayload(t *testing.T) {
 	var (
 		assert = assert.New(t)
@@ -201,6 +239,39 @@ func testNewMessageFromHeadersBadPayload(t *testing.T) {
 	reader.AssertExpectations(t)
 }
 
+func testNewMessageFromHeadersLargeContentLength(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	// the declared length is never trusted for allocation
+	message, err := NewMessageFromHeaders(
+		http.Header{
+			MessageTypeHeader: []string{wrp.SimpleEventMessageType.FriendlyName()},
+			"Content-Length":  []string{"8000000000"},
+		},
+		strings.NewReader("payload"),
+	)
+
+	require.NoError(err)
+	require.NotNil(message)
+	assert.Equal([]byte("payload"), message.Payload)
+}
+
+func TestHeaderValue(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = http.Header{SourceHeader: []string{"test"}, "X-Empty": []string{}}
+	)
+
+	assert.Equal("test", headerValue(h, SourceHeader))
+	assert.Equal("test", headerValue(h, "x-xmidt-source"))
+	assert.Equal(h.Get("x-xmidt-source"), headerValue(h, "x-xmidt-source"))
+	assert.Empty(headerValue(h, "X-Empty"))
+	assert.Empty(headerValue(h, DestinationHeader))
+}
+
 func TestNewMessageFromHeaders(t *testing.T) {
 	t.Run("Success", testNewMessageFromHeadersSuccess)
 	t.Run("BadMessageType", testNewMessageFromHeadersBadMessageType)
@@ -208,6 +279,7 @@ func TestNewMessageFromHeaders(t *testing.T) {
 	t.Run("BadIntHeader", func(t *testing.T) {
 		testNewMessageFromHeadersBadIntHeader(t, StatusHeader)
 		testNewMessageFromHeadersBadIntHeader(t, RequestDeliveryResponseHeader)
+		testNewMessageFromHeadersBadIntHeader(t, QOSHeader)
 	})
 
 	t.Run("BadBoolHeader", func(t *testing.T) {
@@ -215,7 +287,9 @@ func TestNewMessageFromHeaders(t *testing.T) {
 	})
 
 	t.Run("BadSpanHeader", testNewMessageFromHeadersBadSpanHeader)
+	t.Run("BadMetadataHeader", testNewMessageFromHeadersBadMetadataHeader)
 	t.Run("BadPayload", testNewMessageFromHeadersBadPayload)
+	t.Run("LargeContentLength", testNewMessageFromHeadersLargeContentLength)
 }
 
 func TestAddMessageHeaders(t *testing.T) {
@@ -264,6 +338,24 @@ func TestAddMessageHeaders(t *testing.T) {
 					PathHeader:                    []string{"/foo/bar"},
 				},
 			},
+			{
+				message: wrp.Message{
+					Type:             wrp.UpdateMessageType,
+					Headers:          []string{"Header1", "Header2"},
+					Metadata:         map[string]string{"b": "2", "a": "1"},
+					ServiceName:      "config",
+					URL:              "local:/config",
+					QualityOfService: 30,
+				},
+				expected: http.Header{
+					MessageTypeHeader: []string{wrp.UpdateMessageType.FriendlyName()},
+					HeadersHeader:     []string{"Header1", "Header2"},
+					MetadataHeader:    []string{"a=1", "b=2"},
+					ServiceNameHeader: []string{"config"},
+					URLHeader:         []string{"local:/config"},
+					QOSHeader:         []string{"30"},
+				},
+			},
 		}
 	)
 
@@ -344,3 +436,105 @@ func TestWriteMessagePayload(t *testing.T) {
 	t.Run("NoHeader", testWriteMessagePayloadNoHeader)
 	t.Run("WithHeader", testWriteMessagePayloadWithHeader)
 }
+
+func TestSpanHeaders(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		start    = time.Date(2017, time.November, 14, 17, 30, 15, 0, time.UTC)
+		duration = 250 * time.Millisecond
+		spanner  = tracing.NewSpanner(
+			tracing.Now(func() time.Time { return start }),
+			tracing.Since(func(time.Time) time.Duration { return duration }),
+		)
+
+		spans = []tracing.Span{
+			spanner.Start("first")(nil),
+			spanner.Start("second")(nil),
+		}
+
+		h = make(http.Header)
+	)
+
+	AddSpanHeaders(h, spans)
+	assert.Equal(
+		[]string{"first,2017-11-14T17:30:15Z,250ms", "second,2017-11-14T17:30:15Z,250ms"},
+		h[SpanHeader],
+	)
+
+	actual, err := SpansFromHeaders(h)
+	require.NoError(err)
+	require.Len(actual, 2)
+	for i, s := range spans {
+		assert.Equal(s.Name(), actual[i].Name())
+		assert.Equal(s.Start(), actual[i].Start())
+		assert.Equal(s.Duration(), actual[i].Duration())
+	}
+
+	// the headers also populate the message's spans field
+	h.Set(MessageTypeHeader, wrp.SimpleEventMessageType.FriendlyName())
+	message, err := NewMessageFromHeaders(h, nil)
+	require.NoError(err)
+	assert.Equal([][]string{{"first", "2017-11-14T17:30:15Z", "250ms"}, {"second", "2017-11-14T17:30:15Z", "250ms"}}, message.Spans)
+
+	actual, err = SpansFromHeaders(http.Header{SpanHeader: []string{"invalid"}})
+	assert.Empty(actual)
+	assert.Error(err)
+
+	actual, err = SpansFromHeaders(http.Header{})
+	assert.Empty(actual)
+	assert.NoError(err)
+}
+
+// benchmarkHeaders produces a representative header representation of a WRP message
+func benchmarkHeaders() http.Header {
+	return http.Header{
+		MessageTypeHeader:             []string{"SimpleRequestResponse"},
+		SourceHeader:                  []string{"dns:talaria.example.com"},
+		DestinationHeader:             []string{"mac:112233445566/config"},
+		TransactionUuidHeader:         []string{"2b3f9e3a-95f4-4c6c-9e6a-1e1d0a7b3c40"},
+		StatusHeader:                  []string{"200"},
+		RequestDeliveryResponseHeader: []string{"0"},
+		IncludeSpansHeader:            []string{"true"},
+		SpanHeader:                    []string{"first, 2018-03-04T05:06:07Z, 10ms", "second, 2018-03-04T05:06:08Z, 20ms"},
+		AcceptHeader:                  []string{"application/json"},
+		PathHeader:                    []string{"/config"},
+		HeadersHeader:                 []string{"X-Header-1", "X-Header-2"},
+		MetadataHeader:                []string{"hw-model=model", "fw-name=firmware", "/trust=1000"},
+		QOSHeader:                     []string{"25"},
+		"Content-Type":                []string{"application/json"},
+	}
+}
+
+func BenchmarkSetMessageFromHeaders(b *testing.B) {
+	h := benchmarkHeaders()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var m wrp.Message
+		if err := SetMessageFromHeaders(h, &m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewMessageFromHeaders(b *testing.B) {
+	var (
+		h       = benchmarkHeaders()
+		payload = bytes.Repeat([]byte("x"), 1024)
+		reader  = bytes.NewReader(payload)
+	)
+
+	h.Set("Content-Length", strconv.Itoa(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader.Reset(payload)
+		if _, err := NewMessageFromHeaders(h, reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package wrphttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/webpa-common/tracing/tracinghttp"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// NegotiateFormat selects the WRP format for a response given the value of an HTTP Accept header.  Only the
+// supplied formats are considered, and the media range with the highest quality value wins, with ties going to the
+// earliest range in the header.  Wildcard ranges, such as */* or application/*, select defaultFormat.  If accept is
+// empty or none of its ranges match a supported format, defaultFormat is returned.
+func NegotiateFormat(accept string, defaultFormat wrp.Format, supported ...wrp.Format) wrp.Format {
+	var (
+		selected = defaultFormat
+		best     = 0.0
+	)
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, quality := parseMediaRange(mediaRange)
+		if quality <= best {
+			continue
+		}
+
+		if mediaType == "*/*" || mediaType == "application/*" {
+			selected, best = defaultFormat, quality
+			continue
+		}
+
+		format, err := wrp.FormatFromContentType(mediaType)
+		if err != nil {
+			continue
+		}
+
+		for _, candidate := range supported {
+			if candidate == format {
+				selected, best = format, quality
+				break
+			}
+		}
+	}
+
+	return selected
+}
+
+// parseMediaRange splits a single Accept media range into its lowercased media type and its quality value.
+// A missing or unparseable quality value is treated as 1.
+func parseMediaRange(mediaRange string) (string, float64) {
+	var (
+		parameters = strings.Split(mediaRange, ";")
+		mediaType  = strings.ToLower(strings.TrimSpace(parameters[0]))
+		quality    = 1.0
+	)
+
+	for _, parameter := range parameters[1:] {
+		parameter = strings.TrimSpace(parameter)
+		if len(parameter) > 2 && (parameter[0] == 'q' || parameter[0] == 'Q') && parameter[1] == '=' {
+			if value, err := strconv.ParseFloat(parameter[2:], 64); err == nil {
+				quality = value
+			}
+		}
+	}
+
+	return mediaType, quality
+}
+
+// ServerNegotiateResponseBody produces a go-kit transport/http.EncodeResponseFunc that works like ServerEncodeResponseBody,
+// except that the format of the response is negotiated using the original request's Accept header.  The defaultPool is
+// used when the request does not express a preference, while the additional pools supply the other formats a client may ask for.
+//
+// The Accept header is read from the context under gokithttp.ContextKeyRequestAccept, so the server must be configured
+// with gokithttp.ServerBefore(gokithttp.PopulateRequestContext).
+func ServerNegotiateResponseBody(timeLayout string, defaultPool *wrp.EncoderPool, pools ...*wrp.EncoderPool) gokithttp.EncodeResponseFunc {
+	var (
+		byFormat  = map[wrp.Format]*wrp.EncoderPool{defaultPool.Format(): defaultPool}
+		supported = []wrp.Format{defaultPool.Format()}
+	)
+
+	for _, pool := range pools {
+		if _, ok := byFormat[pool.Format()]; !ok {
+			byFormat[pool.Format()] = pool
+			supported = append(supported, pool.Format())
+		}
+	}
+
+	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+		var (
+			wrpResponse = value.(wrpendpoint.Response)
+			accept, _   = ctx.Value(gokithttp.ContextKeyRequestAccept).(string)
+			pool        = byFormat[NegotiateFormat(accept, defaultPool.Format(), supported...)]
+			output      bytes.Buffer
+		)
+
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
+
+		if err := wrpResponse.Encode(&output, pool); err != nil {
+			return err
+		}
+
+		httpResponse.Header().Set("Content-Type", pool.Format().ContentType())
+		httpResponse.Header().Add("Vary", "Accept")
+		_, err := output.WriteTo(httpResponse)
+		return err
+	}
+}
@@ -0,0 +1,129 @@
+package wrphttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/wrp"
+	gokithttp "github.com/go-kit/kit/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	testData := []struct {
+		accept        string
+		defaultFormat wrp.Format
+		supported     []wrp.Format
+		expected      wrp.Format
+	}{
+		{"", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.Msgpack},
+		{"", wrp.JSON, nil, wrp.JSON},
+		{"application/json", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.JSON},
+		{"application/json", wrp.Msgpack, nil, wrp.Msgpack},
+		{"Application/JSON; charset=utf-8", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.JSON},
+		{"application/msgpack", wrp.JSON, []wrp.Format{wrp.Msgpack}, wrp.Msgpack},
+		{"application/x-msgpack", wrp.JSON, []wrp.Format{wrp.Msgpack}, wrp.Msgpack},
+		{"application/json, application/msgpack", wrp.CBOR, []wrp.Format{wrp.Msgpack, wrp.JSON}, wrp.JSON},
+		{"application/json;q=0.5, application/msgpack", wrp.CBOR, []wrp.Format{wrp.Msgpack, wrp.JSON}, wrp.Msgpack},
+		{"application/json;q=0.5, */*;q=0.9", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.Msgpack},
+		{"application/json;q=0.9, application/*;q=0.5", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.JSON},
+		{"application/json;q=0", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.Msgpack},
+		{"application/json;q=garbage", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.JSON},
+		{"text/html, image/png", wrp.Msgpack, []wrp.Format{wrp.JSON}, wrp.Msgpack},
+	}
+
+	for _, record := range testData {
+		t.Run(record.accept, func(t *testing.T) {
+			assert.Equal(t, record.expected, NegotiateFormat(record.accept, record.defaultFormat, record.supported...))
+		})
+	}
+}
+
+func testServerNegotiateResponseBodySuccess(t *testing.T, accept string, expected wrp.Format) {
+	var (
+		assert      = assert.New(t)
+		msgpackPool = wrp.NewEncoderPool(1, wrp.Msgpack)
+		jsonPool    = wrp.NewEncoderPool(1, wrp.JSON)
+		pools       = map[wrp.Format]*wrp.EncoderPool{wrp.Msgpack: msgpackPool, wrp.JSON: jsonPool}
+
+		expectedPayload = []byte("expected payload")
+		ctx             = context.WithValue(context.Background(), gokithttp.ContextKeyRequestAccept, accept)
+		httpResponse    = httptest.NewRecorder()
+		wrpResponse     = new(mockRequestResponse)
+	)
+
+	wrpResponse.On("Spans").Return([]tracing.Span{})
+	wrpResponse.On("Encode", mock.MatchedBy(func(io.Writer) bool { return true }), pools[expected]).
+		Run(func(arguments mock.Arguments) {
+			output := arguments.Get(0).(io.Writer)
+			output.Write(expectedPayload)
+		}).
+		Return(error(nil)).Once()
+
+	assert.NoError(ServerNegotiateResponseBody("", msgpackPool, jsonPool, msgpackPool)(ctx, httpResponse, wrpResponse))
+	assert.Equal(http.StatusOK, httpResponse.Code)
+	assert.Equal(expected.ContentType(), httpResponse.HeaderMap.Get("Content-Type"))
+	assert.Equal("Accept", httpResponse.HeaderMap.Get("Vary"))
+	assert.Equal(expectedPayload, httpResponse.Body.Bytes())
+
+	wrpResponse.AssertExpectations(t)
+}
+
+func testServerNegotiateResponseBodyNoAccept(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		pool   = wrp.NewEncoderPool(1, wrp.JSON)
+
+		httpResponse = httptest.NewRecorder()
+		wrpResponse  = new(mockRequestResponse)
+	)
+
+	wrpResponse.On("Spans").Return([]tracing.Span{})
+	wrpResponse.On("Encode", mock.MatchedBy(func(io.Writer) bool { return true }), pool).
+		Return(error(nil)).Once()
+
+	assert.NoError(ServerNegotiateResponseBody("", pool)(context.Background(), httpResponse, wrpResponse))
+	assert.Equal(wrp.JSON.ContentType(), httpResponse.HeaderMap.Get("Content-Type"))
+
+	wrpResponse.AssertExpectations(t)
+}
+
+func testServerNegotiateResponseBodyEncodeError(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		pool     = wrp.NewEncoderPool(1, wrp.Msgpack)
+		jsonPool = wrp.NewEncoderPool(1, wrp.JSON)
+
+		ctx          = context.WithValue(context.Background(), gokithttp.ContextKeyRequestAccept, "application/json")
+		httpResponse = httptest.NewRecorder()
+		wrpResponse  = new(mockRequestResponse)
+	)
+
+	wrpResponse.On("Spans").Return([]tracing.Span{})
+	wrpResponse.On("Encode", mock.MatchedBy(func(io.Writer) bool { return true }), jsonPool).
+		Return(errors.New("expected error")).Once()
+
+	assert.Error(ServerNegotiateResponseBody("", pool, jsonPool)(ctx, httpResponse, wrpResponse))
+	assert.Empty(httpResponse.HeaderMap)
+	assert.Empty(httpResponse.Body.Bytes())
+
+	wrpResponse.AssertExpectations(t)
+}
+
+func TestServerNegotiateResponseBody(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		testServerNegotiateResponseBodySuccess(t, "", wrp.Msgpack)
+		testServerNegotiateResponseBodySuccess(t, "application/json", wrp.JSON)
+		testServerNegotiateResponseBodySuccess(t, "application/json;q=0.1, application/msgpack", wrp.Msgpack)
+		testServerNegotiateResponseBodySuccess(t, "application/cbor", wrp.Msgpack)
+	})
+
+	t.Run("NoAccept", testServerNegotiateResponseBodyNoAccept)
+	t.Run("EncodeError", testServerNegotiateResponseBodyEncodeError)
+}
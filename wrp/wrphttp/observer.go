@@ -0,0 +1,256 @@
+package wrphttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+//go:generate stringer -type=Operation
+
+// Operation identifies whether a CodecEvent describes encoding or decoding
+type Operation int
+
+const (
+	// DecodeOperation indicates that an HTTP request or response was decoded as a WRP message
+	DecodeOperation Operation = iota
+
+	// EncodeOperation indicates that a WRP message was encoded as an HTTP request or response
+	EncodeOperation
+)
+
+// CodecEvent describes a single WRP encode or decode performed by one of the go-kit functions in this package
+type CodecEvent struct {
+	// Name is the name of the function in this package which produced the encoder or decoder, e.g. "ServerDecodeRequestBody"
+	Name string
+
+	// Operation indicates whether a message was encoded or decoded
+	Operation Operation
+
+	// Headers is true if the WRP message was represented with HTTP headers, in which case the entity is just the payload
+	// and Format is not meaningful
+	Headers bool
+
+	// Format is the WRP format of the HTTP entity
+	Format wrp.Format
+
+	// MessageType is the type of the WRP message.  This field is zero if no message was available, e.g. because decoding failed.
+	MessageType wrp.MessageType
+
+	// Size is the number of bytes in the HTTP entity that was read or written
+	Size int
+
+	// Duration is the length of time the encode or decode took
+	Duration time.Duration
+
+	// Err is the error returned by the encoder or decoder, if any
+	Err error
+}
+
+// Observer receives a CodecEvent for every encode and decode performed by this package's go-kit functions.
+// Observers are invoked synchronously, and so must not block.
+type Observer interface {
+	ObserveCodec(context.Context, CodecEvent)
+}
+
+// ObserverFunc is a function type that implements Observer
+type ObserverFunc func(context.Context, CodecEvent)
+
+func (of ObserverFunc) ObserveCodec(ctx context.Context, e CodecEvent) {
+	of(ctx, e)
+}
+
+// observerEntry wraps a registered Observer, so that each registration has a distinct identity
+type observerEntry struct {
+	observer Observer
+}
+
+// observers is the registry of Observers, replaced rather than modified whenever it changes
+var observers struct {
+	lock    sync.RWMutex
+	entries []*observerEntry
+}
+
+// RegisterObserver adds an Observer which is notified of every WRP encode and decode performed by the go-kit functions
+// in this package, including those created before the Observer was registered.  This is typically used for audit logging
+// and metrics.  The returned function removes the Observer, and may be called more than once.
+//
+// Observers are global, and are normally registered during initialization.  This function is safe for concurrent use.
+func RegisterObserver(o Observer) func() {
+	if o == nil {
+		panic("No Observer supplied")
+	}
+
+	entry := &observerEntry{observer: o}
+
+	observers.lock.Lock()
+	entries := make([]*observerEntry, len(observers.entries), len(observers.entries)+1)
+	copy(entries, observers.entries)
+	observers.entries = append(entries, entry)
+	observers.lock.Unlock()
+
+	return func() {
+		observers.lock.Lock()
+		defer observers.lock.Unlock()
+
+		for i, e := range observers.entries {
+			if e == entry {
+				entries := make([]*observerEntry, 0, len(observers.entries)-1)
+				entries = append(entries, observers.entries[:i]...)
+				observers.entries = append(entries, observers.entries[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func currentObservers() []*observerEntry {
+	observers.lock.RLock()
+	entries := observers.entries
+	observers.lock.RUnlock()
+	return entries
+}
+
+// notifyObservers dispatches an event to each of the given observers
+func notifyObservers(ctx context.Context, entries []*observerEntry, e CodecEvent) {
+	for _, entry := range entries {
+		entry.observer.ObserveCodec(ctx, e)
+	}
+}
+
+// observedMessage extracts the WRP message, and format if known, from the value produced or consumed by a codec function
+func observedMessage(v interface{}, format wrp.Format) (*wrp.Message, wrp.Format) {
+	switch m := v.(type) {
+	case wrpendpoint.Note:
+		return m.Message(), format
+	case *Entity:
+		return &m.Message, m.Format
+	case Entity:
+		return &m.Message, m.Format
+	}
+
+	return nil, format
+}
+
+// newCodecEvent produces the event for a finished encode or decode
+func newCodecEvent(name string, op Operation, headers bool, format wrp.Format, v interface{}, size int, start time.Time, err error) CodecEvent {
+	e := CodecEvent{
+		Name:      name,
+		Operation: op,
+		Headers:   headers,
+		Size:      size,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+
+	var m *wrp.Message
+	if m, e.Format = observedMessage(v, format); m != nil {
+		e.MessageType = m.Type
+	}
+
+	return e
+}
+
+// observedBody counts the bytes read from an HTTP entity
+type observedBody struct {
+	io.ReadCloser
+	count int
+}
+
+func (ob *observedBody) Read(p []byte) (int, error) {
+	n, err := ob.ReadCloser.Read(p)
+	ob.count += n
+	return n, err
+}
+
+// observedResponseWriter counts the bytes written to an HTTP response
+type observedResponseWriter struct {
+	http.ResponseWriter
+	count int
+}
+
+func (orw *observedResponseWriter) Write(p []byte) (int, error) {
+	n, err := orw.ResponseWriter.Write(p)
+	orw.count += n
+	return n, err
+}
+
+// observeDecodeRequest decorates a server decoder so that it notifies any registered observers
+func observeDecodeRequest(name string, headers bool, format wrp.Format, decode gokithttp.DecodeRequestFunc) gokithttp.DecodeRequestFunc {
+	return func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+		entries := currentObservers()
+		if len(entries) == 0 || httpRequest.Body == nil {
+			return decode(ctx, httpRequest)
+		}
+
+		var (
+			start = time.Now()
+			body  = &observedBody{ReadCloser: httpRequest.Body}
+		)
+
+		httpRequest.Body = body
+		v, err := decode(ctx, httpRequest)
+		notifyObservers(ctx, entries, newCodecEvent(name, DecodeOperation, headers, format, v, body.count, start, err))
+		return v, err
+	}
+}
+
+// observeDecodeResponse decorates a client decoder so that it notifies any registered observers
+func observeDecodeResponse(name string, headers bool, format wrp.Format, decode gokithttp.DecodeResponseFunc) gokithttp.DecodeResponseFunc {
+	return func(ctx context.Context, httpResponse *http.Response) (interface{}, error) {
+		entries := currentObservers()
+		if len(entries) == 0 || httpResponse.Body == nil {
+			return decode(ctx, httpResponse)
+		}
+
+		var (
+			start = time.Now()
+			body  = &observedBody{ReadCloser: httpResponse.Body}
+		)
+
+		httpResponse.Body = body
+		v, err := decode(ctx, httpResponse)
+		notifyObservers(ctx, entries, newCodecEvent(name, DecodeOperation, headers, format, v, body.count, start, err))
+		return v, err
+	}
+}
+
+// observeEncodeRequest decorates a client encoder so that it notifies any registered observers
+func observeEncodeRequest(name string, headers bool, format wrp.Format, encode gokithttp.EncodeRequestFunc) gokithttp.EncodeRequestFunc {
+	return func(ctx context.Context, httpRequest *http.Request, v interface{}) error {
+		entries := currentObservers()
+		if len(entries) == 0 {
+			return encode(ctx, httpRequest, v)
+		}
+
+		start := time.Now()
+		err := encode(ctx, httpRequest, v)
+		notifyObservers(ctx, entries, newCodecEvent(name, EncodeOperation, headers, format, v, int(httpRequest.ContentLength), start, err))
+		return err
+	}
+}
+
+// observeEncodeResponse decorates a server encoder so that it notifies any registered observers
+func observeEncodeResponse(name string, headers bool, format wrp.Format, encode gokithttp.EncodeResponseFunc) gokithttp.EncodeResponseFunc {
+	return func(ctx context.Context, httpResponse http.ResponseWriter, v interface{}) error {
+		entries := currentObservers()
+		if len(entries) == 0 {
+			return encode(ctx, httpResponse, v)
+		}
+
+		var (
+			start  = time.Now()
+			output = &observedResponseWriter{ResponseWriter: httpResponse}
+		)
+
+		err := encode(ctx, output, v)
+		notifyObservers(ctx, entries, newCodecEvent(name, EncodeOperation, headers, format, v, output.count, start, err))
+		return err
+	}
+}
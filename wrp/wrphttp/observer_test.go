@@ -0,0 +1,195 @@
+package wrphttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testObserver records the events it receives
+type testObserver struct {
+	lock   sync.Mutex
+	events []CodecEvent
+}
+
+func (to *testObserver) ObserveCodec(_ context.Context, e CodecEvent) {
+	to.lock.Lock()
+	to.events = append(to.events, e)
+	to.lock.Unlock()
+}
+
+func (to *testObserver) take() []CodecEvent {
+	to.lock.Lock()
+	defer to.lock.Unlock()
+	events := to.events
+	to.events = nil
+	return events
+}
+
+func TestOperationString(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("DecodeOperation", DecodeOperation.String())
+	assert.Equal("EncodeOperation", EncodeOperation.String())
+	assert.Equal("Operation(5)", Operation(5).String())
+}
+
+func TestRegisterObserver(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		observer = new(testObserver)
+		called   = 0
+		other    = ObserverFunc(func(context.Context, CodecEvent) { called++ })
+
+		unregister      = RegisterObserver(observer)
+		unregisterOther = RegisterObserver(other)
+	)
+
+	assert.Len(currentObservers(), 2)
+	notifyObservers(context.Background(), currentObservers(), CodecEvent{Name: "test"})
+	assert.Equal([]CodecEvent{{Name: "test"}}, observer.take())
+	assert.Equal(1, called)
+
+	unregister()
+	unregister()
+	assert.Len(currentObservers(), 1)
+
+	unregisterOther()
+	assert.Empty(currentObservers())
+
+	assert.Panics(func() { RegisterObserver(nil) })
+}
+
+func testObserverServer(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		logger   = logging.NewTestLogger(nil, t)
+		observer = new(testObserver)
+
+		message = wrp.Message{Type: wrp.SimpleEventMessageType, Source: "test", Destination: "mac:112233445566"}
+		encoded = wrp.MustEncode(&message, wrp.Msgpack)
+	)
+
+	defer RegisterObserver(observer)()
+
+	httpRequest := httptest.NewRequest("POST", "/", bytes.NewReader(encoded))
+	value, err := ServerDecodeRequestBody(logger, wrp.NewDecoderPool(1, wrp.Msgpack))(context.Background(), httpRequest)
+	require.NoError(err)
+
+	response := httptest.NewRecorder()
+	require.NoError(
+		ServerEncodeResponseBody("", wrp.NewEncoderPool(1, wrp.JSON))(
+			context.Background(),
+			response,
+			wrpendpoint.WrapAsResponse(value.(wrpendpoint.Request).Message()),
+		),
+	)
+
+	events := observer.take()
+	require.Len(events, 2)
+
+	assert.Equal("ServerDecodeRequestBody", events[0].Name)
+	assert.Equal(DecodeOperation, events[0].Operation)
+	assert.False(events[0].Headers)
+	assert.Equal(wrp.Msgpack, events[0].Format)
+	assert.Equal(wrp.SimpleEventMessageType, events[0].MessageType)
+	assert.Equal(len(encoded), events[0].Size)
+	assert.True(events[0].Duration >= 0)
+	assert.NoError(events[0].Err)
+
+	assert.Equal("ServerEncodeResponseBody", events[1].Name)
+	assert.Equal(EncodeOperation, events[1].Operation)
+	assert.Equal(wrp.JSON, events[1].Format)
+	assert.Equal(wrp.SimpleEventMessageType, events[1].MessageType)
+	assert.Equal(response.Body.Len(), events[1].Size)
+	assert.NoError(events[1].Err)
+}
+
+func testObserverClient(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		logger   = logging.NewTestLogger(nil, t)
+		observer = new(testObserver)
+
+		message = &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Source: "test", Destination: "mac:112233445566", Payload: []byte("payload")}
+	)
+
+	defer RegisterObserver(observer)()
+
+	httpRequest := httptest.NewRequest("POST", "/", nil)
+	require.NoError(
+		ClientEncodeRequestHeaders(nil)(context.Background(), httpRequest, wrpendpoint.WrapAsRequest(logger, message)),
+	)
+
+	_, err := ClientDecodeResponseHeaders(
+		context.Background(),
+		&http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("payload")),
+		},
+	)
+
+	require.Error(err)
+
+	events := observer.take()
+	require.Len(events, 2)
+
+	assert.Equal("ClientEncodeRequestHeaders", events[0].Name)
+	assert.Equal(EncodeOperation, events[0].Operation)
+	assert.True(events[0].Headers)
+	assert.Equal(wrp.SimpleRequestResponseMessageType, events[0].MessageType)
+	assert.Equal(len(message.Payload), events[0].Size)
+	assert.NoError(events[0].Err)
+
+	assert.Equal("ClientDecodeResponseHeaders", events[1].Name)
+	assert.Equal(DecodeOperation, events[1].Operation)
+	assert.True(events[1].Headers)
+	assert.Zero(events[1].MessageType)
+	assert.Equal(len("payload"), events[1].Size)
+	assert.True(errors.Is(events[1].Err, ErrMissingHeader))
+}
+
+func testObserverEntity(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		observer = new(testObserver)
+
+		message = wrp.Message{Type: wrp.SimpleEventMessageType, Source: "test", Destination: "mac:112233445566"}
+		encoded = wrp.MustEncode(&message, wrp.JSON)
+	)
+
+	defer RegisterObserver(observer)()
+
+	httpRequest := httptest.NewRequest("POST", "/", bytes.NewReader(encoded))
+	httpRequest.Header.Set("Content-Type", wrp.JSON.ContentType())
+	_, err := DecodeRequest(context.Background(), httpRequest)
+	require.NoError(err)
+
+	events := observer.take()
+	require.Len(events, 1)
+	assert.Equal("DecodeRequest", events[0].Name)
+	assert.Equal(wrp.JSON, events[0].Format)
+	assert.Equal(wrp.SimpleEventMessageType, events[0].MessageType)
+	assert.Equal(len(encoded), events[0].Size)
+}
+
+func TestObserver(t *testing.T) {
+	t.Run("Server", testObserverServer)
+	t.Run("Client", testObserverClient)
+	t.Run("Entity", testObserverEntity)
+}
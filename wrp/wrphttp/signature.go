@@ -0,0 +1,203 @@
+package wrphttp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xhttp"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+const (
+	SignatureHeader          = "X-Xmidt-Signature"
+	SignatureKeyIDHeader     = "X-Xmidt-Signature-Key-Id"
+	SignatureAlgorithmHeader = "X-Xmidt-Signature-Algorithm"
+
+	// HMACSHA256 is the signature algorithm used with symmetric keys, which are represented as []byte
+	HMACSHA256 = "hmac-sha256"
+
+	// RSASHA256 is the signature algorithm used with RSA keys, which are PKCS #1 v1.5 signatures of the SHA-256 digest
+	RSASHA256 = "rsa-sha256"
+)
+
+var (
+	// ErrUnsupportedSigningKey indicates that a resolved key cannot be used to sign or verify WRP messages
+	ErrUnsupportedSigningKey = errors.New("Unsupported WRP signing key")
+
+	// ErrMissingSignature is returned when a signature is required but the HTTP request is not signed
+	ErrMissingSignature = &xhttp.Error{Code: http.StatusUnauthorized, Text: "Missing WRP signature"}
+
+	// ErrInvalidSignature is returned when the signature of an HTTP request does not match its contents
+	ErrInvalidSignature = &xhttp.Error{Code: http.StatusUnauthorized, Text: "Invalid WRP signature"}
+)
+
+// Sign produces a signature of the given contents using a resolved key.  An RSA private key produces an RSASHA256
+// signature, while a symmetric key, i.e. a pair whose public key is a []byte, produces an HMACSHA256 signature.
+func Sign(pair key.Pair, contents []byte) (string, []byte, error) {
+	if privateKey, ok := pair.Private().(*rsa.PrivateKey); ok {
+		digest := sha256.Sum256(contents)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		return RSASHA256, signature, err
+	}
+
+	if secret, ok := pair.Public().([]byte); ok {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(contents)
+		return HMACSHA256, mac.Sum(nil), nil
+	}
+
+	return "", nil, ErrUnsupportedSigningKey
+}
+
+// Verify checks a signature produced by Sign.  The algorithm must match the type of the resolved key.
+func Verify(pair key.Pair, algorithm string, contents, signature []byte) error {
+	switch k := pair.Public().(type) {
+	case *rsa.PublicKey:
+		if algorithm != RSASHA256 {
+			return ErrInvalidSignature
+		}
+
+		digest := sha256.Sum256(contents)
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+
+		return nil
+
+	case []byte:
+		if algorithm != HMACSHA256 {
+			return ErrInvalidSignature
+		}
+
+		mac := hmac.New(sha256.New, k)
+		mac.Write(contents)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+
+		return nil
+
+	default:
+		return ErrUnsupportedSigningKey
+	}
+}
+
+// signedContents produces the contents that are signed for an HTTP request.  When the WRP message is carried in
+// the entity, as with ClientEncodeRequestBody, the entity is signed as is.  When the message is carried in headers,
+// as with ClientEncodeRequestHeaders, the message is rebuilt from the headers and payload and signed in its canonical
+// msgpack form, so that neither the WRP headers nor the payload can be altered without invalidating the signature.
+func signedContents(h http.Header, entity []byte) ([]byte, error) {
+	if len(h.Get(MessageTypeHeader)) == 0 {
+		return entity, nil
+	}
+
+	message, err := NewMessageFromHeaders(h, bytes.NewReader(entity))
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []byte
+	if err := wrp.NewCanonicalEncoderBytes(&contents, wrp.Msgpack).Encode(message); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// ClientSignRequest decorates a go-kit EncodeRequestFunc so that the encoded WRP message is signed with the key
+// resolved for keyID.  The signature, algorithm, and key identifier are transmitted as HTTP headers.  The entire
+// WRP message is signed whether it is encoded with ClientEncodeRequestBody or ClientEncodeRequestHeaders, in which
+// case the WRP headers are signed along with the payload.
+func ClientSignRequest(resolver key.Resolver, keyID string, next gokithttp.EncodeRequestFunc) gokithttp.EncodeRequestFunc {
+	return func(ctx context.Context, httpRequest *http.Request, value interface{}) error {
+		if err := next(ctx, httpRequest, value); err != nil {
+			return err
+		}
+
+		var entity []byte
+		if httpRequest.Body != nil {
+			var err error
+			if entity, err = ioutil.ReadAll(httpRequest.Body); err != nil {
+				return err
+			}
+
+			httpRequest.Body.Close()
+			httpRequest.Body = ioutil.NopCloser(bytes.NewReader(entity))
+		}
+
+		contents, err := signedContents(httpRequest.Header, entity)
+		if err != nil {
+			return err
+		}
+
+		pair, err := resolver.ResolveKey(keyID)
+		if err != nil {
+			return err
+		}
+
+		algorithm, signature, err := Sign(pair, contents)
+		if err != nil {
+			return err
+		}
+
+		httpRequest.Header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(signature))
+		httpRequest.Header.Set(SignatureKeyIDHeader, keyID)
+		httpRequest.Header.Set(SignatureAlgorithmHeader, algorithm)
+		return nil
+	}
+}
+
+// ServerVerifyRequest decorates a go-kit DecodeRequestFunc so that the WRP message's signature, as produced by
+// ClientSignRequest, is verified before the request is decoded.  The verification key is resolved using the key identifier sent by the client.  If required
+// is false, unsigned requests are decoded without verification, but a signature that is present must always be valid.
+// Requests that fail verification are rejected with ErrMissingSignature or ErrInvalidSignature.
+func ServerVerifyRequest(resolver key.Resolver, required bool, next gokithttp.DecodeRequestFunc) gokithttp.DecodeRequestFunc {
+	return func(ctx context.Context, httpRequest *http.Request) (interface{}, error) {
+		encoded := httpRequest.Header.Get(SignatureHeader)
+		if len(encoded) == 0 {
+			if required {
+				return nil, ErrMissingSignature
+			}
+
+			return next(ctx, httpRequest)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, ErrInvalidSignature
+		}
+
+		pair, err := resolver.ResolveKey(httpRequest.Header.Get(SignatureKeyIDHeader))
+		if err != nil {
+			return nil, err
+		}
+
+		entity, err := ioutil.ReadAll(httpRequest.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := signedContents(httpRequest.Header, entity)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := Verify(pair, httpRequest.Header.Get(SignatureAlgorithmHeader), contents, signature); err != nil {
+			return nil, err
+		}
+
+		httpRequest.Body = ioutil.NopCloser(bytes.NewReader(entity))
+		return next(ctx, httpRequest)
+	}
+}
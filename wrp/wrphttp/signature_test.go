@@ -0,0 +1,318 @@
+package wrphttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRSAPairs produces a signing and a verification key pair backed by the same RSA key
+func newRSAPairs(t *testing.T) (*key.MockPair, *key.MockPair) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var (
+		signPair   = new(key.MockPair)
+		verifyPair = new(key.MockPair)
+	)
+
+	signPair.On("Private").Return(privateKey)
+	signPair.On("Public").Return(privateKey.Public())
+	verifyPair.On("Private").Return(nil)
+	verifyPair.On("Public").Return(privateKey.Public())
+	return signPair, verifyPair
+}
+
+// newHMACPair produces a key pair representing a symmetric secret
+func newHMACPair(secret string) *key.MockPair {
+	pair := new(key.MockPair)
+	pair.On("Private").Return(nil)
+	pair.On("Public").Return([]byte(secret))
+	return pair
+}
+
+func testSignAndVerify(t *testing.T, signPair, verifyPair key.Pair, expectedAlgorithm string) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		contents = []byte("some WRP contents")
+	)
+
+	algorithm, signature, err := Sign(signPair, contents)
+	require.NoError(err)
+	assert.Equal(expectedAlgorithm, algorithm)
+	assert.NotEmpty(signature)
+
+	assert.NoError(Verify(verifyPair, algorithm, contents, signature))
+	assert.Equal(ErrInvalidSignature, Verify(verifyPair, algorithm, []byte("tampered contents"), signature))
+	assert.Equal(ErrInvalidSignature, Verify(verifyPair, algorithm, contents, append([]byte("x"), signature...)))
+	assert.Equal(ErrInvalidSignature, Verify(verifyPair, "unknown", contents, signature))
+}
+
+func TestSign(t *testing.T) {
+	t.Run("RSA", func(t *testing.T) {
+		signPair, verifyPair := newRSAPairs(t)
+		testSignAndVerify(t, signPair, verifyPair, RSASHA256)
+	})
+
+	t.Run("HMAC", func(t *testing.T) {
+		pair := newHMACPair("secret")
+		testSignAndVerify(t, pair, pair, HMACSHA256)
+
+		_, signature, err := Sign(pair, []byte("contents"))
+		require.NoError(t, err)
+		assert.Equal(t, ErrInvalidSignature, Verify(newHMACPair("another secret"), HMACSHA256, []byte("contents"), signature))
+	})
+
+	t.Run("UnsupportedKey", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			pair   = new(key.MockPair)
+		)
+
+		pair.On("Private").Return(nil)
+		pair.On("Public").Return("not a key")
+
+		algorithm, signature, err := Sign(pair, []byte("contents"))
+		assert.Empty(algorithm)
+		assert.Empty(signature)
+		assert.Equal(ErrUnsupportedSigningKey, err)
+		assert.Equal(ErrUnsupportedSigningKey, Verify(pair, HMACSHA256, []byte("contents"), []byte("signature")))
+	})
+}
+
+func testSignatureRoundTrip(t *testing.T, signPair, verifyPair key.Pair) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		signResolver   = new(key.MockResolver)
+		verifyResolver = new(key.MockResolver)
+
+		message = &wrp.Message{
+			Type:        wrp.SimpleRequestResponseMessageType,
+			Source:      "test",
+			Destination: "mac:121212121212",
+			Payload:     []byte("expected payload"),
+		}
+
+		encode = ClientSignRequest(signResolver, "test-key", ClientEncodeRequestBody(wrp.NewEncoderPool(1, wrp.Msgpack), nil))
+		decode = ServerVerifyRequest(verifyResolver, true, DecodeRequest)
+	)
+
+	signResolver.On("ResolveKey", "test-key").Return(signPair, error(nil)).Once()
+	verifyResolver.On("ResolveKey", "test-key").Return(verifyPair, error(nil)).Twice()
+
+	httpRequest := httptest.NewRequest("POST", "/", nil)
+	require.NoError(encode(context.Background(), httpRequest, wrpendpoint.WrapAsRequest(nil, message)))
+	assert.Equal("test-key", httpRequest.Header.Get(SignatureKeyIDHeader))
+	assert.NotEmpty(httpRequest.Header.Get(SignatureHeader))
+	assert.NotEmpty(httpRequest.Header.Get(SignatureAlgorithmHeader))
+
+	contents, err := ioutil.ReadAll(httpRequest.Body)
+	require.NoError(err)
+
+	httpRequest.Body = ioutil.NopCloser(bytes.NewReader(contents))
+	entity, err := decode(context.Background(), httpRequest)
+	require.NoError(err)
+	assert.Equal(*message, entity.(*Entity).Message)
+
+	// tamper with the contents, which must fail verification
+	contents[len(contents)-1]++
+	httpRequest.Body = ioutil.NopCloser(bytes.NewReader(contents))
+	entity, err = decode(context.Background(), httpRequest)
+	assert.Nil(entity)
+	assert.Equal(ErrInvalidSignature, err)
+
+	signResolver.AssertExpectations(t)
+	verifyResolver.AssertExpectations(t)
+}
+
+func testSignatureRoundTripHeaders(t *testing.T, signPair, verifyPair key.Pair) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		signResolver   = new(key.MockResolver)
+		verifyResolver = new(key.MockResolver)
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test",
+			Destination:     "mac:121212121212",
+			TransactionUUID: "1234",
+			ContentType:     "text/plain",
+			Metadata:        map[string]string{"key": "value"},
+			Payload:         []byte("expected payload"),
+		}
+
+		encode = ClientSignRequest(signResolver, "test-key", ClientEncodeRequestHeaders(nil))
+		decode = ServerVerifyRequest(verifyResolver, true, DecodeRequestHeaders)
+	)
+
+	signResolver.On("ResolveKey", "test-key").Return(signPair, error(nil)).Once()
+	verifyResolver.On("ResolveKey", "test-key").Return(verifyPair, error(nil))
+
+	original := httptest.NewRequest("POST", "/", nil)
+	require.NoError(encode(context.Background(), original, wrpendpoint.WrapAsRequest(nil, message)))
+	require.NotEmpty(original.Header.Get(SignatureHeader))
+
+	payload, err := ioutil.ReadAll(original.Body)
+	require.NoError(err)
+
+	newRequest := func(tamper func(http.Header)) *http.Request {
+		httpRequest := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		for name, values := range original.Header {
+			httpRequest.Header[name] = append([]string(nil), values...)
+		}
+
+		tamper(httpRequest.Header)
+		return httpRequest
+	}
+
+	entity, err := decode(context.Background(), newRequest(func(http.Header) {}))
+	require.NoError(err)
+	assert.Equal(message.Source, entity.(Entity).Message.Source)
+	assert.Equal(message.Payload, entity.(Entity).Message.Payload)
+
+	// altering any WRP header must fail verification
+	for _, tamper := range []func(http.Header){
+		func(h http.Header) { h.Set(MessageTypeHeader, wrp.SimpleEventMessageType.FriendlyName()) },
+		func(h http.Header) { h.Set(SourceHeader, "attacker") },
+		func(h http.Header) { h.Set(DestinationHeader, "mac:999999999999") },
+		func(h http.Header) { h.Set(TransactionUuidHeader, "5678") },
+		func(h http.Header) { h.Set(MetadataHeader, "key=tampered") },
+		func(h http.Header) { h.Add(MetadataHeader, "extra=value") },
+		func(h http.Header) { h.Set("Content-Type", "application/json") },
+	} {
+		entity, err := decode(context.Background(), newRequest(tamper))
+		assert.Nil(entity)
+		assert.Equal(ErrInvalidSignature, err)
+	}
+
+	signResolver.AssertExpectations(t)
+	verifyResolver.AssertExpectations(t)
+}
+
+func TestClientSignRequest(t *testing.T) {
+	t.Run("RSA", func(t *testing.T) {
+		signPair, verifyPair := newRSAPairs(t)
+		testSignatureRoundTrip(t, signPair, verifyPair)
+	})
+
+	t.Run("HMAC", func(t *testing.T) {
+		pair := newHMACPair("secret")
+		testSignatureRoundTrip(t, pair, pair)
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		pair := newHMACPair("secret")
+		testSignatureRoundTripHeaders(t, pair, pair)
+	})
+
+	t.Run("EncodeError", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			resolver      = new(key.MockResolver)
+			expectedError = errors.New("expected")
+
+			encode = ClientSignRequest(resolver, "test-key", func(context.Context, *http.Request, interface{}) error {
+				return expectedError
+			})
+		)
+
+		assert.Equal(expectedError, encode(context.Background(), httptest.NewRequest("POST", "/", nil), nil))
+		resolver.AssertExpectations(t)
+	})
+
+	t.Run("ResolveError", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			resolver      = new(key.MockResolver)
+			expectedError = errors.New("expected")
+
+			encode = ClientSignRequest(resolver, "test-key", func(context.Context, *http.Request, interface{}) error {
+				return nil
+			})
+
+			httpRequest = httptest.NewRequest("POST", "/", nil)
+		)
+
+		resolver.On("ResolveKey", "test-key").Return(nil, expectedError).Once()
+		assert.Equal(expectedError, encode(context.Background(), httpRequest, nil))
+		assert.Empty(httpRequest.Header.Get(SignatureHeader))
+		resolver.AssertExpectations(t)
+	})
+}
+
+func TestServerVerifyRequest(t *testing.T) {
+	var (
+		next = func(context.Context, *http.Request) (interface{}, error) {
+			return "decoded", nil
+		}
+
+		newSignedRequest = func(signature string) *http.Request {
+			httpRequest := httptest.NewRequest("POST", "/", bytes.NewBufferString("contents"))
+			httpRequest.Header.Set(SignatureHeader, signature)
+			httpRequest.Header.Set(SignatureKeyIDHeader, "test-key")
+			httpRequest.Header.Set(SignatureAlgorithmHeader, HMACSHA256)
+			return httpRequest
+		}
+	)
+
+	t.Run("Unsigned", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			resolver = new(key.MockResolver)
+		)
+
+		value, err := ServerVerifyRequest(resolver, false, next)(context.Background(), httptest.NewRequest("POST", "/", nil))
+		assert.Equal("decoded", value)
+		assert.NoError(err)
+
+		value, err = ServerVerifyRequest(resolver, true, next)(context.Background(), httptest.NewRequest("POST", "/", nil))
+		assert.Nil(value)
+		assert.Equal(ErrMissingSignature, err)
+		assert.Equal(http.StatusUnauthorized, ErrMissingSignature.StatusCode())
+
+		resolver.AssertExpectations(t)
+	})
+
+	t.Run("BadEncoding", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			resolver = new(key.MockResolver)
+		)
+
+		value, err := ServerVerifyRequest(resolver, false, next)(context.Background(), newSignedRequest("this is not base64!"))
+		assert.Nil(value)
+		assert.Equal(ErrInvalidSignature, err)
+		resolver.AssertExpectations(t)
+	})
+
+	t.Run("ResolveError", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			resolver      = new(key.MockResolver)
+			expectedError = errors.New("expected")
+		)
+
+		resolver.On("ResolveKey", "test-key").Return(nil, expectedError).Once()
+		value, err := ServerVerifyRequest(resolver, false, next)(context.Background(), newSignedRequest("c2lnbmF0dXJl"))
+		assert.Nil(value)
+		assert.Equal(expectedError, err)
+		resolver.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,70 @@
+package wrphttp
+
+import (
+	"net/http"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// StatusMap maps the status field of WRP messages onto HTTP status codes.  A nil StatusMap is valid, and
+// simply applies the default rules described by StatusCode and WRPStatus.
+//
+// A StatusMap is used in both directions:  server encoders use StatusCode to report a WRP response's status
+// as an HTTP status code, while client decoders use WRPStatus to report an HTTP status code as a WRP status.
+type StatusMap map[int64]int
+
+// StatusCode determines the HTTP status code for a WRP message:
+//
+// (a) A message that is nil or has no status is reported as http.StatusOK
+// (b) A status that appears in this map is reported as the mapped HTTP status code
+// (c) A status that is itself a valid HTTP status code, from 100 through 599, is reported as is
+// (d) Any other status is reported as http.StatusInternalServerError
+func (sm StatusMap) StatusCode(m *wrp.Message) int {
+	if m == nil || m.Status == nil {
+		return http.StatusOK
+	}
+
+	if code, ok := sm[*m.Status]; ok {
+		return code
+	}
+
+	if *m.Status >= 100 && *m.Status < 600 {
+		return int(*m.Status)
+	}
+
+	return http.StatusInternalServerError
+}
+
+// WRPStatus determines the WRP status for an HTTP status code, and is the inverse of StatusCode:
+//
+// (a) If one or more WRP statuses in this map are mapped onto the HTTP status code, the smallest such WRP status is returned
+// (b) Otherwise, the HTTP status code is returned as is
+func (sm StatusMap) WRPStatus(code int) int64 {
+	var (
+		status int64
+		found  bool
+	)
+
+	for s, c := range sm {
+		if c == code && (!found || s < status) {
+			status, found = s, true
+		}
+	}
+
+	if found {
+		return status
+	}
+
+	return int64(code)
+}
+
+// setStatus sets a WRP message's status from an HTTP status code.  A message that already has a status is left
+// unchanged, and this function returns false.
+func (sm StatusMap) setStatus(m *wrp.Message, code int) bool {
+	if m.Status != nil {
+		return false
+	}
+
+	m.SetStatus(sm.WRPStatus(code))
+	return true
+}
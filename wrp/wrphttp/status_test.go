@@ -0,0 +1,102 @@
+package wrphttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStatusMapStatusCode(t *testing.T, statusMap StatusMap) {
+	var (
+		assert = assert.New(t)
+		status = func(v int64) *wrp.Message { return &wrp.Message{Status: &v} }
+	)
+
+	assert.Equal(http.StatusOK, statusMap.StatusCode(nil))
+	assert.Equal(http.StatusOK, statusMap.StatusCode(new(wrp.Message)))
+	assert.Equal(http.StatusOK, statusMap.StatusCode(status(200)))
+	assert.Equal(http.StatusNotFound, statusMap.StatusCode(status(404)))
+	assert.Equal(http.StatusInternalServerError, statusMap.StatusCode(status(0)))
+	assert.Equal(http.StatusInternalServerError, statusMap.StatusCode(status(-1)))
+	assert.Equal(http.StatusInternalServerError, statusMap.StatusCode(status(1234)))
+}
+
+func TestStatusMap(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		testStatusMapStatusCode(t, nil)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		testStatusMapStatusCode(t, StatusMap{})
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			status    = func(v int64) *wrp.Message { return &wrp.Message{Status: &v} }
+			statusMap = StatusMap{
+				531:  http.StatusServiceUnavailable,
+				1000: http.StatusBadGateway,
+				404:  http.StatusGone,
+			}
+		)
+
+		assert.Equal(http.StatusOK, statusMap.StatusCode(new(wrp.Message)))
+		assert.Equal(http.StatusServiceUnavailable, statusMap.StatusCode(status(531)))
+		assert.Equal(http.StatusBadGateway, statusMap.StatusCode(status(1000)))
+		assert.Equal(http.StatusGone, statusMap.StatusCode(status(404)))
+		assert.Equal(http.StatusBadRequest, statusMap.StatusCode(status(400)))
+		assert.Equal(http.StatusInternalServerError, statusMap.StatusCode(status(2000)))
+	})
+}
+
+func TestStatusMapWRPStatus(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, statusMap := range []StatusMap{nil, {}} {
+			assert.Equal(int64(200), statusMap.WRPStatus(http.StatusOK))
+			assert.Equal(int64(404), statusMap.WRPStatus(http.StatusNotFound))
+			assert.Equal(int64(503), statusMap.WRPStatus(http.StatusServiceUnavailable))
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			statusMap = StatusMap{
+				531:  http.StatusServiceUnavailable,
+				1000: http.StatusBadGateway,
+				1001: http.StatusBadGateway,
+				404:  http.StatusGone,
+			}
+		)
+
+		assert.Equal(int64(531), statusMap.WRPStatus(http.StatusServiceUnavailable))
+		assert.Equal(int64(1000), statusMap.WRPStatus(http.StatusBadGateway))
+		assert.Equal(int64(404), statusMap.WRPStatus(http.StatusGone))
+		assert.Equal(int64(404), statusMap.WRPStatus(http.StatusNotFound))
+		assert.Equal(int64(400), statusMap.WRPStatus(http.StatusBadRequest))
+
+		// every custom mapping survives a round trip from WRP to HTTP and back
+		for _, status := range []int64{531, 1000, 404} {
+			code := statusMap.StatusCode(&wrp.Message{Status: &status})
+			assert.Equal(status, statusMap.WRPStatus(code))
+		}
+	})
+
+	t.Run("SetStatus", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			statusMap = StatusMap{531: http.StatusServiceUnavailable}
+			message   wrp.Message
+		)
+
+		assert.True(statusMap.setStatus(&message, http.StatusServiceUnavailable))
+		assert.Equal(int64(531), *message.Status)
+
+		assert.False(statusMap.setStatus(&message, http.StatusNotFound))
+		assert.Equal(int64(531), *message.Status)
+	})
+}
@@ -0,0 +1,15 @@
+/*
+Package wrpmeta assembles WRP message metadata from multiple sources, such as convey data sent by devices,
+configuration, and values computed at runtime.
+
+A Builder copies fields from each Source onto the metadata, optionally renaming them, and records any requested
+field that a source did not supply.  Build reports whether every requested field was present, so that callers
+can decide whether incomplete metadata is acceptable:
+
+	metadata, complete := wrpmeta.NewBuilder().
+		Apply(wrpmeta.FromConvey(c), wrpmeta.Field{From: "hw-model"}, wrpmeta.Field{From: "fw-name", To: "/fw-name"}).
+		Apply(wrpmeta.FromMap(config), wrpmeta.Field{From: "region"}).
+		Set("/trust", "1000").
+		Build()
+*/
+package wrpmeta
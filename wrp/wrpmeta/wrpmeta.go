@@ -0,0 +1,117 @@
+package wrpmeta
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Comcast/webpa-common/convey"
+)
+
+// Field describes a single metadata field to be copied from a Source
+type Field struct {
+	// From is the key of the field within the Source
+	From string
+
+	// To is the metadata key for the field.  If unset, From is used as the metadata key.
+	To string
+}
+
+func (f Field) to() string {
+	if len(f.To) > 0 {
+		return f.To
+	}
+
+	return f.From
+}
+
+// Source is a strategy for obtaining metadata values by key
+type Source interface {
+	// GetString returns the value of a field as a string.  If the field does not exist in this source,
+	// this method returns false.
+	GetString(key string) (string, bool)
+}
+
+// SourceFunc is a function type that implements Source
+type SourceFunc func(string) (string, bool)
+
+func (sf SourceFunc) GetString(key string) (string, bool) {
+	return sf(key)
+}
+
+// FromMap produces a Source backed by a map of strings, which is typically configuration or values computed at runtime
+func FromMap(m map[string]string) Source {
+	return SourceFunc(func(key string) (string, bool) {
+		value, ok := m[key]
+		return value, ok
+	})
+}
+
+// FromConvey produces a Source backed by the convey data sent with a device connection.  String values are used as is.
+// Numbers and booleans are formatted in the same way as their JSON representation.  A nil value is treated as missing,
+// while any other value is formatted with fmt.Sprint.
+func FromConvey(c convey.C) Source {
+	return SourceFunc(func(key string) (string, bool) {
+		switch v := c[key].(type) {
+		case nil:
+			return "", false
+		case string:
+			return v, true
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		default:
+			return fmt.Sprint(v), true
+		}
+	})
+}
+
+// Builder assembles metadata from one or more Sources.  The zero value is not usable; create instances with NewBuilder.
+// A Builder is not safe for concurrent use.
+type Builder struct {
+	metadata map[string]string
+	missing  []string
+}
+
+// NewBuilder creates a Builder with no metadata
+func NewBuilder() *Builder {
+	return &Builder{
+		metadata: make(map[string]string),
+	}
+}
+
+// Apply copies each of the given fields from a Source.  Any field that the Source does not have is recorded as
+// missing, and is otherwise skipped.  A field copied from a later Source replaces the value from an earlier one.
+func (b *Builder) Apply(s Source, fields ...Field) *Builder {
+	for _, f := range fields {
+		if value, ok := s.GetString(f.From); ok {
+			b.metadata[f.to()] = value
+		} else {
+			b.missing = append(b.missing, f.From)
+		}
+	}
+
+	return b
+}
+
+// Set sets a metadata value directly
+func (b *Builder) Set(key, value string) *Builder {
+	b.metadata[key] = value
+	return b
+}
+
+// Missing returns the source keys of the fields which could not be found, in the order in which they were applied
+func (b *Builder) Missing() []string {
+	return append([]string(nil), b.missing...)
+}
+
+// Build returns a copy of the assembled metadata, along with a flag indicating whether every applied field was found.
+// The returned metadata is always non-nil, and holds every field that was found even when some fields are missing.
+func (b *Builder) Build() (map[string]string, bool) {
+	metadata := make(map[string]string, len(b.metadata))
+	for k, v := range b.metadata {
+		metadata[k] = v
+	}
+
+	return metadata, len(b.missing) == 0
+}
@@ -0,0 +1,111 @@
+package wrpmeta
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/convey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromMap(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		source = FromMap(map[string]string{"key": "value"})
+	)
+
+	value, ok := source.GetString("key")
+	assert.Equal("value", value)
+	assert.True(ok)
+
+	value, ok = source.GetString("missing")
+	assert.Empty(value)
+	assert.False(ok)
+
+	value, ok = FromMap(nil).GetString("key")
+	assert.Empty(value)
+	assert.False(ok)
+}
+
+func TestFromConvey(t *testing.T) {
+	var (
+		source = FromConvey(convey.C{
+			"string": "value",
+			"int":    float64(1234),
+			"float":  12.5,
+			"bool":   true,
+			"nil":    nil,
+			"other":  []interface{}{"a", "b"},
+		})
+
+		testData = []struct {
+			key      string
+			expected string
+			ok       bool
+		}{
+			{"string", "value", true},
+			{"int", "1234", true},
+			{"float", "12.5", true},
+			{"bool", "true", true},
+			{"nil", "", false},
+			{"missing", "", false},
+			{"other", "[a b]", true},
+		}
+	)
+
+	for _, record := range testData {
+		t.Run(record.key, func(t *testing.T) {
+			value, ok := source.GetString(record.key)
+			assert.Equal(t, record.expected, value)
+			assert.Equal(t, record.ok, ok)
+		})
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	t.Run("Complete", func(t *testing.T) {
+		assert := assert.New(t)
+
+		metadata, complete := NewBuilder().
+			Apply(FromConvey(convey.C{"hw-model": "model", "fw-name": "firmware"}), Field{From: "hw-model"}, Field{From: "fw-name", To: "/fw-name"}).
+			Apply(FromMap(map[string]string{"region": "east"}), Field{From: "region"}).
+			Set("/trust", "1000").
+			Build()
+
+		assert.True(complete)
+		assert.Equal(
+			map[string]string{"hw-model": "model", "/fw-name": "firmware", "region": "east", "/trust": "1000"},
+			metadata,
+		)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			builder = NewBuilder()
+		)
+
+		builder.
+			Apply(FromMap(map[string]string{"a": "1"}), Field{From: "a"}, Field{From: "b", To: "renamed"}).
+			Apply(FromMap(map[string]string{"a": "2"}), Field{From: "a"}, Field{From: "c"})
+
+		metadata, complete := builder.Build()
+		assert.False(complete)
+		assert.Equal(map[string]string{"a": "2"}, metadata)
+		assert.Equal([]string{"b", "c"}, builder.Missing())
+
+		// the built metadata is a copy
+		metadata["a"] = "modified"
+		metadata, _ = builder.Build()
+		assert.Equal("2", metadata["a"])
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert := assert.New(t)
+
+		metadata, complete := NewBuilder().Build()
+		assert.True(complete)
+		assert.NotNil(metadata)
+		assert.Empty(metadata)
+		assert.Empty(NewBuilder().Missing())
+	})
+}
@@ -0,0 +1,221 @@
+package wrptcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// frameHeaderLength is the size of the length prefix of each frame
+const frameHeaderLength = 8
+
+// ErrFrameTooLarge indicates that the remote side sent a frame larger than the configured maximum message size.
+// The connection cannot be resynchronized after this error, and should be closed.
+var ErrFrameTooLarge = errors.New("The frame exceeds the maximum message size")
+
+// Conn is a TCP connection that carries length-prefixed WRP frames.
+//
+// Reads must be performed from a single goroutine.  Writes are serialized internally, so Write and WriteFrame may
+// be called concurrently with each other and with reads.  Close may be called at any time from any goroutine.
+type Conn struct {
+	conn           net.Conn
+	format         wrp.Format
+	remoteProtocol Protocol
+	maxMessageSize int64
+	idlePeriod     time.Duration
+	writeTimeout   time.Duration
+
+	readHeader [frameHeaderLength]byte
+	decoder    wrp.Decoder
+
+	writeLock   sync.Mutex
+	writeHeader [frameHeaderLength]byte
+	encoder     wrp.Encoder
+	encoded     []byte
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewConn produces a Conn from an established network connection, exchanging protocol headers if the Options
+// specify a Protocol.  If the exchange fails, the network connection is closed and an error is returned.
+//
+// Listener and Dialer use this function, but it is exposed for connections established by other means.
+func NewConn(conn net.Conn, o *Options) (*Conn, error) {
+	c := &Conn{
+		conn:           conn,
+		format:         o.format(),
+		maxMessageSize: o.maxMessageSize(),
+		idlePeriod:     o.idlePeriod(),
+		writeTimeout:   o.writeTimeout(),
+		decoder:        wrp.NewDecoderBytes(nil, o.format()),
+	}
+
+	c.encoder = wrp.NewEncoderBytes(&c.encoded, c.format)
+	if protocol := o.protocol(); protocol != NoProtocol {
+		if err := c.handshake(protocol, o.handshakeTimeout()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// handshake exchanges nanomsg protocol headers with the remote side.  Both sides send their header
+// immediately, so the local header is written concurrently with reading the remote header.
+func (c *Conn) handshake(protocol Protocol, timeout time.Duration) error {
+	peer := protocol.Peer()
+	if peer == NoProtocol {
+		return ErrUnsupportedProtocol
+	}
+
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := c.conn.Write(protocol.header())
+		written <- err
+	}()
+
+	var header [protocolHeaderLength]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		return err
+	}
+
+	if err := <-written; err != nil {
+		return err
+	}
+
+	remoteProtocol, err := parseProtocolHeader(header[:])
+	if err != nil {
+		return err
+	}
+
+	if remoteProtocol != peer {
+		return ErrIncompatibleProtocol
+	}
+
+	c.remoteProtocol = remoteProtocol
+	return c.conn.SetDeadline(time.Time{})
+}
+
+// Format returns the WRP format of the frames on this connection
+func (c *Conn) Format() wrp.Format {
+	return c.format
+}
+
+// RemoteProtocol returns the nanomsg protocol of the remote side, which is NoProtocol if no headers were exchanged
+func (c *Conn) RemoteProtocol() Protocol {
+	return c.remoteProtocol
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) updateReadDeadline() error {
+	if c.idlePeriod > 0 {
+		return c.conn.SetReadDeadline(time.Now().Add(c.idlePeriod))
+	}
+
+	return nil
+}
+
+// ReadFrame returns the contents of the next frame.  When the remote side closes the connection between frames,
+// this method returns io.EOF.  Any other error indicates that this connection should be closed.
+func (c *Conn) ReadFrame() ([]byte, error) {
+	if err := c.updateReadDeadline(); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(c.conn, c.readHeader[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint64(c.readHeader[:])
+	if size > uint64(c.maxMessageSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// Read decodes the next WRP message from this connection into the given message.  As with ReadFrame,
+// io.EOF is returned when the remote side closes the connection.
+func (c *Conn) Read(m *wrp.Message) error {
+	frame, err := c.ReadFrame()
+	if err != nil {
+		return err
+	}
+
+	c.decoder.ResetBytes(frame)
+	return c.decoder.Decode(m)
+}
+
+// WriteFrame sends the given bytes, which must already be encoded in this connection's format, as a single frame
+func (c *Conn) WriteFrame(frame []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return c.writeFrame(frame)
+}
+
+// writeFrame sends the length prefix and the frame together, so that small frames are not split across
+// packets.  This method must be called under the write lock.
+func (c *Conn) writeFrame(frame []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint64(c.writeHeader[:], uint64(len(frame)))
+	buffers := net.Buffers{c.writeHeader[:], frame}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// Write encodes the given WRP message, or any value which encodes to WRP, and sends it as a single frame
+func (c *Conn) Write(v interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.encoded = c.encoded[:0]
+	c.encoder.ResetBytes(&c.encoded)
+	if err := c.encoder.Encode(v); err != nil {
+		return err
+	}
+
+	return c.writeFrame(c.encoded)
+}
+
+// Close closes the underlying network connection, which causes any pending read to fail.  This method is
+// idempotent, and subsequent calls return the result of the first call.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.conn.Close()
+	})
+
+	return c.closeErr
+}
@@ -0,0 +1,269 @@
+package wrptcp
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connPair establishes a connection between a test listener and a client.  The server side of the
+// connection is returned once the protocol exchange completes.
+func connPair(t *testing.T, server, client *Options) (*Conn, *Conn, func()) {
+	listener, err := Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+
+	accepted := make(chan *Conn, 1)
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %s", err)
+		}
+
+		accepted <- c
+	}()
+
+	clientConn, err := NewDialer(client, nil).Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+
+	serverConn := <-accepted
+	require.NotNil(t, serverConn)
+
+	return serverConn, clientConn, func() {
+		clientConn.Close()
+		serverConn.Close()
+		listener.Close()
+	}
+}
+
+func testConnReadWrite(t *testing.T, format wrp.Format, serverProtocol, clientProtocol Protocol) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, client, cleanup = connPair(
+			t,
+			&Options{Format: format, Protocol: serverProtocol},
+			&Options{Format: format, Protocol: clientProtocol},
+		)
+
+		expected = wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status",
+			Payload:     []byte("payload"),
+		}
+	)
+
+	defer cleanup()
+	assert.Equal(format, server.Format())
+	assert.Equal(format, client.Format())
+	assert.Equal(clientProtocol, server.RemoteProtocol())
+	assert.Equal(serverProtocol, client.RemoteProtocol())
+	assert.Equal(server.LocalAddr(), client.RemoteAddr())
+	assert.Equal(client.LocalAddr(), server.RemoteAddr())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(client.Write(&expected))
+
+		var actual wrp.Message
+		require.NoError(server.Read(&actual))
+		assert.Equal(expected, actual)
+	}
+
+	var encoded []byte
+	require.NoError(wrp.NewEncoderBytes(&encoded, format).Encode(&expected))
+	require.NoError(server.WriteFrame(encoded))
+	require.NoError(server.WriteFrame(nil))
+
+	frame, err := client.ReadFrame()
+	require.NoError(err)
+	assert.Equal(encoded, frame)
+
+	frame, err = client.ReadFrame()
+	require.NoError(err)
+	assert.Empty(frame)
+}
+
+func testConnFraming(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		local, remote = net.Pipe()
+		written       = make(chan []byte, 1)
+	)
+
+	c, err := NewConn(local, nil)
+	require.NoError(err)
+	defer c.Close()
+
+	go func() {
+		frame := make([]byte, frameHeaderLength+3)
+		io.ReadFull(remote, frame)
+		written <- frame
+	}()
+
+	require.NoError(c.WriteFrame([]byte("abc")))
+	assert.Equal([]byte{0, 0, 0, 0, 0, 0, 0, 3, 'a', 'b', 'c'}, <-written)
+
+	go remote.Write([]byte{0, 0, 0, 0, 0, 0, 0, 2, 'd', 'e'})
+	frame, err := c.ReadFrame()
+	require.NoError(err)
+	assert.Equal([]byte("de"), frame)
+}
+
+func testConnFrameTooLarge(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		local, remote = net.Pipe()
+		header        = make([]byte, frameHeaderLength)
+	)
+
+	c, err := NewConn(local, &Options{MaxMessageSize: 10})
+	require.NoError(err)
+	defer c.Close()
+
+	binary.BigEndian.PutUint64(header, 11)
+	go remote.Write(header)
+
+	frame, err := c.ReadFrame()
+	assert.Nil(frame)
+	assert.Equal(ErrFrameTooLarge, err)
+}
+
+func testConnTruncated(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		local, remote = net.Pipe()
+	)
+
+	c, err := NewConn(local, nil)
+	require.NoError(err)
+	defer c.Close()
+
+	go func() {
+		remote.Write([]byte{0, 0, 0, 0, 0, 0, 0, 5, 'a'})
+		remote.Close()
+	}()
+
+	frame, err := c.ReadFrame()
+	assert.Nil(frame)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func testConnClose(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, client, cleanup = connPair(t, nil, nil)
+	)
+
+	defer cleanup()
+	require.NoError(client.Close())
+	assert.NoError(client.Close())
+
+	frame, err := server.ReadFrame()
+	assert.Nil(frame)
+	assert.Equal(io.EOF, err)
+	assert.Equal(io.EOF, server.Read(new(wrp.Message)))
+}
+
+func testConnIdle(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		serverOptions = &Options{
+			IdlePeriod: 50 * time.Millisecond,
+		}
+
+		server, _, cleanup = connPair(t, serverOptions, nil)
+	)
+
+	defer cleanup()
+
+	// the client never writes, so the server receives nothing and times out
+	frame, err := server.ReadFrame()
+	assert.Nil(frame)
+	assert.Error(err)
+	assert.NotEqual(io.EOF, err)
+}
+
+func testConnHandshakeFailure(t *testing.T, local, remote *Options, expected error) {
+	var (
+		assert = assert.New(t)
+
+		localConn, remoteConn = net.Pipe()
+		remoteDone            = make(chan error, 1)
+	)
+
+	defer remoteConn.Close()
+	go func() {
+		if remote == nil {
+			// play the part of a peer that doesn't speak nanomsg, but still consumes whatever is sent
+			go io.Copy(ioutil.Discard, remoteConn)
+			_, err := remoteConn.Write([]byte("GET / HTTP/1.1\r\n"))
+			remoteDone <- err
+			return
+		}
+
+		_, err := NewConn(remoteConn, remote)
+		remoteDone <- err
+	}()
+
+	c, err := NewConn(localConn, local)
+	assert.Nil(c)
+	assert.Equal(expected, err)
+	<-remoteDone
+}
+
+func TestConn(t *testing.T) {
+	t.Run("ReadWrite", func(t *testing.T) {
+		for _, format := range wrp.AllFormats() {
+			t.Run(format.String(), func(t *testing.T) {
+				testConnReadWrite(t, format, NoProtocol, NoProtocol)
+			})
+		}
+
+		t.Run("Pair", func(t *testing.T) { testConnReadWrite(t, wrp.Msgpack, Pair, Pair) })
+		t.Run("PushPull", func(t *testing.T) { testConnReadWrite(t, wrp.Msgpack, Pull, Push) })
+		t.Run("Bus", func(t *testing.T) { testConnReadWrite(t, wrp.Msgpack, Bus, Bus) })
+	})
+
+	t.Run("Framing", testConnFraming)
+	t.Run("FrameTooLarge", testConnFrameTooLarge)
+	t.Run("Truncated", testConnTruncated)
+	t.Run("Close", testConnClose)
+	t.Run("Idle", testConnIdle)
+
+	t.Run("Handshake", func(t *testing.T) {
+		t.Run("Unsupported", func(t *testing.T) {
+			assert := assert.New(t)
+			localConn, remoteConn := net.Pipe()
+			defer remoteConn.Close()
+
+			c, err := NewConn(localConn, &Options{Protocol: Protocol(0x30)})
+			assert.Nil(c)
+			assert.Equal(ErrUnsupportedProtocol, err)
+		})
+
+		t.Run("Incompatible", func(t *testing.T) {
+			testConnHandshakeFailure(t, &Options{Protocol: Push}, &Options{Protocol: Push}, ErrIncompatibleProtocol)
+		})
+
+		t.Run("Invalid", func(t *testing.T) {
+			testConnHandshakeFailure(t, &Options{Protocol: Pair}, nil, ErrInvalidProtocolHeader)
+		})
+	})
+}
@@ -0,0 +1,15 @@
+/*
+Package wrptcp provides length-prefixed TCP transports for WRP, for interoperability with components that
+use nanomsg sockets rather than HTTP, such as parodus and its clients.
+
+Each WRP message is carried in a single frame, which is the encoded message preceded by its length as a 64-bit
+big-endian integer.  This is the framing used by nanomsg's TCP transport.  When Options.Protocol is set, each side
+of a connection also exchanges the 8-byte scalability protocol header when the connection is established, which
+allows a Conn to talk directly to a nanomsg socket of the peer protocol, e.g. a Push Conn to a nanomsg pull socket.
+Only protocols whose messages carry no protocol-specific headers are supported.
+
+Server code accepts connections with a Listener, while client code establishes connections with a Dialer.  Both
+produce a *Conn, which reads and writes WRP messages.  The Serve function dispatches each inbound message on a Conn
+to a wrpendpoint.Service, writing any responses back over the same connection.
+*/
+package wrptcp
@@ -0,0 +1,100 @@
+package wrptcp
+
+import (
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	DefaultHandshakeTimeout time.Duration = 10 * time.Second
+	DefaultWriteTimeout     time.Duration = 60 * time.Second
+
+	// DefaultMaxMessageSize is the same as nanomsg's default limit on inbound messages
+	DefaultMaxMessageSize = 1024 * 1024
+)
+
+// Options describes the configuration for TCP WRP connections.  A nil Options is valid
+// and uses the default for each setting.
+type Options struct {
+	// Format is the WRP format of each frame.  The zero value indicates Msgpack.
+	Format wrp.Format
+
+	// Protocol is the nanomsg protocol that connections identify themselves with.  If not supplied,
+	// no protocol header is exchanged, which is not compatible with nanomsg sockets.
+	Protocol Protocol
+
+	// HandshakeTimeout is the deadline for exchanging protocol headers.  If not supplied, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+
+	// MaxMessageSize is the maximum size of an inbound frame.  If not supplied, DefaultMaxMessageSize is used.
+	MaxMessageSize int64
+
+	// IdlePeriod is the length of time a connection is allowed to be idle, with no frames received from the remote side.
+	// If not supplied, connections may be idle indefinitely, since nanomsg does not send keepalives.
+	IdlePeriod time.Duration
+
+	// WriteTimeout is the deadline for each write.  If not supplied, DefaultWriteTimeout is used.
+	WriteTimeout time.Duration
+
+	// Logger is the output sink for log messages.  If not supplied, log output is sent to a NOP logger.
+	Logger log.Logger
+}
+
+func (o *Options) format() wrp.Format {
+	if o != nil {
+		return o.Format
+	}
+
+	return wrp.Msgpack
+}
+
+func (o *Options) protocol() Protocol {
+	if o != nil {
+		return o.Protocol
+	}
+
+	return NoProtocol
+}
+
+func (o *Options) handshakeTimeout() time.Duration {
+	if o != nil && o.HandshakeTimeout > 0 {
+		return o.HandshakeTimeout
+	}
+
+	return DefaultHandshakeTimeout
+}
+
+func (o *Options) maxMessageSize() int64 {
+	if o != nil && o.MaxMessageSize > 0 {
+		return o.MaxMessageSize
+	}
+
+	return DefaultMaxMessageSize
+}
+
+func (o *Options) idlePeriod() time.Duration {
+	if o != nil && o.IdlePeriod > 0 {
+		return o.IdlePeriod
+	}
+
+	return 0
+}
+
+func (o *Options) writeTimeout() time.Duration {
+	if o != nil && o.WriteTimeout > 0 {
+		return o.WriteTimeout
+	}
+
+	return DefaultWriteTimeout
+}
+
+func (o *Options) logger() log.Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
@@ -0,0 +1,59 @@
+package wrptcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefaults(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Equal(wrp.Msgpack, o.format())
+	assert.Equal(NoProtocol, o.protocol())
+	assert.Equal(DefaultHandshakeTimeout, o.handshakeTimeout())
+	assert.Equal(int64(DefaultMaxMessageSize), o.maxMessageSize())
+	assert.Zero(o.idlePeriod())
+	assert.Equal(DefaultWriteTimeout, o.writeTimeout())
+	assert.NotNil(o.logger())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		logger = logging.NewTestLogger(nil, t)
+
+		o = &Options{
+			Format:           wrp.JSON,
+			Protocol:         Pair,
+			HandshakeTimeout: 1 * time.Second,
+			MaxMessageSize:   1024,
+			IdlePeriod:       2 * time.Second,
+			WriteTimeout:     3 * time.Second,
+			Logger:           logger,
+		}
+	)
+
+	assert.Equal(wrp.JSON, o.format())
+	assert.Equal(Pair, o.protocol())
+	assert.Equal(1*time.Second, o.handshakeTimeout())
+	assert.Equal(int64(1024), o.maxMessageSize())
+	assert.Equal(2*time.Second, o.idlePeriod())
+	assert.Equal(3*time.Second, o.writeTimeout())
+	assert.Equal(logger, o.logger())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		testOptionsDefaults(t, nil)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefaults(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
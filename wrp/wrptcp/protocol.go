@@ -0,0 +1,89 @@
+package wrptcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol is a nanomsg scalability protocol identifier, as exchanged in the header of each connection
+type Protocol uint16
+
+const (
+	// NoProtocol indicates that no protocol header is exchanged, so that connections carry only WRP frames
+	NoProtocol Protocol = 0
+
+	Pair Protocol = 0x10
+	Pub  Protocol = 0x20
+	Sub  Protocol = 0x21
+	Push Protocol = 0x50
+	Pull Protocol = 0x51
+	Bus  Protocol = 0x70
+)
+
+// protocolHeaderLength is the size of the header exchanged by each side of a nanomsg TCP connection
+const protocolHeaderLength = 8
+
+var (
+	// ErrUnsupportedProtocol indicates that the local protocol is not one that can carry WRP frames as is
+	ErrUnsupportedProtocol = errors.New("Unsupported nanomsg protocol")
+
+	// ErrInvalidProtocolHeader indicates that the remote side did not send a nanomsg protocol header
+	ErrInvalidProtocolHeader = errors.New("Invalid nanomsg protocol header")
+
+	// ErrIncompatibleProtocol indicates that the remote side's protocol is not the peer of the local protocol
+	ErrIncompatibleProtocol = errors.New("Incompatible nanomsg protocol")
+)
+
+var protocolStrings = map[Protocol]string{
+	NoProtocol: "None",
+	Pair:       "Pair",
+	Pub:        "Pub",
+	Sub:        "Sub",
+	Push:       "Push",
+	Pull:       "Pull",
+	Bus:        "Bus",
+}
+
+func (p Protocol) String() string {
+	if s, ok := protocolStrings[p]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Protocol(%d)", uint16(p))
+}
+
+// Peer returns the protocol that the remote side of a connection must use.  For symmetric protocols such as Pair,
+// this is the protocol itself.  Unsupported protocols have no peer, in which case this method returns NoProtocol.
+func (p Protocol) Peer() Protocol {
+	switch p {
+	case Pair, Bus:
+		return p
+	case Pub:
+		return Sub
+	case Sub:
+		return Pub
+	case Push:
+		return Pull
+	case Pull:
+		return Push
+	default:
+		return NoProtocol
+	}
+}
+
+// header returns the nanomsg protocol header for this protocol
+func (p Protocol) header() []byte {
+	header := []byte{0x00, 'S', 'P', 0x00, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(header[4:6], uint16(p))
+	return header
+}
+
+// parseProtocolHeader extracts the protocol from a nanomsg protocol header
+func parseProtocolHeader(header []byte) (Protocol, error) {
+	if len(header) != protocolHeaderLength || header[0] != 0x00 || header[1] != 'S' || header[2] != 'P' || header[3] != 0x00 {
+		return NoProtocol, ErrInvalidProtocolHeader
+	}
+
+	return Protocol(binary.BigEndian.Uint16(header[4:6])), nil
+}
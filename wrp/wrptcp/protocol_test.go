@@ -0,0 +1,42 @@
+package wrptcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocol(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		peers = map[Protocol]Protocol{
+			NoProtocol:     NoProtocol,
+			Pair:           Pair,
+			Pub:            Sub,
+			Sub:            Pub,
+			Push:           Pull,
+			Pull:           Push,
+			Bus:            Bus,
+			Protocol(0x30): NoProtocol,
+		}
+	)
+
+	for protocol, expected := range peers {
+		assert.Equal(expected, protocol.Peer(), protocol.String())
+		assert.NotEmpty(protocol.String())
+
+		actual, err := parseProtocolHeader(protocol.header())
+		assert.Equal(protocol, actual)
+		assert.NoError(err)
+	}
+
+	assert.Equal([]byte{0x00, 'S', 'P', 0x00, 0x00, 0x50, 0x00, 0x00}, Push.header())
+	assert.Equal("Protocol(48)", Protocol(0x30).String())
+
+	for _, invalid := range [][]byte{nil, []byte("SP"), []byte("GET / HTTP/1.1")[:8]} {
+		actual, err := parseProtocolHeader(invalid)
+		assert.Equal(NoProtocol, actual)
+		assert.Equal(ErrInvalidProtocolHeader, err)
+	}
+}
@@ -0,0 +1,165 @@
+package wrptcp
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/go-kit/kit/log"
+)
+
+// Listener is the server side transport, which accepts WRP connections
+type Listener struct {
+	listener net.Listener
+	options  *Options
+}
+
+// NewListener produces a Listener from an existing net.Listener and a set of Options, which may be nil.
+// The Options are retained and should not be modified afterward.
+func NewListener(l net.Listener, o *Options) *Listener {
+	return &Listener{
+		listener: l,
+		options:  o,
+	}
+}
+
+// Listen announces on the given network address, e.g. Listen("tcp", "127.0.0.1:6666", o)
+func Listen(network, address string, o *Options) (*Listener, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListener(l, o), nil
+}
+
+// Accept waits for the next connection and exchanges protocol headers with it, if configured.  Since the exchange
+// happens on the calling goroutine, a slow client delays subsequent accepts by up to the handshake timeout.
+//
+// A connection whose protocol exchange fails is closed and its error is returned.  Such errors do not affect
+// the Listener, and callers should continue to accept connections.
+func (l *Listener) Accept() (*Conn, error) {
+	conn, err := l.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(conn, l.options)
+}
+
+// Addr returns the address this Listener is bound to
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close stops this Listener.  Connections that were already accepted are not affected.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+// Dialer is the client side transport, which establishes WRP connections
+type Dialer struct {
+	dialer  net.Dialer
+	options *Options
+}
+
+// NewDialer produces a Dialer from a set of Options and an optional net.Dialer.  If the net.Dialer is
+// supplied, it is copied.
+func NewDialer(o *Options, d *net.Dialer) *Dialer {
+	dialer := &Dialer{options: o}
+	if d != nil {
+		dialer.dialer = *d
+	}
+
+	return dialer
+}
+
+// Dial connects to the given network address
+func (d *Dialer) Dial(network, address string) (*Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the given network address using the supplied context.  The context only
+// applies to establishing the network connection, not to the protocol exchange.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (*Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConn(conn, d.options)
+}
+
+// Serve reads WRP messages from the given connection and dispatches each of them to the service, writing any
+// non-nil response back over the connection.  Messages are processed one at a time, in the order received.
+// Errors from the service are logged and do not terminate the connection.  For one-way protocols such as Pull,
+// the service should not produce responses.
+//
+// This function returns when the connection fails, the remote side closes it, or the context is canceled.  A close
+// by the remote side returns nil.  The connection is always closed when this function returns.
+func Serve(ctx context.Context, logger log.Logger, c *Conn, service wrpendpoint.Service) error {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	defer c.Close()
+
+	// unblock any pending read when the context is canceled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-stop:
+		}
+	}()
+
+	var (
+		decoders = wrp.NewDecoderPool(1, c.Format())
+		encoders = wrp.NewEncoderPool(1, c.Format())
+		errorLog = logging.Error(logger)
+	)
+
+	for {
+		frame, err := c.ReadFrame()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		request, err := wrpendpoint.DecodeRequestBytes(logger, frame, decoders)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "Unable to decode WRP message", logging.ErrorKey(), err)
+			continue
+		}
+
+		response, err := service.ServeWRP(ctx, request)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "WRP service failed", logging.ErrorKey(), err)
+			continue
+		}
+
+		if response == nil {
+			continue
+		}
+
+		encoded, err := response.EncodeBytes(encoders)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "Unable to encode WRP response", logging.ErrorKey(), err)
+			continue
+		}
+
+		if err := c.WriteFrame(encoded); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package wrptcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testListenerFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := Listen("tcp", "not a valid address", nil)
+	assert.Nil(l)
+	assert.Error(err)
+}
+
+func testListenerHandshakeFailure(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := Listen("tcp", "127.0.0.1:0", &Options{Protocol: Pull, HandshakeTimeout: 50 * time.Millisecond})
+	require.NoError(err)
+	defer l.Close()
+
+	// the client connects but never sends a protocol header
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer client.Close()
+
+	c, err := l.Accept()
+	assert.Nil(c)
+	assert.Error(err)
+}
+
+func TestListener(t *testing.T) {
+	t.Run("Failure", testListenerFailure)
+	t.Run("HandshakeFailure", testListenerHandshakeFailure)
+}
+
+func testDialerCopy(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		dialer = &net.Dialer{Timeout: 5 * time.Second}
+		d      = NewDialer(nil, dialer)
+	)
+
+	assert.Equal(5*time.Second, d.dialer.Timeout)
+	dialer.Timeout = time.Second
+	assert.Equal(5*time.Second, d.dialer.Timeout)
+}
+
+func testDialerFailure(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	// reserve an address, then release it so that nothing is listening
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	address := l.Addr().String()
+	l.Close()
+
+	c, err := NewDialer(nil, nil).Dial("tcp", address)
+	assert.Nil(c)
+	assert.Error(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c, err = NewDialer(nil, nil).DialContext(ctx, "tcp", address)
+	assert.Nil(c)
+	assert.Error(err)
+}
+
+func TestDialer(t *testing.T) {
+	t.Run("Copy", testDialerCopy)
+	t.Run("Failure", testDialerFailure)
+}
+
+func testServe(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+		options = &Options{Protocol: Pair}
+
+		server, client, cleanup = connPair(t, options, options)
+		serveDone               = make(chan error, 1)
+
+		service = wrpendpoint.ServiceFunc(func(ctx context.Context, request wrpendpoint.Request) (wrpendpoint.Response, error) {
+			m := request.Message()
+			switch m.Destination {
+			case "fail":
+				return nil, errors.New("expected")
+			case "event":
+				return nil, nil
+			}
+
+			return wrpendpoint.WrapAsResponse(&wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          m.Destination,
+				Destination:     m.Source,
+				TransactionUUID: m.TransactionUUID,
+			}), nil
+		})
+	)
+
+	defer cleanup()
+	go func() {
+		serveDone <- Serve(context.Background(), logger, server, service)
+	}()
+
+	// neither undecodable frames, errors, nor missing responses stop the loop
+	require.NoError(client.WriteFrame([]byte{0xc1}))
+	require.NoError(client.Write(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Destination: "fail"}))
+	require.NoError(client.Write(&wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "event"}))
+	require.NoError(client.Write(&wrp.Message{
+		Type:            wrp.SimpleRequestResponseMessageType,
+		Source:          "mac:112233445566",
+		Destination:     "dns:somewhere.comcast.net",
+		TransactionUUID: "1234",
+	}))
+
+	var response wrp.Message
+	require.NoError(client.Read(&response))
+	assert.Equal("dns:somewhere.comcast.net", response.Source)
+	assert.Equal("mac:112233445566", response.Destination)
+	assert.Equal("1234", response.TransactionUUID)
+
+	require.NoError(client.Close())
+	assert.NoError(<-serveDone)
+}
+
+func testServeCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+
+		server, _, cleanup = connPair(t, nil, nil)
+		serveDone          = make(chan error, 1)
+
+		service = wrpendpoint.ServiceFunc(func(context.Context, wrpendpoint.Request) (wrpendpoint.Response, error) {
+			return nil, nil
+		})
+	)
+
+	defer cleanup()
+	go func() {
+		serveDone <- Serve(ctx, nil, server, service)
+	}()
+
+	cancel()
+	assert.Equal(context.Canceled, <-serveDone)
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Messages", testServe)
+	t.Run("Canceled", testServeCanceled)
+}
@@ -0,0 +1,179 @@
+package wrpws
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+)
+
+// Conn is a websocket connection that carries WRP messages, one message per binary frame.
+//
+// Reads must be performed from a single goroutine, as with the underlying gorilla connection.  Writes, including
+// the keepalive pings sent by this Conn, are serialized internally, so Write and WriteFrame may be called concurrently
+// with each other and with reads.  Close may be called at any time from any goroutine.
+type Conn struct {
+	webSocket    *websocket.Conn
+	format       wrp.Format
+	idlePeriod   time.Duration
+	writeTimeout time.Duration
+
+	decoder wrp.Decoder
+
+	writeLock sync.Mutex
+	encoder   wrp.Encoder
+	encoded   []byte
+
+	closeOnce sync.Once
+	closeErr  error
+	shutdown  chan struct{}
+}
+
+func newConn(webSocket *websocket.Conn, o *Options) *Conn {
+	c := &Conn{
+		webSocket:    webSocket,
+		format:       o.format(),
+		idlePeriod:   o.idlePeriod(),
+		writeTimeout: o.writeTimeout(),
+		decoder:      wrp.NewDecoderBytes(nil, o.format()),
+		shutdown:     make(chan struct{}),
+	}
+
+	c.encoder = wrp.NewEncoderBytes(&c.encoded, c.format)
+	if size := o.maxMessageSize(); size > 0 {
+		webSocket.SetReadLimit(size)
+	}
+
+	// every pong from the remote side extends the idle period
+	webSocket.SetPongHandler(func(string) error {
+		return c.updateReadDeadline()
+	})
+
+	go c.keepAlive(o.pingPeriod())
+	return c
+}
+
+// Format returns the WRP format of the frames on this connection
+func (c *Conn) Format() wrp.Format {
+	return c.format
+}
+
+// Subprotocol returns the negotiated websocket subprotocol, which may be empty
+func (c *Conn) Subprotocol() string {
+	return c.webSocket.Subprotocol()
+}
+
+func (c *Conn) updateReadDeadline() error {
+	return c.webSocket.SetReadDeadline(time.Now().Add(c.idlePeriod))
+}
+
+func (c *Conn) nextWriteDeadline() time.Time {
+	return time.Now().Add(c.writeTimeout)
+}
+
+// keepAlive pings the remote side until this connection is closed.  A failed ping closes the connection,
+// which causes any pending read to fail.
+func (c *Conn) keepAlive(pingPeriod time.Duration) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.shutdown:
+			return
+		case <-ticker.C:
+			if err := c.webSocket.WriteControl(websocket.PingMessage, nil, c.nextWriteDeadline()); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// ReadFrame returns the contents of the next binary frame.  Frames of other types, which do not carry WRP,
+// are skipped.  When the remote side closes the connection normally, this method returns io.EOF.  Any other error
+// indicates that this connection should be closed.
+func (c *Conn) ReadFrame() ([]byte, error) {
+	for {
+		if err := c.updateReadDeadline(); err != nil {
+			return nil, err
+		}
+
+		messageType, frame, err := c.webSocket.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil, io.EOF
+			}
+
+			return nil, err
+		}
+
+		if messageType == websocket.BinaryMessage {
+			return frame, nil
+		}
+	}
+}
+
+// Read decodes the next WRP message from this connection into the given message.  As with ReadFrame,
+// io.EOF is returned when the remote side closes the connection normally.
+func (c *Conn) Read(m *wrp.Message) error {
+	frame, err := c.ReadFrame()
+	if err != nil {
+		return err
+	}
+
+	c.decoder.ResetBytes(frame)
+	return c.decoder.Decode(m)
+}
+
+// WriteFrame sends the given bytes, which must already be encoded in this connection's format, as a single binary frame
+func (c *Conn) WriteFrame(frame []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	return c.writeFrame(frame)
+}
+
+// writeFrame sends a binary frame.  This method must be called under the write lock.
+func (c *Conn) writeFrame(frame []byte) error {
+	if err := c.webSocket.SetWriteDeadline(c.nextWriteDeadline()); err != nil {
+		return err
+	}
+
+	return c.webSocket.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// Write encodes the given WRP message, or any value which encodes to WRP, and sends it as a single binary frame
+func (c *Conn) Write(v interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.encoded = c.encoded[:0]
+	c.encoder.ResetBytes(&c.encoded)
+	if err := c.encoder.Encode(v); err != nil {
+		return err
+	}
+
+	return c.writeFrame(c.encoded)
+}
+
+// Close sends a normal close frame to the remote side, stops the keepalive pings, and closes the underlying
+// network connection.  This method is idempotent, and subsequent calls return the result of the first call.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.shutdown)
+
+		// the close frame is a courtesy, since the remote side may already be gone
+		c.webSocket.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			c.nextWriteDeadline(),
+		)
+
+		c.closeErr = c.webSocket.Close()
+	})
+
+	return c.closeErr
+}
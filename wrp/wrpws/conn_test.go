@@ -0,0 +1,194 @@
+package wrpws
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connPair establishes a connection between a test server and a client.  The server side of the
+// connection is returned once the handshake completes.
+func connPair(t *testing.T, server, client *Options) (*Conn, *Conn, func()) {
+	var (
+		upgrader = NewUpgrader(server)
+		accepted = make(chan *Conn, 1)
+	)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		c, err := upgrader.Upgrade(response, request, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %s", err)
+			close(accepted)
+			return
+		}
+
+		accepted <- c
+	}))
+
+	clientConn, response, err := NewDialer(client, nil).Dial("ws"+strings.TrimPrefix(testServer.URL, "http"), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+
+	serverConn := <-accepted
+	require.NotNil(t, serverConn)
+
+	return serverConn, clientConn, func() {
+		clientConn.Close()
+		serverConn.Close()
+		testServer.Close()
+	}
+}
+
+func testConnReadWrite(t *testing.T, format wrp.Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		options = &Options{Format: format}
+
+		server, client, cleanup = connPair(t, options, options)
+
+		expected = wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status",
+			Payload:     []byte("payload"),
+		}
+	)
+
+	defer cleanup()
+	assert.Equal(format, server.Format())
+	assert.Equal(format, client.Format())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(client.Write(&expected))
+
+		var actual wrp.Message
+		require.NoError(server.Read(&actual))
+		assert.Equal(expected, actual)
+	}
+
+	var encoded []byte
+	require.NoError(wrp.NewEncoderBytes(&encoded, format).Encode(&expected))
+	require.NoError(server.WriteFrame(encoded))
+
+	frame, err := client.ReadFrame()
+	require.NoError(err)
+	assert.Equal(encoded, frame)
+}
+
+func testConnSkipsTextFrames(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, client, cleanup = connPair(t, nil, nil)
+	)
+
+	defer cleanup()
+	require.NoError(client.webSocket.WriteMessage(websocket.TextMessage, []byte("not WRP")))
+	require.NoError(client.WriteFrame([]byte("binary")))
+
+	frame, err := server.ReadFrame()
+	require.NoError(err)
+	assert.Equal([]byte("binary"), frame)
+}
+
+func testConnClose(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, client, cleanup = connPair(t, nil, nil)
+	)
+
+	defer cleanup()
+	require.NoError(client.Close())
+	assert.NoError(client.Close())
+
+	frame, err := server.ReadFrame()
+	assert.Nil(frame)
+	assert.Equal(io.EOF, err)
+	assert.Equal(io.EOF, server.Read(new(wrp.Message)))
+}
+
+func testConnKeepAlive(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		serverOptions = &Options{
+			PingPeriod: 10 * time.Millisecond,
+			IdlePeriod: 100 * time.Millisecond,
+		}
+
+		server, client, cleanup = connPair(t, serverOptions, nil)
+		clientDone              = make(chan error, 1)
+	)
+
+	defer cleanup()
+
+	// the client only reads, which is what services the server's pings
+	go func() {
+		_, err := client.ReadFrame()
+		clientDone <- err
+	}()
+
+	// the pongs keep the server from timing out, so this read only ends when the client closes
+	serverDone := make(chan error, 1)
+	go func() {
+		_, err := server.ReadFrame()
+		serverDone <- err
+	}()
+
+	select {
+	case err := <-serverDone:
+		assert.Fail("The server read should not have ended", "%s", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	client.Close()
+	assert.Equal(io.EOF, <-serverDone)
+	assert.Error(<-clientDone)
+}
+
+func testConnIdle(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		serverOptions = &Options{
+			IdlePeriod: 50 * time.Millisecond,
+		}
+
+		server, _, cleanup = connPair(t, serverOptions, nil)
+	)
+
+	defer cleanup()
+
+	// the client never reads, so the server receives nothing and times out
+	frame, err := server.ReadFrame()
+	assert.Nil(frame)
+	assert.Error(err)
+	assert.NotEqual(io.EOF, err)
+}
+
+func TestConn(t *testing.T) {
+	t.Run("ReadWrite", func(t *testing.T) {
+		for _, format := range wrp.AllFormats() {
+			t.Run(format.String(), func(t *testing.T) {
+				testConnReadWrite(t, format)
+			})
+		}
+	})
+
+	t.Run("SkipsTextFrames", testConnSkipsTextFrames)
+	t.Run("Close", testConnClose)
+	t.Run("KeepAlive", testConnKeepAlive)
+	t.Run("Idle", testConnIdle)
+}
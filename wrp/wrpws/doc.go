@@ -0,0 +1,10 @@
+/*
+Package wrpws provides WebSocket transports for WRP.  Each WRP message is carried in a single binary websocket
+frame, encoded in the format configured for the connection.
+
+Server code upgrades inbound HTTP requests with an Upgrader, while client code establishes connections with a Dialer.
+Both produce a *Conn, which reads and writes WRP messages, keeps the connection alive with pings, and enforces an
+idle period against the remote side.  The Serve function dispatches each inbound message on a Conn to a
+wrpendpoint.Service, writing any responses back over the same connection.
+*/
+package wrpws
@@ -0,0 +1,135 @@
+package wrpws
+
+import (
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	DefaultHandshakeTimeout time.Duration = 10 * time.Second
+	DefaultIdlePeriod       time.Duration = 135 * time.Second
+	DefaultWriteTimeout     time.Duration = 60 * time.Second
+	DefaultPingPeriod       time.Duration = 45 * time.Second
+
+	DefaultReadBufferSize  = 4096
+	DefaultWriteBufferSize = 4096
+)
+
+// Options describes the configuration for websocket WRP connections.  A nil Options is valid
+// and uses the default for each setting.
+type Options struct {
+	// Format is the WRP format of each frame.  The zero value indicates Msgpack.
+	Format wrp.Format
+
+	// HandshakeTimeout is the websocket handshake timeout.  If not supplied, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+
+	// ReadBufferSize is the size of websocket read buffers.  If not supplied, DefaultReadBufferSize is used.
+	ReadBufferSize int
+
+	// WriteBufferSize is the size of websocket write buffers.  If not supplied, DefaultWriteBufferSize is used.
+	WriteBufferSize int
+
+	// Subprotocols is the optional slice of websocket subprotocols to use.
+	Subprotocols []string
+
+	// MaxMessageSize is the maximum size of an inbound frame.  If not supplied, no limit is imposed.
+	MaxMessageSize int64
+
+	// PingPeriod is the time between pings sent to the remote side.  If not supplied, DefaultPingPeriod is used.
+	PingPeriod time.Duration
+
+	// IdlePeriod is the length of time a connection is allowed to be idle, with no frames or pongs
+	// received from the remote side.  If not supplied, DefaultIdlePeriod is used.
+	IdlePeriod time.Duration
+
+	// WriteTimeout is the deadline for each websocket write.  If not supplied, DefaultWriteTimeout is used.
+	WriteTimeout time.Duration
+
+	// Logger is the output sink for log messages.  If not supplied, log output is sent to a NOP logger.
+	Logger log.Logger
+}
+
+func (o *Options) format() wrp.Format {
+	if o != nil {
+		return o.Format
+	}
+
+	return wrp.Msgpack
+}
+
+func (o *Options) handshakeTimeout() time.Duration {
+	if o != nil && o.HandshakeTimeout > 0 {
+		return o.HandshakeTimeout
+	}
+
+	return DefaultHandshakeTimeout
+}
+
+func (o *Options) readBufferSize() int {
+	if o != nil && o.ReadBufferSize > 0 {
+		return o.ReadBufferSize
+	}
+
+	return DefaultReadBufferSize
+}
+
+func (o *Options) writeBufferSize() int {
+	if o != nil && o.WriteBufferSize > 0 {
+		return o.WriteBufferSize
+	}
+
+	return DefaultWriteBufferSize
+}
+
+func (o *Options) subprotocols() (subprotocols []string) {
+	if o != nil && len(o.Subprotocols) > 0 {
+		subprotocols = make([]string, len(o.Subprotocols))
+		copy(subprotocols, o.Subprotocols)
+	}
+
+	return
+}
+
+func (o *Options) maxMessageSize() int64 {
+	if o != nil && o.MaxMessageSize > 0 {
+		return o.MaxMessageSize
+	}
+
+	return 0
+}
+
+func (o *Options) pingPeriod() time.Duration {
+	if o != nil && o.PingPeriod > 0 {
+		return o.PingPeriod
+	}
+
+	return DefaultPingPeriod
+}
+
+func (o *Options) idlePeriod() time.Duration {
+	if o != nil && o.IdlePeriod > 0 {
+		return o.IdlePeriod
+	}
+
+	return DefaultIdlePeriod
+}
+
+func (o *Options) writeTimeout() time.Duration {
+	if o != nil && o.WriteTimeout > 0 {
+		return o.WriteTimeout
+	}
+
+	return DefaultWriteTimeout
+}
+
+func (o *Options) logger() log.Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
@@ -0,0 +1,68 @@
+package wrpws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefaults(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Equal(wrp.Msgpack, o.format())
+	assert.Equal(DefaultHandshakeTimeout, o.handshakeTimeout())
+	assert.Equal(DefaultReadBufferSize, o.readBufferSize())
+	assert.Equal(DefaultWriteBufferSize, o.writeBufferSize())
+	assert.Empty(o.subprotocols())
+	assert.Zero(o.maxMessageSize())
+	assert.Equal(DefaultPingPeriod, o.pingPeriod())
+	assert.Equal(DefaultIdlePeriod, o.idlePeriod())
+	assert.Equal(DefaultWriteTimeout, o.writeTimeout())
+	assert.NotNil(o.logger())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		logger = logging.NewTestLogger(nil, t)
+
+		o = &Options{
+			Format:           wrp.JSON,
+			HandshakeTimeout: 1 * time.Second,
+			ReadBufferSize:   100,
+			WriteBufferSize:  200,
+			Subprotocols:     []string{"wrp"},
+			MaxMessageSize:   1024,
+			PingPeriod:       2 * time.Second,
+			IdlePeriod:       3 * time.Second,
+			WriteTimeout:     4 * time.Second,
+			Logger:           logger,
+		}
+	)
+
+	assert.Equal(wrp.JSON, o.format())
+	assert.Equal(1*time.Second, o.handshakeTimeout())
+	assert.Equal(100, o.readBufferSize())
+	assert.Equal(200, o.writeBufferSize())
+	assert.Equal([]string{"wrp"}, o.subprotocols())
+	assert.Equal(int64(1024), o.maxMessageSize())
+	assert.Equal(2*time.Second, o.pingPeriod())
+	assert.Equal(3*time.Second, o.idlePeriod())
+	assert.Equal(4*time.Second, o.writeTimeout())
+	assert.Equal(logger, o.logger())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		testOptionsDefaults(t, nil)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefaults(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
@@ -0,0 +1,146 @@
+package wrpws
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/websocket"
+)
+
+// Upgrader is the server side transport, which upgrades HTTP requests to WRP websocket connections
+type Upgrader struct {
+	upgrader websocket.Upgrader
+	options  *Options
+}
+
+// NewUpgrader produces an Upgrader from a set of Options, which may be nil.  The Options are retained
+// and should not be modified afterward.
+func NewUpgrader(o *Options) *Upgrader {
+	return &Upgrader{
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: o.handshakeTimeout(),
+			ReadBufferSize:   o.readBufferSize(),
+			WriteBufferSize:  o.writeBufferSize(),
+			Subprotocols:     o.subprotocols(),
+		},
+		options: o,
+	}
+}
+
+// Upgrade upgrades the given HTTP request.  If the upgrade fails, an HTTP error response has already been
+// written and the returned error describes the failure.
+func (u *Upgrader) Upgrade(response http.ResponseWriter, request *http.Request, responseHeader http.Header) (*Conn, error) {
+	webSocket, err := u.upgrader.Upgrade(response, request, responseHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(webSocket, u.options), nil
+}
+
+// Dialer is the client side transport, which establishes WRP websocket connections
+type Dialer struct {
+	dialer  websocket.Dialer
+	options *Options
+}
+
+// NewDialer produces a Dialer from a set of Options and an optional gorilla Dialer.  If the gorilla Dialer is
+// supplied, it is copied and the Options override its buffer sizes, handshake timeout, and subprotocols.
+func NewDialer(o *Options, d *websocket.Dialer) *Dialer {
+	dialer := &Dialer{options: o}
+	if d != nil {
+		dialer.dialer = *d
+	}
+
+	dialer.dialer.HandshakeTimeout = o.handshakeTimeout()
+	dialer.dialer.ReadBufferSize = o.readBufferSize()
+	dialer.dialer.WriteBufferSize = o.writeBufferSize()
+	dialer.dialer.Subprotocols = o.subprotocols()
+	return dialer
+}
+
+// Dial connects to the given websocket URL.  The HTTP response is returned whenever the server responded,
+// even if the handshake failed, to allow callers to inspect the failure.
+func (d *Dialer) Dial(URL string, requestHeader http.Header) (*Conn, *http.Response, error) {
+	webSocket, response, err := d.dialer.Dial(URL, requestHeader)
+	if err != nil {
+		return nil, response, err
+	}
+
+	return newConn(webSocket, d.options), response, nil
+}
+
+// Serve reads WRP messages from the given connection and dispatches each of them to the service, writing any
+// non-nil response back over the connection.  Messages are processed one at a time, in the order received.
+// Errors from the service are logged and do not terminate the connection.
+//
+// This function returns when the connection fails, the remote side closes it, or the context is canceled.  A normal
+// close by the remote side returns nil.  The connection is always closed when this function returns.
+func Serve(ctx context.Context, logger log.Logger, c *Conn, service wrpendpoint.Service) error {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	defer c.Close()
+
+	// unblock any pending read when the context is canceled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-stop:
+		}
+	}()
+
+	var (
+		decoders = wrp.NewDecoderPool(1, c.Format())
+		encoders = wrp.NewEncoderPool(1, c.Format())
+		errorLog = logging.Error(logger)
+	)
+
+	for {
+		frame, err := c.ReadFrame()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		request, err := wrpendpoint.DecodeRequestBytes(logger, frame, decoders)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "Unable to decode WRP message", logging.ErrorKey(), err)
+			continue
+		}
+
+		response, err := service.ServeWRP(ctx, request)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "WRP service failed", logging.ErrorKey(), err)
+			continue
+		}
+
+		if response == nil {
+			continue
+		}
+
+		encoded, err := response.EncodeBytes(encoders)
+		if err != nil {
+			errorLog.Log(logging.MessageKey(), "Unable to encode WRP response", logging.ErrorKey(), err)
+			continue
+		}
+
+		if err := c.WriteFrame(encoded); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package wrpws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUpgraderFailure(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	c, err := NewUpgrader(nil).Upgrade(response, request, nil)
+	assert.Nil(c)
+	assert.Error(err)
+	assert.Equal(http.StatusBadRequest, response.Code)
+}
+
+func TestUpgrader(t *testing.T) {
+	t.Run("Failure", testUpgraderFailure)
+}
+
+func testDialerOverrides(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		gorilla = &websocket.Dialer{
+			ReadBufferSize: 1,
+			Subprotocols:   []string{"ignored"},
+		}
+
+		d = NewDialer(&Options{ReadBufferSize: 123, Subprotocols: []string{"wrp"}}, gorilla)
+	)
+
+	assert.Equal(123, d.dialer.ReadBufferSize)
+	assert.Equal(DefaultWriteBufferSize, d.dialer.WriteBufferSize)
+	assert.Equal(DefaultHandshakeTimeout, d.dialer.HandshakeTimeout)
+	assert.Equal([]string{"wrp"}, d.dialer.Subprotocols)
+	assert.Equal(1, gorilla.ReadBufferSize)
+}
+
+func testDialerFailure(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		testServer = httptest.NewServer(http.NotFoundHandler())
+	)
+
+	defer testServer.Close()
+	c, response, err := NewDialer(nil, nil).Dial("ws"+testServer.URL[len("http"):], nil)
+	assert.Nil(c)
+	assert.Error(err)
+	if assert.NotNil(response) {
+		assert.Equal(http.StatusNotFound, response.StatusCode)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	t.Run("Overrides", testDialerOverrides)
+	t.Run("Failure", testDialerFailure)
+}
+
+func testServe(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		server, client, cleanup = connPair(t, nil, nil)
+		serveDone               = make(chan error, 1)
+
+		service = wrpendpoint.ServiceFunc(func(ctx context.Context, request wrpendpoint.Request) (wrpendpoint.Response, error) {
+			m := request.Message()
+			switch m.Destination {
+			case "fail":
+				return nil, errors.New("expected")
+			case "event":
+				return nil, nil
+			}
+
+			return wrpendpoint.WrapAsResponse(&wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          m.Destination,
+				Destination:     m.Source,
+				TransactionUUID: m.TransactionUUID,
+			}), nil
+		})
+	)
+
+	defer cleanup()
+	go func() {
+		serveDone <- Serve(context.Background(), logger, server, service)
+	}()
+
+	// neither undecodable frames, errors, nor missing responses stop the loop
+	require.NoError(client.WriteFrame([]byte{0xc1}))
+	require.NoError(client.Write(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Destination: "fail"}))
+	require.NoError(client.Write(&wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "event"}))
+	require.NoError(client.Write(&wrp.Message{
+		Type:            wrp.SimpleRequestResponseMessageType,
+		Source:          "mac:112233445566",
+		Destination:     "dns:somewhere.comcast.net",
+		TransactionUUID: "1234",
+	}))
+
+	var response wrp.Message
+	require.NoError(client.Read(&response))
+	assert.Equal("dns:somewhere.comcast.net", response.Source)
+	assert.Equal("mac:112233445566", response.Destination)
+	assert.Equal("1234", response.TransactionUUID)
+
+	require.NoError(client.Close())
+	assert.NoError(<-serveDone)
+}
+
+func testServeCanceled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+
+		server, _, cleanup = connPair(t, nil, nil)
+		serveDone          = make(chan error, 1)
+
+		service = wrpendpoint.ServiceFunc(func(context.Context, wrpendpoint.Request) (wrpendpoint.Response, error) {
+			return nil, nil
+		})
+	)
+
+	defer cleanup()
+	go func() {
+		serveDone <- Serve(ctx, nil, server, service)
+	}()
+
+	cancel()
+	assert.Equal(context.Canceled, <-serveDone)
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Messages", testServe)
+	t.Run("Canceled", testServeCanceled)
+}